@@ -0,0 +1,10 @@
+// build.rs - Erzeugt den gRPC-Server aus proto/preconf.proto
+//
+// protox statt protoc, damit der Build keinen System-Protobuf-Compiler braucht.
+
+fn main() -> Result<(), Box<dyn std::error::Error>> {
+    println!("cargo:rerun-if-changed=proto/preconf.proto");
+    let descriptors = protox::compile(["proto/preconf.proto"], ["proto"])?;
+    tonic_build::configure().build_client(false).compile_fds(descriptors)?;
+    Ok(())
+}
@@ -50,6 +50,49 @@ typedef struct {
   uint32_t bitmask_gaps;       /* Präzise Gaps via Bitmask-Tracking */
 } KonaBridgeStats;
 
+/* Speicher-Statistiken des Preconf-Stores */
+typedef struct {
+  uint64_t entries;          /* Gespeicherte Blöcke (.raw Dateien) */
+  uint64_t total_bytes;      /* Belegter Platz inkl. Metadaten und Blobs */
+  uint64_t oldest_block;     /* Ältester gespeicherter Block (0 = keiner) */
+  uint64_t newest_block;     /* Neuester gespeicherter Block (0 = keiner) */
+  uint64_t oldest_age_secs;  /* Alter des ältesten Blocks in Sekunden */
+  uint64_t newest_age_secs;  /* Alter des neuesten Blocks in Sekunden */
+  uint64_t stored_http;      /* Seit Start über HTTP gespeicherte Blöcke */
+  uint64_t stored_gossip;    /* Seit Start über Gossip gespeicherte Blöcke */
+  uint64_t stored_other;     /* Seit Start aus sonstigen Quellen gespeicherte Blöcke */
+  uint64_t evicted_ttl;      /* Seit Start per TTL gelöschte Blöcke */
+  uint64_t evicted_quota;    /* Seit Start per Quota gelöschte Blöcke */
+  uint64_t evicted_finality; /* Seit Start per Safe-/Finalized-Retention gelöschte Blöcke */
+  uint64_t invalid_signatures; /* Seit Start abgelehnte Signer (verworfen, quarantäniert oder markiert) */
+  uint64_t equivocations;      /* Seit Start erkannte Sequencer-Equivocations (Evidence unter equivocations/) */
+  uint64_t oversized_payloads; /* Seit Start verworfene Payloads über KONA_BRIDGE_MAX_PAYLOAD_KB */
+  uint64_t gossip_dropped;     /* Seit Start verworfene Gossip-Nachrichten (KONA_BRIDGE_GOSSIP_DROP_POLICY) */
+  uint64_t sampled_out;        /* Seit Start geprüfte, wegen KONA_BRIDGE_SAMPLING nicht gespeicherte Blöcke */
+  uint64_t reorgs;             /* Seit Start ersetzte Blöcke (gleiche Höhe, anderer Hash, unter superseded/) */
+  uint64_t gaps;               /* Seit Start erkannte Lücken in der Blockfolge (KONA_BRIDGE_GAP_TOLERANCE) */
+  uint64_t backwards;          /* Seit Start erkannte Rücksprünge hinter den höchsten erfassten Block */
+  uint64_t dns_failures;       /* Seit Start gescheiterte DNS-Auflösungen des HTTP-Endpoints (siehe dnscache.rs) */
+  uint64_t canonical_matched;       /* Seit Start mit dem kanonischen Block übereinstimmend (siehe accuracy.rs) */
+  uint64_t canonical_mismatched;    /* Seit Start abweichende Preconfs (anderer Block-Hash) */
+  uint64_t canonical_tx_mismatched; /* Davon mit abweichender Menge an Transaktionen */
+} KonaBridgeStorageStats;
+
+/* Anzahl der Buckets je Histogramm (ohne +Inf, das ist *_count) */
+#define KONA_BRIDGE_HISTOGRAM_BUCKETS 10
+
+/* Histogramme der gespeicherten Preconfs, Buckets kumulativ wie bei Prometheus */
+typedef struct {
+  uint64_t interval_bounds_ms[KONA_BRIDGE_HISTOGRAM_BUCKETS]; /* Obergrenzen (le) der Ankunftsabstände in ms */
+  uint64_t interval_buckets[KONA_BRIDGE_HISTOGRAM_BUCKETS];   /* Anzahl Abstände <= interval_bounds_ms[i] */
+  uint64_t interval_count;                                    /* Alle Abstände (+Inf) */
+  uint64_t interval_sum_ms;                                   /* Summe der Abstände in ms */
+  uint64_t size_bounds_bytes[KONA_BRIDGE_HISTOGRAM_BUCKETS];  /* Obergrenzen (le) der Payload-Größen in Bytes */
+  uint64_t size_buckets[KONA_BRIDGE_HISTOGRAM_BUCKETS];       /* Anzahl Payloads <= size_bounds_bytes[i] */
+  uint64_t size_count;                                        /* Alle Payloads (+Inf) */
+  uint64_t size_sum_bytes;                                    /* Summe der unkomprimierten Payload-Größen */
+} KonaBridgeHistograms;
+
 /**
  * Initialisiert das Logging-System der Bridge
  * Sollte einmal beim Programmstart aufgerufen werden
@@ -88,6 +131,49 @@ int kona_bridge_is_running(const KonaBridgeHandle* handle);
  */
 int kona_bridge_get_stats(const KonaBridgeHandle* handle, KonaBridgeStats* stats);
 
+/**
+ * Gibt Speicher-Statistiken des Preconf-Stores zurück (Belegung wird dabei aus dem
+ * Output-Verzeichnis ermittelt, daher nicht in engen Schleifen aufrufen)
+ *
+ * @param handle Handle zur Bridge-Instanz
+ * @param stats Zeiger auf KonaBridgeStorageStats-Struktur
+ * @return 0 bei Erfolg, -1 bei Fehler
+ */
+int kona_bridge_get_storage_stats(const KonaBridgeHandle* handle, KonaBridgeStorageStats* stats);
+
+/**
+ * Gibt die Histogramme der Ankunftsabstände und Payload-Größen gespeicherter Preconfs zurück
+ *
+ * @param handle Handle zur Bridge-Instanz
+ * @param histograms Zeiger auf KonaBridgeHistograms-Struktur
+ * @return 0 bei Erfolg, -1 bei Fehler
+ */
+int kona_bridge_get_histograms(const KonaBridgeHandle* handle, KonaBridgeHistograms* histograms);
+
+/**
+ * Exportiert alle Preconfs im Bereich [from_block, to_block] als tar.zst-Archiv
+ * inklusive Manifest (Chain ID, Bereich, Prüfsummen)
+ *
+ * @param output_dir Verzeichnis mit den Preconf-Dateien
+ * @param chain_id Chain ID der zu exportierenden Blöcke
+ * @param from_block Erster Block (inklusive)
+ * @param to_block Letzter Block (inklusive)
+ * @param dest Pfad der Archiv-Datei (z.B. "preconfs.tar.zst")
+ * @return Anzahl exportierter Blöcke oder -1 bei Fehler
+ */
+int kona_bridge_export(const char* output_dir, uint64_t chain_id, uint64_t from_block, uint64_t to_block, const char* dest);
+
+/**
+ * Importiert ein mit kona_bridge_export erstelltes Archiv
+ * Prüfsummen werden validiert, bereits vorhandene Blöcke übersprungen
+ *
+ * @param output_dir Ziel-Verzeichnis
+ * @param chain_id Erwartete Chain ID (0 = beliebig)
+ * @param src Pfad der Archiv-Datei
+ * @return Anzahl neu importierter Blöcke oder -1 bei Fehler
+ */
+int kona_bridge_import(const char* output_dir, uint64_t chain_id, const char* src);
+
 /* Integration-Funktionen (benötigen op_chains_conf.h) */
 #ifdef OP_CHAINS_CONF_H
 
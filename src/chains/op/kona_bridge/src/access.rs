@@ -0,0 +1,312 @@
+// access.rs - Zugriffsschutz der HTTP-API: API-Keys bzw. JWTs, Rate-Limits je Client und CORS
+//
+// Credentials: "Authorization: Bearer <key|jwt>", "X-API-Key: <key>" oder ?api_key=<key> (für EventSource und
+// WebSocket im Browser, die keine Header setzen können).
+//   API-Keys   KONA_BRIDGE_API_KEYS / KONA_BRIDGE_API_KEYS_FILE, Einträge name:key[:requests_per_sec]
+//   JWT        HS256 mit KONA_BRIDGE_JWT_SECRET; exp/nbf werden geprüft, sub benennt den Client,
+//              ein Claim "rate" (Requests/s) überschreibt das Limit
+//
+// Sind weder Keys noch JWT-Secret konfiguriert, bleibt die API offen wie bisher (gedacht für localhost);
+// mit KONA_BRIDGE_API_ANONYMOUS=1 sind Requests ohne Credentials trotz Keys erlaubt. Limitiert wird per Token-Bucket
+// (Burst = eine Sekunde): Keys nach Name, JWTs nach sub, anonyme Clients nach IP, jeweils mit KONA_BRIDGE_RATE_LIMIT,
+// sofern der Key kein eigenes Limit hat. /healthz und /readyz (Probes) sowie /admin (eigenes Token) sind
+// ausgenommen; CORS (KONA_BRIDGE_CORS_ORIGINS) gilt für alle Routen. Der gRPC-Service bleibt davon unberührt.
+//
+// Davor greifen CIDR-Allowlists nach Adresse des Clients: KONA_BRIDGE_API_ALLOW für alle Routen und den
+// gRPC-Service (dort schon beim Verbindungsaufbau), KONA_BRIDGE_ADMIN_ALLOW zusätzlich für /admin, z.B. nur der
+// Host des C-Servers. Abgelehnte Clients erhalten 403.
+
+use crate::{s3::hmac_sha256, settings::AccessSettings};
+use axum::{
+    extract::{ConnectInfo, Request, State},
+    http::{header, HeaderMap, HeaderValue, Method, StatusCode},
+    middleware::Next,
+    response::{IntoResponse, Json, Response},
+};
+use base64::{engine::general_purpose::URL_SAFE_NO_PAD as BASE64_URL, Engine};
+use serde_json::Value;
+use sha2::{Digest, Sha256};
+use std::{
+    collections::HashMap,
+    net::{IpAddr, SocketAddr},
+    sync::{Arc, Mutex},
+    time::{Instant, SystemTime, UNIX_EPOCH},
+};
+use tracing::debug;
+
+/// Header für API-Keys als Alternative zu Authorization
+const API_KEY_HEADER: &str = "x-api-key";
+/// Ab dieser Zahl verfolgter Clients werden inaktive Buckets verworfen
+const MAX_TRACKED_CLIENTS: usize = 10_000;
+/// Buckets ohne Request seit so vielen Sekunden sind wieder voll und können weg
+const IDLE_CLIENT_SECS: u64 = 60;
+/// Gültigkeit eines Preflights im Browser-Cache
+const CORS_MAX_AGE_SECS: &str = "600";
+
+/// Token-Bucket eines Clients
+struct Bucket {
+    tokens: f64,
+    updated: Instant,
+}
+
+/// Authentifizierter oder anonymer Client mit seinem Limit (Requests/s, 0 = unbegrenzt)
+struct Client {
+    id: String,
+    rate_limit: f64,
+}
+
+pub struct AccessControl {
+    keys: HashMap<[u8; 32], (String, Option<f64>)>, // SHA-256 des Keys -> Name, eigenes Limit
+    jwt_secret: Option<Vec<u8>>,
+    rate_limit: f64,
+    anonymous: bool,
+    cors_origins: Vec<String>,
+    allow_ips: Option<Vec<IpNetwork>>,
+    admin_allow_ips: Option<Vec<IpNetwork>>,
+    buckets: Mutex<HashMap<String, Bucket>>,
+}
+
+/// Netz einer Allowlist (Adresse mit Präfixlänge, ohne Präfix genau eine Adresse)
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct IpNetwork {
+    addr: IpAddr,
+    prefix: u32,
+}
+
+impl IpNetwork {
+    /// "10.0.0.0/8", "192.168.1.5", "fd00::/8" (IPv4-mapped IPv6 wird als IPv4 behandelt)
+    pub fn parse(entry: &str) -> Option<Self> {
+        let (addr, prefix) = match entry.split_once('/') {
+            Some((addr, prefix)) => (addr.trim(), Some(prefix.trim().parse::<u32>().ok()?)),
+            None => (entry, None),
+        };
+        let addr = addr.parse::<IpAddr>().ok()?.to_canonical();
+        let bits = if addr.is_ipv4() { 32 } else { 128 };
+        let prefix = prefix.unwrap_or(bits);
+        (prefix <= bits).then_some(Self { addr, prefix })
+    }
+
+    pub fn contains(&self, ip: IpAddr) -> bool {
+        match (self.addr, ip.to_canonical()) {
+            (IpAddr::V4(network), IpAddr::V4(ip)) => {
+                let mask = u32::MAX.checked_shl(32 - self.prefix).unwrap_or(0);
+                u32::from(network) & mask == u32::from(ip) & mask
+            }
+            (IpAddr::V6(network), IpAddr::V6(ip)) => {
+                let mask = u128::MAX.checked_shl(128 - self.prefix).unwrap_or(0);
+                u128::from(network) & mask == u128::from(ip) & mask
+            }
+            _ => false,
+        }
+    }
+}
+
+/// Ohne Allowlist sind alle Adressen zugelassen
+fn allowed(allowlist: &Option<Vec<IpNetwork>>, ip: IpAddr) -> bool {
+    allowlist.as_ref().map_or(true, |networks| networks.iter().any(|network| network.contains(ip)))
+}
+
+impl AccessControl {
+    pub fn new(settings: &AccessSettings) -> Self {
+        let keys = settings
+            .api_keys
+            .iter()
+            .map(|key| (Sha256::digest(key.key.as_bytes()).into(), (key.name.clone(), key.rate_limit)))
+            .collect();
+        Self {
+            keys,
+            jwt_secret: settings.jwt_secret.as_ref().map(|secret| secret.as_bytes().to_vec()),
+            rate_limit: settings.rate_limit,
+            anonymous: settings.anonymous,
+            cors_origins: settings.cors_origins.clone(),
+            allow_ips: settings.allow_ips.clone(),
+            admin_allow_ips: settings.admin_allow_ips.clone(),
+            buckets: Mutex::new(HashMap::new()),
+        }
+    }
+
+    /// Darf der Client die API (HTTP oder gRPC) überhaupt erreichen?
+    pub(crate) fn peer_allowed(&self, ip: IpAddr) -> bool {
+        allowed(&self.allow_ips, ip)
+    }
+
+    fn admin_allowed(&self, ip: IpAddr) -> bool {
+        self.peer_allowed(ip) && allowed(&self.admin_allow_ips, ip)
+    }
+
+    fn auth_required(&self) -> bool {
+        !self.keys.is_empty() || self.jwt_secret.is_some()
+    }
+
+    /// Client eines Requests (Err = Meldung für 401)
+    fn identify(&self, request: &Request, peer: SocketAddr) -> Result<Client, &'static str> {
+        let anonymous = || Client { id: format!("ip:{}", peer.ip()), rate_limit: self.rate_limit };
+        if !self.auth_required() {
+            return Ok(anonymous());
+        }
+        let Some(credential) = credential(request) else {
+            return if self.anonymous { Ok(anonymous()) } else { Err("missing API key or token") };
+        };
+
+        // Vergleich über Hashes, damit die Laufzeit nichts über die Keys verrät
+        let digest: [u8; 32] = Sha256::digest(credential.as_bytes()).into();
+        if let Some((name, rate_limit)) = self.keys.get(&digest) {
+            return Ok(Client { id: format!("key:{}", name), rate_limit: rate_limit.unwrap_or(self.rate_limit) });
+        }
+        if let Some(claims) = self.jwt_secret.as_deref().and_then(|secret| verify_jwt(&credential, secret)) {
+            let subject = claims.get("sub").and_then(Value::as_str).unwrap_or("-");
+            let rate_limit = claims.get("rate").and_then(Value::as_f64).unwrap_or(self.rate_limit);
+            return Ok(Client { id: format!("jwt:{}", subject), rate_limit });
+        }
+        Err("invalid API key or token")
+    }
+
+    /// Nimmt ein Token aus dem Bucket des Clients (Err = Sekunden bis zum nächsten erlaubten Request)
+    fn take(&self, client: &Client) -> Result<(), u64> {
+        if client.rate_limit <= 0.0 {
+            return Ok(());
+        }
+        let now = Instant::now();
+        let capacity = client.rate_limit.max(1.0);
+        let mut buckets = self.buckets.lock().unwrap();
+        if buckets.len() >= MAX_TRACKED_CLIENTS {
+            buckets.retain(|_, bucket| now.duration_since(bucket.updated).as_secs() < IDLE_CLIENT_SECS);
+        }
+        let bucket = buckets.entry(client.id.clone()).or_insert(Bucket { tokens: capacity, updated: now });
+        let refill = now.duration_since(bucket.updated).as_secs_f64() * client.rate_limit;
+        bucket.tokens = (bucket.tokens + refill).min(capacity);
+        bucket.updated = now;
+        if bucket.tokens >= 1.0 {
+            bucket.tokens -= 1.0;
+            Ok(())
+        } else {
+            Err(((1.0 - bucket.tokens) / client.rate_limit).ceil() as u64)
+        }
+    }
+
+    /// Origin des Requests, falls er per CORS zugelassen ist
+    fn allowed_origin(&self, headers: &HeaderMap) -> Option<HeaderValue> {
+        let origin = headers.get(header::ORIGIN)?;
+        let value = origin.to_str().ok()?;
+        self.cors_origins
+            .iter()
+            .any(|allowed| allowed == "*" || allowed == value)
+            .then(|| origin.clone())
+    }
+}
+
+/// Middleware vor allen Routen der HTTP-API
+pub(crate) async fn check(
+    State(access): State<Arc<AccessControl>>,
+    ConnectInfo(peer): ConnectInfo<SocketAddr>,
+    request: Request,
+    next: Next,
+) -> Response {
+    let path = request.uri().path();
+    let admin = path.starts_with("/admin/");
+    let permitted = if admin { access.admin_allowed(peer.ip()) } else { access.peer_allowed(peer.ip()) };
+    if !permitted {
+        debug!("🚫 Rejected {} from {} (not in allowlist)", path, peer.ip());
+        let body = Json(serde_json::json!({ "error": "client address not allowed" }));
+        return (StatusCode::FORBIDDEN, body).into_response();
+    }
+
+    let origin = access.allowed_origin(request.headers());
+    // Preflight beantworten, bevor Credentials geprüft werden (Browser senden dabei keine)
+    if request.method() == Method::OPTIONS && request.headers().contains_key(header::ACCESS_CONTROL_REQUEST_METHOD) {
+        if let Some(origin) = origin.clone() {
+            let mut response = StatusCode::NO_CONTENT.into_response();
+            let headers = response.headers_mut();
+            add_cors_headers(headers, origin);
+            headers.insert(header::ACCESS_CONTROL_ALLOW_METHODS, HeaderValue::from_static("GET, POST, OPTIONS"));
+            headers.insert(
+                header::ACCESS_CONTROL_ALLOW_HEADERS,
+                HeaderValue::from_static("Authorization, Content-Type, X-API-Key"),
+            );
+            headers.insert(header::ACCESS_CONTROL_MAX_AGE, HeaderValue::from_static(CORS_MAX_AGE_SECS));
+            return response;
+        }
+    }
+
+    let exempt = admin || path == "/healthz" || path == "/readyz";
+    let mut response = if exempt {
+        next.run(request).await
+    } else {
+        match access.identify(&request, peer) {
+            Ok(client) => match access.take(&client) {
+                Ok(()) => next.run(request).await,
+                Err(retry_after) => {
+                    debug!("🚦 Rate limit exceeded by {}", client.id);
+                    (
+                        StatusCode::TOO_MANY_REQUESTS,
+                        [(header::RETRY_AFTER, retry_after.max(1).to_string())],
+                        Json(serde_json::json!({ "error": "rate limit exceeded" })),
+                    )
+                        .into_response()
+                }
+            },
+            Err(message) => (
+                StatusCode::UNAUTHORIZED,
+                [(header::WWW_AUTHENTICATE, "Bearer")],
+                Json(serde_json::json!({ "error": message })),
+            )
+                .into_response(),
+        }
+    };
+    if let Some(origin) = origin {
+        add_cors_headers(response.headers_mut(), origin);
+    }
+    response
+}
+
+fn add_cors_headers(headers: &mut HeaderMap, origin: HeaderValue) {
+    headers.insert(header::ACCESS_CONTROL_ALLOW_ORIGIN, origin);
+    headers.insert(header::ACCESS_CONTROL_EXPOSE_HEADERS, HeaderValue::from_static("Retry-After"));
+    headers.append(header::VARY, HeaderValue::from_static("Origin"));
+}
+
+/// API-Key oder JWT aus Authorization, X-API-Key oder ?api_key=
+fn credential(request: &Request) -> Option<String> {
+    let headers = request.headers();
+    let header_value = |name: &str| headers.get(name).and_then(|value| value.to_str().ok());
+    if let Some(token) = header_value(header::AUTHORIZATION.as_str()).and_then(|value| value.strip_prefix("Bearer ")) {
+        return Some(token.trim().to_string());
+    }
+    if let Some(key) = header_value(API_KEY_HEADER) {
+        return Some(key.trim().to_string());
+    }
+    request
+        .uri()
+        .query()?
+        .split('&')
+        .find_map(|pair| pair.strip_prefix("api_key="))
+        .map(str::to_string)
+}
+
+/// Prüft ein HS256-JWT und liefert seine Claims
+fn verify_jwt(token: &str, secret: &[u8]) -> Option<Value> {
+    let mut parts = token.split('.');
+    let (header, claims, signature) = (parts.next()?, parts.next()?, parts.next()?);
+    if parts.next().is_some() {
+        return None;
+    }
+    let header: Value = serde_json::from_slice(&BASE64_URL.decode(header).ok()?).ok()?;
+    if header.get("alg").and_then(Value::as_str) != Some("HS256") {
+        return None;
+    }
+    let expected = hmac_sha256(secret, token[..token.len() - signature.len() - 1].as_bytes());
+    let signature = BASE64_URL.decode(signature).ok()?;
+    if Sha256::digest(signature) != Sha256::digest(expected) {
+        return None;
+    }
+
+    let claims: Value = serde_json::from_slice(&BASE64_URL.decode(claims).ok()?).ok()?;
+    let now = SystemTime::now().duration_since(UNIX_EPOCH).ok()?.as_secs();
+    if claims.get("exp").and_then(Value::as_u64).is_some_and(|exp| exp <= now) {
+        return None;
+    }
+    if claims.get("nbf").and_then(Value::as_u64).is_some_and(|nbf| nbf > now) {
+        return None;
+    }
+    Some(claims)
+}
@@ -0,0 +1,233 @@
+// accuracy.rs - Abgleich gespeicherter Preconfs mit den kanonischen L2-Blöcken (KONA_BRIDGE_ACCURACY_RPC)
+//
+// Für jeden gespeicherten Block merkt sich der Store Hash und Transaktions-Hashes (höchstens MAX_PENDING_BLOCKS, die
+// ältesten fallen heraus). Ein Hintergrund-Task fragt alle ACCURACY_POLL_INTERVAL den Head des L2-RPC ab
+// (KONA_BRIDGE_ACCURACY_HEAD: safe (Default), finalized oder latest) und vergleicht jeden vorgemerkten Block bis
+// dahin mit eth_getBlockByNumber:
+//   match      gleicher Block-Hash
+//   mismatch   anderer Hash; zusätzlich wird verglichen, ob die Menge der Transaktionen übereinstimmt (missing =
+//              nur im kanonischen Block, extra = nur im Preconf)
+// Ergebnisse zählen als canonical_matched, canonical_mismatched und canonical_tx_mismatched in den Speicher-
+// Statistiken, die Preconf-Accuracy einer Chain ist matched / (matched + mismatched). Jede Abweichung steht als
+// strukturiertes Log-Ereignis im Log und geht als Hook-Ereignis "mismatch" raus. Blöcke, die der RPC noch nicht
+// liefert, bleiben vorgemerkt; ein Reorg ersetzt den vorgemerkten Block derselben Höhe.
+
+use crate::storage::PreconfStore;
+use std::{
+    collections::{BTreeMap, HashSet},
+    sync::{Arc, Mutex},
+    time::Duration,
+};
+use tokio::time::interval;
+use tracing::{debug, info, warn};
+
+/// Vorgemerkte Blöcke je Chain (bei 2s Blockzeit gut 2h, mehr als der übliche Abstand zum Safe-Head)
+const MAX_PENDING_BLOCKS: usize = 4096;
+/// Höchstens so viele Vergleiche je Durchlauf (der Rest folgt im nächsten)
+const MAX_COMPARES_PER_ROUND: usize = 256;
+const ACCURACY_POLL_INTERVAL: Duration = Duration::from_secs(12);
+
+/// Head, bis zu dem ein L2-Block als kanonisch gilt
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum CanonicalHead {
+    Latest,
+    #[default]
+    Safe,
+    Finalized,
+}
+
+impl CanonicalHead {
+    /// Parst "latest", "safe" oder "finalized"
+    pub fn parse(value: &str) -> Option<Self> {
+        match value.trim().to_ascii_lowercase().as_str() {
+            "latest" | "unsafe" => Some(CanonicalHead::Latest),
+            "safe" => Some(CanonicalHead::Safe),
+            "finalized" | "final" => Some(CanonicalHead::Finalized),
+            _ => None,
+        }
+    }
+
+    fn tag(&self) -> &'static str {
+        match self {
+            CanonicalHead::Latest => "latest",
+            CanonicalHead::Safe => "safe",
+            CanonicalHead::Finalized => "finalized",
+        }
+    }
+}
+
+/// Gespeicherter Stand eines Blocks, der noch verglichen werden muss
+#[derive(Debug, Clone)]
+pub struct PendingBlock {
+    pub block_hash: [u8; 32],
+    pub tx_hashes: Vec<[u8; 32]>,
+}
+
+/// Ergebnis eines Vergleichs mit dem kanonischen Block
+#[derive(Debug, Clone)]
+pub struct Comparison {
+    pub preconf_hash: [u8; 32],
+    pub canonical_hash: [u8; 32],
+    pub missing: usize, // Transaktionen nur im kanonischen Block
+    pub extra: usize,   // Transaktionen nur im Preconf
+}
+
+impl Comparison {
+    pub fn matched(&self) -> bool {
+        self.preconf_hash == self.canonical_hash
+    }
+
+    pub fn tx_set_matches(&self) -> bool {
+        self.missing == 0 && self.extra == 0
+    }
+
+    pub fn details(&self) -> serde_json::Value {
+        serde_json::json!({
+            "canonical_block_hash": format!("0x{}", hex::encode(self.canonical_hash)),
+            "tx_set_matches": self.tx_set_matches(),
+            "missing_txs": self.missing,
+            "extra_txs": self.extra,
+        })
+    }
+}
+
+/// Vergleicht einen vorgemerkten Block mit Hash und Transaktions-Hashes des kanonischen Blocks
+pub fn compare(pending: &PendingBlock, canonical_hash: [u8; 32], canonical_txs: &[[u8; 32]]) -> Comparison {
+    let preconf: HashSet<&[u8; 32]> = pending.tx_hashes.iter().collect();
+    let canonical: HashSet<&[u8; 32]> = canonical_txs.iter().collect();
+    Comparison {
+        preconf_hash: pending.block_hash,
+        canonical_hash,
+        missing: canonical.difference(&preconf).count(),
+        extra: preconf.difference(&canonical).count(),
+    }
+}
+
+/// Noch nicht verglichene Blöcke einer Chain
+#[derive(Default)]
+pub struct CanonicalTracker {
+    pending: Mutex<BTreeMap<u64, PendingBlock>>,
+}
+
+impl CanonicalTracker {
+    /// Merkt einen gespeicherten Block vor (ersetzt einen vorgemerkten Block derselben Höhe)
+    pub fn track(&self, block_number: u64, block_hash: [u8; 32], tx_hashes: Vec<[u8; 32]>) {
+        let mut pending = self.pending.lock().unwrap();
+        pending.insert(block_number, PendingBlock { block_hash, tx_hashes });
+        while pending.len() > MAX_PENDING_BLOCKS {
+            pending.pop_first();
+        }
+    }
+
+    /// Vorgemerkte Blöcke bis einschließlich `head`, älteste zuerst
+    fn due(&self, head: u64) -> Vec<(u64, PendingBlock)> {
+        let pending = self.pending.lock().unwrap();
+        pending
+            .range(..=head)
+            .take(MAX_COMPARES_PER_ROUND)
+            .map(|(block_number, block)| (*block_number, block.clone()))
+            .collect()
+    }
+
+    /// Entfernt einen verglichenen Block (nicht, wenn ihn inzwischen ein Reorg ersetzt hat)
+    fn resolve(&self, block_number: u64, block_hash: &[u8; 32]) {
+        let mut pending = self.pending.lock().unwrap();
+        if pending.get(&block_number).is_some_and(|block| block.block_hash == *block_hash) {
+            pending.remove(&block_number);
+        }
+    }
+}
+
+/// Vergleicht gespeicherte Preconfs laufend mit den kanonischen Blöcken von `rpc_url`
+pub async fn run_accuracy_check(
+    store: Arc<PreconfStore>,
+    tracker: Arc<CanonicalTracker>,
+    rpc_url: String,
+    head: CanonicalHead,
+    running: Arc<Mutex<bool>>,
+) {
+    let client = reqwest::Client::new();
+    let mut ticker = interval(ACCURACY_POLL_INTERVAL);
+    let mut failing = false;
+    info!("🎯 Preconf accuracy: comparing chain {} with {} blocks from {}", store.chain_id(), head.tag(), rpc_url);
+
+    loop {
+        ticker.tick().await;
+        if !*running.lock().unwrap() {
+            break;
+        }
+        match compare_round(&store, &tracker, &client, &rpc_url, head).await {
+            Ok(compared) => {
+                if compared > 0 {
+                    debug!("🎯 Preconf accuracy: compared {} blocks", compared);
+                }
+                failing = false;
+            }
+            Err(e) if !failing => {
+                warn!("⚠️  Preconf accuracy: RPC {} failed: {}", rpc_url, e);
+                failing = true;
+            }
+            Err(e) => debug!("🎯 Preconf accuracy: RPC {} failed: {}", rpc_url, e),
+        }
+    }
+}
+
+/// Ein Durchlauf: Head abfragen, fällige Blöcke vergleichen (Anzahl der Vergleiche)
+async fn compare_round(
+    store: &PreconfStore,
+    tracker: &CanonicalTracker,
+    client: &reqwest::Client,
+    rpc_url: &str,
+    head: CanonicalHead,
+) -> Result<usize, Box<dyn std::error::Error + Send + Sync>> {
+    let Some(head_block) = fetch_block(client, rpc_url, serde_json::json!(head.tag())).await? else {
+        return Err(format!("no {} block", head.tag()).into());
+    };
+    let head_number = parse_quantity(&head_block["number"]).ok_or("block without number")?;
+
+    let mut compared = 0;
+    for (block_number, pending) in tracker.due(head_number) {
+        let tag = serde_json::json!(format!("0x{:x}", block_number));
+        let Some(block) = fetch_block(client, rpc_url, tag).await? else {
+            // Noch nicht beim RPC angekommen, im nächsten Durchlauf erneut
+            break;
+        };
+        let canonical_hash = parse_hash(&block["hash"]).ok_or("block without hash")?;
+        let canonical_txs: Vec<[u8; 32]> = block["transactions"]
+            .as_array()
+            .map(|txs| txs.iter().filter_map(parse_hash).collect())
+            .unwrap_or_default();
+        store.report_canonical(block_number, &compare(&pending, canonical_hash, &canonical_txs));
+        tracker.resolve(block_number, &pending.block_hash);
+        compared += 1;
+    }
+    Ok(compared)
+}
+
+/// eth_getBlockByNumber ohne Transaktionsobjekte (None = Block unbekannt)
+async fn fetch_block(
+    client: &reqwest::Client,
+    rpc_url: &str,
+    tag: serde_json::Value,
+) -> Result<Option<serde_json::Value>, Box<dyn std::error::Error + Send + Sync>> {
+    let request =
+        serde_json::json!({"jsonrpc": "2.0", "id": 1, "method": "eth_getBlockByNumber", "params": [tag, false]});
+    let response = client.post(rpc_url).json(&request).timeout(Duration::from_secs(10)).send().await?;
+    if !response.status().is_success() {
+        return Err(format!("HTTP {} from {}", response.status(), rpc_url).into());
+    }
+    let mut body: serde_json::Value = response.json().await?;
+    if let Some(error) = body.get("error") {
+        return Err(format!("eth_getBlockByNumber failed: {}", error).into());
+    }
+    let block = body["result"].take();
+    Ok((!block.is_null()).then_some(block))
+}
+
+fn parse_quantity(value: &serde_json::Value) -> Option<u64> {
+    u64::from_str_radix(value.as_str()?.trim_start_matches("0x"), 16).ok()
+}
+
+fn parse_hash(value: &serde_json::Value) -> Option<[u8; 32]> {
+    hex::decode(value.as_str()?.trim_start_matches("0x")).ok()?.try_into().ok()
+}
@@ -0,0 +1,293 @@
+// admin.rs - Admin-API für Betriebsaktionen (HTTP-API oder KONA_BRIDGE_ADMIN_ADDR, aktiv mit KONA_BRIDGE_ADMIN_TOKEN)
+//
+//   POST /admin/chains/{chain_id}/purge?from=&to=      Blöcke from..=to löschen (Dateien, Index, Hot-Cache)
+//   POST /admin/chains/{chain_id}/cleanup              Cleanup-Zyklus (TTL/Finality, Quota, Temp-Dateien) sofort
+//   POST /admin/chains/{chain_id}/backfill?from=&to=   fehlende Blöcke von KONA_BRIDGE_BACKFILL_URL nachladen
+//                                                      (automatisch für erkannte Lücken siehe continuity.rs)
+//   POST /admin/chains/{chain_id}/reload               Signer-Datei neu lesen und Signer aus der SystemConfig abfragen
+//   POST /admin/chains/{chain_id}/rotate-latest        latest.raw/pre_latest.raw/latest.json auf den neuesten Block
+//
+// Bisher hieß das: Prozess anhalten und Dateien von Hand bearbeiten. Alle Requests brauchen
+// "Authorization: Bearer <token>"; ohne konfiguriertes Token antworten die Routen mit 404.
+// Backfill lädt aus der Serving-API einer anderen Bridge (format=raw) und schreibt über den normalen Store-Pfad,
+// d.h. Signatur- und Plausibilitätsprüfungen gelten wie für empfangene Preconfs.
+// Andere Einstellungen als die Signer werden weiterhin nur beim Start gelesen.
+
+use crate::{
+    codec::PreconfContainer,
+    storage::{PreconfStore, PreconfWrite},
+    utils::{extract_block_hash_from_preconf_data, extract_block_number_from_preconf_data},
+};
+use axum::{
+    extract::{Path, Query},
+    http::{header, HeaderMap, StatusCode},
+    response::{IntoResponse, Json, Response},
+};
+use serde::Deserialize;
+use sha2::{Digest, Sha256};
+use std::{
+    collections::HashMap,
+    sync::{
+        atomic::{AtomicBool, Ordering},
+        Arc, Mutex, OnceLock, RwLock,
+    },
+    time::Duration,
+};
+use tokio::sync::{watch, Notify};
+use tracing::{info, warn};
+
+/// Höchstzahl Blöcke pro Backfill-Auftrag
+pub(crate) const MAX_BACKFILL_BLOCKS: u64 = 10_000;
+/// Quelle nachgeladener Blöcke in den Metadaten (zählt nicht für die Lückenerkennung, siehe continuity.rs)
+pub(crate) const BACKFILL_SOURCE: &str = "backfill";
+/// Timeout je nachgeladenem Block
+const BACKFILL_TIMEOUT: Duration = Duration::from_secs(10);
+
+/// Token der Admin-API (einmal pro Prozess gesetzt)
+static TOKEN: OnceLock<String> = OnceLock::new();
+/// Registrierte Chains nach Chain-ID
+static CHAINS: OnceLock<RwLock<HashMap<u64, Arc<AdminControl>>>> = OnceLock::new();
+
+fn chains() -> &'static RwLock<HashMap<u64, Arc<AdminControl>>> {
+    CHAINS.get_or_init(|| RwLock::new(HashMap::new()))
+}
+
+/// Eingriffspunkte einer Chain für die Admin-API
+pub struct AdminControl {
+    store: Arc<PreconfStore>,
+    cleanup: Arc<Notify>,
+    reload: watch::Sender<()>,
+    backfill_url: Option<String>,
+    backfilling: AtomicBool,
+}
+
+impl AdminControl {
+    pub fn new(
+        store: Arc<PreconfStore>,
+        cleanup: Arc<Notify>,
+        reload: watch::Sender<()>,
+        backfill_url: Option<String>,
+    ) -> Self {
+        Self { store, cleanup, reload, backfill_url, backfilling: AtomicBool::new(false) }
+    }
+}
+
+/// Nimmt die Chain in die Admin-API auf und wieder heraus, sobald `running` false wird
+pub fn register(token: String, control: AdminControl, running: Arc<Mutex<bool>>) {
+    let chain_id = control.store.chain_id();
+    if *TOKEN.get_or_init(|| token.clone()) != token {
+        warn!("⚠️  Admin API already uses another token, chain {} keeps the first one", chain_id);
+    }
+    if let Ok(mut chains) = chains().write() {
+        chains.insert(chain_id, Arc::new(control));
+    }
+    tokio::spawn(async move {
+        while *running.lock().unwrap() {
+            tokio::time::sleep(Duration::from_secs(1)).await;
+        }
+        if let Ok(mut chains) = chains().write() {
+            chains.remove(&chain_id);
+        }
+    });
+}
+
+fn error_response(status: StatusCode, message: impl ToString) -> Response {
+    (status, Json(serde_json::json!({ "error": message.to_string() }))).into_response()
+}
+
+/// Prüft das Bearer-Token (Vergleich über Hashes, damit die Laufzeit nichts über das Token verrät)
+/// und liefert die Steuerung der Chain
+fn authorize(headers: &HeaderMap, chain_id: u64) -> Result<Arc<AdminControl>, Response> {
+    let Some(token) = TOKEN.get() else {
+        return Err(error_response(StatusCode::NOT_FOUND, "admin API disabled"));
+    };
+    let presented = headers
+        .get(header::AUTHORIZATION)
+        .and_then(|value| value.to_str().ok())
+        .and_then(|value| value.strip_prefix("Bearer "))
+        .unwrap_or("");
+    if Sha256::digest(presented.as_bytes()) != Sha256::digest(token.as_bytes()) {
+        return Err(error_response(StatusCode::UNAUTHORIZED, "invalid admin token"));
+    }
+    chains()
+        .read()
+        .ok()
+        .and_then(|chains| chains.get(&chain_id).cloned())
+        .ok_or_else(|| error_response(StatusCode::NOT_FOUND, format!("chain {} not served", chain_id)))
+}
+
+#[derive(Deserialize)]
+pub(crate) struct RangeQuery {
+    from: u64,
+    to: u64,
+}
+
+pub(crate) async fn post_purge(Path(chain_id): Path<u64>, Query(range): Query<RangeQuery>, headers: HeaderMap) -> Response {
+    let control = match authorize(&headers, chain_id) {
+        Ok(control) => control,
+        Err(response) => return response,
+    };
+    if range.from > range.to {
+        return error_response(StatusCode::BAD_REQUEST, "from > to");
+    }
+    info!("🛠️  Admin: purging blocks {} - {} of chain {}", range.from, range.to, chain_id);
+    match control.store.purge(range.from, range.to).await {
+        Ok(purged) => Json(serde_json::json!({ "purged": purged })).into_response(),
+        Err(e) => error_response(StatusCode::INTERNAL_SERVER_ERROR, e),
+    }
+}
+
+pub(crate) async fn post_cleanup(Path(chain_id): Path<u64>, headers: HeaderMap) -> Response {
+    let control = match authorize(&headers, chain_id) {
+        Ok(control) => control,
+        Err(response) => return response,
+    };
+    control.cleanup.notify_one();
+    (StatusCode::ACCEPTED, Json(serde_json::json!({ "status": "cleanup triggered" }))).into_response()
+}
+
+pub(crate) async fn post_reload(Path(chain_id): Path<u64>, headers: HeaderMap) -> Response {
+    let control = match authorize(&headers, chain_id) {
+        Ok(control) => control,
+        Err(response) => return response,
+    };
+    // Ohne Signer-Datei und SystemConfig-Abfrage hört niemand zu
+    if control.reload.receiver_count() == 0 {
+        return error_response(StatusCode::CONFLICT, "nothing to reload (no signers file or SystemConfig refresh)");
+    }
+    info!("🛠️  Admin: reloading signers of chain {}", chain_id);
+    control.reload.send_replace(());
+    (StatusCode::ACCEPTED, Json(serde_json::json!({ "status": "reload triggered" }))).into_response()
+}
+
+pub(crate) async fn post_rotate_latest(Path(chain_id): Path<u64>, headers: HeaderMap) -> Response {
+    let control = match authorize(&headers, chain_id) {
+        Ok(control) => control,
+        Err(response) => return response,
+    };
+    match control.store.rotate_latest().await {
+        Ok(Some(latest)) => Json(serde_json::json!({ "latest": latest })).into_response(),
+        Ok(None) => error_response(StatusCode::NOT_FOUND, "no preconf stored yet"),
+        Err(e) => error_response(StatusCode::INTERNAL_SERVER_ERROR, e),
+    }
+}
+
+pub(crate) async fn post_backfill(Path(chain_id): Path<u64>, Query(range): Query<RangeQuery>, headers: HeaderMap) -> Response {
+    let control = match authorize(&headers, chain_id) {
+        Ok(control) => control,
+        Err(response) => return response,
+    };
+    let Some(source) = control.backfill_url.clone() else {
+        return error_response(StatusCode::CONFLICT, "no backfill source (KONA_BRIDGE_BACKFILL_URL)");
+    };
+    if range.from > range.to {
+        return error_response(StatusCode::BAD_REQUEST, "from > to");
+    }
+    if range.to - range.from >= MAX_BACKFILL_BLOCKS {
+        return error_response(StatusCode::BAD_REQUEST, format!("range exceeds {} blocks", MAX_BACKFILL_BLOCKS));
+    }
+    if control.backfilling.swap(true, Ordering::AcqRel) {
+        return error_response(StatusCode::CONFLICT, "a backfill is already running");
+    }
+
+    info!("🛠️  Admin: backfilling blocks {} - {} of chain {} from {}", range.from, range.to, chain_id, source);
+    // Läuft im Runtime der Chain weiter, der Request kehrt sofort zurück
+    tokio::spawn(async move {
+        run_backfill(&control.store, &source, range.from, range.to).await;
+        control.backfilling.store(false, Ordering::Release);
+    });
+    (StatusCode::ACCEPTED, Json(serde_json::json!({ "status": "backfill started", "from": range.from, "to": range.to })))
+        .into_response()
+}
+
+/// Lädt die fehlenden Blöcke from..=to nach und setzt danach die latest-Zeiger wieder auf den neuesten Block
+pub(crate) async fn run_backfill(store: &PreconfStore, source: &str, from: u64, to: u64) {
+    let client = reqwest::Client::new();
+    let (mut stored, mut missing, mut failed) = (0, 0, 0);
+    for block_number in from..=to {
+        if store.filename_by_number(block_number).is_some() {
+            continue;
+        }
+        match backfill_block(&client, store, source, block_number).await {
+            Ok(true) => stored += 1,
+            Ok(false) => missing += 1,
+            Err(e) => {
+                warn!("⚠️  Backfill of block {} failed: {}", block_number, e);
+                failed += 1;
+            }
+        }
+    }
+    // Jeder Schreibvorgang setzt latest auf den geschriebenen (älteren) Block
+    if stored > 0 {
+        if let Err(e) = store.rotate_latest().await {
+            warn!("⚠️  Cannot restore latest pointer after backfill: {}", e);
+        }
+    }
+    info!(
+        "🛠️  Backfill {} - {} of chain {} done: {} stored, {} not available, {} failed",
+        from,
+        to,
+        store.chain_id(),
+        stored,
+        missing,
+        failed
+    );
+}
+
+/// Neuester Block der Quelle (GET /v1/chains/{chain_id}/head ihrer Serving-API)
+pub(crate) async fn source_head(source: &str, chain_id: u64) -> Result<u64, Box<dyn std::error::Error + Send + Sync>> {
+    let url = format!("{}/v1/chains/{}/head", source.trim_end_matches('/'), chain_id);
+    let response = reqwest::Client::new().get(&url).timeout(BACKFILL_TIMEOUT).send().await?;
+    if !response.status().is_success() {
+        return Err(format!("HTTP {} from {}", response.status(), url).into());
+    }
+    let head: serde_json::Value = response.json().await?;
+    head["number"].as_u64().ok_or_else(|| format!("no block number in response of {}", url).into())
+}
+
+/// Ein Block von der Quelle (false = dort nicht vorhanden)
+async fn backfill_block(
+    client: &reqwest::Client,
+    store: &PreconfStore,
+    source: &str,
+    block_number: u64,
+) -> Result<bool, Box<dyn std::error::Error + Send + Sync>> {
+    let url = format!(
+        "{}/v1/chains/{}/preconfs/{}?format=raw",
+        source.trim_end_matches('/'),
+        store.chain_id(),
+        block_number
+    );
+    let response = client.get(&url).timeout(BACKFILL_TIMEOUT).send().await?;
+    if response.status() == reqwest::StatusCode::NOT_FOUND {
+        return Ok(false);
+    }
+    if !response.status().is_success() {
+        return Err(format!("HTTP {} from {}", response.status(), url).into());
+    }
+    let data = response.bytes().await?;
+
+    let container = PreconfContainer::parse(&data)?;
+    let payload = container.payload()?;
+    let signature = container
+        .signature
+        .try_into()
+        .map_err(|_| format!("Unexpected signature length {}", container.signature.len()))?;
+    let number = extract_block_number_from_preconf_data(&payload)?;
+    if number != block_number {
+        return Err(format!("source returned block {} instead of {}", number, block_number).into());
+    }
+    let block_hash = extract_block_hash_from_preconf_data(&payload)?;
+
+    store
+        .write(PreconfWrite {
+            block_number,
+            block_hash,
+            payload,
+            signature,
+            source: BACKFILL_SOURCE,
+            version: None,
+        })
+        .await?;
+    Ok(true)
+}
@@ -0,0 +1,382 @@
+// alerts.rs - Alarm-Webhooks für Betriebsprobleme (Slack-, PagerDuty- oder generisches JSON)
+//
+// Anders als die Hooks (hooks.rs, jedes Ereignis einzeln) meldet ein Alarm einen Zustand, der Eingreifen braucht:
+//   capture_stall        seit KONA_BRIDGE_ALERT_STALL_SECS (Default 120) kein neuer Preconf gespeichert
+//   signature_failures   mindestens KONA_BRIDGE_ALERT_SIGNATURE_FAILURES (Default 5) abgelehnte Signaturen innerhalb
+//                        von KONA_BRIDGE_ALERT_SIGNATURE_WINDOW_SECS (Default 300)
+//   disk_quota           Preconf-Dateien über KONA_BRIDGE_QUOTA_MB_<chain_id> (Quota-Cleanup kommt nicht nach),
+//                        weniger als KONA_BRIDGE_ALERT_MIN_FREE_MB (Default 1024, 0 = aus) frei im Dateisystem oder
+//                        Notfall-Löschung durch den Disk-Guard (siehe diskguard.rs)
+//   equivocation         zwei verschiedene Blöcke derselben Höhe von akzeptierten Signern (siehe equivocations/)
+//
+// KONA_BRIDGE_ALERT_URL (mehrere durch Komma getrennt) nimmt die Alarme per POST an, das Format richtet sich nach
+// KONA_BRIDGE_ALERT_FORMAT: slack ({"text": ...}, auch Mattermost/Rocket.Chat), pagerduty (Events API v2, braucht
+// KONA_BRIDGE_ALERT_ROUTING_KEY) oder json. Ohne Angabe gilt pagerduty für events.pagerduty.com, sonst slack.
+// Je Chain und Alarm wird höchstens einmal pro KONA_BRIDGE_ALERT_COOLDOWN_SECS (Default 900) gesendet; was in der
+// Zwischenzeit unterdrückt wurde, steht als Anzahl im nächsten Alarm. Zustände melden sich zusätzlich als behoben
+// (PagerDuty: resolve mit demselben dedup_key), Equivocations nicht.
+
+use crate::{
+    dirlock::hostname,
+    reload::RuntimeConfig,
+    settings::AlertSettings,
+    stats::StorageStats,
+    storage::PreconfStore,
+    utils::free_bytes,
+};
+use std::{
+    collections::{HashMap, VecDeque},
+    sync::{Arc, Mutex},
+    time::{Duration, Instant, SystemTime, UNIX_EPOCH},
+};
+use tokio::{
+    sync::watch,
+    time::{interval, timeout, MissedTickBehavior},
+};
+use tracing::{debug, info, warn};
+
+pub const DEFAULT_ALERT_STALL_SECS: u64 = 120;
+pub const DEFAULT_ALERT_SIGNATURE_FAILURES: u64 = 5;
+pub const DEFAULT_ALERT_SIGNATURE_WINDOW_SECS: u64 = 300;
+pub const DEFAULT_ALERT_MIN_FREE_MB: u64 = 1024;
+pub const DEFAULT_ALERT_COOLDOWN_SECS: u64 = 900;
+/// Prüfintervall für Stall, Signaturen und Equivocations
+const CHECK_INTERVAL: Duration = Duration::from_secs(5);
+/// Prüfintervall für die Belegung (Verzeichnis-Scan)
+const DISK_CHECK_INTERVAL: Duration = Duration::from_secs(60);
+const WEBHOOK_TIMEOUT: Duration = Duration::from_secs(10);
+const PAGERDUTY_HOST: &str = "events.pagerduty.com";
+
+/// Payload-Format der Alarm-Webhooks
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum AlertFormat {
+    Slack,
+    PagerDuty,
+    Json,
+}
+
+impl AlertFormat {
+    pub fn parse(value: &str) -> Option<Self> {
+        match value.trim() {
+            "slack" => Some(Self::Slack),
+            "pagerduty" => Some(Self::PagerDuty),
+            "json" => Some(Self::Json),
+            _ => None,
+        }
+    }
+
+    /// Default je URL: PagerDuty für die Events API, sonst Slack-kompatibel
+    pub fn for_url(url: &str) -> Self {
+        if url.contains(PAGERDUTY_HOST) {
+            Self::PagerDuty
+        } else {
+            Self::Slack
+        }
+    }
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+enum AlertKind {
+    CaptureStall,
+    SignatureFailures,
+    DiskQuota,
+    Equivocation,
+}
+
+impl AlertKind {
+    fn name(self) -> &'static str {
+        match self {
+            Self::CaptureStall => "capture_stall",
+            Self::SignatureFailures => "signature_failures",
+            Self::DiskQuota => "disk_quota",
+            Self::Equivocation => "equivocation",
+        }
+    }
+
+    /// Einzelne Ereignisse statt eines andauernden Zustands
+    fn is_event(self) -> bool {
+        matches!(self, Self::SignatureFailures | Self::Equivocation)
+    }
+
+    /// PagerDuty-Severity (critical, error, warning, info)
+    fn severity(self) -> &'static str {
+        match self {
+            Self::CaptureStall | Self::Equivocation => "critical",
+            Self::SignatureFailures | Self::DiskQuota => "error",
+        }
+    }
+}
+
+/// Ein Alarm oder seine Entwarnung
+struct Alert {
+    kind: AlertKind,
+    resolved: bool,
+    summary: String,
+    details: serde_json::Value,
+    suppressed: u64, // seit dem letzten gesendeten Alarm dieser Art unterdrückt
+}
+
+/// Versandzustand je Alarm-Art
+#[derive(Default)]
+struct AlertState {
+    active: bool,               // Zustand besteht (Entwarnung fällig, sobald er endet)
+    last_sent: Option<Instant>, // letzter gesendeter Alarm (Cool-down)
+    suppressed: u64,
+}
+
+struct Alerter {
+    settings: AlertSettings,
+    chain_id: u64,
+    host: String,
+    client: reqwest::Client,
+    states: HashMap<AlertKind, AlertState>,
+}
+
+impl Alerter {
+    /// Meldet einen Alarm, außer innerhalb des Cool-downs der letzten Meldung derselben Art
+    async fn fire(&mut self, kind: AlertKind, summary: String, details: serde_json::Value) {
+        let cooldown = Duration::from_secs(self.settings.cooldown_secs);
+        let state = self.states.entry(kind).or_default();
+        state.active = true;
+        if state.last_sent.is_some_and(|sent| sent.elapsed() < cooldown) {
+            // Zustände werden bei jeder Prüfung erneut gemeldet, gezählt werden nur neue Ereignisse
+            if kind.is_event() {
+                state.suppressed += 1;
+            }
+            debug!("🚨 Alert {} suppressed (cool-down): {}", kind.name(), summary);
+            return;
+        }
+        state.last_sent = Some(Instant::now());
+        let suppressed = std::mem::take(&mut state.suppressed);
+        warn!("🚨 Alert {}: {}", kind.name(), summary);
+        self.send(Alert { kind, resolved: false, summary, details, suppressed }).await;
+    }
+
+    /// Entwarnung, falls für diese Art ein Alarm aussteht
+    async fn resolve(&mut self, kind: AlertKind, summary: String) {
+        let Some(state) = self.states.get_mut(&kind).filter(|state| state.active) else {
+            return;
+        };
+        state.active = false;
+        // Nach einer Entwarnung darf ein neuer Vorfall sofort gemeldet werden
+        state.last_sent = None;
+        let suppressed = std::mem::take(&mut state.suppressed);
+        info!("✅ Alert {} resolved: {}", kind.name(), summary);
+        self.send(Alert { kind, resolved: true, summary, details: serde_json::Value::Null, suppressed }).await;
+    }
+
+    async fn send(&self, alert: Alert) {
+        for url in &self.settings.urls {
+            let format = self.settings.format.unwrap_or_else(|| AlertFormat::for_url(url));
+            let body = self.payload(format, &alert);
+            match timeout(WEBHOOK_TIMEOUT, self.client.post(url).json(&body).send()).await {
+                Ok(Ok(response)) if response.status().is_success() => {}
+                Ok(Ok(response)) => {
+                    warn!("⚠️  Alert webhook {} answered {} for {}", url, response.status(), alert.kind.name())
+                }
+                Ok(Err(e)) => warn!("⚠️  Alert webhook {} failed for {}: {}", url, alert.kind.name(), e),
+                Err(_) => warn!("⚠️  Alert webhook {} timed out for {}", url, alert.kind.name()),
+            }
+        }
+    }
+
+    fn payload(&self, format: AlertFormat, alert: &Alert) -> serde_json::Value {
+        let suppressed = if alert.suppressed > 0 {
+            format!(" ({} more suppressed)", alert.suppressed)
+        } else {
+            String::new()
+        };
+        match format {
+            AlertFormat::Slack => {
+                let icon = if alert.resolved { "✅ Resolved" } else { "🚨" };
+                serde_json::json!({
+                    "text": format!(
+                        "{} *kona_bridge* chain {} on {}: {}{}",
+                        icon, self.chain_id, self.host, alert.summary, suppressed
+                    ),
+                })
+            }
+            AlertFormat::PagerDuty => {
+                let dedup_key = format!("kona_bridge/{}/{}/{}", self.host, self.chain_id, alert.kind.name());
+                let mut event = serde_json::json!({
+                    "routing_key": self.settings.routing_key.clone().unwrap_or_default(),
+                    "event_action": if alert.resolved { "resolve" } else { "trigger" },
+                    "dedup_key": dedup_key,
+                });
+                if !alert.resolved {
+                    event["payload"] = serde_json::json!({
+                        "summary": format!("kona_bridge chain {}: {}{}", self.chain_id, alert.summary, suppressed),
+                        "source": self.host,
+                        "severity": alert.kind.severity(),
+                        "component": "kona_bridge",
+                        "group": format!("chain {}", self.chain_id),
+                        "class": alert.kind.name(),
+                        "custom_details": alert.details,
+                    });
+                }
+                event
+            }
+            AlertFormat::Json => serde_json::json!({
+                "alert": alert.kind.name(),
+                "status": if alert.resolved { "resolved" } else { "firing" },
+                "severity": alert.kind.severity(),
+                "chain_id": self.chain_id,
+                "host": self.host,
+                "summary": alert.summary,
+                "suppressed": alert.suppressed,
+                "details": alert.details,
+                "emitted_unix": unix_now(),
+            }),
+        }
+    }
+}
+
+fn unix_now() -> u64 {
+    SystemTime::now().duration_since(UNIX_EPOCH).map_or(0, |d| d.as_secs())
+}
+
+/// Überwacht eine Chain und sendet die Alarme (eigener Task, der Capture-Pfad wartet nie darauf)
+pub async fn run_alerts(
+    settings: AlertSettings,
+    chain_id: u64,
+    store: Arc<PreconfStore>,
+    runtime: watch::Receiver<RuntimeConfig>,
+    running: Arc<Mutex<bool>>,
+) {
+    info!(
+        "🚨 Alerts enabled: {} webhook(s), stall {}s, {} signature failures in {}s, cool-down {}s",
+        settings.urls.len(),
+        settings.stall_secs,
+        settings.signature_failures,
+        settings.signature_window_secs,
+        settings.cooldown_secs
+    );
+    let stats: Arc<StorageStats> = store.storage_stats();
+    let disk = store.disk_state();
+    let mut alerter = Alerter {
+        settings: settings.clone(),
+        chain_id,
+        host: hostname(),
+        client: reqwest::Client::new(),
+        states: HashMap::new(),
+    };
+
+    let started = unix_now();
+    let mut invalid_seen = stats.counters().invalid_signatures;
+    let mut equivocations_seen = stats.counters().equivocations;
+    let mut failures: VecDeque<(Instant, u64)> = VecDeque::new(); // neue Fehlschläge je Prüfung
+    let mut last_disk_check: Option<Instant> = None;
+
+    let mut ticker = interval(CHECK_INTERVAL);
+    ticker.set_missed_tick_behavior(MissedTickBehavior::Delay);
+    loop {
+        ticker.tick().await;
+        if !*running.lock().unwrap() {
+            break;
+        }
+        let now = unix_now();
+
+        // Capture-Stall: Alter des neuesten gespeicherten Preconfs (vor dem ersten ab Start gerechnet)
+        let (latest_block, last_received) = match store.latest_entry() {
+            Some((number, entry)) => (Some(number), entry.received_unix.max(started)),
+            None => (None, started),
+        };
+        let idle = now.saturating_sub(last_received);
+        if settings.stall_secs > 0 && idle > settings.stall_secs {
+            let summary = match latest_block {
+                Some(number) => format!("no new preconf for {}s (latest block {})", idle, number),
+                None => format!("no preconf captured since start {}s ago", idle),
+            };
+            let details = serde_json::json!({ "idle_secs": idle, "latest_block": latest_block });
+            alerter.fire(AlertKind::CaptureStall, summary, details).await;
+        } else if latest_block.is_some() {
+            let summary = format!("capturing again (latest block {})", latest_block.unwrap_or_default());
+            alerter.resolve(AlertKind::CaptureStall, summary).await;
+        }
+
+        // Abgelehnte Signaturen im gleitenden Fenster
+        let counters = stats.counters();
+        let new_failures = counters.invalid_signatures.saturating_sub(invalid_seen);
+        invalid_seen = counters.invalid_signatures;
+        if new_failures > 0 {
+            failures.push_back((Instant::now(), new_failures));
+        }
+        let window = Duration::from_secs(settings.signature_window_secs.max(1));
+        while failures.front().is_some_and(|(at, _)| at.elapsed() > window) {
+            failures.pop_front();
+        }
+        let in_window: u64 = failures.iter().map(|(_, count)| count).sum();
+        if settings.signature_failures > 0 && in_window >= settings.signature_failures {
+            if new_failures > 0 {
+                let summary = format!(
+                    "{} invalid signatures in the last {}s ({} since start)",
+                    in_window, settings.signature_window_secs, counters.invalid_signatures
+                );
+                let details = serde_json::json!({
+                    "window_secs": settings.signature_window_secs,
+                    "failures_in_window": in_window,
+                    "failures_total": counters.invalid_signatures,
+                });
+                alerter.fire(AlertKind::SignatureFailures, summary, details).await;
+            }
+        } else if in_window == 0 {
+            alerter.resolve(AlertKind::SignatureFailures, "no invalid signatures in the window".to_string()).await;
+        }
+
+        // Equivocation: jedes neue Ereignis meldet sich (Evidence liegt unter equivocations/)
+        let new_equivocations = counters.equivocations.saturating_sub(equivocations_seen);
+        equivocations_seen = counters.equivocations;
+        if new_equivocations > 0 {
+            let summary = format!(
+                "{} new equivocation(s) detected, evidence in {}/equivocations",
+                new_equivocations,
+                store.output_dir().display()
+            );
+            let details = serde_json::json!({ "new": new_equivocations, "total": counters.equivocations });
+            alerter.fire(AlertKind::Equivocation, summary, details).await;
+        }
+
+        // Belegung und freier Platz (seltener, der Scan blockiert; sofort, solange der Disk-Guard Blöcke löscht)
+        let emergency = disk.low();
+        let due = last_disk_check.map_or(true, |checked| checked.elapsed() >= DISK_CHECK_INTERVAL);
+        if !due && emergency.is_none() {
+            continue;
+        }
+        last_disk_check = Some(Instant::now());
+        let quota_bytes = runtime.borrow().quota_bytes;
+        let scan_stats = stats.clone();
+        let output_dir = store.output_dir().to_path_buf();
+        let usage = tokio::task::spawn_blocking(move || {
+            let used = scan_stats.snapshot().total_bytes;
+            (used, free_bytes(&output_dir))
+        })
+        .await;
+        let Ok((used, free)) = usage else {
+            continue;
+        };
+        let mut problems = Vec::new();
+        if emergency.is_some() {
+            let state = if disk.read_only().is_some() { ", new preconfs rejected" } else { "" };
+            problems.push(format!("emergency pruning{}", state));
+        }
+        if let Some(quota) = quota_bytes.filter(|quota| used > *quota) {
+            problems.push(format!("{} MB used, quota {} MB", used / (1024 * 1024), quota / (1024 * 1024)));
+        }
+        let min_free = settings.min_free_mb * 1024 * 1024;
+        let free = free.map_err(|e| debug!("Free space of the output directory unknown: {}", e)).ok();
+        if let Some(free) = free.filter(|free| settings.min_free_mb > 0 && *free < min_free) {
+            problems.push(format!("{} MB free, minimum {} MB", free / (1024 * 1024), settings.min_free_mb));
+        }
+        if problems.is_empty() {
+            alerter.resolve(AlertKind::DiskQuota, "storage back within limits".to_string()).await;
+        } else {
+            let details = serde_json::json!({
+                "used_bytes": used,
+                "quota_bytes": quota_bytes,
+                "free_bytes": free,
+                "min_free_bytes": min_free,
+            });
+            alerter.fire(AlertKind::DiskQuota, problems.join(", "), details).await;
+        }
+    }
+}
@@ -0,0 +1,530 @@
+// archive.rs - Export/Import von Preconf-Bereichen als tar.zst-Archiv
+//
+// Delta-Blöcke (siehe delta.rs) gehen als Keyframes ins Archiv, jeder Block ist dort also ohne seinen Vorgänger lesbar.
+
+use crate::{
+    delta,
+    durability::{Durability, DurableFs},
+    index::{BlockIndex, IndexEntry},
+    perms,
+    settings::BridgeSettings,
+};
+use alloy::primitives::keccak256;
+use serde::{Deserialize, Serialize};
+use std::{
+    fs,
+    io::{BufReader, BufWriter, Read},
+    path::{Path, PathBuf},
+    time::{SystemTime, UNIX_EPOCH},
+};
+use tracing::{info, warn};
+
+/// Name des Manifests im Archiv (immer der erste Eintrag)
+pub const MANIFEST_NAME: &str = "manifest.json";
+
+/// Aktuelle Version des Archiv-Formats
+pub const ARCHIVE_FORMAT_VERSION: u32 = 1;
+
+/// Größter Eintrag, der beim Import gelesen wird (Payloads sind weit kleiner, siehe KONA_BRIDGE_MAX_PAYLOAD_KB)
+const MAX_ENTRY_BYTES: u64 = 64 * 1024 * 1024;
+
+/// Manifest eines Preconf-Archivs
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ArchiveManifest {
+    pub format_version: u32,
+    pub chain_id: u64,
+    pub from_block: u64,
+    pub to_block: u64,
+    pub created_unix: u64,
+    pub entries: Vec<ArchiveEntry>,
+}
+
+/// Ein Block im Archiv mit Prüfsummen für .raw und .json
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ArchiveEntry {
+    pub block_number: u64,
+    pub block_hash: Option<String>,
+    pub raw_file: String,
+    pub raw_keccak: String,
+    pub meta_file: Option<String>,
+    pub meta_keccak: Option<String>,
+}
+
+/// Ergebnis eines Imports
+#[derive(Debug, Default)]
+pub struct ImportSummary {
+    pub imported: u32,
+    pub skipped: u32,
+}
+
+/// Exportiert alle Preconfs im Bereich [from_block, to_block] als tar.zst mit Manifest
+pub fn export_archive(
+    output_dir: &Path,
+    chain_id: u64,
+    from_block: u64,
+    to_block: u64,
+    dest: &Path,
+) -> Result<ArchiveManifest, Box<dyn std::error::Error + Send + Sync>> {
+    if from_block > to_block {
+        return Err(format!("Invalid range: {} > {}", from_block, to_block).into());
+    }
+
+    // Erster Durchlauf: vorhandene Blöcke sammeln und Prüfsummen berechnen,
+    // damit das Manifest vor den Dateien ins Archiv geschrieben werden kann
+    // Deltas werden beim Lesen über ihre Basis im Verzeichnis der Chain rekonstruiert
+    if !delta::is_registered(chain_id) {
+        delta::register_dir(chain_id, output_dir);
+    }
+    let mut entries = Vec::new();
+    for (block_number, raw_file) in list_block_files(output_dir, chain_id)? {
+        if block_number < from_block || block_number > to_block {
+            continue;
+        }
+
+        let meta_name = format!("block_{}_{}.json", chain_id, block_number);
+        let (raw_data, meta_data) = export_files(output_dir, &raw_file, &meta_name)?;
+        let (meta_file, meta_keccak, block_hash) = match meta_data {
+            Some(meta_data) => {
+                let block_hash = serde_json::from_slice::<serde_json::Value>(&meta_data)
+                    .ok()
+                    .and_then(|v| v["block_hash"].as_str().map(|s| s.to_string()));
+                (Some(meta_name), Some(format!("{:x}", keccak256(&meta_data))), block_hash)
+            }
+            None => (None, None, None),
+        };
+
+        entries.push(ArchiveEntry {
+            block_number,
+            block_hash,
+            raw_file,
+            raw_keccak: format!("{:x}", keccak256(&raw_data)),
+            meta_file,
+            meta_keccak,
+        });
+    }
+
+    if entries.is_empty() {
+        return Err(format!("No preconfs found for chain {} in range {}-{}", chain_id, from_block, to_block).into());
+    }
+
+    let manifest = ArchiveManifest {
+        format_version: ARCHIVE_FORMAT_VERSION,
+        chain_id,
+        from_block,
+        to_block,
+        created_unix: SystemTime::now().duration_since(UNIX_EPOCH)?.as_secs(),
+        entries,
+    };
+
+    // Zweiter Durchlauf: Archiv schreiben (atomar über temp-Datei)
+    let temp_dest = dest.with_extension("tmp");
+    {
+        let file = BufWriter::new(fs::File::create(&temp_dest)?);
+        let encoder = zstd::stream::write::Encoder::new(file, 3)?;
+        let mut builder = tar::Builder::new(encoder);
+
+        let manifest_json = serde_json::to_vec_pretty(&manifest)?;
+        append_bytes(&mut builder, MANIFEST_NAME, &manifest_json, manifest.created_unix)?;
+
+        for entry in &manifest.entries {
+            let meta_name = format!("block_{}_{}.json", chain_id, entry.block_number);
+            let (raw_data, meta_data) = export_files(output_dir, &entry.raw_file, &meta_name)?;
+            append_bytes(&mut builder, &entry.raw_file, &raw_data, manifest.created_unix)?;
+            if let (Some(meta_file), Some(meta_data)) = (&entry.meta_file, meta_data) {
+                append_bytes(&mut builder, meta_file, &meta_data, manifest.created_unix)?;
+            }
+        }
+
+        let encoder = builder.into_inner()?;
+        encoder.finish()?.into_inner().map_err(|e| e.into_error())?.sync_all()?;
+    }
+    fs::rename(&temp_dest, dest)?;
+
+    info!(
+        "📦 Exported {} preconfs (chain {}, blocks {}-{}) to {:?}",
+        manifest.entries.len(),
+        chain_id,
+        from_block,
+        to_block,
+        dest
+    );
+
+    Ok(manifest)
+}
+
+/// .raw und .json eines Blocks, wie sie ins Archiv gehen: Deltas als Keyframes, weil ihre Basis (etwa from_block - 1)
+/// nicht im Archiv liegen muss; compressed_size in den Metadaten passt zum neuen Body
+fn export_files(
+    output_dir: &Path,
+    raw_file: &str,
+    meta_file: &str,
+) -> Result<(Vec<u8>, Option<Vec<u8>>), Box<dyn std::error::Error + Send + Sync>> {
+    let raw_data = fs::read(output_dir.join(raw_file))?;
+    let meta_data = fs::read(output_dir.join(meta_file)).ok();
+    let Some((raw_data, body_len)) = delta::as_keyframe(&raw_data)? else {
+        return Ok((raw_data, meta_data));
+    };
+    let meta_data = match meta_data {
+        Some(meta_data) => {
+            let mut metadata: serde_json::Value = serde_json::from_slice(&meta_data)?;
+            metadata["compressed_size"] = serde_json::json!(body_len);
+            Some(serde_json::to_vec_pretty(&metadata)?)
+        }
+        None => None,
+    };
+    Ok((raw_data, meta_data))
+}
+
+/// Importiert ein tar.zst-Archiv in das Output-Verzeichnis.
+/// Jede Datei wird gegen die Prüfsumme im Manifest validiert; bereits vorhandene Blöcke werden übersprungen,
+/// importierte in den Block-Index aufgenommen. `expected_chain_id` = 0 akzeptiert jede Chain.
+pub fn import_archive(
+    output_dir: &Path,
+    src: &Path,
+    expected_chain_id: u64,
+) -> Result<ImportSummary, Box<dyn std::error::Error + Send + Sync>> {
+    perms::create_dir_all(output_dir)?;
+
+    let file = BufReader::new(fs::File::open(src)?);
+    let decoder = zstd::stream::read::Decoder::new(file)?;
+    let mut archive = tar::Archive::new(decoder);
+
+    let mut manifest: Option<ArchiveManifest> = None;
+    let mut summary = ImportSummary::default();
+    let mut imported = Vec::new();
+
+    for item in archive.entries()? {
+        let item = item?;
+        let name = item.path()?.to_string_lossy().to_string();
+        let mut data = Vec::new();
+        item.take(MAX_ENTRY_BYTES + 1).read_to_end(&mut data)?;
+        if data.len() as u64 > MAX_ENTRY_BYTES {
+            return Err(format!("Archive entry {} exceeds {} bytes", name, MAX_ENTRY_BYTES).into());
+        }
+
+        if name == MANIFEST_NAME {
+            let parsed = parse_manifest(&data, expected_chain_id)?;
+            manifest = Some(parsed);
+            continue;
+        }
+
+        let manifest = manifest.as_ref().ok_or("Archive does not start with a manifest")?;
+
+        // Nur Dateien aus dem (geprüften) Manifest akzeptieren
+        let (entry, expected_hash) = manifest
+            .entries
+            .iter()
+            .find_map(|e| {
+                if e.raw_file == name {
+                    Some((e, e.raw_keccak.as_str()))
+                } else if e.meta_file.as_deref() == Some(name.as_str()) {
+                    e.meta_keccak.as_deref().map(|hash| (e, hash))
+                } else {
+                    None
+                }
+            })
+            .ok_or_else(|| format!("Unexpected file in archive: {}", name))?;
+
+        let actual_hash = format!("{:x}", keccak256(&data));
+        if actual_hash != expected_hash {
+            return Err(format!("Checksum mismatch for {}: expected {}, got {}", name, expected_hash, actual_hash).into());
+        }
+
+        let target = output_dir.join(&name);
+        if target.exists() {
+            if name.ends_with(".raw") {
+                summary.skipped += 1;
+            }
+            continue;
+        }
+
+        let temp_target: PathBuf = target.with_extension("tmp");
+        fs::write(&temp_target, &data)?;
+        perms::apply_file(&temp_target);
+        fs::rename(&temp_target, &target)?;
+
+        if name.ends_with(".raw") {
+            summary.imported += 1;
+            imported.push(entry.clone());
+        }
+    }
+
+    let manifest = manifest.ok_or("Archive contains no manifest")?;
+    if !imported.is_empty() {
+        if let Err(e) = index_imported(output_dir, manifest.chain_id, &imported) {
+            warn!("⚠️  Imported blocks not added to the block index: {}", e);
+        }
+    }
+    let expected_raw = manifest.entries.len() as u32;
+    if summary.imported + summary.skipped != expected_raw {
+        warn!(
+            "⚠️  Archive incomplete: manifest lists {} preconfs, found {}",
+            expected_raw,
+            summary.imported + summary.skipped
+        );
+    }
+
+    info!(
+        "📥 Imported {} preconfs (chain {}, blocks {}-{}), {} already present",
+        summary.imported, manifest.chain_id, manifest.from_block, manifest.to_block, summary.skipped
+    );
+
+    Ok(summary)
+}
+
+/// Liest nur das Manifest (den ersten Eintrag) eines Archivs, etwa um vor dem Import das Verzeichnis der Chain zu
+/// bestimmen; `expected_chain_id` wie bei import_archive
+pub fn read_manifest(
+    src: &Path,
+    expected_chain_id: u64,
+) -> Result<ArchiveManifest, Box<dyn std::error::Error + Send + Sync>> {
+    let file = BufReader::new(fs::File::open(src)?);
+    let mut archive = tar::Archive::new(zstd::stream::read::Decoder::new(file)?);
+    let item = archive.entries()?.next().ok_or("Archive contains no manifest")??;
+    if item.path()?.to_string_lossy() != MANIFEST_NAME {
+        return Err("Archive does not start with a manifest".into());
+    }
+    let mut data = Vec::new();
+    item.take(MAX_ENTRY_BYTES + 1).read_to_end(&mut data)?;
+    if data.len() as u64 > MAX_ENTRY_BYTES {
+        return Err(format!("Archive entry {} exceeds {} bytes", MANIFEST_NAME, MAX_ENTRY_BYTES).into());
+    }
+    parse_manifest(&data, expected_chain_id)
+}
+
+/// Parst und prüft das Manifest (Format-Version, Chain, Dateinamen)
+fn parse_manifest(
+    data: &[u8],
+    expected_chain_id: u64,
+) -> Result<ArchiveManifest, Box<dyn std::error::Error + Send + Sync>> {
+    let parsed: ArchiveManifest =
+        serde_json::from_slice(data).map_err(|e| format!("Invalid archive manifest: {}", e))?;
+    if parsed.format_version > ARCHIVE_FORMAT_VERSION {
+        return Err(format!("Unsupported archive format version {}", parsed.format_version).into());
+    }
+    if expected_chain_id != 0 && parsed.chain_id != expected_chain_id {
+        return Err(format!("Archive is for chain {}, expected chain {}", parsed.chain_id, expected_chain_id).into());
+    }
+    // Das Manifest stammt aus dem Archiv selbst: nur block_{chain}_{n}.raw/.json der eigenen Chain als
+    // einfache Dateinamen, sonst könnte es Pfade außerhalb des Output-Verzeichnisses nennen
+    for entry in &parsed.entries {
+        let files = std::iter::once(entry.raw_file.as_str()).chain(entry.meta_file.as_deref());
+        for file in files {
+            if block_file_number(file, parsed.chain_id) != Some(entry.block_number) {
+                return Err(format!("Invalid file name in archive manifest: {:?}", file).into());
+            }
+        }
+    }
+    Ok(parsed)
+}
+
+/// Blocknummer eines einfachen Dateinamens block_{chain_id}_{n}.raw bzw. .json (keine Pfade)
+fn block_file_number(name: &str, chain_id: u64) -> Option<u64> {
+    let rest = name.strip_prefix(&format!("block_{}_", chain_id))?;
+    let number = rest.strip_suffix(".raw").or_else(|| rest.strip_suffix(".json"))?;
+    if number.is_empty() || !number.bytes().all(|b| b.is_ascii_digit()) {
+        return None;
+    }
+    number.parse().ok()
+}
+
+/// Nimmt importierte Blöcke in den Block-Index auf (block_index.idx bzw. block_index.json wie beim Start der Bridge).
+/// Eine laufende Bridge derselben Chain kennt sie erst nach dem nächsten Start (Abgleich mit den Metadaten).
+fn index_imported(
+    output_dir: &Path,
+    chain_id: u64,
+    entries: &[ArchiveEntry],
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let settings = BridgeSettings::from_env(chain_id);
+    let mut index = match settings.index_mmap_blocks {
+        Some(blocks) => BlockIndex::mapped(output_dir, chain_id, blocks)?,
+        None if settings.persist_index => BlockIndex::load(output_dir, chain_id),
+        None => return Ok(()),
+    };
+    for entry in entries {
+        let metadata = entry
+            .meta_file
+            .as_ref()
+            .and_then(|file| fs::read(output_dir.join(file)).ok())
+            .and_then(|data| serde_json::from_slice::<serde_json::Value>(&data).ok())
+            .unwrap_or_default();
+        let block_hash = metadata["block_hash"].as_str().or(entry.block_hash.as_deref());
+        let Some(block_hash) = block_hash.and_then(|hash| hex::decode(hash.trim_start_matches("0x")).ok()) else {
+            continue;
+        };
+        let Ok(block_hash) = block_hash.try_into() else {
+            continue;
+        };
+        index.insert(
+            entry.block_number,
+            IndexEntry {
+                block_hash,
+                file: entry.raw_file.clone(),
+                received_unix: metadata["received_unix"].as_u64().unwrap_or(0),
+                timestamp: metadata["timestamp"].as_u64().unwrap_or(0),
+            },
+        );
+    }
+    index.save(output_dir, chain_id, &DurableFs::new(output_dir, Durability::default()))
+}
+
+/// Listet alle block_{chain_id}_{number}.raw Dateien sortiert nach Blocknummer
+fn list_block_files(
+    output_dir: &Path,
+    chain_id: u64,
+) -> Result<Vec<(u64, String)>, Box<dyn std::error::Error + Send + Sync>> {
+    let prefix = format!("block_{}_", chain_id);
+    let mut files = Vec::new();
+
+    for entry in fs::read_dir(output_dir)? {
+        let filename = entry?.file_name().to_string_lossy().to_string();
+        if let Some(number_str) = filename.strip_prefix(&prefix).and_then(|s| s.strip_suffix(".raw")) {
+            if let Ok(block_number) = number_str.parse::<u64>() {
+                files.push((block_number, filename));
+            }
+        }
+    }
+
+    files.sort_by_key(|&(number, _)| number);
+    Ok(files)
+}
+
+fn append_bytes<W: std::io::Write>(
+    builder: &mut tar::Builder<W>,
+    name: &str,
+    data: &[u8],
+    mtime: u64,
+) -> std::io::Result<()> {
+    let mut header = tar::Header::new_gnu();
+    header.set_size(data.len() as u64);
+    header.set_mode(0o644);
+    header.set_mtime(mtime);
+    header.set_cksum();
+    builder.append_data(&mut header, name, data)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::{
+        codec::{encode_container, PayloadCodec, PreconfContainer, Reference, SIGNATURE_SIZE},
+        ssz::{tests::TestPayload, PayloadVersion},
+    };
+
+    const SIGNATURE: [u8; SIGNATURE_SIZE] = [0x5a; SIGNATURE_SIZE];
+
+    fn payload(block_number: u64) -> Vec<u8> {
+        let mut payload = TestPayload::sample(PayloadVersion::V3);
+        payload.block_number = block_number;
+        payload.timestamp += block_number * 2;
+        payload.encode()
+    }
+
+    /// Blöcke 100-103 wie im Delta-Modus: 100 Keyframe, die übrigen Deltas gegen ihren Vorgänger
+    fn store_delta_chain(dir: &Path, chain_id: u64) {
+        for block_number in 100..=103 {
+            let base = (block_number > 100).then(|| payload(block_number - 1));
+            let reference = base.as_deref().map(Reference::Delta);
+            let (raw, body_len) = encode_container(
+                PayloadCodec::Zstd(3),
+                chain_id,
+                block_number,
+                &payload(block_number),
+                &SIGNATURE,
+                reference,
+            )
+            .unwrap();
+            fs::write(dir.join(format!("block_{}_{}.raw", chain_id, block_number)), raw).unwrap();
+            let metadata = serde_json::json!({
+                "block_number": block_number,
+                "block_hash": format!("0x{:064x}", block_number),
+                "compressed_size": body_len,
+            });
+            fs::write(dir.join(format!("block_{}_{}.json", chain_id, block_number)), metadata.to_string()).unwrap();
+        }
+    }
+
+    #[test]
+    fn mid_chain_export_imports_as_keyframes() {
+        let chain_id = 0xa4c1;
+        let source = tempfile::tempdir().unwrap();
+        let target = tempfile::tempdir().unwrap();
+        let archive = source.path().join("range.tar.zst");
+        store_delta_chain(source.path(), chain_id);
+
+        // 102 ist ein Delta gegen 101, das nicht im Archiv liegt
+        let manifest = export_archive(source.path(), chain_id, 102, 103, &archive).unwrap();
+        assert_eq!(manifest.entries.len(), 2);
+        assert_eq!(manifest.entries[0].block_hash.as_deref(), Some(format!("0x{:064x}", 102).as_str()));
+
+        let summary = import_archive(target.path(), &archive, chain_id).unwrap();
+        assert_eq!((summary.imported, summary.skipped), (2, 0));
+        for block_number in [102, 103] {
+            let data = fs::read(target.path().join(format!("block_{}_{}.raw", chain_id, block_number))).unwrap();
+            let container = PreconfContainer::parse(&data).unwrap();
+            assert_eq!(container.delta_base, None);
+            assert_eq!(container.signature, &SIGNATURE[..]);
+            assert_eq!(container.payload().unwrap(), payload(block_number));
+
+            let meta = fs::read(target.path().join(format!("block_{}_{}.json", chain_id, block_number))).unwrap();
+            let metadata: serde_json::Value = serde_json::from_slice(&meta).unwrap();
+            assert_eq!(metadata["compressed_size"], serde_json::json!(container.body.len()));
+        }
+        assert!(!target.path().join(format!("block_{}_101.raw", chain_id)).exists());
+
+        // Zweiter Import: alles schon vorhanden
+        let summary = import_archive(target.path(), &archive, chain_id).unwrap();
+        assert_eq!((summary.imported, summary.skipped), (0, 2));
+    }
+
+    #[test]
+    fn export_keeps_keyframes_unchanged() {
+        let chain_id = 0xa4c2;
+        let source = tempfile::tempdir().unwrap();
+        let target = tempfile::tempdir().unwrap();
+        let archive = source.path().join("range.tar.zst");
+        store_delta_chain(source.path(), chain_id);
+
+        export_archive(source.path(), chain_id, 100, 100, &archive).unwrap();
+        import_archive(target.path(), &archive, 0).unwrap();
+        let name = format!("block_{}_100.raw", chain_id);
+        assert_eq!(fs::read(target.path().join(&name)).unwrap(), fs::read(source.path().join(&name)).unwrap());
+    }
+
+    #[test]
+    fn import_rejects_other_chain() {
+        let chain_id = 0xa4c3;
+        let source = tempfile::tempdir().unwrap();
+        let archive = source.path().join("range.tar.zst");
+        store_delta_chain(source.path(), chain_id);
+
+        export_archive(source.path(), chain_id, 100, 101, &archive).unwrap();
+        assert!(import_archive(tempfile::tempdir().unwrap().path(), &archive, chain_id + 1).is_err());
+        assert!(export_archive(source.path(), chain_id, 200, 300, &archive).is_err());
+    }
+
+    #[test]
+    fn reads_manifest_before_import() {
+        let chain_id = 0xa4c4;
+        let source = tempfile::tempdir().unwrap();
+        let archive = source.path().join("range.tar.zst");
+        store_delta_chain(source.path(), chain_id);
+        export_archive(source.path(), chain_id, 101, 103, &archive).unwrap();
+
+        let manifest = read_manifest(&archive, 0).unwrap();
+        assert_eq!((manifest.chain_id, manifest.from_block, manifest.to_block), (chain_id, 101, 103));
+        assert_eq!(read_manifest(&archive, chain_id).unwrap().entries.len(), 3);
+        assert!(read_manifest(&archive, chain_id + 1).is_err());
+        assert!(read_manifest(&source.path().join(format!("block_{}_101.raw", chain_id)), 0).is_err());
+    }
+
+    #[test]
+    fn block_file_names() {
+        assert_eq!(block_file_number("block_10_123.raw", 10), Some(123));
+        assert_eq!(block_file_number("block_10_123.json", 10), Some(123));
+        assert_eq!(block_file_number("block_11_123.raw", 10), None);
+        assert_eq!(block_file_number("../block_10_123.raw", 10), None);
+        assert_eq!(block_file_number("block_10_.raw", 10), None);
+        assert_eq!(block_file_number("block_10_1/2.raw", 10), None);
+    }
+}
@@ -0,0 +1,136 @@
+// attestation.rs - Betreiber-Signatur über gespeicherte Metadaten und den Block-Index
+//
+// Optional signiert die Bridge jede block_*.json und block_index.json mit einem Betreiber-Schlüssel, damit
+// Konsumenten des Output-Verzeichnisses prüfen können, dass Einträge von einer bestimmten Bridge-Instanz
+// stammen. Signiert wird nach EIP-191 (personal_sign) über keccak256 der Daten:
+//   keccak256("\x19Ethereum Signed Message:\n32" || keccak256(data))
+// Metadaten: data = kompaktes JSON mit sortierten Schlüsseln ohne das Feld "attestation"; über raw_keccak
+// ist auch die .raw Datei abgedeckt. Block-Index: data = Inhalt von block_index.json, Signatur daneben in
+// block_index.json.sig.
+//
+// Schlüssel entweder lokal (Datei mit hex Private Key) oder remote per eth_sign über JSON-RPC, z.B. ein
+// Web3Signer mit KMS-Backend - der Schlüssel verlässt dann nie das KMS.
+
+use crate::{settings::AttestationSettings, utils::signature_to_bytes};
+use alloy::{
+    primitives::{keccak256, Address, PrimitiveSignature, B256, U256},
+    signers::{local::PrivateKeySigner, SignerSync},
+};
+use std::{fs, str::FromStr, time::Duration};
+
+/// Signatur zu block_index.json (gleiches Verzeichnis)
+pub const INDEX_SIGNATURE_FILE_NAME: &str = "block_index.json.sig";
+
+const ATTESTATION_SCHEME: &str = "eip191-keccak256";
+
+enum OperatorKey {
+    Local(PrivateKeySigner),
+    Remote { url: String, address: Address, client: reqwest::Client },
+}
+
+/// Signiert Metadaten und Index mit dem Betreiber-Schlüssel
+pub struct Attestor {
+    key: OperatorKey,
+}
+
+impl Attestor {
+    /// Lädt den Schlüssel (Datei) bzw. übernimmt Endpoint und Adresse des Remote-Signers
+    pub fn from_settings(settings: &AttestationSettings) -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        let key = match settings {
+            AttestationSettings::KeyFile(path) => {
+                let content = fs::read_to_string(path).map_err(|e| format!("Cannot read {:?}: {}", path, e))?;
+                let signer = PrivateKeySigner::from_str(content.trim().trim_start_matches("0x"))
+                    .map_err(|e| format!("Invalid operator key in {:?}: {}", path, e))?;
+                OperatorKey::Local(signer)
+            }
+            AttestationSettings::Remote { url, address } => OperatorKey::Remote {
+                url: url.clone(),
+                address: *address,
+                client: reqwest::Client::new(),
+            },
+        };
+        Ok(Self { key })
+    }
+
+    pub fn address(&self) -> Address {
+        match self.key {
+            OperatorKey::Local(ref signer) => signer.address(),
+            OperatorKey::Remote { address, .. } => address,
+        }
+    }
+
+    /// Signiert `data` und liefert den Attestation-Eintrag (Schema, Signer, Hash, Signatur)
+    pub async fn attest(&self, data: &[u8]) -> Result<serde_json::Value, Box<dyn std::error::Error + Send + Sync>> {
+        let hash = keccak256(data);
+        let signature = match self.key {
+            OperatorKey::Local(ref signer) => signature_to_bytes(&signer.sign_message_sync(hash.as_slice())?),
+            OperatorKey::Remote { ref url, address, ref client } => remote_sign(client, url, address, &hash.0).await?,
+        };
+        Ok(serde_json::json!({
+            "scheme": ATTESTATION_SCHEME,
+            "signer": format!("{}", self.address()),
+            "hash": format!("0x{}", hex::encode(hash)),
+            "signature": format!("0x{}", hex::encode(signature)),
+        }))
+    }
+}
+
+/// Daten, über die die Attestation einer block_*.json gebildet wird
+pub fn canonical_metadata(metadata: &serde_json::Value) -> Vec<u8> {
+    let mut metadata = metadata.clone();
+    if let serde_json::Value::Object(ref mut fields) = metadata {
+        fields.remove("attestation");
+    }
+    serde_json::to_vec(&metadata).unwrap_or_default()
+}
+
+/// EIP-191-Hash einer 32-Byte-Nachricht
+fn personal_message_hash(hash: &[u8; 32]) -> B256 {
+    let mut message = Vec::with_capacity(60);
+    message.extend_from_slice(b"\x19Ethereum Signed Message:\n32");
+    message.extend_from_slice(hash);
+    keccak256(&message)
+}
+
+/// eth_sign beim Remote-Signer; die Antwort wird gegen die konfigurierte Adresse geprüft
+async fn remote_sign(
+    client: &reqwest::Client,
+    url: &str,
+    address: Address,
+    hash: &[u8; 32],
+) -> Result<[u8; 65], Box<dyn std::error::Error + Send + Sync>> {
+    let request = serde_json::json!({
+        "jsonrpc": "2.0",
+        "id": 1,
+        "method": "eth_sign",
+        "params": [format!("{}", address), format!("0x{}", hex::encode(hash))],
+    });
+    let response = client.post(url).json(&request).timeout(Duration::from_secs(10)).send().await?;
+    if !response.status().is_success() {
+        return Err(format!("HTTP {} from {}", response.status(), url).into());
+    }
+    let body: serde_json::Value = response.json().await?;
+    if let Some(error) = body.get("error") {
+        return Err(format!("eth_sign failed: {}", error).into());
+    }
+    let result = body["result"].as_str().ok_or("eth_sign without result")?;
+    let bytes = hex::decode(result.trim_start_matches("0x"))?;
+    let mut signature: [u8; 65] = bytes
+        .as_slice()
+        .try_into()
+        .map_err(|_| format!("Unexpected signature length {} from {}", bytes.len(), url))?;
+    if signature[64] < 27 {
+        signature[64] += 27;
+    }
+
+    let parsed = PrimitiveSignature::new(
+        U256::from_be_slice(&signature[..32]),
+        U256::from_be_slice(&signature[32..64]),
+        signature[64] == 28,
+    );
+    let recovered = parsed.recover_address_from_prehash(&personal_message_hash(hash))?;
+    if recovered != address {
+        return Err(format!("Remote signer returned a signature by {} instead of {}", recovered, address).into());
+    }
+    Ok(signature)
+}
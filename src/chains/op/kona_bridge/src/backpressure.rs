@@ -0,0 +1,121 @@
+// backpressure.rs - Verhalten der Gossip-Pipeline, wenn die Write-Stufe nicht nachkommt
+//
+// Die Queue zwischen Empfang und Write-Stufe ist begrenzt (KONA_BRIDGE_GOSSIP_QUEUE). Ist sie voll, entscheidet
+// KONA_BRIDGE_GOSSIP_DROP_POLICY (bzw. KONA_BRIDGE_GOSSIP_DROP_POLICY_{chain_id}):
+//   drop-newest   der neue Block wird verworfen (Default, die Queue schreibt ab, was sie schon hat)
+//   drop-oldest   der älteste wartende Block wird verworfen, die Queue bleibt nah am Head
+//   block         der Empfang wartet auf Platz; kona verwirft dann selbst (Receiver lagged), das wird mitgezählt
+// Verworfene Nachrichten zählt gossip_dropped in den Speicher-Statistiken.
+
+use std::{
+    collections::VecDeque,
+    fmt,
+    sync::{
+        atomic::{AtomicBool, Ordering},
+        Mutex,
+    },
+};
+use tokio::sync::Notify;
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum DropPolicy {
+    Block,
+    DropOldest,
+    #[default]
+    DropNewest,
+}
+
+impl fmt::Display for DropPolicy {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        let name = match self {
+            DropPolicy::Block => "block",
+            DropPolicy::DropOldest => "drop-oldest",
+            DropPolicy::DropNewest => "drop-newest",
+        };
+        write!(f, "{}", name)
+    }
+}
+
+impl DropPolicy {
+    /// Parst "block", "drop-oldest" oder "drop-newest" (auch mit Unterstrich)
+    pub fn parse(value: &str) -> Option<Self> {
+        match value.trim().to_ascii_lowercase().as_str() {
+            "block" => Some(DropPolicy::Block),
+            "drop-oldest" | "drop_oldest" | "oldest" => Some(DropPolicy::DropOldest),
+            "drop-newest" | "drop_newest" | "newest" | "" => Some(DropPolicy::DropNewest),
+            _ => None,
+        }
+    }
+}
+
+/// Begrenzte Queue zwischen zwei Stufen (ein Produzent, ein Konsument)
+pub struct StageQueue<T> {
+    items: Mutex<VecDeque<T>>,
+    capacity: usize,
+    closed: AtomicBool,
+    filled: Notify, // neues Element oder geschlossen
+    freed: Notify,  // Platz frei geworden
+}
+
+impl<T> StageQueue<T> {
+    pub fn new(capacity: usize) -> Self {
+        let capacity = capacity.max(1);
+        Self {
+            items: Mutex::new(VecDeque::with_capacity(capacity)),
+            capacity,
+            closed: AtomicBool::new(false),
+            filled: Notify::new(),
+            freed: Notify::new(),
+        }
+    }
+
+    /// Stellt ein Element ein und gibt das verworfene zurück (das neue bei drop-newest, das älteste bei
+    /// drop-oldest); bei block wird gewartet, bis die Gegenseite Platz macht
+    pub async fn push(&self, item: T, policy: DropPolicy) -> Option<T> {
+        loop {
+            {
+                let mut items = self.items.lock().unwrap();
+                if items.len() < self.capacity {
+                    items.push_back(item);
+                    drop(items);
+                    self.filled.notify_one();
+                    return None;
+                }
+                match policy {
+                    DropPolicy::DropNewest => return Some(item),
+                    DropPolicy::DropOldest => {
+                        let oldest = items.pop_front();
+                        items.push_back(item);
+                        drop(items);
+                        self.filled.notify_one();
+                        return oldest;
+                    }
+                    DropPolicy::Block => {}
+                }
+            }
+            // notify_one hinterlegt ein Permit, ein Pop zwischen Prüfung und Warten geht nicht verloren
+            self.freed.notified().await;
+        }
+    }
+
+    /// Nächstes Element in Einstellungsreihenfolge, None sobald geschlossen und leer
+    pub async fn pop(&self) -> Option<T> {
+        loop {
+            let item = self.items.lock().unwrap().pop_front();
+            if let Some(item) = item {
+                self.freed.notify_one();
+                return Some(item);
+            }
+            if self.closed.load(Ordering::Acquire) {
+                return None;
+            }
+            self.filled.notified().await;
+        }
+    }
+
+    /// Keine weiteren Elemente; pop() liefert noch die wartenden
+    pub fn close(&self) {
+        self.closed.store(true, Ordering::Release);
+        self.filled.notify_one();
+    }
+}
@@ -0,0 +1,63 @@
+// opg_bridge.rs - Kommandozeilen-Werkzeuge für gespeicherte Preconfs
+//
+//   opg_bridge decode <file.raw> [--chain-id N] [--signer 0x..] [--json]
+//
+// Exit-Code 1, wenn die Datei nicht lesbar ist oder eine Prüfung fehlschlägt.
+
+use alloy::primitives::Address;
+use clap::{Parser, Subcommand};
+use kona_bridge::decode::{decode_file, format_summary};
+use std::{path::PathBuf, process::ExitCode};
+
+#[derive(Parser)]
+#[command(name = "opg_bridge")]
+#[command(about = "Werkzeuge für gespeicherte OP-Stack-Preconfs")]
+struct Cli {
+    #[command(subcommand)]
+    command: Command,
+}
+
+#[derive(Subcommand)]
+enum Command {
+    /// Container auspacken, SSZ dekodieren, Block-Hash und Signatur prüfen
+    Decode {
+        /// Gespeicherte .raw Datei
+        file: PathBuf,
+
+        /// Chain ID (sonst aus Container bzw. Dateiname)
+        #[arg(long)]
+        chain_id: Option<u64>,
+
+        /// Erwarteter Sequencer (sonst aus der Chain-Konfiguration)
+        #[arg(long)]
+        signer: Option<Address>,
+
+        /// Vollständiger Report als JSON statt Zusammenfassung
+        #[arg(long)]
+        json: bool,
+    },
+}
+
+fn main() -> ExitCode {
+    match Cli::parse().command {
+        Command::Decode { file, chain_id, signer, json } => match decode_file(&file, chain_id, signer) {
+            Ok(report) => {
+                if json {
+                    println!("{}", serde_json::to_string_pretty(&report).unwrap_or_default());
+                } else {
+                    print!("{}", format_summary(&report));
+                }
+                let valid = report["errors"].as_array().map_or(true, |errors| errors.is_empty());
+                if valid {
+                    ExitCode::SUCCESS
+                } else {
+                    ExitCode::FAILURE
+                }
+            }
+            Err(e) => {
+                eprintln!("❌ {}: {}", file.display(), e);
+                ExitCode::FAILURE
+            }
+        },
+    }
+}
@@ -0,0 +1,104 @@
+// blobs.rs - Content-addressed Blob-Store für Preconf-Payloads
+//
+// Payloads werden einmal unter {output_dir}/blobs/{keccak}.blob gespeichert, die
+// block_{chain}_{n}.raw Einträge sind Hardlinks darauf. Der Referenzzähler ist der
+// Link-Count des Dateisystems: ein Blob mit nlink == 1 wird von keinem Eintrag mehr
+// referenziert (z.B. nach TTL-Cleanup) und wird von gc_unreferenced_blobs entfernt.
+// Leser (C-Server, Archiv, Sinks) sehen weiterhin normale Dateien.
+
+use crate::{durability::DurableFs, perms};
+use std::{
+    os::unix::fs::MetadataExt,
+    path::{Path, PathBuf},
+    sync::Arc,
+};
+use tokio::fs as tokio_fs;
+use tracing::{debug, info};
+
+pub const BLOB_DIR_NAME: &str = "blobs";
+const BLOB_EXTENSION: &str = "blob";
+
+/// Blob-Verzeichnis unterhalb des Output-Verzeichnisses
+pub struct BlobStore {
+    dir: PathBuf,
+    fs: Arc<DurableFs>,
+}
+
+impl BlobStore {
+    pub fn open(output_dir: &Path, fs: Arc<DurableFs>) -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        let dir = output_dir.join(BLOB_DIR_NAME);
+        perms::create_dir_all(&dir)
+            .map_err(|e| format!("Failed to create blob directory {:?}: {}", dir, e))?;
+        Ok(Self { dir, fs })
+    }
+
+    pub fn blob_path(&self, key: &[u8; 32]) -> PathBuf {
+        self.dir.join(format!("{}.{}", hex::encode(key), BLOB_EXTENSION))
+    }
+
+    /// Prüft, ob `entry` bereits ein Link auf den Blob ist (gleicher Inode)
+    pub async fn is_linked(&self, key: &[u8; 32], entry: &Path) -> bool {
+        match (tokio_fs::metadata(self.blob_path(key)).await, tokio_fs::metadata(entry).await) {
+            (Ok(blob), Ok(entry)) => blob.dev() == entry.dev() && blob.ino() == entry.ino(),
+            _ => false,
+        }
+    }
+
+    /// Liest einen Blob, None wenn er nicht existiert
+    pub async fn get(&self, key: &[u8; 32]) -> Option<Vec<u8>> {
+        tokio_fs::read(self.blob_path(key)).await.ok()
+    }
+
+    /// Speichert einen Blob atomar (tmp + rename)
+    pub async fn put(&self, key: &[u8; 32], data: &[u8]) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        let path = self.blob_path(key);
+        let temp_path = path.with_extension("tmp");
+        self.fs.write_atomic(&temp_path, &path, data).await
+            .map_err(|e| format!("Failed to write blob {:?}: {}", path, e))?;
+        Ok(())
+    }
+
+    /// Lässt `entry` atomar auf den Blob zeigen (Hardlink auf tmp-Namen, dann rename)
+    pub async fn link(&self, key: &[u8; 32], entry: &Path) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        let temp_entry = entry.with_extension("lnk");
+        let _ = tokio_fs::remove_file(&temp_entry).await;
+        tokio_fs::hard_link(self.blob_path(key), &temp_entry).await
+            .map_err(|e| format!("Failed to link blob to {:?}: {}", entry, e))?;
+        tokio_fs::rename(&temp_entry, entry).await
+            .map_err(|e| format!("Failed to rename {:?}: {}", temp_entry, e))?;
+        self.fs.renamed(entry).await
+            .map_err(|e| format!("Failed to sync {:?}: {}", entry, e))?;
+        Ok(())
+    }
+}
+
+/// Entfernt Blobs, auf die kein Eintrag mehr verweist (Link-Count 1)
+pub async fn gc_unreferenced_blobs(output_dir: &Path) -> Result<usize, Box<dyn std::error::Error + Send + Sync>> {
+    let dir = output_dir.join(BLOB_DIR_NAME);
+    let mut entries = match tokio_fs::read_dir(&dir).await {
+        Ok(entries) => entries,
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => return Ok(0),
+        Err(e) => return Err(e.into()),
+    };
+
+    let mut removed = 0;
+    while let Some(entry) = entries.next_entry().await? {
+        let path = entry.path();
+        if path.extension().map_or(true, |ext| ext != BLOB_EXTENSION) {
+            continue;
+        }
+        let metadata = match tokio_fs::metadata(&path).await {
+            Ok(metadata) => metadata,
+            Err(_) => continue,
+        };
+        if metadata.nlink() <= 1 && tokio_fs::remove_file(&path).await.is_ok() {
+            debug!("🧹 Removed unreferenced blob {:?}", path.file_name().unwrap_or_default());
+            removed += 1;
+        }
+    }
+
+    if removed > 0 {
+        info!("🧹 Blob GC: removed {} unreferenced blobs", removed);
+    }
+    Ok(removed)
+}
@@ -0,0 +1,403 @@
+// blockhash.rs - Nachrechnen des Execution-Block-Hashes aus dem dekodierten Payload
+//
+// Der Header wird aus den Payload-Feldern als RLP rekonstruiert (Felder je nach Hardfork, siehe
+// ssz.rs) und keccak256(header) mit dem eingebetteten block_hash verglichen:
+//   v1 Bedrock   London-Header (inkl. base_fee_per_gas)
+//   v2 Canyon    + withdrawals_root (Trie über die Withdrawals, siehe check_withdrawals)
+//   v3 Ecotone   + blob_gas_used, excess_blob_gas, parent_beacon_block_root
+//   v4 Isthmus   withdrawals_root aus dem Payload (L2ToL1MessagePasser) + requests_hash (leer)
+// transactions_root wird als Merkle-Patricia-Trie (Schlüssel rlp(index)) berechnet.
+
+use crate::ssz::{ExecutionPayload, PayloadEnvelope, PayloadVersion, Withdrawal};
+use alloy::primitives::keccak256;
+
+/// keccak256(rlp([])) - leere Ommers-Liste
+pub const EMPTY_OMMERS_HASH: [u8; 32] = hex_literal(b"1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347");
+
+/// sha256("") - requests_hash ohne Requests (OP Isthmus)
+pub const EMPTY_REQUESTS_HASH: [u8; 32] = hex_literal(b"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855");
+
+/// keccak256(rlp("")) - leerer Trie
+pub const EMPTY_ROOT_HASH: [u8; 32] = hex_literal(b"56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421");
+
+const fn hex_literal(hex: &[u8; 64]) -> [u8; 32] {
+    const fn nibble(c: u8) -> u8 {
+        match c {
+            b'0'..=b'9' => c - b'0',
+            _ => c - b'a' + 10,
+        }
+    }
+    let mut out = [0u8; 32];
+    let mut i = 0;
+    while i < 32 {
+        out[i] = nibble(hex[i * 2]) << 4 | nibble(hex[i * 2 + 1]);
+        i += 1;
+    }
+    out
+}
+
+// --- RLP-Encoding ---
+
+fn rlp_length_prefix(out: &mut Vec<u8>, len: usize, offset: u8) {
+    if len < 56 {
+        out.push(offset + len as u8);
+    } else {
+        let bytes = len.to_be_bytes();
+        let skip = bytes.iter().take_while(|b| **b == 0).count();
+        out.push(offset + 55 + (bytes.len() - skip) as u8);
+        out.extend_from_slice(&bytes[skip..]);
+    }
+}
+
+fn rlp_bytes(out: &mut Vec<u8>, data: &[u8]) {
+    if data.len() == 1 && data[0] < 0x80 {
+        out.push(data[0]);
+    } else {
+        rlp_length_prefix(out, data.len(), 0x80);
+        out.extend_from_slice(data);
+    }
+}
+
+/// Skalar: big-endian ohne führende Nullen
+fn rlp_uint(out: &mut Vec<u8>, be: &[u8]) {
+    let skip = be.iter().take_while(|b| **b == 0).count();
+    rlp_bytes(out, &be[skip..]);
+}
+
+fn rlp_u64(out: &mut Vec<u8>, value: u64) {
+    rlp_uint(out, &value.to_be_bytes());
+}
+
+fn rlp_list(payload: &[u8]) -> Vec<u8> {
+    let mut out = Vec::with_capacity(payload.len() + 9);
+    rlp_length_prefix(&mut out, payload.len(), 0xc0);
+    out.extend_from_slice(payload);
+    out
+}
+
+// --- Merkle-Patricia-Trie (nur Root, geordnete Liste) ---
+
+/// Hex-Prefix-Kodierung eines Pfads
+fn hex_prefix(nibbles: &[u8], leaf: bool) -> Vec<u8> {
+    let flag = if leaf { 2 } else { 0 };
+    let mut out = Vec::with_capacity(nibbles.len() / 2 + 1);
+    let rest = if nibbles.len() % 2 == 1 {
+        out.push((flag + 1) << 4 | nibbles[0]);
+        &nibbles[1..]
+    } else {
+        out.push(flag << 4);
+        nibbles
+    };
+    for pair in rest.chunks(2) {
+        out.push(pair[0] << 4 | pair[1]);
+    }
+    out
+}
+
+/// Referenz auf einen Kindknoten: eingebettet (< 32 Bytes) oder als Hash
+fn child_ref(out: &mut Vec<u8>, node: &[u8]) {
+    if node.len() < 32 {
+        out.extend_from_slice(node);
+    } else {
+        rlp_bytes(out, keccak256(node).as_slice());
+    }
+}
+
+/// RLP eines Knotens über sortierte (Pfad, Wert)-Paare ab Tiefe `depth`
+fn trie_node(items: &[(Vec<u8>, &[u8])], depth: usize) -> Vec<u8> {
+    if let [(path, value)] = items {
+        let mut payload = Vec::new();
+        rlp_bytes(&mut payload, &hex_prefix(&path[depth..], true));
+        rlp_bytes(&mut payload, value);
+        return rlp_list(&payload);
+    }
+
+    // Gemeinsamer Pfad -> Extension
+    let (first, last) = (&items[0].0, &items[items.len() - 1].0);
+    let common = first[depth..].iter().zip(&last[depth..]).take_while(|(a, b)| a == b).count();
+    if common > 0 {
+        let mut payload = Vec::new();
+        rlp_bytes(&mut payload, &hex_prefix(&first[depth..depth + common], false));
+        child_ref(&mut payload, &trie_node(items, depth + common));
+        return rlp_list(&payload);
+    }
+
+    // Branch mit 16 Kindern und Wert
+    let mut payload = Vec::new();
+    let mut rest = items;
+    let value = match rest.first() {
+        Some((path, value)) if path.len() == depth => {
+            rest = &rest[1..];
+            Some(*value)
+        }
+        _ => None,
+    };
+    for nibble in 0..16u8 {
+        let count = rest.iter().take_while(|(path, _)| path[depth] == nibble).count();
+        if count == 0 {
+            payload.push(0x80);
+        } else {
+            child_ref(&mut payload, &trie_node(&rest[..count], depth + 1));
+            rest = &rest[count..];
+        }
+    }
+    rlp_bytes(&mut payload, value.unwrap_or_default());
+    rlp_list(&payload)
+}
+
+/// Root eines Tries mit Schlüssel rlp(index) (Transaktionen, Withdrawals)
+pub fn ordered_trie_root(values: &[Vec<u8>]) -> [u8; 32] {
+    let mut items: Vec<(Vec<u8>, &[u8])> = values
+        .iter()
+        .enumerate()
+        .map(|(i, value)| {
+            let mut key = Vec::new();
+            rlp_u64(&mut key, i as u64);
+            let nibbles = key.iter().flat_map(|b| [b >> 4, b & 0x0f]).collect();
+            (nibbles, value.as_slice())
+        })
+        .collect();
+    items.sort_by(|a, b| a.0.cmp(&b.0));
+
+    if items.is_empty() {
+        return EMPTY_ROOT_HASH;
+    }
+    keccak256(trie_node(&items, 0)).0
+}
+
+/// Withdrawal als RLP-Liste [index, validator_index, address, amount]
+fn withdrawal_rlp(w: &Withdrawal) -> Vec<u8> {
+    let mut payload = Vec::new();
+    rlp_u64(&mut payload, w.index);
+    rlp_u64(&mut payload, w.validator_index);
+    rlp_bytes(&mut payload, &w.address);
+    rlp_u64(&mut payload, w.amount);
+    rlp_list(&payload)
+}
+
+/// Root des Withdrawals-Tries aus der Liste im Payload (None vor v2)
+pub fn withdrawals_root(payload: &ExecutionPayload) -> Option<[u8; 32]> {
+    payload.withdrawals?;
+    let withdrawals: Vec<Vec<u8>> = payload.withdrawals().iter().map(withdrawal_rlp).collect();
+    Some(ordered_trie_root(&withdrawals))
+}
+
+/// Prüft die Withdrawals gegen das Header-Feld: OP-Blöcke haben ab Canyon eine leere Liste, der
+/// Header trägt bis Ecotone deren Root (leerer Trie). Ab Isthmus ist das Header-Feld der Storage-Root
+/// des L2ToL1MessagePasser und kommt aus dem Payload; die Liste muss trotzdem leer sein.
+pub fn check_withdrawals(payload: &ExecutionPayload) -> Result<(), String> {
+    let Some(computed) = withdrawals_root(payload) else {
+        return Ok(());
+    };
+    let count = payload.withdrawals().len();
+    if count != 0 {
+        return Err(format!("{} withdrawals in OP payload (must be empty)", count));
+    }
+    if payload.withdrawals_root.is_none() && computed != EMPTY_ROOT_HASH {
+        return Err(format!("withdrawals root 0x{} != empty root", hex::encode(computed)));
+    }
+    Ok(())
+}
+
+/// Root des Transaktions-Tries
+pub fn transactions_root(payload: &ExecutionPayload) -> [u8; 32] {
+    let transactions: Vec<Vec<u8>> = payload.transactions.iter().map(|tx| tx.to_vec()).collect();
+    ordered_trie_root(&transactions)
+}
+
+/// RLP-Header aus dem dekodierten Payload (transactions_root vorab berechnet, siehe transactions_root)
+pub fn header_rlp(envelope: &PayloadEnvelope, transactions_root: &[u8; 32]) -> Vec<u8> {
+    let p = &envelope.payload;
+    let mut base_fee = p.base_fee_per_gas;
+    base_fee.reverse(); // uint256 little-endian -> big-endian
+
+    let mut h = Vec::with_capacity(640);
+    rlp_bytes(&mut h, &p.parent_hash);
+    rlp_bytes(&mut h, &EMPTY_OMMERS_HASH);
+    rlp_bytes(&mut h, &p.fee_recipient);
+    rlp_bytes(&mut h, &p.state_root);
+    rlp_bytes(&mut h, transactions_root);
+    rlp_bytes(&mut h, &p.receipts_root);
+    rlp_bytes(&mut h, p.logs_bloom);
+    rlp_u64(&mut h, 0); // difficulty
+    rlp_u64(&mut h, p.block_number);
+    rlp_u64(&mut h, p.gas_limit);
+    rlp_u64(&mut h, p.gas_used);
+    rlp_u64(&mut h, p.timestamp);
+    rlp_bytes(&mut h, p.extra_data);
+    rlp_bytes(&mut h, &p.prev_randao); // mix_hash
+    rlp_bytes(&mut h, &[0u8; 8]); // nonce
+    rlp_uint(&mut h, &base_fee);
+
+    if let Some(root) = p.withdrawals_root.or_else(|| withdrawals_root(p)) {
+        rlp_bytes(&mut h, &root);
+    }
+    if let (Some(blob_gas_used), Some(excess_blob_gas)) = (p.blob_gas_used, p.excess_blob_gas) {
+        rlp_u64(&mut h, blob_gas_used);
+        rlp_u64(&mut h, excess_blob_gas);
+        rlp_bytes(&mut h, &envelope.parent_beacon_block_root);
+    }
+    if p.version == PayloadVersion::V4 {
+        rlp_bytes(&mut h, &EMPTY_REQUESTS_HASH);
+    }
+    rlp_list(&h)
+}
+
+/// keccak256 des rekonstruierten Headers
+pub fn compute_block_hash(envelope: &PayloadEnvelope, transactions_root: &[u8; 32]) -> [u8; 32] {
+    keccak256(header_rlp(envelope, transactions_root)).0
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::ssz::{decode_envelope, tests::TestPayload};
+    use sha2::{Digest, Sha256};
+
+    // Ethereum-Mainnet-Blöcke aus den Testdaten des Verifiers; ihre Header haben dieselben Felder wie OP-Blöcke:
+    // Cancun = Ecotone (v3), Prague ohne Requests (requests_hash leer) = Isthmus (v4)
+    const CANCUN_BLOCK: &str =
+        include_str!("../../../../../test/data/eth_getBlockByNumber1/eth_getBlockByNumber_0x152c765_false.json");
+    const PRAGUE_BLOCK: &str = include_str!(
+        "../../../../../test/data/eth_getTransactionreceipt_electra/eth_getBlockByNumber_0x1564967_false.json"
+    );
+
+    fn bytes(value: &serde_json::Value) -> Vec<u8> {
+        hex::decode(value.as_str().unwrap().trim_start_matches("0x")).unwrap()
+    }
+
+    fn array<const N: usize>(value: &serde_json::Value) -> [u8; N] {
+        bytes(value).try_into().unwrap()
+    }
+
+    fn quantity(value: &serde_json::Value) -> u64 {
+        u64::from_str_radix(value.as_str().unwrap().trim_start_matches("0x"), 16).unwrap()
+    }
+
+    /// Payload aus eth_getBlockByNumber (ohne Transaktionen, transactions_root kommt aus dem Block) und der Block
+    fn block_payload(json: &str, version: PayloadVersion) -> (TestPayload, serde_json::Value) {
+        let block: serde_json::Value = serde_json::from_str::<serde_json::Value>(json).unwrap()["result"].take();
+        let withdrawals = block["withdrawals"]
+            .as_array()
+            .unwrap()
+            .iter()
+            .map(|w| Withdrawal {
+                index: quantity(&w["index"]),
+                validator_index: quantity(&w["validatorIndex"]),
+                address: array(&w["address"]),
+                amount: quantity(&w["amount"]),
+            })
+            .collect();
+        let payload = TestPayload {
+            version,
+            parent_beacon_block_root: array(&block["parentBeaconBlockRoot"]),
+            parent_hash: array(&block["parentHash"]),
+            fee_recipient: array(&block["miner"]),
+            state_root: array(&block["stateRoot"]),
+            receipts_root: array(&block["receiptsRoot"]),
+            logs_bloom: bytes(&block["logsBloom"]),
+            prev_randao: array(&block["mixHash"]),
+            block_number: quantity(&block["number"]),
+            gas_limit: quantity(&block["gasLimit"]),
+            gas_used: quantity(&block["gasUsed"]),
+            timestamp: quantity(&block["timestamp"]),
+            extra_data: bytes(&block["extraData"]),
+            base_fee_per_gas: quantity(&block["baseFeePerGas"]) as u128,
+            block_hash: array(&block["hash"]),
+            transactions: Vec::new(),
+            withdrawals,
+            blob_gas_used: quantity(&block["blobGasUsed"]),
+            excess_blob_gas: quantity(&block["excessBlobGas"]),
+            withdrawals_root: array(&block["withdrawalsRoot"]),
+        };
+        (payload, block)
+    }
+
+    #[test]
+    fn empty_hashes() {
+        assert_eq!(keccak256([0xc0]).0, EMPTY_OMMERS_HASH);
+        assert_eq!(keccak256([0x80]).0, EMPTY_ROOT_HASH);
+        assert_eq!(<[u8; 32]>::from(Sha256::digest([])), EMPTY_REQUESTS_HASH);
+        assert_eq!(ordered_trie_root(&[]), EMPTY_ROOT_HASH);
+    }
+
+    #[test]
+    fn mainnet_cancun_block_hash() {
+        let (payload, block) = block_payload(CANCUN_BLOCK, PayloadVersion::V3);
+        let data = payload.encode();
+        let envelope = decode_envelope(&data).unwrap();
+        assert_eq!(envelope.payload.version, PayloadVersion::V3);
+
+        // 16 Withdrawals: Root über den Trie aus dem Payload
+        assert_eq!(envelope.payload.withdrawals().len(), 16);
+        assert_eq!(withdrawals_root(&envelope.payload), Some(array(&block["withdrawalsRoot"])));
+
+        let hash = compute_block_hash(&envelope, &array(&block["transactionsRoot"]));
+        assert_eq!(hash, array::<32>(&block["hash"]));
+        assert_eq!(hash, envelope.payload.block_hash);
+    }
+
+    #[test]
+    fn mainnet_prague_block_hash_with_empty_requests() {
+        let (mut payload, block) = block_payload(PRAGUE_BLOCK, PayloadVersion::V4);
+        assert_eq!(array::<32>(&block["requestsHash"]), EMPTY_REQUESTS_HASH);
+        // Wie bei Isthmus: leere Liste, das Header-Feld kommt aus dem Payload
+        payload.withdrawals.clear();
+        let data = payload.encode();
+        let envelope = decode_envelope(&data).unwrap();
+        assert_eq!(envelope.payload.version, PayloadVersion::V4);
+
+        let hash = compute_block_hash(&envelope, &array(&block["transactionsRoot"]));
+        assert_eq!(hash, array::<32>(&block["hash"]));
+        assert!(check_withdrawals(&envelope.payload).is_ok());
+    }
+
+    #[test]
+    fn block_hash_covers_header_fields() {
+        let (payload, block) = block_payload(PRAGUE_BLOCK, PayloadVersion::V4);
+        let transactions_root: [u8; 32] = array(&block["transactionsRoot"]);
+        let mut changed = payload.clone();
+        changed.withdrawals.clear();
+        changed.withdrawals_root[0] ^= 1;
+        let data = changed.encode();
+        let envelope = decode_envelope(&data).unwrap();
+        assert_ne!(compute_block_hash(&envelope, &transactions_root), envelope.payload.block_hash);
+
+        let mut changed = payload;
+        changed.withdrawals.clear();
+        changed.parent_beacon_block_root[31] ^= 1;
+        let data = changed.encode();
+        let envelope = decode_envelope(&data).unwrap();
+        assert_ne!(compute_block_hash(&envelope, &transactions_root), envelope.payload.block_hash);
+    }
+
+    #[test]
+    fn transactions_root_of_payload() {
+        let mut payload = TestPayload::sample(PayloadVersion::V3);
+        payload.transactions.clear();
+        let data = payload.encode();
+        assert_eq!(transactions_root(&decode_envelope(&data).unwrap().payload), EMPTY_ROOT_HASH);
+
+        // Reihenfolge ist Teil des Roots
+        let mut payload = TestPayload::sample(PayloadVersion::V3);
+        let data = payload.encode();
+        let root = transactions_root(&decode_envelope(&data).unwrap().payload);
+        payload.transactions.swap(0, 2);
+        let data = payload.encode();
+        assert_ne!(transactions_root(&decode_envelope(&data).unwrap().payload), root);
+    }
+
+    #[test]
+    fn op_payloads_have_no_withdrawals() {
+        for version in [PayloadVersion::V2, PayloadVersion::V3, PayloadVersion::V4] {
+            let mut payload = TestPayload::sample(version);
+            let data = payload.encode();
+            assert!(check_withdrawals(&decode_envelope(&data).unwrap().payload).is_err());
+            payload.withdrawals.clear();
+            let data = payload.encode();
+            assert!(check_withdrawals(&decode_envelope(&data).unwrap().payload).is_ok());
+        }
+        let data = TestPayload::sample(PayloadVersion::V1).encode();
+        assert!(check_withdrawals(&decode_envelope(&data).unwrap().payload).is_ok());
+    }
+}
@@ -0,0 +1,69 @@
+// c4.rs - Preconfs im SSZ-Format des colibri-Verifiers (siehe src/chains/op/ssz/op_proof_types.h, op_types.c)
+//
+// OP_PRECONF (Block-Proof eines Preconfs):
+//   [offset payload: u32][signature: 65][union selector 0 = compressed_zstd][zstd(parent_beacon_block_root + payload)]
+// C4Request mit BlockProof (wie c4_op_proof_block im Prover):
+//   [version: chain type, major, minor, patch][offset data][offset proof][offset sync_data]
+//   data = NONE, proof = BlockProof { block_proof: union preconf = OP_PRECONF }, sync_data = NONE
+//
+// Damit kann der C-Server bzw. ein Client die Antwort ohne Umpacken als block_proof einsetzen bzw. direkt
+// verifizieren. Optional legt die Bridge den OP_PRECONF-Container beim Speichern als block_{chain}_{n}.c4 ab.
+
+use crate::codec::{to_legacy, SIGNATURE_SIZE};
+
+/// Endung der vorab erzeugten OP_PRECONF-Container
+pub const C4_SUFFIX: &str = ".c4";
+
+/// C4_CHAIN_TYPE_OP (src/util/chains.h)
+const C4_CHAIN_TYPE_OP: u8 = 6;
+/// VERSION_MAJOR, VERSION_MINOR, VERSION_PATCH aus src/util/version.h (muss zum Verifier passen)
+const C4_VERSION: [u8; 3] = [0, 1, 0];
+
+/// Selektoren der SSZ-Unions
+const UNION_NONE: u8 = 0;
+const PAYLOAD_COMPRESSED_ZSTD: u8 = 0; // OP_PRECONF_PAYLOAD_UNION
+const BLOCK_PROOF_PRECONF: u8 = 0; // OP_BLOCKPROOF_UNION
+const PROOF_BLOCK_PROOF: u8 = 6; // C4_OP_REQUEST_PROOFS_UNION
+
+/// SSZ-Offset (u32 little endian)
+fn offset(value: usize) -> [u8; 4] {
+    (value as u32).to_le_bytes()
+}
+
+/// OP_PRECONF-Container aus einer .raw Datei (beliebiger Codec, der Payload wird bei Bedarf nach zstd umkodiert)
+pub fn preconf_proof(raw: &[u8], chain_id: u64) -> Result<Vec<u8>, Box<dyn std::error::Error + Send + Sync>> {
+    let legacy = to_legacy(raw, chain_id)?;
+    if legacy.len() <= SIGNATURE_SIZE {
+        return Err(format!("Preconf too short ({} bytes)", legacy.len()).into());
+    }
+    let (payload, signature) = legacy.split_at(legacy.len() - SIGNATURE_SIZE);
+
+    let mut proof = Vec::with_capacity(4 + SIGNATURE_SIZE + 1 + payload.len());
+    proof.extend_from_slice(&offset(4 + SIGNATURE_SIZE));
+    proof.extend_from_slice(signature);
+    proof.push(PAYLOAD_COMPRESSED_ZSTD);
+    proof.extend_from_slice(payload);
+    Ok(proof)
+}
+
+/// C4Request (BlockProof) um einen OP_PRECONF-Container, wie ihn der Prover für eth_getBlockByNumber erzeugt
+pub fn block_proof_request(preconf: &[u8]) -> Vec<u8> {
+    // BlockProof { block_proof: Union[preconf] }
+    let mut proof = Vec::with_capacity(1 + 4 + 1 + preconf.len());
+    proof.push(PROOF_BLOCK_PROOF);
+    proof.extend_from_slice(&offset(4));
+    proof.push(BLOCK_PROOF_PRECONF);
+    proof.extend_from_slice(preconf);
+
+    const FIXED_SIZE: usize = 4 + 3 * 4;
+    let mut request = Vec::with_capacity(FIXED_SIZE + proof.len() + 2);
+    request.push(C4_CHAIN_TYPE_OP);
+    request.extend_from_slice(&C4_VERSION);
+    request.extend_from_slice(&offset(FIXED_SIZE)); // data
+    request.extend_from_slice(&offset(FIXED_SIZE + 1)); // proof
+    request.extend_from_slice(&offset(FIXED_SIZE + 1 + proof.len())); // sync_data
+    request.push(UNION_NONE);
+    request.extend_from_slice(&proof);
+    request.push(UNION_NONE);
+    request
+}
@@ -0,0 +1,115 @@
+// cbor.rs - Minimaler CBOR-Encoder (RFC 8949) für die kompakte Darstellung der HTTP-API
+//
+// Nur was die API braucht: Zahlen, Text, Byte-Strings, Arrays, Maps, Bool und null. Payload und Signatur gehen als
+// Byte-Strings statt als Hex raus, das halbiert die Größe gegenüber JSON ohne Kompression beim Client.
+// JSON-Werte (z.B. die Metadaten) werden feldweise übernommen.
+
+/// CBOR-Datenelement
+pub enum Cbor {
+    Unsigned(u64),
+    Negative(u64), // -1 - n
+    Float(f64),
+    Bytes(Vec<u8>),
+    Text(String),
+    Array(Vec<Cbor>),
+    Map(Vec<(String, Cbor)>),
+    Bool(bool),
+    Null,
+}
+
+const MAJOR_UNSIGNED: u8 = 0;
+const MAJOR_NEGATIVE: u8 = 1;
+const MAJOR_BYTES: u8 = 2;
+const MAJOR_TEXT: u8 = 3;
+const MAJOR_ARRAY: u8 = 4;
+const MAJOR_MAP: u8 = 5;
+const SIMPLE_FALSE: u8 = 0xf4;
+const SIMPLE_TRUE: u8 = 0xf5;
+const SIMPLE_NULL: u8 = 0xf6;
+const FLOAT_64: u8 = 0xfb;
+
+/// Kopf eines Datenelements: Major Type und Wert/Länge in der kürzesten Form
+fn write_head(out: &mut Vec<u8>, major: u8, value: u64) {
+    let major = major << 5;
+    match value {
+        0..=23 => out.push(major | value as u8),
+        24..=0xff => out.extend_from_slice(&[major | 24, value as u8]),
+        0x100..=0xffff => {
+            out.push(major | 25);
+            out.extend_from_slice(&(value as u16).to_be_bytes());
+        }
+        0x1_0000..=0xffff_ffff => {
+            out.push(major | 26);
+            out.extend_from_slice(&(value as u32).to_be_bytes());
+        }
+        _ => {
+            out.push(major | 27);
+            out.extend_from_slice(&value.to_be_bytes());
+        }
+    }
+}
+
+impl Cbor {
+    pub fn encode(&self) -> Vec<u8> {
+        let mut out = Vec::new();
+        self.write(&mut out);
+        out
+    }
+
+    fn write(&self, out: &mut Vec<u8>) {
+        match self {
+            Cbor::Unsigned(value) => write_head(out, MAJOR_UNSIGNED, *value),
+            Cbor::Negative(value) => write_head(out, MAJOR_NEGATIVE, *value),
+            Cbor::Float(value) => {
+                out.push(FLOAT_64);
+                out.extend_from_slice(&value.to_be_bytes());
+            }
+            Cbor::Bytes(bytes) => {
+                write_head(out, MAJOR_BYTES, bytes.len() as u64);
+                out.extend_from_slice(bytes);
+            }
+            Cbor::Text(text) => {
+                write_head(out, MAJOR_TEXT, text.len() as u64);
+                out.extend_from_slice(text.as_bytes());
+            }
+            Cbor::Array(items) => {
+                write_head(out, MAJOR_ARRAY, items.len() as u64);
+                for item in items {
+                    item.write(out);
+                }
+            }
+            Cbor::Map(entries) => {
+                write_head(out, MAJOR_MAP, entries.len() as u64);
+                for (key, value) in entries {
+                    Cbor::Text(key.clone()).write(out);
+                    value.write(out);
+                }
+            }
+            Cbor::Bool(value) => out.push(if *value { SIMPLE_TRUE } else { SIMPLE_FALSE }),
+            Cbor::Null => out.push(SIMPLE_NULL),
+        }
+    }
+}
+
+impl From<&serde_json::Value> for Cbor {
+    fn from(value: &serde_json::Value) -> Self {
+        match value {
+            serde_json::Value::Null => Cbor::Null,
+            serde_json::Value::Bool(value) => Cbor::Bool(*value),
+            serde_json::Value::Number(number) => {
+                if let Some(value) = number.as_u64() {
+                    Cbor::Unsigned(value)
+                } else if let Some(value) = number.as_i64() {
+                    Cbor::Negative(!(value as u64))
+                } else {
+                    Cbor::Float(number.as_f64().unwrap_or_default())
+                }
+            }
+            serde_json::Value::String(text) => Cbor::Text(text.clone()),
+            serde_json::Value::Array(items) => Cbor::Array(items.iter().map(Cbor::from).collect()),
+            serde_json::Value::Object(entries) => {
+                Cbor::Map(entries.iter().map(|(key, value)| (key.clone(), Cbor::from(value))).collect())
+            }
+        }
+    }
+}
@@ -0,0 +1,184 @@
+// chaincheck.rs - Abgleich der Chain-ID mit der HTTP-Quelle, damit z.B. Base-Preconfs nicht unter OP Mainnet landen
+//
+// Beim Start (vor dem ersten Poll) und danach alle KONA_BRIDGE_CHAIN_CHECK_SECS (Default 600, 0 = nur beim Start)
+// sowie nach einem Wechsel des Endpoints auf SIGHUP. Die Chain der Quelle ergibt sich aus, in dieser Reihenfolge:
+//   eth_chainId              falls die Quelle JSON-RPC spricht
+//   chain_id / chainId       Feld in der Antwort der Quelle
+//   Signatur                 die Sequencer-Signatur enthält die Chain-ID: passt der Signer mit der eigenen Chain-ID
+//                            nicht, aber mit der einer bekannten Chain (config.rs, Registry) zu deren Sequencer,
+//                            liefert die Quelle diese Chain
+// Abweichung: Start bricht ab bzw. die Chain stoppt im Betrieb. Lässt sich die Chain nicht bestimmen, nur ein Log.
+// KONA_BRIDGE_CHAIN_CHECK=0 schaltet den Abgleich ab.
+
+use crate::{
+    config::{ChainConfig, KNOWN_CHAIN_IDS},
+    http::{fetch_http_preconf, parse_http_preconf, DEFAULT_MAX_PAYLOAD_BYTES},
+    registry,
+    reload::RuntimeConfig,
+    signer::recover_signer,
+};
+use alloy::primitives::Address;
+use std::{
+    sync::{Arc, Mutex},
+    time::Duration,
+};
+use tokio::{sync::watch, time::interval};
+use tracing::{debug, error, info};
+
+pub const DEFAULT_CHAIN_CHECK_SECS: u64 = 600;
+
+/// Ergebnis des Abgleichs (mit der Quelle der Aussage: "eth_chainId", "response" oder "signature")
+#[derive(Debug, Clone, PartialEq)]
+pub enum EndpointChain {
+    Match(&'static str),
+    Mismatch(u64, &'static str),
+    Unknown,
+}
+
+/// Chain-ID aus einer JSON-Zahl oder einem (hex-)String
+fn chain_id_value(value: &serde_json::Value) -> Option<u64> {
+    match value {
+        serde_json::Value::Number(number) => number.as_u64(),
+        serde_json::Value::String(text) => match text.strip_prefix("0x") {
+            Some(hex) => u64::from_str_radix(hex, 16).ok(),
+            None => text.parse().ok(),
+        },
+        _ => None,
+    }
+}
+
+/// eth_chainId, falls die Quelle JSON-RPC spricht (sonst None)
+async fn rpc_chain_id(client: &reqwest::Client, endpoint: &str) -> Option<u64> {
+    let request = serde_json::json!({"jsonrpc": "2.0", "id": 1, "method": "eth_chainId", "params": []});
+    let response = client.post(endpoint).json(&request).timeout(Duration::from_secs(5)).send().await.ok()?;
+    if !response.status().is_success() {
+        return None;
+    }
+    let body: serde_json::Value = response.json().await.ok()?;
+    chain_id_value(&body["result"])
+}
+
+/// Chain-ID, zu deren Sequencer die Signatur passt (ohne die eigene Chain)
+fn chain_by_signature(data: &[u8], signature: &[u8; 65], own_chain_id: u64) -> Option<u64> {
+    let mut candidates: Vec<u64> = KNOWN_CHAIN_IDS.into_iter().chain(registry::chain_ids()).collect();
+    candidates.sort_unstable();
+    candidates.dedup();
+    candidates.into_iter().filter(|chain_id| *chain_id != own_chain_id).find(|chain_id| {
+        let expected = ChainConfig::for_chain(*chain_id).unsafe_signer;
+        expected != Address::ZERO && recover_signer(data, signature, *chain_id).is_ok_and(|signer| signer == expected)
+    })
+}
+
+fn compare(remote: u64, chain_id: u64, by: &'static str) -> EndpointChain {
+    if remote == chain_id {
+        EndpointChain::Match(by)
+    } else {
+        EndpointChain::Mismatch(remote, by)
+    }
+}
+
+/// Bestimmt die Chain der HTTP-Quelle und vergleicht sie mit `chain_id`
+pub async fn check_endpoint(
+    client: &reqwest::Client,
+    endpoint: &str,
+    chain_id: u64,
+    signers: &[Address],
+) -> Result<EndpointChain, Box<dyn std::error::Error + Send + Sync>> {
+    if let Some(remote) = rpc_chain_id(client, endpoint).await {
+        return Ok(compare(remote, chain_id, "eth_chainId"));
+    }
+
+    let Some((_, preconf)) = fetch_http_preconf(client, endpoint, DEFAULT_MAX_PAYLOAD_BYTES).await? else {
+        return Ok(EndpointChain::Unknown);
+    };
+    if let Some(remote) = ["chain_id", "chainId"].iter().find_map(|key| chain_id_value(&preconf[*key])) {
+        return Ok(compare(remote, chain_id, "response"));
+    }
+
+    let (data, signature) = parse_http_preconf(&preconf)?;
+    if recover_signer(&data, &signature, chain_id).is_ok_and(|signer| signers.contains(&signer)) {
+        return Ok(EndpointChain::Match("signature"));
+    }
+    Ok(match chain_by_signature(&data, &signature, chain_id) {
+        Some(remote) => EndpointChain::Mismatch(remote, "signature"),
+        None => EndpointChain::Unknown,
+    })
+}
+
+/// Fehlermeldung bei Abweichung, sonst Log des Ergebnisses
+pub async fn verify_endpoint(
+    client: &reqwest::Client,
+    endpoint: &str,
+    chain_id: u64,
+    signers: &[Address],
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    match check_endpoint(client, endpoint, chain_id, signers).await {
+        Ok(EndpointChain::Match(by)) => {
+            info!("🆔 HTTP source {} serves chain {} ({})", endpoint, chain_id, by);
+            Ok(())
+        }
+        Ok(EndpointChain::Mismatch(remote, by)) => Err(format!(
+            "HTTP source {} serves chain {} ({}), not the configured chain {} - check chain id and http_endpoint",
+            endpoint, remote, by, chain_id
+        )
+        .into()),
+        Ok(EndpointChain::Unknown) => {
+            info!("🆔 Cannot determine the chain of HTTP source {}, relying on signature checks", endpoint);
+            Ok(())
+        }
+        Err(e) => {
+            debug!("Chain check of {} inconclusive: {}", endpoint, e);
+            Ok(())
+        }
+    }
+}
+
+/// Wiederholt den Abgleich periodisch und nach einem Endpoint-Wechsel; stoppt die Chain bei Abweichung
+pub async fn run_chain_check(
+    mut runtime: watch::Receiver<RuntimeConfig>,
+    chain_id: u64,
+    chain_config: ChainConfig,
+    interval_secs: u64,
+    running: Arc<Mutex<bool>>,
+) {
+    let client = reqwest::Client::new();
+    let mut ticker = (interval_secs > 0).then(|| interval(Duration::from_secs(interval_secs)));
+    if let Some(ref mut ticker) = ticker {
+        ticker.tick().await; // beim Start schon geprüft
+    }
+    let mut endpoint = runtime.borrow_and_update().http_endpoint.clone();
+
+    loop {
+        tokio::select! {
+            _ = async {
+                match ticker.as_mut() {
+                    Some(ticker) => ticker.tick().await,
+                    None => std::future::pending().await,
+                }
+            } => {}
+            changed = runtime.changed() => {
+                if changed.is_err() {
+                    break;
+                }
+                let current = runtime.borrow_and_update().http_endpoint.clone();
+                if current == endpoint {
+                    continue;
+                }
+                endpoint = current;
+            }
+        }
+        if !*running.lock().unwrap() {
+            break;
+        }
+        let Some(ref url) = endpoint else {
+            continue;
+        };
+        let mut signers: Vec<Address> = runtime.borrow().signers.iter().map(|entry| entry.address).collect();
+        signers.push(chain_config.current_signer());
+        if let Err(e) = verify_endpoint(&client, url, chain_id, &signers).await {
+            error!("❌ {} - stopping chain {}", e, chain_id);
+            *running.lock().unwrap() = false;
+            break;
+        }
+    }
+}
@@ -0,0 +1,171 @@
+// checkpoint.rs - Wiederaufsetzpunkt nach Absturz oder Neustart (checkpoint.json im Output-Verzeichnis)
+//
+// Festgehalten werden je Quelle der zuletzt gespeicherte Block (Nummer, Hash, Empfang), der Cursor des HTTP-Polls
+// (Endpoint, Hash der letzten Antwort, Blocknummer) und das Fenster der zuletzt über Gossip verarbeiteten Blöcke.
+// Geschrieben wird mit dem Block-Index (atomar, nur nach Änderungen). Beim Start setzt der HTTP-Poll am Cursor wieder
+// an (dieselbe Antwort wird nicht erneut verarbeitet, eine Lücke seit dem Absturz fällt beim ersten Poll auf), der
+// Gossip-Pfad übernimmt das Fenster für Deduplizierung und Lückenerkennung, und mit KONA_BRIDGE_GAP_BACKFILL lädt
+// continuity.rs die Blöcke zwischen Checkpoint und Head der Backfill-Quelle sofort nach, statt auf die nächste
+// Live-Nachricht zu warten.
+
+use crate::durability::DurableFs;
+use serde::{Deserialize, Serialize};
+use std::{
+    collections::{BTreeMap, VecDeque},
+    fs,
+    path::Path,
+    time::{SystemTime, UNIX_EPOCH},
+};
+use tracing::{info, warn};
+
+pub const CHECKPOINT_FILE_NAME: &str = "checkpoint.json";
+const CHECKPOINT_VERSION: u32 = 1;
+
+/// Zuletzt gespeicherter Block einer Quelle
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SourcePosition {
+    pub block_number: u64,
+    pub block_hash: String,
+    pub received_unix: u64,
+}
+
+/// Stand des HTTP-Polls
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct PollCursor {
+    pub endpoint: String,
+    pub data_hash: String, // Hash der zuletzt verarbeiteten Antwort (siehe fetch_http_preconf)
+    pub block_number: u64,
+}
+
+/// Serialisierte Form von checkpoint.json
+#[derive(Serialize, Deserialize)]
+struct CheckpointFile {
+    version: u32,
+    chain_id: u64,
+    written_unix: u64,
+    sources: BTreeMap<String, SourcePosition>,
+    poll: Option<PollCursor>,
+    gossip_window: Vec<u64>,
+}
+
+#[derive(Default)]
+pub struct Checkpoint {
+    sources: BTreeMap<String, SourcePosition>,
+    poll: Option<PollCursor>,
+    gossip_window: VecDeque<u64>,
+    window_size: usize,
+    dirty: bool,
+}
+
+impl Checkpoint {
+    /// Lädt checkpoint.json (fehlt die Datei oder ist sie unbrauchbar, beginnt die Bridge ohne Wiederaufsetzpunkt);
+    /// `window_size`: Blöcke im Gossip-Fenster (wie der Deduplikator)
+    pub fn load(output_dir: &Path, chain_id: u64, window_size: usize) -> Self {
+        let mut checkpoint = Self { window_size, ..Self::default() };
+        let path = output_dir.join(CHECKPOINT_FILE_NAME);
+        match fs::read(&path) {
+            Ok(data) => match serde_json::from_slice::<CheckpointFile>(&data) {
+                Ok(file) if file.version == CHECKPOINT_VERSION && file.chain_id == chain_id => {
+                    checkpoint.sources = file.sources;
+                    checkpoint.poll = file.poll;
+                    let skip = file.gossip_window.len().saturating_sub(window_size);
+                    checkpoint.gossip_window = file.gossip_window.into_iter().skip(skip).collect();
+                    checkpoint.log_resume(file.written_unix);
+                }
+                Ok(file) => warn!("⚠️  Ignoring {:?} (version {}, chain {})", path, file.version, file.chain_id),
+                Err(e) => warn!("⚠️  Corrupt {:?} ({}), starting without resume point", path, e),
+            },
+            Err(e) if e.kind() == std::io::ErrorKind::NotFound => {}
+            Err(e) => warn!("⚠️  Cannot read {:?} ({}), starting without resume point", path, e),
+        }
+        checkpoint
+    }
+
+    fn log_resume(&self, written_unix: u64) {
+        let sources: Vec<String> = self
+            .sources
+            .iter()
+            .map(|(source, position)| format!("{} #{}", source, position.block_number))
+            .collect();
+        info!(
+            "📍 Resuming from checkpoint written {}s ago: {}{}",
+            unix_now().saturating_sub(written_unix),
+            if sources.is_empty() { "no blocks".to_string() } else { sources.join(", ") },
+            self.poll.as_ref().map(|poll| format!(", poll cursor #{}", poll.block_number)).unwrap_or_default()
+        );
+    }
+
+    /// Vermerkt einen gespeicherten Block (je Quelle zählt der höchste)
+    pub fn record_stored(&mut self, source: &str, block_number: u64, block_hash: &[u8; 32], received_unix: u64) {
+        if self.sources.get(source).is_some_and(|position| position.block_number > block_number) {
+            return;
+        }
+        let position = SourcePosition {
+            block_number,
+            block_hash: format!("0x{}", hex::encode(block_hash)),
+            received_unix,
+        };
+        self.sources.insert(source.to_string(), position);
+        self.dirty = true;
+    }
+
+    pub fn record_poll(&mut self, cursor: PollCursor) {
+        self.poll = Some(cursor);
+        self.dirty = true;
+    }
+
+    /// Vermerkt einen über Gossip verarbeiteten Block im Fenster (älteste fallen heraus)
+    pub fn record_gossip(&mut self, block_number: u64) {
+        if self.gossip_window.contains(&block_number) {
+            return;
+        }
+        if self.gossip_window.len() >= self.window_size.max(1) {
+            self.gossip_window.pop_front();
+        }
+        self.gossip_window.push_back(block_number);
+        self.dirty = true;
+    }
+
+    /// Höchster gespeicherter Block über alle Quellen
+    pub fn highest(&self) -> Option<u64> {
+        self.sources.values().map(|position| position.block_number).max()
+    }
+
+    pub fn poll(&self) -> Option<PollCursor> {
+        self.poll.clone()
+    }
+
+    pub fn gossip_window(&self) -> Vec<u64> {
+        self.gossip_window.iter().copied().collect()
+    }
+
+    /// Schreibt checkpoint.json atomar, falls sich seit dem letzten Aufruf etwas geändert hat (true = geschrieben)
+    pub fn save_if_dirty(
+        &mut self,
+        output_dir: &Path,
+        chain_id: u64,
+        durable: &DurableFs,
+    ) -> Result<bool, Box<dyn std::error::Error + Send + Sync>> {
+        if !self.dirty {
+            return Ok(false);
+        }
+        let file = CheckpointFile {
+            version: CHECKPOINT_VERSION,
+            chain_id,
+            written_unix: unix_now(),
+            sources: self.sources.clone(),
+            poll: self.poll.clone(),
+            gossip_window: self.gossip_window(),
+        };
+        let path = output_dir.join(CHECKPOINT_FILE_NAME);
+        durable
+            .write_atomic_blocking(&path.with_extension("json.tmp"), &path, &serde_json::to_vec_pretty(&file)?)
+            .map_err(|e| format!("Failed to write {:?}: {}", path, e))?;
+        self.dirty = false;
+        Ok(true)
+    }
+}
+
+fn unix_now() -> u64 {
+    SystemTime::now().duration_since(UNIX_EPOCH).map(|d| d.as_secs()).unwrap_or(0)
+}
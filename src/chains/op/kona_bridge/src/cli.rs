@@ -0,0 +1,1016 @@
+// cli.rs - Unterkommandos des Standalone-Binaries (src/main.rs)
+//
+//   capture   Preconfs empfangen und speichern, dieselbe Pipeline wie kona_bridge_start (Default ohne Kommando),
+//             für eine oder mehrere Chains im selben Prozess
+//   serve     HTTP-API/gRPC über ein vorhandenes Output-Verzeichnis, ohne selbst zu empfangen
+//   decode    eine .raw Datei dekodieren und prüfen (wie `opg_bridge decode`)
+//   verify    alle gespeicherten Blöcke einer Chain dekodieren und prüfen
+//   export    Blockbereich als tar.zst-Archiv exportieren (siehe archive.rs)
+//   import    Archiv in das Output-Verzeichnis importieren
+//   replay    gespeicherte Preconfs als NDJSON auf stdout, optional im Tempo der Payload-Timestamps
+//   stats     Belegung, Lücken und Heartbeat (status.json) eines Output-Verzeichnisses
+//   doctor    Konfiguration, Verzeichnis, Speicherplatz, Sperre, Heartbeat, Signer, HTTP-Quelle (Format, Chain,
+//             Signatur, Uhrzeit), Ports und Bootnodes prüfen
+// Alle Kommandos lesen dieselben KONA_BRIDGE_* Variablen bzw. dieselbe Konfigurationsdatei wie die eingebettete
+// Bridge, auch KONA_BRIDGE_CHAIN_SUBDIRS für das Verzeichnis der Chain. Die Offline-Kommandos lesen außerdem aus
+// {output_dir}/{chain_id}/, wenn es existiert (so legt `capture` mehrere --chain-id an).
+
+use crate::{
+    access, archive,
+    chaincheck::{self, EndpointChain},
+    configfile,
+    config::{chain_id_for_network, network_name, ChainConfig},
+    decode::{decode_file, format_summary},
+    dirlock::DirLock,
+    handoff,
+    http::parse_http_preconf,
+    logging, perms,
+    publish::preconf_message,
+    registry, rollup, run_http_first_network, serve,
+    settings::{env_string, BridgeSettings},
+    signer::{self, fetch_unsafe_signer, load_signers_file, recover_signer, SignerEntry},
+    sink::StoredPreconf,
+    stats::StorageStats,
+    status::{DEFAULT_STATUS_INTERVAL_SECS, STATUS_FILE_NAME},
+    storage::PreconfStore,
+    systemd,
+    types::KonaBridgeStats,
+    utils::{
+        block_number_from_filename, extract_block_number_from_preconf_data, extract_timestamp_from_preconf_data,
+        free_bytes,
+    },
+};
+use alloy::primitives::Address;
+use discv5::Enr;
+use std::{
+    fs,
+    io::{self, Write},
+    net::{SocketAddr, TcpListener, UdpSocket},
+    path::{Path, PathBuf},
+    sync::{Arc, Mutex},
+    time::{Duration, Instant, SystemTime, UNIX_EPOCH},
+};
+use tokio::{
+    signal::unix::{signal, SignalKind},
+    task::{JoinSet, LocalSet},
+};
+use tracing::{error, info, warn, Instrument};
+
+/// Chain von `capture` ohne --chain-id und ohne Abschnitte in der Konfigurationsdatei (Base)
+const DEFAULT_CHAIN_ID: u64 = 8453;
+/// Wartezeit auf das Ende der Tasks nach SIGINT/SIGTERM
+const SHUTDOWN_TIMEOUT: Duration = Duration::from_secs(15);
+/// Freier Platz im Output-Verzeichnis, unter dem `doctor` warnt
+const DOCTOR_MIN_FREE_BYTES: u64 = 1 << 30;
+const DOCTOR_HTTP_TIMEOUT: Duration = Duration::from_secs(5);
+const DOCTOR_DIAL_TIMEOUT: Duration = Duration::from_secs(3);
+
+/// Flags von `capture`, entsprechen den Feldern von KonaBridgeConfig
+#[derive(Debug, Clone)]
+pub struct CaptureOptions {
+    pub chain_ids: Vec<u64>,     // leer = alle [chains.<id>] der Konfigurationsdatei, sonst Base
+    pub network: Option<String>, // Netzwerk-Name, bestimmt wie früher die Chain ID (siehe config.rs)
+    pub output_dir: PathBuf,
+    pub disc_port: u16,
+    pub gossip_port: u16,
+    pub ttl_minutes: u64,
+    pub cleanup_interval: u64,
+    pub http_poll_interval: u64,
+    pub http_failure_threshold: u32,
+    pub sequencer_address: Option<String>,
+    pub chain_name: Option<String>,
+    pub rollup_config: Option<PathBuf>, // op-node rollup.json, bestimmt ohne --chain-id die Chain (siehe rollup.rs)
+}
+
+/// Flags von `replay`
+#[derive(Debug, Clone)]
+pub struct ReplayOptions {
+    pub chain_id: u64,
+    pub output_dir: PathBuf,
+    pub from: Option<u64>,
+    pub to: Option<u64>,
+    pub speed: f64,    // 0 = so schnell wie möglich, 1 = Tempo der Payload-Timestamps
+    pub payload: bool, // Container zusätzlich als Base64 in "payload"
+}
+
+fn unix_now() -> u64 {
+    SystemTime::now().duration_since(UNIX_EPOCH).map(|d| d.as_secs()).unwrap_or(0)
+}
+
+/// Ob `capture` die Chain unter {output_dir}/{chain_id}/ ablegt: mit KONA_BRIDGE_CHAIN_SUBDIRS, bei mehreren
+/// --chain-id im selben Prozess (`multi`) oder wenn die Konfigurationsdatei mehrere Chains enthält
+fn uses_chain_subdir(multi: bool) -> bool {
+    multi || BridgeSettings::chain_subdirs_from_env() || configfile::chain_ids().len() > 1
+}
+
+/// Verzeichnis der Chain für die Offline-Kommandos, Layout wie bei `capture` (siehe uses_chain_subdir). Ob mehrere
+/// --chain-id erfasst wurden, ist nur am vorhandenen {output_dir}/{chain_id}/ erkennbar.
+fn chain_dir(output_dir: &Path, chain_id: u64) -> PathBuf {
+    let subdir = output_dir.join(chain_id.to_string());
+    if uses_chain_subdir(false) || subdir.is_dir() {
+        subdir
+    } else {
+        output_dir.to_path_buf()
+    }
+}
+
+/// Gespeicherte Blöcke der Chain im Bereich, aufsteigend nach Blocknummer
+fn stored_blocks(
+    dir: &Path,
+    chain_id: u64,
+    from: Option<u64>,
+    to: Option<u64>,
+) -> Result<Vec<(u64, PathBuf)>, Box<dyn std::error::Error + Send + Sync>> {
+    let mut blocks = Vec::new();
+    for entry in fs::read_dir(dir).map_err(|e| format!("Cannot read {:?}: {}", dir, e))? {
+        let path = entry?.path();
+        if path.extension().map_or(true, |ext| ext != "raw") {
+            continue;
+        }
+        let Some(number) = block_number_from_filename(&path, chain_id) else {
+            continue;
+        };
+        if from.map_or(true, |from| number >= from) && to.map_or(true, |to| number <= to) {
+            blocks.push((number, path));
+        }
+    }
+    blocks.sort_unstable_by_key(|(number, _)| *number);
+    Ok(blocks)
+}
+
+/// Fehlende Blocknummern zwischen den gespeicherten Blöcken als (von, bis)
+fn gaps(blocks: &[(u64, PathBuf)]) -> Vec<(u64, u64)> {
+    blocks
+        .windows(2)
+        .filter(|pair| pair[1].0 > pair[0].0 + 1)
+        .map(|pair| (pair[0].0 + 1, pair[1].0 - 1))
+        .collect()
+}
+
+/// Bis SIGINT oder SIGTERM
+async fn shutdown_signal() {
+    let mut terminate = signal(SignalKind::terminate())
+        .map_err(|e| warn!("⚠️  SIGTERM handler not installed: {}", e))
+        .ok();
+    tokio::select! {
+        _ = tokio::signal::ctrl_c() => {}
+        _ = async {
+            match terminate.as_mut() {
+                Some(terminate) => {
+                    terminate.recv().await;
+                }
+                None => std::future::pending().await,
+            }
+        } => {}
+    }
+}
+
+/// Bis SIGUSR2 (ohne Signal-Handler nie)
+async fn upgrade_signal(signal: &mut Option<tokio::signal::unix::Signal>) {
+    match signal {
+        Some(signal) => {
+            signal.recv().await;
+        }
+        None => std::future::pending().await,
+    }
+}
+
+/// Chains, die `capture` startet: --network, --chain-id, alle Abschnitte der Konfigurationsdatei oder Base
+fn capture_chains(options: &CaptureOptions) -> Result<Vec<u64>, Box<dyn std::error::Error + Send + Sync>> {
+    if let Some(ref network) = options.network {
+        let chain_id =
+            chain_id_for_network(network).ok_or_else(|| format!("Unknown network {:?}, use --chain-id", network))?;
+        return Ok(vec![chain_id]);
+    }
+    let rollup_config =
+        options.rollup_config.clone().or_else(|| env_string("KONA_BRIDGE_ROLLUP_CONFIG").map(PathBuf::from));
+    if let Some(path) = rollup_config {
+        let chain_id = rollup::load(&path)?.l2_chain_id;
+        if options.chain_ids.iter().any(|id| *id != chain_id) {
+            let hint = "use rollup_config in [chains.<id>] for several chains";
+            return Err(format!("Rollup config {:?} is for chain {} only, {}", path, chain_id, hint).into());
+        }
+        return Ok(vec![chain_id]);
+    }
+    let mut chain_ids = if options.chain_ids.is_empty() { configfile::chain_ids() } else { options.chain_ids.clone() };
+    if chain_ids.is_empty() {
+        chain_ids.push(DEFAULT_CHAIN_ID);
+    }
+    chain_ids.sort_unstable();
+    chain_ids.dedup();
+    Ok(chain_ids)
+}
+
+/// Empfängt und speichert Preconfs bis SIGINT/SIGTERM (HTTP-first mit Gossip-Fallback wie im C-Server).
+/// Mehrere Chains laufen nebeneinander im selben Prozess, jede mit eigenem Verzeichnis und eigenen Ports;
+/// HTTP-API, gRPC, Admin- und Debug-Listener gibt es nur einmal für alle Chains.
+pub async fn capture(options: CaptureOptions) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let chain_ids = capture_chains(&options)?;
+    let multi = chain_ids.len() > 1;
+    if multi && options.sequencer_address.is_some() {
+        return Err("--sequencer-address only applies to a single chain, configure signers per chain instead".into());
+    }
+    perms::create_dir_all(&options.output_dir)
+        .map_err(|e| format!("Cannot create output directory {:?}: {}", options.output_dir, e))?;
+    // Nach einem Upgrade per SIGUSR2 gibt der Vorgänger jetzt Listener und Verzeichnisse frei (siehe handoff.rs)
+    handoff::ready();
+
+    // Die Capture-Futures sind nicht Send, sie laufen auf diesem Thread; ihre Tasks verteilt die Runtime
+    let local = LocalSet::new();
+    let mut flags = Vec::new();
+    let mut notify_chains = Vec::new();
+    let mut tasks = JoinSet::new();
+    for (slot, chain_id) in chain_ids.iter().copied().enumerate() {
+        let name = options
+            .chain_name
+            .clone()
+            .filter(|_| !multi)
+            .or_else(|| network_name(chain_id).map(str::to_string))
+            .or_else(|| registry::lookup(chain_id).map(|chain| chain.name))
+            .unwrap_or_else(|| format!("chain {}", chain_id));
+        // Mehrere Chains nie in dasselbe flache Verzeichnis (latest.raw und Index wären geteilt), Layout wie chain_dir;
+        // mit KONA_BRIDGE_CHAIN_SUBDIRS legt run_http_first_network das Unterverzeichnis selbst an
+        let output_dir = if uses_chain_subdir(multi) && !BridgeSettings::chain_subdirs_from_env() {
+            options.output_dir.join(chain_id.to_string())
+        } else {
+            options.output_dir.clone()
+        };
+        // Ohne disc_port/gossip_port in der Konfiguration bekommt jede weitere Chain das nächste Port-Paar
+        let offset = u16::try_from(2 * slot).map_err(|_| "too many chains")?;
+        let (disc_port, gossip_port) = match (
+            options.disc_port.checked_add(offset),
+            options.gossip_port.checked_add(offset),
+        ) {
+            (Some(disc_port), Some(gossip_port)) => (disc_port, gossip_port),
+            _ => return Err(format!("No free port pair for chain {}", chain_id).into()),
+        };
+        info!("🚀 Starting Kona-P2P Bridge for {} ({})", name, chain_id);
+        info!("📁 Output: {:?}", output_dir);
+
+        let running = Arc::new(Mutex::new(true));
+        flags.push(running.clone());
+        notify_chains.push((chain_id, running.clone()));
+        let options = options.clone();
+        let span = logging::chain_span(chain_id, multi);
+        tasks.spawn_local_on(
+            async move {
+                let result = run_http_first_network(
+                    chain_id,
+                    disc_port,
+                    gossip_port,
+                    &output_dir,
+                    options.ttl_minutes,
+                    options.cleanup_interval,
+                    options.http_poll_interval,
+                    options.http_failure_threshold,
+                    options.sequencer_address.as_deref(),
+                    Arc::new(Mutex::new(KonaBridgeStats::default())),
+                    Arc::new(StorageStats::default()),
+                    running,
+                )
+                .await;
+                (chain_id, result.map_err(|e| e.to_string()))
+            }
+            .instrument(span),
+            &local,
+        );
+    }
+
+    // Type=notify und WatchdogSec= unter systemd (siehe systemd.rs)
+    tokio::spawn(systemd::run_notify(notify_chains));
+
+    let errors = local.run_until(wait_for_chains(tasks, flags, multi)).await;
+    if !errors.is_empty() {
+        return Err(errors.join("; ").into());
+    }
+    info!("✅ Kona-P2P Bridge stopped");
+    Ok(())
+}
+
+/// Wartet auf das Ende aller Chains und stoppt sie auf SIGINT/SIGTERM; Ergebnis: Fehler der Chains.
+/// Chains enden auch von selbst, z.B. wenn ihr Verzeichnis gesperrt ist, die übrigen laufen dann weiter.
+/// Auf SIGUSR2 übernimmt ein neu gestarteter Prozess (siehe handoff.rs), danach enden die Chains wie bei SIGTERM.
+async fn wait_for_chains(
+    mut tasks: JoinSet<(u64, Result<(), String>)>,
+    flags: Vec<Arc<Mutex<bool>>>,
+    multi: bool,
+) -> Vec<String> {
+    let shutdown = shutdown_signal();
+    tokio::pin!(shutdown);
+    let mut upgrade = signal(SignalKind::user_defined2())
+        .map_err(|e| warn!("⚠️  SIGUSR2 handler not installed, upgrades restart from scratch: {}", e))
+        .ok();
+    let mut deadline: Option<tokio::time::Instant> = None;
+    let mut errors = Vec::new();
+    loop {
+        let timeout = async {
+            match deadline {
+                Some(deadline) => tokio::time::sleep_until(deadline).await,
+                None => std::future::pending().await,
+            }
+        };
+        tokio::select! {
+            joined = tasks.join_next() => match joined {
+                Some(Ok((chain_id, Ok(())))) => info!("✅ Chain {} stopped", chain_id),
+                Some(Ok((chain_id, Err(e)))) => {
+                    error!("❌ Chain {} failed: {}", chain_id, e);
+                    errors.push(if multi { format!("chain {}: {}", chain_id, e) } else { e });
+                }
+                Some(Err(e)) => errors.push(format!("capture task failed: {}", e)),
+                None => break,
+            },
+            _ = &mut shutdown, if deadline.is_none() => {
+                info!("🛑 Stopping Kona-P2P Bridge");
+                systemd::notify("STOPPING=1");
+                for running in &flags {
+                    *running.lock().unwrap() = false;
+                }
+                deadline = Some(tokio::time::Instant::now() + SHUTDOWN_TIMEOUT);
+            }
+            _ = upgrade_signal(&mut upgrade), if deadline.is_none() => {
+                info!("🔁 Upgrade requested, starting successor");
+                match handoff::spawn_successor().await {
+                    Ok(pid) => {
+                        info!("🔁 Handed over to PID {}, stopping", pid);
+                        for running in &flags {
+                            *running.lock().unwrap() = false;
+                        }
+                        deadline = Some(tokio::time::Instant::now() + SHUTDOWN_TIMEOUT);
+                    }
+                    Err(e) => error!("❌ Upgrade failed, keeping this process: {}", e),
+                }
+            }
+            _ = timeout => {
+                let pending = tasks.len();
+                warn!("⚠️  {} chain(s) did not stop within {}s, exiting anyway", pending, SHUTDOWN_TIMEOUT.as_secs());
+                break;
+            }
+        }
+    }
+    errors
+}
+
+/// HTTP-API und gRPC über ein vorhandenes Output-Verzeichnis (z.B. nach `import`) bis SIGINT/SIGTERM
+pub async fn serve(
+    chain_id: u64,
+    output_dir: &Path,
+    listen: Option<SocketAddr>,
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let mut settings = BridgeSettings::from_env(chain_id);
+    if listen.is_some() {
+        settings.serve_addr = listen;
+    }
+    if settings.serve_addr.is_none() && settings.grpc_addr.is_none() {
+        return Err("Nothing to serve, use --listen or set KONA_BRIDGE_SERVE_ADDR / KONA_BRIDGE_GRPC_ADDR".into());
+    }
+
+    let dir = chain_dir(output_dir, chain_id);
+    if !dir.is_dir() {
+        return Err(format!("Output directory {:?} does not exist", dir).into());
+    }
+    // Der Store gleicht Journal und Index mit dem Verzeichnis ab, das darf keine zweite Bridge gleichzeitig tun
+    let wait = Duration::from_secs(settings.lock_wait_secs);
+    let _dir_lock = DirLock::acquire(&dir, chain_id, settings.lock_takeover, wait).await?;
+
+    let running = Arc::new(Mutex::new(true));
+    let storage_stats = Arc::new(StorageStats::default());
+    storage_stats.set_output_dir(dir.clone(), chain_id);
+    let store = Arc::new(PreconfStore::new(dir.clone(), chain_id, &settings, storage_stats, running.clone()));
+    match store.latest_entry() {
+        Some((number, _)) => info!("📚 Serving chain {} from {:?}, newest block #{}", chain_id, dir, number),
+        None => warn!("⚠️  Serving chain {} from {:?}, no blocks stored yet", chain_id, dir),
+    }
+
+    let tx_store = store.clone();
+    tokio::spawn(async move { tx_store.rebuild_tx_index().await });
+    serve::register(
+        settings.serve_addr,
+        settings.grpc_addr,
+        settings.admin_addr,
+        access::AccessControl::new(&settings.access),
+        settings.tls.clone(),
+        store,
+        running.clone(),
+    );
+
+    shutdown_signal().await;
+    info!("🛑 Stopping serving API");
+    *running.lock().unwrap() = false;
+    Ok(())
+}
+
+/// Dekodiert und prüft eine .raw Datei, Err wenn eine Prüfung fehlschlägt
+pub fn decode(
+    file: &Path,
+    chain_id: Option<u64>,
+    signer: Option<Address>,
+    json: bool,
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let report = decode_file(file, chain_id, signer).map_err(|e| format!("{}: {}", file.display(), e))?;
+    if json {
+        println!("{}", serde_json::to_string_pretty(&report)?);
+    } else {
+        print!("{}", format_summary(&report));
+    }
+    let errors = report["errors"].as_array().map_or(0, |errors| errors.len());
+    if errors > 0 {
+        return Err(format!("{}: {} check(s) failed", file.display(), errors).into());
+    }
+    Ok(())
+}
+
+/// Prüft alle gespeicherten Blöcke der Chain (Container, SSZ, Block-Hash, Withdrawals, Signatur)
+pub fn verify(
+    chain_id: u64,
+    output_dir: &Path,
+    from: Option<u64>,
+    to: Option<u64>,
+    signer: Option<Address>,
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let dir = chain_dir(output_dir, chain_id);
+    let blocks = stored_blocks(&dir, chain_id, from, to)?;
+    let (Some((first, _)), Some((last, _))) = (blocks.first(), blocks.last()) else {
+        return Err(format!("No blocks of chain {} in {:?}", chain_id, dir).into());
+    };
+
+    let mut failed = 0;
+    for (number, path) in &blocks {
+        let errors = match decode_file(path, Some(chain_id), signer) {
+            Ok(report) => report["errors"]
+                .as_array()
+                .map(|errors| errors.iter().filter_map(|e| e.as_str()).map(str::to_string).collect())
+                .unwrap_or_default(),
+            Err(e) => vec![e.to_string()],
+        };
+        if !errors.is_empty() {
+            failed += 1;
+            println!("❌ #{} {}: {}", number, path.display(), errors.join("; "));
+        }
+    }
+
+    let missing: u64 = gaps(&blocks).iter().map(|(from, to)| to - from + 1).sum();
+    println!(
+        "🔎 Chain {}: {} of {} block(s) valid (#{}..=#{}, {} missing)",
+        chain_id,
+        blocks.len() - failed,
+        blocks.len(),
+        first,
+        last,
+        missing
+    );
+    if failed > 0 {
+        return Err(format!("{} of {} block(s) failed verification", failed, blocks.len()).into());
+    }
+    Ok(())
+}
+
+/// Exportiert [from, to] als tar.zst (ohne Bereich: alle gespeicherten Blöcke)
+pub fn export(
+    chain_id: u64,
+    output_dir: &Path,
+    from: Option<u64>,
+    to: Option<u64>,
+    dest: &Path,
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let dir = chain_dir(output_dir, chain_id);
+    let (from, to) = match (from, to) {
+        (Some(from), Some(to)) => (from, to),
+        _ => {
+            let blocks = stored_blocks(&dir, chain_id, from, to)?;
+            match (blocks.first(), blocks.last()) {
+                (Some((first, _)), Some((last, _))) => (from.unwrap_or(*first), to.unwrap_or(*last)),
+                _ => return Err(format!("No blocks of chain {} in {:?}", chain_id, dir).into()),
+            }
+        }
+    };
+    let manifest = archive::export_archive(&dir, chain_id, from, to, dest)?;
+    println!("📦 Exported {} block(s) #{}..=#{} to {}", manifest.entries.len(), from, to, dest.display());
+    Ok(())
+}
+
+/// Importiert ein Archiv in das Verzeichnis seiner Chain (chain_id None = jede Chain, sonst muss sie zum Manifest
+/// passen)
+pub fn import(
+    chain_id: Option<u64>,
+    output_dir: &Path,
+    src: &Path,
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let chain_id = archive::read_manifest(src, chain_id.unwrap_or(0))?.chain_id;
+    let dir = chain_dir(output_dir, chain_id);
+    let summary = archive::import_archive(&dir, src, chain_id)?;
+    println!(
+        "📥 Imported {} block(s) into {:?}, {} already present",
+        summary.imported, dir, summary.skipped
+    );
+    Ok(())
+}
+
+/// Gespeicherter Block als StoredPreconf (Metadaten aus block_*.json)
+fn load_preconf(
+    chain_id: u64,
+    block_number: u64,
+    path: &Path,
+) -> Result<StoredPreconf, Box<dyn std::error::Error + Send + Sync>> {
+    let metadata: serde_json::Value = serde_json::from_slice(&fs::read(path.with_extension("json"))?)?;
+    let block_hash = metadata["block_hash"]
+        .as_str()
+        .and_then(|hash| hex::decode(hash.trim_start_matches("0x")).ok())
+        .and_then(|hash| <[u8; 32]>::try_from(hash).ok())
+        .ok_or("metadata without block_hash")?;
+    Ok(StoredPreconf {
+        chain_id,
+        block_number,
+        block_hash,
+        received_unix: metadata["received_unix"].as_u64().unwrap_or(0),
+        timestamp: metadata["timestamp"].as_u64().unwrap_or(0),
+        raw_filename: path.file_name().and_then(|name| name.to_str()).unwrap_or_default().to_string(),
+        raw_data: fs::read(path)?,
+        metadata,
+    })
+}
+
+/// Gibt gespeicherte Preconfs im Format des stdout-Sinks aus (eine JSON-Zeile pro Block)
+pub fn replay(options: ReplayOptions) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let dir = chain_dir(&options.output_dir, options.chain_id);
+    let blocks = stored_blocks(&dir, options.chain_id, options.from, options.to)?;
+
+    let mut stdout = io::stdout().lock();
+    let (mut replayed, mut skipped) = (0u64, 0u64);
+    let mut previous: Option<u64> = None;
+    for (number, path) in &blocks {
+        let preconf = match load_preconf(options.chain_id, *number, path) {
+            Ok(preconf) => preconf,
+            Err(e) => {
+                eprintln!("⚠️  Skipping block #{}: {}", number, e);
+                skipped += 1;
+                continue;
+            }
+        };
+        if options.speed > 0.0 && preconf.timestamp > 0 {
+            if let Some(previous) = previous.filter(|previous| preconf.timestamp > *previous) {
+                std::thread::sleep(Duration::from_secs_f64((preconf.timestamp - previous) as f64 / options.speed));
+            }
+            previous = Some(preconf.timestamp);
+        }
+
+        let mut line = serde_json::to_vec(&preconf_message(&preconf, options.payload))?;
+        line.push(b'\n');
+        match stdout.write_all(&line).and_then(|_| stdout.flush()) {
+            Ok(()) => replayed += 1,
+            // Leser hat aufgehört (z.B. `| head`)
+            Err(e) if e.kind() == io::ErrorKind::BrokenPipe => break,
+            Err(e) => return Err(e.into()),
+        }
+    }
+    eprintln!("📼 Replayed {} block(s) of chain {}, {} skipped", replayed, options.chain_id, skipped);
+    Ok(())
+}
+
+/// Inhalt von status.json (None, wenn nicht vorhanden oder unlesbar)
+fn read_status(dir: &Path) -> Option<serde_json::Value> {
+    serde_json::from_slice(&fs::read(dir.join(STATUS_FILE_NAME)).ok()?).ok()
+}
+
+/// Belegung, Lücken und Heartbeat des Output-Verzeichnisses
+pub fn stats(chain_id: u64, output_dir: &Path, json: bool) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let dir = chain_dir(output_dir, chain_id);
+    let storage_stats = StorageStats::default();
+    storage_stats.set_output_dir(dir.clone(), chain_id);
+    let usage = storage_stats.snapshot();
+    let blocks = stored_blocks(&dir, chain_id, None, None)?;
+    let gaps = gaps(&blocks);
+    let missing: u64 = gaps.iter().map(|(from, to)| to - from + 1).sum();
+    let status = read_status(&dir);
+
+    if json {
+        let report = serde_json::json!({
+            "chain_id": chain_id,
+            "output_dir": dir.display().to_string(),
+            "entries": usage.entries,
+            "total_bytes": usage.total_bytes,
+            "oldest_block": usage.oldest_block,
+            "newest_block": usage.newest_block,
+            "oldest_age_secs": usage.oldest_age_secs,
+            "newest_age_secs": usage.newest_age_secs,
+            "missing_blocks": missing,
+            "gaps": gaps.iter().map(|(from, to)| serde_json::json!([from, to])).collect::<Vec<_>>(),
+            "status": status,
+        });
+        println!("{}", serde_json::to_string_pretty(&report)?);
+        return Ok(());
+    }
+
+    println!("📊 Chain {} in {}", chain_id, dir.display());
+    if usage.entries == 0 {
+        println!("   blocks:  none");
+    } else {
+        println!(
+            "   blocks:  {} (#{}..=#{}), {} missing in {} gap(s)",
+            usage.entries,
+            usage.oldest_block,
+            usage.newest_block,
+            missing,
+            gaps.len()
+        );
+        println!("   size:    {:.1} MiB", usage.total_bytes as f64 / (1024.0 * 1024.0));
+        println!("   age:     newest {}s, oldest {}s", usage.newest_age_secs, usage.oldest_age_secs);
+    }
+    match status {
+        Some(status) => println!(
+            "   bridge:  {} (pid {}, mode {}, status.json {}s old)",
+            status["state"].as_str().unwrap_or("-"),
+            status["pid"],
+            status["mode"].as_str().unwrap_or("-"),
+            unix_now().saturating_sub(status["written_unix"].as_u64().unwrap_or(0))
+        ),
+        None => println!("   bridge:  no {}", STATUS_FILE_NAME),
+    }
+    Ok(())
+}
+
+/// Ergebnis einer Prüfung von `doctor`
+enum Check {
+    Ok,
+    Info,
+    Warn,
+    Fail,
+}
+
+/// Sammelt die Ausgabe von `doctor` und zählt Fehler
+#[derive(Default)]
+struct Doctor {
+    failed: usize,
+}
+
+impl Doctor {
+    fn report(&mut self, check: Check, name: &str, detail: impl std::fmt::Display) {
+        let icon = match check {
+            Check::Ok => "✅",
+            Check::Info => "ℹ️ ",
+            Check::Warn => "⚠️ ",
+            Check::Fail => {
+                self.failed += 1;
+                "❌"
+            }
+        };
+        println!("{} {}: {}", icon, name, detail);
+    }
+}
+
+/// Jetzt akzeptierte Signer; meldet leere Konfiguration, unlesbare Signer-Datei und fehlgeschlagene SystemConfig
+async fn doctor_signers(
+    doctor: &mut Doctor,
+    client: &reqwest::Client,
+    settings: &BridgeSettings,
+    chain_config: &ChainConfig,
+    chain_id: u64,
+) -> Vec<Address> {
+    let now = unix_now();
+    let mut accepted: Vec<(Address, &str)> = Vec::new();
+    if chain_config.unsafe_signer != Address::ZERO {
+        accepted.push((chain_config.unsafe_signer, "chain default"));
+    }
+    let active = |entries: &[SignerEntry]| -> Vec<Address> {
+        entries.iter().filter(|entry| entry.is_active(now)).map(|entry| entry.address).collect()
+    };
+    accepted.extend(active(&settings.signers).into_iter().map(|address| (address, "signers")));
+    if let Some(ref path) = settings.signers_file {
+        match load_signers_file(path) {
+            Ok(entries) => accepted.extend(active(&entries).into_iter().map(|address| (address, "signers file"))),
+            Err(e) => doctor.report(Check::Fail, "signers", format!("{}: {}", path.display(), e)),
+        }
+    }
+    if let Some(ref signer_settings) = settings.signer {
+        match signer::system_config_address(signer_settings, chain_id) {
+            Some(system_config) => match fetch_unsafe_signer(client, &signer_settings.l1_rpc, system_config).await {
+                Ok(address) => accepted.push((address, "SystemConfig")),
+                Err(e) => doctor.report(Check::Fail, "signers", format!("SystemConfig {}: {}", system_config, e)),
+            },
+            None => doctor.report(Check::Fail, "signers", "KONA_BRIDGE_L1_RPC set, but no SystemConfig address known"),
+        }
+    }
+    if accepted.is_empty() {
+        doctor.report(Check::Fail, "signers", "no signer accepted at the moment");
+    } else {
+        let list: Vec<String> = accepted.iter().map(|(address, from)| format!("{} ({})", address, from)).collect();
+        doctor.report(Check::Ok, "signers", list.join(", "));
+    }
+    accepted.into_iter().map(|(address, _)| address).collect()
+}
+
+/// Antwort der HTTP-Quelle: Format, Uhrzeit gegen den Payload-Timestamp und Signatur
+fn doctor_preconf(
+    doctor: &mut Doctor,
+    preconf: &serde_json::Value,
+    chain_id: u64,
+    accepted: &[Address],
+    settings: &BridgeSettings,
+) {
+    let parsed = parse_http_preconf(preconf).and_then(|(data, signature)| {
+        let block_number = extract_block_number_from_preconf_data(&data)?;
+        let timestamp = extract_timestamp_from_preconf_data(&data)?;
+        Ok((data, signature, block_number, timestamp))
+    });
+    let (data, signature, block_number, timestamp) = match parsed {
+        Ok(parsed) => parsed,
+        Err(e) => {
+            doctor.report(Check::Fail, "format", format!("unexpected response: {}", e));
+            return;
+        }
+    };
+    doctor.report(Check::Ok, "format", format!("block {}, {} bytes payload", block_number, data.len()));
+
+    // Der neueste Preconf ist Sekunden alt: liegt er in der Zukunft, geht die lokale Uhr nach
+    let now = unix_now();
+    let validation = &settings.validation;
+    if timestamp > now + validation.max_future_secs {
+        let detail = format!(
+            "newest payload {}s ahead of the local clock (limit {}s), clock behind? Preconfs would be rejected",
+            timestamp - now,
+            validation.max_future_secs
+        );
+        doctor.report(Check::Fail, "clock", detail);
+    } else if now.saturating_sub(timestamp) > validation.max_past_secs {
+        let detail = format!(
+            "newest payload {}s behind the local clock (limit {}s), clock ahead or source stale?",
+            now - timestamp,
+            validation.max_past_secs
+        );
+        doctor.report(Check::Warn, "clock", detail);
+    } else {
+        let skew = now as i64 - timestamp as i64;
+        doctor.report(Check::Ok, "clock", format!("local clock {:+}s against the newest payload", skew));
+    }
+
+    match recover_signer(&data, &signature, chain_id) {
+        Ok(signer) if accepted.contains(&signer) => {
+            doctor.report(Check::Ok, "signature", format!("block {} signed by {}", block_number, signer))
+        }
+        Ok(signer) => {
+            let detail = format!("block {} signed by {}, which is not accepted (see signers)", block_number, signer);
+            doctor.report(Check::Fail, "signature", detail)
+        }
+        Err(e) => doctor.report(Check::Fail, "signature", format!("block {}: {}", block_number, e)),
+    }
+}
+
+/// Bootnodes per TCP zum libp2p-Port aus dem ENR (Discovery über UDP ist ohne Handshake nicht prüfbar)
+async fn doctor_bootnodes(doctor: &mut Doctor, settings: &BridgeSettings, chain_config: &ChainConfig) {
+    let mut bootnodes: Vec<Enr> = Vec::new();
+    for enr in &settings.bootnodes {
+        match enr.parse::<Enr>() {
+            Ok(enr) => bootnodes.push(enr),
+            Err(e) => doctor.report(Check::Fail, "bootnodes", format!("invalid ENR {}: {}", enr, e)),
+        }
+    }
+    bootnodes.extend(chain_config.bootnodes.iter().cloned());
+    if bootnodes.is_empty() {
+        doctor.report(Check::Warn, "bootnodes", "none configured, gossip cannot find peers (KONA_BRIDGE_BOOTNODES)");
+        return;
+    }
+
+    let mut dials = JoinSet::new();
+    let mut udp_only = 0;
+    for enr in &bootnodes {
+        match (enr.ip4(), enr.tcp4()) {
+            (Some(ip), Some(port)) => {
+                let addr = SocketAddr::from((ip, port));
+                dials.spawn(async move {
+                    let dialed = tokio::time::timeout(DOCTOR_DIAL_TIMEOUT, tokio::net::TcpStream::connect(addr)).await;
+                    (addr, matches!(dialed, Ok(Ok(_))))
+                });
+            }
+            _ => udp_only += 1,
+        }
+    }
+    let (mut dialable, mut unreachable) = (0, Vec::new());
+    while let Some(Ok((addr, ok))) = dials.join_next().await {
+        if ok {
+            dialable += 1;
+        } else {
+            unreachable.push(addr.to_string());
+        }
+    }
+    let mut detail = format!("{}/{} dialable over TCP", dialable, dialable + unreachable.len());
+    if !unreachable.is_empty() {
+        detail.push_str(&format!(", unreachable: {}", unreachable.join(", ")));
+    }
+    if udp_only > 0 {
+        detail.push_str(&format!(", {} without TCP port (not checked)", udp_only));
+    }
+    let check = if dialable > 0 || (unreachable.is_empty() && udp_only > 0) { Check::Ok } else { Check::Warn };
+    doctor.report(check, "bootnodes", detail);
+}
+
+/// Prüft, ob die Bridge für die Chain so starten kann; Err, wenn mindestens eine Prüfung fehlschlägt
+pub async fn doctor(
+    chain_id: u64,
+    output_dir: &Path,
+    disc_port: u16,
+    gossip_port: u16,
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let mut doctor = Doctor::default();
+
+    match configfile::config_path() {
+        Some(path) => match configfile::validate(Path::new(&path)) {
+            Ok(values) => doctor.report(Check::Ok, "config", format!("{} ({} values)", path, values)),
+            Err(e) => doctor.report(Check::Fail, "config", format!("{}: {}", path, e)),
+        },
+        None => doctor.report(Check::Info, "config", "KONA_BRIDGE_CONFIG not set, environment only"),
+    }
+
+    let settings = BridgeSettings::from_env(chain_id);
+    let registry_chain = registry::lookup(chain_id);
+    let network = network_name(chain_id)
+        .map(str::to_string)
+        .or_else(|| registry_chain.as_ref().map(|chain| chain.name.clone()))
+        .or_else(|| settings.rollup_config.as_ref().map(|_| "custom".to_string()));
+    let chain_config = ChainConfig::for_chain(chain_id);
+    let signer = chain_config.unsafe_signer;
+    let signers_configured = !settings.signers.is_empty() || settings.signer.is_some();
+    match network {
+        Some(ref network) if signer != Address::ZERO => {
+            doctor.report(Check::Ok, "chain", format!("{} ({}), signer {}", network, chain_id, signer))
+        }
+        Some(ref network) if signers_configured => {
+            let detail =
+                format!("{} ({}) has no known unsafe signer, using configured signers", network, chain_id);
+            doctor.report(Check::Info, "chain", detail)
+        }
+        Some(ref network) => {
+            let detail = format!("{} ({}) has no known unsafe signer and no signers are configured", network, chain_id);
+            doctor.report(Check::Fail, "chain", detail)
+        }
+        None if signers_configured => {
+            doctor.report(Check::Warn, "chain", format!("{} is not a known chain, using configured signers", chain_id))
+        }
+        None => {
+            let detail = format!("{} is not a known chain and no signers are configured", chain_id);
+            doctor.report(Check::Fail, "chain", detail)
+        }
+    }
+    if let Some(ref path) = settings.rollup_config {
+        match rollup::load_for_chain(path, chain_id) {
+            Ok(rollup) => {
+                let detail = format!("{}: {}", path.display(), rollup::describe(&rollup, unix_now()));
+                doctor.report(Check::Ok, "rollup", detail)
+            }
+            Err(e) => doctor.report(Check::Fail, "rollup", e),
+        }
+    }
+    if let Some(ref chain) = registry_chain {
+        let detail = format!(
+            "{}, L1 {}, {}s blocks, hardfork {}, public RPC {}",
+            chain.name,
+            chain.l1_chain_id,
+            chain.block_time,
+            chain.hardforks.active(unix_now()),
+            chain.public_rpc.as_deref().unwrap_or("-")
+        );
+        doctor.report(Check::Info, "registry", detail);
+    }
+
+    // Output-Verzeichnis anlegen und beschreiben
+    let dir = chain_dir(output_dir, chain_id);
+    let probe = dir.join(format!(".doctor_{}.tmp", std::process::id()));
+    match fs::create_dir_all(&dir).and_then(|_| fs::write(&probe, b"ok")).and_then(|_| fs::remove_file(&probe)) {
+        Ok(()) => doctor.report(Check::Ok, "output", format!("{} is writable", dir.display())),
+        Err(e) => doctor.report(Check::Fail, "output", format!("{}: {}", dir.display(), e)),
+    }
+    match free_bytes(&dir) {
+        Ok(free) if free < DOCTOR_MIN_FREE_BYTES => {
+            doctor.report(Check::Warn, "disk", format!("only {} MiB free", free / (1024 * 1024)))
+        }
+        Ok(free) => doctor.report(Check::Ok, "disk", format!("{} MiB free", free / (1024 * 1024))),
+        Err(e) => doctor.report(Check::Warn, "disk", format!("cannot determine free space: {}", e)),
+    }
+
+    // Läuft schon eine Bridge auf dem Verzeichnis, sind die Ports belegt und der Heartbeat aussagekräftig
+    let bridge_running = match DirLock::acquire(&dir, chain_id, false, Duration::ZERO).await {
+        Ok(_lock) => {
+            doctor.report(Check::Info, "lock", "no bridge is writing to the directory");
+            false
+        }
+        Err(e) => {
+            doctor.report(Check::Info, "lock", e);
+            true
+        }
+    };
+    match read_status(&dir) {
+        Some(status) => {
+            let age = unix_now().saturating_sub(status["written_unix"].as_u64().unwrap_or(0));
+            let interval = match settings.status_interval_secs {
+                0 => DEFAULT_STATUS_INTERVAL_SECS,
+                interval => interval,
+            };
+            let state = status["state"].as_str().unwrap_or("-");
+            if state == "running" && bridge_running && age > 3 * interval {
+                let detail = format!("{} not updated for {}s, bridge hanging?", STATUS_FILE_NAME, age);
+                doctor.report(Check::Fail, "heartbeat", detail);
+            } else if state == "running" && !bridge_running {
+                let detail = format!("{} says running, but the directory is not locked (crashed?)", STATUS_FILE_NAME);
+                doctor.report(Check::Warn, "heartbeat", detail);
+            } else {
+                doctor.report(Check::Ok, "heartbeat", format!("{} ({}s old)", state, age));
+            }
+        }
+        None => doctor.report(Check::Info, "heartbeat", format!("no {}", STATUS_FILE_NAME)),
+    }
+
+    // Akzeptierte Signer wie im Capture-Pfad: Default der Chain, KONA_BRIDGE_SIGNERS, Signer-Datei, SystemConfig
+    let client = reqwest::Client::builder().timeout(DOCTOR_HTTP_TIMEOUT).build()?;
+    let accepted = doctor_signers(&mut doctor, &client, &settings, &chain_config, chain_id).await;
+
+    // HTTP-Quelle wie im Capture-Pfad (Einstellung vor Default der Chain): erreichbar, Format, Chain und Signatur
+    let endpoint =
+        settings.http_endpoint.clone().or_else(|| chain_config.get_http_endpoint());
+    match endpoint {
+        Some(endpoint) => {
+            let started = Instant::now();
+            match client.get(&endpoint).send().await {
+                Ok(response) if response.status().is_success() => {
+                    let detail =
+                        format!("{} answered {} in {} ms", endpoint, response.status(), started.elapsed().as_millis());
+                    doctor.report(Check::Ok, "http", detail);
+                    match response.json::<serde_json::Value>().await {
+                        Ok(preconf) => doctor_preconf(&mut doctor, &preconf, chain_id, &accepted, &settings),
+                        Err(e) => doctor.report(Check::Fail, "format", format!("response is not JSON: {}", e)),
+                    }
+                    match chaincheck::check_endpoint(&client, &endpoint, chain_id, &accepted).await {
+                        Ok(EndpointChain::Match(by)) => {
+                            let detail = format!("{} serves chain {} ({})", endpoint, chain_id, by);
+                            doctor.report(Check::Ok, "chain-id", detail)
+                        }
+                        Ok(EndpointChain::Mismatch(remote, by)) => {
+                            let detail = format!("{} serves chain {} ({}), not {}", endpoint, remote, by, chain_id);
+                            doctor.report(Check::Fail, "chain-id", detail)
+                        }
+                        Ok(EndpointChain::Unknown) => {
+                            let detail = format!("cannot determine the chain of {}", endpoint);
+                            doctor.report(Check::Info, "chain-id", detail)
+                        }
+                        Err(e) => doctor.report(Check::Warn, "chain-id", e),
+                    }
+                }
+                Ok(response) => {
+                    doctor.report(Check::Fail, "http", format!("{} answered {}", endpoint, response.status()))
+                }
+                Err(e) => doctor.report(Check::Fail, "http", format!("{}: {}", endpoint, e)),
+            }
+        }
+        None => {
+            doctor.report(Check::Info, "http", "no HTTP endpoint, gossip only");
+            doctor.report(Check::Info, "clock", format!("not checked without HTTP source, local time {}", unix_now()));
+        }
+    }
+
+    // Ports nur prüfen, wenn sie nicht ohnehin die laufende Bridge belegt
+    if bridge_running {
+        doctor.report(Check::Info, "ports", "not checked, a bridge is running");
+    } else {
+        let udp = SocketAddr::from(([0, 0, 0, 0], disc_port));
+        match UdpSocket::bind(udp) {
+            Ok(_) => doctor.report(Check::Ok, "discovery", format!("udp {} free", udp)),
+            Err(e) => doctor.report(Check::Fail, "discovery", format!("udp {}: {}", udp, e)),
+        }
+        let listeners = [
+            ("gossip", Some(SocketAddr::from(([0, 0, 0, 0], gossip_port)))),
+            ("serve", settings.serve_addr),
+            ("grpc", settings.grpc_addr),
+            ("admin", settings.admin_addr),
+            ("debug", settings.debug_addr),
+        ];
+        for (name, addr) in listeners {
+            let Some(addr) = addr else {
+                continue;
+            };
+            match TcpListener::bind(addr) {
+                Ok(_) => doctor.report(Check::Ok, name, format!("tcp {} free", addr)),
+                Err(e) => doctor.report(Check::Fail, name, format!("tcp {}: {}", addr, e)),
+            }
+        }
+    }
+
+    doctor_bootnodes(&mut doctor, &settings, &chain_config).await;
+
+    // Dateien, die erst beim Start gelesen werden
+    let mut files = Vec::new();
+    if let Some(ref tls) = settings.tls {
+        files.push(("tls", tls.cert_path.clone()));
+        files.push(("tls", tls.key_path.clone()));
+    }
+    if let Some(ref path) = settings.signers_file {
+        files.push(("signers", path.clone()));
+    }
+    for (name, path) in files {
+        match fs::File::open(&path) {
+            Ok(_) => doctor.report(Check::Ok, name, format!("{} readable", path.display())),
+            Err(e) => doctor.report(Check::Fail, name, format!("{}: {}", path.display(), e)),
+        }
+    }
+
+    if doctor.failed > 0 {
+        return Err(format!("{} check(s) failed", doctor.failed).into());
+    }
+    Ok(())
+}
@@ -0,0 +1,776 @@
+// codec.rs - Kompression der Preconf-Payloads und Container-Format der .raw Dateien
+//
+// Layout einer .raw Datei (Version 2, little-endian, muss mit server/preconf.c übereinstimmen):
+//   0   magic "KPRC"          4   version u8 (2)     5   codec u8      6   level i8     7   flags u8
+//   8   chain_id u64          16  block_number u64   24  decompressed_size u32
+//   28  body_len u32          32  signature [65]     97  reference u24 (dict_id bzw. Abstand zur Delta-Basis, sonst 0)
+//   100 crc32 u32 (IEEE, über Header-Bytes 0..100 und Body)
+//   104 Body: Payload (parent_beacon_block_root + execution payload) im angegebenen Codec
+// flags bit 0: zstd-Body mit dem Dictionary dict_id komprimiert (siehe zdict.rs)
+// flags bit 1: zstd-Body als Delta gegen den Payload von Block block_number - reference (siehe delta.rs)
+//
+// Weiterhin lesbar: Version 1 (12-Byte-Header, Body, Signatur am Ende) und Legacy-Dateien
+// ohne Header (zstd-Body + Signatur). Der C-Server und der Query-Socket liefern an den
+// Prover immer das Legacy-Format (zstd + Signatur), weil der Proof nur zstd kennt.
+
+use crate::{
+    delta,
+    zdict::{self, ZstdDict},
+};
+use std::{
+    cell::RefCell,
+    fmt,
+    io::{self, Write},
+};
+use zstd::{
+    bulk::{Compressor, Decompressor},
+    zstd_safe::{CParameter, DCtx, DParameter},
+};
+
+pub const CONTAINER_MAGIC: [u8; 4] = *b"KPRC";
+pub const CONTAINER_VERSION: u8 = 2;
+pub const CONTAINER_HEADER_SIZE: usize = 104;
+pub const SIGNATURE_SIZE: usize = 65;
+/// Obergrenze für decompressed_size bzw. die zstd-Content-Size, bevor dafür Speicher angelegt wird (Payloads sind
+/// weit kleiner, siehe KONA_BRIDGE_MAX_PAYLOAD_KB); muss mit PRECONF_MAX_DECOMPRESSED_SIZE in server/preconf.c
+/// übereinstimmen
+pub const MAX_DECOMPRESSED_SIZE: usize = 64 * 1024 * 1024;
+
+const V1_HEADER_SIZE: usize = 12;
+
+// Offsets im Version-2-Header
+const V2_CHAIN_ID: usize = 8;
+const V2_BLOCK_NUMBER: usize = 16;
+const V2_DECOMPRESSED_SIZE: usize = 24;
+const V2_BODY_LEN: usize = 28;
+const V2_FLAGS: usize = 7;
+const V2_SIGNATURE: usize = 32;
+const V2_REFERENCE: usize = 97;
+const V2_CRC: usize = 100;
+
+const FLAG_ZSTD_DICT: u8 = 0x01;
+const FLAG_DELTA: u8 = 0x02;
+
+/// Deltas beziehen sich immer auf den direkten Vorgänger (das Header-Feld erlaubt größere Abstände)
+const DELTA_DISTANCE: u32 = 1;
+/// Obergrenze des zstd-Fensters für Deltas (Default-Grenze des Dekompressors)
+const DELTA_MAX_WINDOW_LOG: u32 = 27;
+
+const CODEC_ID_NONE: u8 = 0;
+const CODEC_ID_SNAPPY: u8 = 1;
+const CODEC_ID_ZSTD: u8 = 2;
+
+/// Level für Legacy-Konvertierung und als Default (schnell, gut genug für Payloads)
+pub const DEFAULT_ZSTD_LEVEL: i32 = 1;
+
+thread_local! {
+    /// zstd-Kontexte je Thread (Tokio-Blocking-Pool, Verify-Worker) statt je Preconf neu angelegt; der Kompressor
+    /// gehört zu einem Level und Dictionary (0 = keins) und wird nur bei einem anderen ersetzt (Cold-Codec,
+    /// Legacy-Konvertierung, neu trainiertes Dictionary), der Dekompressor ebenso zu einem Dictionary
+    static ZSTD_COMPRESSOR: RefCell<Option<(i32, u32, Compressor<'static>)>> = const { RefCell::new(None) };
+    static ZSTD_DECOMPRESSOR: RefCell<Option<(u32, Decompressor<'static>)>> = const { RefCell::new(None) };
+    /// Snappy-Encoder (Hash-Tabelle) und Kompressionspuffer ebenfalls je Thread: der Body wird aus dem Puffer direkt
+    /// an sein Ziel kopiert, statt je Preconf einen Puffer in Größe der Worst-Case-Schranke anzulegen
+    static SNAPPY_ENCODER: RefCell<snap::raw::Encoder> = RefCell::new(snap::raw::Encoder::new());
+    static COMPRESS_BUFFER: RefCell<Vec<u8>> = const { RefCell::new(Vec::new()) };
+}
+
+/// Größere Kompressionspuffer werden nach Gebrauch verkleinert (der Blocking-Pool hat viele Threads)
+const COMPRESS_BUFFER_KEEP: usize = 2 * 1024 * 1024;
+
+/// Referenz einer zstd-Kompression: trainiertes Dictionary (flags bit 0) oder Payload des Vorgängers (flags bit 1)
+#[derive(Clone, Copy)]
+pub enum Reference<'a> {
+    Dict(&'a ZstdDict),
+    Delta(&'a [u8]),
+}
+
+impl<'a> Reference<'a> {
+    fn dict(self) -> Option<&'a ZstdDict> {
+        match self {
+            Reference::Dict(dict) => Some(dict),
+            Reference::Delta(_) => None,
+        }
+    }
+}
+
+fn zstd_error(code: usize) -> io::Error {
+    io::Error::other(zstd::zstd_safe::get_error_name(code))
+}
+
+/// zstd-Fenster, das Basis und Payload überdeckt (sonst wären frühe Teile der Basis nicht referenzierbar)
+fn delta_window_log(len: usize) -> u32 {
+    (usize::BITS - len.max(1).leading_zeros()).clamp(10, DELTA_MAX_WINDOW_LOG)
+}
+
+/// zstd-Kompression mit dem Kontext des aktuellen Threads nach `out` (vorheriger Inhalt wird verworfen)
+fn zstd_compress_into(data: &[u8], level: i32, reference: Option<Reference>, out: &mut Vec<u8>) -> io::Result<()> {
+    if let Some(Reference::Delta(base)) = reference {
+        return zstd_delta_into(data, level, base, out);
+    }
+    let dict = reference.and_then(Reference::dict);
+    let dict_id = dict.map_or(0, |dict| dict.id);
+    ZSTD_COMPRESSOR.with(|cell| {
+        let mut cell = cell.borrow_mut();
+        let compressor = match &mut *cell {
+            Some((current, current_dict, compressor)) if *current == level && *current_dict == dict_id => compressor,
+            slot => {
+                let compressor = match dict {
+                    Some(dict) => Compressor::with_dictionary(level, &dict.data)?,
+                    None => Compressor::new(level)?,
+                };
+                &mut slot.insert((level, dict_id, compressor)).2
+            }
+        };
+        out.clear();
+        out.reserve(zstd::zstd_safe::compress_bound(data.len()));
+        compressor.compress_to_buffer(data, out).map(|_| ())
+    })
+}
+
+/// Delta gegen `base` als zstd-Prefix; eigener Kontext, weil jeder Block eine andere Basis hat. Die Prüfsumme im
+/// Frame lässt eine geänderte Basis beim Lesen auffallen
+fn zstd_delta_into(data: &[u8], level: i32, base: &[u8], out: &mut Vec<u8>) -> io::Result<()> {
+    let mut compressor = Compressor::default();
+    compressor.set_compression_level(level)?;
+    compressor.set_parameter(CParameter::WindowLog(delta_window_log(base.len() + data.len())))?;
+    compressor.set_parameter(CParameter::ChecksumFlag(true))?;
+    compressor.context_mut().ref_prefix(base).map_err(zstd_error)?;
+    out.clear();
+    out.reserve(zstd::zstd_safe::compress_bound(data.len()));
+    compressor.compress_to_buffer(data, out).map(|_| ())
+}
+
+/// zstd-Dekompression mit dem Kontext des aktuellen Threads, `capacity` ist die Obergrenze des Ergebnisses
+fn zstd_decompress(data: &[u8], capacity: usize, reference: Option<Reference>) -> io::Result<Vec<u8>> {
+    if let Some(Reference::Delta(base)) = reference {
+        let mut context = DCtx::create();
+        context.set_parameter(DParameter::WindowLogMax(DELTA_MAX_WINDOW_LOG)).map_err(zstd_error)?;
+        context.ref_prefix(base).map_err(zstd_error)?;
+        let mut payload = Vec::with_capacity(capacity);
+        context.decompress(&mut payload, data).map_err(zstd_error)?;
+        return Ok(payload);
+    }
+    let dict = reference.and_then(Reference::dict);
+    let dict_id = dict.map_or(0, |dict| dict.id);
+    ZSTD_DECOMPRESSOR.with(|cell| {
+        let mut cell = cell.borrow_mut();
+        let decompressor = match &mut *cell {
+            Some((current_dict, decompressor)) if *current_dict == dict_id => decompressor,
+            slot => {
+                let decompressor = match dict {
+                    Some(dict) => Decompressor::with_dictionary(&dict.data)?,
+                    None => Decompressor::new()?,
+                };
+                &mut slot.insert((dict_id, decompressor)).1
+            }
+        };
+        decompressor.decompress(data, capacity)
+    })
+}
+
+/// Kompression der gespeicherten Payloads
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum PayloadCodec {
+    None,
+    Snappy,
+    Zstd(i32),
+}
+
+impl Default for PayloadCodec {
+    fn default() -> Self {
+        PayloadCodec::Zstd(DEFAULT_ZSTD_LEVEL)
+    }
+}
+
+impl fmt::Display for PayloadCodec {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            PayloadCodec::None => write!(f, "none"),
+            PayloadCodec::Snappy => write!(f, "snappy"),
+            PayloadCodec::Zstd(level) => write!(f, "zstd:{}", level),
+        }
+    }
+}
+
+impl PayloadCodec {
+    /// Parst "none", "snappy", "zstd" oder "zstd:<level>" (Level 1-22)
+    pub fn parse(value: &str) -> Option<Self> {
+        let value = value.trim().to_ascii_lowercase();
+        let (name, level) = match value.split_once(':') {
+            Some((name, level)) => (name.to_string(), Some(level.parse::<i32>().ok()?)),
+            None => (value, None),
+        };
+
+        match name.as_str() {
+            "none" | "raw" => Some(PayloadCodec::None),
+            "snappy" => Some(PayloadCodec::Snappy),
+            "zstd" => {
+                let level = level.unwrap_or(DEFAULT_ZSTD_LEVEL);
+                (1..=22).contains(&level).then_some(PayloadCodec::Zstd(level))
+            }
+            _ => None,
+        }
+    }
+
+    /// Kurzname für Metadaten und Metriken
+    pub fn name(&self) -> &'static str {
+        match self {
+            PayloadCodec::None => "none",
+            PayloadCodec::Snappy => "snappy",
+            PayloadCodec::Zstd(_) => "zstd",
+        }
+    }
+
+    fn id(&self) -> u8 {
+        match self {
+            PayloadCodec::None => CODEC_ID_NONE,
+            PayloadCodec::Snappy => CODEC_ID_SNAPPY,
+            PayloadCodec::Zstd(_) => CODEC_ID_ZSTD,
+        }
+    }
+
+    fn level(&self) -> i8 {
+        match self {
+            PayloadCodec::Zstd(level) => *level as i8,
+            _ => 0,
+        }
+    }
+
+    fn from_header(id: u8, level: i8) -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        match id {
+            CODEC_ID_NONE => Ok(PayloadCodec::None),
+            CODEC_ID_SNAPPY => Ok(PayloadCodec::Snappy),
+            CODEC_ID_ZSTD => Ok(PayloadCodec::Zstd(level as i32)),
+            _ => Err(format!("Unknown payload codec id {}", id).into()),
+        }
+    }
+
+    /// Komprimiert einen Payload in den Puffer des aktuellen Threads und übergibt den Body an `f`, das ihn an sein
+    /// Ziel kopiert (`f` darf selbst nicht komprimieren); `reference` gilt nur für zstd
+    pub fn with_compressed<R>(
+        &self,
+        payload: &[u8],
+        reference: Option<Reference>,
+        f: impl FnOnce(&[u8]) -> R,
+    ) -> Result<R, Box<dyn std::error::Error + Send + Sync>> {
+        if *self == PayloadCodec::None {
+            return Ok(f(payload));
+        }
+        COMPRESS_BUFFER.with(|cell| {
+            let mut buffer = cell.borrow_mut();
+            let len = match self {
+                PayloadCodec::Zstd(level) => {
+                    zstd_compress_into(payload, *level, reference, &mut buffer)?;
+                    buffer.len()
+                }
+                _ => {
+                    let max = snap::raw::max_compress_len(payload.len());
+                    if buffer.len() < max {
+                        buffer.resize(max, 0);
+                    }
+                    SNAPPY_ENCODER.with(|encoder| encoder.borrow_mut().compress(payload, &mut buffer[..]))?
+                }
+            };
+            let result = f(&buffer[..len]);
+            if buffer.capacity() > COMPRESS_BUFFER_KEEP {
+                buffer.clear();
+                buffer.shrink_to(COMPRESS_BUFFER_KEEP);
+            }
+            Ok(result)
+        })
+    }
+
+    /// Dekomprimiert einen Body, `size` ist die erwartete Größe aus dem Header
+    pub fn decompress(
+        &self,
+        body: &[u8],
+        size: usize,
+        reference: Option<Reference>,
+    ) -> Result<Vec<u8>, Box<dyn std::error::Error + Send + Sync>> {
+        check_decompressed_size(size)?;
+        let payload = match self {
+            PayloadCodec::None => body.to_vec(),
+            PayloadCodec::Snappy => {
+                // Die Länge im Snappy-Body bestimmt die Allokation, sie muss zum Header passen
+                let len = snap::raw::decompress_len(body)?;
+                if len != size {
+                    return Err(format!("Decompressed size mismatch: {} != {}", len, size).into());
+                }
+                snap::raw::Decoder::new().decompress_vec(body)?
+            }
+            PayloadCodec::Zstd(_) => zstd_decompress(body, size, reference)?,
+        };
+        if payload.len() != size {
+            return Err(format!("Decompressed size mismatch: {} != {}", payload.len(), size).into());
+        }
+        Ok(payload)
+    }
+}
+
+/// Zerlegte .raw Datei (Body noch im gespeicherten Codec)
+pub struct PreconfContainer<'a> {
+    pub version: u8, // 0 = Legacy ohne Header
+    pub codec: PayloadCodec,
+    pub chain_id: Option<u64>,     // erst ab Version 2
+    pub block_number: Option<u64>, // erst ab Version 2
+    pub decompressed_size: usize,
+    pub dict_id: Option<u32>,    // zstd-Dictionary (flags bit 0, erst ab Version 2)
+    pub delta_base: Option<u64>, // Block, gegen dessen Payload der Body ein Delta ist (flags bit 1, ab Version 2)
+    pub body: &'a [u8],
+    pub signature: &'a [u8],
+}
+
+impl<'a> PreconfContainer<'a> {
+    /// Parst eine .raw Datei (Version 2, Version 1 oder Legacy ohne Header)
+    pub fn parse(data: &'a [u8]) -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        if data.len() >= 5 && data[..4] == CONTAINER_MAGIC {
+            return match data[4] {
+                CONTAINER_VERSION => Self::parse_v2(data),
+                1 => Self::parse_v1(data),
+                version => Err(format!("Unsupported container version {}", version).into()),
+            };
+        }
+        Self::parse_legacy(data)
+    }
+
+    fn parse_v2(data: &'a [u8]) -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        if data.len() < CONTAINER_HEADER_SIZE {
+            return Err(format!("Truncated container header: {} bytes", data.len()).into());
+        }
+        let body_len = read_u32(data, V2_BODY_LEN) as usize;
+        if data.len() != CONTAINER_HEADER_SIZE + body_len {
+            return Err(format!(
+                "Container length mismatch: {} bytes, expected {}",
+                data.len(),
+                CONTAINER_HEADER_SIZE + body_len
+            )
+            .into());
+        }
+
+        let expected_crc = read_u32(data, V2_CRC);
+        let mut hasher = crc32fast::Hasher::new();
+        hasher.update(&data[..V2_CRC]);
+        hasher.update(&data[CONTAINER_HEADER_SIZE..]);
+        let crc = hasher.finalize();
+        if crc != expected_crc {
+            return Err(format!("Container CRC mismatch: {:08x} != {:08x}", crc, expected_crc).into());
+        }
+
+        let block_number = read_u64(data, V2_BLOCK_NUMBER);
+        let reference = read_u24(data, V2_REFERENCE);
+        Ok(Self {
+            version: CONTAINER_VERSION,
+            codec: PayloadCodec::from_header(data[5], data[6] as i8)?,
+            chain_id: Some(read_u64(data, V2_CHAIN_ID)),
+            block_number: Some(block_number),
+            decompressed_size: check_decompressed_size(read_u32(data, V2_DECOMPRESSED_SIZE) as usize)?,
+            dict_id: (data[V2_FLAGS] & FLAG_ZSTD_DICT != 0).then_some(reference),
+            delta_base: (data[V2_FLAGS] & FLAG_DELTA != 0).then(|| block_number.saturating_sub(reference as u64)),
+            body: &data[CONTAINER_HEADER_SIZE..],
+            signature: &data[V2_SIGNATURE..V2_SIGNATURE + SIGNATURE_SIZE],
+        })
+    }
+
+    /// Version 1: 12-Byte-Header, Body, Signatur am Ende (ohne Prüfsumme)
+    fn parse_v1(data: &'a [u8]) -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        if data.len() < V1_HEADER_SIZE + SIGNATURE_SIZE {
+            return Err(format!("Truncated v1 container: {} bytes", data.len()).into());
+        }
+        let sig_start = data.len() - SIGNATURE_SIZE;
+        Ok(Self {
+            version: 1,
+            codec: PayloadCodec::from_header(data[5], data[6] as i8)?,
+            chain_id: None,
+            block_number: None,
+            decompressed_size: check_decompressed_size(read_u32(data, 8) as usize)?,
+            dict_id: None,
+            delta_base: None,
+            body: &data[V1_HEADER_SIZE..sig_start],
+            signature: &data[sig_start..],
+        })
+    }
+
+    /// Legacy: zstd-Body + Signatur
+    fn parse_legacy(data: &'a [u8]) -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        if data.len() <= SIGNATURE_SIZE {
+            return Err(format!("Preconf file too short: {} bytes", data.len()).into());
+        }
+        let sig_start = data.len() - SIGNATURE_SIZE;
+        let body = &data[..sig_start];
+        let decompressed_size = zstd::zstd_safe::get_frame_content_size(body)
+            .ok()
+            .flatten()
+            .ok_or("Legacy preconf without zstd content size")?;
+        let decompressed_size = check_decompressed_size(usize::try_from(decompressed_size).unwrap_or(usize::MAX))?;
+        Ok(Self {
+            version: 0,
+            codec: PayloadCodec::Zstd(DEFAULT_ZSTD_LEVEL),
+            chain_id: None,
+            block_number: None,
+            decompressed_size,
+            dict_id: None,
+            delta_base: None,
+            body,
+            signature: &data[sig_start..],
+        })
+    }
+
+    /// Dekomprimierter Payload (parent_beacon_block_root + execution payload); Deltas werden über den Payload ihrer
+    /// Basis aus dem Verzeichnis der Chain rekonstruiert (siehe delta::base_payload)
+    pub fn payload(&self) -> Result<Vec<u8>, Box<dyn std::error::Error + Send + Sync>> {
+        let dict = match self.dict_id {
+            Some(id) => Some(zdict::lookup(id).ok_or_else(|| format!("zstd dictionary {:06x} not loaded", id))?),
+            None => None,
+        };
+        let base = match (self.chain_id, self.delta_base) {
+            (Some(chain_id), Some(block_number)) => Some(delta::base_payload(chain_id, block_number)?),
+            _ => None,
+        };
+        let reference = match (&dict, &base) {
+            (Some(dict), _) => Some(Reference::Dict(dict)),
+            (None, Some(base)) => Some(Reference::Delta(base)),
+            (None, None) => None,
+        };
+        self.codec
+            .decompress(self.body, self.decompressed_size, reference)
+            .map_err(|e| match self.delta_base {
+                Some(base) => format!("Cannot apply delta against block {}: {}", base, e).into(),
+                None => e,
+            })
+    }
+}
+
+/// Baut eine .raw Datei im aktuellen Container-Format, gibt (Datei, Body-Größe) zurück; `reference` nur für zstd
+pub fn encode_container(
+    codec: PayloadCodec,
+    chain_id: u64,
+    block_number: u64,
+    payload: &[u8],
+    signature: &[u8; SIGNATURE_SIZE],
+    reference: Option<Reference>,
+) -> Result<(Vec<u8>, usize), Box<dyn std::error::Error + Send + Sync>> {
+    let size = u32::try_from(payload.len()).map_err(|_| "Payload too large for container")?;
+    let reference = reference.filter(|_| matches!(codec, PayloadCodec::Zstd(_)));
+    codec.with_compressed(payload, reference, |body| {
+        let header = container_header(codec, chain_id, block_number, size, body, signature, reference)?;
+        let mut data = Vec::with_capacity(CONTAINER_HEADER_SIZE + body.len());
+        data.extend_from_slice(&header);
+        data.extend_from_slice(body);
+        Ok((data, body.len()))
+    })?
+}
+
+/// Schreibt eine .raw Datei im aktuellen Container-Format direkt nach `out` (Header, dann Body aus dem
+/// Kompressionspuffer), ohne die Datei am Stück im Speicher aufzubauen; gibt (Dateigröße, Body-Größe) zurück
+pub fn write_container(
+    codec: PayloadCodec,
+    chain_id: u64,
+    block_number: u64,
+    payload: &[u8],
+    signature: &[u8; SIGNATURE_SIZE],
+    reference: Option<Reference>,
+    out: &mut impl Write,
+) -> Result<(usize, usize), Box<dyn std::error::Error + Send + Sync>> {
+    let size = u32::try_from(payload.len()).map_err(|_| "Payload too large for container")?;
+    let reference = reference.filter(|_| matches!(codec, PayloadCodec::Zstd(_)));
+    codec.with_compressed(payload, reference, |body| {
+        let header = container_header(codec, chain_id, block_number, size, body, signature, reference)?;
+        out.write_all(&header)?;
+        out.write_all(body)?;
+        Ok((CONTAINER_HEADER_SIZE + body.len(), body.len()))
+    })?
+}
+
+/// Header einer Version-2-Datei inklusive CRC über Header und Body
+fn container_header(
+    codec: PayloadCodec,
+    chain_id: u64,
+    block_number: u64,
+    size: u32,
+    body: &[u8],
+    signature: &[u8; SIGNATURE_SIZE],
+    reference: Option<Reference>,
+) -> Result<[u8; CONTAINER_HEADER_SIZE], Box<dyn std::error::Error + Send + Sync>> {
+    let body_len = u32::try_from(body.len()).map_err(|_| "Body too large for container")?;
+    let (flags, reference) = match reference {
+        Some(Reference::Dict(dict)) => (FLAG_ZSTD_DICT, dict.id),
+        Some(Reference::Delta(_)) => (FLAG_DELTA, DELTA_DISTANCE),
+        None => (0, 0),
+    };
+
+    let mut header = [0u8; CONTAINER_HEADER_SIZE];
+    let mut data = &mut header[..];
+    data.write_all(&CONTAINER_MAGIC)?;
+    data.write_all(&[CONTAINER_VERSION, codec.id(), codec.level() as u8, flags])?;
+    data.write_all(&chain_id.to_le_bytes())?;
+    data.write_all(&block_number.to_le_bytes())?;
+    data.write_all(&size.to_le_bytes())?;
+    data.write_all(&body_len.to_le_bytes())?;
+    data.write_all(signature)?;
+    data.write_all(&reference.to_le_bytes()[..3])?;
+    debug_assert_eq!(CONTAINER_HEADER_SIZE - data.len(), V2_CRC);
+
+    let mut hasher = crc32fast::Hasher::new();
+    hasher.update(&header[..V2_CRC]);
+    hasher.update(body);
+    header[V2_CRC..].copy_from_slice(&hasher.finalize().to_le_bytes());
+    Ok(header)
+}
+
+/// Wandelt eine .raw Datei in das Legacy-Format (zstd-Body + Signatur), das der Prover erwartet
+pub fn to_legacy(data: &[u8], chain_id: u64) -> Result<Vec<u8>, Box<dyn std::error::Error + Send + Sync>> {
+    let container = PreconfContainer::parse(data)?;
+    if let Some(stored) = container.chain_id.filter(|stored| *stored != chain_id) {
+        return Err(format!("Preconf belongs to chain {}, expected {}", stored, chain_id).into());
+    }
+    if container.version == 0 {
+        return Ok(data.to_vec());
+    }
+
+    let legacy = |body: &[u8]| {
+        let mut legacy = Vec::with_capacity(body.len() + container.signature.len());
+        legacy.extend_from_slice(body);
+        legacy.extend_from_slice(container.signature);
+        legacy
+    };
+    // Der Prover kennt weder Dictionaries noch Deltas, solche Bodies werden wie andere Codecs neu komprimiert
+    match container.codec {
+        PayloadCodec::Zstd(_) if container.dict_id.is_none() && container.delta_base.is_none() => {
+            Ok(legacy(container.body))
+        }
+        _ => PayloadCodec::Zstd(DEFAULT_ZSTD_LEVEL).with_compressed(&container.payload()?, None, legacy),
+    }
+}
+
+/// Lehnt Größen über MAX_DECOMPRESSED_SIZE ab (beschädigte Datei oder fremde Antwort, etwa beim Backfill)
+fn check_decompressed_size(size: usize) -> Result<usize, Box<dyn std::error::Error + Send + Sync>> {
+    if size > MAX_DECOMPRESSED_SIZE {
+        return Err(format!("Preconf payload too large: {} bytes (max {})", size, MAX_DECOMPRESSED_SIZE).into());
+    }
+    Ok(size)
+}
+
+fn read_u32(data: &[u8], offset: usize) -> u32 {
+    u32::from_le_bytes(data[offset..offset + 4].try_into().unwrap())
+}
+
+fn read_u24(data: &[u8], offset: usize) -> u32 {
+    u32::from_le_bytes([data[offset], data[offset + 1], data[offset + 2], 0])
+}
+
+fn read_u64(data: &[u8], offset: usize) -> u64 {
+    u64::from_le_bytes(data[offset..offset + 8].try_into().unwrap())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::ssz::{tests::TestPayload, PayloadVersion};
+    use std::{fs, sync::Arc};
+
+    const CHAIN_ID: u64 = 10;
+    const SIGNATURE: [u8; SIGNATURE_SIZE] = [0x5a; SIGNATURE_SIZE];
+
+    fn payload() -> Vec<u8> {
+        TestPayload::sample(PayloadVersion::V3).encode()
+    }
+
+    /// Version-1-Datei wie vor dem 104-Byte-Header
+    fn v1_container(codec: PayloadCodec, payload: &[u8]) -> Vec<u8> {
+        let body = codec.with_compressed(payload, None, <[u8]>::to_vec).unwrap();
+        let mut data = CONTAINER_MAGIC.to_vec();
+        data.extend_from_slice(&[1, codec.id(), codec.level() as u8, 0]);
+        data.extend_from_slice(&(payload.len() as u32).to_le_bytes());
+        data.extend_from_slice(&body);
+        data.extend_from_slice(&SIGNATURE);
+        data
+    }
+
+    fn legacy_container(payload: &[u8]) -> Vec<u8> {
+        let mut data = zstd::bulk::compress(payload, DEFAULT_ZSTD_LEVEL).unwrap();
+        data.extend_from_slice(&SIGNATURE);
+        data
+    }
+
+    #[test]
+    fn v2_roundtrip() {
+        let payload = payload();
+        for codec in [PayloadCodec::None, PayloadCodec::Snappy, PayloadCodec::Zstd(3)] {
+            let (data, body_len) = encode_container(codec, CHAIN_ID, 1234, &payload, &SIGNATURE, None).unwrap();
+            assert_eq!(data.len(), CONTAINER_HEADER_SIZE + body_len);
+
+            let container = PreconfContainer::parse(&data).unwrap();
+            assert_eq!(container.version, CONTAINER_VERSION);
+            assert_eq!(container.codec, codec);
+            assert_eq!(container.chain_id, Some(CHAIN_ID));
+            assert_eq!(container.block_number, Some(1234));
+            assert_eq!(container.decompressed_size, payload.len());
+            assert_eq!(container.dict_id, None);
+            assert_eq!(container.delta_base, None);
+            assert_eq!(container.signature, &SIGNATURE[..]);
+            assert_eq!(container.payload().unwrap(), payload);
+
+            // write_container schreibt dieselben Bytes
+            let mut written = Vec::new();
+            let sizes = write_container(codec, CHAIN_ID, 1234, &payload, &SIGNATURE, None, &mut written).unwrap();
+            assert_eq!(sizes, (data.len(), body_len));
+            assert_eq!(written, data);
+        }
+    }
+
+    #[test]
+    fn v1_roundtrip() {
+        let payload = payload();
+        for codec in [PayloadCodec::None, PayloadCodec::Snappy, PayloadCodec::Zstd(3)] {
+            let data = v1_container(codec, &payload);
+            let container = PreconfContainer::parse(&data).unwrap();
+            assert_eq!(container.version, 1);
+            assert_eq!(container.codec, codec);
+            assert_eq!(container.chain_id, None);
+            assert_eq!(container.signature, &SIGNATURE[..]);
+            assert_eq!(container.payload().unwrap(), payload);
+        }
+    }
+
+    #[test]
+    fn legacy_roundtrip() {
+        let payload = payload();
+        let data = legacy_container(&payload);
+        let container = PreconfContainer::parse(&data).unwrap();
+        assert_eq!(container.version, 0);
+        assert_eq!(container.decompressed_size, payload.len());
+        assert_eq!(container.signature, &SIGNATURE[..]);
+        assert_eq!(container.payload().unwrap(), payload);
+
+        // Legacy-Dateien gehen unverändert an den Prover
+        assert_eq!(to_legacy(&data, CHAIN_ID).unwrap(), data);
+    }
+
+    #[test]
+    fn to_legacy_recompresses() {
+        let payload = payload();
+        let v1 = v1_container(PayloadCodec::Snappy, &payload);
+        for codec in [PayloadCodec::None, PayloadCodec::Snappy, PayloadCodec::Zstd(3)] {
+            let (v2, _) = encode_container(codec, CHAIN_ID, 1, &payload, &SIGNATURE, None).unwrap();
+            for data in [&v2, &v1] {
+                let legacy = to_legacy(data, CHAIN_ID).unwrap();
+                let container = PreconfContainer::parse(&legacy).unwrap();
+                assert_eq!(container.version, 0);
+                assert_eq!(container.signature, &SIGNATURE[..]);
+                assert_eq!(container.payload().unwrap(), payload);
+            }
+        }
+
+        let (v2, _) = encode_container(PayloadCodec::Zstd(1), CHAIN_ID, 1, &payload, &SIGNATURE, None).unwrap();
+        assert!(to_legacy(&v2, CHAIN_ID + 1).is_err());
+    }
+
+    #[test]
+    fn dict_roundtrip() {
+        // Raw-Content-Dictionary; eigene ID, weil die Registry prozessweit ist
+        let payload = payload();
+        let id = 0x00c0de;
+        let dir = tempfile::tempdir().unwrap();
+        fs::create_dir(dir.path().join(zdict::DICT_DIR_NAME)).unwrap();
+        let path = dir.path().join(zdict::DICT_DIR_NAME).join(format!("zstd_dict_{:06x}.dict", id));
+        fs::write(path, &payload[..payload.len() / 2]).unwrap();
+        let dict = zdict::load_dir(dir.path()).unwrap();
+        assert_eq!(dict.id, id);
+
+        let reference = Some(Reference::Dict(&dict));
+        let (data, _) = encode_container(PayloadCodec::Zstd(3), CHAIN_ID, 7, &payload, &SIGNATURE, reference).unwrap();
+        let container = PreconfContainer::parse(&data).unwrap();
+        assert_eq!(container.dict_id, Some(id));
+        assert_eq!(container.delta_base, None);
+        assert_eq!(container.payload().unwrap(), payload);
+
+        // Der Prover bekommt einen Body ohne Dictionary
+        let legacy = to_legacy(&data, CHAIN_ID).unwrap();
+        assert_eq!(PreconfContainer::parse(&legacy).unwrap().payload().unwrap(), payload);
+
+        // Nur zstd kennt Dictionaries
+        let (data, _) = encode_container(PayloadCodec::Snappy, CHAIN_ID, 7, &payload, &SIGNATURE, reference).unwrap();
+        assert_eq!(PreconfContainer::parse(&data).unwrap().dict_id, None);
+    }
+
+    #[test]
+    fn delta_roundtrip() {
+        // Eigene Chain, weil der Delta-Cache prozessweit ist
+        let chain_id = 0xde17a;
+        let base = payload();
+        let mut next = TestPayload::sample(PayloadVersion::V3);
+        next.block_number += 1;
+        next.timestamp += 2;
+        let payload = next.encode();
+        delta::remember(chain_id, 99, Arc::new(base.clone()));
+
+        let reference = Some(Reference::Delta(&base));
+        let (data, _) =
+            encode_container(PayloadCodec::Zstd(3), chain_id, 100, &payload, &SIGNATURE, reference).unwrap();
+        let container = PreconfContainer::parse(&data).unwrap();
+        assert_eq!(container.delta_base, Some(99));
+        assert_eq!(container.dict_id, None);
+        assert_eq!(container.payload().unwrap(), payload);
+
+        let legacy = to_legacy(&data, chain_id).unwrap();
+        assert_eq!(PreconfContainer::parse(&legacy).unwrap().payload().unwrap(), payload);
+
+        // Andere Basis: die Frame-Prüfsumme schlägt an
+        delta::remember(chain_id, 99, Arc::new(vec![0xff; base.len()]));
+        assert!(PreconfContainer::parse(&data).unwrap().payload().is_err());
+    }
+
+    #[test]
+    fn rejects_oversized_payloads() {
+        // Header mit gültiger CRC, aber decompressed_size über der Obergrenze
+        let payload = payload();
+        let (mut data, _) = encode_container(PayloadCodec::None, CHAIN_ID, 1, &payload, &SIGNATURE, None).unwrap();
+        data[V2_DECOMPRESSED_SIZE..V2_DECOMPRESSED_SIZE + 4].copy_from_slice(&u32::MAX.to_le_bytes());
+        let mut hasher = crc32fast::Hasher::new();
+        hasher.update(&data[..V2_CRC]);
+        hasher.update(&data[CONTAINER_HEADER_SIZE..]);
+        let crc = hasher.finalize().to_le_bytes();
+        data[V2_CRC..CONTAINER_HEADER_SIZE].copy_from_slice(&crc);
+        assert!(PreconfContainer::parse(&data).err().unwrap().to_string().contains("too large"));
+
+        let mut v1 = v1_container(PayloadCodec::Zstd(1), &payload);
+        v1[8..12].copy_from_slice(&(MAX_DECOMPRESSED_SIZE as u32 + 1).to_le_bytes());
+        assert!(PreconfContainer::parse(&v1).is_err());
+
+        // Legacy: zstd-Frame (Single Segment, 8-Byte-Content-Size, leerer Raw-Block) mit riesiger Content-Size
+        let mut frame = vec![0x28, 0xb5, 0x2f, 0xfd, 0xe0];
+        frame.extend_from_slice(&(MAX_DECOMPRESSED_SIZE as u64 + 1).to_le_bytes());
+        frame.extend_from_slice(&[0x01, 0x00, 0x00]);
+        frame.extend_from_slice(&SIGNATURE);
+        assert!(PreconfContainer::parse(&frame).err().unwrap().to_string().contains("too large"));
+
+        for codec in [PayloadCodec::None, PayloadCodec::Snappy, PayloadCodec::Zstd(1)] {
+            assert!(codec.decompress(&payload, MAX_DECOMPRESSED_SIZE + 1, None).is_err());
+        }
+        assert!(PayloadCodec::Snappy.decompress(&payload, payload.len() + 1, None).is_err());
+    }
+
+    #[test]
+    fn rejects_corrupt_containers() {
+        let payload = payload();
+        let (data, _) = encode_container(PayloadCodec::Zstd(1), CHAIN_ID, 1, &payload, &SIGNATURE, None).unwrap();
+
+        let mut corrupt = data.clone();
+        *corrupt.last_mut().unwrap() ^= 1;
+        assert!(PreconfContainer::parse(&corrupt).is_err());
+
+        let mut corrupt = data.clone();
+        corrupt[V2_BLOCK_NUMBER] ^= 1;
+        assert!(PreconfContainer::parse(&corrupt).is_err());
+
+        assert!(PreconfContainer::parse(&data[..CONTAINER_HEADER_SIZE - 1]).is_err());
+        assert!(PreconfContainer::parse(&data[..data.len() - 1]).is_err());
+
+        let mut unknown = data.clone();
+        unknown[4] = 9;
+        assert!(PreconfContainer::parse(&unknown).is_err());
+
+        assert!(PreconfContainer::parse(&SIGNATURE).is_err());
+    }
+}
@@ -0,0 +1,71 @@
+// confidence.rs - Vertrauenswert eines gespeicherten Preconfs (Metadaten "confidence", 0-100)
+//
+// Der Wert setzt sich aus vier Anteilen zusammen, die einzeln unter "confidence_factors" stehen:
+//   signature   40  Signer gegen KONA_BRIDGE_SIGNERS bzw. SystemConfig bestätigt; ohne Signer-Prüfung die Hälfte,
+//                   signature_valid=false (Modus warn/flag) 0
+//   sources     25  unabhängige Quellen in "sources" (siehe provenance.rs), ab MAX_SOURCES voll
+//   gossip      15  verbundene Gossip-Peers beim Empfang, ab MAX_GOSSIP_PEERS voll; nur wenn Gossip eine der Quellen
+//                   ist. kona-p2p meldet nicht, welche Peers eine Nachricht weitergereicht haben, gezählt wird daher
+//                   die Anzahl verbundener Peers
+//   timestamp   20  Payload-Timestamp beim ersten Empfang innerhalb von KONA_BRIDGE_MAX_FUTURE_SECS/_MAX_PAST_SECS;
+//                   unbekannter Timestamp die Hälfte
+// Berechnet wird beim Speichern und erneut, wenn eine weitere Quelle denselben Block liefert. Die Serving-API filtert
+// mit ?min_confidence=N (siehe serve.rs).
+
+use crate::{settings::ValidationSettings, validation::check_timestamp};
+
+const SIGNATURE_WEIGHT: u64 = 40;
+const SOURCES_WEIGHT: u64 = 25;
+const GOSSIP_WEIGHT: u64 = 15;
+const TIMESTAMP_WEIGHT: u64 = 20;
+
+const MAX_SOURCES: u64 = 3;
+const MAX_GOSSIP_PEERS: u64 = 4;
+
+/// Setzt "confidence" und "confidence_factors"; `gossip_peers`: aktuell verbundene Gossip-Peers
+pub fn apply(metadata: &mut serde_json::Value, gossip_peers: u32, validation: &ValidationSettings) {
+    let signature = if metadata["signature_valid"] == serde_json::json!(false) {
+        0
+    } else if metadata["signer_match"].is_string() {
+        SIGNATURE_WEIGHT
+    } else {
+        SIGNATURE_WEIGHT / 2
+    };
+
+    let sources: Vec<&str> = metadata["sources"]
+        .as_array()
+        .map(|entries| entries.iter().filter_map(|entry| entry["source"].as_str()).collect())
+        .unwrap_or_default();
+    let source_count = (sources.len() as u64).max(1);
+    let source_score = SOURCES_WEIGHT * source_count.min(MAX_SOURCES) / MAX_SOURCES;
+
+    // Bei einem Merge zählen die Peers vom ersten Empfang über Gossip
+    let peers = match metadata["confidence_factors"]["gossip_peers"].as_u64() {
+        Some(recorded) if recorded > 0 => recorded,
+        _ => u64::from(gossip_peers),
+    };
+    let gossip = sources.contains(&"gossip");
+    let gossip_score = if gossip { GOSSIP_WEIGHT * peers.min(MAX_GOSSIP_PEERS) / MAX_GOSSIP_PEERS } else { 0 };
+
+    let timestamp = metadata["timestamp"].as_u64().unwrap_or(0);
+    let received = metadata["received_unix"].as_u64().unwrap_or(0);
+    let plausible = check_timestamp(timestamp, received, validation).is_ok();
+    let timestamp_score = match (timestamp, plausible) {
+        (0, _) => TIMESTAMP_WEIGHT / 2,
+        (_, true) => TIMESTAMP_WEIGHT,
+        (_, false) => 0,
+    };
+
+    metadata["confidence"] = serde_json::json!(signature + source_score + gossip_score + timestamp_score);
+    metadata["confidence_factors"] = serde_json::json!({
+        "signature": signature,
+        "sources": source_count,
+        "gossip_peers": if gossip { peers } else { 0 },
+        "timestamp_plausible": plausible && timestamp > 0,
+    });
+}
+
+/// Vertrauenswert aus gespeicherten Metadaten (ohne "confidence", etwa ältere Blöcke: 0)
+pub fn of(metadata: &serde_json::Value) -> u64 {
+    metadata["confidence"].as_u64().unwrap_or(0)
+}
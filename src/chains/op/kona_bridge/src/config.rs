@@ -1,7 +1,10 @@
 // config.rs - Chain-Konfigurationen für OP-Stack-Chains
 
+use crate::registry;
 use alloy::primitives::{address, Address};
 use discv5::Enr;
+use kona_genesis::RollupConfig;
+use tokio::sync::watch;
 
 /// Chain-spezifische Konfiguration
 #[derive(Clone)]
@@ -9,9 +12,39 @@ pub struct ChainConfig {
     pub unsafe_signer: Address,
     pub chain_id: u64,
     pub bootnodes: Vec<Enr>,
+    pub signer_updates: Option<watch::Receiver<Address>>, // Live-Signer aus SystemConfig (signer.rs)
+    pub rollup_config: Option<RollupConfig>, // aus rollup.json (rollup.rs), sonst kona-registry
+}
+
+/// Chains mit eigenem Eintrag (network_name, Signer, HTTP-Quelle)
+pub const KNOWN_CHAIN_IDS: [u64; 5] = [10, 8453, 130, 480, 7777777];
+
+/// Netzwerk-Name zu einer bekannten Chain-ID
+pub fn network_name(chain_id: u64) -> Option<&'static str> {
+    match chain_id {
+        10 => Some("op-mainnet"),
+        8453 => Some("base"),
+        130 => Some("unichain"),
+        480 => Some("worldchain"),
+        7777777 => Some("zora"),
+        _ => None,
+    }
+}
+
+/// Chain-ID zu einem Netzwerk-Namen (Umkehrung von network_name, sonst Name aus der Superchain-Registry)
+pub fn chain_id_for_network(network: &str) -> Option<u64> {
+    KNOWN_CHAIN_IDS
+        .into_iter()
+        .find(|chain_id| network_name(*chain_id) == Some(network))
+        .or_else(|| registry::chain_id_by_name(network))
 }
 
 impl ChainConfig {
+    /// Aktueller Unsafe-Signer: live aus SystemConfig, sonst der statische Wert
+    pub fn current_signer(&self) -> Address {
+        self.signer_updates.as_ref().map_or(self.unsafe_signer, |updates| *updates.borrow())
+    }
+
     /// HTTP-Endpoint für die Chain abrufen
     pub fn get_http_endpoint(&self) -> Option<String> {
         // Get HTTP endpoint from centralized chain configuration
@@ -38,6 +71,45 @@ impl ChainConfig {
         }
     }
 
+    /// Chain-Konfiguration zur Chain-ID: eigene Einträge, sonst Superchain-Registry, sonst ohne Signer und Bootnodes
+    pub fn for_chain(chain_id: u64) -> Self {
+        match network_name(chain_id) {
+            Some(network) => Self::from(network, chain_id),
+            None => Self::from_registry(chain_id).unwrap_or_else(|| Self::from("custom", chain_id)),
+        }
+    }
+
+    /// Chain-Konfiguration aus der Superchain-Registry (None für unbekannte Chains)
+    pub fn from_registry(chain_id: u64) -> Option<Self> {
+        let chain = registry::lookup(chain_id)?;
+        Some(ChainConfig {
+            unsafe_signer: chain.unsafe_signer.unwrap_or(Address::ZERO), // ZERO: Signer aus C config bzw. SystemConfig
+            chain_id,
+            bootnodes: Self::superchain_bootnodes(chain.l1_chain_id),
+            signer_updates: None,
+            rollup_config: None,
+        })
+    }
+
+    /// Chain-Konfiguration aus einer rollup.json; Signer und Bootnodes wie bei for_chain, sofern bekannt
+    pub fn from_rollup(rollup: RollupConfig) -> Self {
+        let mut config = Self::for_chain(rollup.l2_chain_id);
+        if config.bootnodes.is_empty() {
+            config.bootnodes = Self::superchain_bootnodes(rollup.l1_chain_id);
+        }
+        config.rollup_config = Some(rollup);
+        config
+    }
+
+    /// Die Registry führt keine Bootnodes; Mainnet-Chains teilen sich das Discovery-Netz der Superchain
+    fn superchain_bootnodes(l1_chain_id: u64) -> Vec<Enr> {
+        if l1_chain_id == registry::L1_MAINNET {
+            Self::from("op-mainnet", 10).bootnodes
+        } else {
+            Vec::new()
+        }
+    }
+
     /// Chain-Konfiguration aus Netzwerk-Name erstellen
     pub fn from(network: &str, chain_id: u64) -> Self {
         match network {
@@ -52,6 +124,8 @@ impl ChainConfig {
                 .iter()
                 .map(|v| v.parse().unwrap())
                 .collect(),
+                signer_updates: None,
+                rollup_config: None,
             },
             "base" => ChainConfig {
                 unsafe_signer: address!("Af6E19BE0F9cE7f8afd49a1824851023A8249e8a"),
@@ -64,6 +138,8 @@ impl ChainConfig {
                 .iter()
                 .map(|v| v.parse().unwrap())
                 .collect(),
+                signer_updates: None,
+                rollup_config: None,
             },
             "unichain" => ChainConfig {
                 unsafe_signer: address!("833C6f278474A78658af91aE8edC926FE33a230e"),
@@ -75,16 +151,22 @@ impl ChainConfig {
                 .iter()
                 .map(|v| v.parse().unwrap())
                 .collect(),
+                signer_updates: None,
+                rollup_config: None,
             },
             "worldchain" => ChainConfig {
                 unsafe_signer: address!("2270d6eC8E760daA317DD978cFB98C8f144B1f3A"),
                 chain_id: 480,
                 bootnodes: Vec::new(),
+                signer_updates: None,
+                rollup_config: None,
             },
             "zora" => ChainConfig {
                 unsafe_signer: address!("3Dc8Dfd070C835cAd15a6A27e089FF4cF4C92280"),
                 chain_id: 7777777,
                 bootnodes: Vec::new(),
+                signer_updates: None,
+                rollup_config: None,
             },
             _ => {
                 // Use provided chain_id for custom networks
@@ -92,6 +174,8 @@ impl ChainConfig {
                     unsafe_signer: Address::ZERO, // Will be configured from C config
                     chain_id,
                     bootnodes: Vec::new(),
+                    signer_updates: None,
+                    rollup_config: None,
                 }
             }
         }
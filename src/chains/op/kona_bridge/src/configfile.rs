@@ -0,0 +1,176 @@
+// configfile.rs - Konfigurationsdatei (TOML) statt langer Listen von KONA_BRIDGE_* Umgebungsvariablen
+//
+// KONA_BRIDGE_CONFIG=/etc/kona-bridge.toml. Schlüssel sind die Namen der Umgebungsvariablen ohne Prefix in
+// Kleinbuchstaben, Tabellen werden mit '_' vorangestellt:
+//   codec = "zstd:3"                  -> KONA_BRIDGE_CODEC
+//   [s3] bucket = "preconfs"          -> KONA_BRIDGE_S3_BUCKET
+//   [chains.8453] ttl_minutes = 60    -> KONA_BRIDGE_TTL_MINUTES_8453, für Chain 8453 auch KONA_BRIDGE_TTL_MINUTES
+// Werte: Strings, Zahlen, Booleans (true = 1) und Listen (kommagetrennt, z.B. signers oder hook_url).
+//
+// Vorrang: gesetzte Umgebungsvariable > Abschnitt der Chain > globaler Wert der Datei. Variablen, die der C-Server
+// aus seinen Flags setzt (z.B. KONA_BRIDGE_DURABILITY), überschreiben also die Datei. Die Datei wird beim ersten
+// Zugriff gelesen und auf SIGHUP erneut (siehe reload.rs); ist sie nicht lesbar oder kein gültiges TOML, wird sie
+// mit Warnung ignoriert bzw. bleibt der zuletzt gelesene Stand aktiv.
+
+use std::{
+    cell::Cell,
+    collections::HashMap,
+    path::Path,
+    sync::{Arc, OnceLock, RwLock},
+};
+use tracing::{info, warn};
+
+const ENV_PREFIX: &str = "KONA_BRIDGE";
+
+static CONFIG: OnceLock<RwLock<Option<Arc<ConfigFile>>>> = OnceLock::new();
+
+thread_local! {
+    /// Chain, deren Abschnitt gerade gilt (gesetzt von BridgeSettings::from_env)
+    static CHAIN: Cell<Option<u64>> = const { Cell::new(None) };
+}
+
+/// Werte der Datei, bereits unter den Namen der Umgebungsvariablen
+#[derive(Debug, Default)]
+struct ConfigFile {
+    global: HashMap<String, String>,
+    chains: HashMap<u64, HashMap<String, String>>,
+}
+
+/// Aktiviert den Abschnitt einer Chain bis zum Drop
+pub struct ChainScope {
+    previous: Option<u64>,
+}
+
+impl ChainScope {
+    pub fn enter(chain_id: u64) -> Self {
+        Self { previous: CHAIN.with(|chain| chain.replace(Some(chain_id))) }
+    }
+}
+
+impl Drop for ChainScope {
+    fn drop(&mut self) {
+        CHAIN.with(|chain| chain.set(self.previous));
+    }
+}
+
+/// Wert aus der Datei für eine Umgebungsvariable (None ohne Datei oder Eintrag)
+pub fn lookup(name: &str) -> Option<String> {
+    let config = CONFIG.get_or_init(|| RwLock::new(read_config())).read().ok()?.clone()?;
+    let chain_id = CHAIN.with(|chain| chain.get());
+    chain_id
+        .and_then(|chain_id| config.chains.get(&chain_id))
+        .and_then(|values| values.get(name))
+        .or_else(|| config.global.get(name))
+        .cloned()
+}
+
+/// Chains mit eigenem Abschnitt [chains.<id>] (aufsteigend), z.B. für `kona_bridge capture` ohne --chain-id
+pub fn chain_ids() -> Vec<u64> {
+    let config = CONFIG.get_or_init(|| RwLock::new(read_config())).read().ok().and_then(|config| config.clone());
+    let mut chain_ids: Vec<u64> = config.map(|config| config.chains.keys().copied().collect()).unwrap_or_default();
+    chain_ids.sort_unstable();
+    chain_ids
+}
+
+/// Liest die Datei erneut; bei Fehlern bleibt der bisherige Stand aktiv
+pub fn reload() {
+    let Some(path) = config_path() else {
+        return;
+    };
+    let lock = CONFIG.get_or_init(|| RwLock::new(None));
+    match load(Path::new(&path)) {
+        Ok(config) => {
+            if let Ok(mut current) = lock.write() {
+                *current = Some(Arc::new(config));
+            }
+        }
+        Err(e) => warn!("⚠️  Cannot reload configuration file {}, keeping previous values: {}", path, e),
+    }
+}
+
+/// Pfad aus KONA_BRIDGE_CONFIG (None = keine Datei)
+pub fn config_path() -> Option<String> {
+    // Nicht über env_string, das selbst hier nachschlägt
+    std::env::var("KONA_BRIDGE_CONFIG").ok().filter(|path| !path.trim().is_empty())
+}
+
+/// Liest die Datei zur Prüfung (z.B. `kona_bridge doctor`), ohne den aktiven Stand zu ändern; Ok = Anzahl Werte
+pub fn validate(path: &Path) -> Result<usize, Box<dyn std::error::Error + Send + Sync>> {
+    let config = load(path)?;
+    Ok(config.global.len())
+}
+
+fn read_config() -> Option<Arc<ConfigFile>> {
+    let path = config_path()?;
+    match load(Path::new(&path)) {
+        Ok(config) => {
+            info!(
+                "📄 Loaded configuration file {} ({} values, {} chain sections)",
+                path,
+                config.global.len(),
+                config.chains.len()
+            );
+            Some(Arc::new(config))
+        }
+        Err(e) => {
+            warn!("⚠️  Ignoring configuration file {}: {}", path, e);
+            None
+        }
+    }
+}
+
+fn load(path: &Path) -> Result<ConfigFile, Box<dyn std::error::Error + Send + Sync>> {
+    let mut table: toml::Table = std::fs::read_to_string(path)?.parse()?;
+    let mut config = ConfigFile::default();
+
+    if let Some(chains) = table.remove("chains") {
+        let toml::Value::Table(chains) = chains else {
+            return Err("chains must be a table of [chains.<chain_id>] sections".into());
+        };
+        for (key, section) in chains {
+            let chain_id: u64 = key.parse().map_err(|_| format!("invalid chain id in [chains.{}]", key))?;
+            let toml::Value::Table(section) = section else {
+                return Err(format!("chains.{} must be a table", key).into());
+            };
+            let mut values = HashMap::new();
+            flatten(ENV_PREFIX, &section, &mut values);
+            // Auch unter den chain-spezifischen Namen (KONA_BRIDGE_QUOTA_MB_8453 usw.)
+            for (name, value) in &values {
+                config.global.insert(format!("{}_{}", name, chain_id), value.clone());
+            }
+            config.chains.insert(chain_id, values);
+        }
+    }
+
+    flatten(ENV_PREFIX, &table, &mut config.global);
+    Ok(config)
+}
+
+fn flatten(prefix: &str, table: &toml::Table, values: &mut HashMap<String, String>) {
+    for (key, value) in table {
+        let name = format!("{}_{}", prefix, key.to_ascii_uppercase().replace('-', "_"));
+        if let toml::Value::Table(inner) = value {
+            flatten(&name, inner, values);
+            continue;
+        }
+        match scalar(value) {
+            Some(value) => {
+                values.insert(name, value);
+            }
+            None => warn!("⚠️  Ignoring unsupported value for {} in configuration file", name),
+        }
+    }
+}
+
+/// Wert so, wie er in der Umgebungsvariable stehen würde
+fn scalar(value: &toml::Value) -> Option<String> {
+    match value {
+        toml::Value::String(value) => Some(value.clone()),
+        toml::Value::Integer(value) => Some(value.to_string()),
+        toml::Value::Float(value) => Some(value.to_string()),
+        toml::Value::Boolean(value) => Some(if *value { "1" } else { "0" }.to_string()),
+        toml::Value::Datetime(value) => Some(value.to_string()),
+        toml::Value::Array(items) => items.iter().map(scalar).collect::<Option<Vec<_>>>().map(|items| items.join(",")),
+        toml::Value::Table(_) => None,
+    }
+}
@@ -0,0 +1,141 @@
+// continuity.rs - Lücken und Rücksprünge in der Folge der erfassten Blöcke (KONA_BRIDGE_GAP_TOLERANCE)
+//
+// Je Chain merkt sich der Store den höchsten erfassten Block (beim Start aus dem Index, Ausfallzeiten fallen also
+// auf); erwartet wird dessen Nachfolger. Erfasst heißt geprüft und nicht nur eine weitere Quelle desselben Blocks,
+// Sampling ändert daran nichts, Backfills zählen nicht. Außerhalb der Toleranz (Blöcke, Default 0) gilt:
+//   gap         der Block liegt über dem erwarteten, missing_from..=missing_to fehlen
+//   backwards   der Block liegt unter dem bisher höchsten (ein Reorg derselben Höhe ist kein Rücksprung)
+// Beides steht als strukturiertes Log-Ereignis (Felder event, expected/highest, received, missing/behind) im Log,
+// zählt als gaps bzw. backwards in den Speicher-Statistiken und geht als gleichnamiges Hook-Ereignis raus. Mit
+// KONA_BRIDGE_GAP_BACKFILL=1 lädt ein Hintergrund-Task die fehlenden Blöcke von KONA_BRIDGE_BACKFILL_URL nach (wie
+// POST /admin/chains/{chain_id}/backfill, höchstens die neuesten MAX_BACKFILL_BLOCKS einer Lücke); nach einem Neustart
+// zuerst die Blöcke zwischen dem Checkpoint (siehe checkpoint.rs) und dem Head der Quelle.
+
+use crate::{
+    admin::{run_backfill, source_head, MAX_BACKFILL_BLOCKS},
+    storage::PreconfStore,
+};
+use std::{
+    collections::VecDeque,
+    sync::{Arc, Mutex},
+    time::Duration,
+};
+use tokio::sync::Notify;
+use tracing::{info, warn};
+
+pub const DEFAULT_GAP_TOLERANCE: u64 = 0;
+
+/// Abweichung von der erwarteten Folge
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Discontinuity {
+    Gap { expected: u64, received: u64 },      // expected..received fehlen
+    Backwards { highest: u64, received: u64 }, // received liegt unter dem höchsten erfassten Block
+}
+
+impl Discontinuity {
+    /// Name des Hook-Ereignisses
+    pub fn event(&self) -> &'static str {
+        match self {
+            Discontinuity::Gap { .. } => "gap",
+            Discontinuity::Backwards { .. } => "backwards",
+        }
+    }
+
+    pub fn details(&self) -> serde_json::Value {
+        match *self {
+            Discontinuity::Gap { expected, received } => serde_json::json!({
+                "expected": expected,
+                "missing_from": expected,
+                "missing_to": received - 1,
+                "missing": received - expected,
+            }),
+            Discontinuity::Backwards { highest, received } => serde_json::json!({
+                "highest": highest,
+                "behind": highest - received,
+            }),
+        }
+    }
+}
+
+/// Erwartete Blocknummer einer Chain und ausstehende Backfills
+pub struct ContinuityMonitor {
+    tolerance: u64,
+    backfill: bool,
+    highest: Mutex<Option<u64>>,
+    pending: Mutex<VecDeque<(u64, u64)>>, // fehlende Bereiche from..=to für run_gap_backfill
+    queued: Notify,
+}
+
+impl ContinuityMonitor {
+    /// `highest`: neuester Block im Index beim Start
+    pub fn new(tolerance: u64, backfill: bool, highest: Option<u64>) -> Self {
+        Self {
+            tolerance,
+            backfill,
+            highest: Mutex::new(highest),
+            pending: Mutex::new(VecDeque::new()),
+            queued: Notify::new(),
+        }
+    }
+
+    /// Vermerkt einen erfassten Block; Some bei einer Abweichung außerhalb der Toleranz (Lücken werden dann auch
+    /// für den Backfill vorgemerkt)
+    pub fn observe(&self, block_number: u64) -> Option<Discontinuity> {
+        let mut highest = self.highest.lock().unwrap();
+        let Some(last) = *highest else {
+            *highest = Some(block_number);
+            return None;
+        };
+        if block_number <= last {
+            return (last - block_number > self.tolerance)
+                .then_some(Discontinuity::Backwards { highest: last, received: block_number });
+        }
+
+        *highest = Some(block_number);
+        let expected = last + 1;
+        if block_number - expected <= self.tolerance {
+            return None;
+        }
+        if self.backfill {
+            self.queue_backfill(expected, block_number - 1);
+        }
+        Some(Discontinuity::Gap { expected, received: block_number })
+    }
+
+    /// Merkt from..=to für run_gap_backfill vor (höchstens die neuesten MAX_BACKFILL_BLOCKS)
+    fn queue_backfill(&self, from: u64, to: u64) {
+        let from = from.max((to + 1).saturating_sub(MAX_BACKFILL_BLOCKS));
+        self.pending.lock().unwrap().push_back((from, to));
+        self.queued.notify_one();
+    }
+
+    fn take_backfill(&self) -> Option<(u64, u64)> {
+        self.pending.lock().unwrap().pop_front()
+    }
+}
+
+/// Lädt gemeldete Lücken von der Serving-API einer anderen Bridge nach (KONA_BRIDGE_GAP_BACKFILL)
+pub async fn run_gap_backfill(store: Arc<PreconfStore>, source: String, running: Arc<Mutex<bool>>) {
+    let monitor = store.continuity();
+    info!("🕳️  Backfilling gaps of chain {} from {}", store.chain_id(), source);
+
+    // Nach einem Neustart nicht auf die nächste Live-Nachricht warten: Checkpoint bis Head der Quelle nachladen
+    if let Some(highest) = store.checkpoint_highest() {
+        match source_head(&source, store.chain_id()).await {
+            Ok(head) if head > highest => {
+                info!("📍 Backfilling blocks {} - {} missed since the checkpoint", highest + 1, head);
+                monitor.queue_backfill(highest + 1, head);
+            }
+            Ok(_) => {}
+            Err(e) => warn!("⚠️  Cannot determine head of {} for the restart backfill: {}", source, e),
+        }
+    }
+    while *running.lock().unwrap() {
+        match monitor.take_backfill() {
+            Some((from, to)) => run_backfill(&store, &source, from, to).await,
+            None => {
+                let _ = tokio::time::timeout(Duration::from_secs(1), monitor.queued.notified()).await;
+            }
+        }
+    }
+}
@@ -0,0 +1,338 @@
+// debug.rs - Laufzeit-Diagnose über einen Debug-Listener nur auf localhost (KONA_BRIDGE_DEBUG_ADDR)
+//
+//   GET /debug/vars                        Bridge- und Speicher-Statistiken aller Chains, Prozess-Speicher, Uptime
+//   GET /debug/runtime                     Tokio-Metriken je Chain-Runtime (Worker, lebende Tasks, Global Queue)
+//   GET /debug/threads                     Threads des Prozesses mit Name, Zustand und CPU-Zeit (Linux)
+//   GET /debug/pprof/profile?seconds=30    CPU-Profil im pprof-Format (go tool pprof), format=flamegraph als SVG (Unix)
+//
+// Gedacht, um Speicherwachstum und hängende bzw. auflaufende Tasks in lang laufenden Captures in Produktion zu
+// untersuchen. Der Listener bindet ausschließlich an Loopback-Adressen (sonst wird er nicht gestartet) und läuft
+// wie die Serving-API einmal pro Prozess in einem eigenen Thread; die Chains registrieren sich beim Start.
+
+use crate::{handoff, storage::PreconfStore, types::KonaBridgeStats};
+use axum::{
+    extract::Query,
+    http::{header, StatusCode},
+    response::{IntoResponse, Json, Response},
+    routing::get,
+    Router,
+};
+use serde::Deserialize;
+use std::{
+    collections::HashMap,
+    net::SocketAddr,
+    sync::{
+        atomic::{AtomicBool, Ordering},
+        Arc, Mutex, OnceLock, RwLock,
+    },
+    time::{Duration, Instant, SystemTime, UNIX_EPOCH},
+};
+use tracing::{error, info, warn};
+
+/// Default-Dauer eines CPU-Profils
+const DEFAULT_PROFILE_SECS: u64 = 30;
+/// Obergrenze, damit ein vergessener Request den Profiler nicht ewig belegt
+const MAX_PROFILE_SECS: u64 = 300;
+/// Abtastrate des CPU-Profilers (Hz)
+#[cfg(unix)]
+const PROFILE_FREQUENCY: i32 = 99;
+
+/// Zustand einer Chain für die Debug-Endpoints
+struct DebugChain {
+    runtime: tokio::runtime::Handle,
+    stats: Arc<Mutex<KonaBridgeStats>>,
+    store: Arc<PreconfStore>,
+}
+
+/// Registrierte Chains nach Chain-ID
+static CHAINS: OnceLock<RwLock<HashMap<u64, Arc<DebugChain>>>> = OnceLock::new();
+/// Adresse des laufenden Listeners (einmal pro Prozess gestartet)
+static LISTENER: OnceLock<SocketAddr> = OnceLock::new();
+/// Start des Prozesses (erste Registrierung)
+static STARTED: OnceLock<Instant> = OnceLock::new();
+/// Läuft gerade ein CPU-Profil? (der Profiler ist prozessweit)
+static PROFILING: AtomicBool = AtomicBool::new(false);
+
+fn chains() -> &'static RwLock<HashMap<u64, Arc<DebugChain>>> {
+    CHAINS.get_or_init(|| RwLock::new(HashMap::new()))
+}
+
+fn registered() -> Vec<(u64, Arc<DebugChain>)> {
+    let mut chains: Vec<_> = match chains().read() {
+        Ok(chains) => chains.iter().map(|(chain_id, chain)| (*chain_id, chain.clone())).collect(),
+        Err(_) => Vec::new(),
+    };
+    chains.sort_unstable_by_key(|(chain_id, _)| *chain_id);
+    chains
+}
+
+/// Nimmt die Chain (mit dem Runtime des aufrufenden Tasks) in die Debug-Endpoints auf, startet den Listener
+/// beim ersten Aufruf und entfernt die Chain wieder, sobald `running` false wird
+pub fn register(
+    addr: SocketAddr,
+    chain_id: u64,
+    stats: Arc<Mutex<KonaBridgeStats>>,
+    store: Arc<PreconfStore>,
+    running: Arc<Mutex<bool>>,
+) {
+    if !addr.ip().is_loopback() {
+        warn!("⚠️  Debug listener disabled: {} is not a loopback address", addr);
+        return;
+    }
+    STARTED.get_or_init(Instant::now);
+
+    let chain = DebugChain { runtime: tokio::runtime::Handle::current(), stats, store };
+    if let Ok(mut chains) = chains().write() {
+        chains.insert(chain_id, Arc::new(chain));
+    }
+
+    let listening = *LISTENER.get_or_init(|| {
+        start_listener(addr);
+        addr
+    });
+    if listening != addr {
+        warn!("⚠️  Debug listener already started on {}, chain {} is available there", listening, chain_id);
+    }
+
+    tokio::spawn(async move {
+        while *running.lock().unwrap() {
+            tokio::time::sleep(Duration::from_secs(1)).await;
+        }
+        if let Ok(mut chains) = chains().write() {
+            chains.remove(&chain_id);
+        }
+    });
+}
+
+fn start_listener(addr: SocketAddr) {
+    std::thread::spawn(move || {
+        let rt = match tokio::runtime::Builder::new_current_thread().thread_name("kona-debug").enable_all().build() {
+            Ok(rt) => rt,
+            Err(e) => {
+                error!("❌ Failed to create runtime for the debug listener: {}", e);
+                return;
+            }
+        };
+        rt.block_on(async move {
+            let listener = match handoff::bind_tcp(addr).await {
+                Ok(listener) => listener,
+                Err(e) => {
+                    warn!("⚠️  Debug listener disabled, cannot bind {}: {}", addr, e);
+                    return;
+                }
+            };
+            info!("🩺 Debug listener on http://{}", addr);
+            if let Err(e) = axum::serve(listener, router()).with_graceful_shutdown(handoff::handed_over()).await {
+                warn!("⚠️  Debug listener stopped: {}", e);
+            }
+        });
+    });
+}
+
+fn router() -> Router {
+    Router::new()
+        .route("/debug/vars", get(get_vars))
+        .route("/debug/runtime", get(get_runtime))
+        .route("/debug/threads", get(get_threads))
+        .route("/debug/pprof/profile", get(get_profile))
+}
+
+fn error_response(status: StatusCode, message: impl ToString) -> Response {
+    (status, Json(serde_json::json!({ "error": message.to_string() }))).into_response()
+}
+
+fn bridge_stats_json(stats: &KonaBridgeStats) -> serde_json::Value {
+    serde_json::json!({
+        "connected_peers": stats.connected_peers,
+        "received_preconfs": stats.received_preconfs,
+        "processed_preconfs": stats.processed_preconfs,
+        "failed_preconfs": stats.failed_preconfs,
+        "http_received": stats.http_received,
+        "http_processed": stats.http_processed,
+        "gossip_received": stats.gossip_received,
+        "gossip_processed": stats.gossip_processed,
+        "mode_switches": stats.mode_switches,
+        "current_mode": stats.current_mode,
+        "total_gaps": stats.total_gaps,
+        "http_gaps": stats.http_gaps,
+        "gossip_gaps": stats.gossip_gaps,
+        "bitmask_gaps": stats.bitmask_gaps,
+    })
+}
+
+/// Zeilen "Key: Wert" aus /proc/self/status (VmRSS, VmHWM, Threads, ...)
+#[cfg(target_os = "linux")]
+fn process_status() -> serde_json::Value {
+    const FIELDS: [&str; 6] = ["VmRSS", "VmHWM", "VmSize", "VmData", "RssAnon", "Threads"];
+    let mut status = serde_json::Map::new();
+    if let Ok(content) = std::fs::read_to_string("/proc/self/status") {
+        for line in content.lines() {
+            let Some((key, value)) = line.split_once(':') else { continue };
+            if FIELDS.contains(&key) {
+                status.insert(key.to_string(), serde_json::Value::String(value.trim().to_string()));
+            }
+        }
+    }
+    serde_json::Value::Object(status)
+}
+
+#[cfg(not(target_os = "linux"))]
+fn process_status() -> serde_json::Value {
+    serde_json::Value::Null
+}
+
+/// Entspricht expvar: alle Zähler auf einen Blick
+async fn get_vars() -> Response {
+    let mut vars = serde_json::Map::new();
+    for (chain_id, chain) in registered() {
+        let bridge = bridge_stats_json(&chain.stats.lock().unwrap());
+        let storage_stats = chain.store.storage_stats();
+        // Verzeichnis-Scan blockiert
+        let storage = match tokio::task::spawn_blocking(move || storage_stats.snapshot()).await {
+            Ok(snapshot) => serde_json::to_value(snapshot).unwrap_or(serde_json::Value::Null),
+            Err(e) => serde_json::json!({ "error": e.to_string() }),
+        };
+        let now = SystemTime::now().duration_since(UNIX_EPOCH).map_or(0, |d| d.as_secs());
+        let lag = chain.store.capture_lag(now);
+        vars.insert(
+            chain_id.to_string(),
+            serde_json::json!({ "bridge": bridge, "storage": storage, "capture_lag": lag }),
+        );
+    }
+    let uptime = STARTED.get().map(|started| started.elapsed().as_secs()).unwrap_or(0);
+    Json(serde_json::json!({
+        "uptime_secs": uptime,
+        "process": process_status(),
+        "chains": vars,
+    }))
+    .into_response()
+}
+
+/// Metriken der Tokio-Runtimes; wachsende alive_tasks deuten auf hängende oder auflaufende Tasks hin
+async fn get_runtime() -> Response {
+    let mut runtimes = serde_json::Map::new();
+    for (chain_id, chain) in registered() {
+        let metrics = chain.runtime.metrics();
+        runtimes.insert(
+            chain_id.to_string(),
+            serde_json::json!({
+                "workers": metrics.num_workers(),
+                "alive_tasks": metrics.num_alive_tasks(),
+                "global_queue_depth": metrics.global_queue_depth(),
+            }),
+        );
+    }
+    Json(serde_json::json!({ "runtimes": runtimes })).into_response()
+}
+
+/// Thread-Dump aus /proc/self/task: Name, Zustand (R/S/D/...) und CPU-Zeit in Ticks
+#[cfg(target_os = "linux")]
+async fn get_threads() -> Response {
+    let result = tokio::task::spawn_blocking(|| -> std::io::Result<Vec<serde_json::Value>> {
+        let mut threads = Vec::new();
+        for entry in std::fs::read_dir("/proc/self/task")? {
+            let path = entry?.path();
+            let Ok(stat) = std::fs::read_to_string(path.join("stat")) else { continue };
+            // Format: tid (comm) state ... utime stime (Felder 14/15); comm kann Leerzeichen enthalten
+            let (Some(open), Some(close)) = (stat.find('('), stat.rfind(')')) else { continue };
+            let tid = stat[..open].trim();
+            let name = &stat[open + 1..close];
+            let fields: Vec<&str> = stat[close + 1..].split_whitespace().collect();
+            let field = |index: usize| fields.get(index).and_then(|value| value.parse::<u64>().ok()).unwrap_or(0);
+            threads.push(serde_json::json!({
+                "tid": tid.parse::<u64>().unwrap_or(0),
+                "name": name,
+                "state": fields.first().copied().unwrap_or("?"),
+                "utime_ticks": field(11),
+                "stime_ticks": field(12),
+            }));
+        }
+        threads.sort_by_key(|thread| thread["tid"].as_u64());
+        Ok(threads)
+    })
+    .await;
+    match result {
+        Ok(Ok(threads)) => Json(serde_json::json!({ "count": threads.len(), "threads": threads })).into_response(),
+        Ok(Err(e)) => error_response(StatusCode::INTERNAL_SERVER_ERROR, e),
+        Err(e) => error_response(StatusCode::INTERNAL_SERVER_ERROR, e),
+    }
+}
+
+#[cfg(not(target_os = "linux"))]
+async fn get_threads() -> Response {
+    error_response(StatusCode::NOT_IMPLEMENTED, "thread dump is only available on Linux")
+}
+
+#[derive(Deserialize)]
+struct ProfileQuery {
+    seconds: Option<u64>,
+    format: Option<String>, // pprof (Default) | flamegraph
+}
+
+/// Gibt den Profiler frei, auch wenn der Request abbricht
+struct ProfilingGuard;
+
+impl ProfilingGuard {
+    fn acquire() -> Option<Self> {
+        PROFILING.compare_exchange(false, true, Ordering::AcqRel, Ordering::Acquire).ok().map(|_| Self)
+    }
+}
+
+impl Drop for ProfilingGuard {
+    fn drop(&mut self) {
+        PROFILING.store(false, Ordering::Release);
+    }
+}
+
+async fn get_profile(Query(query): Query<ProfileQuery>) -> Response {
+    let seconds = query.seconds.unwrap_or(DEFAULT_PROFILE_SECS).clamp(1, MAX_PROFILE_SECS);
+    let flamegraph = match query.format.as_deref().unwrap_or("pprof") {
+        "pprof" => false,
+        "flamegraph" => true,
+        other => {
+            return error_response(StatusCode::BAD_REQUEST, format!("unknown format {}, expected pprof or flamegraph", other))
+        }
+    };
+    let Some(guard) = ProfilingGuard::acquire() else {
+        return error_response(StatusCode::CONFLICT, "a profile is already running");
+    };
+
+    info!("🩺 Recording CPU profile for {}s", seconds);
+    // Profiler samplet alle Threads des Prozesses; im Blocking-Pool, damit der Listener weiter antwortet
+    let result = tokio::task::spawn_blocking(move || {
+        let _guard = guard;
+        cpu_profile(Duration::from_secs(seconds), flamegraph)
+    })
+    .await;
+    match result {
+        Ok(Ok(body)) if flamegraph => ([(header::CONTENT_TYPE, "image/svg+xml")], body).into_response(),
+        Ok(Ok(body)) => ([(header::CONTENT_TYPE, "application/octet-stream")], body).into_response(),
+        Ok(Err(e)) => error_response(StatusCode::INTERNAL_SERVER_ERROR, e),
+        Err(e) => error_response(StatusCode::INTERNAL_SERVER_ERROR, e),
+    }
+}
+
+#[cfg(unix)]
+fn cpu_profile(duration: Duration, flamegraph: bool) -> Result<Vec<u8>, Box<dyn std::error::Error + Send + Sync>> {
+    use pprof::protos::Message;
+
+    let profiler = pprof::ProfilerGuardBuilder::default()
+        .frequency(PROFILE_FREQUENCY)
+        .blocklist(&["libc", "libgcc", "pthread", "vdso"])
+        .build()?;
+    std::thread::sleep(duration);
+    let report = profiler.report().build()?;
+
+    let mut body = Vec::new();
+    if flamegraph {
+        report.flamegraph(&mut body)?;
+    } else {
+        report.pprof()?.encode(&mut body)?;
+    }
+    Ok(body)
+}
+
+#[cfg(not(unix))]
+fn cpu_profile(_duration: Duration, _flamegraph: bool) -> Result<Vec<u8>, Box<dyn std::error::Error + Send + Sync>> {
+    Err("CPU profiling is only available on Unix".into())
+}
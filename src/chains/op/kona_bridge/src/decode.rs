@@ -0,0 +1,178 @@
+// decode.rs - Untersuchen einer gespeicherten .raw Datei (`opg_bridge decode <file.raw>`)
+//
+// Packt den Container aus, dekomprimiert, dekodiert das SSZ und prüft Block-Hash, Withdrawals und
+// Sequencer-Signatur mit derselben Nachricht wie der C-Verifier (verifier/op_verify_block.c):
+//   keccak256(domain (32 Null-Bytes) || chain_id (uint256 big-endian) || keccak256(payload))
+// payload = parent_beacon_block_root + execution payload, wie in der .raw Datei gespeichert.
+// Ergebnis als JSON (Container, Prüfungen, Block im Format von eth_getBlockByNumber) oder als
+// kurze Zusammenfassung - zum Nachvollziehen, warum der C-Verifier einen Preconf ablehnt.
+
+use crate::{
+    blockhash::{check_withdrawals, compute_block_hash, transactions_root},
+    codec::PreconfContainer,
+    config::ChainConfig,
+    delta,
+    render::render_block,
+    signer::recover_signer,
+    ssz::decode_envelope,
+    zdict,
+};
+use alloy::primitives::Address;
+use std::{fmt::Write, fs, path::Path};
+
+/// Chain-ID aus dem Dateinamen (block_{chain_id}_{block}.raw)
+fn chain_id_from_filename(path: &Path) -> Option<u64> {
+    let name = path.file_name()?.to_str()?;
+    let mut parts = name.strip_prefix("block_")?.split('_');
+    parts.next()?.parse().ok()
+}
+
+/// Dekodiert eine .raw Datei und prüft sie. Chain-ID und erwarteter Signer werden, falls nicht
+/// angegeben, aus Container bzw. Dateiname und der bekannten Chain-Konfiguration ermittelt.
+pub fn decode_file(
+    path: &Path,
+    chain_id: Option<u64>,
+    expected_signer: Option<Address>,
+) -> Result<serde_json::Value, Box<dyn std::error::Error + Send + Sync>> {
+    let data = fs::read(path)?;
+    let container = PreconfContainer::parse(&data)?;
+    // Dictionaries liegen neben der Datei in zstd_dicts/
+    if container.dict_id.is_some_and(|id| zdict::lookup(id).is_none()) {
+        zdict::load_dir(path.parent().unwrap_or(Path::new(".")));
+    }
+    // Basis eines Deltas ebenfalls neben der Datei
+    if let (Some(chain_id), Some(_)) = (container.chain_id, container.delta_base) {
+        if !delta::is_registered(chain_id) {
+            delta::register_dir(chain_id, path.parent().unwrap_or(Path::new(".")));
+        }
+    }
+    let payload = container.payload()?;
+    let chain_id = chain_id.or(container.chain_id).or_else(|| chain_id_from_filename(path));
+
+    let mut errors: Vec<String> = Vec::new();
+    let mut report = serde_json::json!({
+        "file": path.display().to_string(),
+        "container": {
+            "version": container.version,
+            "codec": container.codec.name(),
+            "chain_id": container.chain_id,
+            "block_number": container.block_number,
+            "compressed_size": container.body.len(),
+            "decompressed_size": payload.len(),
+            "zstd_dict": container.dict_id.map(|id| format!("{:06x}", id)),
+            "delta_base": container.delta_base,
+        },
+        "chain_id": chain_id,
+        "signature": format!("0x{}", hex::encode(container.signature)),
+    });
+
+    // Signatur wie im C-Verifier
+    let expected_signer = expected_signer.or_else(|| {
+        let signer = ChainConfig::for_chain(chain_id?).unsafe_signer;
+        (signer != Address::ZERO).then_some(signer)
+    });
+    match chain_id.map(|id| recover_signer(&payload, container.signature, id)) {
+        Some(Ok(signer)) => {
+            report["signer"] = serde_json::json!(format!("{}", signer));
+            if let Some(expected) = expected_signer {
+                report["expected_signer"] = serde_json::json!(format!("{}", expected));
+                report["signature_valid"] = serde_json::json!(signer == expected);
+                if signer != expected {
+                    errors.push(format!("signer {} != expected {}", signer, expected));
+                }
+            }
+        }
+        Some(Err(e)) => errors.push(format!("signature recovery failed: {}", e)),
+        None => errors.push("unknown chain id, signature not checked (use --chain-id)".to_string()),
+    }
+
+    match decode_envelope(&payload) {
+        Ok(envelope) => {
+            let p = &envelope.payload;
+            let computed = compute_block_hash(&envelope, &transactions_root(p));
+            report["payload_version"] = serde_json::json!(p.version.name());
+            report["hardfork"] = serde_json::json!(p.version.hardfork());
+            report["computed_block_hash"] = serde_json::json!(format!("0x{}", hex::encode(computed)));
+            report["block_hash_verified"] = serde_json::json!(computed == p.block_hash);
+            if computed != p.block_hash {
+                errors.push(format!(
+                    "block hash mismatch: embedded 0x{}, computed 0x{}",
+                    hex::encode(p.block_hash),
+                    hex::encode(computed)
+                ));
+            }
+            if let Err(e) = check_withdrawals(p) {
+                errors.push(e);
+            }
+            if let Some(number) = container.block_number.filter(|n| *n != p.block_number) {
+                errors.push(format!("container block number {} != payload block number {}", number, p.block_number));
+            }
+            report["block"] = render_block(&envelope, Some(container.signature));
+        }
+        Err(e) => errors.push(format!("SSZ decoding failed: {}", e)),
+    }
+
+    report["errors"] = serde_json::json!(errors);
+    Ok(report)
+}
+
+/// Kurze, menschenlesbare Zusammenfassung eines Reports aus decode_file
+pub fn format_summary(report: &serde_json::Value) -> String {
+    let text = |value: &serde_json::Value| match value {
+        serde_json::Value::String(s) => s.clone(),
+        serde_json::Value::Null => "-".to_string(),
+        other => other.to_string(),
+    };
+    let container = &report["container"];
+    let block = &report["block"];
+
+    let mut out = String::new();
+    let _ = writeln!(out, "📄 {}", text(&report["file"]));
+    let _ = writeln!(
+        out,
+        "   container: v{} codec={} {} -> {} bytes",
+        text(&container["version"]),
+        text(&container["codec"]),
+        text(&container["compressed_size"]),
+        text(&container["decompressed_size"])
+    );
+    let _ = writeln!(out, "   chain:     {}", text(&report["chain_id"]));
+    if !block.is_null() {
+        let number = block["number"].as_str().and_then(|n| u64::from_str_radix(n.trim_start_matches("0x"), 16).ok());
+        let _ = writeln!(
+            out,
+            "   block:     #{} {} ({} / {})",
+            number.map_or_else(|| "-".to_string(), |n| n.to_string()),
+            text(&block["hash"]),
+            text(&report["payload_version"]),
+            text(&report["hardfork"])
+        );
+        let _ = writeln!(
+            out,
+            "   txs:       {}  gas {} / {}  timestamp {}",
+            block["transactions"].as_array().map_or(0, |txs| txs.len()),
+            text(&block["gasUsed"]),
+            text(&block["gasLimit"]),
+            text(&block["timestamp"])
+        );
+        let _ = writeln!(out, "   hash:      computed {}", text(&report["computed_block_hash"]));
+    }
+    let _ = writeln!(
+        out,
+        "   signer:    {} (expected {})",
+        text(&report["signer"]),
+        text(&report["expected_signer"])
+    );
+
+    match report["errors"].as_array().filter(|errors| !errors.is_empty()) {
+        Some(errors) => {
+            for error in errors {
+                let _ = writeln!(out, "❌ {}", text(error));
+            }
+        }
+        None => {
+            let _ = writeln!(out, "✅ block hash and signature valid");
+        }
+    }
+    out
+}
@@ -0,0 +1,300 @@
+// delta.rs - Delta-Speicherung aufeinanderfolgender Preconfs (KONA_BRIDGE_DELTA=1)
+//
+// Aufeinanderfolgende Payloads einer Chain (Flashblocks, kurze Blockzeiten) teilen einen Großteil ihrer Bytes. Im
+// Delta-Modus komprimiert der Store Block n mit dem Payload von Block n-1 als zstd-Prefix (flags bit 1, siehe
+// codec.rs), der Body enthält dann im Wesentlichen nur noch die neuen Bytes. Blöcke mit block_number % keyframe == 0
+// und Blöcke, deren Vorgänger nicht gespeichert ist, werden vollständig geschrieben (Keyframes) - eine Kette ist damit
+// nie länger als das Keyframe-Intervall.
+// Beim Lesen holt PreconfContainer::payload() die Basis über base_payload() aus dem registrierten Verzeichnis der
+// Chain; ein kleiner Cache (in den der Schreibpfad jeden gespeicherten Payload legt) macht das für aktuelle Blöcke
+// billig. Bevor eine Basis gelöscht oder überschrieben wird, schreibt detach() ihren Delta-Nachfolger als Keyframe neu.
+
+use crate::{
+    codec::{encode_container, PreconfContainer, SIGNATURE_SIZE},
+    membudget, perms, profile,
+};
+use std::{
+    collections::{HashMap, HashSet, VecDeque},
+    fs::{self, File},
+    io::Write,
+    path::{Path, PathBuf},
+    sync::{Arc, Mutex, OnceLock, RwLock},
+};
+use tracing::{debug, warn};
+
+pub const DEFAULT_DELTA_KEYFRAME: u64 = 32;
+
+/// Cache rekonstruierter bzw. gerade gespeicherter Payloads (über alle Chains); die Bytes begrenzt das Profil
+const CACHE_ENTRIES: usize = 64;
+
+/// Unter Speicherdruck (siehe membudget.rs) bleiben nur die Basen der nächsten Blöcke im Cache
+const CACHE_ENTRIES_UNDER_PRESSURE: usize = 2;
+
+/// Einstellungen der Delta-Speicherung (siehe BridgeSettings)
+#[derive(Debug, Clone)]
+pub struct DeltaSettings {
+    pub keyframe_interval: u64, // jeder n-te Block wird vollständig gespeichert
+}
+
+static DIRS: OnceLock<RwLock<HashMap<u64, PathBuf>>> = OnceLock::new();
+static CACHE: OnceLock<Mutex<VecDeque<(u64, u64, Arc<Vec<u8>>)>>> = OnceLock::new();
+
+fn dirs() -> &'static RwLock<HashMap<u64, PathBuf>> {
+    DIRS.get_or_init(|| RwLock::new(HashMap::new()))
+}
+
+fn cache() -> &'static Mutex<VecDeque<(u64, u64, Arc<Vec<u8>>)>> {
+    CACHE.get_or_init(|| Mutex::new(VecDeque::with_capacity(CACHE_ENTRIES)))
+}
+
+/// Verzeichnis, in dem die Basis-Blöcke einer Chain liegen (Store beim Start, decode für einzelne Dateien)
+pub fn register_dir(chain_id: u64, dir: &Path) {
+    dirs().write().unwrap().insert(chain_id, dir.to_path_buf());
+}
+
+/// Ob für die Chain schon ein Verzeichnis registriert ist
+pub fn is_registered(chain_id: u64) -> bool {
+    dirs().read().unwrap().contains_key(&chain_id)
+}
+
+/// Payload eines gespeicherten Blocks merken (ersetzt einen älteren Stand derselben Höhe)
+pub fn remember(chain_id: u64, block_number: u64, payload: Arc<Vec<u8>>) {
+    let mut cache = cache().lock().unwrap();
+    cache.retain(|(chain, block, _)| (*chain, *block) != (chain_id, block_number));
+    cache.push_back((chain_id, block_number, payload));
+
+    let (max_entries, max_bytes) = match membudget::under_pressure() {
+        true => (CACHE_ENTRIES_UNDER_PRESSURE, 0),
+        false => (CACHE_ENTRIES, profile::current().payload_cache_bytes),
+    };
+    let mut bytes: usize = cache.iter().map(|(_, _, payload)| payload.len()).sum();
+    while cache.len() > max_entries || (bytes > max_bytes && cache.len() > 1) {
+        if let Some((_, _, evicted)) = cache.pop_front() {
+            bytes -= evicted.len();
+        }
+    }
+}
+
+/// Payload aus dem Cache (z.B. der Vorgänger als Basis für den nächsten Block)
+pub fn cached(chain_id: u64, block_number: u64) -> Option<Arc<Vec<u8>>> {
+    let cache = cache().lock().unwrap();
+    cache
+        .iter()
+        .rev()
+        .find(|(chain, block, _)| (*chain, *block) == (chain_id, block_number))
+        .map(|(_, _, payload)| payload.clone())
+}
+
+/// Payload der Basis eines Deltas, aus dem Cache oder der .raw Datei (rekursiv bis zum Keyframe)
+pub fn base_payload(
+    chain_id: u64,
+    block_number: u64,
+) -> Result<Arc<Vec<u8>>, Box<dyn std::error::Error + Send + Sync>> {
+    if let Some(payload) = cached(chain_id, block_number) {
+        return Ok(payload);
+    }
+    let dir = dirs()
+        .read()
+        .unwrap()
+        .get(&chain_id)
+        .cloned()
+        .ok_or_else(|| format!("No directory registered for delta base of chain {}", chain_id))?;
+    let path = dir.join(format!("block_{}_{}.raw", chain_id, block_number));
+    let data = fs::read(&path).map_err(|e| format!("Delta base {:?} not readable: {}", path, e))?;
+    let payload = Arc::new(PreconfContainer::parse(&data)?.payload()?);
+    remember(chain_id, block_number, payload.clone());
+    Ok(payload)
+}
+
+/// (Chain, Block) aus block_{chain}_{n}.raw
+fn parse_raw_filename(path: &Path) -> Option<(u64, u64)> {
+    let name = path.file_name()?.to_str()?;
+    let (chain_id, block_number) = name.strip_prefix("block_")?.strip_suffix(".raw")?.split_once('_')?;
+    Some((chain_id.parse().ok()?, block_number.parse().ok()?))
+}
+
+/// Schreibt die Delta-Nachfolger der .raw Dateien in `paths` als Keyframes neu, bevor diese gelöscht oder
+/// überschrieben werden (Nachfolger, die selbst in `paths` stehen, bleiben unverändert); gibt ihre Anzahl zurück
+pub async fn detach(paths: &[PathBuf]) -> usize {
+    let removed: HashSet<&Path> = paths.iter().map(PathBuf::as_path).collect();
+    let successors: Vec<PathBuf> = paths
+        .iter()
+        .filter_map(|path| {
+            let (chain_id, block_number) = parse_raw_filename(path)?;
+            let successor = path.with_file_name(format!("block_{}_{}.raw", chain_id, block_number + 1));
+            (!removed.contains(successor.as_path()) && successor.exists()).then_some(successor)
+        })
+        .collect();
+    if successors.is_empty() {
+        return 0;
+    }
+
+    let rewritten = tokio::task::spawn_blocking(move || {
+        let mut rewritten = 0;
+        for path in successors {
+            match rewrite_as_keyframe(&path) {
+                Ok(true) => {
+                    debug!("🧩 Rewrote {:?} as keyframe", path.file_name().unwrap_or_default());
+                    rewritten += 1;
+                }
+                Ok(false) => {}
+                Err(e) => warn!("⚠️  Cannot rewrite delta {:?} as keyframe: {}", path, e),
+            }
+        }
+        rewritten
+    })
+    .await;
+    rewritten.unwrap_or(0)
+}
+
+/// Ein Delta als vollständiger Payload im selben Codec, gibt (Datei, Body-Größe) zurück; None, wenn die Datei kein
+/// Delta ist
+pub fn as_keyframe(data: &[u8]) -> Result<Option<(Vec<u8>, usize)>, Box<dyn std::error::Error + Send + Sync>> {
+    let container = PreconfContainer::parse(data)?;
+    let (Some(chain_id), Some(block_number), Some(_)) =
+        (container.chain_id, container.block_number, container.delta_base)
+    else {
+        return Ok(None);
+    };
+    let payload = container.payload()?;
+    let signature: [u8; SIGNATURE_SIZE] = container.signature.try_into()?;
+    Ok(Some(encode_container(container.codec, chain_id, block_number, &payload, &signature, None)?))
+}
+
+/// Ersetzt ein Delta durch den vollständigen Payload im selben Codec (tmp + fsync + rename) und zieht die
+/// Body-Größe in den Metadaten nach; false, wenn die Datei kein Delta ist
+pub fn rewrite_as_keyframe(path: &Path) -> Result<bool, Box<dyn std::error::Error + Send + Sync>> {
+    let Some((raw, body_len)) = as_keyframe(&fs::read(path)?)? else {
+        return Ok(false);
+    };
+    write_synced(&path.with_extension("tmp"), path, &raw)?;
+
+    let meta_path = path.with_extension("json");
+    if let Ok(meta) = fs::read(&meta_path) {
+        let mut metadata: serde_json::Value = serde_json::from_slice(&meta)?;
+        metadata["compressed_size"] = serde_json::json!(body_len);
+        write_synced(&meta_path.with_extension("json.tmp"), &meta_path, &serde_json::to_vec_pretty(&metadata)?)?;
+    }
+    Ok(true)
+}
+
+fn write_synced(temp: &Path, path: &Path, data: &[u8]) -> std::io::Result<()> {
+    let mut file = File::create(temp)?;
+    file.write_all(data)?;
+    file.sync_all()?;
+    perms::apply_file(temp);
+    fs::rename(temp, path)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::{
+        codec::{PayloadCodec, Reference},
+        ssz::{tests::TestPayload, PayloadVersion},
+    };
+
+    const SIGNATURE: [u8; SIGNATURE_SIZE] = [0x5a; SIGNATURE_SIZE];
+
+    fn payload(block_number: u64) -> Vec<u8> {
+        let mut payload = TestPayload::sample(PayloadVersion::V3);
+        payload.block_number = block_number;
+        payload.timestamp += block_number * 2;
+        payload.encode()
+    }
+
+    /// Schreibt Block `block_number` (mit Metadaten), als Delta gegen `base` oder als Keyframe
+    fn store(dir: &Path, chain_id: u64, block_number: u64, base: Option<&[u8]>) -> PathBuf {
+        let payload = payload(block_number);
+        let reference = base.map(Reference::Delta);
+        let (raw, body_len) =
+            encode_container(PayloadCodec::Zstd(3), chain_id, block_number, &payload, &SIGNATURE, reference)
+                .unwrap();
+        let path = dir.join(format!("block_{}_{}.raw", chain_id, block_number));
+        fs::write(&path, raw).unwrap();
+        let metadata = serde_json::json!({ "block_number": block_number, "compressed_size": body_len });
+        fs::write(path.with_extension("json"), metadata.to_string()).unwrap();
+        path
+    }
+
+    fn read(path: &Path) -> (Option<u64>, Vec<u8>) {
+        let data = fs::read(path).unwrap();
+        let container = PreconfContainer::parse(&data).unwrap();
+        (container.delta_base, container.payload().unwrap())
+    }
+
+    #[test]
+    fn reads_delta_chain_from_disk() {
+        // Eigene Chain je Test: Verzeichnis-Registry und Cache sind prozessweit
+        let chain_id = 0xde17a1;
+        let dir = tempfile::tempdir().unwrap();
+        register_dir(chain_id, dir.path());
+
+        store(dir.path(), chain_id, 100, None);
+        store(dir.path(), chain_id, 101, Some(&payload(100)));
+        let path = store(dir.path(), chain_id, 102, Some(&payload(101)));
+
+        // Ohne Cache: 102 -> 101 -> 100 (Keyframe) von der Platte
+        assert_eq!(read(&path), (Some(101), payload(102)));
+        assert_eq!(cached(chain_id, 101).as_deref(), Some(&payload(101)));
+    }
+
+    #[test]
+    fn keyframe_rewrite_keeps_payload() {
+        let chain_id = 0xde17a3;
+        let dir = tempfile::tempdir().unwrap();
+        register_dir(chain_id, dir.path());
+        let keyframe = store(dir.path(), chain_id, 100, None);
+        let path = store(dir.path(), chain_id, 101, Some(&payload(100)));
+
+        assert!(!rewrite_as_keyframe(&keyframe).unwrap());
+        assert!(rewrite_as_keyframe(&path).unwrap());
+        assert_eq!(read(&path), (None, payload(101)));
+
+        let data = fs::read(&path).unwrap();
+        let body_len = PreconfContainer::parse(&data).unwrap().body.len();
+        let metadata: serde_json::Value =
+            serde_json::from_slice(&fs::read(path.with_extension("json")).unwrap()).unwrap();
+        assert_eq!(metadata["compressed_size"], serde_json::json!(body_len));
+        assert!(!dir.path().join(format!("block_{}_101.tmp", chain_id)).exists());
+    }
+
+    #[tokio::test]
+    async fn detach_keeps_successor_readable() {
+        let chain_id = 0xde17a4;
+        let dir = tempfile::tempdir().unwrap();
+        register_dir(chain_id, dir.path());
+        let first = store(dir.path(), chain_id, 100, None);
+        let second = store(dir.path(), chain_id, 101, Some(&payload(100)));
+        let third = store(dir.path(), chain_id, 102, Some(&payload(101)));
+
+        // 100 und 101 werden gelöscht: nur 102 muss neu geschrieben werden
+        assert_eq!(detach(&[first.clone(), second.clone()]).await, 1);
+        for path in [&first, &second] {
+            fs::remove_file(path).unwrap();
+        }
+        assert_eq!(read(&third), (None, payload(102)));
+        assert_eq!(detach(&[third]).await, 0);
+    }
+
+    #[test]
+    fn deleted_base_without_detach_is_unreadable() {
+        // Regression: ohne detach verweist der Nachfolger auf eine gelöschte Basis
+        let chain_id = 0xde17a5;
+        let dir = tempfile::tempdir().unwrap();
+        register_dir(chain_id, dir.path());
+        let first = store(dir.path(), chain_id, 100, None);
+        let second = store(dir.path(), chain_id, 101, Some(&payload(100)));
+
+        fs::remove_file(&first).unwrap();
+        let data = fs::read(&second).unwrap();
+        assert!(PreconfContainer::parse(&data).unwrap().payload().is_err());
+    }
+
+    #[test]
+    fn parses_raw_filenames() {
+        assert_eq!(parse_raw_filename(Path::new("/data/block_10_123.raw")), Some((10, 123)));
+        assert_eq!(parse_raw_filename(Path::new("block_10_123.json")), None);
+        assert_eq!(parse_raw_filename(Path::new("latest.raw")), None);
+    }
+}
@@ -0,0 +1,181 @@
+// dirlock.rs - Sperre gegen zwei Bridges, die in dasselbe Output-Verzeichnis schreiben
+//
+// Zwei Instanzen für dieselbe Chain und dasselbe Verzeichnis liefern sich ein Rennen um latest.raw, den Block-Index
+// und die Block-Dateien. Beim Start wird deshalb bridge_{chain_id}.lock per flock exklusiv gesperrt; der Kernel gibt
+// die Sperre frei, sobald der Prozess endet (auch nach einem Absturz), eine liegengebliebene Datei blockiert also
+// nichts. In der Datei stehen PID, Host und Startzeit des Halters für die Fehlermeldung.
+//
+// Ist das Verzeichnis gesperrt, startet die Bridge nicht. Mit KONA_BRIDGE_LOCK_TAKEOVER=1 bekommt der bisherige
+// Halter (nur auf demselben Host) SIGTERM und die Bridge wartet bis KONA_BRIDGE_LOCK_WAIT_SECS (Default 30) auf
+// die Sperre, z.B. für ein Deployment, bei dem die neue Instanz die alte ablöst. Unterstützt das Dateisystem kein
+// flock (manche Netz-Dateisysteme), entscheidet stattdessen, ob die PID aus der Datei noch lebt.
+// Ein per SIGUSR2 gestarteter Nachfolger (siehe handoff.rs) wartet ebenso auf seinen Vorgänger, aber ohne SIGTERM.
+
+use crate::handoff;
+use std::{
+    fs::{self, File, OpenOptions},
+    io,
+    os::unix::{fs::FileExt, io::AsRawFd},
+    path::{Path, PathBuf},
+    time::{Duration, Instant, SystemTime, UNIX_EPOCH},
+};
+use tracing::{info, warn};
+
+pub const DEFAULT_LOCK_WAIT_SECS: u64 = 30;
+/// Abstand der Versuche, während auf den bisherigen Halter gewartet wird
+const LOCK_RETRY_INTERVAL: Duration = Duration::from_millis(250);
+
+/// Gehaltene Sperre, wird mit dem Drop freigegeben
+pub struct DirLock {
+    file: File,
+    path: PathBuf,
+}
+
+/// Halter laut Lock-Datei
+struct Holder {
+    pid: u32,
+    host: String,
+    started_unix: u64,
+}
+
+impl Holder {
+    fn read(path: &Path) -> Option<Self> {
+        let content = fs::read_to_string(path).ok()?;
+        let mut holder = Holder { pid: 0, host: String::new(), started_unix: 0 };
+        for line in content.lines() {
+            match line.split_once('=') {
+                Some(("pid", value)) => holder.pid = value.trim().parse().ok()?,
+                Some(("host", value)) => holder.host = value.trim().to_string(),
+                Some(("started_unix", value)) => holder.started_unix = value.trim().parse().unwrap_or(0),
+                _ => {}
+            }
+        }
+        (holder.pid != 0).then_some(holder)
+    }
+
+    fn describe(&self) -> String {
+        format!("pid {} on {} (since {})", self.pid, self.host, self.started_unix)
+    }
+
+    fn is_local(&self) -> bool {
+        self.host == hostname()
+    }
+
+    fn is_alive(&self) -> bool {
+        // Signal 0 prüft nur, ob der Prozess existiert (EPERM: existiert, gehört jemand anderem)
+        self.is_local()
+            && (unsafe { libc::kill(self.pid as libc::pid_t, 0) } == 0
+                || io::Error::last_os_error().raw_os_error() == Some(libc::EPERM))
+    }
+}
+
+pub fn hostname() -> String {
+    let mut buffer = [0u8; 256];
+    if unsafe { libc::gethostname(buffer.as_mut_ptr() as *mut libc::c_char, buffer.len()) } != 0 {
+        return String::new();
+    }
+    let len = buffer.iter().position(|b| *b == 0).unwrap_or(buffer.len());
+    String::from_utf8_lossy(&buffer[..len]).to_string()
+}
+
+pub(crate) enum Attempt {
+    Acquired,
+    Held,
+    Unsupported,
+}
+
+pub(crate) fn try_flock(file: &File) -> io::Result<Attempt> {
+    if unsafe { libc::flock(file.as_raw_fd(), libc::LOCK_EX | libc::LOCK_NB) } == 0 {
+        return Ok(Attempt::Acquired);
+    }
+    let e = io::Error::last_os_error();
+    match e.raw_os_error() {
+        Some(libc::EWOULDBLOCK) => Ok(Attempt::Held),
+        Some(libc::ENOLCK) | Some(libc::EOPNOTSUPP) => Ok(Attempt::Unsupported),
+        _ => Err(e),
+    }
+}
+
+impl DirLock {
+    /// Sperrt das Output-Verzeichnis für die Chain (Err: anderer Halter aktiv oder Sperre nicht möglich)
+    pub async fn acquire(
+        output_dir: &Path,
+        chain_id: u64,
+        takeover: bool,
+        wait: Duration,
+    ) -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        let path = output_dir.join(format!("bridge_{}.lock", chain_id));
+        let file = OpenOptions::new()
+            .read(true)
+            .write(true)
+            .create(true)
+            .truncate(false)
+            .open(&path)
+            .map_err(|e| format!("Cannot open lock file {:?}: {}", path, e))?;
+
+        let deadline = Instant::now() + wait;
+        let mut signalled = false;
+        loop {
+            let holder = Holder::read(&path);
+            let held = match try_flock(&file)? {
+                Attempt::Acquired => false,
+                Attempt::Held => true,
+                Attempt::Unsupported => holder.as_ref().is_some_and(|holder| {
+                    holder.pid != std::process::id() && holder.is_alive()
+                }),
+            };
+            if !held {
+                break;
+            }
+
+            let description = holder.as_ref().map(Holder::describe).unwrap_or_else(|| "unknown holder".to_string());
+            // Beim Upgrade (siehe handoff.rs) gibt der Vorgänger die Sperre von selbst frei
+            let handoff = holder.as_ref().is_some_and(|holder| handoff::is_parent(holder.pid));
+            if !takeover && !handoff {
+                let hint = "set KONA_BRIDGE_LOCK_TAKEOVER=1 to replace it";
+                return Err(format!("Output directory {:?} is locked by {}, {}", output_dir, description, hint).into());
+            }
+            if !signalled && !handoff {
+                match holder.as_ref().filter(|holder| holder.is_local() && holder.pid != std::process::id()) {
+                    Some(holder) => {
+                        warn!("⚠️  Taking over {:?} from {}, sending SIGTERM", output_dir, description);
+                        unsafe { libc::kill(holder.pid as libc::pid_t, libc::SIGTERM) };
+                    }
+                    None => {
+                        return Err(format!(
+                            "Output directory {:?} is locked by {}, which cannot be taken over from this process",
+                            output_dir, description
+                        )
+                        .into())
+                    }
+                }
+                signalled = true;
+            }
+            if Instant::now() >= deadline {
+                return Err(format!("Timed out waiting for {} to release {:?}", description, output_dir).into());
+            }
+            tokio::time::sleep(LOCK_RETRY_INTERVAL).await;
+        }
+
+        let lock = Self { file, path };
+        lock.write_holder().map_err(|e| format!("Cannot write lock file {:?}: {}", lock.path, e))?;
+        info!("🔒 Locked output directory {:?}", output_dir);
+        Ok(lock)
+    }
+
+    fn write_holder(&self) -> io::Result<()> {
+        let started = SystemTime::now().duration_since(UNIX_EPOCH).map(|d| d.as_secs()).unwrap_or(0);
+        self.file.set_len(0)?;
+        let content = format!("pid={}\nhost={}\nstarted_unix={}\n", std::process::id(), hostname(), started);
+        self.file.write_all_at(content.as_bytes(), 0)?;
+        self.file.sync_data()
+    }
+}
+
+impl Drop for DirLock {
+    fn drop(&mut self) {
+        // Inhalt leeren, damit der PID-Fallback keinen wiederverwendeten PID für den Halter hält
+        let _ = self.file.set_len(0);
+        unsafe { libc::flock(self.file.as_raw_fd(), libc::LOCK_UN) };
+    }
+}
@@ -0,0 +1,120 @@
+// diskguard.rs - Schutz vor vollem Dateisystem: Notfall-Löschung und Schreibsperre
+//
+// Alle DISK_GUARD_INTERVAL prüft die Bridge den freien Platz im Dateisystem des Output-Verzeichnisses
+// (statvfs, Platz für unprivilegierte Prozesse):
+//   unter KONA_BRIDGE_MIN_FREE_MB (Default 512, 0 = aus)
+//       Notfall-Löschung der ältesten Blöcke dieser Chain, bis wieder das Doppelte frei ist (die zwei neuesten Blöcke
+//       bleiben wie bei der Quota erhalten), dazu der Hook "disk_full" und der Alarm disk_quota (siehe alerts.rs)
+//   unter einem Viertel davon
+//       neue Preconfs werden vor dem ersten Schreibzugriff abgelehnt, statt mitten im tmp + rename zu scheitern und
+//       einen halben Eintrag oder einen latest-Zeiger auf eine fehlende Datei zu hinterlassen
+// Die Sperre hebt sich auf, sobald wieder genug Platz frei ist. Gelöschte Blöcke zählen als Quota-Löschung.
+
+use crate::{
+    blobs::gc_unreferenced_blobs,
+    stats::Eviction,
+    storage::PreconfStore,
+    utils::{free_bytes, prune_for_free_space},
+};
+use std::{
+    sync::{
+        atomic::{AtomicBool, AtomicU64, Ordering},
+        Arc, Mutex,
+    },
+    time::Duration,
+};
+use tokio::time::interval;
+use tracing::{error, info, warn};
+
+pub const DEFAULT_MIN_FREE_MB: u64 = 512;
+const DISK_GUARD_INTERVAL: Duration = Duration::from_secs(5);
+
+/// Zustand des Dateisystems für den Schreibpfad (vom Guard-Task aktualisiert)
+#[derive(Default)]
+pub struct DiskState {
+    low: AtomicBool,        // unter KONA_BRIDGE_MIN_FREE_MB
+    read_only: AtomicBool,  // unter einem Viertel davon, Schreibsperre
+    free_bytes: AtomicU64,
+}
+
+impl DiskState {
+    /// Freier Platz beim letzten Check, falls er unter der Schwelle lag
+    pub fn low(&self) -> Option<u64> {
+        self.low.load(Ordering::Relaxed).then(|| self.free_bytes.load(Ordering::Relaxed))
+    }
+
+    /// Freier Platz beim letzten Check, falls neue Preconfs abgelehnt werden
+    pub fn read_only(&self) -> Option<u64> {
+        self.read_only.load(Ordering::Relaxed).then(|| self.free_bytes.load(Ordering::Relaxed))
+    }
+}
+
+/// Überwacht den freien Platz und löscht im Notfall die ältesten Blöcke
+pub async fn run_disk_guard(store: Arc<PreconfStore>, min_free_mb: u64, running: Arc<Mutex<bool>>) {
+    let min_free = min_free_mb * 1024 * 1024;
+    let write_reserve = min_free / 4;
+    let output_dir = store.output_dir().to_path_buf();
+    let state = store.disk_state();
+    info!("💽 Disk guard: emergency pruning below {} MB free in {}", min_free_mb, output_dir.display());
+
+    let mut ticker = interval(DISK_GUARD_INTERVAL);
+    let mut failing = false;
+    loop {
+        ticker.tick().await;
+        if !*running.lock().unwrap() {
+            break;
+        }
+        let free = match free_bytes(&output_dir) {
+            Ok(free) => free,
+            Err(e) => {
+                if !failing {
+                    warn!("⚠️  Disk guard cannot read free space of {}: {}", output_dir.display(), e);
+                    failing = true;
+                }
+                continue;
+            }
+        };
+        failing = false;
+        state.free_bytes.store(free, Ordering::Relaxed);
+
+        if free >= min_free {
+            if state.low.swap(false, Ordering::Relaxed) {
+                info!("💽 Disk space recovered: {} MB free", free / (1024 * 1024));
+            }
+            if state.read_only.swap(false, Ordering::Relaxed) {
+                info!("💽 Accepting preconfs again");
+            }
+            continue;
+        }
+
+        let newly_low = !state.low.swap(true, Ordering::Relaxed);
+        if newly_low {
+            warn!("⚠️  Only {} MB free (minimum {} MB), emergency pruning", free / (1024 * 1024), min_free_mb);
+        }
+        if free < write_reserve && !state.read_only.swap(true, Ordering::Relaxed) {
+            error!("❌ Only {} MB free, rejecting new preconfs until space is available", free / (1024 * 1024));
+        }
+
+        // Bis zum Doppelten der Schwelle freigeben (nicht bei jedem Check nur knapp darüber)
+        let deleted = match prune_for_free_space(&output_dir, store.chain_id(), 2 * min_free - free).await {
+            Ok(deleted) => deleted,
+            Err(e) => {
+                warn!("⚠️  Emergency pruning failed: {}", e);
+                0
+            }
+        };
+        store.storage_stats().record_evicted(Eviction::Quota, deleted);
+        // Mit KONA_BRIDGE_DEDUP gibt erst das Entfernen der Blobs den Platz frei
+        if let Err(e) = gc_unreferenced_blobs(&output_dir).await {
+            warn!("⚠️  Blob GC failed: {}", e);
+        }
+        let free_after = free_bytes(&output_dir).unwrap_or(free);
+        state.free_bytes.store(free_after, Ordering::Relaxed);
+        if newly_low || deleted > 0 {
+            store.report_disk_full(free, free_after, deleted);
+        }
+        if newly_low && deleted == 0 {
+            warn!("⚠️  Nothing left to prune in {}, free space must come from elsewhere", output_dir.display());
+        }
+    }
+}
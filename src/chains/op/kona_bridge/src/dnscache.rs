@@ -0,0 +1,78 @@
+// dnscache.rs - Zuletzt aufgelöste Adressen des Preconf-Endpoints als Fallback bei DNS-Fehlern
+//
+// Der HTTP-Poll löst den Host des Endpoints bei jeder neuen Verbindung auf. Scheitert die Auflösung vorübergehend
+// (DNS-Server nicht erreichbar, SERVFAIL), verbindet sich der Poll mit den zuletzt erfolgreich aufgelösten Adressen,
+// solange diese jünger als KONA_BRIDGE_DNS_CACHE_SECS (Default 600, 0 = kein Fallback) sind, statt bei jedem Poll
+// zu scheitern. Jeder Fehlschlag zählt als dns_failures in den Speicher-Statistiken, auch wenn der Cache aushilft.
+
+use crate::stats::StorageStats;
+use reqwest::dns::{Addrs, Name, Resolve, Resolving};
+use std::{
+    collections::HashMap,
+    net::SocketAddr,
+    sync::{Arc, Mutex},
+    time::{Duration, Instant},
+};
+use tracing::warn;
+
+pub const DEFAULT_DNS_CACHE_SECS: u64 = 600;
+
+/// Host -> zuletzt aufgelöste Adressen und Zeitpunkt der Auflösung
+type AddressCache = HashMap<String, (Vec<SocketAddr>, Instant)>;
+
+/// Resolver für reqwest mit Fallback auf die letzten bekannten Adressen
+pub struct CachingResolver {
+    ttl: Duration,
+    stats: Arc<StorageStats>,
+    cache: Arc<Mutex<AddressCache>>,
+}
+
+impl CachingResolver {
+    pub fn new(ttl: Duration, stats: Arc<StorageStats>) -> Self {
+        Self { ttl, stats, cache: Arc::new(Mutex::new(HashMap::new())) }
+    }
+}
+
+impl Resolve for CachingResolver {
+    fn resolve(&self, name: Name) -> Resolving {
+        let host = name.as_str().to_string();
+        let (ttl, stats, cache) = (self.ttl, self.stats.clone(), self.cache.clone());
+        Box::pin(async move {
+            let error = match tokio::net::lookup_host((host.as_str(), 0)).await {
+                Ok(addrs) => {
+                    let addrs: Vec<SocketAddr> = addrs.collect();
+                    if !addrs.is_empty() {
+                        cache.lock().unwrap().insert(host.clone(), (addrs.clone(), Instant::now()));
+                        return Ok(Box::new(addrs.into_iter()) as Addrs);
+                    }
+                    format!("no addresses for {}", host).into()
+                }
+                Err(e) => Box::new(e) as Box<dyn std::error::Error + Send + Sync>,
+            };
+            stats.record_dns_failure();
+
+            let cached = cache.lock().unwrap().get(&host).cloned();
+            match cached {
+                Some((addrs, resolved)) if !ttl.is_zero() && resolved.elapsed() <= ttl => {
+                    warn!(
+                        "⚠️  DNS lookup of {} failed ({}), using addresses resolved {}s ago",
+                        host,
+                        error,
+                        resolved.elapsed().as_secs()
+                    );
+                    Ok(Box::new(addrs.into_iter()) as Addrs)
+                }
+                _ => Err(error),
+            }
+        })
+    }
+}
+
+/// HTTP-Client mit CachingResolver (`cache_secs` = 0: nur Fehler zählen, kein Fallback)
+pub fn client(cache_secs: u64, stats: Arc<StorageStats>) -> reqwest::Client {
+    let resolver = CachingResolver::new(Duration::from_secs(cache_secs), stats);
+    reqwest::Client::builder().dns_resolver(Arc::new(resolver)).build().unwrap_or_else(|e| {
+        warn!("⚠️  Cannot build HTTP client with DNS cache ({}), using the default resolver", e);
+        reqwest::Client::new()
+    })
+}
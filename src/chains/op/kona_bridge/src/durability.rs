@@ -0,0 +1,249 @@
+// durability.rs - Steuerung von fsync beim Schreiben der Preconf-Dateien
+//
+// Alle Dateien werden über tmp + rename geschrieben, die Modi unterscheiden sich nur darin,
+// wann die Daten auf dem Datenträger landen:
+//   none       - kein fsync, das OS schreibt irgendwann (maximaler Durchsatz, Default)
+//   flush      - Dateiinhalt wird vor dem rename gesynct (keine halben Dateien nach Absturz,
+//                der rename selbst kann aber verloren gehen)
+//   fsync-each - Datei vor dem rename und Verzeichnis nach dem rename syncen (crash-sicher)
+//   group      - kein fsync pro Schreibvorgang, ein Hintergrund-Task synct das Dateisystem
+//                gesammelt alle KONA_BRIDGE_DURABILITY_GROUP_MS (Group-Commit)
+//
+// Mit KONA_BRIDGE_GROUP_COMMIT=1 sammelt der Store zusätzlich die Metadaten-Dateien (block_{chain}_{n}.json) und
+// schreibt sie zusammen mit dem Block-Index in einem Commit-Zyklus je KONA_BRIDGE_DURABILITY_GROUP_MS, gefolgt von
+// höchstens einem Sync (siehe storage::run_group_commit). Mehrfach geschriebene Dateien (Flashblocks, Nachladen)
+// landen nur einmal auf dem Datenträger.
+
+use crate::perms;
+use std::{
+    collections::HashMap,
+    fmt,
+    path::{Path, PathBuf},
+    sync::{
+        atomic::{AtomicBool, Ordering},
+        Arc, Mutex,
+    },
+    time::Duration,
+};
+use tokio::{fs as tokio_fs, io::AsyncWriteExt, time::interval};
+use tracing::{info, warn};
+
+/// Default-Intervall für den Group-Commit
+pub const DEFAULT_GROUP_INTERVAL_MS: u64 = 200;
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum Durability {
+    #[default]
+    None,
+    Flush,
+    FsyncEach,
+    Group,
+}
+
+impl fmt::Display for Durability {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        let name = match self {
+            Durability::None => "none",
+            Durability::Flush => "flush",
+            Durability::FsyncEach => "fsync-each",
+            Durability::Group => "group",
+        };
+        write!(f, "{}", name)
+    }
+}
+
+impl Durability {
+    /// Parst "none", "flush", "fsync-each" (auch "fsync") oder "group"
+    pub fn parse(value: &str) -> Option<Self> {
+        match value.trim().to_ascii_lowercase().as_str() {
+            "none" | "" => Some(Durability::None),
+            "flush" => Some(Durability::Flush),
+            "fsync-each" | "fsync_each" | "fsync" => Some(Durability::FsyncEach),
+            "group" => Some(Durability::Group),
+            _ => None,
+        }
+    }
+}
+
+/// Schreibt Dateien im Output-Verzeichnis gemäß des Durability-Modus
+pub struct DurableFs {
+    mode: Durability,
+    dir: PathBuf,
+    dirty: AtomicBool, // Group-Modus: seit dem letzten Sync geschrieben
+    staged: Option<Mutex<HashMap<PathBuf, (PathBuf, Vec<u8>)>>>, // Group-Commit: Ziel -> (tmp, Inhalt)
+}
+
+impl DurableFs {
+    pub fn new(dir: &Path, mode: Durability) -> Self {
+        Self {
+            mode,
+            dir: dir.to_path_buf(),
+            dirty: AtomicBool::new(false),
+            staged: None,
+        }
+    }
+
+    /// Sammelt write_deferred() bis zum nächsten commit_staged() (KONA_BRIDGE_GROUP_COMMIT)
+    pub fn with_group_commit(mut self, enabled: bool) -> Self {
+        self.staged = enabled.then(|| Mutex::new(HashMap::new()));
+        self
+    }
+
+    /// Wie write_atomic, mit Group-Commit aber erst im nächsten Zyklus; ein späterer Aufruf für dieselbe Datei
+    /// ersetzt den gesammelten Inhalt
+    pub async fn write_deferred(&self, temp: &Path, path: &Path, data: Vec<u8>) -> std::io::Result<()> {
+        match self.staged {
+            Some(ref staged) => {
+                staged.lock().unwrap().insert(path.to_path_buf(), (temp.to_path_buf(), data));
+                Ok(())
+            }
+            None => self.write_atomic(temp, path, &data).await,
+        }
+    }
+
+    /// Noch nicht geschriebener Inhalt einer Datei (Leser sehen gesammelte Dateien sofort)
+    pub fn read_staged(&self, path: &Path) -> Option<Vec<u8>> {
+        self.staged.as_ref()?.lock().unwrap().get(path).map(|(_, data)| data.clone())
+    }
+
+    /// Schreibt alle gesammelten Dateien (tmp + rename, ohne fsync pro Datei) und gibt ihre Anzahl zurück;
+    /// außer im Modus none markiert das einen Sync für sync_pending()
+    pub async fn commit_staged(&self) -> std::io::Result<usize> {
+        let Some(ref staged) = self.staged else {
+            return Ok(0);
+        };
+        let files: Vec<_> = staged.lock().unwrap().drain().collect();
+        let mut result = Ok(files.len());
+        for (path, (temp, data)) in &files {
+            let written = async {
+                tokio_fs::write(temp, data).await?;
+                perms::apply_file(temp);
+                tokio_fs::rename(temp, path).await
+            };
+            if let Err(e) = written.await {
+                warn!("⚠️  Group commit cannot write {:?}: {}", path, e);
+                result = Err(e);
+            }
+        }
+        if !files.is_empty() && self.mode != Durability::None {
+            self.dirty.store(true, Ordering::Relaxed);
+        }
+        result
+    }
+
+    /// Schreibt `data` nach `temp` und benennt die Datei atomar in `path` um
+    pub async fn write_atomic(&self, temp: &Path, path: &Path, data: &[u8]) -> std::io::Result<()> {
+        match self.mode {
+            Durability::None | Durability::Group => tokio_fs::write(temp, data).await?,
+            Durability::Flush | Durability::FsyncEach => {
+                let mut file = tokio_fs::File::create(temp).await?;
+                file.write_all(data).await?;
+                if self.mode == Durability::Flush {
+                    file.sync_data().await?;
+                } else {
+                    file.sync_all().await?;
+                }
+            }
+        }
+        perms::apply_file(temp);
+        tokio_fs::rename(temp, path).await?;
+        self.renamed(path).await
+    }
+
+    /// Nach einem rename/link im Verzeichnis von `path` aufrufen (synct bzw. markiert das Verzeichnis)
+    pub async fn renamed(&self, path: &Path) -> std::io::Result<()> {
+        match self.mode {
+            Durability::FsyncEach => {
+                let parent = path.parent().unwrap_or(&self.dir);
+                tokio_fs::File::open(parent).await?.sync_all().await
+            }
+            Durability::Group => {
+                self.dirty.store(true, Ordering::Relaxed);
+                Ok(())
+            }
+            _ => Ok(()),
+        }
+    }
+
+    /// Blockierende Variante von write_atomic (für Aufrufer außerhalb von Async-Code)
+    pub fn write_atomic_blocking(&self, temp: &Path, path: &Path, data: &[u8]) -> std::io::Result<()> {
+        use std::io::Write;
+
+        self.write_atomic_with(temp, path, |file| file.write_all(data))
+    }
+
+    /// Wie write_atomic_blocking, den Inhalt schreibt `write` stückweise in die tmp-Datei (große Dateien müssen
+    /// so nicht am Stück im Speicher liegen)
+    pub fn write_atomic_with<E: From<std::io::Error>>(
+        &self,
+        temp: &Path,
+        path: &Path,
+        write: impl FnOnce(&mut std::fs::File) -> Result<(), E>,
+    ) -> Result<(), E> {
+        let mut file = std::fs::File::create(temp)?;
+        write(&mut file)?;
+        match self.mode {
+            Durability::Flush => file.sync_data()?,
+            Durability::FsyncEach => file.sync_all()?,
+            _ => {}
+        }
+        drop(file);
+        perms::apply_file(temp);
+        std::fs::rename(temp, path)?;
+
+        match self.mode {
+            Durability::FsyncEach => std::fs::File::open(path.parent().unwrap_or(&self.dir))?.sync_all()?,
+            Durability::Group => self.dirty.store(true, Ordering::Relaxed),
+            _ => {}
+        }
+        Ok(())
+    }
+
+    /// Synct das Dateisystem des Output-Verzeichnisses, falls seit dem letzten Aufruf geschrieben wurde
+    pub async fn sync_pending(&self) -> std::io::Result<()> {
+        if !self.dirty.swap(false, Ordering::Relaxed) {
+            return Ok(());
+        }
+        let dir = self.dir.clone();
+        tokio::task::spawn_blocking(move || sync_filesystem(&dir)).await?
+    }
+}
+
+#[cfg(target_os = "linux")]
+fn sync_filesystem(dir: &Path) -> std::io::Result<()> {
+    use std::os::unix::io::AsRawFd;
+
+    let handle = std::fs::File::open(dir)?;
+    if unsafe { libc::syncfs(handle.as_raw_fd()) } != 0 {
+        return Err(std::io::Error::last_os_error());
+    }
+    Ok(())
+}
+
+#[cfg(all(unix, not(target_os = "linux")))]
+fn sync_filesystem(_dir: &Path) -> std::io::Result<()> {
+    unsafe { libc::sync() };
+    Ok(())
+}
+
+#[cfg(not(unix))]
+fn sync_filesystem(_dir: &Path) -> std::io::Result<()> {
+    Ok(())
+}
+
+/// Group-Commit: synct gesammelte Schreibvorgänge periodisch und beim Beenden
+pub async fn run_group_sync(fs: Arc<DurableFs>, interval_ms: u64, running: Arc<Mutex<bool>>) {
+    info!("💾 Durability: group commit every {}ms", interval_ms);
+    let mut interval_timer = interval(Duration::from_millis(interval_ms.max(1)));
+
+    while *running.lock().unwrap() {
+        interval_timer.tick().await;
+        if let Err(e) = fs.sync_pending().await {
+            warn!("⚠️  Group sync failed: {}", e);
+        }
+    }
+
+    if let Err(e) = fs.sync_pending().await {
+        warn!("⚠️  Group sync failed: {}", e);
+    }
+}
@@ -0,0 +1,68 @@
+// equivocation.rs - Evidence-Bundles für widersprüchliche Preconfs des Sequencers
+//
+// Zwei Payloads mit unterschiedlichem Block-Hash für dieselbe Höhe, die beide gegen einen akzeptierten
+// Signer verifizieren, sind eine Equivocation. Der Store legt dafür unter equivocations/ ein
+// eigenständiges JSON ab (beide Payloads, Signaturen, Signer und Metadaten), das sich ohne das übrige
+// Output-Verzeichnis veröffentlichen und nachprüfen lässt (gleiche Signatur-Nachricht wie der C-Verifier).
+
+use alloy::primitives::Address;
+
+/// Unterverzeichnis für Evidence-Bundles (nicht im Index, läuft nicht per TTL ab)
+pub const EVIDENCE_DIR_NAME: &str = "equivocations";
+
+/// Eine der beiden signierten Versionen eines Blocks
+pub struct SignedPayload<'a> {
+    pub block_hash: [u8; 32],
+    pub payload: &'a [u8], // parent_beacon_block_root + execution payload
+    pub signature: &'a [u8],
+    pub signer: Address,
+    pub signer_match: String, // signers[i] bzw. system_config
+    pub source: String,
+    pub received_unix: u64,
+    pub metadata: Option<serde_json::Value>, // gespeicherte block_*.json, falls vorhanden
+}
+
+impl SignedPayload<'_> {
+    fn to_json(&self) -> serde_json::Value {
+        serde_json::json!({
+            "block_hash": format!("0x{}", hex::encode(self.block_hash)),
+            "payload": format!("0x{}", hex::encode(self.payload)),
+            "signature": format!("0x{}", hex::encode(self.signature)),
+            "signer": format!("{}", self.signer),
+            "signer_match": self.signer_match,
+            "source": self.source,
+            "received_unix": self.received_unix,
+            "metadata": self.metadata,
+        })
+    }
+}
+
+/// Dateiname des Bundles; die Hashes werden sortiert, damit dieselbe Equivocation nur einmal abgelegt wird
+pub fn evidence_filename(chain_id: u64, block_number: u64, a: &[u8; 32], b: &[u8; 32]) -> String {
+    let (first, second) = if a <= b { (a, b) } else { (b, a) };
+    format!(
+        "equivocation_{}_{}_{}_{}.json",
+        chain_id,
+        block_number,
+        hex::encode(&first[..4]),
+        hex::encode(&second[..4])
+    )
+}
+
+/// Baut das Bundle (zuerst gespeicherte Version, dann die neu empfangene)
+pub fn evidence_bundle(
+    chain_id: u64,
+    block_number: u64,
+    first: &SignedPayload,
+    second: &SignedPayload,
+    detected_unix: u64,
+) -> serde_json::Value {
+    serde_json::json!({
+        "type": "sequencer_equivocation",
+        "chain_id": chain_id.to_string(),
+        "block_number": block_number,
+        "detected_unix": detected_unix,
+        "signing_message": "keccak256(32 zero bytes || chain_id (uint256 big-endian) || keccak256(payload))",
+        "payloads": [first.to_json(), second.to_json()],
+    })
+}
@@ -1,8 +1,13 @@
 // gossip.rs - P2P-Gossip-Netzwerk für Preconfs
 
 use crate::{
+    backpressure::StageQueue,
     config::ChainConfig,
-    processing::process_preconf_with_correct_format,
+    processing::prepare_gossip_preconf,
+    profile,
+    signer,
+    storage::{PreconfStore, PreconfWrite},
+    systemd,
     types::{BlockDeduplicator, BlockBitmaskTracker, KonaBridgeStats},
 };
 use discv5::{ConfigBuilder, enr::CombinedKey};
@@ -16,20 +21,28 @@ use std::{
     sync::{Arc, Mutex},
     time::Duration,
 };
+use tokio::{
+    sync::{broadcast::error::RecvError, Semaphore},
+    task::JoinHandle,
+};
 use tracing::{error, info, warn};
 
+/// Verify-Task eines Blocks (Blocknummer, Task), liefert den Preconf im Store-Format
+type VerifyTask = (u64, JoinHandle<Result<PreconfWrite, Box<dyn std::error::Error + Send + Sync>>>);
+
 /// Reine Gossip-Netzwerk Implementierung
 pub async fn run_gossip_network(
-    chain_id: u64,
+    _chain_id: u64,
     disc_port: u16,
     gossip_port: u16,
-    output_dir: &PathBuf,
+    _output_dir: &PathBuf, // Dateien schreibt der PreconfStore
     chain_config: &ChainConfig,
-    expected_sequencer: Option<&str>,
+    _expected_sequencer: Option<&str>, // Signer prüfen kona-p2p und der PreconfStore
     stats: Arc<Mutex<KonaBridgeStats>>,
     running: Arc<Mutex<bool>>,
     deduplicator: Option<Arc<Mutex<BlockDeduplicator>>>,
     bitmask_tracker: Option<Arc<Mutex<BlockBitmaskTracker>>>,
+    store: Arc<PreconfStore>,
 ) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
     
     let gossip = SocketAddr::new(IpAddr::V4(Ipv4Addr::new(0, 0, 0, 0)), gossip_port);
@@ -47,40 +60,53 @@ pub async fn run_gossip_network(
     let gossip_key = Keypair::generate_secp256k1();
 
     tracing::debug!("🔍 Looking up rollup config for chain {}", chain_config.chain_id);
-    let cfg = ROLLUP_CONFIGS
-        .get(&chain_config.chain_id)
-        .or_else(|| {
-            warn!("⚠️  Rollup config not found for chain {}, using Base as fallback", chain_config.chain_id);
-            ROLLUP_CONFIGS.get(&8453) // Use Base as fallback
-        })
-        .ok_or_else(|| format!("No rollup config found for chain {} or Base fallback", chain_config.chain_id))?
-        .clone();
+    // rollup.json der Chain (KONA_BRIDGE_ROLLUP_CONFIG) vor kona-registry
+    let cfg = match chain_config.rollup_config {
+        Some(ref cfg) => cfg.clone(),
+        None => ROLLUP_CONFIGS
+            .get(&chain_config.chain_id)
+            .or_else(|| {
+                warn!(
+                    "⚠️  Rollup config not found for chain {}, using Base as fallback (set KONA_BRIDGE_ROLLUP_CONFIG)",
+                    chain_config.chain_id
+                );
+                ROLLUP_CONFIGS.get(&8453) // Use Base as fallback
+            })
+            .ok_or_else(|| format!("No rollup config found for chain {} or Base fallback", chain_config.chain_id))?
+            .clone(),
+    };
     
     tracing::debug!("✅ Found rollup config for chain {}", chain_config.chain_id);
 
     tracing::debug!("🔍 Discovery: 0.0.0.0:{}", disc_port);
     tracing::debug!("📡 Gossip: 0.0.0.0:{}", gossip_port);
-    tracing::debug!("🔐 Expected sequencer: {}", chain_config.unsafe_signer);
+    let unsafe_signer = chain_config.current_signer();
+    tracing::debug!("🔐 Expected sequencer: {}", unsafe_signer);
 
-    // Thread-optimized P2P: Use first 3 bootnodes for reliable discovery
-    let optimized_bootnodes = if chain_config.bootnodes.len() > 3 {
-        chain_config.bootnodes[0..3].to_vec() // Use first 3 bootnodes
-    } else {
-        chain_config.bootnodes.clone() // Use all available
-    };
+    // Thread-optimized P2P: Use the first max_peers bootnodes (3, profile tiny: 2) for reliable discovery
+    let profile = profile::current();
+    let optimized_bootnodes: Vec<_> = chain_config.bootnodes.iter().take(profile.max_peers).cloned().collect();
     
     tracing::debug!("🔧 Thread-optimized P2P: Using {} bootnode(s) for reliable discovery", optimized_bootnodes.len());
+
+    // Ohne Discovery (Profil tiny) bleibt discv5 bei den Bootnodes: keine gefundenen Knoten weitergeben,
+    // keine fremden Knoten in die Routing-Tabelle aufnehmen, Sessions nur für die Peers
+    let mut discovery_config = ConfigBuilder::new(disc_listen.into());
+    if !profile.discovery {
+        discovery_config
+            .disable_report_discovered_peers()
+            .incoming_bucket_limit(0)
+            .query_parallelism(1)
+            .session_cache_capacity(profile.max_peers);
+    }
     
     let mut network = Network::builder()
         .with_rollup_config(cfg)
-        .with_unsafe_block_signer(chain_config.unsafe_signer)
+        .with_unsafe_block_signer(unsafe_signer)
         .with_discovery_address(disc)
         .with_gossip_address(gossip_addr)
         .with_keypair(gossip_key)
-        .with_discovery_config(
-            ConfigBuilder::new(disc_listen.into())
-                .build() // Minimal discovery config
-        )
+        .with_discovery_config(discovery_config.build()) // Minimal discovery config
         .build()
         .map_err(|e| format!("Failed to build P2P network: {}", e))?;
 
@@ -97,6 +123,12 @@ pub async fn run_gossip_network(
     }
 
     let mut payload_recv = network.unsafe_block_recv();
+
+    // Rotationen des Signers (SystemConfig auf L1) an die Signaturprüfung weiterreichen
+    if let Some(updates) = chain_config.signer_updates.clone() {
+        tokio::spawn(signer::forward_signer_updates(updates, network.unsafe_block_signer_sender()));
+    }
+
     network
         .start()
         .await
@@ -109,133 +141,194 @@ pub async fn run_gossip_network(
     {
         let mut stats_guard = stats.lock().unwrap();
         stats_guard.connected_peers = 1; // Will update with real peer count
+        store.set_gossip_peers(stats_guard.connected_peers);
     }
 
+    // Pipeline: Empfang -> Verify-Stufe (SSZ, Keccak, Recovery, bis zu gossip_workers parallel) -> Write-Stufe.
+    // Die Queue hält die Verify-Tasks in Empfangsreihenfolge, damit die Write-Stufe latest in Ordnung schreibt;
+    // ist sie voll (langsame Platte), entscheidet die Drop-Policy der Chain (siehe backpressure.rs)
+    let queue = Arc::new(StageQueue::new(profile.gossip_queue));
+    let drop_policy = store.drop_policy();
+    let storage_stats = store.storage_stats();
+    let verify_permits = Arc::new(Semaphore::new(profile.gossip_workers));
+    let writer = tokio::spawn(run_write_stage(
+        queue.clone(),
+        store.clone(),
+        stats.clone(),
+        deduplicator.clone(),
+        bitmask_tracker,
+    ));
+
+    // Gossip-Fenster des Checkpoints: im Hybrid-Modus kennt der Deduplikator die Blöcke schon, im reinen
+    // Gossip-Modus setzt die Lückenerkennung nach einem Absturz beim zuletzt verarbeiteten Block an
+    let window = store.gossip_window();
     let mut latest_block_number = 0u64;
-    let mut last_status_block = 0u64;
-    const STATUS_INTERVAL: u64 = 200; // Status alle 200 Blöcke (ca. 6 Minuten)
+    match deduplicator {
+        Some(ref dedup_arc) => dedup_arc.lock().unwrap().resume(&window),
+        None => latest_block_number = window.iter().copied().max().unwrap_or(0),
+    }
+    if latest_block_number > 0 {
+        info!("📍 GOSSIP: Resuming after block {}", latest_block_number);
+    }
 
-    // Memory-optimized event loop with reduced polling frequency
     while *running.lock().unwrap() {
-        // Memory-optimized: Check every 2 seconds (faster response, less memory buildup)
-        tokio::time::sleep(Duration::from_secs(2)).await;
-        
-        if !*running.lock().unwrap() {
-            break;
+        systemd::beat(chain_config.chain_id);
+
+        let payload_envelope = tokio::select! {
+            received = payload_recv.recv() => match received {
+                Ok(payload_envelope) => payload_envelope,
+                Err(RecvError::Closed) => {
+                    info!("🛑 GOSSIP: Receiver closed");
+                    break;
+                }
+                Err(RecvError::Lagged(skipped)) => {
+                    storage_stats.record_gossip_dropped(skipped);
+                    warn!("📡 GOSSIP: Receiver lagged - skipped {} messages", skipped);
+                    continue;
+                }
+            },
+            // Laufzeit-Flag und Watchdog auch ohne neue Blöcke prüfen
+            _ = tokio::time::sleep(Duration::from_secs(2)) => continue,
+        };
+
+        let hash = payload_envelope.payload.block_hash();
+        let number = payload_envelope.payload.block_number();
+
+        // Reduziertes Logging: Nur Debug-Level für einzelne Blöcke
+        tracing::debug!("🎉 P2P: PRECONF RECEIVED! Block #{} Hash: {}", number, hash);
+
+        // Update received stats
+        {
+            let mut stats_guard = stats.lock().unwrap();
+            stats_guard.received_preconfs += 1;
+            stats_guard.gossip_received += 1;
+        }
+
+        // Process preconf (only if newer)
+        if number <= latest_block_number {
+            info!("⏭️  GOSSIP: Skipping old preconf #{}", number);
+            continue;
         }
-        
-        // Check for new payloads (every 5 seconds)
-        match payload_recv.try_recv() {
-            Ok(payload_envelope) => {
-                let hash = payload_envelope.payload.block_hash();
-                let number = payload_envelope.payload.block_number();
-                
-                // Reduziertes Logging: Nur Debug-Level für einzelne Blöcke
-                tracing::debug!("🎉 P2P: PRECONF RECEIVED! Block #{} Hash: {}", number, hash);
 
-                // Update received stats
+        // Race-Condition-Schutz: Prüfe Deduplizierung ZUERST
+        let is_duplicate = if let Some(ref dedup_arc) = deduplicator {
+            let dedup = dedup_arc.lock().unwrap();
+            dedup.is_duplicate(number)
+        } else {
+            false
+        };
+
+        // Bereits über HTTP gespeichert: geht trotzdem an den Store, der nur die Herkunft ergänzt (siehe provenance.rs)
+        if is_duplicate {
+            tracing::debug!("🛡️  GOSSIP: Block {} already processed by HTTP - recording provenance", number);
+        }
+
+        // Check for gaps in gossip stream NACH Deduplizierung (nur echte Gaps)
+        if latest_block_number > 0 && !is_duplicate {
+            let gap = number.saturating_sub(latest_block_number);
+            if gap > 1 {
+                let missing_blocks = gap - 1;
+                tracing::debug!("📡 GOSSIP: Real gap detected {} -> {} (missing {} blocks)",
+                              latest_block_number, number, missing_blocks);
+
+                // Update gap statistics for Gossip mode (nur Gossip-spezifisch)
                 {
                     let mut stats_guard = stats.lock().unwrap();
-                    stats_guard.received_preconfs += 1;
-                    stats_guard.gossip_received += 1;
+                    // total_gaps wird nur beim finalen Processing gezählt
+                    stats_guard.gossip_gaps += missing_blocks as u32;
                 }
-
-                    // Process preconf (only if newer)
-                    if number > latest_block_number {
-                        // Race-Condition-Schutz: Prüfe Deduplizierung ZUERST
-                        let is_duplicate = if let Some(ref dedup_arc) = deduplicator {
-                            let dedup = dedup_arc.lock().unwrap();
-                            dedup.is_duplicate(number)
-                        } else {
-                            false
-                        };
-                        
-                        if is_duplicate {
-                            tracing::debug!("🛡️  GOSSIP: Block {} already processed by HTTP - skipping", number);
-                            // received_preconfs already incremented, but not processed (due to deduplication)
-                            continue;
-                        }
-                        
-                        // Check for gaps in gossip stream NACH Deduplizierung (nur echte Gaps)
-                        if latest_block_number > 0 {
-                            let gap = number.saturating_sub(latest_block_number);
-                            if gap > 1 {
-                                let missing_blocks = gap - 1;
-                                tracing::debug!("📡 GOSSIP: Real gap detected {} -> {} (missing {} blocks)", 
-                                              latest_block_number, number, missing_blocks);
-                                
-                                // Update gap statistics for Gossip mode (nur Gossip-spezifisch)
-                                {
-                                    let mut stats_guard = stats.lock().unwrap();
-                                    // total_gaps wird nur beim finalen Processing gezählt
-                                    stats_guard.gossip_gaps += missing_blocks as u32;
-                                }
-                            }
-                        }
-                    
-                    match process_preconf_with_correct_format(
-                        &payload_envelope, 
-                        chain_id, 
-                        output_dir, 
-                        expected_sequencer
-                    ).await {
-                        Ok(()) => {
-                            latest_block_number = number;
-                            let mut stats_guard = stats.lock().unwrap();
-                            stats_guard.processed_preconfs += 1;
-                            stats_guard.gossip_processed += 1;
-                            
-                            // CRITICAL FIX: Update bitmask tracker with processed block
-                            if let Some(ref bitmask_tracker_arc) = bitmask_tracker {
-                                let mut tracker = bitmask_tracker_arc.lock().unwrap();
-                                tracker.mark_block_processed(number);
-                                tracing::debug!("🎯 GOSSIP: Marked block {} in bitmask tracker", number);
-                            }
-                            
-                            // Mark block as processed in deduplicator
-                            if let Some(ref dedup_arc) = deduplicator {
-                                let mut dedup = dedup_arc.lock().unwrap();
-                                dedup.mark_processed(number);
-                            }
-                            
-                            // Periodische Statusmeldung alle 200 Blöcke
-                            if number >= last_status_block + STATUS_INTERVAL {
-                                let skipped = stats_guard.gossip_received - stats_guard.gossip_processed;
-                                info!("📡 GOSSIP Status: Block #{}, Total processed: {}, HTTP: {}, Gossip: {} (skipped: {})", 
-                                      number, stats_guard.processed_preconfs, 
-                                      stats_guard.http_processed, stats_guard.gossip_processed, skipped);
-                                last_status_block = number;
-                            }
-                            
-                            // Reduziertes Logging: Nur Debug-Level für einzelne Blöcke
-                            tracing::debug!("✅ GOSSIP: Processed (total: {})", stats_guard.processed_preconfs);
-                        }
-                        Err(e) => {
-                            let mut stats_guard = stats.lock().unwrap();
-                            stats_guard.failed_preconfs += 1;
-                            error!("❌ GOSSIP: Failed: {}", e);
-                        }
-                    }
-                } else {
-                    info!("⏭️  GOSSIP: Skipping old preconf #{}", number);
-                }
-            }
-            Err(tokio::sync::broadcast::error::TryRecvError::Empty) => {
-                // GPT-5 Hot-Thread Fix: yield_now() statt busy-waiting
-                tokio::task::yield_now().await;
-                continue;
-            }
-            Err(tokio::sync::broadcast::error::TryRecvError::Closed) => {
-                info!("🛑 GOSSIP: Receiver closed");
-                break;
-            }
-            Err(tokio::sync::broadcast::error::TryRecvError::Lagged(skipped)) => {
-                tracing::debug!("📡 GOSSIP: Receiver lagged - skipped {} messages", skipped);
-                continue;
             }
         }
+
+        latest_block_number = number;
+
+        let permits = verify_permits.clone();
+        let verify_store = store.clone();
+        let task = tokio::spawn(async move {
+            let _permit = permits.acquire_owned().await?;
+            let preconf = tokio::task::spawn_blocking(move || prepare_gossip_preconf(&payload_envelope)).await?;
+            verify_store.prefetch_signer(&preconf).await;
+            Ok(preconf)
+        });
+        if let Some((dropped_block, dropped_task)) = queue.push((number, task), drop_policy).await {
+            dropped_task.abort();
+            storage_stats.record_gossip_dropped(1);
+            stats.lock().unwrap().failed_preconfs += 1;
+            warn!(
+                "⚠️  GOSSIP: Pipeline full ({} blocks, {}), dropped block {}",
+                profile.gossip_queue, drop_policy, dropped_block
+            );
+        }
+    }
+
+    // Bereits angenommene Blöcke noch schreiben
+    queue.close();
+    if let Err(e) = writer.await {
+        error!("❌ GOSSIP: Write stage failed: {}", e);
     }
 
     info!("🛑 Gossip network stopping...");
     Ok(())
 }
+
+/// Write-Stufe der Gossip-Pipeline: wartet die Verify-Tasks in Empfangsreihenfolge ab und schreibt nacheinander
+async fn run_write_stage(
+    pending: Arc<StageQueue<VerifyTask>>,
+    store: Arc<PreconfStore>,
+    stats: Arc<Mutex<KonaBridgeStats>>,
+    deduplicator: Option<Arc<Mutex<BlockDeduplicator>>>,
+    bitmask_tracker: Option<Arc<Mutex<BlockBitmaskTracker>>>,
+) {
+    let mut last_status_block = 0u64;
+    const STATUS_INTERVAL: u64 = 200; // Status alle 200 Blöcke (ca. 6 Minuten)
+
+    while let Some((_, task)) = pending.pop().await {
+        let result = match task.await {
+            Ok(Ok(preconf)) => {
+                let number = preconf.block_number;
+                store.write(preconf).await.map(|()| number)
+            }
+            Ok(Err(e)) => Err(e),
+            Err(e) => Err(e.into()),
+        };
+
+        match result {
+            Ok(number) => {
+                store.record_gossip(number);
+                let mut stats_guard = stats.lock().unwrap();
+                stats_guard.processed_preconfs += 1;
+                stats_guard.gossip_processed += 1;
+
+                // CRITICAL FIX: Update bitmask tracker with processed block
+                if let Some(ref bitmask_tracker_arc) = bitmask_tracker {
+                    let mut tracker = bitmask_tracker_arc.lock().unwrap();
+                    tracker.mark_block_processed(number);
+                    tracing::debug!("🎯 GOSSIP: Marked block {} in bitmask tracker", number);
+                }
+
+                // Mark block as processed in deduplicator
+                if let Some(ref dedup_arc) = deduplicator {
+                    let mut dedup = dedup_arc.lock().unwrap();
+                    dedup.mark_processed(number);
+                }
+
+                // Periodische Statusmeldung alle 200 Blöcke
+                if number >= last_status_block + STATUS_INTERVAL {
+                    let skipped = stats_guard.gossip_received - stats_guard.gossip_processed;
+                    info!("📡 GOSSIP Status: Block #{}, Total processed: {}, HTTP: {}, Gossip: {} (skipped: {})",
+                          number, stats_guard.processed_preconfs,
+                          stats_guard.http_processed, stats_guard.gossip_processed, skipped);
+                    last_status_block = number;
+                }
+
+                // Reduziertes Logging: Nur Debug-Level für einzelne Blöcke
+                tracing::debug!("✅ GOSSIP: Processed (total: {})", stats_guard.processed_preconfs);
+            }
+            Err(e) => {
+                let mut stats_guard = stats.lock().unwrap();
+                stats_guard.failed_preconfs += 1;
+                error!("❌ GOSSIP: Failed: {}", e);
+            }
+        }
+    }
+}
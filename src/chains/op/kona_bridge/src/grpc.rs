@@ -0,0 +1,274 @@
+// grpc.rs - gRPC-Service für gespeicherte Preconfs (KONA_BRIDGE_GRPC_ADDR, proto/preconf.proto)
+//
+// GetPreconf, GetRange (Server-Streaming), StreamNewPreconfs (Server-Streaming) und GetStats für interne
+// Dienste, die Preconfs typisiert und in großer Zahl konsumieren. Läuft im selben Thread wie die HTTP-API
+// (serve.rs) und nutzt deren Registry und Push-Kanäle; Chains werden dort beim Start registriert.
+
+use crate::{
+    access::AccessControl,
+    codec::PreconfContainer,
+    handoff,
+    serve::{served_store, subscribe, Subscription},
+    sink::StoredPreconf,
+    stats::StorageSnapshot,
+    storage::PreconfStore,
+    tls::{self, Tls},
+};
+use std::{io, net::SocketAddr, sync::Arc};
+use tokio::sync::{broadcast, mpsc};
+use tokio_stream::{
+    wrappers::{ReceiverStream, TcpListenerStream},
+    StreamExt,
+};
+use tonic::{Request, Response, Status};
+use tracing::{debug, info, warn};
+
+pub mod proto {
+    tonic::include_proto!("colibri.preconf.v1");
+}
+
+use proto::{
+    preconf_service_server::{PreconfService, PreconfServiceServer},
+    GetPreconfRequest, GetRangeRequest, GetStatsRequest, Preconf, Stats, StreamRequest,
+};
+
+/// Höchstzahl Blöcke pro GetRange
+const MAX_RANGE: u64 = 1024;
+/// Gepufferte Nachrichten pro Stream, bevor auf den Client gewartet wird
+const STREAM_BUFFER: usize = 16;
+
+type PreconfStream = ReceiverStream<Result<Preconf, Status>>;
+
+/// Startet den gRPC-Server (läuft bis zum Prozessende); Clients außerhalb von KONA_BRIDGE_API_ALLOW werden
+/// schon beim Verbindungsaufbau getrennt
+pub async fn run_grpc_server(addr: SocketAddr, access_control: Arc<AccessControl>, tls: Option<Arc<Tls>>) {
+    let listener = match handoff::bind_tcp(addr).await {
+        Ok(listener) => listener,
+        Err(e) => {
+            warn!("⚠️  gRPC service disabled, cannot bind {}: {}", addr, e);
+            return;
+        }
+    };
+    let server = tonic::transport::Server::builder().add_service(PreconfServiceServer::new(PreconfGrpc));
+    let allowed = move |peer: SocketAddr| {
+        let allowed = access_control.peer_allowed(peer.ip());
+        if !allowed {
+            debug!("🚫 Rejected gRPC connection from {} (not in allowlist)", peer.ip());
+        }
+        allowed
+    };
+    let result = match tls {
+        Some(tls) => {
+            let config = match tls.server_config(tls::ALPN_GRPC) {
+                Ok(config) => config,
+                Err(e) => {
+                    warn!("⚠️  gRPC service disabled, TLS setup failed: {}", e);
+                    return;
+                }
+            };
+            info!("🛰️  gRPC service listening on {} (TLS)", addr);
+            let incoming = ReceiverStream::new(tls::accept(listener, config))
+                .filter(move |(_, peer)| allowed(*peer))
+                .map(|(stream, _)| Ok::<_, io::Error>(stream));
+            server.serve_with_incoming_shutdown(incoming, handoff::handed_over()).await
+        }
+        None => {
+            info!("🛰️  gRPC service listening on {}", addr);
+            // Fehler beim Accept (z.B. keine File-Deskriptoren) sollen den Server nicht beenden
+            let incoming = TcpListenerStream::new(listener)
+                .filter_map(Result::ok)
+                .filter(move |stream| stream.peer_addr().is_ok_and(|peer| allowed(peer)))
+                .map(Ok::<_, io::Error>);
+            server.serve_with_incoming_shutdown(incoming, handoff::handed_over()).await
+        }
+    };
+    if let Err(e) = result {
+        warn!("⚠️  gRPC service stopped: {}", e);
+    }
+}
+
+struct PreconfGrpc;
+
+fn chain_store(chain_id: u64) -> Result<Arc<PreconfStore>, Status> {
+    served_store(chain_id).ok_or_else(|| Status::not_found(format!("chain {} not served", chain_id)))
+}
+
+/// Signatur und (optional) dekomprimierter Payload aus einer .raw Datei
+fn unpack(data: &[u8], include_payload: bool) -> Result<(Vec<u8>, Vec<u8>), Box<dyn std::error::Error + Send + Sync>> {
+    let container = PreconfContainer::parse(data)?;
+    let payload = if include_payload { container.payload()? } else { Vec::new() };
+    Ok((container.signature.to_vec(), payload))
+}
+
+async fn unpack_blocking(data: Vec<u8>, include_payload: bool) -> Result<(Vec<u8>, Vec<u8>), Status> {
+    match tokio::task::spawn_blocking(move || unpack(&data, include_payload)).await {
+        Ok(Ok(unpacked)) => Ok(unpacked),
+        Ok(Err(e)) => Err(Status::internal(e.to_string())),
+        Err(e) => Err(Status::internal(e.to_string())),
+    }
+}
+
+/// Liest einen gespeicherten Block (None, wenn nicht vorhanden)
+async fn load_preconf(store: &PreconfStore, block_number: u64, include_payload: bool) -> Result<Option<Preconf>, Status> {
+    let Some(filename) = store.filename_by_number(block_number) else {
+        return Ok(None);
+    };
+    let data = match store.read_raw(&filename).await {
+        Ok(data) => data,
+        // Datei kann zwischen Lookup und Lesen vom TTL-Cleanup entfernt worden sein
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => return Ok(None),
+        Err(e) => return Err(Status::internal(e.to_string())),
+    };
+    let (signature, payload) = unpack_blocking(data, include_payload).await?;
+
+    let metadata = store.read_metadata(block_number).await.unwrap_or(serde_json::Value::Null);
+    let block_hash = metadata["block_hash"]
+        .as_str()
+        .and_then(|hash| hex::decode(hash.trim_start_matches("0x")).ok())
+        .unwrap_or_default();
+    Ok(Some(Preconf {
+        chain_id: store.chain_id(),
+        block_number,
+        block_hash,
+        timestamp: metadata["timestamp"].as_u64().unwrap_or(0),
+        received_unix: metadata["received_unix"].as_u64().unwrap_or(0),
+        signature,
+        payload,
+        metadata_json: metadata.to_string(),
+        skipped: 0,
+    }))
+}
+
+/// Nachricht zu einem gerade gespeicherten Block (Push-Kanal)
+async fn stored_preconf(preconf: &StoredPreconf, include_payload: bool, skipped: u64) -> Result<Preconf, Status> {
+    let (signature, payload) = unpack_blocking(preconf.raw_data.clone(), include_payload).await?;
+    Ok(Preconf {
+        chain_id: preconf.chain_id,
+        block_number: preconf.block_number,
+        block_hash: preconf.block_hash.to_vec(),
+        timestamp: preconf.timestamp,
+        received_unix: preconf.received_unix,
+        signature,
+        payload,
+        metadata_json: preconf.metadata.to_string(),
+        skipped,
+    })
+}
+
+fn stats_message(snapshot: StorageSnapshot) -> Stats {
+    Stats {
+        entries: snapshot.entries,
+        total_bytes: snapshot.total_bytes,
+        oldest_block: snapshot.oldest_block,
+        newest_block: snapshot.newest_block,
+        oldest_age_secs: snapshot.oldest_age_secs,
+        newest_age_secs: snapshot.newest_age_secs,
+        stored_http: snapshot.stored_http,
+        stored_gossip: snapshot.stored_gossip,
+        stored_other: snapshot.stored_other,
+        evicted_ttl: snapshot.evicted_ttl,
+        evicted_quota: snapshot.evicted_quota,
+        evicted_finality: snapshot.evicted_finality,
+        invalid_signatures: snapshot.invalid_signatures,
+        equivocations: snapshot.equivocations,
+        oversized_payloads: snapshot.oversized_payloads,
+        gossip_dropped: snapshot.gossip_dropped,
+        sampled_out: snapshot.sampled_out,
+        reorgs: snapshot.reorgs,
+        gaps: snapshot.gaps,
+        backwards: snapshot.backwards,
+        dns_failures: snapshot.dns_failures,
+        canonical_matched: snapshot.canonical_matched,
+        canonical_mismatched: snapshot.canonical_mismatched,
+        canonical_tx_mismatched: snapshot.canonical_tx_mismatched,
+    }
+}
+
+#[tonic::async_trait]
+impl PreconfService for PreconfGrpc {
+    async fn get_preconf(&self, request: Request<GetPreconfRequest>) -> Result<Response<Preconf>, Status> {
+        let request = request.into_inner();
+        let store = chain_store(request.chain_id)?;
+        let block_number = match request.block_number {
+            0 => store.latest_entry().map(|(number, _)| number).ok_or_else(|| Status::not_found("no preconf stored yet"))?,
+            number => number,
+        };
+        match load_preconf(&store, block_number, request.include_payload).await? {
+            Some(preconf) => Ok(Response::new(preconf)),
+            None => Err(Status::not_found(format!("block {} not found", block_number))),
+        }
+    }
+
+    type GetRangeStream = PreconfStream;
+
+    async fn get_range(&self, request: Request<GetRangeRequest>) -> Result<Response<Self::GetRangeStream>, Status> {
+        let request = request.into_inner();
+        let store = chain_store(request.chain_id)?;
+        if request.from_block > request.to_block {
+            return Err(Status::invalid_argument("from_block > to_block"));
+        }
+        if request.to_block - request.from_block >= MAX_RANGE {
+            return Err(Status::invalid_argument(format!("range exceeds {} blocks", MAX_RANGE)));
+        }
+
+        let (sender, receiver) = mpsc::channel(STREAM_BUFFER);
+        tokio::spawn(async move {
+            for block_number in request.from_block..=request.to_block {
+                let item = match load_preconf(&store, block_number, request.include_payload).await {
+                    Ok(Some(preconf)) => Ok(preconf),
+                    Ok(None) => continue,
+                    Err(status) => Err(status),
+                };
+                if sender.send(item).await.is_err() {
+                    break; // Client hat den Stream geschlossen
+                }
+            }
+        });
+        Ok(Response::new(ReceiverStream::new(receiver)))
+    }
+
+    type StreamNewPreconfsStream = PreconfStream;
+
+    async fn stream_new_preconfs(
+        &self,
+        request: Request<StreamRequest>,
+    ) -> Result<Response<Self::StreamNewPreconfsStream>, Status> {
+        let request = request.into_inner();
+        chain_store(request.chain_id)?;
+        let subscription = Subscription::open().ok_or_else(|| Status::resource_exhausted("too many subscribers"))?;
+        let mut updates = subscribe(request.chain_id);
+
+        let (sender, receiver) = mpsc::channel(STREAM_BUFFER);
+        tokio::spawn(async move {
+            let _subscription = subscription;
+            let mut skipped = 0;
+            loop {
+                let preconf = match updates.recv().await {
+                    Ok(preconf) => preconf,
+                    // Zu langsam gelesen: verlorene Blöcke mit dem nächsten Event melden
+                    Err(broadcast::error::RecvError::Lagged(lost)) => {
+                        skipped += lost;
+                        continue;
+                    }
+                    Err(broadcast::error::RecvError::Closed) => break,
+                };
+                let item = stored_preconf(&preconf, request.include_payload, skipped).await;
+                skipped = 0;
+                if sender.send(item).await.is_err() {
+                    break;
+                }
+            }
+            debug!("🛰️  gRPC subscriber for chain {} disconnected", request.chain_id);
+        });
+        Ok(Response::new(ReceiverStream::new(receiver)))
+    }
+
+    async fn get_stats(&self, request: Request<GetStatsRequest>) -> Result<Response<Stats>, Status> {
+        let stats = chain_store(request.into_inner().chain_id)?.storage_stats();
+        // Verzeichnis-Scan blockiert
+        match tokio::task::spawn_blocking(move || stats.snapshot()).await {
+            Ok(snapshot) => Ok(Response::new(stats_message(snapshot))),
+            Err(e) => Err(Status::internal(e.to_string())),
+        }
+    }
+}
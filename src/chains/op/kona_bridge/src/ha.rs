@@ -0,0 +1,456 @@
+// ha.rs - Aktiv/Standby: zwei Instanzen, genau eine schreibt ins Output-Verzeichnis
+//
+// Mit KONA_BRIDGE_HA bewerben sich beide Instanzen je Chain um eine Lease; wer sie hält, ist aktiv und läuft wie
+// ohne HA. Die andere ist Standby: sie pollt die HTTP-Quelle im Dry-Run (parsen, Blocknummer, Signatur prüfen, nichts
+// schreiben), bleibt so warm und versucht alle lease_secs / 3, die Lease zu bekommen. Backends:
+//   file[:pfad]          flock auf {output_dir}/bridge_{chain_id}.lease (geteiltes Verzeichnis, z.B. NFSv4), der
+//                        Kernel gibt sie frei, sobald der aktive Prozess endet; Heartbeat steht in der Datei
+//   redis://...          Key kona_bridge:lease:{chain_id} mit Ablauf (SET ... PX, Erneuerung per Lua nur durch den
+//                        Halter), "redis" nimmt KONA_BRIDGE_REDIS_URL
+//   postgres://...       Zeile in kona_bridge_lease mit expires_at (Upsert nur durch den Halter oder nach Ablauf),
+//                        "postgres" nimmt KONA_BRIDGE_POSTGRES_URL
+// Erneuert die aktive Instanz nicht mehr, läuft die Lease nach KONA_BRIDGE_HA_LEASE_SECS (Default 10) ab und der
+// Standby übernimmt innerhalb von Sekunden. Verliert die aktive Instanz die Lease (übernommen oder Backend länger
+// als die Lease-Dauer nicht erreichbar), stoppt sie die Chain mit Fehler, statt neben dem Nachfolger zu schreiben;
+// unter systemd mit Restart=on-failure startet sie als Standby neu. Beim Beenden wird die Lease freigegeben.
+// Die Lease ersetzt die Verzeichnis-Sperre (dirlock.rs), die über Hosts hinweg nicht zuverlässig ist.
+
+use crate::{
+    dirlock::{try_flock, Attempt},
+    http::{fetch_http_preconf, parse_http_preconf, DEFAULT_MAX_PAYLOAD_BYTES},
+    redis::{parse_url, RedisConnection, RedisTarget, Reply},
+    reload::RuntimeConfig,
+    settings::HaSettings,
+    signer::recover_signer,
+    systemd,
+    utils::extract_block_number_from_preconf_data,
+};
+use alloy::primitives::Address;
+use std::{
+    fs::{File, OpenOptions},
+    os::unix::fs::FileExt,
+    path::{Path, PathBuf},
+    sync::{Arc, Mutex},
+    time::{Duration, Instant, SystemTime, UNIX_EPOCH},
+};
+use tokio::{sync::watch, time::interval};
+use tokio_postgres::{Client, NoTls};
+use tracing::{debug, error, info, warn};
+
+pub const DEFAULT_LEASE_SECS: u64 = 10;
+/// Abstand der Dry-Run-Zusammenfassung im Log
+const STANDBY_LOG_INTERVAL: Duration = Duration::from_secs(60);
+
+const REDIS_KEY_PREFIX: &str = "kona_bridge:lease:";
+/// Setzt die Lease, wenn sie frei ist oder schon diesem Knoten gehört (1 = gehalten)
+const REDIS_ACQUIRE: &str = "local holder = redis.call('GET', KEYS[1]) \
+    if holder == false or holder == ARGV[1] then redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2]) return 1 end \
+    return 0";
+/// Löscht die Lease nur, wenn sie diesem Knoten gehört
+const REDIS_RELEASE: &str = "if redis.call('GET', KEYS[1]) == ARGV[1] then return redis.call('DEL', KEYS[1]) end \
+    return 0";
+
+const PG_SCHEMA: &str = "
+CREATE TABLE IF NOT EXISTS kona_bridge_lease (
+    chain_id   BIGINT PRIMARY KEY,
+    holder     TEXT        NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+)";
+const PG_ACQUIRE: &str = "
+INSERT INTO kona_bridge_lease (chain_id, holder, expires_at) VALUES ($1, $2, now() + make_interval(secs => $3))
+ON CONFLICT (chain_id) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+WHERE kona_bridge_lease.holder = EXCLUDED.holder OR kona_bridge_lease.expires_at < now()";
+const PG_RELEASE: &str = "DELETE FROM kona_bridge_lease WHERE chain_id = $1 AND holder = $2";
+const PG_HOLDER: &str = "SELECT holder FROM kona_bridge_lease WHERE chain_id = $1 AND expires_at >= now()";
+
+/// Wo die Lease liegt
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum LeaseBackend {
+    File(Option<PathBuf>), // None = {output_dir}/bridge_{chain_id}.lease
+    Redis(String),
+    Postgres(String),
+}
+
+impl LeaseBackend {
+    pub fn parse(value: &str) -> Option<Self> {
+        match value.trim() {
+            "file" => Some(Self::File(None)),
+            value if value.starts_with("file:") => Some(Self::File(Some(PathBuf::from(&value["file:".len()..])))),
+            value if value.starts_with("redis://") => Some(Self::Redis(value.to_string())),
+            value if value.starts_with("postgres://") || value.starts_with("postgresql://") => {
+                Some(Self::Postgres(value.to_string()))
+            }
+            _ => None,
+        }
+    }
+}
+
+/// Verbindung bzw. Datei des Backends (None = noch nicht bzw. neu zu öffnen)
+enum Backend {
+    File { path: PathBuf, file: Option<File> },
+    Redis { target: RedisTarget, key: String, connection: Option<RedisConnection> },
+    Postgres { url: String, client: Option<Client> },
+}
+
+impl Backend {
+    fn describe(&self) -> String {
+        match self {
+            Backend::File { path, .. } => format!("file {}", path.display()),
+            Backend::Redis { target, .. } => format!("redis {}", target.address),
+            Backend::Postgres { .. } => "postgres".to_string(),
+        }
+    }
+}
+
+/// Lease einer Chain
+pub struct Lease {
+    backend: Backend,
+    chain_id: u64,
+    node: String,
+    lease: Duration,
+    held: bool,
+    lost: bool,
+}
+
+fn unix_now() -> u64 {
+    SystemTime::now().duration_since(UNIX_EPOCH).map_or(0, |d| d.as_secs())
+}
+
+impl Lease {
+    pub fn new(settings: &HaSettings, chain_id: u64, output_dir: &Path) -> Self {
+        let backend = match settings.backend {
+            LeaseBackend::File(ref path) => Backend::File {
+                path: path.clone().unwrap_or_else(|| output_dir.join(format!("bridge_{}.lease", chain_id))),
+                file: None,
+            },
+            LeaseBackend::Redis(ref url) => Backend::Redis {
+                target: parse_url(url),
+                key: format!("{}{}", REDIS_KEY_PREFIX, chain_id),
+                connection: None,
+            },
+            LeaseBackend::Postgres(ref url) => Backend::Postgres { url: url.clone(), client: None },
+        };
+        Self {
+            backend,
+            chain_id,
+            node: settings.node.clone(),
+            lease: Duration::from_secs(settings.lease_secs),
+            held: false,
+            lost: false,
+        }
+    }
+
+    fn renew_interval(&self) -> Duration {
+        self.lease / 3
+    }
+
+    /// Hat die aktive Instanz die Lease verloren (Chain gestoppt)?
+    pub fn is_lost(&self) -> bool {
+        self.lost
+    }
+
+    /// Holt bzw. erneuert die Lease; Ok(false) = ein anderer Knoten hält sie
+    async fn acquire(&mut self) -> Result<bool, Box<dyn std::error::Error + Send + Sync>> {
+        let held = match self.backend {
+            Backend::File { ref path, ref mut file } => {
+                if file.is_none() {
+                    let opened = OpenOptions::new().read(true).write(true).create(true).truncate(false).open(path)?;
+                    match try_flock(&opened)? {
+                        Attempt::Acquired => *file = Some(opened),
+                        Attempt::Held => return Ok(false),
+                        Attempt::Unsupported => {
+                            return Err(format!("{:?} does not support flock, use redis or postgres", path).into())
+                        }
+                    }
+                }
+                // Heartbeat für den Standby (Diagnose, die Lease selbst ist der flock)
+                let heartbeat = format!("node={}\nheartbeat_unix={}\n", self.node, unix_now());
+                if let Some(ref file) = file {
+                    file.set_len(0)?;
+                    file.write_all_at(heartbeat.as_bytes(), 0)?;
+                }
+                true
+            }
+            Backend::Redis { ref target, ref key, ref mut connection } => {
+                if connection.is_none() {
+                    *connection = Some(RedisConnection::open(target).await?);
+                }
+                let redis = connection.as_mut().expect("connected above");
+                let millis = self.lease.as_millis().to_string();
+                let args: [&[u8]; 6] =
+                    [b"EVAL", REDIS_ACQUIRE.as_bytes(), b"1", key.as_bytes(), self.node.as_bytes(), millis.as_bytes()];
+                match redis.command(&args).await {
+                    Ok(Reply::Integer(held)) => held == 1,
+                    Ok(reply) => return Err(format!("unexpected Redis reply {:?}", reply).into()),
+                    Err(e) => {
+                        // Verbindung nach Fehlern nicht wiederverwenden (Antworten könnten versetzt sein)
+                        *connection = None;
+                        return Err(e);
+                    }
+                }
+            }
+            Backend::Postgres { ref url, ref mut client } => {
+                if client.as_ref().map_or(true, Client::is_closed) {
+                    *client = Some(connect_postgres(url).await?);
+                }
+                let postgres = client.as_ref().expect("connected above");
+                let secs = self.lease.as_secs_f64();
+                postgres.execute(PG_ACQUIRE, &[&(self.chain_id as i64), &self.node, &secs]).await? == 1
+            }
+        };
+        self.held = held;
+        Ok(held)
+    }
+
+    /// Aktueller Halter laut Backend (für das Log des Standby)
+    async fn holder(&mut self) -> Option<String> {
+        match self.backend {
+            Backend::File { ref path, .. } => {
+                let content = std::fs::read_to_string(path).ok()?;
+                let mut node = None;
+                let mut heartbeat = None;
+                for line in content.lines() {
+                    match line.split_once('=') {
+                        Some(("node", value)) => node = Some(value.trim().to_string()),
+                        Some(("heartbeat_unix", value)) => heartbeat = value.trim().parse::<u64>().ok(),
+                        _ => {}
+                    }
+                }
+                let age = unix_now().saturating_sub(heartbeat?);
+                Some(format!("{} (heartbeat {}s ago)", node?, age))
+            }
+            Backend::Redis { ref key, ref mut connection, .. } => {
+                match connection.as_mut()?.command(&[b"GET", key.as_bytes()]).await.ok()? {
+                    Reply::Bulk(Some(holder)) => Some(String::from_utf8_lossy(&holder).to_string()),
+                    _ => None,
+                }
+            }
+            Backend::Postgres { ref client, .. } => {
+                let row = client.as_ref()?.query_opt(PG_HOLDER, &[&(self.chain_id as i64)]).await.ok()??;
+                row.try_get(0).ok()
+            }
+        }
+    }
+
+    /// Gibt die Lease frei, damit der Standby nicht erst den Ablauf abwarten muss
+    pub async fn release(&mut self) {
+        if !self.held {
+            return;
+        }
+        self.held = false;
+        let released = match self.backend {
+            Backend::File { ref mut file, .. } => {
+                // Schließen gibt den flock frei
+                file.take();
+                Ok(())
+            }
+            Backend::Redis { ref key, ref mut connection, .. } => match connection.as_mut() {
+                Some(redis) => {
+                    let script = REDIS_RELEASE.as_bytes();
+                    let args: [&[u8]; 5] = [b"EVAL", script, b"1", key.as_bytes(), self.node.as_bytes()];
+                    redis.command(&args).await.map(|_| ())
+                }
+                None => Ok(()),
+            },
+            Backend::Postgres { ref client, .. } => match client.as_ref() {
+                Some(postgres) => {
+                    let params: [&(dyn tokio_postgres::types::ToSql + Sync); 2] = [&(self.chain_id as i64), &self.node];
+                    postgres.execute(PG_RELEASE, &params).await.map(|_| ()).map_err(Into::into)
+                }
+                None => Ok(()),
+            },
+        };
+        match released {
+            Ok(()) => info!("👑 Released HA lease for chain {}", self.chain_id),
+            Err(e) => {
+                let expiry = self.lease.as_secs();
+                warn!("⚠️  Cannot release HA lease for chain {}, it expires in {}s: {}", self.chain_id, expiry, e)
+            }
+        }
+    }
+}
+
+async fn connect_postgres(url: &str) -> Result<Client, Box<dyn std::error::Error + Send + Sync>> {
+    let (client, connection) = tokio_postgres::connect(url, NoTls).await?;
+    tokio::spawn(async move {
+        if let Err(e) = connection.await {
+            debug!("HA lease: Postgres connection closed: {}", e);
+        }
+    });
+    client.batch_execute(PG_SCHEMA).await?;
+    Ok(client)
+}
+
+/// Dry-Run-Capture des Standby: neuester Preconf der HTTP-Quelle, geprüft, aber nicht gespeichert
+struct DryRun {
+    client: reqwest::Client,
+    chain_id: u64,
+    default_signer: Address,
+    latest: Option<u64>,
+    captured: u64,
+    rejected: u64,
+}
+
+impl DryRun {
+    async fn poll(&mut self, runtime: &watch::Receiver<RuntimeConfig>) {
+        let (endpoint, mut signers) = {
+            let config = runtime.borrow();
+            let signers: Vec<Address> = config.signers.iter().map(|entry| entry.address).collect();
+            (config.http_endpoint.clone(), signers)
+        };
+        let Some(endpoint) = endpoint else {
+            return;
+        };
+        signers.push(self.default_signer);
+
+        let preconf = match fetch_http_preconf(&self.client, &endpoint, DEFAULT_MAX_PAYLOAD_BYTES).await {
+            Ok(Some((_, preconf))) => preconf,
+            Ok(None) => return,
+            Err(e) => {
+                debug!("Standby dry-run: {} unavailable: {}", endpoint, e);
+                return;
+            }
+        };
+        let checked = parse_http_preconf(&preconf).and_then(|(data, signature)| {
+            let block_number = extract_block_number_from_preconf_data(&data)?;
+            Ok((block_number, recover_signer(&data, &signature, self.chain_id)?))
+        });
+        match checked {
+            Ok((block_number, _)) if self.latest.is_some_and(|latest| block_number <= latest) => {}
+            Ok((block_number, signer)) if signers.contains(&signer) => {
+                self.latest = Some(block_number);
+                self.captured += 1;
+            }
+            Ok((block_number, signer)) => {
+                debug!("Standby dry-run: block {} signed by unexpected {}", block_number, signer);
+                self.rejected += 1;
+            }
+            Err(e) => {
+                debug!("Standby dry-run: invalid preconf: {}", e);
+                self.rejected += 1;
+            }
+        }
+    }
+}
+
+/// Standby bis zur Lease: Dry-Run-Capture der HTTP-Quelle und regelmäßige Versuche, die Lease zu bekommen.
+/// Ok(None), wenn die Chain vorher gestoppt wird.
+pub async fn wait_for_lease(
+    mut lease: Lease,
+    runtime: watch::Receiver<RuntimeConfig>,
+    default_signer: Address,
+    stall_secs: u64,
+    running: Arc<Mutex<bool>>,
+) -> Result<Option<Lease>, Box<dyn std::error::Error + Send + Sync>> {
+    let chain_id = lease.chain_id;
+    let (backend, secs) = (lease.backend.describe(), lease.lease.as_secs());
+    info!("👑 HA: competing for the lease of chain {} as {} ({}, {}s)", chain_id, lease.node, backend, secs);
+
+    // Auch der Standby meldet sich bei systemd (READY=1 und Watchdog, siehe systemd.rs)
+    systemd::register(chain_id, stall_secs, running.clone());
+
+    let mut dry_run = DryRun {
+        client: reqwest::Client::new(),
+        chain_id,
+        default_signer,
+        latest: None,
+        captured: 0,
+        rejected: 0,
+    };
+    let mut lease_ticker = interval(lease.renew_interval());
+    let mut poll_ticker = interval(Duration::from_secs(runtime.borrow().http_poll_interval.max(1)));
+    let mut last_log = Instant::now();
+    let mut failing = false;
+    let mut standby_logged = false;
+    loop {
+        tokio::select! {
+            _ = lease_ticker.tick() => {
+                if !*running.lock().unwrap() {
+                    return Ok(None);
+                }
+                systemd::beat(chain_id);
+                match lease.acquire().await {
+                    Ok(true) => {
+                        info!("👑 HA: acquired the lease of chain {}, becoming active", chain_id);
+                        return Ok(Some(lease));
+                    }
+                    Ok(false) => {
+                        failing = false;
+                        if !standby_logged {
+                            let holder = lease.holder().await.unwrap_or_else(|| "unknown".to_string());
+                            info!("🕒 HA: standby for chain {}, active is {}", chain_id, holder);
+                            standby_logged = true;
+                        }
+                    }
+                    // Fehler der Lease-Datei (Rechte, kein flock) behebt erneutes Versuchen nicht
+                    Err(e) if matches!(lease.backend, Backend::File { .. }) => return Err(e),
+                    Err(e) => {
+                        if !failing {
+                            warn!("⚠️  HA: lease backend {} unavailable: {}", backend, e);
+                            failing = true;
+                        }
+                    }
+                }
+            }
+            _ = poll_ticker.tick() => {
+                dry_run.poll(&runtime).await;
+                if runtime.borrow().http_endpoint.is_some() {
+                    systemd::beat(chain_id);
+                }
+            }
+        }
+
+        if last_log.elapsed() >= STANDBY_LOG_INTERVAL {
+            last_log = Instant::now();
+            let holder = lease.holder().await.unwrap_or_else(|| "unknown".to_string());
+            info!(
+                "🕒 HA standby chain {}: dry-run {} preconfs (latest {}), {} rejected, active is {}",
+                chain_id,
+                dry_run.captured,
+                dry_run.latest.map_or("-".to_string(), |latest| format!("#{}", latest)),
+                dry_run.rejected,
+                holder
+            );
+        }
+    }
+}
+
+/// Erneuert die Lease der aktiven Instanz; verliert sie die Lease, wird die Chain gestoppt
+pub async fn run_lease_renewal(lease: Arc<tokio::sync::Mutex<Lease>>, running: Arc<Mutex<bool>>) {
+    let (chain_id, renew, expiry) = {
+        let lease = lease.lock().await;
+        (lease.chain_id, lease.renew_interval(), lease.lease)
+    };
+    let mut ticker = interval(renew);
+    let mut last_renewed = Instant::now();
+    loop {
+        ticker.tick().await;
+        if !*running.lock().unwrap() {
+            break;
+        }
+        let mut lease = lease.lock().await;
+        let lost = match lease.acquire().await {
+            Ok(true) => {
+                last_renewed = Instant::now();
+                None
+            }
+            Ok(false) => {
+                let holder = lease.holder().await.unwrap_or_else(|| "another node".to_string());
+                Some(format!("taken over by {}", holder))
+            }
+            // Bis zum möglichen Ablauf weiter versuchen, danach könnte der Standby schon schreiben
+            Err(e) if last_renewed.elapsed() + renew >= expiry => Some(format!("not renewed in time: {}", e)),
+            Err(e) => {
+                warn!("⚠️  HA: renewing the lease of chain {} failed: {}", chain_id, e);
+                None
+            }
+        };
+        if let Some(reason) = lost {
+            error!("❌ HA: lost the lease of chain {} ({}), stopping to write", chain_id, reason);
+            lease.held = false;
+            lease.lost = true;
+            *running.lock().unwrap() = false;
+            break;
+        }
+    }
+}
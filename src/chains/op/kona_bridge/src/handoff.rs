@@ -0,0 +1,256 @@
+// handoff.rs - Upgrade ohne Unterbrechung: Listener an einen neuen Prozess übergeben (nur `kona_bridge capture`)
+//
+// Auf SIGUSR2 startet die Bridge ihr Binary (derselbe Pfad, also auch ein inzwischen ersetztes) mit denselben
+// Argumenten neu und vererbt dem Nachfolger die offenen Listener: HTTP-API, gRPC, Admin- und Debug-Listener sowie die
+// Query-Sockets der Chains. Der Nachfolger übernimmt sie statt neu zu binden:
+//   KONA_BRIDGE_HANDOFF_FDS      key=fd,... (key = tcp:<Adresse> bzw. unix:<Pfad>, nur geänderte Adressen neu binden)
+//   KONA_BRIDGE_HANDOFF_READY    Schreibende einer Pipe, über die der Nachfolger meldet, dass er läuft
+//   KONA_BRIDGE_HANDOFF_PARENT   PID des Vorgängers, auf dessen Verzeichnis-Sperren der Nachfolger wartet
+// Ablauf:
+//   1. Nachfolger startet, liest Argumente und Konfiguration und meldet sich über die Pipe (sonst nach
+//      HANDOFF_TIMEOUT beendet; der alte Prozess läuft dann unverändert weiter)
+//   2. der alte Prozess nimmt keine Verbindungen mehr an (neue warten im Backlog des geteilten Sockets), meldet
+//      systemd MAINPID=<Nachfolger> und stoppt seine Chains wie bei SIGTERM, aber ohne STOPPING=1
+//   3. der Nachfolger bekommt die Verzeichnis-Sperren, sobald eine Chain des alten Prozesses beendet ist, und
+//      übernimmt mit ihr den Capture-Zustand (Index, Journal, latest-Zeiger, Signer-Historie liegen im Verzeichnis)
+// Laufende Anfragen beantwortet der alte Prozess noch; offene SSE-/WebSocket-Streams und gRPC-Streams enden mit ihm
+// und verbinden sich beim Nachfolger neu, ohne dass ein Connect abgewiesen wird.
+
+use crate::systemd;
+use std::{
+    collections::HashMap,
+    fs::File,
+    io::{self, Read, Write},
+    net::SocketAddr,
+    os::{
+        fd::{AsFd, AsRawFd, FromRawFd, OwnedFd, RawFd},
+        unix::process::CommandExt,
+    },
+    path::{Path, PathBuf},
+    process::Command,
+    sync::{Mutex, OnceLock},
+    time::Duration,
+};
+use tokio::{
+    net::{TcpListener, UnixListener},
+    sync::watch,
+};
+use tracing::{info, warn};
+
+/// So lange darf der Nachfolger bis zur Bereitschaftsmeldung brauchen
+const HANDOFF_TIMEOUT: Duration = Duration::from_secs(30);
+
+/// Vom Vorgänger geerbte Sockets, noch nicht übernommen (wieder mit FD_CLOEXEC, damit Hooks sie nicht erben)
+static INHERITED: OnceLock<Mutex<HashMap<String, RawFd>>> = OnceLock::new();
+/// Offene Listener dieses Prozesses (eigene Kopie des Deskriptors, damit ein beendeter Server keinen fremden
+/// Deskriptor hinterlässt)
+static LISTENERS: OnceLock<Mutex<HashMap<String, OwnedFd>>> = OnceLock::new();
+/// true, sobald ein Nachfolger übernommen hat
+static HANDED_OVER: OnceLock<watch::Sender<bool>> = OnceLock::new();
+
+fn inherited() -> &'static Mutex<HashMap<String, RawFd>> {
+    INHERITED.get_or_init(|| {
+        let list = std::env::var("KONA_BRIDGE_HANDOFF_FDS").unwrap_or_default();
+        let fds = list
+            .split(',')
+            .filter_map(|item| item.rsplit_once('='))
+            .filter_map(|(key, fd)| Some((key.to_string(), fd.parse().ok()?)))
+            .filter(|(key, fd)| match set_cloexec(*fd, true) {
+                Ok(()) => true,
+                Err(e) => {
+                    warn!("⚠️  Ignoring inherited socket {} (fd {}): {}", key, fd, e);
+                    false
+                }
+            })
+            .collect();
+        Mutex::new(fds)
+    })
+}
+
+fn listeners() -> &'static Mutex<HashMap<String, OwnedFd>> {
+    LISTENERS.get_or_init(|| Mutex::new(HashMap::new()))
+}
+
+fn handed_over_sender() -> &'static watch::Sender<bool> {
+    HANDED_OVER.get_or_init(|| watch::channel(false).0)
+}
+
+fn set_cloexec(fd: RawFd, cloexec: bool) -> io::Result<()> {
+    let flags = unsafe { libc::fcntl(fd, libc::F_GETFD) };
+    if flags < 0 {
+        return Err(io::Error::last_os_error());
+    }
+    let flags = if cloexec { flags | libc::FD_CLOEXEC } else { flags & !libc::FD_CLOEXEC };
+    if unsafe { libc::fcntl(fd, libc::F_SETFD, flags) } < 0 {
+        return Err(io::Error::last_os_error());
+    }
+    Ok(())
+}
+
+/// Geerbter Socket zu `key`
+fn take(key: &str) -> Option<RawFd> {
+    let fd = inherited().lock().unwrap().remove(key)?;
+    info!("🔁 Took over {} from the previous process", key);
+    Some(fd)
+}
+
+fn keep(key: String, fd: impl AsFd) {
+    match fd.as_fd().try_clone_to_owned() {
+        Ok(fd) => {
+            listeners().lock().unwrap().insert(key, fd);
+        }
+        Err(e) => warn!("⚠️  {} cannot be handed over on upgrade: {}", key, e),
+    }
+}
+
+/// TCP-Listener: vom Vorgänger übernommen oder neu gebunden
+pub async fn bind_tcp(addr: SocketAddr) -> io::Result<TcpListener> {
+    let key = format!("tcp:{}", addr);
+    let listener = match take(&key) {
+        Some(fd) => {
+            let listener = unsafe { std::net::TcpListener::from_raw_fd(fd) };
+            listener.set_nonblocking(true)?;
+            TcpListener::from_std(listener)?
+        }
+        None => TcpListener::bind(addr).await?,
+    };
+    keep(key, &listener);
+    Ok(listener)
+}
+
+/// Unix-Socket-Listener: vom Vorgänger übernommen oder neu gebunden (ein verwaister Socket wird ersetzt)
+pub fn bind_unix(path: &Path) -> io::Result<UnixListener> {
+    let key = format!("unix:{}", path.display());
+    let listener = match take(&key) {
+        Some(fd) => {
+            let listener = unsafe { std::os::unix::net::UnixListener::from_raw_fd(fd) };
+            listener.set_nonblocking(true)?;
+            UnixListener::from_std(listener)?
+        }
+        None => {
+            if path.exists() {
+                std::fs::remove_file(path)?;
+            }
+            UnixListener::bind(path)?
+        }
+    };
+    keep(key, &listener);
+    Ok(listener)
+}
+
+/// Listener wird geschlossen (Chain beendet): nicht mehr übergeben
+pub fn release_unix(path: &Path) {
+    listeners().lock().unwrap().remove(&format!("unix:{}", path.display()));
+}
+
+/// Hat ein Nachfolger übernommen? (dann z.B. den Socket-Pfad nicht löschen)
+pub fn is_handed_over() -> bool {
+    *handed_over_sender().borrow()
+}
+
+/// Endet, sobald ein Nachfolger übernommen hat (Listener hören dann auf, Verbindungen anzunehmen)
+pub async fn handed_over() {
+    let mut receiver = handed_over_sender().subscribe();
+    let _ = receiver.wait_for(|handed_over| *handed_over).await;
+}
+
+/// Vorgänger, der diesen Prozess gestartet hat (seine Verzeichnis-Sperren werden ohne Takeover abgewartet)
+pub fn is_parent(pid: u32) -> bool {
+    std::env::var("KONA_BRIDGE_HANDOFF_PARENT").is_ok_and(|parent| parent.parse() == Ok(pid))
+}
+
+/// Meldet dem Vorgänger, dass dieser Prozess läuft (ohne Vorgänger ein No-op)
+pub fn ready() {
+    static READY: OnceLock<()> = OnceLock::new();
+    if READY.set(()).is_err() {
+        return;
+    }
+    inherited();
+    let Some(fd) = std::env::var("KONA_BRIDGE_HANDOFF_READY").ok().and_then(|fd| fd.parse::<RawFd>().ok()) else {
+        return;
+    };
+    let mut pipe = unsafe { File::from_raw_fd(fd) };
+    match pipe.write_all(b"1") {
+        Ok(()) => info!(
+            "🔁 Taking over from PID {}",
+            std::env::var("KONA_BRIDGE_HANDOFF_PARENT").unwrap_or_else(|_| "?".to_string())
+        ),
+        Err(e) => warn!("⚠️  Cannot report readiness to the previous process: {}", e),
+    }
+}
+
+/// Pfad des eigenen Binaries; nach einem Upgrade zeigt /proc/self/exe auf die gelöschte alte Datei
+fn executable() -> io::Result<PathBuf> {
+    let exe = std::env::current_exe()?;
+    let path = exe.to_string_lossy();
+    Ok(match path.strip_suffix(" (deleted)") {
+        Some(path) => PathBuf::from(path),
+        None => exe,
+    })
+}
+
+/// Startet den Nachfolger mit den Listenern dieses Prozesses und wartet auf seine Bereitschaft.
+/// Ergebnis: PID des Nachfolgers; danach nehmen die Listener dieses Prozesses nichts mehr an.
+pub async fn spawn_successor() -> Result<u32, Box<dyn std::error::Error + Send + Sync>> {
+    if is_handed_over() {
+        return Err("already handed over".into());
+    }
+    let exe = executable()?;
+
+    let mut pipe = [0 as RawFd; 2];
+    if unsafe { libc::pipe2(pipe.as_mut_ptr(), libc::O_CLOEXEC) } != 0 {
+        return Err(format!("cannot create pipe: {}", io::Error::last_os_error()).into());
+    }
+    let (reader, writer) = unsafe { (File::from_raw_fd(pipe[0]), OwnedFd::from_raw_fd(pipe[1])) };
+
+    // Die Kopien in LISTENERS bleiben bis nach dem Start gültig; FD_CLOEXEC nur im Kindprozess entfernen
+    let listeners = listeners().lock().unwrap();
+    let mut fds: Vec<RawFd> = listeners.values().map(AsRawFd::as_raw_fd).collect();
+    let list: Vec<String> = listeners.iter().map(|(key, fd)| format!("{}={}", key, fd.as_raw_fd())).collect();
+    fds.push(writer.as_raw_fd());
+
+    let mut command = Command::new(&exe);
+    command
+        .args(std::env::args_os().skip(1))
+        .env("KONA_BRIDGE_HANDOFF_FDS", list.join(","))
+        .env("KONA_BRIDGE_HANDOFF_READY", writer.as_raw_fd().to_string())
+        .env("KONA_BRIDGE_HANDOFF_PARENT", std::process::id().to_string())
+        // Der Watchdog gilt nach MAINPID= für den Nachfolger
+        .env_remove("WATCHDOG_PID");
+    unsafe {
+        command.pre_exec(move || {
+            for fd in &fds {
+                set_cloexec(*fd, false)?;
+            }
+            Ok(())
+        });
+    }
+    let spawned = command.spawn();
+    drop(listeners);
+    drop(writer);
+    let mut child = spawned.map_err(|e| format!("cannot start {:?}: {}", exe, e))?;
+    let pid = child.id();
+    info!("🔁 Started successor {:?} (PID {}), waiting until it is ready", exe, pid);
+
+    let ready = tokio::task::spawn_blocking(move || {
+        let mut reader = reader;
+        let mut byte = [0u8; 1];
+        reader.read(&mut byte).map(|read| read == 1)
+    });
+    match tokio::time::timeout(HANDOFF_TIMEOUT, ready).await {
+        Ok(Ok(Ok(true))) => {}
+        result => {
+            let _ = child.kill();
+            let status = child.wait().map(|status| status.to_string()).unwrap_or_else(|e| e.to_string());
+            return Err(match result {
+                Err(_) => format!("successor not ready within {}s, stopped ({})", HANDOFF_TIMEOUT.as_secs(), status),
+                _ => format!("successor exited before taking over ({})", status),
+            }
+            .into());
+        }
+    }
+
+    handed_over_sender().send_replace(true);
+    systemd::notify(&format!("MAINPID={}\nSTATUS=Handed over to PID {}", pid, pid));
+    Ok(pid)
+}
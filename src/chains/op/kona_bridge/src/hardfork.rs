@@ -0,0 +1,157 @@
+// hardfork.rs - Hardfork-Zeitplan der Chain: Payload-Version und Gossip-Topic je Timestamp
+//
+// Mit Canyon, Ecotone und Isthmus wechseln Blöcke auf ein neues Topic mit neuem Payload-Layout:
+//   /optimism/{chain_id}/0/blocks   v1 (Bedrock)     /optimism/{chain_id}/2/blocks   v3 (Ecotone)
+//   /optimism/{chain_id}/1/blocks   v2 (Canyon)      /optimism/{chain_id}/3/blocks   v4 (Isthmus)
+// kona-p2p abonniert alle Topics gleichzeitig, Blöcke vor und nach der Aktivierung kommen also ohne Eingriff an.
+// Den Zeitplan (rollup.json, sonst Superchain-Registry) nutzt die Bridge zusätzlich für:
+//   - Dekodierung von HTTP-Payloads mit der Version am Payload-Timestamp (Layout-Erkennung nur als Rückfall)
+//   - Prüfung "fork": Version passt zum Hardfork am Timestamp (KONA_BRIDGE_FORK_CHECK, Default reject)
+//   - Log vor und bei der Aktivierung (Topic und Decoder), ohne Neustart oder Eingriff am Fork-Tag
+
+use crate::{registry, ssz::PayloadVersion};
+use kona_genesis::{HardForkConfig, RollupConfig};
+use std::{
+    sync::{Arc, Mutex},
+    time::{Duration, SystemTime, UNIX_EPOCH},
+};
+use tokio::time::interval;
+use tracing::info;
+
+/// Vorlauf für den Hinweis auf einen anstehenden Topic-Wechsel
+const FORK_NOTICE_SECS: u64 = 3600;
+
+/// Aktivierungszeiten der Hardforks als (Name, Timestamp), aufsteigend
+#[derive(Debug, Clone, Default)]
+pub struct HardforkSchedule(pub Vec<(&'static str, u64)>);
+
+impl HardforkSchedule {
+    pub fn new(forks: &HardForkConfig) -> Self {
+        let mut schedule: Vec<(&'static str, u64)> = [
+            ("regolith", forks.regolith_time),
+            ("canyon", forks.canyon_time),
+            ("delta", forks.delta_time),
+            ("ecotone", forks.ecotone_time),
+            ("fjord", forks.fjord_time),
+            ("granite", forks.granite_time),
+            ("holocene", forks.holocene_time),
+            ("isthmus", forks.isthmus_time),
+        ]
+        .into_iter()
+        .filter_map(|(name, time)| Some((name, time?)))
+        .collect();
+        schedule.sort_by_key(|(_, time)| *time);
+        Self(schedule)
+    }
+
+    /// Zeitplan der Chain: rollup.json vor Superchain-Registry (None = unbekannt)
+    pub fn for_chain(chain_id: u64, rollup: Option<&RollupConfig>) -> Option<Self> {
+        match rollup {
+            Some(rollup) => Some(Self::new(&rollup.hardforks)),
+            None => registry::lookup(chain_id).map(|chain| chain.hardforks),
+        }
+    }
+
+    /// Neuester zum Zeitpunkt aktive Hardfork ("bedrock" vor dem ersten)
+    pub fn active(&self, now: u64) -> &'static str {
+        self.0.iter().rev().find(|(_, time)| *time <= now).map_or("bedrock", |(name, _)| *name)
+    }
+
+    /// Nächster geplanter Hardfork mit Aktivierungszeit
+    pub fn next(&self, now: u64) -> Option<(&'static str, u64)> {
+        self.0.iter().find(|(_, time)| *time > now).copied()
+    }
+
+    fn activation(&self, hardfork: &str) -> Option<u64> {
+        self.0.iter().find(|(name, _)| *name == hardfork).map(|(_, time)| *time)
+    }
+
+    /// Payload-Version für einen Block mit diesem Timestamp
+    pub fn payload_version(&self, timestamp: u64) -> PayloadVersion {
+        [PayloadVersion::V4, PayloadVersion::V3, PayloadVersion::V2]
+            .into_iter()
+            .find(|version| self.activation(version.hardfork()).is_some_and(|time| time <= timestamp))
+            .unwrap_or(PayloadVersion::V1)
+    }
+
+    /// Nächster Wechsel der Payload-Version nach `now` (Version, Aktivierungszeit)
+    pub fn next_version_change(&self, now: u64) -> Option<(PayloadVersion, u64)> {
+        [PayloadVersion::V2, PayloadVersion::V3, PayloadVersion::V4]
+            .into_iter()
+            .filter_map(|version| Some((version, self.activation(version.hardfork())?)))
+            .filter(|(_, time)| *time > now)
+            .min_by_key(|(_, time)| *time)
+    }
+}
+
+/// Gossip-Topic der Blöcke einer Payload-Version
+pub fn topic(chain_id: u64, version: PayloadVersion) -> String {
+    let index = match version {
+        PayloadVersion::V1 => 0,
+        PayloadVersion::V2 => 1,
+        PayloadVersion::V3 => 2,
+        PayloadVersion::V4 => 3,
+    };
+    format!("/optimism/{}/{}/blocks", chain_id, index)
+}
+
+/// Prüft die Payload-Version gegen den Hardfork am Payload-Timestamp (0 = unbekannt, wird nicht geprüft)
+pub fn check_version(schedule: &HardforkSchedule, timestamp: u64, version: PayloadVersion) -> Result<(), String> {
+    let expected = schedule.payload_version(timestamp);
+    if timestamp == 0 || version == expected {
+        return Ok(());
+    }
+    Err(format!(
+        "payload {} at timestamp {}, expected {} ({} active)",
+        version.name(),
+        timestamp,
+        expected.name(),
+        schedule.active(timestamp)
+    ))
+}
+
+fn unix_now() -> u64 {
+    SystemTime::now().duration_since(UNIX_EPOCH).map_or(0, |d| d.as_secs())
+}
+
+/// Kündigt Topic-Wechsel an und loggt sie zur Aktivierung
+pub async fn run_fork_watch(chain_id: u64, schedule: HardforkSchedule, running: Arc<Mutex<bool>>) {
+    let now = unix_now();
+    let mut current = schedule.payload_version(now);
+    let active = schedule.active(now);
+    info!("🔀 Chain {}: payloads {} on {} ({} active)", chain_id, current.name(), topic(chain_id, current), active);
+
+    let mut noticed = false;
+    let mut ticker = interval(Duration::from_secs(1));
+    while let Some((next, activation)) = schedule.next_version_change(unix_now()) {
+        ticker.tick().await;
+        if !*running.lock().unwrap() {
+            break;
+        }
+        let now = unix_now();
+        if !noticed && now + FORK_NOTICE_SECS >= activation {
+            info!(
+                "⏰ Chain {}: {} activates in {}s at {}, blocks move from {} to {}",
+                chain_id,
+                next.hardfork(),
+                activation.saturating_sub(now),
+                activation,
+                topic(chain_id, current),
+                topic(chain_id, next)
+            );
+            noticed = true;
+        }
+        if now >= activation {
+            info!(
+                "🔀 Chain {}: {} active, blocks on {} (was {}), decoding payloads as {}",
+                chain_id,
+                next.hardfork(),
+                topic(chain_id, next),
+                topic(chain_id, current),
+                next.name()
+            );
+            current = next;
+            noticed = false;
+        }
+    }
+}
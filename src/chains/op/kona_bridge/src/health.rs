@@ -0,0 +1,188 @@
+// health.rs - Liveness und Readiness der Bridge (GET /healthz und /readyz der HTTP-API, siehe serve.rs)
+//
+//   GET /healthz              200, solange der Prozess lebt und der Server-Thread antwortet
+//   GET /readyz?chain_id=...  200 nur wenn für jede (bzw. die angegebene) Chain alle Prüfungen bestehen, sonst 503
+//
+// Prüfungen je Chain:
+//   source   Quelle verbunden: im HTTP-Modus ein erfolgreicher Poll innerhalb des Fensters, sonst Gossip-Peers
+//   fresh    neuester gespeicherter Preconf jünger als KONA_BRIDGE_READY_MAX_AGE_SECS (Default 30)
+//   storage  Output-Verzeichnis beschreibbar (Probe-Datei anlegen und wieder löschen)
+// Die Einzelergebnisse stehen im JSON der Antwort, damit Load Balancer bzw. Kubernetes eine hängende Bridge
+// aus dem Verkehr nehmen können, während der Betreiber sieht, welche Prüfung fehlschlägt.
+
+use crate::{
+    storage::PreconfStore,
+    types::{BridgeMode, HttpHealthTracker, KonaBridgeStats},
+};
+use axum::{
+    extract::Query,
+    http::StatusCode,
+    response::{IntoResponse, Json, Response},
+};
+use serde::Deserialize;
+use std::{
+    collections::HashMap,
+    sync::{Arc, Mutex, OnceLock, RwLock},
+    time::{Duration, SystemTime, UNIX_EPOCH},
+};
+
+/// Maximales Alter des neuesten Preconfs, bis die Chain als nicht bereit gilt
+pub const DEFAULT_READY_MAX_AGE_SECS: u64 = 30;
+
+/// Probe-Datei für den Schreibtest (passt auf kein Cleanup-Muster)
+const PROBE_FILE_NAME: &str = ".ready_probe";
+
+/// Registrierte Chains nach Chain-ID
+static CHAINS: OnceLock<RwLock<HashMap<u64, Arc<ChainHealth>>>> = OnceLock::new();
+
+fn chains() -> &'static RwLock<HashMap<u64, Arc<ChainHealth>>> {
+    CHAINS.get_or_init(|| RwLock::new(HashMap::new()))
+}
+
+/// Zustand einer Chain, aus dem die Readiness abgeleitet wird
+pub struct ChainHealth {
+    store: Arc<PreconfStore>,
+    stats: Arc<Mutex<KonaBridgeStats>>,
+    http: Option<Arc<Mutex<HttpHealthTracker>>>, // None = reiner Gossip-Betrieb
+    max_age_secs: u64,
+}
+
+impl ChainHealth {
+    pub fn new(
+        store: Arc<PreconfStore>,
+        stats: Arc<Mutex<KonaBridgeStats>>,
+        http: Option<Arc<Mutex<HttpHealthTracker>>>,
+        max_age_secs: u64,
+    ) -> Self {
+        Self { store, stats, http, max_age_secs }
+    }
+
+    /// Quelle verbunden? (siehe source_health)
+    fn source(&self) -> (bool, String) {
+        source_health(&self.stats, self.http.as_deref(), self.max_age_secs)
+    }
+
+    /// Alter des neuesten gespeicherten Preconfs (Block-Timestamp, sonst Empfangszeit)
+    fn fresh(&self) -> (bool, Option<u64>, Option<u64>) {
+        let Some((block_number, entry)) = self.store.latest_entry() else {
+            return (false, None, None);
+        };
+        let now = SystemTime::now().duration_since(UNIX_EPOCH).map(|d| d.as_secs()).unwrap_or(0);
+        let produced = if entry.timestamp > 0 { entry.timestamp } else { entry.received_unix };
+        let age = now.saturating_sub(produced);
+        (age <= self.max_age_secs, Some(block_number), Some(age))
+    }
+
+    /// Schreibtest im Output-Verzeichnis
+    async fn storage(&self) -> (bool, String) {
+        let probe = self.store.output_dir().join(PROBE_FILE_NAME);
+        let result = tokio::task::spawn_blocking(move || {
+            std::fs::write(&probe, b"ok")?;
+            std::fs::remove_file(&probe)
+        })
+        .await;
+        match result {
+            Ok(Ok(())) => (true, "writable".to_string()),
+            Ok(Err(e)) => (false, e.to_string()),
+            Err(e) => (false, e.to_string()),
+        }
+    }
+
+    async fn check(&self) -> (bool, serde_json::Value) {
+        let (source_ok, source) = self.source();
+        let (fresh_ok, block_number, age_secs) = self.fresh();
+        let (storage_ok, storage) = self.storage().await;
+        let ready = source_ok && fresh_ok && storage_ok;
+        (
+            ready,
+            serde_json::json!({
+                "ready": ready,
+                "source": { "ok": source_ok, "detail": source },
+                "fresh": { "ok": fresh_ok, "block": block_number, "age_secs": age_secs, "max_age_secs": self.max_age_secs },
+                "storage": { "ok": storage_ok, "detail": storage },
+            }),
+        )
+    }
+}
+
+/// Quelle verbunden? HTTP zählt nur, solange nicht auf Gossip zurückgefallen wurde (auch für status.rs)
+pub(crate) fn source_health(
+    stats: &Mutex<KonaBridgeStats>,
+    http: Option<&Mutex<HttpHealthTracker>>,
+    max_age_secs: u64,
+) -> (bool, String) {
+    if let Some(http) = http {
+        let tracker = http.lock().unwrap();
+        if tracker.current_mode != BridgeMode::GossipFallback {
+            let since = tracker.last_success.and_then(|at| at.elapsed().ok());
+            return match since {
+                Some(since) if since <= Duration::from_secs(max_age_secs) => (true, "http".to_string()),
+                Some(since) => (false, format!("http: last successful poll {}s ago", since.as_secs())),
+                None => (false, "http: no successful poll yet".to_string()),
+            };
+        }
+    }
+    let peers = stats.lock().unwrap().connected_peers;
+    if peers > 0 {
+        (true, format!("gossip: {} peers", peers))
+    } else {
+        (false, "gossip: no peers".to_string())
+    }
+}
+
+/// Nimmt die Chain in /readyz auf und wieder heraus, sobald `running` false wird
+pub fn register(health: ChainHealth, running: Arc<Mutex<bool>>) {
+    let chain_id = health.store.chain_id();
+    if let Ok(mut chains) = chains().write() {
+        chains.insert(chain_id, Arc::new(health));
+    }
+    tokio::spawn(async move {
+        while *running.lock().unwrap() {
+            tokio::time::sleep(Duration::from_secs(1)).await;
+        }
+        if let Ok(mut chains) = chains().write() {
+            chains.remove(&chain_id);
+        }
+    });
+}
+
+#[derive(Deserialize)]
+pub(crate) struct ReadyQuery {
+    chain_id: Option<u64>,
+}
+
+pub(crate) async fn get_healthz() -> Response {
+    let mut chain_ids: Vec<u64> = chains().read().map(|chains| chains.keys().copied().collect()).unwrap_or_default();
+    chain_ids.sort_unstable();
+    Json(serde_json::json!({ "status": "ok", "chains": chain_ids })).into_response()
+}
+
+pub(crate) async fn get_readyz(Query(query): Query<ReadyQuery>) -> Response {
+    let mut selected: Vec<(u64, Arc<ChainHealth>)> = match chains().read() {
+        Ok(chains) => chains
+            .iter()
+            .filter(|(chain_id, _)| query.chain_id.map_or(true, |wanted| wanted == **chain_id))
+            .map(|(chain_id, health)| (*chain_id, health.clone()))
+            .collect(),
+        Err(_) => Vec::new(),
+    };
+    if let Some(chain_id) = query.chain_id {
+        if selected.is_empty() {
+            let body = serde_json::json!({ "error": format!("chain {} not served", chain_id) });
+            return (StatusCode::NOT_FOUND, Json(body)).into_response();
+        }
+    }
+    selected.sort_unstable_by_key(|(chain_id, _)| *chain_id);
+
+    // Ohne registrierte Chain ist der Prozess noch nicht bereit
+    let mut ready = !selected.is_empty();
+    let mut results = serde_json::Map::new();
+    for (chain_id, health) in selected {
+        let (chain_ready, result) = health.check().await;
+        ready &= chain_ready;
+        results.insert(chain_id.to_string(), result);
+    }
+
+    let status = if ready { StatusCode::OK } else { StatusCode::SERVICE_UNAVAILABLE };
+    (status, Json(serde_json::json!({ "ready": ready, "chains": results }))).into_response()
+}
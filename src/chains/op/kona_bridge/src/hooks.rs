@@ -0,0 +1,182 @@
+// hooks.rs - Webhooks und Exec-Hooks für neue Preconfs und auffällige Ereignisse
+//
+// Ereignisse:
+//   preconf             neuer, gespeicherter Preconf (Metadaten wie in block_*.json)
+//   invalid_signature   Signer nicht akzeptiert (unabhängig vom Modus drop/quarantine/flag)
+//   gap                 erfasster Block liegt über dem erwarteten Nachfolger, mehr Blöcke fehlen als
+//                       KONA_BRIDGE_GAP_TOLERANCE erlaubt (expected, missing_from..=missing_to, siehe continuity.rs)
+//   backwards           erfasster Block liegt mehr als die Toleranz unter dem höchsten erfassten (highest, behind)
+//   reorg               für eine bereits gespeicherte Höhe kommt ein Block mit anderem Hash (previous_block_hash,
+//                       Quellen, superseded_file unter superseded/, siehe reorg.rs)
+//   equivocation        wie reorg, aber beide Blöcke von akzeptierten Signern signiert (siehe equivocations/)
+//   mismatch            gespeicherter Preconf weicht vom kanonischen Block ab (canonical_block_hash, tx_set_matches,
+//                       missing_txs, extra_txs, siehe accuracy.rs)
+//   disk_full           Notfall-Löschung wegen knappem Platz (free_bytes, free_bytes_after, deleted_blocks,
+//                       read_only), Block = neuester gespeicherter (siehe diskguard.rs)
+//
+// KONA_BRIDGE_HOOK_URL: JSON per HTTP POST (mehrere URLs durch Komma getrennt).
+// KONA_BRIDGE_HOOK_EXEC: Kommando über `sh -c`, das Ereignis als JSON auf stdin und in KONA_EVENT_JSON, dazu
+// KONA_EVENT, KONA_CHAIN_ID, KONA_BLOCK_NUMBER und KONA_BLOCK_HASH. KONA_BRIDGE_HOOK_EVENTS schränkt die Ereignisse
+// ein (Default: alle). Hooks laufen in einem eigenen Task nacheinander (Reihenfolge bleibt erhalten), jeder mit
+// KONA_BRIDGE_HOOK_TIMEOUT_SECS; der Capture-Pfad wartet nie darauf. Ist die Queue voll, werden Ereignisse
+// verworfen und gezählt.
+
+use crate::{settings::HookSettings, sink::StoredPreconf};
+use std::{
+    process::Stdio,
+    sync::{
+        atomic::{AtomicU64, Ordering},
+        Arc, Mutex,
+    },
+    time::{Duration, SystemTime, UNIX_EPOCH},
+};
+use tokio::{io::AsyncWriteExt, process::Command, sync::mpsc, time::timeout};
+use tracing::{debug, info, warn};
+
+/// Ereignisse, die noch nicht ausgeliefert sind, bevor neue verworfen werden
+const HOOK_QUEUE_SIZE: usize = 256;
+
+pub const HOOK_EVENTS: &[&str] =
+    &["preconf", "invalid_signature", "gap", "backwards", "reorg", "equivocation", "mismatch", "disk_full"];
+pub const DEFAULT_HOOK_TIMEOUT_SECS: u64 = 10;
+
+/// Ereignis für die Hooks
+#[derive(Debug)]
+pub struct HookEvent {
+    pub event: &'static str,
+    pub chain_id: u64,
+    pub block_number: u64,
+    pub block_hash: Option<[u8; 32]>,
+    pub details: serde_json::Value,
+}
+
+impl HookEvent {
+    pub fn from_preconf(preconf: &StoredPreconf) -> Self {
+        Self {
+            event: "preconf",
+            chain_id: preconf.chain_id,
+            block_number: preconf.block_number,
+            block_hash: Some(preconf.block_hash),
+            details: preconf.metadata.clone(),
+        }
+    }
+
+    fn block_hash_hex(&self) -> Option<String> {
+        self.block_hash.map(|hash| format!("0x{}", hex::encode(hash)))
+    }
+
+    /// JSON für Webhook und Exec: Ereignis, Chain, Block und die Details des Ereignisses
+    fn to_json(&self) -> serde_json::Value {
+        let mut message = serde_json::json!({
+            "event": self.event,
+            "chain_id": self.chain_id,
+            "block_number": self.block_number,
+            "block_hash": self.block_hash_hex(),
+            "emitted_unix": SystemTime::now().duration_since(UNIX_EPOCH).map(|d| d.as_secs()).unwrap_or(0),
+        });
+        if let serde_json::Value::Object(ref details) = self.details {
+            for (key, value) in details {
+                // Grundfelder nicht überschreiben (chain_id ist in den Metadaten ein String)
+                if message.get(key).is_none() {
+                    message[key] = value.clone();
+                }
+            }
+        }
+        message
+    }
+}
+
+/// Übergabe an den Hook-Task (nicht-blockierend)
+pub struct Hooks {
+    events: Vec<String>,
+    sender: mpsc::Sender<HookEvent>,
+    dropped: AtomicU64,
+}
+
+impl Hooks {
+    /// Startet den Hook-Task (muss im Tokio-Runtime laufen)
+    pub fn start(settings: &HookSettings, running: Arc<Mutex<bool>>) -> Self {
+        let (sender, receiver) = mpsc::channel(HOOK_QUEUE_SIZE);
+        info!(
+            "🪝 Hooks enabled: {} webhook(s){}, events: {}",
+            settings.urls.len(),
+            if settings.exec.is_some() { " and exec" } else { "" },
+            if settings.events.is_empty() { "all".to_string() } else { settings.events.join(",") }
+        );
+        tokio::spawn(run_hooks(settings.clone(), receiver, running));
+        Self { events: settings.events.clone(), sender, dropped: AtomicU64::new(0) }
+    }
+
+    pub fn emit(&self, event: HookEvent) {
+        if !self.events.is_empty() && !self.events.iter().any(|wanted| wanted == event.event) {
+            return;
+        }
+        let (name, block_number) = (event.event, event.block_number);
+        if let Err(e) = self.sender.try_send(event) {
+            let dropped = self.dropped.fetch_add(1, Ordering::Relaxed) + 1;
+            warn!("⚠️  Hook event {} for block {} dropped ({}), {} dropped so far", name, block_number, e, dropped);
+        }
+    }
+}
+
+async fn run_hooks(settings: HookSettings, mut receiver: mpsc::Receiver<HookEvent>, running: Arc<Mutex<bool>>) {
+    let client = reqwest::Client::new();
+    let limit = Duration::from_secs(settings.timeout_secs.max(1));
+
+    while let Some(event) = receiver.recv().await {
+        if !*running.lock().unwrap() {
+            break;
+        }
+        let message = event.to_json();
+        for url in &settings.urls {
+            let result = timeout(limit, client.post(url).json(&message).send()).await;
+            match result {
+                Ok(Ok(response)) if response.status().is_success() => {}
+                Ok(Ok(response)) => warn!("⚠️  Webhook {} answered {} for {}", url, response.status(), event.event),
+                Ok(Err(e)) => warn!("⚠️  Webhook {} failed for {}: {}", url, event.event, e),
+                Err(_) => warn!("⚠️  Webhook {} timed out for {}", url, event.event),
+            }
+        }
+        if let Some(ref command) = settings.exec {
+            if let Err(e) = run_exec(command, &event, &message, limit).await {
+                warn!("⚠️  Hook command failed for {} (block {}): {}", event.event, event.block_number, e);
+            }
+        }
+        debug!("🪝 Delivered {} for block {}", event.event, event.block_number);
+    }
+}
+
+async fn run_exec(
+    command: &str,
+    event: &HookEvent,
+    message: &serde_json::Value,
+    limit: Duration,
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let json = serde_json::to_string(message)?;
+    let mut child = Command::new("sh")
+        .arg("-c")
+        .arg(command)
+        .env("KONA_EVENT", event.event)
+        .env("KONA_CHAIN_ID", event.chain_id.to_string())
+        .env("KONA_BLOCK_NUMBER", event.block_number.to_string())
+        .env("KONA_BLOCK_HASH", event.block_hash_hex().unwrap_or_default())
+        .env("KONA_EVENT_JSON", &json)
+        .stdin(Stdio::piped())
+        .stdout(Stdio::null())
+        .kill_on_drop(true)
+        .spawn()?;
+    if let Some(mut stdin) = child.stdin.take() {
+        // Kommandos, die stdin nicht lesen, schließen die Pipe vorzeitig, das ist kein Fehler
+        let _ = stdin.write_all(json.as_bytes()).await;
+    }
+    match timeout(limit, child.wait()).await {
+        Ok(status) => {
+            let status = status?;
+            if !status.success() {
+                return Err(format!("exited with {}", status).into());
+            }
+            Ok(())
+        }
+        Err(_) => Err(format!("timed out after {}s", limit.as_secs()).into()),
+    }
+}
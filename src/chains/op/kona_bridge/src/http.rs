@@ -1,23 +1,49 @@
 // http.rs - HTTP-Polling Logik für Preconfs
 
 use crate::{
+    checkpoint::PollCursor,
     config::ChainConfig,
+    dnscache,
     gossip,
+    reload::RuntimeConfig,
+    storage::{PreconfStore, PreconfWrite},
+    systemd,
     types::{BridgeMode, BlockBitmaskTracker, BlockDeduplicator, HttpHealthTracker, KonaBridgeStats},
-    utils::{extract_block_number_from_preconf_data, extract_block_hash_from_preconf_data, update_symlinks_lib},
+    utils::{extract_block_number_from_preconf_data, extract_block_hash_from_preconf_data},
 };
 use reqwest;
 use std::{
     path::PathBuf,
     sync::{Arc, Mutex},
-    time::{Duration, SystemTime, UNIX_EPOCH},
+    time::{Duration, SystemTime},
 };
 use tokio::{
-    fs as tokio_fs, 
+    sync::watch,
     task::JoinHandle,
     time::{interval, sleep}
 };
-use tracing::{info, warn};
+use tracing::{info, warn, Instrument};
+
+/// Default-Grenze für die Payload eines Preconfs (wie die Gossip-Grenze von op-node, 10 MiB)
+pub const DEFAULT_MAX_PAYLOAD_BYTES: usize = 10 * 1024 * 1024;
+/// Platz für Signatur und übrige Felder der JSON-Antwort neben der hex-kodierten Payload
+const RESPONSE_OVERHEAD_BYTES: usize = 64 * 1024;
+
+/// Payload bzw. Antwort über der Grenze (KONA_BRIDGE_MAX_PAYLOAD_KB): wird gezählt und verworfen, ist aber kein
+/// Ausfall der Quelle
+#[derive(Debug)]
+pub struct PayloadTooLarge {
+    pub size: usize,
+    pub limit: usize,
+}
+
+impl std::fmt::Display for PayloadTooLarge {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        write!(f, "payload of at least {} bytes exceeds the limit of {} bytes", self.size, self.limit)
+    }
+}
+
+impl std::error::Error for PayloadTooLarge {}
 
 /// Robust hex decoding that handles odd-length strings
 fn safe_hex_decode(hex_str: &str) -> Result<Vec<u8>, hex::FromHexError> {
@@ -39,8 +65,9 @@ async fn _try_fill_block_gaps_deprecated(
     endpoint: &str,
     last_block: u64,
     current_block: u64,
-    chain_id: u64,
-    output_dir: &PathBuf,
+    _chain_id: u64,
+    _output_dir: &PathBuf,
+    store: &PreconfStore,
     max_attempts: u32,
 ) -> u32 {
     let gap = current_block.saturating_sub(last_block).saturating_sub(1);
@@ -54,8 +81,8 @@ async fn _try_fill_block_gaps_deprecated(
     
     // Try aggressive polling for a few seconds to catch missed blocks
     for attempt in 1..=max_attempts {
-        if let Ok(Some((_, preconf_data))) = fetch_http_preconf(client, endpoint).await {
-            if let Ok(block_num) = process_http_preconf(preconf_data, chain_id, output_dir).await {
+        if let Ok(Some((_, preconf_data))) = fetch_http_preconf(client, endpoint, store.max_payload_bytes()).await {
+            if let Ok(block_num) = process_http_preconf(preconf_data, store).await {
                 if block_num > last_block && block_num < current_block {
                     filled_blocks += 1;
                     info!("📦 Gap-fill: Found missing block {}", block_num);
@@ -81,11 +108,14 @@ async fn _try_fill_block_gaps_deprecated(
 }
 
 /// Fetch preconf from HTTP endpoint (similar to Go implementation)
+/// Die Antwort wird höchstens bis zur Grenze für `max_payload_bytes` gelesen (hex-kodiert doppelt so groß), größere
+/// enden mit PayloadTooLarge, bevor sie gepuffert oder geparst sind.
 pub async fn fetch_http_preconf(
     client: &reqwest::Client,
     endpoint: &str,
+    max_payload_bytes: usize,
 ) -> Result<Option<(String, serde_json::Value)>, Box<dyn std::error::Error + Send + Sync>> {
-    let response = client
+    let mut response = client
         .get(endpoint)
         .timeout(Duration::from_secs(2)) // Aggressive timeout for 2s block times
         .send()
@@ -94,8 +124,23 @@ pub async fn fetch_http_preconf(
     if !response.status().is_success() {
         return Err(format!("HTTP {} from {}", response.status(), endpoint).into());
     }
-    
-    let body = response.text().await?;
+
+    let limit = max_payload_bytes.saturating_mul(2).saturating_add(RESPONSE_OVERHEAD_BYTES);
+    let too_large = |size: usize| PayloadTooLarge {
+        size: size.saturating_sub(RESPONSE_OVERHEAD_BYTES) / 2,
+        limit: max_payload_bytes,
+    };
+    if let Some(length) = response.content_length().filter(|length| *length > limit as u64) {
+        return Err(too_large(length as usize).into());
+    }
+    let mut data = Vec::new();
+    while let Some(chunk) = response.chunk().await? {
+        if data.len() + chunk.len() > limit {
+            return Err(too_large(data.len() + chunk.len()).into());
+        }
+        data.extend_from_slice(&chunk);
+    }
+    let body = String::from_utf8(data)?;
     let data_hash = format!("{:x}", md5::compute(&body));
     
     let preconf: serde_json::Value = serde_json::from_str(&body)?;
@@ -103,12 +148,10 @@ pub async fn fetch_http_preconf(
     Ok(Some((data_hash, preconf)))
 }
 
-/// Process HTTP preconf and save to filesystem (similar to Go implementation)
-pub async fn process_http_preconf(
-    preconf: serde_json::Value,
-    chain_id: u64,
-    output_dir: &PathBuf,
-) -> Result<u64, Box<dyn std::error::Error + Send + Sync>> {
+/// Daten (parent_beacon_block_root + Payload) und Signatur (r + s + v) aus der JSON-Antwort der HTTP-Quelle
+pub fn parse_http_preconf(
+    preconf: &serde_json::Value,
+) -> Result<(Vec<u8>, [u8; 65]), Box<dyn std::error::Error + Send + Sync>> {
     // Extract data and signature from JSON
     let data_hex = preconf["data"]
         .as_str()
@@ -136,65 +179,43 @@ pub async fn process_http_preconf(
     sig_bytes[32 - r_bytes.len()..32].copy_from_slice(&r_bytes);
     sig_bytes[64 - s_bytes.len()..64].copy_from_slice(&s_bytes);
     sig_bytes[64] = v_byte;
-    
+    Ok((data_bytes, sig_bytes))
+}
+
+/// Process HTTP preconf and save to filesystem (similar to Go implementation)
+pub async fn process_http_preconf(
+    preconf: serde_json::Value,
+    store: &PreconfStore,
+) -> Result<u64, Box<dyn std::error::Error + Send + Sync>> {
+    let (data_bytes, sig_bytes) = parse_http_preconf(&preconf)?;
+
     // Extract block number from raw data using fixed offsets
     let block_number = extract_block_number_from_preconf_data(&data_bytes)?;
 //    info!("🔍 HTTP: Extracted block number {} from preconf data", block_number);
     
-    // Compress HTTP preconfs with ZSTD (level 1 for speed)
-    let compressed = zstd::bulk::compress(&data_bytes, 1)?;
-    let compressed_size = compressed.len(); // Speichere Größe vor dem Move
-    
-    // Combine compressed payload + signature
-    let mut combined = compressed;
-    combined.extend_from_slice(&sig_bytes);
-    
-    // Save to filesystem
-    let filename = format!("block_{}_{}.raw", chain_id, block_number);
-    let filepath = output_dir.join(&filename);
-    tokio_fs::write(&filepath, &combined).await?;
-    
-    // Update symlinks (latest.raw and pre_latest.raw) 
-    update_symlinks_lib(output_dir, &filename, chain_id).await?;
-    
-    // Create metadata JSON with extracted block hash
     let block_hash = extract_block_hash_from_preconf_data(&data_bytes)
         .unwrap_or([0u8; 32]); // Fallback bei Fehlern
-    
-    let meta_filename = format!("block_{}_{}.json", chain_id, block_number);
-    let meta_filepath = output_dir.join(&meta_filename);
-    
-    let timestamp = SystemTime::now().duration_since(UNIX_EPOCH)?.as_secs();
-    let metadata = serde_json::json!({
-        "chain_id": chain_id.to_string(),
-        "block_number": block_number,
-        "block_hash": format!("0x{}", hex::encode(&block_hash)),
-        "received_unix": timestamp,
-        "signature": format!("0x{}", hex::encode(&sig_bytes)),
-        "compressed_size": compressed_size,
-        "decompressed_size": data_bytes.len(),
-        "file_path": filename,
-        "source": "http",
-        "kona_p2p": true
-    });
 
-    let metadata_json = serde_json::to_string_pretty(&metadata)
-        .map_err(|e| format!("Failed to serialize metadata: {}", e))?;
-    
-    tokio_fs::write(&meta_filepath, metadata_json).await
-        .map_err(|e| format!("Failed to write metadata: {}", e))?;
+    // Kompression, Dateien, Symlinks und Publish übernimmt der Store
+    store.write(PreconfWrite {
+        block_number,
+        block_hash,
+        payload: data_bytes,
+        signature: sig_bytes,
+        source: "http",
+        version: None,
+    }).await?;
     
     Ok(block_number)
 }
 
-/// HTTP-Primary mit Gossip-Fallback
+/// HTTP-Primary mit Gossip-Fallback (Endpoint und Poll-Intervall aus der neu ladbaren Konfiguration)
 pub async fn run_http_primary_with_gossip_fallback(
-    http_endpoint: String,
+    mut runtime: watch::Receiver<RuntimeConfig>,
     chain_id: u64,
     disc_port: u16,
     gossip_port: u16,
     output_dir: &PathBuf,
-    http_poll_interval: u64,
     chain_config: &ChainConfig,
     expected_sequencer: Option<&str>,
     health_tracker: Arc<Mutex<HttpHealthTracker>>,
@@ -202,12 +223,25 @@ pub async fn run_http_primary_with_gossip_fallback(
     running: Arc<Mutex<bool>>,
     deduplicator: Arc<Mutex<BlockDeduplicator>>,
     bitmask_tracker: Arc<Mutex<BlockBitmaskTracker>>,
+    store: Arc<PreconfStore>,
+    dns_cache_secs: u64,
 ) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
     
-    let client = reqwest::Client::new();
+    // Bei DNS-Ausfällen weiter mit den zuletzt aufgelösten Adressen (siehe dnscache.rs)
+    let client = dnscache::client(dns_cache_secs, store.storage_stats());
+    let (mut http_endpoint, mut http_poll_interval) = {
+        let config = runtime.borrow_and_update();
+        (config.http_endpoint.clone().unwrap_or_default(), config.http_poll_interval)
+    };
     let mut interval_timer = interval(Duration::from_secs(http_poll_interval));
     let mut last_data_hash: Option<String> = None;
     let mut last_block_number: Option<u64> = None;
+    // Am Cursor des Checkpoints weitermachen (nur für denselben Endpoint, siehe checkpoint.rs)
+    if let Some(cursor) = store.poll_cursor().filter(|cursor| cursor.endpoint == http_endpoint) {
+        info!("📍 HTTP: Resuming after block {}", cursor.block_number);
+        last_data_hash = Some(cursor.data_hash);
+        last_block_number = Some(cursor.block_number);
+    }
     let mut _consecutive_same_data = 0u32; // Kept for potential future use
     let mut last_status_block = 0u64;
     // Removed unused variable last_http_processed
@@ -221,10 +255,30 @@ pub async fn run_http_primary_with_gossip_fallback(
     
     while *running.lock().unwrap() {
         interval_timer.tick().await;
+        systemd::beat(chain_id);
         
         if !*running.lock().unwrap() {
             break;
         }
+
+        // Auf SIGHUP geänderter Endpoint bzw. Intervall gilt ab diesem Poll
+        if runtime.has_changed().unwrap_or(false) {
+            let config = runtime.borrow_and_update().clone();
+            match config.http_endpoint {
+                Some(endpoint) if endpoint != http_endpoint => {
+                    info!("🌐 HTTP endpoint switched: {} -> {}", http_endpoint, endpoint);
+                    http_endpoint = endpoint;
+                    last_data_hash = None;
+                }
+                Some(_) => {}
+                None => warn!("⚠️  HTTP endpoint removed from configuration, keeping {} until restart", http_endpoint),
+            }
+            if config.http_poll_interval != http_poll_interval {
+                http_poll_interval = config.http_poll_interval;
+                interval_timer = interval(Duration::from_secs(http_poll_interval));
+                interval_timer.tick().await;
+            }
+        }
         
         // Check if we should switch to gossip mode (failure threshold)
         {
@@ -239,7 +293,7 @@ pub async fn run_http_primary_with_gossip_fallback(
         // Simplified: Only check for HTTP failures, not time-based switching
         
         // Try HTTP polling
-        match fetch_http_preconf(&client, &http_endpoint).await {
+        match fetch_http_preconf(&client, &http_endpoint, store.max_payload_bytes()).await {
             Ok(Some((data_hash, preconf_data))) => {
                 // Check if this is new data
                 if let Some(ref last_hash) = last_data_hash {
@@ -264,7 +318,7 @@ pub async fn run_http_primary_with_gossip_fallback(
                 }
                 
                 // Process and save the preconf
-                    match process_http_preconf(preconf_data, chain_id, output_dir).await {
+                    match process_http_preconf(preconf_data, &store).await {
                         Ok(block_number) => {
                             // FIXED: Only check for duplicates in hybrid mode (when gossip is active)
                             let is_duplicate = {
@@ -341,6 +395,7 @@ pub async fn run_http_primary_with_gossip_fallback(
                                         let gossip_sequencer = expected_sequencer.map(|s| s.to_string());
                                         let gossip_deduplicator = deduplicator.clone();
                                         let gossip_bitmask_tracker = bitmask_tracker.clone(); // Clone for async move
+                                        let gossip_store = store.clone();
                                         
                                         gossip_task = Some(tokio::spawn(async move {
                                             info!("📡 Gossip backup task started");
@@ -355,11 +410,12 @@ pub async fn run_http_primary_with_gossip_fallback(
                                                 gossip_running,
                                                 Some(gossip_deduplicator),
                                                 Some(gossip_bitmask_tracker), // CRITICAL FIX: Pass bitmask_tracker to gossip
+                                                gossip_store,
                                             ).await {
                                                 warn!("📡 Gossip backup failed: {}", e);
                                             }
                                             info!("📡 Gossip backup task stopped");
-                                        }));
+                                        }.in_current_span()));
                                         
                                         info!("🔧 HTTP continues polling while gossip backup is active");
                                     }
@@ -403,6 +459,11 @@ pub async fn run_http_primary_with_gossip_fallback(
                             }
                         }
                         last_block_number = Some(block_number);
+                        store.record_poll(PollCursor {
+                            endpoint: http_endpoint.clone(),
+                            data_hash: data_hash.clone(),
+                            block_number,
+                        });
                         
                         // Update bitmask tracker with processed block
                         {
@@ -460,6 +521,12 @@ pub async fn run_http_primary_with_gossip_fallback(
                     }
                 }
             }
+            Err(e) if e.is::<PayloadTooLarge>() => {
+                // Die Quelle antwortet, nur diese Payload wird verworfen (zählt nicht als HTTP-Fehler)
+                warn!("🐘 HTTP: Dropped response from {}: {}", http_endpoint, e);
+                store.storage_stats().record_oversized();
+                continue;
+            }
             Ok(None) => {
                 // No new data, but HTTP is working - reset failure counter
                 {
@@ -520,6 +587,7 @@ pub async fn run_http_primary_with_gossip_fallback(
                 running,
                 Some(deduplicator), // Shared Deduplicator für Fallback
                 Some(bitmask_tracker), // CRITICAL FIX: Pass bitmask_tracker to gossip fallback
+                store,
             ).await;
         }
     }
@@ -0,0 +1,350 @@
+// index.rs - Block-Index (Nummer/Hash -> Datei) mit Persistenz in block_index.json
+//
+// Der Index wird beim Start geladen und mit dem Output-Verzeichnis abgeglichen:
+// Einträge ohne .raw Datei fliegen raus, Blöcke mit .raw + .json Metadaten, die im Index
+// fehlen (fehlende/korrupte block_index.json, Absturz vor dem Flush), werden rekonstruiert.
+// Mit KONA_BRIDGE_INDEX_MMAP liegt der Index stattdessen in block_index.idx (siehe mmapindex.rs).
+
+use crate::{
+    durability::DurableFs,
+    mmapindex::{MappedIndex, INDEX_MMAP_FILE_NAME},
+    profile,
+};
+use serde::{Deserialize, Serialize};
+use std::{
+    collections::{BTreeMap, HashMap},
+    fs,
+    path::Path,
+};
+use tracing::{info, warn};
+
+pub const INDEX_FILE_NAME: &str = "block_index.json";
+const INDEX_VERSION: u32 = 1;
+
+/// Anzahl Blöcke im Index (ältere werden nur noch über das Dateilayout gefunden), Default; siehe profile.rs
+pub const INDEX_CAPACITY: usize = 4096;
+
+#[derive(Debug, Clone)]
+pub struct IndexEntry {
+    pub block_hash: [u8; 32],
+    pub file: String,
+    pub received_unix: u64,
+    pub timestamp: u64, // Payload-Timestamp (0 = unbekannt, z.B. Einträge älterer Versionen)
+}
+
+/// Serialisierte Form von block_index.json
+#[derive(Serialize, Deserialize)]
+struct IndexFile {
+    version: u32,
+    chain_id: u64,
+    blocks: Vec<IndexRecord>,
+}
+
+#[derive(Serialize, Deserialize)]
+struct IndexRecord {
+    block_number: u64,
+    block_hash: String,
+    file: String,
+    received_unix: u64,
+    #[serde(default)]
+    timestamp: u64,
+}
+
+/// Index der zuletzt gespeicherten Blöcke
+#[derive(Default)]
+pub struct BlockIndex {
+    by_number: BTreeMap<u64, IndexEntry>,
+    by_hash: HashMap<[u8; 32], u64>,
+    mapped: Option<MappedIndex>, // gesetzt: alle Einträge liegen in block_index.idx, die Maps bleiben leer
+}
+
+impl BlockIndex {
+    /// Öffnet block_index.idx; eine neue Datei wird einmalig aus block_index.json bzw. den Metadaten befüllt
+    pub fn mapped(
+        output_dir: &Path,
+        chain_id: u64,
+        capacity: u32,
+    ) -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        let mut mapped = MappedIndex::open(&output_dir.join(INDEX_MMAP_FILE_NAME), chain_id, capacity)?;
+        if mapped.iter().next().is_none() {
+            let imported = Self::load(output_dir, chain_id);
+            for (block_number, entry) in &imported.by_number {
+                mapped.insert(*block_number, entry);
+            }
+            if imported.len() > 0 {
+                info!("📇 Imported {} entries into {}", imported.len(), INDEX_MMAP_FILE_NAME);
+            }
+        }
+        Ok(Self {
+            mapped: Some(mapped),
+            ..Self::default()
+        })
+    }
+
+    /// true = Index liegt in block_index.idx, block_index.json wird nicht geschrieben
+    pub fn is_mapped(&self) -> bool {
+        self.mapped.is_some()
+    }
+
+    pub fn insert(&mut self, block_number: u64, entry: IndexEntry) {
+        if let Some(mapped) = &mut self.mapped {
+            mapped.insert(block_number, &entry);
+            return;
+        }
+        let block_hash = entry.block_hash;
+        if let Some(old) = self.by_number.insert(block_number, entry) {
+            if old.block_hash != block_hash {
+                self.by_hash.remove(&old.block_hash);
+            }
+        }
+        self.by_hash.insert(block_hash, block_number);
+
+        while self.by_number.len() > profile::current().index_capacity {
+            if let Some((_, old)) = self.by_number.pop_first() {
+                self.by_hash.remove(&old.block_hash);
+            }
+        }
+    }
+
+    pub fn get(&self, block_number: u64) -> Option<IndexEntry> {
+        match &self.mapped {
+            Some(mapped) => mapped.get(block_number),
+            None => self.by_number.get(&block_number).cloned(),
+        }
+    }
+
+    pub fn get_by_hash(&self, block_hash: &[u8; 32]) -> Option<IndexEntry> {
+        match &self.mapped {
+            Some(mapped) => mapped.get_by_hash(block_hash),
+            None => self.by_hash.get(block_hash).and_then(|n| self.by_number.get(n)).cloned(),
+        }
+    }
+
+    /// Neuester (back = 0) bzw. ein vorheriger Block
+    pub fn latest(&self, back: usize) -> Option<IndexEntry> {
+        match &self.mapped {
+            Some(mapped) => mapped.latest(back).map(|(_, e)| e),
+            None => self.by_number.values().rev().nth(back).cloned(),
+        }
+    }
+
+    /// Neuester Block mit Nummer
+    pub fn latest_block(&self) -> Option<(u64, IndexEntry)> {
+        match &self.mapped {
+            Some(mapped) => mapped.latest(0),
+            None => self.by_number.iter().next_back().map(|(n, e)| (*n, e.clone())),
+        }
+    }
+
+    /// Block mit dem Payload-Timestamp am nächsten an `timestamp` (`closest`) bzw. der neueste
+    /// mit Timestamp <= `timestamp`. Einträge ohne Timestamp werden ignoriert.
+    pub fn by_timestamp(&self, timestamp: u64, closest: bool) -> Option<IndexEntry> {
+        if let Some(mapped) = &self.mapped {
+            let entries = mapped.iter().map(|(_, e)| e).filter(|e| e.timestamp > 0);
+            return if closest {
+                entries.min_by_key(|e| e.timestamp.abs_diff(timestamp))
+            } else {
+                entries.filter(|e| e.timestamp <= timestamp).max_by_key(|e| e.timestamp)
+            };
+        }
+        let entries = self.by_number.values().filter(|e| e.timestamp > 0);
+        if closest {
+            entries.min_by_key(|e| e.timestamp.abs_diff(timestamp)).cloned()
+        } else {
+            entries.filter(|e| e.timestamp <= timestamp).max_by_key(|e| e.timestamp).cloned()
+        }
+    }
+
+    /// Alle Blöcke mit Dateiname (aufsteigend)
+    pub fn files(&self) -> Vec<(u64, String)> {
+        match &self.mapped {
+            Some(mapped) => mapped.iter().map(|(n, e)| (n, e.file)).collect(),
+            None => self.by_number.iter().map(|(n, e)| (*n, e.file.clone())).collect(),
+        }
+    }
+
+    pub fn len(&self) -> usize {
+        match &self.mapped {
+            Some(mapped) => mapped.iter().count(),
+            None => self.by_number.len(),
+        }
+    }
+
+    /// Entfernt die Blöcke from..=to (z.B. nach einem Purge)
+    pub fn remove_range(&mut self, from: u64, to: u64) -> usize {
+        if let Some(mapped) = &mut self.mapped {
+            return mapped.range(from, to).filter(|n| mapped.remove(*n)).count();
+        }
+        if from > to {
+            return 0;
+        }
+        let removed: Vec<u64> = self.by_number.range(from..=to).map(|(n, _)| *n).collect();
+        for block_number in &removed {
+            if let Some(entry) = self.by_number.remove(block_number) {
+                self.by_hash.remove(&entry.block_hash);
+            }
+        }
+        removed.len()
+    }
+
+    /// Entfernt Einträge, deren .raw Datei nicht mehr existiert (z.B. nach TTL-Cleanup); in block_index.idx nur vom
+    /// ältesten Block aufwärts bis zum ersten vorhandenen, damit nicht bei jedem Flush das ganze Fenster geprüft wird
+    pub fn retain_existing(&mut self, output_dir: &Path) -> usize {
+        if let Some(mapped) = &mut self.mapped {
+            let missing: Vec<u64> = mapped
+                .iter()
+                .take_while(|(_, entry)| !output_dir.join(&entry.file).exists())
+                .map(|(n, _)| n)
+                .collect();
+            return missing.into_iter().filter(|n| mapped.remove(*n)).count();
+        }
+        let before = self.by_number.len();
+        let by_hash = &mut self.by_hash;
+        self.by_number.retain(|_, entry| {
+            let exists = output_dir.join(&entry.file).exists();
+            if !exists {
+                by_hash.remove(&entry.block_hash);
+            }
+            exists
+        });
+        before - self.by_number.len()
+    }
+
+    /// Lädt block_index.json und gleicht den Index mit den Dateien im Output-Verzeichnis ab
+    pub fn load(output_dir: &Path, chain_id: u64) -> Self {
+        let mut index = Self::default();
+        let path = output_dir.join(INDEX_FILE_NAME);
+
+        match fs::read(&path) {
+            Ok(data) => match serde_json::from_slice::<IndexFile>(&data) {
+                Ok(file) if file.version == INDEX_VERSION && file.chain_id == chain_id => {
+                    for record in file.blocks {
+                        if let Some(block_hash) = parse_hash(&record.block_hash) {
+                            index.insert(
+                                record.block_number,
+                                IndexEntry {
+                                    block_hash,
+                                    file: record.file,
+                                    received_unix: record.received_unix,
+                                    timestamp: record.timestamp,
+                                },
+                            );
+                        }
+                    }
+                }
+                Ok(file) => warn!(
+                    "⚠️  Ignoring {:?} (version {}, chain {}), rebuilding from metadata",
+                    path, file.version, file.chain_id
+                ),
+                Err(e) => warn!("⚠️  Corrupt {:?} ({}), rebuilding from metadata", path, e),
+            },
+            Err(e) if e.kind() == std::io::ErrorKind::NotFound => {}
+            Err(e) => warn!("⚠️  Cannot read {:?} ({}), rebuilding from metadata", path, e),
+        }
+
+        let loaded = index.len();
+        let dropped = index.retain_existing(output_dir);
+        let recovered = index.recover_from_metadata(output_dir, chain_id);
+
+        if loaded > 0 || recovered > 0 {
+            info!(
+                "📇 Block index: {} entries ({} loaded, {} dropped, {} recovered from metadata)",
+                index.len(),
+                loaded,
+                dropped,
+                recovered
+            );
+        }
+        index
+    }
+
+    /// Scannt block_{chain}_{n}.json Metadaten und ergänzt fehlende oder abweichende Einträge
+    fn recover_from_metadata(&mut self, output_dir: &Path, chain_id: u64) -> usize {
+        let entries = match fs::read_dir(output_dir) {
+            Ok(entries) => entries,
+            Err(e) => {
+                warn!("⚠️  Cannot scan {:?} for orphaned preconfs: {}", output_dir, e);
+                return 0;
+            }
+        };
+
+        let prefix = format!("block_{}_", chain_id);
+        let mut recovered = 0;
+
+        for entry in entries.flatten() {
+            let name = entry.file_name().to_string_lossy().into_owned();
+            let Some(block_number) = name
+                .strip_prefix(&prefix)
+                .and_then(|rest| rest.strip_suffix(".json"))
+                .and_then(|n| n.parse::<u64>().ok())
+            else {
+                continue;
+            };
+
+            let Some(recovered_entry) = read_metadata(output_dir, &entry.path()) else {
+                continue;
+            };
+            let known = self
+                .get(block_number)
+                .map_or(false, |e| e.block_hash == recovered_entry.block_hash && e.file == recovered_entry.file);
+            if !known {
+                self.insert(block_number, recovered_entry);
+                recovered += 1;
+            }
+        }
+
+        recovered
+    }
+
+    /// Schreibt den Index atomar nach block_index.json (block_index.idx: nur msync)
+    pub fn save(&self, output_dir: &Path, chain_id: u64, durable: &DurableFs) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        if let Some(mapped) = &self.mapped {
+            mapped.flush().map_err(|e| format!("Failed to sync {}: {}", INDEX_MMAP_FILE_NAME, e))?;
+            return Ok(());
+        }
+        let file = IndexFile {
+            version: INDEX_VERSION,
+            chain_id,
+            blocks: self
+                .by_number
+                .iter()
+                .map(|(number, entry)| IndexRecord {
+                    block_number: *number,
+                    block_hash: format!("0x{}", hex::encode(entry.block_hash)),
+                    file: entry.file.clone(),
+                    received_unix: entry.received_unix,
+                    timestamp: entry.timestamp,
+                })
+                .collect(),
+        };
+
+        let path = output_dir.join(INDEX_FILE_NAME);
+        let temp_path = path.with_extension("json.tmp");
+        durable.write_atomic_blocking(&temp_path, &path, &serde_json::to_vec(&file)?)
+            .map_err(|e| format!("Failed to write {:?}: {}", path, e))?;
+        Ok(())
+    }
+}
+
+/// Liest einen Index-Eintrag aus einer Metadaten-Datei (nur wenn die .raw Datei existiert)
+fn read_metadata(output_dir: &Path, meta_path: &Path) -> Option<IndexEntry> {
+    let data = fs::read(meta_path).ok()?;
+    let metadata: serde_json::Value = serde_json::from_slice(&data).ok()?;
+
+    let file = metadata["file_path"].as_str()?.to_string();
+    if !output_dir.join(&file).exists() {
+        return None;
+    }
+
+    Some(IndexEntry {
+        block_hash: parse_hash(metadata["block_hash"].as_str()?)?,
+        file,
+        received_unix: metadata["received_unix"].as_u64().unwrap_or(0),
+        timestamp: metadata["timestamp"].as_u64().unwrap_or(0),
+    })
+}
+
+fn parse_hash(value: &str) -> Option<[u8; 32]> {
+    let bytes = hex::decode(value.trim_start_matches("0x")).ok()?;
+    bytes.try_into().ok()
+}
@@ -0,0 +1,187 @@
+// journal.rs - Write-Journal für crash-sichere Preconf-Einträge
+//
+// Vor dem Schreiben eines Blocks wird "B <block> <datei>" angehängt, danach "C <block>"
+// (vollständig) bzw. "A <block>" (abgebrochen). Ein Absturz zwischen .raw, Symlinks und
+// .json hinterlässt einen Block ohne "C". Beim Start werden diese Blöcke geprüft:
+// sind .raw (Container parsebar) und .json (verweist auf die .raw) konsistent, bleibt der Eintrag,
+// sonst werden beide Dateien verworfen. Zusätzlich werden liegen gebliebene Temp-Dateien entfernt.
+
+use crate::{c4::C4_SUFFIX, codec::PreconfContainer, perms, render::RENDERED_SUFFIX, utils::remove_stale_temp_files};
+use std::{
+    collections::{BTreeMap, HashMap},
+    fs,
+    path::{Path, PathBuf},
+    time::Duration,
+};
+use tokio::{fs::File, io::AsyncWriteExt, sync::Mutex};
+use tracing::{info, warn};
+
+pub const JOURNAL_FILE_NAME: &str = "write_journal.log";
+
+/// Ab dieser Größe wird das Journal geleert, sobald kein Schreibvorgang offen ist
+const JOURNAL_COMPACT_SIZE: u64 = 1024 * 1024;
+
+struct JournalState {
+    file: Option<File>,
+    in_flight: HashMap<u64, String>,
+}
+
+pub struct WriteJournal {
+    path: PathBuf,
+    sync: bool, // Einträge syncen (Durability flush/fsync-each)
+    state: Mutex<JournalState>,
+}
+
+impl WriteJournal {
+    /// Stellt den Zustand nach einem Absturz wieder her und öffnet ein leeres Journal
+    pub fn open(output_dir: &Path, sync: bool) -> Self {
+        let path = output_dir.join(JOURNAL_FILE_NAME);
+        recover(output_dir, &path);
+
+        // Append-Modus, damit nach dem Kürzen (set_len(0)) wieder am Anfang geschrieben wird
+        let file = fs::OpenOptions::new()
+            .create(true)
+            .append(true)
+            .open(&path)
+            .and_then(|file| file.set_len(0).map(|_| file))
+            .inspect(|_| perms::apply_file(&path))
+            .map(File::from_std)
+            .map_err(|e| warn!("⚠️  Write journal disabled, cannot open {:?}: {}", path, e))
+            .ok();
+
+        Self {
+            path,
+            sync,
+            state: Mutex::new(JournalState {
+                file,
+                in_flight: HashMap::new(),
+            }),
+        }
+    }
+
+    /// Markiert den Beginn eines Schreibvorgangs
+    pub async fn begin(&self, block_number: u64, filename: &str) {
+        let mut state = self.state.lock().await;
+        state.in_flight.insert(block_number, filename.to_string());
+        self.append(&mut state, format!("B {} {}\n", block_number, filename)).await;
+    }
+
+    /// Markiert das Ende eines Schreibvorgangs. Ein abgebrochener Eintrag wird sofort geprüft
+    /// und bei Inkonsistenz verworfen, damit er nicht bis zum nächsten Start liegen bleibt.
+    pub async fn end(&self, output_dir: &Path, block_number: u64, committed: bool) {
+        let mut state = self.state.lock().await;
+        let filename = state.in_flight.remove(&block_number);
+        let record = if committed { "C" } else { "A" };
+        self.append(&mut state, format!("{} {}\n", record, block_number)).await;
+
+        if let (false, Some(filename)) = (committed, filename) {
+            if !entry_is_consistent(output_dir, &filename) {
+                discard_entry(output_dir, &filename);
+                warn!("🗑️  Discarded incomplete preconf for block {} ({})", block_number, filename);
+            }
+        }
+
+        // Journal kürzen, sobald kein Schreibvorgang mehr offen ist
+        if !state.in_flight.is_empty() {
+            return;
+        }
+        if let Some(ref file) = state.file {
+            if file.metadata().await.map_or(false, |m| m.len() > JOURNAL_COMPACT_SIZE) {
+                if let Err(e) = file.set_len(0).await {
+                    warn!("⚠️  Failed to compact {:?}: {}", self.path, e);
+                }
+            }
+        }
+    }
+
+    async fn append(&self, state: &mut JournalState, record: String) {
+        let Some(ref mut file) = state.file else {
+            return;
+        };
+        let mut result = file.write_all(record.as_bytes()).await;
+        if result.is_ok() && self.sync {
+            result = file.sync_data().await;
+        }
+        if let Err(e) = result {
+            warn!("⚠️  Failed to write {:?}: {}", self.path, e);
+        }
+    }
+}
+
+/// Prüft alle nicht abgeschlossenen Einträge des Journals und entfernt Temp-Dateien
+fn recover(output_dir: &Path, journal_path: &Path) {
+    // Beim Start schreibt niemand -> alle Temp-Dateien sind Reste eines Absturzes
+    let removed = remove_stale_temp_files(output_dir, Duration::ZERO);
+    if removed > 0 {
+        info!("🧹 Removed {} stranded temp files", removed);
+    }
+
+    let content = match fs::read_to_string(journal_path) {
+        Ok(content) => content,
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => return,
+        Err(e) => {
+            warn!("⚠️  Cannot read {:?}: {}", journal_path, e);
+            return;
+        }
+    };
+
+    // Letzter Zustand je Block: Some(datei) = offen oder abgebrochen, None = abgeschlossen
+    let mut pending: BTreeMap<u64, Option<String>> = BTreeMap::new();
+    for line in content.lines() {
+        let mut parts = line.split_whitespace();
+        let (Some(record), Some(Ok(block_number))) = (parts.next(), parts.next().map(str::parse::<u64>)) else {
+            continue; // unvollständige letzte Zeile
+        };
+        match (record, parts.next()) {
+            ("B", Some(filename)) => {
+                pending.insert(block_number, Some(filename.to_string()));
+            }
+            ("C", _) => {
+                pending.insert(block_number, None);
+            }
+            _ => {} // "A": Datei aus "B" bleibt zur Prüfung stehen
+        }
+    }
+
+    let mut kept = 0;
+    let mut discarded = 0;
+    for (block_number, filename) in pending {
+        let Some(filename) = filename else {
+            continue;
+        };
+        if entry_is_consistent(output_dir, &filename) {
+            kept += 1;
+        } else {
+            discard_entry(output_dir, &filename);
+            warn!("🗑️  Discarded incomplete preconf for block {} ({})", block_number, filename);
+            discarded += 1;
+        }
+    }
+
+    if kept > 0 || discarded > 0 {
+        info!("📓 Write journal recovery: {} entries kept, {} discarded", kept, discarded);
+    }
+}
+
+/// .raw parsebar und .json verweist auf genau diese Datei
+fn entry_is_consistent(output_dir: &Path, filename: &str) -> bool {
+    let raw_ok = fs::read(output_dir.join(filename))
+        .map_or(false, |data| PreconfContainer::parse(&data).is_ok());
+    if !raw_ok {
+        return false;
+    }
+
+    let meta_path = output_dir.join(filename).with_extension("json");
+    fs::read(&meta_path)
+        .ok()
+        .and_then(|data| serde_json::from_slice::<serde_json::Value>(&data).ok())
+        .map_or(false, |meta| meta["file_path"].as_str() == Some(filename))
+}
+
+fn discard_entry(output_dir: &Path, filename: &str) {
+    let raw_path = output_dir.join(filename);
+    let _ = fs::remove_file(raw_path.with_extension("json"));
+    let _ = fs::remove_file(raw_path.with_extension(&RENDERED_SUFFIX[1..]));
+    let _ = fs::remove_file(raw_path.with_extension(&C4_SUFFIX[1..]));
+    let _ = fs::remove_file(&raw_path);
+}
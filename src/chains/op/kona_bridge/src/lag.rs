@@ -0,0 +1,49 @@
+// lag.rs - Capture-Lag: Abstand zwischen dem Head der Chain und dem neuesten gespeicherten Preconf
+//
+// Head mit KONA_BRIDGE_HEAD_RPC aus eth_blockNumber (siehe validation::run_head_poll), bis zur nächsten Abfrage mit
+// der Blockzeit fortgeschrieben. Ohne RPC oder wenn die letzte Antwort älter als RPC_HEAD_MAX_AGE_SECS ist, wird der
+// Head aus dem Payload-Timestamp des neuesten Preconfs abgeleitet: block + (jetzt - timestamp) / Blockzeit.
+// Die Blockzeit stammt aus rollup.json bzw. der Superchain-Registry (Default 2s).
+//
+//   blocks   Head minus neuester gespeicherter Block (0 = aktuell)
+//   secs     Sekunden seit der Produktion des neuesten Preconfs (Payload-Timestamp, sonst Empfangszeit)
+//
+// Gemeldet in status.json, per Metrik-Push (capture_lag_blocks, capture_lag_secs, chain_head) und in /debug/vars.
+
+use crate::index::IndexEntry;
+use serde::Serialize;
+
+pub const DEFAULT_BLOCK_TIME_SECS: u64 = 2;
+/// Ältere Antworten des Head-RPC gelten als ausgefallen (dann zählt der Payload-Timestamp)
+const RPC_HEAD_MAX_AGE_SECS: u64 = 120;
+
+/// Capture-Lag einer Chain
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+pub struct CaptureLag {
+    pub head: u64,            // Head der Chain (RPC oder abgeleitet)
+    pub latest: u64,          // neuester gespeicherter Block
+    pub blocks: u64,          // head - latest
+    pub secs: u64,            // Alter des neuesten Preconfs
+    pub source: &'static str, // "rpc" oder "timestamp"
+}
+
+/// Berechnet den Lag aus dem neuesten Eintrag und dem Head laut RPC (Blocknummer, Alter in Sekunden)
+pub fn measure(
+    latest: Option<(u64, IndexEntry)>,
+    rpc_head: Option<(u64, u64)>,
+    block_time: u64,
+    now: u64,
+) -> Option<CaptureLag> {
+    let (latest, entry) = latest?;
+    let block_time = block_time.max(1);
+    let produced = if entry.timestamp > 0 { entry.timestamp } else { entry.received_unix };
+    let secs = now.saturating_sub(produced);
+
+    let (head, source) = match rpc_head.filter(|(_, age)| *age <= RPC_HEAD_MAX_AGE_SECS) {
+        Some((number, age)) => (number + age / block_time, "rpc"),
+        None => (latest + secs / block_time, "timestamp"),
+    };
+    // Der gespeicherte Block kann vor der nächsten RPC-Abfrage über dem Head liegen
+    let head = head.max(latest);
+    Some(CaptureLag { head, latest, blocks: head - latest, secs, source })
+}
@@ -1,29 +1,107 @@
 // lib.rs - HTTP-first Kona-Bridge mit modularer Struktur
 // Haupteinstiegspunkt und C-FFI für die Kona-Bridge
 
+mod access;
+mod accuracy;
+mod admin;
+mod alerts;
+mod archive;
+mod attestation;
+mod backpressure;
+mod blobs;
+mod blockhash;
+mod c4;
+mod cbor;
+mod chaincheck;
+mod checkpoint;
+pub mod cli;
+mod codec;
+mod confidence;
 mod config;
+mod configfile;
+mod continuity;
+mod debug;
+pub mod decode;
+mod delta;
+mod dirlock;
+mod diskguard;
+mod dnscache;
+mod durability;
+mod equivocation;
 mod gossip;
+mod grpc;
+mod handoff;
+mod ha;
+mod hardfork;
+mod health;
+mod hooks;
 mod http;
+mod index;
+mod journal;
+mod lag;
+mod logging;
+mod membudget;
+mod metawriter;
+mod metrics;
+mod mmapindex;
+mod perms;
+mod pointer;
+mod postgres;
 mod processing;
+mod profile;
+mod provenance;
+mod publish;
+mod query;
+mod redis;
+mod registry;
+mod reorg;
+mod reload;
+mod render;
+mod retention;
+mod rollup;
+mod rpc;
+mod s3;
+mod sampling;
+mod serve;
+mod settings;
+#[cfg(unix)]
+mod shm;
+mod signer;
+mod signerhistory;
+mod sink;
+mod ssz;
+mod stats;
+mod status;
+mod stdout;
+mod storage;
+mod systemd;
+mod tiering;
+mod tls;
+mod txdecode;
+mod txindex;
 mod types;
 mod utils;
+mod validation;
+mod zdict;
 
 use config::ChainConfig;
 use http::run_http_primary_with_gossip_fallback;
-use types::{BridgeMode, BlockBitmaskTracker, BlockDeduplicator, HttpHealthTracker, KonaBridgeConfig, KonaBridgeHandle, KonaBridgeStats};
+use settings::BridgeSettings;
+use stats::StorageStats;
+use storage::PreconfStore;
+use types::{BridgeMode, BlockBitmaskTracker, BlockDeduplicator, HttpHealthTracker, KonaBridgeConfig, KonaBridgeHandle, KonaBridgeHistograms, KonaBridgeStats, KonaBridgeStorageStats};
 use utils::cleanup_old_files;
 
 use alloy::primitives::Address;
 use std::{
     ffi::CStr,
-    fs,
-    os::raw::c_int,
-    path::PathBuf,
+    os::raw::{c_char, c_int},
+    path::{Path, PathBuf},
     sync::{Arc, Mutex},
     thread,
-    time::Duration,
+    time::{Duration, SystemTime, UNIX_EPOCH},
 };
-use tracing::{error, info, warn};
+use tracing::{error, info, warn, Instrument};
 
 
 /// Startet die echte Kona-Bridge mit korrektem Preconf-Format
@@ -104,7 +182,7 @@ pub extern "C" fn kona_bridge_start(config: *const KonaBridgeConfig) -> *mut Kon
     info!("📁 Output: {}", output_dir);
 
     // Create output directory
-    if let Err(e) = fs::create_dir_all(&output_dir) {
+    if let Err(e) = perms::create_dir_all(Path::new(&output_dir)) {
         error!("❌ Failed to create output directory: {}", e);
         return std::ptr::null_mut();
     }
@@ -127,9 +205,11 @@ pub extern "C" fn kona_bridge_start(config: *const KonaBridgeConfig) -> *mut Kon
     }));
 
     let running = Arc::new(Mutex::new(true));
+    let storage_stats = Arc::new(StorageStats::default());
 
     // Start Kona network in separate thread
     let stats_clone = stats.clone();
+    let storage_stats_clone = storage_stats.clone();
     let running_clone = running.clone();
     let chain_id = config.chain_id as u64;
     let disc_port = config.disc_port as u16;
@@ -174,6 +254,7 @@ pub extern "C" fn kona_bridge_start(config: *const KonaBridgeConfig) -> *mut Kon
                 http_failure_threshold,
                 sequencer_address.as_deref(),
                 stats_clone,
+                storage_stats_clone,
                 running_clone,
             ).await {
                 error!("❌ HTTP-first network failed: {}", e);
@@ -181,7 +262,7 @@ pub extern "C" fn kona_bridge_start(config: *const KonaBridgeConfig) -> *mut Kon
             } else {
                 info!("✅ HTTP-first network completed successfully");
             }
-        });
+        }.instrument(logging::chain_span(chain_id, false)));
 
         info!("🛑 Kona-P2P bridge thread stopping...");
     });
@@ -205,6 +286,7 @@ pub extern "C" fn kona_bridge_start(config: *const KonaBridgeConfig) -> *mut Kon
         config: config_copy,
         runtime: None,
         stats,
+        storage_stats,
         running,
         thread_handle: Some(thread_handle),
         deduplicator: Arc::new(Mutex::new(BlockDeduplicator::new())),
@@ -227,43 +309,320 @@ async fn run_http_first_network(
     http_failure_threshold: u32,
     expected_sequencer: Option<&str>,
     stats: Arc<Mutex<KonaBridgeStats>>,
+    storage_stats: Arc<StorageStats>,
     running: Arc<Mutex<bool>>,
 ) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
     
+    // Optionale Features (Shared-Memory-Index, S3 etc.) aus Umgebungsvariablen
+    let mut settings = BridgeSettings::from_env(chain_id);
+    // Ports aus der Konfiguration (z.B. [chains.<id>] mit mehreren Chains in einem Prozess) vor denen des Aufrufers
+    let disc_port = settings.disc_port.unwrap_or(disc_port);
+    let gossip_port = settings.gossip_port.unwrap_or(gossip_port);
+
+    // Chain-Unterverzeichnis: Store, Cleanup und latest-Symlinks arbeiten nur darin
+    let chain_output_dir = settings.chain_dir(output_dir, chain_id);
+    perms::create_dir_all(&chain_output_dir)?;
+    let output_dir = &chain_output_dir;
+
+    // Nur eine Bridge je Chain und Verzeichnis; die Sperre hält bis zum Ende dieser Funktion.
+    // Im Aktiv/Standby-Betrieb übernimmt das die Lease (siehe ha.rs).
+    let wait = Duration::from_secs(settings.lock_wait_secs);
+    let _dir_lock = match settings.ha {
+        Some(_) => None,
+        None => match dirlock::DirLock::acquire(output_dir, chain_id, settings.lock_takeover, wait).await {
+            Ok(lock) => Some(lock),
+            Err(e) => {
+                *running.lock().unwrap() = false;
+                return Err(e);
+            }
+        },
+    };
+    storage_stats.set_output_dir(chain_output_dir.clone(), chain_id);
+
+    // Chain-Konfiguration: eigene Einträge in config.rs, rollup.json (siehe rollup.rs), sonst Superchain-Registry
+    let now = SystemTime::now().duration_since(UNIX_EPOCH).map_or(0, |d| d.as_secs());
+    let rollup_config = match settings.rollup_config {
+        Some(ref path) => match rollup::load_for_chain(path, chain_id) {
+            Ok(rollup) => {
+                info!("📜 Rollup config {}: {}", path.display(), rollup::describe(&rollup, now));
+                Some(rollup)
+            }
+            Err(e) => {
+                *running.lock().unwrap() = false;
+                return Err(e);
+            }
+        },
+        None => None,
+    };
+    let registry_chain = registry::lookup(chain_id);
+    match registry_chain {
+        Some(ref chain) => {
+            info!(
+                "📚 Registry: {} (chain {}, L1 {}, {}s blocks, hardfork {})",
+                chain.name,
+                chain_id,
+                chain.l1_chain_id,
+                chain.block_time,
+                chain.hardforks.active(now)
+            );
+            if let Some((name, time)) = chain.hardforks.next(now) {
+                info!("📚 Next hardfork {} at timestamp {}", name, time);
+            }
+        }
+        None if rollup_config.is_none() && config::network_name(chain_id).is_none() => warn!(
+            "⚠️  Chain ID {} neither in the Superchain registry nor KONA_BRIDGE_ROLLUP_CONFIG, configure signer and bootnodes",
+            chain_id
+        ),
+        None => {}
+    }
+
+    // Payload-Version und Gossip-Topic folgen dem Hardfork-Zeitplan, Wechsel ohne Neustart (siehe hardfork.rs)
+    let hardforks = hardfork::HardforkSchedule::for_chain(chain_id, rollup_config.as_ref());
+    if let Some(ref schedule) = hardforks {
+        tokio::spawn(hardfork::run_fork_watch(chain_id, schedule.clone(), running.clone()));
+    }
+
+    let block_time = rollup_config
+        .as_ref()
+        .map(|rollup| rollup.block_time)
+        .or(registry_chain.as_ref().map(|chain| chain.block_time))
+        .unwrap_or(lag::DEFAULT_BLOCK_TIME_SECS);
+
+    let mut chain_config = match rollup_config {
+        Some(rollup) => ChainConfig::from_rollup(rollup),
+        None => ChainConfig::for_chain(chain_id),
+    };
+    // Live-Signer über die SystemConfig aus rollup.json, sofern KONA_BRIDGE_SYSTEM_CONFIG nicht gesetzt ist
+    if let (Some(ref mut signer), Some(ref rollup)) = (&mut settings.signer, &chain_config.rollup_config) {
+        if signer.system_config.is_none() && rollup.l1_system_config_address != Address::ZERO {
+            signer.system_config = Some(rollup.l1_system_config_address);
+        }
+    }
+    // Konfigurierte Bootnodes zuerst, das Gossip-Netzwerk nutzt nur die ersten drei
+    if !settings.bootnodes.is_empty() {
+        let mut bootnodes: Vec<discv5::Enr> = settings
+            .bootnodes
+            .iter()
+            .filter_map(|enr| enr.parse().map_err(|e| warn!("⚠️  Ignoring invalid bootnode {}: {}", enr, e)).ok())
+            .collect();
+        bootnodes.append(&mut chain_config.bootnodes);
+        chain_config.bootnodes = bootnodes;
+    }
+    
+    // Override with expected sequencer from C config if provided
+    if let Some(expected) = expected_sequencer {
+        if let Ok(addr) = expected.parse::<Address>() {
+            chain_config.unsafe_signer = addr;
+            info!("🔐 Using sequencer from C config: {}", addr);
+        }
+    }
+
+    // Endpoint, Poll-Intervall, Signer, TTL/Retention und Log-Level sind auf SIGHUP neu ladbar (siehe reload.rs)
+    let defaults = reload::RuntimeDefaults {
+        http_endpoint: chain_config.get_http_endpoint(),
+        http_poll_interval,
+        ttl_minutes,
+        finality_rpc: registry_chain.and_then(|chain| chain.public_rpc),
+    };
+    let runtime_config = reload::RuntimeConfig::new(&settings, &defaults);
+    let ttl_minutes = runtime_config.ttl_minutes;
+    let http_poll_interval = runtime_config.http_poll_interval;
+    let http_endpoint = runtime_config.http_endpoint.clone();
+    let signers_configured = !runtime_config.signers.is_empty();
+    let runtime = reload::start_config_reload(chain_id, runtime_config, defaults, running.clone());
+
+    // HTTP-Quelle muss die konfigurierte Chain liefern, sonst landen fremde Preconfs hier (siehe chaincheck.rs)
+    if let (Some(ref endpoint), true) = (&http_endpoint, settings.chain_check) {
+        let mut signers: Vec<Address> = runtime.borrow().signers.iter().map(|entry| entry.address).collect();
+        signers.push(chain_config.current_signer());
+        if let Err(e) = chaincheck::verify_endpoint(&reqwest::Client::new(), endpoint, chain_id, &signers).await {
+            *running.lock().unwrap() = false;
+            return Err(e);
+        }
+        let check_config = chain_config.clone();
+        let check_secs = settings.chain_check_secs;
+        tokio::spawn(chaincheck::run_chain_check(runtime.clone(), chain_id, check_config, check_secs, running.clone()));
+    }
+    if let Some(ref mut redis) = settings.redis {
+        if redis.ttl_secs == 0 {
+            redis.ttl_secs = ttl_minutes * 60;
+        }
+    }
+
+    // Aktiv/Standby: bis zur Lease nur Dry-Run, erst danach Store, Tasks und Capture (siehe ha.rs)
+    let lease = match settings.ha {
+        Some(ref ha_settings) => {
+            let lease = ha::Lease::new(ha_settings, chain_id, output_dir);
+            let stall_secs = settings.watchdog_stall_secs.max(3 * http_poll_interval);
+            let signer = chain_config.current_signer();
+            match ha::wait_for_lease(lease, runtime.clone(), signer, stall_secs, running.clone()).await {
+                Ok(Some(lease)) => Some(Arc::new(tokio::sync::Mutex::new(lease))),
+                Ok(None) => return Ok(()),
+                Err(e) => {
+                    *running.lock().unwrap() = false;
+                    return Err(e);
+                }
+            }
+        }
+        None => None,
+    };
+    if let Some(ref lease) = lease {
+        tokio::spawn(ha::run_lease_renewal(lease.clone(), running.clone()));
+    }
+
     info!("🚀 HTTP-first network starting for chain {}", chain_id);
     info!("🧹 TTL cleanup: {} minutes, interval: {} minutes", ttl_minutes, cleanup_interval);
     info!("🌐 HTTP polling: {}s interval, {} failure threshold", http_poll_interval, http_failure_threshold);
+    info!("💾 Durability: {}", settings.durability);
     
     // Start TTL cleanup task
     let cleanup_output_dir = output_dir.clone();
     let cleanup_running = running.clone();
+    let cleanup_stats = storage_stats.clone();
+    let cleanup_runtime = runtime.clone();
+    let cleanup_reorg_grace = Duration::from_secs(settings.reorg_grace_secs);
+    // Admin-API kann einen Cleanup-Zyklus sofort anstoßen
+    let cleanup_trigger = Arc::new(tokio::sync::Notify::new());
+    let cleanup_notify = cleanup_trigger.clone();
     tokio::spawn(async move {
-        cleanup_old_files(cleanup_output_dir, ttl_minutes, cleanup_interval, cleanup_running).await;
+        cleanup_old_files(
+            cleanup_output_dir,
+            chain_id,
+            cleanup_interval,
+            cleanup_runtime,
+            cleanup_stats,
+            cleanup_reorg_grace,
+            cleanup_notify,
+            cleanup_running,
+        )
+        .await;
     });
-    
-    // Determine network name from chain_id
-    let network_name = match chain_id {
-        10 => "op-mainnet",
-        8453 => "base", 
-        130 => "unichain",
-        480 => "worldchain",
-        7777777 => "zora",
-        _ => {
-            warn!("⚠️  Unknown chain ID {}, using default config", chain_id);
-            "base" // fallback
+
+    // Reload über die Admin-API: Signer-Datei neu lesen, SystemConfig sofort abfragen
+    let (signer_reload, signer_reload_updates) = tokio::sync::watch::channel(());
+
+    // Optional: Signer live aus SystemConfig auf L1 (überschreibt den statischen Wert)
+    let live_signer = match settings.signer {
+        Some(ref signer_settings) => {
+            let reload = signer_reload_updates.clone();
+            signer::start_signer_refresh(signer_settings, chain_id, chain_config.unsafe_signer, reload, running.clone()).await
         }
+        None => None,
+    };
+    // Konfigurierte Signer mit Gültigkeitsfenstern (optional aus einer neu ladbaren Datei)
+    let signer_entries = match settings.signers_file {
+        Some(ref path) => Some(signer::start_signers_file_watch(
+            path.clone(),
+            reload::signer_updates(runtime.clone(), running.clone()),
+            signer_reload_updates.clone(),
+            running.clone(),
+        )),
+        None => signers_configured.then(|| reload::signer_updates(runtime.clone(), running.clone())),
     };
+    // Nur die Signer-Tasks halten Empfänger (Admin-API erkennt daran, ob ein Reload etwas bewirkt)
+    drop(signer_reload_updates);
+    // Gossip folgt dem aktiven Eintrag, sofern kein Live-Signer läuft
+    chain_config.signer_updates = live_signer.clone().or_else(|| {
+        signer_entries
+            .clone()
+            .map(|entries| signer::start_window_switch(entries, chain_config.unsafe_signer, running.clone()))
+    });
+    let signers = signer::SignerSet::new(signer_entries, live_signer);
 
-    let mut chain_config = ChainConfig::from(network_name, chain_id);
-    
-    // Override with expected sequencer from C config if provided
-    if let Some(expected) = expected_sequencer {
-        if let Ok(addr) = expected.parse::<Address>() {
-            chain_config.unsafe_signer = addr;
-            info!("🔐 Using sequencer from C config: {}", addr);
+    let store = Arc::new(
+        PreconfStore::new(output_dir.clone(), chain_id, &settings, storage_stats, running.clone())
+            .with_signers(signers)
+            .with_hardforks(hardforks.clone())
+            .with_block_time(block_time),
+    );
+
+    // Freien Platz überwachen: Notfall-Löschung und Schreibsperre statt abgebrochener Schreibvorgänge
+    if settings.min_free_mb > 0 {
+        tokio::spawn(diskguard::run_disk_guard(store.clone(), settings.min_free_mb, running.clone()));
+    }
+
+    // Head für die Blocknummer-Plausibilität optional per RPC verfolgen
+    if let Some(ref head_rpc) = settings.validation.head_rpc {
+        tokio::spawn(validation::run_head_poll(store.rpc_head(), head_rpc.clone(), running.clone()));
+    }
+
+    // Group-Commit: gesammelte Schreibvorgänge periodisch syncen
+    if settings.durability == durability::Durability::Group && !settings.group_commit {
+        tokio::spawn(durability::run_group_sync(store.durable_fs(), settings.durability_group_ms, running.clone()));
+    }
+
+    // Cold-Tier: ältere Blöcke im Hintergrund neu komprimieren
+    if let Some(cold_codec) = settings.cold_codec {
+        tokio::spawn(tiering::run_compactor(store.clone(), cold_codec, settings.hot_blocks, running.clone()));
+    }
+
+    // Speicherbudget: RSS überwachen und Caches unter Druck verkleinern (ein Guard je Prozess)
+    if let Some(budget) = profile::current().memory_budget {
+        tokio::spawn(membudget::run_memory_guard(budget, running.clone()));
+    }
+
+    // Transaktions-Index für bereits gespeicherte Blöcke im Hintergrund aufbauen
+    let tx_store = store.clone();
+    tokio::spawn(async move { tx_store.rebuild_tx_index().await });
+
+    // Block-Index periodisch nach block_index.json schreiben, mit Group-Commit zusammen mit Metadaten und Sync;
+    // ohne Group-Commit schreibt ein eigener Task die Metadaten abseits des Capture-Pfads
+    if settings.group_commit {
+        tokio::spawn(storage::run_group_commit(store.clone(), settings.durability_group_ms, running.clone()));
+    } else {
+        tokio::spawn(storage::run_index_flush(store.clone(), running.clone()));
+        if settings.metadata_files {
+            tokio::spawn(metawriter::run_metadata_writer(store.metadata_writer(), running.clone()));
         }
     }
+
+    // Optionaler Query-Socket für den C-Server (relativer Pfad = im Output-Verzeichnis)
+    if let Some(ref socket) = settings.query_socket {
+        let socket_path = output_dir.join(socket);
+        let query_store = store.clone();
+        let query_running = running.clone();
+        tokio::spawn(async move {
+            if let Err(e) = query::run_query_socket(&socket_path, query_store, query_running).await {
+                warn!("⚠️  Query socket disabled: {}", e);
+            }
+        });
+    }
     
+    // Optionale HTTP-API, gRPC-Service und Admin-Listener (ein Server pro Prozess für alle Chains)
+    if settings.serve_addr.is_some() || settings.grpc_addr.is_some() || settings.admin_addr.is_some() {
+        let access_control = access::AccessControl::new(&settings.access);
+        serve::register(
+            settings.serve_addr,
+            settings.grpc_addr,
+            settings.admin_addr,
+            access_control,
+            settings.tls.clone(),
+            store.clone(),
+            running.clone(),
+        );
+    }
+
+    // Admin-API (auf der HTTP-API oder dem eigenen Admin-Listener, nur mit Token)
+    if let (Some(_), Some(token)) = (settings.admin_addr.or(settings.serve_addr), settings.admin_token.clone()) {
+        let control = admin::AdminControl::new(store.clone(), cleanup_trigger, signer_reload, settings.backfill_url.clone());
+        admin::register(token, control, running.clone());
+    }
+
+    // Erkannte Lücken automatisch von der Serving-API einer anderen Bridge nachladen
+    match (settings.gap_backfill, settings.backfill_url.clone()) {
+        (true, Some(url)) => {
+            tokio::spawn(continuity::run_gap_backfill(store.clone(), url, running.clone()));
+        }
+        (true, None) => warn!("⚠️  KONA_BRIDGE_GAP_BACKFILL needs KONA_BRIDGE_BACKFILL_URL, gaps are only reported"),
+        _ => {}
+    }
+
+    // Gespeicherte Preconfs mit den kanonischen L2-Blöcken abgleichen (Preconf-Accuracy)
+    if let (Some(url), Some(tracker)) = (settings.accuracy_rpc.clone(), store.canonical_tracker()) {
+        let head = settings.accuracy_head;
+        tokio::spawn(accuracy::run_accuracy_check(store.clone(), tracker, url, head, running.clone()));
+    }
+
     // Initialize HTTP health tracker with simplified switching
     let health_tracker = Arc::new(Mutex::new(HttpHealthTracker {
         consecutive_failures: 0,
@@ -272,19 +631,52 @@ async fn run_http_first_network(
         current_mode: BridgeMode::HttpOnly,
         consecutive_success_blocks: 0,
     }));
+
+    // /healthz und /readyz der HTTP-API (Quelle, Aktualität, Schreibbarkeit)
+    if settings.serve_addr.is_some() {
+        let http_tracker = http_endpoint.as_ref().map(|_| health_tracker.clone());
+        let chain_health = health::ChainHealth::new(store.clone(), stats.clone(), http_tracker, settings.ready_max_age_secs);
+        health::register(chain_health, running.clone());
+    }
+
+    // Heartbeat für den C-Server (status.json im Output-Verzeichnis)
+    if settings.status_interval_secs > 0 {
+        let http_tracker = http_endpoint.as_ref().map(|_| health_tracker.clone());
+        let writer = status::StatusWriter::new(store.clone(), stats.clone(), http_tracker, settings.ready_max_age_secs);
+        tokio::spawn(status::run_status_writer(writer, settings.status_interval_secs, running.clone()));
+    }
+
+    // Metriken per Push an einen OTLP-Collector oder StatsD (einmal pro Prozess für alle Chains)
+    if let Some(ref metrics_settings) = settings.metrics {
+        metrics::register(metrics_settings, chain_id, stats.clone(), store.clone(), running.clone());
+    }
+
+    // Alarm-Webhooks für Stall, Signaturfehler, Speicherplatz und Equivocations (mit Cool-down je Alarm)
+    if let Some(ref alert_settings) = settings.alerts {
+        let (alert_store, alert_runtime) = (store.clone(), runtime.clone());
+        tokio::spawn(alerts::run_alerts(alert_settings.clone(), chain_id, alert_store, alert_runtime, running.clone()));
+    }
+
+    // Debug-Listener (nur localhost) für Laufzeit-Metriken, Thread-Dump und CPU-Profile
+    if let Some(debug_addr) = settings.debug_addr {
+        debug::register(debug_addr, chain_id, stats.clone(), store.clone(), running.clone());
+    }
     
+    // Liveness der Capture-Schleife für den systemd-Watchdog (siehe systemd.rs)
+    let stall_secs = settings.watchdog_stall_secs.max(3 * http_poll_interval);
+    systemd::register(chain_id, stall_secs, running.clone());
+
     // Try HTTP-first approach
-    if let Some(http_endpoint) = chain_config.get_http_endpoint() {
+    let result = if let Some(http_endpoint) = http_endpoint {
         info!("🌐 Starting in HTTP-primary mode: {}", http_endpoint);
         
         // Start HTTP primary with fallback to gossip
         run_http_primary_with_gossip_fallback(
-            http_endpoint,
+            runtime,
             chain_id,
             disc_port,
             gossip_port,
             output_dir,
-            http_poll_interval,
             &chain_config,
             expected_sequencer,
             health_tracker,
@@ -292,7 +684,9 @@ async fn run_http_first_network(
             running,
             Arc::new(Mutex::new(BlockDeduplicator::new())), // Shared Deduplicator
             Arc::new(Mutex::new(BlockBitmaskTracker::new())), // Shared Bitmask Tracker
-        ).await?;
+            store.clone(),
+            settings.dns_cache_secs,
+        ).await
     } else {
         info!("🌐 No HTTP endpoint - starting directly in gossip mode");
         // No HTTP endpoint available, start gossip directly
@@ -307,10 +701,19 @@ async fn run_http_first_network(
             running,
             None, // Kein Deduplicator im reinen Gossip-Modus
             None, // Kein Bitmask-Tracker im reinen Gossip-Modus
-        ).await?;
+            store,
+        ).await
+    };
+
+    // Lease freigeben, damit der Standby sofort übernimmt; verloren heißt: die Chain wurde deshalb gestoppt
+    if let Some(lease) = lease {
+        let mut lease = lease.lock().await;
+        lease.release().await;
+        if lease.is_lost() {
+            return Err(format!("HA lease for chain {} lost, stopped writing", chain_id).into());
+        }
     }
-    
-    Ok(())
+    result
 }
 
 /// Stoppt die Kona-Bridge
@@ -398,34 +801,148 @@ pub extern "C" fn kona_bridge_get_stats(
     }
 }
 
-/// Hilfsfunktion für Logging-Setup von C aus (CPU-optimized)
+/// Gibt Speicher-Statistiken (Belegung, Blöcke, Löschungen) des Preconf-Stores zurück
 #[no_mangle]
-pub extern "C" fn kona_bridge_init_logging() {
-    use tracing_subscriber::{EnvFilter, fmt::format::FmtSpan};
-    use std::sync::Once;
-    
-    static INIT: Once = Once::new();
-    
-    INIT.call_once(|| {
-        eprintln!("🦀 [RUST] Initializing Rust tracing subscriber...");
-        
-        let filter = EnvFilter::try_from_default_env()
-            .unwrap_or_else(|_| EnvFilter::new(
-                "warn,libp2p=off,discv5=off,kona_p2p=off,kona_bridge=info,tokio=warn,hyper=warn,reqwest=warn"
-            ));
-            
-        let result = tracing_subscriber::fmt()
-            .with_env_filter(filter)
-            .with_target(false)  // Reduziert String-Allocation
-            .with_span_events(FmtSpan::NONE)  // Weniger Spam
-            .with_line_number(false)  // Weniger Overhead
-            .compact()  // Kompaktere Logs
-            .with_writer(std::io::stderr)  // Explizit stderr verwenden
-            .try_init();
-            
-        match result {
-            Ok(_) => eprintln!("🦀 [RUST] Tracing subscriber initialized successfully"),
-            Err(e) => eprintln!("🦀 [RUST] Tracing subscriber already initialized: {}", e),
+pub extern "C" fn kona_bridge_get_storage_stats(
+    handle: *const KonaBridgeHandle,
+    stats: *mut KonaBridgeStorageStats,
+) -> c_int {
+    if handle.is_null() || stats.is_null() {
+        return -1;
+    }
+
+    let handle = unsafe { &*handle };
+    let snapshot = handle.storage_stats.snapshot();
+
+    unsafe {
+        (*stats).entries = snapshot.entries;
+        (*stats).total_bytes = snapshot.total_bytes;
+        (*stats).oldest_block = snapshot.oldest_block;
+        (*stats).newest_block = snapshot.newest_block;
+        (*stats).oldest_age_secs = snapshot.oldest_age_secs;
+        (*stats).newest_age_secs = snapshot.newest_age_secs;
+        (*stats).stored_http = snapshot.stored_http;
+        (*stats).stored_gossip = snapshot.stored_gossip;
+        (*stats).stored_other = snapshot.stored_other;
+        (*stats).evicted_ttl = snapshot.evicted_ttl;
+        (*stats).evicted_quota = snapshot.evicted_quota;
+        (*stats).evicted_finality = snapshot.evicted_finality;
+        (*stats).invalid_signatures = snapshot.invalid_signatures;
+        (*stats).equivocations = snapshot.equivocations;
+        (*stats).oversized_payloads = snapshot.oversized_payloads;
+        (*stats).gossip_dropped = snapshot.gossip_dropped;
+        (*stats).sampled_out = snapshot.sampled_out;
+        (*stats).reorgs = snapshot.reorgs;
+        (*stats).gaps = snapshot.gaps;
+        (*stats).backwards = snapshot.backwards;
+        (*stats).dns_failures = snapshot.dns_failures;
+        (*stats).canonical_matched = snapshot.canonical_matched;
+        (*stats).canonical_mismatched = snapshot.canonical_mismatched;
+        (*stats).canonical_tx_mismatched = snapshot.canonical_tx_mismatched;
+    }
+    0
+}
+
+/// Gibt die Histogramme der Ankunftsabstände und Payload-Größen zurück (ohne Verzeichnis-Scan)
+#[no_mangle]
+pub extern "C" fn kona_bridge_get_histograms(
+    handle: *const KonaBridgeHandle,
+    histograms: *mut KonaBridgeHistograms,
+) -> c_int {
+    if handle.is_null() || histograms.is_null() {
+        return -1;
+    }
+
+    let handle = unsafe { &*handle };
+    let counters = handle.storage_stats.counters();
+    let (interval, size) = (counters.arrival_interval_ms, counters.payload_size_bytes);
+
+    unsafe {
+        (*histograms).interval_bounds_ms.copy_from_slice(&interval.bounds);
+        (*histograms).interval_buckets.copy_from_slice(&interval.buckets);
+        (*histograms).interval_count = interval.count;
+        (*histograms).interval_sum_ms = interval.sum;
+        (*histograms).size_bounds_bytes.copy_from_slice(&size.bounds);
+        (*histograms).size_buckets.copy_from_slice(&size.buckets);
+        (*histograms).size_count = size.count;
+        (*histograms).size_sum_bytes = size.sum;
+    }
+    0
+}
+
+/// Konvertiert einen C-String in einen PathBuf (None bei NULL oder ungültigem UTF-8)
+unsafe fn path_from_c(ptr: *const c_char) -> Option<PathBuf> {
+    if ptr.is_null() {
+        return None;
+    }
+    CStr::from_ptr(ptr).to_str().ok().map(PathBuf::from)
+}
+
+/// Exportiert die Preconfs im Bereich [from_block, to_block] als tar.zst-Archiv
+/// Gibt die Anzahl exportierter Blöcke zurück oder -1 bei Fehler
+#[no_mangle]
+pub extern "C" fn kona_bridge_export(
+    output_dir: *const c_char,
+    chain_id: u64,
+    from_block: u64,
+    to_block: u64,
+    dest: *const c_char,
+) -> c_int {
+    let (output_dir, dest) = match unsafe { (path_from_c(output_dir), path_from_c(dest)) } {
+        (Some(o), Some(d)) => (o, d),
+        _ => {
+            error!("❌ Invalid paths provided to kona_bridge_export");
+            return -1;
         }
-    });
-}
\ No newline at end of file
+    };
+
+    let output_dir = BridgeSettings::from_env(chain_id).chain_dir(&output_dir, chain_id);
+    match archive::export_archive(&output_dir, chain_id, from_block, to_block, &dest) {
+        Ok(manifest) => manifest.entries.len() as c_int,
+        Err(e) => {
+            error!("❌ Export failed: {}", e);
+            -1
+        }
+    }
+}
+
+/// Importiert ein tar.zst-Archiv in das Verzeichnis seiner Chain (chain_id = 0 akzeptiert jede Chain, sonst muss sie
+/// zum Manifest passen)
+/// Gibt die Anzahl neu importierter Blöcke zurück oder -1 bei Fehler
+#[no_mangle]
+pub extern "C" fn kona_bridge_import(
+    output_dir: *const c_char,
+    chain_id: u64,
+    src: *const c_char,
+) -> c_int {
+    let (output_dir, src) = match unsafe { (path_from_c(output_dir), path_from_c(src)) } {
+        (Some(o), Some(s)) => (o, s),
+        _ => {
+            error!("❌ Invalid paths provided to kona_bridge_import");
+            return -1;
+        }
+    };
+
+    // In das Verzeichnis der Chain aus dem Manifest importieren (wie kona_bridge_export mit KONA_BRIDGE_CHAIN_SUBDIRS)
+    let chain_id = match archive::read_manifest(&src, chain_id) {
+        Ok(manifest) => manifest.chain_id,
+        Err(e) => {
+            error!("❌ Import failed: {}", e);
+            return -1;
+        }
+    };
+    let output_dir = BridgeSettings::from_env(chain_id).chain_dir(&output_dir, chain_id);
+    match archive::import_archive(&output_dir, &src, chain_id) {
+        Ok(summary) => summary.imported as c_int,
+        Err(e) => {
+            error!("❌ Import failed: {}", e);
+            -1
+        }
+    }
+}
+
+/// Hilfsfunktion für Logging-Setup von C aus (Format und Level siehe logging.rs)
+#[no_mangle]
+pub extern "C" fn kona_bridge_init_logging() {
+    logging::init();
+}
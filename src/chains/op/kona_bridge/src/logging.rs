@@ -0,0 +1,145 @@
+// logging.rs - Tracing-Subscriber der Bridge: Level, Ausgabeformat und Subsysteme (kona_bridge_init_logging)
+//
+//   KONA_BRIDGE_LOG_FORMAT   text (Default, kompakt wie bisher) | json (eine JSON-Zeile je Ereignis auf stderr)
+//   KONA_BRIDGE_LOG_LEVEL    EnvFilter-Direktiven (vor RUST_LOG), auf SIGHUP neu geladen (siehe reload.rs)
+//
+// Subsysteme lassen sich in den Direktiven mit Kurznamen einstellen, z.B. "info,gossip=debug,storage=warn":
+//   gossip    gossip.rs                       http      http.rs, chaincheck.rs
+//   storage   storage.rs, Index, Journal, Durability, Tiering, Retention, latest-Zeiger, Cleanup
+//   verify    Signer, Signer-Historie, Plausibilitätsprüfungen, Equivocation, Hardforks, Block-Hash
+// Im JSON-Format steht das Modul im Feld "target" (z.B. "kona_bridge::gossip") und der Kontext in "span": die Chain
+// als {"name": "chain", "chain_id": ...}, beim Speichern eines Preconfs {"name": "preconf", "chain_id", "block",
+// "source"}. Die Nachrichten selbst bleiben unverändert.
+
+use crate::{reload, settings};
+use std::sync::{
+    atomic::{AtomicBool, Ordering},
+    Once,
+};
+use tracing::{warn, Span};
+use tracing_subscriber::{fmt::format::FmtSpan, prelude::*, EnvFilter};
+
+/// Log-Filter ohne RUST_LOG
+const DEFAULT_LOG_FILTER: &str =
+    "warn,libp2p=off,discv5=off,kona_p2p=off,kona_bridge=info,tokio=warn,hyper=warn,reqwest=warn";
+
+/// Kurznamen der Subsysteme und ihre Module
+const SUBSYSTEMS: [(&str, &[&str]); 4] = [
+    ("gossip", &["gossip"]),
+    ("http", &["http", "chaincheck"]),
+    ("storage", &["storage", "index", "txindex", "journal", "durability", "tiering", "retention", "pointer", "utils"]),
+    ("verify", &["signer", "signerhistory", "validation", "equivocation", "hardfork", "blockhash"]),
+];
+
+/// JSON-Ausgabe aktiv? (dann tragen Chain- und Preconf-Spans die Felder)
+static JSON: AtomicBool = AtomicBool::new(false);
+
+pub fn json() -> bool {
+    JSON.load(Ordering::Relaxed)
+}
+
+/// Span mit chain_id: im JSON-Format immer, im Textformat nur mit mehreren Chains (sonst nur Präfix-Rauschen)
+pub fn chain_span(chain_id: u64, multi: bool) -> Span {
+    if multi || json() {
+        tracing::info_span!("chain", chain_id)
+    } else {
+        Span::none()
+    }
+}
+
+/// Span eines zu speichernden Preconfs, nur im JSON-Format (im Text steht der Block bereits in der Nachricht)
+pub fn preconf_span(chain_id: u64, block: u64, source: &str) -> Span {
+    if json() {
+        tracing::info_span!("preconf", chain_id, block, source)
+    } else {
+        Span::none()
+    }
+}
+
+/// Ersetzt Kurznamen der Subsysteme durch die Module ("gossip=debug" -> "kona_bridge::gossip=debug")
+pub fn expand_directives(directives: &str) -> String {
+    let mut expanded = Vec::new();
+    for directive in directives.split(',').map(str::trim).filter(|d| !d.is_empty()) {
+        let (target, level) = match directive.split_once('=') {
+            Some((target, level)) => (target, Some(level)),
+            None => (directive, None),
+        };
+        match SUBSYSTEMS.iter().find(|(name, _)| *name == target) {
+            Some((_, modules)) => expanded.extend(modules.iter().map(|module| match level {
+                Some(level) => format!("kona_bridge::{}={}", module, level),
+                None => format!("kona_bridge::{}", module),
+            })),
+            None => expanded.push(directive.to_string()),
+        }
+    }
+    expanded.join(",")
+}
+
+/// Richtet den Subscriber einmal pro Prozess ein (stderr, Filter auf SIGHUP neu ladbar)
+pub fn init() {
+    static INIT: Once = Once::new();
+
+    INIT.call_once(|| {
+        let json_format = match settings::env_string("KONA_BRIDGE_LOG_FORMAT").as_deref().map(str::trim) {
+            Some("json") => true,
+            Some("text") | None => false,
+            Some(other) => {
+                eprintln!("⚠️  Unknown KONA_BRIDGE_LOG_FORMAT {:?}, using text", other);
+                false
+            }
+        };
+        JSON.store(json_format, Ordering::Relaxed);
+        if !json_format {
+            eprintln!("🦀 [RUST] Initializing Rust tracing subscriber...");
+        }
+
+        // Filter beim Start (RUST_LOG), KONA_BRIDGE_LOG_LEVEL ersetzt ihn und wird auf SIGHUP neu geladen
+        let startup = std::env::var("RUST_LOG")
+            .ok()
+            .map(|directives| expand_directives(&directives))
+            .filter(|directives| EnvFilter::try_new(directives).is_ok())
+            .unwrap_or_else(|| DEFAULT_LOG_FILTER.to_string());
+        let (filter, handle) = tracing_subscriber::reload::Layer::new(EnvFilter::new(&startup));
+
+        let output = if json_format {
+            tracing_subscriber::fmt::layer()
+                .json()
+                .flatten_event(true)
+                .with_current_span(true)
+                .with_span_list(false)
+                .with_target(true)
+                .with_ansi(false)
+                .with_writer(std::io::stderr)
+                .boxed()
+        } else {
+            tracing_subscriber::fmt::layer()
+                .with_target(false) // Reduziert String-Allocation
+                .with_span_events(FmtSpan::NONE) // Weniger Spam
+                .with_line_number(false) // Weniger Overhead
+                .compact() // Kompaktere Logs
+                .with_writer(std::io::stderr) // Explizit stderr verwenden
+                .boxed()
+        };
+
+        match tracing_subscriber::registry().with(filter).with(output).try_init() {
+            Ok(_) => {
+                if !json_format {
+                    eprintln!("🦀 [RUST] Tracing subscriber initialized successfully");
+                }
+                let set_filter = move |directives: Option<&str>| -> Result<(), String> {
+                    let directives = directives.map_or_else(|| startup.clone(), expand_directives);
+                    let filter = EnvFilter::try_new(directives).map_err(|e| e.to_string())?;
+                    handle.reload(filter).map_err(|e| e.to_string())
+                };
+                // Erst nach dem Init nachschlagen, damit Meldungen der Konfigurationsdatei im Log landen
+                if let Some(directives) = settings::env_string("KONA_BRIDGE_LOG_LEVEL") {
+                    if let Err(e) = set_filter(Some(&directives)) {
+                        warn!("⚠️  Ignoring invalid value for KONA_BRIDGE_LOG_LEVEL: {} ({})", directives, e);
+                    }
+                }
+                reload::register_log_filter(Box::new(set_filter));
+            }
+            Err(e) => eprintln!("🦀 [RUST] Tracing subscriber already initialized: {}", e),
+        }
+    });
+}
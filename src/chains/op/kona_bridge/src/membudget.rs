@@ -0,0 +1,127 @@
+// membudget.rs - Speicherbudget der Bridge (KONA_BRIDGE_MAX_MEMORY bzw. --max-memory, z.B. 512M oder 2G)
+//
+// Ohne Garbage Collector gibt es kein Gegenstück zu GOMEMLIMIT; das Budget wirkt auf zwei Wegen:
+// - beim Start bemisst profile.rs daraus Gossip-Queue, Block- und Transaktions-Index, Signer-Cache, Deduplikator,
+//   Payload-Cache der Delta-Speicherung und Hot-Tier (unter 256 MiB ohne Hot-Tier); explizit gesetzte Variablen
+//   haben Vorrang
+// - zur Laufzeit prüft run_memory_guard den RSS des Prozesses: über 90% des Budgets gilt er als unter Druck, Hot-Tier
+//   und Payload-Cache halten dann nur noch das Nötigste, bis der RSS wieder unter 80% liegt
+
+use crate::{profile::Profile, settings::env_string};
+use std::{
+    sync::{
+        atomic::{AtomicBool, Ordering},
+        Arc, Mutex,
+    },
+    time::Duration,
+};
+use tracing::{info, warn};
+
+const MIB: u64 = 1024 * 1024;
+
+/// Grobe Größen je Eintrag für die Bemessung
+const QUEUE_ENTRY_BYTES: u64 = 256 * 1024; // Payload in der Gossip-Queue
+const INDEX_ENTRY_BYTES: u64 = 4 * 1024; // Block- und Transaktions-Index je Block
+const SIGNER_ENTRY_BYTES: u64 = 128; // Hash -> Signer
+const HOT_ENTRY_BYTES: u64 = 128 * 1024; // komprimierter Block im Hot-Tier
+
+/// Darunter kein Hot-Tier (die Dateien liefert dann der Query-Socket direkt)
+const MIN_HOT_TIER_BUDGET: u64 = 256 * MIB;
+
+/// Prüfintervall und Schwellen des Guards (Anteil des Budgets in Prozent)
+const GUARD_INTERVAL: Duration = Duration::from_secs(10);
+const PRESSURE_ENTER_PERCENT: u64 = 90;
+const PRESSURE_LEAVE_PERCENT: u64 = 80;
+
+static PRESSURE: AtomicBool = AtomicBool::new(false);
+static GUARD_RUNNING: AtomicBool = AtomicBool::new(false);
+
+/// Parst "512" (MiB), "512M", "512MiB", "2G", "2GB" oder "65536K" in Bytes
+pub fn parse_size(value: &str) -> Option<u64> {
+    let value = value.trim().to_ascii_uppercase();
+    let value = value.trim_end_matches('B').trim_end_matches('I');
+    let (number, factor) = match value.chars().last()? {
+        'K' => (&value[..value.len() - 1], 1024),
+        'M' => (&value[..value.len() - 1], MIB),
+        'G' => (&value[..value.len() - 1], 1024 * MIB),
+        _ => (value, MIB),
+    };
+    number.trim().parse::<u64>().ok()?.checked_mul(factor).filter(|bytes| *bytes > 0)
+}
+
+/// Budget aus KONA_BRIDGE_MAX_MEMORY (None = unbegrenzt)
+pub fn from_env() -> Option<u64> {
+    let value = env_string("KONA_BRIDGE_MAX_MEMORY")?;
+    let budget = parse_size(&value);
+    if budget.is_none() {
+        warn!("⚠️  Ignoring invalid value for KONA_BRIDGE_MAX_MEMORY: {}", value);
+    }
+    budget
+}
+
+/// Bemisst die Caches und Queues des Profils nach dem Budget
+pub fn apply(profile: &mut Profile, budget: u64) {
+    let share = |divisor: u64, entry: u64, min: u64, max: u64| (budget / divisor / entry).clamp(min, max) as usize;
+    profile.memory_budget = Some(budget);
+    profile.gossip_queue = share(8, QUEUE_ENTRY_BYTES, 4, 1024);
+    profile.index_capacity = share(16, INDEX_ENTRY_BYTES, 256, 65_536);
+    profile.signer_cache = share(64, SIGNER_ENTRY_BYTES, 256, 65_536);
+    profile.dedup_blocks = (budget / MIB).clamp(32, 200) as usize;
+    profile.payload_cache_bytes = (budget / 16).clamp(MIB, 256 * MIB) as usize;
+    profile.hot_blocks_max = match budget >= MIN_HOT_TIER_BUDGET {
+        true => share(8, HOT_ENTRY_BYTES, 2, 65_536),
+        false => 0,
+    };
+    info!(
+        "🧮 Memory budget {} MiB: gossip queue {}, index of {} blocks, signer cache {}, hot tier up to {} blocks",
+        budget / MIB,
+        profile.gossip_queue,
+        profile.index_capacity,
+        profile.signer_cache,
+        profile.hot_blocks_max
+    );
+}
+
+/// RSS über 90% des Budgets (Hot-Tier und Payload-Cache halten dann nur das Nötigste)
+pub fn under_pressure() -> bool {
+    PRESSURE.load(Ordering::Relaxed)
+}
+
+/// Resident Set Size des Prozesses aus /proc/self/statm
+fn rss_bytes() -> Option<u64> {
+    let statm = std::fs::read_to_string("/proc/self/statm").ok()?;
+    let pages: u64 = statm.split_whitespace().nth(1)?.parse().ok()?;
+    let page_size = unsafe { libc::sysconf(libc::_SC_PAGESIZE) };
+    (page_size > 0).then(|| pages * page_size as u64)
+}
+
+/// Überwacht den RSS gegen das Budget; läuft einmal je Prozess (weitere Chains starten keinen zweiten Guard)
+pub async fn run_memory_guard(budget: u64, running: Arc<Mutex<bool>>) {
+    if GUARD_RUNNING.swap(true, Ordering::AcqRel) {
+        return;
+    }
+    let mut timer = tokio::time::interval(GUARD_INTERVAL);
+    while *running.lock().unwrap() {
+        timer.tick().await;
+        let Some(rss) = rss_bytes() else {
+            warn!("⚠️  Cannot read RSS, memory guard stopped");
+            break;
+        };
+        let percent = rss * 100 / budget;
+        let pressure = PRESSURE.load(Ordering::Relaxed);
+        if !pressure && percent >= PRESSURE_ENTER_PERCENT {
+            warn!(
+                "🧮 Memory at {} of {} MiB ({}%), shrinking hot tier and payload cache",
+                rss / MIB,
+                budget / MIB,
+                percent
+            );
+            PRESSURE.store(true, Ordering::Relaxed);
+        } else if pressure && percent < PRESSURE_LEAVE_PERCENT {
+            info!("🧮 Memory back at {} of {} MiB ({}%)", rss / MIB, budget / MIB, percent);
+            PRESSURE.store(false, Ordering::Relaxed);
+        }
+    }
+    PRESSURE.store(false, Ordering::Relaxed);
+    GUARD_RUNNING.store(false, Ordering::Release);
+}
@@ -0,0 +1,98 @@
+// metawriter.rs - Metadaten-Dateien (block_{chain}_{n}.json) abseits des Capture-Pfads schreiben
+//
+// Der Store legt die Metadaten nach dem dauerhaft geschriebenen .raw nur noch hier ab; Serialisierung und Schreiben
+// übernimmt run_metadata_writer bzw. mit KONA_BRIDGE_GROUP_COMMIT der Commit-Zyklus. Die Capture-Latenz enthält
+// damit nur den Payload-Write. Bis die Datei geschrieben ist, liefert get() die Metadaten für Lesezugriffe.
+
+use crate::durability::DurableFs;
+use std::{
+    collections::HashMap,
+    path::{Path, PathBuf},
+    sync::{Arc, Mutex},
+    time::Duration,
+};
+use tokio::sync::Notify;
+use tracing::{debug, warn};
+
+/// Spätestens nach dieser Zeit schreibt der Hintergrund-Task auch ohne Benachrichtigung
+const WRITE_INTERVAL: Duration = Duration::from_secs(1);
+
+/// Wartende Metadaten je Zieldatei; die Generation verhindert, dass ein neuerer Stand derselben Datei (gleiche Höhe
+/// erneut gespeichert) nach dem Schreiben des älteren verworfen wird
+pub struct MetadataWriter {
+    fs: Arc<DurableFs>,
+    pending: Mutex<HashMap<PathBuf, (u64, serde_json::Value)>>,
+    generation: Mutex<u64>,
+    queued: Notify,
+}
+
+impl MetadataWriter {
+    pub fn new(fs: Arc<DurableFs>) -> Self {
+        Self {
+            fs,
+            pending: Mutex::new(HashMap::new()),
+            generation: Mutex::new(0),
+            queued: Notify::new(),
+        }
+    }
+
+    /// Merkt die Metadaten zum Schreiben vor (ersetzt einen noch nicht geschriebenen Stand derselben Datei)
+    pub fn enqueue(&self, path: PathBuf, metadata: serde_json::Value) {
+        let generation = {
+            let mut generation = self.generation.lock().unwrap();
+            *generation += 1;
+            *generation
+        };
+        self.pending.lock().unwrap().insert(path, (generation, metadata));
+        self.queued.notify_one();
+    }
+
+    /// Noch nicht geschriebene Metadaten einer Datei
+    pub fn get(&self, path: &Path) -> Option<serde_json::Value> {
+        self.pending.lock().unwrap().get(path).map(|(_, metadata)| metadata.clone())
+    }
+
+    /// Serialisiert und schreibt alle wartenden Metadaten (mit Group-Commit nur in den Puffer von DurableFs) und gibt
+    /// ihre Anzahl zurück; Fehler einzelner Dateien werden geloggt
+    pub async fn write_pending(&self) -> usize {
+        let batch: Vec<(PathBuf, u64, serde_json::Value)> = self
+            .pending
+            .lock()
+            .unwrap()
+            .iter()
+            .map(|(path, (generation, metadata))| (path.clone(), *generation, metadata.clone()))
+            .collect();
+
+        for (path, generation, metadata) in &batch {
+            match serde_json::to_vec_pretty(metadata) {
+                Ok(data) => {
+                    let temp = path.with_extension("json.tmp");
+                    if let Err(e) = self.fs.write_deferred(&temp, path, data).await {
+                        warn!("⚠️  Failed to write metadata {:?}: {}", path, e);
+                    }
+                }
+                Err(e) => warn!("⚠️  Failed to serialize metadata {:?}: {}", path, e),
+            }
+
+            // Erst nach dem Schreiben entfernen, damit Leser die Metadaten durchgehend finden
+            let mut pending = self.pending.lock().unwrap();
+            if pending.get(path).map_or(false, |(current, _)| current == generation) {
+                pending.remove(path);
+            }
+        }
+        batch.len()
+    }
+}
+
+/// Schreibt vorgemerkte Metadaten, sobald welche anliegen (ohne Group-Commit), und beim Beenden den Rest
+pub async fn run_metadata_writer(writer: Arc<MetadataWriter>, running: Arc<Mutex<bool>>) {
+    while *running.lock().unwrap() {
+        let _ = tokio::time::timeout(WRITE_INTERVAL, writer.queued.notified()).await;
+        let files = writer.write_pending().await;
+        if files > 1 {
+            debug!("📝 Wrote {} metadata files", files);
+        }
+    }
+
+    writer.write_pending().await;
+}
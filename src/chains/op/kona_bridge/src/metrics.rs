@@ -0,0 +1,376 @@
+// metrics.rs - Push-Export der Metriken an einen OTLP-Collector oder StatsD (für Hosts, die niemand scrapen kann)
+//
+//   KONA_BRIDGE_METRICS_PUSH=http://collector:4318          OTLP/HTTP mit JSON-Encoding (POST .../v1/metrics)
+//   KONA_BRIDGE_METRICS_PUSH=statsd://127.0.0.1:8125        StatsD über UDP, Labels als DogStatsD-Tags (|#k:v)
+//   KONA_BRIDGE_METRICS_INTERVAL_SECS=10                    Push-Intervall
+//   KONA_BRIDGE_METRICS_LABELS=env=prod,region=eu           zusätzliche Labels an jeder Metrik
+//
+// Gemeldet werden je Chain (Label chain_id) die Zähler von kona_bridge_get_stats und kona_bridge_get_storage_stats
+// unter "kona_bridge.<name>": Zähler seit Start (OTLP: kumulative monotone Summe, StatsD: Differenz seit dem
+// letzten Push als |c) und Momentwerte (Peers, Modus, Belegung, ältester/neuester Block als Gauge bzw. |g), dazu
+// der Capture-Lag (chain_head, capture_lag_blocks, capture_lag_secs, siehe lag.rs), sobald ein Preconf gespeichert ist,
+// und die Preconf-Accuracy (preconf_accuracy_bps, siehe accuracy.rs), sobald ein Block verglichen wurde.
+// Wie der Debug-Listener läuft der Export einmal pro Prozess in einem eigenen Thread; die Chains registrieren sich
+// beim Start und fallen heraus, sobald sie stoppen. Fehler beim Senden werden nur geloggt.
+
+use crate::{settings::MetricsSettings, storage::PreconfStore, types::KonaBridgeStats};
+use std::{
+    collections::HashMap,
+    sync::{Arc, Mutex, OnceLock, RwLock},
+    time::{Duration, SystemTime, UNIX_EPOCH},
+};
+use tokio::{net::UdpSocket, time::interval};
+use tracing::{debug, error, info, warn};
+
+pub const DEFAULT_METRICS_INTERVAL_SECS: u64 = 10;
+/// Name der Metriken vor dem Feldnamen
+const METRIC_PREFIX: &str = "kona_bridge";
+/// Maximale Größe eines StatsD-Datagramms (passt ohne Fragmentierung in übliche MTUs)
+const STATSD_MAX_DATAGRAM: usize = 1432;
+
+/// Ziel des Exports
+#[derive(Debug, Clone, PartialEq)]
+pub enum MetricsTarget {
+    Otlp { url: String },    // vollständige URL inkl. /v1/metrics
+    Statsd { addr: String }, // host:port
+}
+
+impl MetricsTarget {
+    /// http(s)://host:port[/pfad] (ohne Pfad: /v1/metrics) bzw. statsd://host[:port] (Default-Port 8125)
+    pub fn parse(value: &str) -> Option<Self> {
+        let value = value.trim().trim_end_matches('/');
+        if let Some(addr) = value.strip_prefix("statsd://") {
+            if addr.is_empty() {
+                return None;
+            }
+            let addr = if addr.contains(':') { addr.to_string() } else { format!("{}:8125", addr) };
+            return Some(MetricsTarget::Statsd { addr });
+        }
+        let rest = value.strip_prefix("http://").or_else(|| value.strip_prefix("https://"))?;
+        match rest.split_once('/') {
+            Some((host, _)) if !host.is_empty() => Some(MetricsTarget::Otlp { url: value.to_string() }),
+            None if !rest.is_empty() => Some(MetricsTarget::Otlp { url: format!("{}/v1/metrics", value) }),
+            _ => None,
+        }
+    }
+}
+
+impl std::fmt::Display for MetricsTarget {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        match self {
+            MetricsTarget::Otlp { url } => write!(f, "otlp {}", url),
+            MetricsTarget::Statsd { addr } => write!(f, "statsd {}", addr),
+        }
+    }
+}
+
+/// Art einer Metrik
+#[derive(Debug, Clone, Copy, PartialEq)]
+enum Kind {
+    Counter, // seit Start, monoton
+    Gauge,   // Momentwert
+}
+
+/// Zustand einer Chain für den Export
+struct MetricsChain {
+    stats: Arc<Mutex<KonaBridgeStats>>,
+    store: Arc<PreconfStore>,
+}
+
+/// Registrierte Chains nach Chain-ID
+static CHAINS: OnceLock<RwLock<HashMap<u64, Arc<MetricsChain>>>> = OnceLock::new();
+/// Ziel des laufenden Exports (einmal pro Prozess gestartet)
+static EXPORTER: OnceLock<MetricsTarget> = OnceLock::new();
+
+fn chains() -> &'static RwLock<HashMap<u64, Arc<MetricsChain>>> {
+    CHAINS.get_or_init(|| RwLock::new(HashMap::new()))
+}
+
+fn unix_nanos() -> u128 {
+    SystemTime::now().duration_since(UNIX_EPOCH).map_or(0, |d| d.as_nanos())
+}
+
+/// Nimmt die Chain in den Export auf, startet ihn beim ersten Aufruf und entfernt die Chain wieder,
+/// sobald `running` false wird
+pub fn register(
+    settings: &MetricsSettings,
+    chain_id: u64,
+    stats: Arc<Mutex<KonaBridgeStats>>,
+    store: Arc<PreconfStore>,
+    running: Arc<Mutex<bool>>,
+) {
+    if let Ok(mut chains) = chains().write() {
+        chains.insert(chain_id, Arc::new(MetricsChain { stats, store }));
+    }
+
+    let exporting = EXPORTER.get_or_init(|| {
+        start_exporter(settings.clone());
+        settings.target.clone()
+    });
+    if *exporting != settings.target {
+        warn!("⚠️  Metrics already pushed to {}, chain {} is included there", exporting, chain_id);
+    }
+
+    tokio::spawn(async move {
+        while *running.lock().unwrap() {
+            tokio::time::sleep(Duration::from_secs(1)).await;
+        }
+        if let Ok(mut chains) = chains().write() {
+            chains.remove(&chain_id);
+        }
+    });
+}
+
+/// Aktuelle Werte aller Chains als (Chain-ID, Name, Art, Wert), nach Chain sortiert
+fn collect() -> Vec<(u64, &'static str, Kind, u64)> {
+    let mut registered: Vec<(u64, Arc<MetricsChain>)> = match chains().read() {
+        Ok(chains) => chains.iter().map(|(chain_id, chain)| (*chain_id, chain.clone())).collect(),
+        Err(_) => Vec::new(),
+    };
+    registered.sort_unstable_by_key(|(chain_id, _)| *chain_id);
+
+    let mut values = Vec::new();
+    for (chain_id, chain) in registered {
+        let bridge: Vec<(&'static str, Kind, u64)> = {
+            let stats = chain.stats.lock().unwrap();
+            vec![
+                ("connected_peers", Kind::Gauge, stats.connected_peers as u64),
+                ("current_mode", Kind::Gauge, stats.current_mode as u64),
+                ("received_preconfs", Kind::Counter, stats.received_preconfs as u64),
+                ("processed_preconfs", Kind::Counter, stats.processed_preconfs as u64),
+                ("failed_preconfs", Kind::Counter, stats.failed_preconfs as u64),
+                ("http_received", Kind::Counter, stats.http_received as u64),
+                ("http_processed", Kind::Counter, stats.http_processed as u64),
+                ("gossip_received", Kind::Counter, stats.gossip_received as u64),
+                ("gossip_processed", Kind::Counter, stats.gossip_processed as u64),
+                ("mode_switches", Kind::Counter, stats.mode_switches as u64),
+                ("total_gaps", Kind::Counter, stats.total_gaps as u64),
+                ("http_gaps", Kind::Counter, stats.http_gaps as u64),
+                ("gossip_gaps", Kind::Counter, stats.gossip_gaps as u64),
+            ]
+        };
+        let storage = chain.store.storage_stats().snapshot();
+        let stored = [
+            ("storage_entries", Kind::Gauge, storage.entries),
+            ("storage_bytes", Kind::Gauge, storage.total_bytes),
+            ("oldest_block", Kind::Gauge, storage.oldest_block),
+            ("newest_block", Kind::Gauge, storage.newest_block),
+            ("newest_age_secs", Kind::Gauge, storage.newest_age_secs),
+            ("stored_http", Kind::Counter, storage.stored_http),
+            ("stored_gossip", Kind::Counter, storage.stored_gossip),
+            ("stored_other", Kind::Counter, storage.stored_other),
+            ("evicted_ttl", Kind::Counter, storage.evicted_ttl),
+            ("evicted_quota", Kind::Counter, storage.evicted_quota),
+            ("evicted_finality", Kind::Counter, storage.evicted_finality),
+            ("invalid_signatures", Kind::Counter, storage.invalid_signatures),
+            ("equivocations", Kind::Counter, storage.equivocations),
+            ("oversized_payloads", Kind::Counter, storage.oversized_payloads),
+            ("gossip_dropped", Kind::Counter, storage.gossip_dropped),
+            ("sampled_out", Kind::Counter, storage.sampled_out),
+            ("reorgs", Kind::Counter, storage.reorgs),
+            ("gaps", Kind::Counter, storage.gaps),
+            ("backwards", Kind::Counter, storage.backwards),
+            ("dns_failures", Kind::Counter, storage.dns_failures),
+            ("canonical_matched", Kind::Counter, storage.canonical_matched),
+            ("canonical_mismatched", Kind::Counter, storage.canonical_mismatched),
+            ("canonical_tx_mismatched", Kind::Counter, storage.canonical_tx_mismatched),
+        ];
+        // Ohne gespeicherten Preconf gibt es keinen Lag (statt eines irreführenden 0)
+        let now = SystemTime::now().duration_since(UNIX_EPOCH).map_or(0, |d| d.as_secs());
+        let lag = chain.store.capture_lag(now).map(|lag| {
+            [
+                ("chain_head", Kind::Gauge, lag.head),
+                ("capture_lag_blocks", Kind::Gauge, lag.blocks),
+                ("capture_lag_secs", Kind::Gauge, lag.secs),
+            ]
+        });
+        // Preconf-Accuracy in Basispunkten (10000 = alle übereinstimmend), erst nach dem ersten Vergleich
+        let accuracy = storage
+            .preconf_accuracy()
+            .map(|accuracy| ("preconf_accuracy_bps", Kind::Gauge, (accuracy * 10_000.0).round() as u64));
+        values.extend(
+            bridge
+                .into_iter()
+                .chain(stored)
+                .chain(lag.into_iter().flatten())
+                .chain(accuracy)
+                .map(|(name, kind, value)| (chain_id, name, kind, value)),
+        );
+    }
+    values
+}
+
+fn start_exporter(settings: MetricsSettings) {
+    std::thread::spawn(move || {
+        let rt = match tokio::runtime::Builder::new_current_thread().thread_name("kona-metrics").enable_all().build() {
+            Ok(rt) => rt,
+            Err(e) => {
+                error!("❌ Failed to create runtime for the metrics export: {}", e);
+                return;
+            }
+        };
+        rt.block_on(run_exporter(settings));
+    });
+}
+
+async fn run_exporter(settings: MetricsSettings) {
+    info!("📈 Pushing metrics to {} every {}s", settings.target, settings.interval_secs);
+    let mut exporter = match Exporter::new(&settings).await {
+        Ok(exporter) => exporter,
+        Err(e) => {
+            warn!("⚠️  Metrics export disabled: {}", e);
+            return;
+        }
+    };
+    let mut ticker = interval(Duration::from_secs(settings.interval_secs.max(1)));
+    ticker.tick().await;
+    loop {
+        ticker.tick().await;
+        // Belegung kommt aus einem Verzeichnis-Scan, nicht im Runtime-Thread
+        let values = match tokio::task::spawn_blocking(collect).await {
+            Ok(values) => values,
+            Err(e) => {
+                debug!("Collecting metrics failed: {}", e);
+                continue;
+            }
+        };
+        if values.is_empty() {
+            continue;
+        }
+        if let Err(e) = exporter.push(&values).await {
+            warn!("⚠️  Metrics push to {} failed: {}", settings.target, e);
+        }
+    }
+}
+
+enum Exporter {
+    Otlp {
+        client: reqwest::Client,
+        url: String,
+        labels: Vec<(String, String)>,
+        start_nanos: u128,
+    },
+    Statsd {
+        socket: UdpSocket,
+        tags: String,                            // ",env:prod" (an chain_id angehängt)
+        last: HashMap<(u64, &'static str), u64>, // letzter Zählerstand für die Differenz
+    },
+}
+
+impl Exporter {
+    async fn new(settings: &MetricsSettings) -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        Ok(match settings.target {
+            MetricsTarget::Otlp { ref url } => Exporter::Otlp {
+                client: reqwest::Client::builder().timeout(Duration::from_secs(10)).build()?,
+                url: url.clone(),
+                labels: settings.labels.clone(),
+                start_nanos: unix_nanos(),
+            },
+            MetricsTarget::Statsd { ref addr } => {
+                let socket = UdpSocket::bind("0.0.0.0:0").await?;
+                socket.connect(addr).await.map_err(|e| format!("statsd {}: {}", addr, e))?;
+                let tags = settings.labels.iter().map(|(key, value)| format!(",{}:{}", key, value)).collect();
+                Exporter::Statsd { socket, tags, last: HashMap::new() }
+            }
+        })
+    }
+
+    async fn push(
+        &mut self,
+        values: &[(u64, &'static str, Kind, u64)],
+    ) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        match self {
+            Exporter::Otlp { client, url, labels, start_nanos } => {
+                let body = otlp_request(values, labels, *start_nanos, unix_nanos());
+                let response = client.post(url.as_str()).json(&body).send().await?;
+                if !response.status().is_success() {
+                    return Err(format!("HTTP {}", response.status()).into());
+                }
+                Ok(())
+            }
+            Exporter::Statsd { socket, tags, last } => {
+                let mut datagram = String::new();
+                for (chain_id, name, kind, value) in values {
+                    let line = match kind {
+                        Kind::Gauge => format!("{}.{}:{}|g|#chain_id:{}{}", METRIC_PREFIX, name, value, chain_id, tags),
+                        Kind::Counter => {
+                            // Differenz seit dem letzten Push; sinkt der Zähler (Chain neu gestartet), zählt er neu
+                            let previous = last.insert((*chain_id, *name), *value).unwrap_or(0);
+                            let delta = if *value >= previous { value - previous } else { *value };
+                            if delta == 0 {
+                                continue;
+                            }
+                            format!("{}.{}:{}|c|#chain_id:{}{}", METRIC_PREFIX, name, delta, chain_id, tags)
+                        }
+                    };
+                    if !datagram.is_empty() && datagram.len() + 1 + line.len() > STATSD_MAX_DATAGRAM {
+                        socket.send(datagram.as_bytes()).await?;
+                        datagram.clear();
+                    }
+                    if !datagram.is_empty() {
+                        datagram.push('\n');
+                    }
+                    datagram.push_str(&line);
+                }
+                if !datagram.is_empty() {
+                    socket.send(datagram.as_bytes()).await?;
+                }
+                Ok(())
+            }
+        }
+    }
+}
+
+fn otlp_attribute(key: &str, value: &str) -> serde_json::Value {
+    serde_json::json!({ "key": key, "value": { "stringValue": value } })
+}
+
+/// ExportMetricsServiceRequest im JSON-Encoding von OTLP/HTTP
+fn otlp_request(
+    values: &[(u64, &'static str, Kind, u64)],
+    labels: &[(String, String)],
+    start_nanos: u128,
+    now_nanos: u128,
+) -> serde_json::Value {
+    let mut resource = vec![otlp_attribute("service.name", METRIC_PREFIX)];
+    resource.extend(labels.iter().map(|(key, value)| otlp_attribute(key, value)));
+
+    // Ein Metrik-Eintrag je Name mit einem Datenpunkt je Chain
+    let mut names: Vec<(&'static str, Kind)> = Vec::new();
+    let mut points: HashMap<&'static str, Vec<serde_json::Value>> = HashMap::new();
+    for (chain_id, name, kind, value) in values {
+        if !points.contains_key(name) {
+            names.push((name, *kind));
+        }
+        points.entry(name).or_default().push(serde_json::json!({
+            "attributes": [otlp_attribute("chain_id", &chain_id.to_string())],
+            "startTimeUnixNano": start_nanos.to_string(),
+            "timeUnixNano": now_nanos.to_string(),
+            "asInt": value.to_string(),
+        }));
+    }
+    let metrics: Vec<serde_json::Value> = names
+        .into_iter()
+        .map(|(name, kind)| {
+            let data_points = points.remove(name).unwrap_or_default();
+            let full_name = format!("{}.{}", METRIC_PREFIX, name);
+            match kind {
+                Kind::Counter => serde_json::json!({
+                    "name": full_name,
+                    "sum": { "dataPoints": data_points, "aggregationTemporality": 2, "isMonotonic": true },
+                }),
+                Kind::Gauge => serde_json::json!({ "name": full_name, "gauge": { "dataPoints": data_points } }),
+            }
+        })
+        .collect();
+
+    serde_json::json!({
+        "resourceMetrics": [{
+            "resource": { "attributes": resource },
+            "scopeMetrics": [{
+                "scope": { "name": METRIC_PREFIX, "version": env!("CARGO_PKG_VERSION") },
+                "metrics": metrics,
+            }],
+        }],
+    })
+}
@@ -0,0 +1,346 @@
+// mmapindex.rs - Block-Index als mmap-Datei (block_index.idx, KONA_BRIDGE_INDEX_MMAP)
+//
+// Für lange Aufbewahrung ersetzt die Datei die Maps im Speicher und die JSON-Runden über block_index.json: jeder
+// gespeicherte Block wird direkt in der Datei aktualisiert, beim Start wird nichts eingelesen und der Speicherbedarf
+// bleibt beim Page-Cache. Andere Prozesse auf demselben Host können die Datei read-only mappen (Seqlock pro Slot wie
+// preconf_index.shm).
+//
+// Layout (native Byte-Order, nur für Leser auf demselben Host):
+//   Header (64 bytes):  magic u32 "KIDX", version u32, chain_id u64, capacity u32, slot_size u32, latest_block u64,
+//                       reserved
+//   Slots (128 bytes):  Slot = block_number % capacity; seq u64 (ungerade = wird geschrieben, 0 = nie belegt),
+//                       block_number u64 (u64::MAX = entfernt), block_hash [32], received_unix u64, timestamp u64,
+//                       file [64] (NUL-terminiert)
+//   Hash-Tabelle:       2 * capacity u64 (block_number + 1, 0 = leer), Start bei den ersten 8 Bytes des Block-Hashes,
+//                       lineares Sondieren über höchstens 16 Einträge; veraltete Einträge werden beim Einfügen ersetzt
+// Gültig sind nur Slots im Fenster latest_block - capacity + 1 ..= latest_block.
+
+use crate::{index::IndexEntry, perms};
+use std::{
+    fs::OpenOptions,
+    os::unix::io::AsRawFd,
+    path::Path,
+    sync::atomic::{fence, AtomicU64, Ordering},
+};
+use tracing::info;
+
+pub const INDEX_MMAP_FILE_NAME: &str = "block_index.idx";
+/// Default-Kapazität (ca. 6 Tage bei 2s Blockzeit, Datei ~36 MiB)
+pub const INDEX_MMAP_DEFAULT_BLOCKS: u32 = 262_144;
+
+const MAGIC: u32 = 0x5844_494B; // "KIDX"
+const VERSION: u32 = 1;
+const HEADER_SIZE: usize = 64;
+const SLOT_SIZE: usize = 128;
+const FILE_LEN: usize = 64;
+const HASH_PROBES: u64 = 16;
+const REMOVED: u64 = u64::MAX;
+
+// Offsets im Header
+const HDR_MAGIC: usize = 0;
+const HDR_VERSION: usize = 4;
+const HDR_CHAIN_ID: usize = 8;
+const HDR_CAPACITY: usize = 16;
+const HDR_SLOT_SIZE: usize = 20;
+const HDR_LATEST_BLOCK: usize = 24;
+
+// Offsets im Slot
+const SLOT_SEQ: usize = 0;
+const SLOT_BLOCK_NUMBER: usize = 8;
+const SLOT_BLOCK_HASH: usize = 16;
+const SLOT_RECEIVED: usize = 48;
+const SLOT_TIMESTAMP: usize = 56;
+const SLOT_FILE: usize = 64;
+
+/// Schreibender Zugriff auf block_index.idx (nur ein Writer pro Datei, der Store hält ihn unter seinem Index-Lock)
+pub struct MappedIndex {
+    ptr: *mut u8,
+    len: usize,
+    capacity: u64,
+    buckets: u64,
+}
+
+// Wie ShmIndex: geteilte Mapping-Region, geschrieben wird nur über &mut self mit Seqlock pro Slot
+unsafe impl Send for MappedIndex {}
+unsafe impl Sync for MappedIndex {}
+
+impl MappedIndex {
+    /// Öffnet oder erstellt die Datei; eine bestehende Datei mit anderer Chain/Kapazität wird neu initialisiert
+    pub fn open(path: &Path, chain_id: u64, capacity: u32) -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        let capacity = capacity.max(16) as u64;
+        let buckets = capacity * 2;
+        let len = HEADER_SIZE + capacity as usize * SLOT_SIZE + buckets as usize * 8;
+
+        let file = OpenOptions::new().read(true).write(true).create(true).open(path)?;
+        perms::apply_file(path);
+        let reinit = file.metadata()?.len() != len as u64;
+        if reinit {
+            file.set_len(len as u64)?;
+        }
+
+        let ptr = unsafe {
+            libc::mmap(
+                std::ptr::null_mut(),
+                len,
+                libc::PROT_READ | libc::PROT_WRITE,
+                libc::MAP_SHARED,
+                file.as_raw_fd(),
+                0,
+            )
+        };
+        if ptr == libc::MAP_FAILED {
+            return Err(format!("mmap of {:?} failed: {}", path, std::io::Error::last_os_error()).into());
+        }
+
+        let index = Self {
+            ptr: ptr as *mut u8,
+            len,
+            capacity,
+            buckets,
+        };
+
+        let valid = !reinit
+            && index.read_u32(HDR_MAGIC) == MAGIC
+            && index.read_u32(HDR_VERSION) == VERSION
+            && index.read_u64(HDR_CHAIN_ID) == chain_id
+            && index.read_u32(HDR_CAPACITY) as u64 == capacity;
+
+        if !valid {
+            // Neu initialisieren: magic zuletzt schreiben, damit Leser nie einen halben Header sehen
+            unsafe { std::ptr::write_bytes(index.ptr, 0, len) };
+            index.write_u32(HDR_VERSION, VERSION);
+            index.write_u64(HDR_CHAIN_ID, chain_id);
+            index.write_u32(HDR_CAPACITY, capacity as u32);
+            index.write_u32(HDR_SLOT_SIZE, SLOT_SIZE as u32);
+            fence(Ordering::Release);
+            index.write_u32(HDR_MAGIC, MAGIC);
+        } else {
+            // Abbruch mitten im Schreiben (ungerade seq): Slot verwerfen, sonst warten Leser ewig
+            for slot in 0..capacity {
+                let seq = index.atomic_u64(HEADER_SIZE + slot as usize * SLOT_SIZE + SLOT_SEQ);
+                if seq.load(Ordering::Relaxed) % 2 == 1 {
+                    seq.store(0, Ordering::Release);
+                }
+            }
+        }
+
+        info!(
+            "📇 Memory-mapped block index {:?}: {} blocks (latest: {})",
+            path,
+            capacity,
+            index.latest_number().map_or("none".to_string(), |n| n.to_string())
+        );
+        Ok(index)
+    }
+
+    /// Neuester je eingetragene Block (auch wenn er inzwischen entfernt wurde)
+    fn latest_number(&self) -> Option<u64> {
+        let latest = self.atomic_u64(HDR_LATEST_BLOCK).load(Ordering::Acquire);
+        (latest > 0).then(|| latest - 1)
+    }
+
+    /// Älteste Blocknummer im Fenster
+    fn window_start(&self, latest: u64) -> u64 {
+        (latest + 1).saturating_sub(self.capacity)
+    }
+
+    fn slot(&self, block_number: u64) -> usize {
+        HEADER_SIZE + (block_number % self.capacity) as usize * SLOT_SIZE
+    }
+
+    fn bucket(&self, position: u64) -> usize {
+        HEADER_SIZE + self.capacity as usize * SLOT_SIZE + (position % self.buckets) as usize * 8
+    }
+
+    fn hash_start(block_hash: &[u8; 32]) -> u64 {
+        u64::from_ne_bytes(block_hash[..8].try_into().unwrap())
+    }
+
+    pub fn get(&self, block_number: u64) -> Option<IndexEntry> {
+        let latest = self.latest_number()?;
+        if block_number > latest || block_number < self.window_start(latest) {
+            return None;
+        }
+
+        let slot = self.slot(block_number);
+        let seq = self.atomic_u64(slot + SLOT_SEQ);
+        loop {
+            let start = seq.load(Ordering::Acquire);
+            if start == 0 {
+                return None;
+            }
+            if start % 2 == 1 {
+                std::hint::spin_loop();
+                continue;
+            }
+            let number = self.read_u64(slot + SLOT_BLOCK_NUMBER);
+            let mut block_hash = [0u8; 32];
+            self.read_bytes(slot + SLOT_BLOCK_HASH, &mut block_hash);
+            let received_unix = self.read_u64(slot + SLOT_RECEIVED);
+            let timestamp = self.read_u64(slot + SLOT_TIMESTAMP);
+            let mut file = [0u8; FILE_LEN];
+            self.read_bytes(slot + SLOT_FILE, &mut file);
+            fence(Ordering::Acquire);
+            if seq.load(Ordering::Relaxed) != start {
+                continue;
+            }
+
+            if number != block_number {
+                return None;
+            }
+            let end = file.iter().position(|b| *b == 0).unwrap_or(FILE_LEN);
+            return Some(IndexEntry {
+                block_hash,
+                file: String::from_utf8_lossy(&file[..end]).into_owned(),
+                received_unix,
+                timestamp,
+            });
+        }
+    }
+
+    pub fn get_by_hash(&self, block_hash: &[u8; 32]) -> Option<IndexEntry> {
+        let start = Self::hash_start(block_hash);
+        for probe in 0..HASH_PROBES {
+            let value = self.read_u64(self.bucket(start.wrapping_add(probe)));
+            if value == 0 {
+                return None;
+            }
+            if let Some(entry) = self.get(value - 1).filter(|entry| entry.block_hash == *block_hash) {
+                return Some(entry);
+            }
+        }
+        None
+    }
+
+    /// Trägt einen Block ein; Blöcke älter als das Fenster werden ignoriert
+    pub fn insert(&mut self, block_number: u64, entry: &IndexEntry) {
+        let latest = self.latest_number();
+        if latest.map_or(false, |latest| block_number < self.window_start(latest)) {
+            return;
+        }
+
+        let mut file = [0u8; FILE_LEN];
+        let bytes = entry.file.as_bytes();
+        let n = bytes.len().min(FILE_LEN - 1);
+        file[..n].copy_from_slice(&bytes[..n]);
+
+        self.write_slot(block_number, |index, slot| {
+            index.write_u64(slot + SLOT_BLOCK_NUMBER, block_number);
+            index.write_bytes(slot + SLOT_BLOCK_HASH, &entry.block_hash);
+            index.write_u64(slot + SLOT_RECEIVED, entry.received_unix);
+            index.write_u64(slot + SLOT_TIMESTAMP, entry.timestamp);
+            index.write_bytes(slot + SLOT_FILE, &file);
+        });
+        if latest.map_or(true, |latest| block_number > latest) {
+            self.atomic_u64(HDR_LATEST_BLOCK).store(block_number + 1, Ordering::Release);
+        }
+
+        // Hash-Eintrag: freier, gleicher oder veralteter Bucket, sonst der mit dem ältesten Block
+        let start = Self::hash_start(&entry.block_hash);
+        let mut target = (start, u64::MAX);
+        for probe in 0..HASH_PROBES {
+            let value = self.read_u64(self.bucket(start.wrapping_add(probe)));
+            let stale = value == 0 || value - 1 == block_number || self.get(value - 1).is_none();
+            if stale {
+                target = (start.wrapping_add(probe), 0);
+                break;
+            }
+            if value - 1 < target.1 {
+                target = (start.wrapping_add(probe), value - 1);
+            }
+        }
+        self.write_u64(self.bucket(target.0), block_number + 1);
+    }
+
+    /// Entfernt einen Block (der Hash-Eintrag veraltet damit)
+    pub fn remove(&mut self, block_number: u64) -> bool {
+        if self.get(block_number).is_none() {
+            return false;
+        }
+        self.write_slot(block_number, |index, slot| index.write_u64(slot + SLOT_BLOCK_NUMBER, REMOVED));
+        true
+    }
+
+    fn write_slot(&mut self, block_number: u64, write: impl FnOnce(&Self, usize)) {
+        let slot = self.slot(block_number);
+        let seq = self.atomic_u64(slot + SLOT_SEQ);
+        let start = seq.load(Ordering::Relaxed) | 1; // ungerade = in Arbeit
+        seq.store(start, Ordering::Relaxed);
+        fence(Ordering::Release);
+        write(self, slot);
+        seq.store(start + 1, Ordering::Release); // gerade = stabil
+    }
+
+    /// Alle Blöcke im Fenster (aufsteigend, liest die Slots erst beim Iterieren)
+    pub fn iter(&self) -> impl DoubleEndedIterator<Item = (u64, IndexEntry)> + '_ {
+        let numbers = match self.latest_number() {
+            Some(latest) => self.window_start(latest)..=latest,
+            None => 1..=0,
+        };
+        numbers.filter_map(|number| self.get(number).map(|entry| (number, entry)))
+    }
+
+    /// Neuester (back = 0) bzw. ein vorheriger Block
+    pub fn latest(&self, back: usize) -> Option<(u64, IndexEntry)> {
+        self.iter().rev().nth(back)
+    }
+
+    /// Blocknummern im Fenster, die in from..=to liegen
+    pub fn range(&self, from: u64, to: u64) -> std::ops::RangeInclusive<u64> {
+        match self.latest_number() {
+            Some(latest) => from.max(self.window_start(latest))..=to.min(latest),
+            None => 1..=0,
+        }
+    }
+
+    /// Schreibt geänderte Seiten zurück (asynchron, der Page-Cache hält sie bereits für andere Leser)
+    pub fn flush(&self) -> std::io::Result<()> {
+        if unsafe { libc::msync(self.ptr as *mut libc::c_void, self.len, libc::MS_ASYNC) } != 0 {
+            return Err(std::io::Error::last_os_error());
+        }
+        Ok(())
+    }
+
+    fn atomic_u64(&self, offset: usize) -> &AtomicU64 {
+        debug_assert!(offset % 8 == 0 && offset + 8 <= self.len);
+        unsafe { &*(self.ptr.add(offset) as *const AtomicU64) }
+    }
+
+    fn read_u32(&self, offset: usize) -> u32 {
+        let mut buf = [0u8; 4];
+        self.read_bytes(offset, &mut buf);
+        u32::from_ne_bytes(buf)
+    }
+
+    fn read_u64(&self, offset: usize) -> u64 {
+        let mut buf = [0u8; 8];
+        self.read_bytes(offset, &mut buf);
+        u64::from_ne_bytes(buf)
+    }
+
+    fn read_bytes(&self, offset: usize, buf: &mut [u8]) {
+        debug_assert!(offset + buf.len() <= self.len);
+        unsafe { std::ptr::copy_nonoverlapping(self.ptr.add(offset), buf.as_mut_ptr(), buf.len()) };
+    }
+
+    fn write_u32(&self, offset: usize, value: u32) {
+        self.write_bytes(offset, &value.to_ne_bytes());
+    }
+
+    fn write_u64(&self, offset: usize, value: u64) {
+        self.write_bytes(offset, &value.to_ne_bytes());
+    }
+
+    fn write_bytes(&self, offset: usize, data: &[u8]) {
+        debug_assert!(offset + data.len() <= self.len);
+        unsafe { std::ptr::copy_nonoverlapping(data.as_ptr(), self.ptr.add(offset), data.len()) };
+    }
+}
+
+impl Drop for MappedIndex {
+    fn drop(&mut self) {
+        unsafe {
+            libc::munmap(self.ptr as *mut libc::c_void, self.len);
+        }
+    }
+}
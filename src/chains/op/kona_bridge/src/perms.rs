@@ -0,0 +1,192 @@
+// perms.rs - Dateirechte und Eigentümer der geschriebenen Dateien und Verzeichnisse
+//
+// Ohne Konfiguration entstehen Dateien mit 0644 bzw. Verzeichnisse mit 0755 abzüglich umask. Läuft der C-Server unter
+// einem anderen Benutzer, lassen sich Rechte und Eigentümer festlegen (unabhängig von der umask):
+//   KONA_BRIDGE_FILE_MODE   oktal, z.B. 0640 (Preconfs, Metadaten, Index, latest-Zeiger, Shared-Memory-Index, Journal)
+//   KONA_BRIDGE_DIR_MODE    oktal, z.B. 2750 (Output-, Chain-, Blob-, Quarantäne- und Evidence-Verzeichnis)
+//   KONA_BRIDGE_OWNER       user[:group] bzw. uid[:gid], nur als root (z.B. im Entrypoint eines Containers)
+// Der Query-Socket bekommt die Dateirechte plus Schreibrecht für jeden, der lesen darf (connect braucht w).
+// Temp-Dateien werden vor dem rename angepasst, die fertige Datei ist also nie mit den Default-Rechten sichtbar.
+// Fehler beim Setzen werden einmal gewarnt, das Schreiben selbst scheitert daran nicht. Außerhalb von unix gibt es
+// weder Modus noch Eigentümer, die Variablen werden dort mit einer Warnung ignoriert.
+
+use crate::settings::env_string;
+#[cfg(unix)]
+use std::{
+    ffi::CString,
+    os::unix::fs::{chown, PermissionsExt},
+    sync::atomic::{AtomicBool, Ordering},
+};
+use std::{fs, path::Path, sync::OnceLock};
+use tracing::{info, warn};
+
+/// Konfigurierte Rechte (None = unverändert)
+#[derive(Debug, Default)]
+struct Permissions {
+    file_mode: Option<u32>,
+    dir_mode: Option<u32>,
+    owner: Option<(Option<u32>, Option<u32>)>, // (uid, gid)
+}
+
+static PERMISSIONS: OnceLock<Permissions> = OnceLock::new();
+/// Schon gewarnt? (ein fehlschlagendes chmod/chown betrifft meist jede Datei)
+#[cfg(unix)]
+static WARNED: AtomicBool = AtomicBool::new(false);
+
+fn permissions() -> &'static Permissions {
+    PERMISSIONS.get_or_init(|| {
+        if cfg!(not(unix)) {
+            for name in ["KONA_BRIDGE_FILE_MODE", "KONA_BRIDGE_DIR_MODE", "KONA_BRIDGE_OWNER"] {
+                if env_string(name).is_some() {
+                    warn!("⚠️  Ignoring {}: file modes and ownership are only supported on unix", name);
+                }
+            }
+            return Permissions::default();
+        }
+        let permissions = Permissions {
+            file_mode: parse_env_mode("KONA_BRIDGE_FILE_MODE"),
+            dir_mode: parse_env_mode("KONA_BRIDGE_DIR_MODE"),
+            owner: env_string("KONA_BRIDGE_OWNER").and_then(|value| match parse_owner(&value) {
+                Ok(_) if !is_root() => {
+                    warn!("⚠️  Ignoring KONA_BRIDGE_OWNER={}: changing ownership requires root", value);
+                    None
+                }
+                Ok(owner) => Some(owner),
+                Err(e) => {
+                    warn!("⚠️  Ignoring invalid value for KONA_BRIDGE_OWNER: {} ({})", value, e);
+                    None
+                }
+            }),
+        };
+        if permissions.file_mode.is_some() || permissions.dir_mode.is_some() || permissions.owner.is_some() {
+            let mode = |mode: Option<u32>| mode.map_or("default".to_string(), |mode| format!("{:04o}", mode));
+            info!(
+                "🔐 File mode {}, directory mode {}, owner {}",
+                mode(permissions.file_mode),
+                mode(permissions.dir_mode),
+                permissions.owner.map_or("unchanged".to_string(), |(uid, gid)| format!(
+                    "{}:{}",
+                    uid.map_or("-".to_string(), |uid| uid.to_string()),
+                    gid.map_or("-".to_string(), |gid| gid.to_string())
+                ))
+            );
+        }
+        permissions
+    })
+}
+
+fn parse_env_mode(name: &str) -> Option<u32> {
+    let value = env_string(name)?;
+    let mode = parse_mode(&value);
+    if mode.is_none() {
+        warn!("⚠️  Ignoring invalid value for {}: {} (octal, e.g. 0640)", name, value);
+    }
+    mode
+}
+
+/// Oktaler Modus wie bei chmod ("640", "0640", "0o640", "2750")
+fn parse_mode(value: &str) -> Option<u32> {
+    let value = value.trim();
+    let digits = value.strip_prefix("0o").unwrap_or(value);
+    u32::from_str_radix(digits, 8).ok().filter(|mode| *mode <= 0o7777)
+}
+
+/// "user[:group]" mit Namen oder numerischen IDs
+fn parse_owner(value: &str) -> Result<(Option<u32>, Option<u32>), String> {
+    let (user, group) = match value.trim().split_once(':') {
+        Some((user, group)) => (user, Some(group)),
+        None => (value.trim(), None),
+    };
+    let uid = match user {
+        "" => None,
+        user => Some(user.parse().or_else(|_| lookup_user(user)).map_err(|_| format!("unknown user {}", user))?),
+    };
+    let gid = match group {
+        None | Some("") => None,
+        Some(group) => {
+            Some(group.parse().or_else(|_| lookup_group(group)).map_err(|_| format!("unknown group {}", group))?)
+        }
+    };
+    if uid.is_none() && gid.is_none() {
+        return Err("neither user nor group".to_string());
+    }
+    Ok((uid, gid))
+}
+
+#[cfg(unix)]
+fn is_root() -> bool {
+    unsafe { libc::geteuid() == 0 }
+}
+
+#[cfg(not(unix))]
+fn is_root() -> bool {
+    false
+}
+
+#[cfg(unix)]
+fn lookup_user(name: &str) -> Result<u32, ()> {
+    let name = CString::new(name).map_err(|_| ())?;
+    let entry = unsafe { libc::getpwnam(name.as_ptr()) };
+    if entry.is_null() {
+        return Err(());
+    }
+    Ok(unsafe { (*entry).pw_uid })
+}
+
+#[cfg(unix)]
+fn lookup_group(name: &str) -> Result<u32, ()> {
+    let name = CString::new(name).map_err(|_| ())?;
+    let entry = unsafe { libc::getgrnam(name.as_ptr()) };
+    if entry.is_null() {
+        return Err(());
+    }
+    Ok(unsafe { (*entry).gr_gid })
+}
+
+#[cfg(not(unix))]
+fn lookup_user(_name: &str) -> Result<u32, ()> {
+    Err(())
+}
+
+#[cfg(not(unix))]
+fn lookup_group(_name: &str) -> Result<u32, ()> {
+    Err(())
+}
+
+#[cfg(unix)]
+fn apply(path: &Path, mode: Option<u32>) {
+    let owner = permissions().owner;
+    let result = owner
+        .map_or(Ok(()), |(uid, gid)| chown(path, uid, gid))
+        .and_then(|_| mode.map_or(Ok(()), |mode| fs::set_permissions(path, fs::Permissions::from_mode(mode))));
+    if let Err(e) = result {
+        if !WARNED.swap(true, Ordering::Relaxed) {
+            warn!("⚠️  Cannot set permissions of {}: {} (further failures are not logged)", path.display(), e);
+        }
+    }
+}
+
+#[cfg(not(unix))]
+fn apply(_path: &Path, _mode: Option<u32>) {}
+
+/// Rechte und Eigentümer einer geschriebenen Datei (vor dem rename auf die Temp-Datei anwenden)
+pub fn apply_file(path: &Path) {
+    apply(path, permissions().file_mode);
+}
+
+/// Rechte und Eigentümer eines angelegten Verzeichnisses
+pub fn apply_dir(path: &Path) {
+    apply(path, permissions().dir_mode);
+}
+
+/// Unix-Socket: wer lesen darf, darf auch verbinden
+pub fn apply_socket(path: &Path) {
+    apply(path, permissions().file_mode.map(|mode| mode | (mode & 0o444) >> 1));
+}
+
+/// create_dir_all mit den konfigurierten Rechten für das Zielverzeichnis
+pub fn create_dir_all(path: &Path) -> std::io::Result<()> {
+    fs::create_dir_all(path)?;
+    apply_dir(path);
+    Ok(())
+}
@@ -0,0 +1,150 @@
+// pointer.rs - Portable "latest"-Zeiger (latest.raw / pre_latest.raw / latest.json)
+//
+// latest.raw und pre_latest.raw waren reine Symlinks. Die schlagen unter Windows ohne
+// Sonderrechte und auf manchen Netzwerk-Dateisystemen fehl. Deshalb:
+//   - latest.json enthält immer die aktuellen Dateinamen (atomar über tmp + rename)
+//   - latest.raw/pre_latest.raw werden je nach Modus als Symlink, Hardlink oder Kopie gepflegt,
+//     jeweils atomar (temporärer Name + rename). Im Modus auto wird auf Unix mit Symlinks begonnen
+//     (sonst mit Hardlinks) und bei einem Fehler dauerhaft auf Hardlink und danach Kopie heruntergeschaltet.
+
+use crate::{durability::DurableFs, perms};
+use std::{
+    path::Path,
+    sync::atomic::{AtomicU8, Ordering},
+};
+use tokio::fs as tokio_fs;
+use tracing::warn;
+
+pub const LATEST_FILE_NAME: &str = "latest.raw";
+pub const PRE_LATEST_FILE_NAME: &str = "pre_latest.raw";
+pub const LATEST_JSON_FILE_NAME: &str = "latest.json";
+
+/// Wie latest.raw/pre_latest.raw gepflegt werden
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum PointerMode {
+    #[default]
+    Auto,
+    Symlink,
+    Hardlink,
+    Copy,
+    Json, // nur latest.json
+}
+
+impl PointerMode {
+    /// Parst "auto", "symlink", "hardlink", "copy" oder "json"
+    pub fn parse(value: &str) -> Option<Self> {
+        match value.trim().to_ascii_lowercase().as_str() {
+            "auto" | "" => Some(PointerMode::Auto),
+            "symlink" => Some(PointerMode::Symlink),
+            "hardlink" => Some(PointerMode::Hardlink),
+            "copy" => Some(PointerMode::Copy),
+            "json" => Some(PointerMode::Json),
+            _ => None,
+        }
+    }
+}
+
+const STRATEGY_SYMLINK: u8 = 0;
+const STRATEGY_HARDLINK: u8 = 1;
+const STRATEGY_COPY: u8 = 2;
+const STRATEGY_NONE: u8 = 3;
+
+/// Pflegt die latest-Zeiger eines Output-Verzeichnisses
+pub struct LatestPointer {
+    auto: bool,
+    strategy: AtomicU8,
+}
+
+impl LatestPointer {
+    pub fn new(mode: PointerMode) -> Self {
+        let strategy = match mode {
+            PointerMode::Auto if cfg!(unix) => STRATEGY_SYMLINK,
+            PointerMode::Auto | PointerMode::Hardlink => STRATEGY_HARDLINK,
+            PointerMode::Symlink => STRATEGY_SYMLINK,
+            PointerMode::Copy => STRATEGY_COPY,
+            PointerMode::Json => STRATEGY_NONE,
+        };
+        Self {
+            auto: mode == PointerMode::Auto,
+            strategy: AtomicU8::new(strategy),
+        }
+    }
+
+    /// Setzt latest auf `latest` und pre_latest auf `pre_latest`
+    pub async fn update(
+        &self,
+        output_dir: &Path,
+        latest: &str,
+        pre_latest: Option<&str>,
+        fs: &DurableFs,
+    ) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        let json_path = output_dir.join(LATEST_JSON_FILE_NAME);
+        let json = serde_json::json!({ "latest": latest, "pre_latest": pre_latest });
+        fs.write_atomic(&json_path.with_extension("json.tmp"), &json_path, json.to_string().as_bytes())
+            .await
+            .map_err(|e| format!("Failed to write {}: {}", LATEST_JSON_FILE_NAME, e))?;
+
+        if let Some(pre_latest) = pre_latest {
+            self.set(output_dir, PRE_LATEST_FILE_NAME, pre_latest).await?;
+        }
+        self.set(output_dir, LATEST_FILE_NAME, latest).await
+    }
+
+    async fn set(&self, output_dir: &Path, name: &str, target: &str) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        loop {
+            let strategy = self.strategy.load(Ordering::Relaxed);
+            let result = match strategy {
+                STRATEGY_NONE => return Ok(()),
+                STRATEGY_SYMLINK => replace_with_symlink(output_dir, name, target).await,
+                STRATEGY_HARDLINK => replace_with_hardlink(output_dir, name, target).await,
+                _ => replace_with_copy(output_dir, name, target).await,
+            };
+
+            match result {
+                Ok(()) => return Ok(()),
+                Err(e) if self.auto && strategy < STRATEGY_COPY => {
+                    let next = if strategy == STRATEGY_SYMLINK { "hardlink" } else { "copy" };
+                    warn!("⚠️  Cannot update {} ({}), switching to {}", name, e, next);
+                    self.strategy.store(strategy + 1, Ordering::Relaxed);
+                }
+                Err(e) => return Err(format!("Failed to update {}: {}", name, e).into()),
+            }
+        }
+    }
+}
+
+#[cfg(unix)]
+async fn replace_with_symlink(output_dir: &Path, name: &str, target: &str) -> std::io::Result<()> {
+    let temp = output_dir.join(format!("{}.lnk", name));
+    let _ = tokio_fs::remove_file(&temp).await;
+    tokio_fs::symlink(target, &temp).await?;
+    tokio_fs::rename(&temp, output_dir.join(name)).await
+}
+
+#[cfg(not(unix))]
+async fn replace_with_symlink(_output_dir: &Path, _name: &str, _target: &str) -> std::io::Result<()> {
+    Err(std::io::Error::new(std::io::ErrorKind::Unsupported, "symlinks not supported"))
+}
+
+async fn replace_with_hardlink(output_dir: &Path, name: &str, target: &str) -> std::io::Result<()> {
+    let temp = output_dir.join(format!("{}.lnk", name));
+    let _ = tokio_fs::remove_file(&temp).await;
+    tokio_fs::hard_link(output_dir.join(target), &temp).await?;
+    tokio_fs::rename(&temp, output_dir.join(name)).await
+}
+
+async fn replace_with_copy(output_dir: &Path, name: &str, target: &str) -> std::io::Result<()> {
+    let temp = output_dir.join(format!("{}.tmp", name));
+    tokio_fs::copy(output_dir.join(target), &temp).await?;
+    perms::apply_file(&temp);
+    tokio_fs::rename(&temp, output_dir.join(name)).await
+}
+
+/// Liest den Dateinamen aus latest.json (back = 0 latest, 1 pre_latest)
+pub fn read_latest_json(output_dir: &Path, back: usize) -> Option<String> {
+    let data = std::fs::read(output_dir.join(LATEST_JSON_FILE_NAME)).ok()?;
+    let json: serde_json::Value = serde_json::from_slice(&data).ok()?;
+    let key = if back == 0 { "latest" } else { "pre_latest" };
+    json[key].as_str().map(str::to_string)
+}
+
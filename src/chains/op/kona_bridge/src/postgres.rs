@@ -0,0 +1,190 @@
+// postgres.rs - PostgreSQL als zusätzlicher Speicher für Preconf-Metadaten (optional inkl. Payload)
+//
+// Die Bridge bleibt der einzige Schreiber: jeder lokal gespeicherte Preconf wird per Upsert
+// in `preconfs` geschrieben (Reorg/erneuter Empfang ersetzt die Zeile), der Container optional
+// in `preconf_payloads`. Leser (Analytics, weitere Server) greifen per SQL zu.
+// Das Schema wird beim Start angelegt; bei Verbindungsabbruch wird neu verbunden.
+
+use crate::{settings::PostgresSettings, sink::StoredPreconf};
+use std::{
+    sync::{Arc, Mutex},
+    time::Duration,
+};
+use tokio::{sync::mpsc, time::sleep};
+use tokio_postgres::{Client, NoTls};
+use tracing::{debug, info, warn};
+
+const WRITE_ATTEMPTS: u32 = 3;
+
+/// Alte Zeilen werden nur alle n Inserts gelöscht (KONA_BRIDGE_POSTGRES_KEEP_BLOCKS)
+const PRUNE_EVERY: u64 = 100;
+
+const SCHEMA: &str = "
+CREATE TABLE IF NOT EXISTS preconfs (
+    chain_id          BIGINT NOT NULL,
+    block_number      BIGINT NOT NULL,
+    block_hash        BYTEA  NOT NULL,
+    received_unix     BIGINT NOT NULL,
+    timestamp         BIGINT NOT NULL,
+    source            TEXT,
+    codec             TEXT,
+    compressed_size   BIGINT,
+    decompressed_size BIGINT,
+    file_path         TEXT   NOT NULL,
+    metadata          JSONB  NOT NULL,
+    PRIMARY KEY (chain_id, block_number)
+);
+CREATE INDEX IF NOT EXISTS preconfs_block_hash_idx ON preconfs (block_hash);
+CREATE INDEX IF NOT EXISTS preconfs_timestamp_idx ON preconfs (chain_id, timestamp);
+CREATE INDEX IF NOT EXISTS preconfs_received_idx ON preconfs (chain_id, received_unix);
+CREATE TABLE IF NOT EXISTS preconf_payloads (
+    chain_id     BIGINT NOT NULL,
+    block_number BIGINT NOT NULL,
+    data         BYTEA  NOT NULL,
+    PRIMARY KEY (chain_id, block_number),
+    FOREIGN KEY (chain_id, block_number) REFERENCES preconfs (chain_id, block_number) ON DELETE CASCADE
+);
+";
+
+const UPSERT_PRECONF: &str = "
+INSERT INTO preconfs (chain_id, block_number, block_hash, received_unix, timestamp, source, codec,
+                      compressed_size, decompressed_size, file_path, metadata)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11::text::jsonb)
+ON CONFLICT (chain_id, block_number) DO UPDATE SET
+    block_hash = EXCLUDED.block_hash, received_unix = EXCLUDED.received_unix, timestamp = EXCLUDED.timestamp,
+    source = EXCLUDED.source, codec = EXCLUDED.codec, compressed_size = EXCLUDED.compressed_size,
+    decompressed_size = EXCLUDED.decompressed_size, file_path = EXCLUDED.file_path, metadata = EXCLUDED.metadata";
+
+const UPSERT_PAYLOAD: &str = "
+INSERT INTO preconf_payloads (chain_id, block_number, data) VALUES ($1, $2, $3)
+ON CONFLICT (chain_id, block_number) DO UPDATE SET data = EXCLUDED.data";
+
+const PRUNE: &str = "DELETE FROM preconfs WHERE chain_id = $1 AND block_number < $2";
+
+/// Verbindet und legt das Schema an
+async fn connect(settings: &PostgresSettings) -> Result<Client, Box<dyn std::error::Error + Send + Sync>> {
+    let (client, connection) = tokio_postgres::connect(&settings.url, NoTls).await?;
+    tokio::spawn(async move {
+        if let Err(e) = connection.await {
+            warn!("⚠️  Postgres: connection closed: {}", e);
+        }
+    });
+    client.batch_execute(SCHEMA).await?;
+    Ok(client)
+}
+
+/// Schreibt einen Preconf (Metadaten und optional Payload) in einer Transaktion
+async fn write_preconf(
+    client: &Client,
+    preconf: &StoredPreconf,
+    store_payloads: bool,
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let metadata = &preconf.metadata;
+    let chain_id = preconf.chain_id as i64;
+    let block_number = preconf.block_number as i64;
+
+    client.batch_execute("BEGIN").await?;
+    let result = async {
+        client
+            .execute(
+                UPSERT_PRECONF,
+                &[
+                    &chain_id,
+                    &block_number,
+                    &preconf.block_hash.as_slice(),
+                    &(preconf.received_unix as i64),
+                    &(preconf.timestamp as i64),
+                    &metadata["source"].as_str(),
+                    &metadata["codec"].as_str(),
+                    &metadata["compressed_size"].as_i64(),
+                    &metadata["decompressed_size"].as_i64(),
+                    &preconf.raw_filename.as_str(),
+                    &metadata.to_string(),
+                ],
+            )
+            .await?;
+        if store_payloads {
+            client
+                .execute(UPSERT_PAYLOAD, &[&chain_id, &block_number, &preconf.raw_data.as_slice()])
+                .await?;
+        }
+        Ok::<_, tokio_postgres::Error>(())
+    }
+    .await;
+
+    match result {
+        Ok(()) => client.batch_execute("COMMIT").await?,
+        Err(e) => {
+            let _ = client.batch_execute("ROLLBACK").await;
+            return Err(e.into());
+        }
+    }
+    Ok(())
+}
+
+/// Sink-Task: schreibt jeden gespeicherten Preconf nach PostgreSQL
+pub async fn run_postgres_sink(
+    settings: PostgresSettings,
+    mut receiver: mpsc::Receiver<Arc<StoredPreconf>>,
+    running: Arc<Mutex<bool>>,
+) {
+    let mut client: Option<Client> = None;
+    let mut written: u64 = 0;
+
+    while let Some(preconf) = receiver.recv().await {
+        if !*running.lock().unwrap() {
+            break;
+        }
+
+        let mut attempt = 1;
+        loop {
+            if client.as_ref().map_or(true, |c| c.is_closed()) {
+                client = match connect(&settings).await {
+                    Ok(c) => {
+                        info!("🐘 Postgres: connected, schema ready");
+                        Some(c)
+                    }
+                    Err(e) => {
+                        debug!("🐘 Postgres: connect failed (attempt {}): {}", attempt, e);
+                        None
+                    }
+                };
+            }
+
+            let result = match client {
+                Some(ref c) => write_preconf(c, &preconf, settings.store_payloads).await,
+                None => Err("not connected".into()),
+            };
+            match result {
+                Ok(()) => {
+                    debug!("🐘 Postgres: stored block {}", preconf.block_number);
+                    written += 1;
+                    break;
+                }
+                Err(e) if attempt < WRITE_ATTEMPTS => {
+                    debug!("🐘 Postgres: write of block {} failed (attempt {}): {}", preconf.block_number, attempt, e);
+                    sleep(Duration::from_millis(500 * attempt as u64)).await;
+                    attempt += 1;
+                }
+                Err(e) => {
+                    warn!("⚠️  Postgres: giving up on block {} after {} attempts: {}", preconf.block_number, attempt, e);
+                    break;
+                }
+            }
+        }
+
+        // Zeilen außerhalb des Fensters löschen (Payloads per ON DELETE CASCADE)
+        if let (Some(keep), Some(ref c)) = (settings.keep_blocks, client.as_ref()) {
+            if written > 0 && written % PRUNE_EVERY == 0 {
+                let below = preconf.block_number.saturating_sub(keep) as i64;
+                match c.execute(PRUNE, &[&(preconf.chain_id as i64), &below]).await {
+                    Ok(0) => {}
+                    Ok(deleted) => debug!("🐘 Postgres: pruned {} rows below block {}", deleted, below),
+                    Err(e) => warn!("⚠️  Postgres: prune failed: {}", e),
+                }
+            }
+        }
+    }
+
+    info!("🛑 Postgres sink stopped");
+}
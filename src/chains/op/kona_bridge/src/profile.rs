@@ -0,0 +1,123 @@
+// profile.rs - Voreinstellungen für den Ressourcenbedarf (KONA_BRIDGE_PROFILE bzw. --profile)
+//
+//   default   wie bisher: Discovery, bis zu 3 Bootnodes, Index über 4096 Blöcke in block_index.json, .json Metadaten
+//   tiny      für Edge-Geräte (Raspberry-Pi-Klasse) neben dem Verifier: keine Discovery (das Gossip-Netz spricht nur
+//             mit den Bootnodes), höchstens 2 Peers, Index und Caches über 256 Blöcke nur im Speicher, keine
+//             block_{chain}_{n}.json Metadaten, je ein Worker für Signaturprüfung und Gossip-Pipeline
+// Einzelne Werte lassen sich weiterhin über ihre Variablen überschreiben (siehe BridgeSettings::from_env):
+//   KONA_BRIDGE_DISCOVERY, KONA_BRIDGE_MAX_PEERS, KONA_BRIDGE_INDEX_CAPACITY, KONA_BRIDGE_PERSIST_INDEX,
+//   KONA_BRIDGE_METADATA_FILES, KONA_BRIDGE_VERIFY_WORKERS, KONA_BRIDGE_GOSSIP_WORKERS, KONA_BRIDGE_GOSSIP_QUEUE
+// Ein Speicherbudget (KONA_BRIDGE_MAX_MEMORY bzw. --max-memory, siehe membudget.rs) bemisst Caches und Queues der
+// Voreinstellung neu, bevor diese Overrides greifen.
+// Index-Größe, Signer-Cache und Gossip-Limits gelten für den ganzen Prozess und werden beim ersten Zugriff gelesen.
+
+use crate::{
+    index::INDEX_CAPACITY,
+    membudget,
+    settings::{env_parse, env_string},
+    signer::{DEFAULT_VERIFY_WORKERS, RECOVERY_CACHE_SIZE},
+};
+use std::sync::OnceLock;
+use tracing::{info, warn};
+
+/// Aufgelöste Voreinstellung
+#[derive(Debug, Clone)]
+pub struct Profile {
+    pub name: &'static str,
+    pub discovery: bool,            // discv5 sucht selbst nach Peers (false = nur Bootnodes)
+    pub max_peers: usize,           // Bootnodes, mit denen das Gossip-Netz startet
+    pub index_capacity: usize,      // Blöcke im Block- und Transaktions-Index
+    pub signer_cache: usize,        // Einträge im Cache der Signer-Recovery
+    pub persist_index: bool,        // block_index.json, signer_history.json und checkpoint.json schreiben
+    pub metadata_files: bool,       // block_{chain}_{n}.json neben der .raw Datei
+    pub verify_workers: usize,      // Threads für die Signaturprüfung
+    pub gossip_workers: usize,      // Parallele Verify-Stufe der Gossip-Pipeline (SSZ, Keccak, Recovery)
+    pub gossip_queue: usize,        // Blöcke zwischen Empfang und Write-Stufe, darüber wird verworfen
+    pub dedup_blocks: usize,        // Block-Nummern im Deduplikator der Capture-Schleife
+    pub hot_blocks_max: usize,      // Obergrenze für KONA_BRIDGE_HOT_BLOCKS (0 = kein Hot-Tier)
+    pub payload_cache_bytes: usize, // Payload-Cache der Delta-Speicherung
+    pub memory_budget: Option<u64>, // Speicherbudget in Bytes (None = unbegrenzt)
+}
+
+const DEFAULT: Profile = Profile {
+    name: "default",
+    discovery: true,
+    max_peers: 3,
+    index_capacity: INDEX_CAPACITY,
+    signer_cache: RECOVERY_CACHE_SIZE,
+    persist_index: true,
+    metadata_files: true,
+    verify_workers: DEFAULT_VERIFY_WORKERS,
+    gossip_workers: 2,
+    gossip_queue: 64,
+    dedup_blocks: 200,
+    hot_blocks_max: usize::MAX,
+    payload_cache_bytes: 32 * 1024 * 1024,
+    memory_budget: None,
+};
+
+const TINY: Profile = Profile {
+    name: "tiny",
+    discovery: false,
+    max_peers: 2,
+    index_capacity: 256,
+    signer_cache: 256,
+    persist_index: false,
+    metadata_files: false,
+    verify_workers: 1,
+    gossip_workers: 1,
+    gossip_queue: 16,
+    dedup_blocks: 200,
+    hot_blocks_max: usize::MAX,
+    payload_cache_bytes: 4 * 1024 * 1024,
+    memory_budget: None,
+};
+
+static PROFILE: OnceLock<Profile> = OnceLock::new();
+
+/// Voreinstellung zu einem Namen
+fn preset(name: &str) -> Option<Profile> {
+    match name.to_ascii_lowercase().as_str() {
+        "default" => Some(DEFAULT),
+        "tiny" => Some(TINY),
+        _ => None,
+    }
+}
+
+/// Aktive Voreinstellung des Prozesses; die prozessweiten Werte enthalten schon ihre Overrides, persist_index,
+/// metadata_files und verify_workers sind nur Defaults für BridgeSettings
+pub fn current() -> &'static Profile {
+    PROFILE.get_or_init(|| {
+        let mut profile = match env_string("KONA_BRIDGE_PROFILE") {
+            Some(name) => preset(&name).unwrap_or_else(|| {
+                warn!("⚠️  Unknown KONA_BRIDGE_PROFILE={} (default or tiny), using default", name);
+                DEFAULT
+            }),
+            None => DEFAULT,
+        };
+        if let Some(budget) = membudget::from_env() {
+            membudget::apply(&mut profile, budget);
+        }
+        if let Some(discovery) = env_parse::<u32>("KONA_BRIDGE_DISCOVERY") {
+            profile.discovery = discovery != 0;
+        }
+        profile.max_peers = env_parse::<usize>("KONA_BRIDGE_MAX_PEERS").unwrap_or(profile.max_peers).max(1);
+        profile.index_capacity = env_parse::<usize>("KONA_BRIDGE_INDEX_CAPACITY")
+            .unwrap_or(profile.index_capacity)
+            .max(16);
+        profile.gossip_workers = env_parse::<usize>("KONA_BRIDGE_GOSSIP_WORKERS")
+            .unwrap_or(profile.gossip_workers)
+            .max(1);
+        profile.gossip_queue = env_parse::<usize>("KONA_BRIDGE_GOSSIP_QUEUE").unwrap_or(profile.gossip_queue).max(1);
+        if profile.name != DEFAULT.name {
+            info!(
+                "🪶 Profile {}: discovery {}, {} peer(s), index of {} blocks",
+                profile.name,
+                if profile.discovery { "on" } else { "off" },
+                profile.max_peers,
+                profile.index_capacity
+            );
+        }
+        profile
+    })
+}
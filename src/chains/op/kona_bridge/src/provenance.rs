@@ -0,0 +1,88 @@
+// provenance.rs - Herkunft eines Blocks, der über mehrere Quellen eintrifft (Gossip, HTTP, Backfill, ...)
+//
+// Derselbe Block (gleiche Höhe, gleicher Hash) wird nur einmal gespeichert; jede weitere Quelle ergänzt in den
+// Metadaten "sources" um {source, received_unix} (je Quelle der erste Empfang). "received_unix" bleibt der erste
+// Empfang, "source" nennt die bevorzugte Quelle (KONA_BRIDGE_SOURCE_PREFERENCE bzw. ..._{chain_id}):
+//   earliest            (Default) die Quelle, die den Block zuerst geliefert hat
+//   <quelle>,<quelle>   Rangfolge, z.B. gossip,http; nicht genannte Quellen zählen nach den genannten, bei
+//                       gleichem Rang bleibt die frühere
+
+use std::fmt;
+
+#[derive(Debug, Clone, PartialEq, Eq, Default)]
+pub enum SourcePreference {
+    #[default]
+    Earliest,
+    Ranked(Vec<String>),
+}
+
+impl fmt::Display for SourcePreference {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            SourcePreference::Earliest => write!(f, "earliest"),
+            SourcePreference::Ranked(sources) => write!(f, "{}", sources.join(",")),
+        }
+    }
+}
+
+impl SourcePreference {
+    /// Parst "earliest" oder eine kommagetrennte Rangfolge von Quellen (z.B. "gossip,http")
+    pub fn parse(value: &str) -> Option<Self> {
+        let value = value.trim().to_ascii_lowercase();
+        if value == "earliest" || value == "first" {
+            return Some(SourcePreference::Earliest);
+        }
+        let sources: Vec<String> = value.split(',').map(|source| source.trim().to_string()).collect();
+        let valid = |source: &String| {
+            !source.is_empty() && source.chars().all(|c| c.is_ascii_alphanumeric() || c == '_' || c == '-')
+        };
+        sources.iter().all(valid).then_some(SourcePreference::Ranked(sources))
+    }
+
+    /// Rang einer Quelle (kleiner = bevorzugt)
+    fn rank(&self, source: &str) -> usize {
+        match self {
+            SourcePreference::Earliest => 0,
+            SourcePreference::Ranked(sources) => {
+                sources.iter().position(|ranked| ranked == source).unwrap_or(sources.len())
+            }
+        }
+    }
+
+    /// Ob `candidate` die bisher bevorzugte Quelle `current` ablöst (bei gleichem Rang nicht)
+    pub fn prefers(&self, candidate: &str, current: &str) -> bool {
+        self.rank(candidate) < self.rank(current)
+    }
+}
+
+/// "sources" eines neu gespeicherten Blocks
+pub fn sources(source: &str, received_unix: u64) -> serde_json::Value {
+    serde_json::json!([{ "source": source, "received_unix": received_unix }])
+}
+
+/// Ergänzt eine weitere Quelle in den Metadaten eines gespeicherten Blocks und setzt "source" auf die bevorzugte;
+/// false, wenn die Quelle schon vermerkt ist (Metadaten unverändert)
+pub fn merge(
+    metadata: &mut serde_json::Value,
+    source: &str,
+    received_unix: u64,
+    preference: &SourcePreference,
+) -> bool {
+    // Metadaten älterer Versionen: bisherige Quelle übernehmen
+    if !metadata["sources"].is_array() {
+        let first = metadata["source"].as_str().unwrap_or("unknown").to_string();
+        metadata["sources"] = sources(&first, metadata["received_unix"].as_u64().unwrap_or(0));
+    }
+    let Some(entries) = metadata["sources"].as_array_mut() else {
+        return false;
+    };
+    if entries.iter().any(|entry| entry["source"] == source) {
+        return false;
+    }
+    entries.push(serde_json::json!({ "source": source, "received_unix": received_unix }));
+
+    if preference.prefers(source, metadata["source"].as_str().unwrap_or_default()) {
+        metadata["source"] = serde_json::json!(source);
+    }
+    true
+}
@@ -0,0 +1,195 @@
+// publish.rs - Veröffentlichung gespeicherter Preconfs über NATS oder Kafka
+//
+// Jeder Preconf wird als JSON-Nachricht (Metadaten + Referenz auf die .raw Datei, optional der
+// Container inline als Base64) publiziert, damit Indexer und Alerting nicht das Dateisystem scannen.
+//   - NATS: minimaler Client über TCP (CONNECT/PUB, PING/PONG), Subject pro Chain
+//   - Kafka: über den Kafka REST Proxy (POST /topics/{topic}), Key = Blocknummer
+// Beide Clients verbinden bei Fehlern neu; die Auslieferung ist at-most-once pro Versuch.
+
+use crate::{settings::PublishSettings, sink::StoredPreconf};
+use base64::{engine::general_purpose::STANDARD as BASE64, Engine};
+use std::{
+    sync::{Arc, Mutex},
+    time::Duration,
+};
+use tokio::{
+    io::{AsyncBufReadExt, AsyncWriteExt, BufReader},
+    net::TcpStream,
+    sync::mpsc,
+    time::{sleep, timeout},
+};
+use tracing::{debug, info, warn};
+
+const PUBLISH_ATTEMPTS: u32 = 3;
+const CONNECT_TIMEOUT: Duration = Duration::from_secs(5);
+
+/// Ziel der Veröffentlichung
+#[derive(Debug, Clone)]
+pub enum PublishTarget {
+    Nats { url: String, subject: String },
+    Kafka { rest_url: String, topic: String },
+}
+
+impl std::fmt::Display for PublishTarget {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        match self {
+            PublishTarget::Nats { url, subject } => write!(f, "nats {} ({})", url, subject),
+            PublishTarget::Kafka { rest_url, topic } => write!(f, "kafka {} ({})", rest_url, topic),
+        }
+    }
+}
+
+/// Nachricht für einen Preconf: Metadaten plus Payload-Referenz bzw. Payload inline
+pub fn preconf_message(preconf: &StoredPreconf, inline: bool) -> serde_json::Value {
+    let mut message = preconf.metadata.clone();
+    message["payload_ref"] = serde_json::json!(preconf.raw_filename);
+    if inline {
+        message["payload"] = serde_json::json!(BASE64.encode(&preconf.raw_data));
+    }
+    message
+}
+
+/// Minimaler NATS-Publisher
+struct NatsPublisher {
+    url: String,
+    connection: Option<BufReader<TcpStream>>,
+}
+
+impl NatsPublisher {
+    /// nats://host:port bzw. host:port
+    fn address(&self) -> String {
+        let address = self.url.trim_start_matches("nats://").trim_end_matches('/');
+        if address.contains(':') {
+            address.to_string()
+        } else {
+            format!("{}:4222", address)
+        }
+    }
+
+    async fn connect(&self) -> Result<BufReader<TcpStream>, Box<dyn std::error::Error + Send + Sync>> {
+        let stream = timeout(CONNECT_TIMEOUT, TcpStream::connect(self.address())).await??;
+        let mut connection = BufReader::new(stream);
+
+        // Server beginnt mit INFO {...}
+        let mut line = String::new();
+        timeout(CONNECT_TIMEOUT, connection.read_line(&mut line)).await??;
+        if !line.starts_with("INFO") {
+            return Err(format!("unexpected NATS greeting: {}", line.trim()).into());
+        }
+        connection
+            .get_mut()
+            .write_all(b"CONNECT {\"verbose\":false,\"pedantic\":false,\"name\":\"kona-bridge\"}\r\nPING\r\n")
+            .await?;
+        self.expect_pong(&mut connection).await?;
+        Ok(connection)
+    }
+
+    /// Liest bis PONG (INFO-Updates werden übersprungen, -ERR bricht ab)
+    async fn expect_pong(&self, connection: &mut BufReader<TcpStream>) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        loop {
+            let mut line = String::new();
+            if timeout(CONNECT_TIMEOUT, connection.read_line(&mut line)).await?? == 0 {
+                return Err("NATS connection closed".into());
+            }
+            match line.trim() {
+                "PONG" => return Ok(()),
+                "PING" => connection.get_mut().write_all(b"PONG\r\n").await?,
+                l if l.starts_with("-ERR") => return Err(format!("NATS error: {}", l).into()),
+                _ => {}
+            }
+        }
+    }
+
+    /// Publiziert und wartet per PING/PONG auf die Bestätigung des Servers
+    async fn publish(&mut self, subject: &str, payload: &[u8]) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        if self.connection.is_none() {
+            self.connection = Some(self.connect().await?);
+        }
+        let mut connection = self.connection.take().ok_or("not connected")?;
+
+        let mut frame = format!("PUB {} {}\r\n", subject, payload.len()).into_bytes();
+        frame.extend_from_slice(payload);
+        frame.extend_from_slice(b"\r\nPING\r\n");
+        connection.get_mut().write_all(&frame).await?;
+        self.expect_pong(&mut connection).await?;
+
+        // Nur eine funktionierende Verbindung wird wiederverwendet
+        self.connection = Some(connection);
+        Ok(())
+    }
+}
+
+/// Kafka über den REST Proxy (v2 API)
+async fn kafka_publish(
+    client: &reqwest::Client,
+    rest_url: &str,
+    topic: &str,
+    key: &str,
+    message: &serde_json::Value,
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let body = serde_json::json!({ "records": [{ "key": key, "value": message }] });
+    let response = client
+        .post(format!("{}/topics/{}", rest_url.trim_end_matches('/'), topic))
+        .header("content-type", "application/vnd.kafka.json.v2+json")
+        .body(body.to_string())
+        .send()
+        .await?;
+    if !response.status().is_success() {
+        return Err(format!("HTTP {}: {}", response.status(), response.text().await.unwrap_or_default()).into());
+    }
+    Ok(())
+}
+
+/// Sink-Task: publiziert jeden gespeicherten Preconf an NATS bzw. Kafka
+pub async fn run_publish_sink(
+    settings: PublishSettings,
+    mut receiver: mpsc::Receiver<Arc<StoredPreconf>>,
+    running: Arc<Mutex<bool>>,
+) {
+    let http = reqwest::Client::new();
+    let mut nats = match settings.target {
+        PublishTarget::Nats { ref url, .. } => Some(NatsPublisher {
+            url: url.clone(),
+            connection: None,
+        }),
+        PublishTarget::Kafka { .. } => None,
+    };
+
+    while let Some(preconf) = receiver.recv().await {
+        if !*running.lock().unwrap() {
+            break;
+        }
+
+        let message = preconf_message(&preconf, settings.inline_payload);
+        let mut attempt = 1;
+        loop {
+            let result = match (&settings.target, nats.as_mut()) {
+                (PublishTarget::Nats { subject, .. }, Some(nats)) => {
+                    let subject = subject.replace("{chain_id}", &preconf.chain_id.to_string());
+                    nats.publish(&subject, message.to_string().as_bytes()).await
+                }
+                (PublishTarget::Kafka { rest_url, topic }, _) => {
+                    kafka_publish(&http, rest_url, topic, &preconf.block_number.to_string(), &message).await
+                }
+                _ => Err("publisher not initialized".into()),
+            };
+            match result {
+                Ok(()) => {
+                    debug!("📣 Published block {}", preconf.block_number);
+                    break;
+                }
+                Err(e) if attempt < PUBLISH_ATTEMPTS => {
+                    debug!("📣 Publish of block {} failed (attempt {}): {}", preconf.block_number, attempt, e);
+                    sleep(Duration::from_millis(500 * attempt as u64)).await;
+                    attempt += 1;
+                }
+                Err(e) => {
+                    warn!("⚠️  Publish: giving up on block {} after {} attempts: {}", preconf.block_number, attempt, e);
+                    break;
+                }
+            }
+        }
+    }
+
+    info!("🛑 Publish sink stopped");
+}
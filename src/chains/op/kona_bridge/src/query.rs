@@ -0,0 +1,221 @@
+// query.rs - Unix-Domain-Socket für Preconf-Lookups des C-Servers
+//
+// Kleines Request/Response-Protokoll mit Längenpräfix (siehe server/preconf_socket.h),
+// damit der C-Server Preconfs ohne Dateisystem-Polling abfragen kann und das Dateilayout
+// der Bridge sich ändern darf, ohne die C-Seite zu brechen.
+//
+// Frame:    u32 Länge (big-endian, ohne die 4 Bytes selbst) + Payload
+// Request:  u8 op + Argument
+//             0x01 latest, 0x02 pre_latest, 0x03 by number (u64 BE), 0x04 by hash ([u8; 32]),
+//             0x05 by tx hash ([u8; 32]) -> Body = Blocknummer (u64 BE) + Transaktionsindex (u32 BE)
+//             0x06 by address ([u8; 20]) -> Body = n x (Blocknummer u64 BE + Transaktionsindex u32 BE),
+//                  neueste zuerst (nur mit KONA_BRIDGE_ADDRESS_INDEX)
+//             0x07 by timestamp, nächster Block (u64 BE unix), 0x08 by timestamp, neuester Block <= Zeit (u64 BE)
+//             0x09 stats -> Body = Speicher-Statistiken als JSON (siehe stats.rs)
+//             0x0a render by number (u64 BE) -> Body = Block als JSON im Format von eth_getBlockByNumber
+// Response: u8 status + Body
+//             0x00 ok (Body = zstd-Payload + Signatur, wie vom Prover erwartet), 0x01 not found,
+//             0x02 bad request, 0x03 internal error (Body = Fehlermeldung)
+
+use crate::{codec::to_legacy, handoff, perms, render::render_raw, storage::PreconfStore};
+use std::{
+    path::Path,
+    sync::{Arc, Mutex},
+    time::Duration,
+};
+use tokio::{
+    io::{AsyncReadExt, AsyncWriteExt},
+    net::UnixStream,
+    time::timeout,
+};
+use tracing::{debug, info, warn};
+
+pub const QUERY_SOCKET_FILE_NAME: &str = "preconf.sock";
+
+pub const OP_LATEST: u8 = 0x01;
+pub const OP_PRE_LATEST: u8 = 0x02;
+pub const OP_BY_NUMBER: u8 = 0x03;
+pub const OP_BY_HASH: u8 = 0x04;
+pub const OP_BY_TX_HASH: u8 = 0x05;
+pub const OP_BY_ADDRESS: u8 = 0x06;
+pub const OP_BY_TIMESTAMP: u8 = 0x07;
+pub const OP_AT_OR_BEFORE: u8 = 0x08;
+pub const OP_STATS: u8 = 0x09;
+pub const OP_RENDER: u8 = 0x0a;
+
+pub const STATUS_OK: u8 = 0x00;
+pub const STATUS_NOT_FOUND: u8 = 0x01;
+pub const STATUS_BAD_REQUEST: u8 = 0x02;
+pub const STATUS_ERROR: u8 = 0x03;
+
+/// Requests sind winzig, alles darüber ist ein Protokollfehler
+const MAX_REQUEST_SIZE: usize = 64;
+/// Verbindungen ohne Request werden nach dieser Zeit geschlossen
+const IDLE_TIMEOUT: Duration = Duration::from_secs(60);
+
+/// Bindet den Socket und beantwortet Anfragen, bis `running` false wird
+pub async fn run_query_socket(
+    path: &Path,
+    store: Arc<PreconfStore>,
+    running: Arc<Mutex<bool>>,
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    // Vom Vorgänger übernehmen (siehe handoff.rs) oder neu binden, ein verwaister Socket wird ersetzt
+    let listener = handoff::bind_unix(path)?;
+    perms::apply_socket(path);
+    info!("🔌 Query socket listening on {:?}", path);
+
+    while *running.lock().unwrap() {
+        let stream = match timeout(Duration::from_secs(1), listener.accept()).await {
+            Ok(Ok((stream, _))) => stream,
+            Ok(Err(e)) => {
+                warn!("⚠️  Query socket accept failed: {}", e);
+                continue;
+            }
+            Err(_) => continue, // Timeout -> running erneut prüfen
+        };
+
+        let store = store.clone();
+        tokio::spawn(async move {
+            if let Err(e) = handle_connection(stream, store).await {
+                debug!("🔌 Query connection closed: {}", e);
+            }
+        });
+    }
+
+    // Nach einem Upgrade nimmt der Nachfolger über denselben Pfad weiter an
+    handoff::release_unix(path);
+    if !handoff::is_handed_over() {
+        let _ = std::fs::remove_file(path);
+    }
+    info!("🛑 Query socket stopped");
+    Ok(())
+}
+
+async fn handle_connection(
+    mut stream: UnixStream,
+    store: Arc<PreconfStore>,
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    loop {
+        let mut len_buf = [0u8; 4];
+        match timeout(IDLE_TIMEOUT, stream.read_exact(&mut len_buf)).await {
+            Ok(Ok(_)) => {}
+            Ok(Err(e)) if e.kind() == std::io::ErrorKind::UnexpectedEof => return Ok(()),
+            Ok(Err(e)) => return Err(e.into()),
+            Err(_) => return Ok(()), // idle
+        }
+
+        let len = u32::from_be_bytes(len_buf) as usize;
+        if len == 0 || len > MAX_REQUEST_SIZE {
+            write_response(&mut stream, STATUS_BAD_REQUEST, b"invalid request length").await?;
+            return Ok(());
+        }
+
+        let mut request = vec![0u8; len];
+        stream.read_exact(&mut request).await?;
+
+        let (status, body) = answer(&store, &request).await;
+        write_response(&mut stream, status, &body).await?;
+    }
+}
+
+/// Beantwortet einen einzelnen Request
+async fn answer(store: &PreconfStore, request: &[u8]) -> (u8, Vec<u8>) {
+    let filename = match (request[0], &request[1..]) {
+        (OP_LATEST, []) => store.latest_filename(0),
+        (OP_PRE_LATEST, []) => store.latest_filename(1),
+        (OP_BY_NUMBER, arg) if arg.len() == 8 => {
+            let mut number = [0u8; 8];
+            number.copy_from_slice(arg);
+            store.filename_by_number(u64::from_be_bytes(number))
+        }
+        (op @ (OP_BY_TIMESTAMP | OP_AT_OR_BEFORE), arg) if arg.len() == 8 => {
+            let mut timestamp = [0u8; 8];
+            timestamp.copy_from_slice(arg);
+            store.filename_by_timestamp(u64::from_be_bytes(timestamp), op == OP_BY_TIMESTAMP)
+        }
+        (OP_BY_TX_HASH, arg) if arg.len() == 32 => {
+            let mut hash = [0u8; 32];
+            hash.copy_from_slice(arg);
+            return match store.tx_location(&hash) {
+                Some(location) => {
+                    let mut body = location.block_number.to_be_bytes().to_vec();
+                    body.extend_from_slice(&location.tx_index.to_be_bytes());
+                    (STATUS_OK, body)
+                }
+                None => (STATUS_NOT_FOUND, Vec::new()),
+            };
+        }
+        (OP_STATS, []) => {
+            let stats = store.storage_stats();
+            return match tokio::task::spawn_blocking(move || serde_json::to_vec(&stats.snapshot())).await {
+                Ok(Ok(body)) => (STATUS_OK, body),
+                Ok(Err(e)) => (STATUS_ERROR, e.to_string().into_bytes()),
+                Err(e) => (STATUS_ERROR, e.to_string().into_bytes()),
+            };
+        }
+        (OP_RENDER, arg) if arg.len() == 8 => {
+            let mut number = [0u8; 8];
+            number.copy_from_slice(arg);
+            let Some(filename) = store.filename_by_number(u64::from_be_bytes(number)) else {
+                return (STATUS_NOT_FOUND, Vec::new());
+            };
+            return match store.read_raw(&filename).await {
+                Ok(data) => match tokio::task::spawn_blocking(move || render_raw(&data)).await {
+                    Ok(Ok(block)) => (STATUS_OK, block.to_string().into_bytes()),
+                    Ok(Err(e)) => (STATUS_ERROR, e.to_string().into_bytes()),
+                    Err(e) => (STATUS_ERROR, e.to_string().into_bytes()),
+                },
+                Err(e) if e.kind() == std::io::ErrorKind::NotFound => (STATUS_NOT_FOUND, Vec::new()),
+                Err(e) => (STATUS_ERROR, e.to_string().into_bytes()),
+            };
+        }
+        (OP_BY_ADDRESS, arg) if arg.len() == 20 => {
+            let mut address = [0u8; 20];
+            address.copy_from_slice(arg);
+            let locations = store.address_locations(&address);
+            if locations.is_empty() {
+                return (STATUS_NOT_FOUND, Vec::new());
+            }
+            let mut body = Vec::with_capacity(locations.len() * 12);
+            for location in locations {
+                body.extend_from_slice(&location.block_number.to_be_bytes());
+                body.extend_from_slice(&location.tx_index.to_be_bytes());
+            }
+            return (STATUS_OK, body);
+        }
+        (OP_BY_HASH, arg) if arg.len() == 32 => {
+            let mut hash = [0u8; 32];
+            hash.copy_from_slice(arg);
+            store.filename_by_hash(&hash)
+        }
+        (op, _) => return (STATUS_BAD_REQUEST, format!("unknown request 0x{:02x}", op).into_bytes()),
+    };
+
+    let Some(filename) = filename else {
+        return (STATUS_NOT_FOUND, Vec::new());
+    };
+
+    if let Some(data) = store.hot_get(&filename) {
+        return (STATUS_OK, data.to_vec());
+    }
+
+    match store.read_raw(&filename).await {
+        // Der Prover kennt nur zstd + Signatur, unabhängig vom gespeicherten Codec
+        Ok(data) => match to_legacy(&data, store.chain_id()) {
+            Ok(legacy) => (STATUS_OK, legacy),
+            Err(e) => (STATUS_ERROR, e.to_string().into_bytes()),
+        },
+        // Datei kann zwischen Lookup und Lesen vom TTL-Cleanup entfernt worden sein
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => (STATUS_NOT_FOUND, Vec::new()),
+        Err(e) => (STATUS_ERROR, e.to_string().into_bytes()),
+    }
+}
+
+async fn write_response(stream: &mut UnixStream, status: u8, body: &[u8]) -> std::io::Result<()> {
+    let len = (body.len() + 1) as u32;
+    let mut frame = Vec::with_capacity(body.len() + 5);
+    frame.extend_from_slice(&len.to_be_bytes());
+    frame.push(status);
+    frame.extend_from_slice(body);
+    stream.write_all(&frame).await
+}
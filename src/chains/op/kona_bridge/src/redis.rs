@@ -0,0 +1,206 @@
+// redis.rs - Spiegelung der neuesten Preconfs in Redis für Web-Backends
+//
+// Pro Chain liegen die letzten KONA_BRIDGE_REDIS_KEEP Blöcke unter
+//   {prefix}{chain_id}:{block}       Container (wie .raw)
+//   {prefix}{chain_id}:{block}:meta  Metadaten (JSON)
+//   {prefix}{chain_id}:latest        Nummer des neuesten Blocks
+// Alle Keys laufen mit der TTL der lokalen Speicherung ab; ältere Blöcke werden aktiv gelöscht.
+// Minimaler RESP-Client über TCP (AUTH/SELECT aus der URL), Neuverbindung bei Fehlern; auch für die Lease in ha.rs.
+
+use crate::{settings::RedisSettings, sink::StoredPreconf};
+use std::{
+    sync::{Arc, Mutex},
+    time::Duration,
+};
+use tokio::{
+    io::{AsyncBufReadExt, AsyncReadExt, AsyncWriteExt, BufReader},
+    net::TcpStream,
+    sync::mpsc,
+    time::{sleep, timeout},
+};
+use tracing::{debug, info, warn};
+
+const WRITE_ATTEMPTS: u32 = 3;
+const IO_TIMEOUT: Duration = Duration::from_secs(5);
+
+/// Antwort eines Redis-Befehls (nur die hier benötigten Typen)
+#[derive(Debug)]
+pub(crate) enum Reply {
+    Ok,
+    Integer(i64),
+    Bulk(Option<Vec<u8>>),
+}
+
+/// Verbindungsdaten aus redis://[:password@]host[:port][/db]
+pub(crate) struct RedisTarget {
+    pub address: String,
+    password: Option<String>,
+    db: Option<u32>,
+}
+
+pub(crate) fn parse_url(url: &str) -> RedisTarget {
+    let rest = url.trim_start_matches("redis://");
+    let (credentials, rest) = match rest.rsplit_once('@') {
+        Some((credentials, rest)) => (Some(credentials), rest),
+        None => (None, rest),
+    };
+    let (host, db) = match rest.split_once('/') {
+        Some((host, db)) => (host, db.parse().ok()),
+        None => (rest, None),
+    };
+    RedisTarget {
+        address: if host.contains(':') { host.to_string() } else { format!("{}:6379", host) },
+        // user:password oder :password
+        password: credentials.map(|c| c.rsplit_once(':').map_or(c, |(_, p)| p).to_string()),
+        db,
+    }
+}
+
+pub(crate) struct RedisConnection {
+    stream: BufReader<TcpStream>,
+}
+
+impl RedisConnection {
+    pub async fn open(target: &RedisTarget) -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        let stream = timeout(IO_TIMEOUT, TcpStream::connect(&target.address)).await??;
+        let mut connection = Self {
+            stream: BufReader::new(stream),
+        };
+        if let Some(ref password) = target.password {
+            connection.command(&[b"AUTH", password.as_bytes()]).await?;
+        }
+        if let Some(db) = target.db {
+            connection.command(&[b"SELECT", db.to_string().as_bytes()]).await?;
+        }
+        Ok(connection)
+    }
+
+    /// Sendet einen Befehl als RESP-Array und liest die Antwort
+    pub async fn command(&mut self, args: &[&[u8]]) -> Result<Reply, Box<dyn std::error::Error + Send + Sync>> {
+        let mut frame = format!("*{}\r\n", args.len()).into_bytes();
+        for arg in args {
+            frame.extend_from_slice(format!("${}\r\n", arg.len()).as_bytes());
+            frame.extend_from_slice(arg);
+            frame.extend_from_slice(b"\r\n");
+        }
+        timeout(IO_TIMEOUT, self.stream.get_mut().write_all(&frame)).await??;
+        timeout(IO_TIMEOUT, self.read_reply()).await?
+    }
+
+    async fn read_reply(&mut self) -> Result<Reply, Box<dyn std::error::Error + Send + Sync>> {
+        let mut line = String::new();
+        if self.stream.read_line(&mut line).await? == 0 {
+            return Err("Redis connection closed".into());
+        }
+        let line = line.trim_end();
+        let (kind, value) = line.split_at(1.min(line.len()));
+        match kind {
+            "+" => Ok(Reply::Ok),
+            "-" => Err(format!("Redis error: {}", value).into()),
+            ":" => value.parse::<i64>().map(Reply::Integer).map_err(Into::into),
+            "$" => {
+                let len: i64 = value.parse()?;
+                if len < 0 {
+                    return Ok(Reply::Bulk(None));
+                }
+                let mut data = vec![0u8; len as usize + 2];
+                self.stream.read_exact(&mut data).await?;
+                data.truncate(len as usize);
+                Ok(Reply::Bulk(Some(data)))
+            }
+            _ => Err(format!("unexpected Redis reply: {}", line).into()),
+        }
+    }
+}
+
+/// Schreibt einen Preconf und entfernt den Block, der aus dem Fenster fällt
+async fn write_preconf(
+    connection: &mut RedisConnection,
+    settings: &RedisSettings,
+    preconf: &StoredPreconf,
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let base = format!("{}{}", settings.prefix, preconf.chain_id);
+    let ttl = settings.ttl_secs.to_string();
+    let block_key = format!("{}:{}", base, preconf.block_number);
+    let metadata = preconf.metadata.to_string();
+
+    connection.command(&[b"SET", block_key.as_bytes(), &preconf.raw_data, b"EX", ttl.as_bytes()]).await?;
+    connection
+        .command(&[b"SET", format!("{}:meta", block_key).as_bytes(), metadata.as_bytes(), b"EX", ttl.as_bytes()])
+        .await?;
+
+    // latest nur vorwärts bewegen (ältere Blöcke können nachträglich eintreffen)
+    let latest_key = format!("{}:latest", base);
+    let current = match connection.command(&[b"GET", latest_key.as_bytes()]).await? {
+        Reply::Bulk(Some(value)) => String::from_utf8_lossy(&value).parse::<u64>().ok(),
+        _ => None,
+    };
+    if current.map_or(true, |current| preconf.block_number >= current) {
+        let block_number = preconf.block_number.to_string();
+        connection
+            .command(&[b"SET", latest_key.as_bytes(), block_number.as_bytes(), b"EX", ttl.as_bytes()])
+            .await?;
+    }
+
+    if let Some(evicted) = preconf.block_number.checked_sub(settings.keep_blocks) {
+        let evicted_key = format!("{}:{}", base, evicted);
+        connection
+            .command(&[b"DEL", evicted_key.as_bytes(), format!("{}:meta", evicted_key).as_bytes()])
+            .await?;
+    }
+    Ok(())
+}
+
+/// Sink-Task: spiegelt jeden gespeicherten Preconf nach Redis
+pub async fn run_redis_sink(
+    settings: RedisSettings,
+    mut receiver: mpsc::Receiver<Arc<StoredPreconf>>,
+    running: Arc<Mutex<bool>>,
+) {
+    let target = parse_url(&settings.url);
+    let mut connection: Option<RedisConnection> = None;
+
+    while let Some(preconf) = receiver.recv().await {
+        if !*running.lock().unwrap() {
+            break;
+        }
+
+        let mut attempt = 1;
+        loop {
+            if connection.is_none() {
+                match RedisConnection::open(&target).await {
+                    Ok(c) => {
+                        info!("🟥 Redis: connected to {}", target.address);
+                        connection = Some(c);
+                    }
+                    Err(e) => debug!("🟥 Redis: connect failed (attempt {}): {}", attempt, e),
+                }
+            }
+
+            let result = match connection {
+                Some(ref mut c) => write_preconf(c, &settings, &preconf).await,
+                None => Err("not connected".into()),
+            };
+            match result {
+                Ok(()) => {
+                    debug!("🟥 Redis: stored block {}", preconf.block_number);
+                    break;
+                }
+                Err(e) if attempt < WRITE_ATTEMPTS => {
+                    debug!("🟥 Redis: write of block {} failed (attempt {}): {}", preconf.block_number, attempt, e);
+                    // Verbindung nach Fehlern nicht wiederverwenden (Antworten könnten versetzt sein)
+                    connection = None;
+                    sleep(Duration::from_millis(500 * attempt as u64)).await;
+                    attempt += 1;
+                }
+                Err(e) => {
+                    warn!("⚠️  Redis: giving up on block {} after {} attempts: {}", preconf.block_number, attempt, e);
+                    connection = None;
+                    break;
+                }
+            }
+        }
+    }
+
+    info!("🛑 Redis sink stopped");
+}
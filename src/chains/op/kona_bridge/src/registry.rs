@@ -0,0 +1,71 @@
+// registry.rs - Chain-Daten aus der Superchain-Registry (über kona-registry eingebettet, kein Netzwerkzugriff)
+//
+// Für Chains ohne eigenen Eintrag in config.rs reicht damit die Chain-ID oder der Name aus der Registry
+// (z.B. "mode", "ink", "op-mainnet"):
+//   Unsafe-Signer   roles.UnsafeBlockSigner (fehlt er, hilft KONA_BRIDGE_SIGNER_RPC über SystemConfig, siehe signer.rs)
+//   RPC-Endpoints   public_rpc als Default für KONA_BRIDGE_FINALITY_RPC (Retention safe/finalized)
+//   Hardforks       Aktivierungszeiten, der aktive Hardfork steht beim Start im Log und in `kona_bridge doctor`
+//   Bootnodes       enthält die Registry nicht; Chains auf L1 Mainnet nutzen die Superchain-Bootnodes von OP Mainnet
+// Einträge in config.rs und alle Einstellungen (signers, http_endpoint, finality_rpc, Ports) haben Vorrang.
+// Neue Chains kommen mit einem Update von kona-registry (Cargo.toml) dazu.
+
+use crate::hardfork::HardforkSchedule;
+use alloy::primitives::Address;
+use kona_registry::OPCHAINS;
+
+/// Chain-ID von Ethereum Mainnet (L1 der produktiven Superchain)
+pub const L1_MAINNET: u64 = 1;
+
+/// Auszug aus der Registry für eine Chain
+#[derive(Debug, Clone)]
+pub struct RegistryChain {
+    pub name: String,
+    pub l1_chain_id: u64,
+    pub block_time: u64,
+    pub unsafe_signer: Option<Address>,
+    pub public_rpc: Option<String>,
+    pub hardforks: HardforkSchedule,
+}
+
+fn non_empty(value: &str) -> Option<String> {
+    let value = value.trim();
+    (!value.is_empty()).then(|| value.to_string())
+}
+
+/// Name in der Form von config.rs ("OP Mainnet" -> "op-mainnet")
+fn slug(name: &str) -> String {
+    name.trim().to_ascii_lowercase().split_whitespace().collect::<Vec<_>>().join("-")
+}
+
+/// Registry-Eintrag der Chain (None für Chains außerhalb der Superchain-Registry)
+pub fn lookup(chain_id: u64) -> Option<RegistryChain> {
+    let chain = OPCHAINS.get(&chain_id)?;
+    Some(RegistryChain {
+        name: chain.name.clone(),
+        l1_chain_id: chain.l1_chain_id,
+        block_time: chain.block_time,
+        unsafe_signer: chain.roles.as_ref().and_then(|roles| roles.unsafe_block_signer),
+        public_rpc: non_empty(&chain.public_rpc),
+        hardforks: HardforkSchedule::new(&chain.hardfork_config),
+    })
+}
+
+/// Alle Chain-IDs der Registry (aufsteigend)
+pub fn chain_ids() -> Vec<u64> {
+    let mut chain_ids: Vec<u64> = OPCHAINS.keys().copied().collect();
+    chain_ids.sort_unstable();
+    chain_ids
+}
+
+/// Chain-ID zu einem Namen der Registry ("OP Mainnet", "op-mainnet" und "op mainnet" sind gleichwertig)
+pub fn chain_id_by_name(name: &str) -> Option<u64> {
+    let wanted = slug(name);
+    let mut matches: Vec<u64> =
+        OPCHAINS.iter().filter(|(_, chain)| slug(&chain.name) == wanted).map(|(chain_id, _)| *chain_id).collect();
+    // Gleicher Name auf Mainnet und Testnet: die Mainnet-Chain
+    matches.sort_by_key(|chain_id| {
+        let testnet = OPCHAINS.get(chain_id).map_or(true, |chain| chain.l1_chain_id != L1_MAINNET);
+        (testnet, *chain_id)
+    });
+    matches.first().copied()
+}
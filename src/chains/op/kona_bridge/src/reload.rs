@@ -0,0 +1,167 @@
+// reload.rs - Laufzeit-Konfiguration auf SIGHUP neu laden, ohne Capture oder Gossip-Verbindungen neu zu starten
+//
+// Auf SIGHUP liest jede Chain die Konfigurationsdatei (KONA_BRIDGE_CONFIG, siehe configfile.rs) neu und übernimmt:
+//   http_endpoint                               HTTP-Quelle ab dem nächsten Poll (nur wenn beim Start HTTP aktiv war)
+//   http_poll_interval                          Poll-Intervall in Sekunden ab dem nächsten Poll
+//   signers                                     Signer-Liste (nur wenn beim Start eine Liste aktiv war)
+//   ttl_minutes, quota_mb, retention, finality_rpc   ab dem nächsten Cleanup-Zyklus
+//   log_level                                   EnvFilter-Direktiven (prozessweit, leer = Wert beim Start)
+// Umgebungsvariablen eines laufenden Prozesses ändern sich nicht, neu geladen wird also, was aus der Datei kommt.
+// Jede Änderung steht einzeln im Log. Ports, Sinks, Speicherformat usw. brauchen weiterhin einen Neustart.
+
+use crate::{
+    configfile,
+    retention::Retention,
+    settings::BridgeSettings,
+    signer::{hangup, SignerEntry},
+};
+use std::{
+    fmt::Debug,
+    sync::{Arc, Mutex, OnceLock},
+    time::Duration,
+};
+use tokio::{sync::watch, time::interval};
+use tracing::{info, warn};
+
+/// Ändert die Log-Filter des Prozesses (None = Filter beim Start), gesetzt von kona_bridge_init_logging
+type LogFilterReload = Box<dyn Fn(Option<&str>) -> Result<(), String> + Send + Sync>;
+
+static LOG_FILTER: OnceLock<LogFilterReload> = OnceLock::new();
+
+pub fn register_log_filter(reload: LogFilterReload) {
+    let _ = LOG_FILTER.set(reload);
+}
+
+/// Werte, die im Betrieb neu geladen werden können
+#[derive(Debug, Clone, PartialEq)]
+pub struct RuntimeConfig {
+    pub http_endpoint: Option<String>,
+    pub http_poll_interval: u64,
+    pub signers: Vec<SignerEntry>,
+    pub ttl_minutes: u64,
+    pub quota_bytes: Option<u64>,
+    pub retention: Retention,
+    pub finality_rpc: Option<String>,
+    pub log_level: Option<String>,
+}
+
+/// Werte aus KonaBridgeConfig bzw. der Chain-Konfiguration, falls nichts anderes eingestellt ist
+#[derive(Debug, Clone)]
+pub struct RuntimeDefaults {
+    pub http_endpoint: Option<String>,
+    pub http_poll_interval: u64,
+    pub ttl_minutes: u64,
+    pub finality_rpc: Option<String>, // public_rpc aus der Superchain-Registry
+}
+
+impl RuntimeConfig {
+    pub fn new(settings: &BridgeSettings, defaults: &RuntimeDefaults) -> Self {
+        Self {
+            http_endpoint: settings.http_endpoint.clone().or_else(|| defaults.http_endpoint.clone()),
+            http_poll_interval: settings.http_poll_interval.unwrap_or(defaults.http_poll_interval).max(1),
+            signers: settings.signers.clone(),
+            ttl_minutes: settings.ttl_minutes.unwrap_or(defaults.ttl_minutes),
+            quota_bytes: settings.quota_bytes,
+            retention: settings.retention,
+            finality_rpc: settings.finality_rpc.clone().or_else(|| defaults.finality_rpc.clone()),
+            log_level: settings.log_level.clone(),
+        }
+    }
+
+    /// Geänderte Werte als "name: alt -> neu"
+    fn changes(&self, new: &Self) -> Vec<String> {
+        fn change<T: Debug + PartialEq>(changes: &mut Vec<String>, name: &str, old: &T, new: &T) {
+            if old != new {
+                changes.push(format!("{}: {:?} -> {:?}", name, old, new));
+            }
+        }
+        let signers =
+            |entries: &[SignerEntry]| entries.iter().map(|entry| format!("{}", entry.address)).collect::<Vec<_>>();
+
+        let mut changes = Vec::new();
+        change(&mut changes, "http_endpoint", &self.http_endpoint, &new.http_endpoint);
+        change(&mut changes, "http_poll_interval", &self.http_poll_interval, &new.http_poll_interval);
+        if self.signers != new.signers {
+            changes.push(format!("signers: {:?} -> {:?}", signers(&self.signers), signers(&new.signers)));
+        }
+        change(&mut changes, "ttl_minutes", &self.ttl_minutes, &new.ttl_minutes);
+        change(&mut changes, "quota_bytes", &self.quota_bytes, &new.quota_bytes);
+        change(&mut changes, "retention", &self.retention, &new.retention);
+        change(&mut changes, "finality_rpc", &self.finality_rpc, &new.finality_rpc);
+        change(&mut changes, "log_level", &self.log_level, &new.log_level);
+        changes
+    }
+}
+
+/// Lädt die Konfiguration der Chain auf SIGHUP neu; Abnehmer lesen den aktuellen Stand aus dem Receiver
+pub fn start_config_reload(
+    chain_id: u64,
+    initial: RuntimeConfig,
+    defaults: RuntimeDefaults,
+    running: Arc<Mutex<bool>>,
+) -> watch::Receiver<RuntimeConfig> {
+    let (sender, receiver) = watch::channel(initial);
+
+    tokio::spawn(async move {
+        let mut signal = tokio::signal::unix::signal(tokio::signal::unix::SignalKind::hangup())
+            .map_err(|e| warn!("⚠️  SIGHUP reload of the configuration disabled: {}", e))
+            .ok();
+        let mut ticker = interval(Duration::from_secs(1));
+        loop {
+            let by_signal = tokio::select! {
+                _ = ticker.tick() => false,
+                _ = hangup(&mut signal) => true,
+            };
+            if !*running.lock().unwrap() {
+                break;
+            }
+            if !by_signal {
+                continue;
+            }
+
+            configfile::reload();
+            let config = RuntimeConfig::new(&BridgeSettings::from_env(chain_id), &defaults);
+            let changes = sender.borrow().changes(&config);
+            if changes.is_empty() {
+                info!("🔄 SIGHUP: configuration of chain {} unchanged", chain_id);
+                continue;
+            }
+            for change in &changes {
+                info!("🔄 Chain {} reloaded {}", chain_id, change);
+            }
+            if config.log_level != sender.borrow().log_level {
+                match LOG_FILTER.get() {
+                    Some(reload) => {
+                        if let Err(e) = reload(config.log_level.as_deref()) {
+                            warn!("⚠️  Cannot apply log level {:?}: {}", config.log_level, e);
+                        }
+                    }
+                    None => warn!("⚠️  Log level cannot be changed, logging was not set up by the bridge"),
+                }
+            }
+            if sender.send(config).is_err() {
+                break;
+            }
+        }
+    });
+    receiver
+}
+
+/// Signer-Liste aus der Laufzeit-Konfiguration (für SignerSet und die Signer-Datei)
+pub fn signer_updates(
+    mut runtime: watch::Receiver<RuntimeConfig>,
+    running: Arc<Mutex<bool>>,
+) -> watch::Receiver<Vec<SignerEntry>> {
+    let (sender, receiver) = watch::channel(runtime.borrow_and_update().signers.clone());
+    tokio::spawn(async move {
+        while runtime.changed().await.is_ok() && *running.lock().unwrap() {
+            let signers = runtime.borrow_and_update().signers.clone();
+            sender.send_if_modified(|current| {
+                let modified = *current != signers;
+                *current = signers;
+                modified
+            });
+        }
+    });
+    receiver
+}
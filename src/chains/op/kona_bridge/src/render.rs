@@ -0,0 +1,96 @@
+// render.rs - JSON-Darstellung eines Preconfs im Format von eth_getBlockByNumber
+//
+// Für Menschen und Tools ohne SSZ-Support: Header-Felder als Hex-Quantities, Transaktionen als
+// Hashes (wie eth_getBlockByNumber(.., false)), zusätzlich die Signatur des Sequencers.
+// Mit KONA_BRIDGE_RENDER_JSON=1 wird pro Block block_{chain_id}_{block}.eth.json geschrieben,
+// unabhängig davon rendert der Query-Socket (0x0a) auf Anfrage aus der .raw Datei.
+
+use crate::{
+    blockhash::{transactions_root, withdrawals_root, EMPTY_OMMERS_HASH, EMPTY_REQUESTS_HASH},
+    codec::PreconfContainer,
+    ssz::{decode_envelope, PayloadEnvelope, PayloadVersion},
+};
+use alloy::primitives::keccak256;
+
+/// Endung der gerenderten Datei (block_{chain_id}_{block}.eth.json)
+pub const RENDERED_SUFFIX: &str = ".eth.json";
+
+fn hex_data(data: &[u8]) -> serde_json::Value {
+    serde_json::json!(format!("0x{}", hex::encode(data)))
+}
+
+fn quantity(value: u64) -> serde_json::Value {
+    serde_json::json!(format!("0x{:x}", value))
+}
+
+/// uint256 little-endian als Hex-Quantity
+fn quantity_u256(le: &[u8; 32]) -> serde_json::Value {
+    let mut be = *le;
+    be.reverse();
+    let digits = hex::encode(be);
+    let trimmed = digits.trim_start_matches('0');
+    serde_json::json!(format!("0x{}", if trimmed.is_empty() { "0" } else { trimmed }))
+}
+
+/// Block im Format von eth_getBlockByNumber
+pub fn render_block(envelope: &PayloadEnvelope, signature: Option<&[u8]>) -> serde_json::Value {
+    let p = &envelope.payload;
+    let mut block = serde_json::json!({
+        "number": quantity(p.block_number),
+        "hash": hex_data(&p.block_hash),
+        "parentHash": hex_data(&p.parent_hash),
+        "sha3Uncles": hex_data(&EMPTY_OMMERS_HASH),
+        "miner": hex_data(&p.fee_recipient),
+        "stateRoot": hex_data(&p.state_root),
+        "transactionsRoot": hex_data(&transactions_root(p)),
+        "receiptsRoot": hex_data(&p.receipts_root),
+        "logsBloom": hex_data(p.logs_bloom),
+        "difficulty": "0x0",
+        "gasLimit": quantity(p.gas_limit),
+        "gasUsed": quantity(p.gas_used),
+        "timestamp": quantity(p.timestamp),
+        "extraData": hex_data(p.extra_data),
+        "mixHash": hex_data(&p.prev_randao),
+        "nonce": "0x0000000000000000",
+        "baseFeePerGas": quantity_u256(&p.base_fee_per_gas),
+        "uncles": [],
+        "transactions": p.transactions.iter().map(|tx| hex_data(keccak256(tx).as_slice())).collect::<Vec<_>>(),
+        "payloadVersion": p.version.name(),
+    });
+
+    if let Some(root) = p.withdrawals_root.or_else(|| withdrawals_root(p)) {
+        block["withdrawalsRoot"] = hex_data(&root);
+        block["withdrawals"] = p
+            .withdrawals()
+            .iter()
+            .map(|w| {
+                serde_json::json!({
+                    "index": quantity(w.index),
+                    "validatorIndex": quantity(w.validator_index),
+                    "address": hex_data(&w.address),
+                    "amount": quantity(w.amount),
+                })
+            })
+            .collect();
+    }
+    if let (Some(blob_gas_used), Some(excess_blob_gas)) = (p.blob_gas_used, p.excess_blob_gas) {
+        block["blobGasUsed"] = quantity(blob_gas_used);
+        block["excessBlobGas"] = quantity(excess_blob_gas);
+        block["parentBeaconBlockRoot"] = hex_data(&envelope.parent_beacon_block_root);
+    }
+    if p.version == PayloadVersion::V4 {
+        block["requestsHash"] = hex_data(&EMPTY_REQUESTS_HASH);
+    }
+    if let Some(signature) = signature {
+        block["sequencerSignature"] = hex_data(signature);
+    }
+    block
+}
+
+/// Rendert eine gespeicherte .raw Datei (Container oder ältere Formate)
+pub fn render_raw(data: &[u8]) -> Result<serde_json::Value, Box<dyn std::error::Error + Send + Sync>> {
+    let container = PreconfContainer::parse(data)?;
+    let payload = container.payload()?;
+    let envelope = decode_envelope(&payload)?;
+    Ok(render_block(&envelope, Some(container.signature)))
+}
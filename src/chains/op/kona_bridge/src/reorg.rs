@@ -0,0 +1,112 @@
+// reorg.rs - Reorgs unter den gespeicherten Preconfs (gleiche Höhe, anderer Hash)
+//
+// Kommt für eine gespeicherte Höhe ein Block mit anderem Hash, wird die bisherige Datei nicht still überschrieben:
+// sie wandert nach superseded/block_{chain}_{n}_{hash}.raw, daneben ein .json mit ihren Metadaten sowie
+// superseded_by und superseded_unix. Das Ereignis mit beiden Hashes geht als "reorg" an die Hooks, zählt als reorgs
+// in den Speicher-Statistiken und steht im Verlauf der HTTP-API (GET /v1/chains/{chain_id}/reorgs, nur im Speicher).
+// Nach der Gnadenfrist (KONA_BRIDGE_REORG_GRACE_SECS, Default 1 h) entfernt der Cleanup-Task die verdrängten Dateien;
+// mit 0 wird der Block wie bisher überschrieben, Ereignis und Zähler bleiben.
+
+use crate::{delta, perms};
+use std::{
+    collections::VecDeque,
+    fs::{self, File},
+    io::Write,
+    path::Path,
+    time::SystemTime,
+};
+use tracing::warn;
+
+/// Unterverzeichnis für verdrängte Blöcke (nicht im Index, läuft nach der Gnadenfrist ab)
+pub const SUPERSEDED_DIR_NAME: &str = "superseded";
+
+pub const DEFAULT_REORG_GRACE_SECS: u64 = 3600;
+
+/// Ereignisse im Verlauf der HTTP-API je Chain
+const HISTORY_SIZE: usize = 256;
+
+/// Ein erkannter Reorg
+#[derive(Debug, Clone, serde::Serialize)]
+pub struct ReorgEvent {
+    pub block_number: u64,
+    pub previous_block_hash: String,
+    pub block_hash: String,
+    pub previous_source: Option<String>, // aus den Metadaten des verdrängten Blocks, falls vorhanden
+    pub source: String,
+    pub previous_received_unix: u64,
+    pub detected_unix: u64,
+    pub superseded_file: Option<String>, // unter superseded/ (None ohne Gnadenfrist oder Datei)
+}
+
+impl ReorgEvent {
+    /// Details des Hook-Ereignisses (Block und neuer Hash stehen schon im Ereignis selbst)
+    pub fn hook_details(&self) -> serde_json::Value {
+        serde_json::json!({
+            "previous_block_hash": self.previous_block_hash,
+            "previous_source": self.previous_source,
+            "previous_received_unix": self.previous_received_unix,
+            "source": self.source,
+            "superseded_file": self.superseded_file,
+        })
+    }
+}
+
+/// Die letzten Reorgs einer Chain
+#[derive(Default)]
+pub struct ReorgHistory {
+    events: VecDeque<ReorgEvent>,
+}
+
+impl ReorgHistory {
+    pub fn record(&mut self, event: ReorgEvent) {
+        if self.events.len() == HISTORY_SIZE {
+            self.events.pop_front();
+        }
+        self.events.push_back(event);
+    }
+
+    /// Ereignisse ab `since` (detected_unix), neueste zuerst
+    pub fn list(&self, since: u64, limit: usize) -> Vec<ReorgEvent> {
+        self.events
+            .iter()
+            .rev()
+            .filter(|event| event.detected_unix >= since)
+            .take(limit)
+            .cloned()
+            .collect()
+    }
+}
+
+/// Dateiname des verdrängten Blocks (die ersten 8 Bytes des Hashes unterscheiden mehrere Reorgs derselben Höhe)
+pub fn superseded_filename(chain_id: u64, block_number: u64, block_hash: &[u8; 32]) -> String {
+    format!("block_{}_{}_{}.raw", chain_id, block_number, hex::encode(&block_hash[..8]))
+}
+
+/// Verschiebt die .raw Datei `file` als `name` nach superseded/ und legt `marker` als .json daneben; ein Delta wird
+/// dabei zum Keyframe (seine Basis kann vor ihm ablaufen). Die mtime ist danach der Zeitpunkt des Reorgs, ab dem die
+/// Gnadenfrist läuft. Die übrigen Dateien der Höhe (.json, .c4, gerendertes JSON) überschreibt der neue Block.
+pub fn keep_superseded(
+    output_dir: &Path,
+    file: &str,
+    name: &str,
+    marker: &serde_json::Value,
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let dir = output_dir.join(SUPERSEDED_DIR_NAME);
+    perms::create_dir_all(&dir)?;
+    let path = dir.join(name);
+    fs::rename(output_dir.join(file), &path)?;
+
+    let meta_path = path.with_extension("json");
+    let temp = meta_path.with_extension("json.tmp");
+    let mut meta = File::create(&temp)?;
+    meta.write_all(&serde_json::to_vec_pretty(marker)?)?;
+    meta.sync_all()?;
+    perms::apply_file(&temp);
+    fs::rename(&temp, &meta_path)?;
+
+    if let Err(e) = delta::rewrite_as_keyframe(&path) {
+        warn!("⚠️  Superseded block {} stays a delta: {}", name, e);
+    }
+    File::options().write(true).open(&path)?.set_modified(SystemTime::now())?;
+    Ok(())
+}
@@ -0,0 +1,196 @@
+// retention.rs - Finality-basierte Retention (statt Wall-Clock-TTL)
+//
+// Ein Preconf ist nur nützlich, solange sein Block noch unsafe ist. Im Modus safe/finalized
+// fragt der Cleanup-Task den Safe- bzw. Finalized-Head über RPC ab und löscht alle Blöcke
+// bis einschließlich dieses Heads; unsafe Blöcke bleiben unabhängig vom Alter erhalten.
+//
+// Unterstützt werden ein Rollup-Node (optimism_syncStatus) und ein L2-Execution-Client
+// (eth_getBlockByNumber mit "safe"/"finalized"); welcher vorliegt, wird beim ersten Aufruf erkannt.
+
+use std::{
+    path::{Path, PathBuf},
+    sync::atomic::{AtomicU8, Ordering},
+    time::Duration,
+};
+use tokio::fs as tokio_fs;
+use tracing::{debug, info, warn};
+
+use crate::{delta, utils::block_number_from_filename};
+
+/// Welcher Head das Ende der Retention bestimmt
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum Retention {
+    #[default]
+    Ttl,
+    Safe,
+    Finalized,
+}
+
+impl Retention {
+    /// Parst "ttl", "safe" oder "finalized"
+    pub fn parse(value: &str) -> Option<Self> {
+        match value.trim().to_ascii_lowercase().as_str() {
+            "ttl" | "" => Some(Retention::Ttl),
+            "safe" => Some(Retention::Safe),
+            "finalized" | "final" => Some(Retention::Finalized),
+            _ => None,
+        }
+    }
+
+    fn tag(&self) -> &'static str {
+        match self {
+            Retention::Finalized => "finalized",
+            _ => "safe",
+        }
+    }
+}
+
+const RPC_UNKNOWN: u8 = 0;
+const RPC_ROLLUP: u8 = 1;
+const RPC_EXECUTION: u8 = 2;
+
+/// Fragt den Safe-/Finalized-Head einer Chain ab
+pub struct FinalityRetention {
+    retention: Retention,
+    rpc_url: String,
+    client: reqwest::Client,
+    rpc_kind: AtomicU8,
+}
+
+impl FinalityRetention {
+    /// Finality-Retention aus den Einstellungen (None = TTL, auch wenn der RPC fehlt)
+    pub fn configured(retention: Retention, rpc_url: Option<String>) -> Option<Self> {
+        match (retention, rpc_url) {
+            (Retention::Ttl, _) => None,
+            (retention, Some(rpc_url)) => Some(Self::new(retention, rpc_url)),
+            (retention, None) => {
+                warn!("⚠️  Retention {:?} requires KONA_BRIDGE_FINALITY_RPC, falling back to TTL", retention);
+                None
+            }
+        }
+    }
+
+    pub fn new(retention: Retention, rpc_url: String) -> Self {
+        Self {
+            retention,
+            rpc_url,
+            client: reqwest::Client::new(),
+            rpc_kind: AtomicU8::new(RPC_UNKNOWN),
+        }
+    }
+
+    pub fn retention(&self) -> Retention {
+        self.retention
+    }
+
+    /// Aktueller Safe- bzw. Finalized-Head (Blocknummer)
+    pub async fn head(&self) -> Result<u64, Box<dyn std::error::Error + Send + Sync>> {
+        match self.rpc_kind.load(Ordering::Relaxed) {
+            RPC_ROLLUP => self.head_from_sync_status().await,
+            RPC_EXECUTION => self.head_from_block_tag().await,
+            _ => match self.head_from_sync_status().await {
+                Ok(head) => {
+                    self.rpc_kind.store(RPC_ROLLUP, Ordering::Relaxed);
+                    Ok(head)
+                }
+                Err(e) => {
+                    debug!("optimism_syncStatus not available ({}), trying eth_getBlockByNumber", e);
+                    let head = self.head_from_block_tag().await?;
+                    self.rpc_kind.store(RPC_EXECUTION, Ordering::Relaxed);
+                    Ok(head)
+                }
+            },
+        }
+    }
+
+    /// Rollup-Node: optimism_syncStatus -> safe_l2 / finalized_l2
+    async fn head_from_sync_status(&self) -> Result<u64, Box<dyn std::error::Error + Send + Sync>> {
+        let result = self.call("optimism_syncStatus", serde_json::json!([])).await?;
+        let key = match self.retention {
+            Retention::Finalized => "finalized_l2",
+            _ => "safe_l2",
+        };
+        result[key]["number"]
+            .as_u64()
+            .ok_or_else(|| format!("optimism_syncStatus without {}.number", key).into())
+    }
+
+    /// L2-Execution-Client: eth_getBlockByNumber("safe"/"finalized")
+    async fn head_from_block_tag(&self) -> Result<u64, Box<dyn std::error::Error + Send + Sync>> {
+        let tag = self.retention.tag();
+        let result = self.call("eth_getBlockByNumber", serde_json::json!([tag, false])).await?;
+        let number = result["number"]
+            .as_str()
+            .ok_or_else(|| format!("No {} block returned", tag))?;
+        Ok(u64::from_str_radix(number.trim_start_matches("0x"), 16)?)
+    }
+
+    async fn call(
+        &self,
+        method: &str,
+        params: serde_json::Value,
+    ) -> Result<serde_json::Value, Box<dyn std::error::Error + Send + Sync>> {
+        let request = serde_json::json!({"jsonrpc": "2.0", "id": 1, "method": method, "params": params});
+        let response = self
+            .client
+            .post(&self.rpc_url)
+            .json(&request)
+            .timeout(Duration::from_secs(10))
+            .send()
+            .await?;
+        if !response.status().is_success() {
+            return Err(format!("HTTP {} from {}", response.status(), self.rpc_url).into());
+        }
+
+        let mut body: serde_json::Value = response.json().await?;
+        if let Some(error) = body.get("error") {
+            return Err(format!("{} failed: {}", method, error).into());
+        }
+        Ok(body["result"].take())
+    }
+}
+
+/// Löscht alle Blöcke (.raw + .json) der Chain mit Nummer <= `head`; Dateien anderer Chains im selben (flachen)
+/// Verzeichnis bleiben unberührt. Die zwei neuesten Blöcke der Chain (latest/pre_latest) bleiben immer erhalten.
+pub async fn prune_finalized(
+    output_dir: &Path,
+    chain_id: u64,
+    head: u64,
+) -> Result<usize, Box<dyn std::error::Error + Send + Sync>> {
+    let mut files = Vec::new();
+    let mut newest = Vec::new();
+
+    let mut entries = tokio_fs::read_dir(output_dir).await?;
+    while let Some(entry) = entries.next_entry().await? {
+        let path = entry.path();
+        if let Some(block_number) = block_number_from_filename(&path, chain_id) {
+            newest.push(block_number);
+            if block_number <= head {
+                files.push((block_number, path));
+            }
+        }
+    }
+
+    newest.sort_unstable();
+    newest.dedup();
+    let keep_from = newest.iter().rev().nth(1).copied().unwrap_or(0);
+    files.retain(|(block_number, _)| *block_number < keep_from);
+
+    // Delta-Nachfolger des letzten gelöschten Blocks brauchen dessen Payload
+    let paths: Vec<PathBuf> = files.iter().map(|(_, path)| path.clone()).collect();
+    delta::detach(&paths).await;
+
+    let mut blocks = Vec::new();
+    for (block_number, path) in files {
+        if tokio_fs::remove_file(&path).await.is_ok() {
+            blocks.push(block_number);
+        }
+    }
+    blocks.sort_unstable();
+    blocks.dedup();
+
+    if let (Some(first), Some(last)) = (blocks.first(), blocks.last()) {
+        info!("🗑️  Finality: deleted {} blocks ({} - {}), head {}", blocks.len(), first, last, head);
+    }
+    Ok(blocks.len())
+}
@@ -0,0 +1,60 @@
+// rollup.rs - op-node Rollup-Konfiguration (rollup.json) für Chains ohne Eintrag in config.rs oder der Registry
+//
+// KONA_BRIDGE_ROLLUP_CONFIG[_{chain_id}]=/pfad/rollup.json (bzw. `kona_bridge capture --rollup-config`), dieselbe
+// Datei, mit der op-node die Chain startet. Daraus übernimmt die Bridge:
+//   l2_chain_id                  Chain-ID (capture ohne --chain-id), muss zur konfigurierten Chain passen
+//   genesis, *_time              Rollup-Konfiguration des Gossip-Netzwerks statt der von Base (gossip.rs)
+//   l1_system_config_address     Unsafe-Signer live per KONA_BRIDGE_L1_RPC (signer.rs)
+//   genesis.system_config        Batcher-Adresse, nur für Log und `kona_bridge doctor`
+// Den Unsafe-Signer selbst enthält rollup.json nicht: ohne L1-RPC muss er über sequencer_address oder
+// KONA_BRIDGE_SIGNERS kommen. Bootnodes für private Devnets über KONA_BRIDGE_BOOTNODES.
+
+use crate::hardfork::HardforkSchedule;
+use alloy::primitives::Address;
+use kona_genesis::RollupConfig;
+use std::path::Path;
+
+/// Liest und prüft eine rollup.json
+pub fn load(path: &Path) -> Result<RollupConfig, Box<dyn std::error::Error + Send + Sync>> {
+    let data = std::fs::read_to_string(path).map_err(|e| format!("Cannot read rollup config {:?}: {}", path, e))?;
+    let config: RollupConfig =
+        serde_json::from_str(&data).map_err(|e| format!("Invalid rollup config {:?}: {}", path, e))?;
+    if config.l2_chain_id == 0 {
+        return Err(format!("Rollup config {:?} has no l2_chain_id", path).into());
+    }
+    if config.block_time == 0 {
+        return Err(format!("Rollup config {:?} has no block_time", path).into());
+    }
+    Ok(config)
+}
+
+/// Liest die Datei und prüft, dass sie zur Chain gehört
+pub fn load_for_chain(path: &Path, chain_id: u64) -> Result<RollupConfig, Box<dyn std::error::Error + Send + Sync>> {
+    let config = load(path)?;
+    if config.l2_chain_id != chain_id {
+        return Err(format!("Rollup config {:?} is for chain {}, not {}", path, config.l2_chain_id, chain_id).into());
+    }
+    Ok(config)
+}
+
+/// Batcher-Adresse aus genesis.system_config (None, wenn nicht angegeben)
+pub fn batcher(config: &RollupConfig) -> Option<Address> {
+    config.genesis.system_config.as_ref().map(|system| system.batcher_address).filter(|a| *a != Address::ZERO)
+}
+
+/// Einzeiler für Log und doctor
+pub fn describe(config: &RollupConfig, now: u64) -> String {
+    let schedule = HardforkSchedule::new(&config.hardforks);
+    let address = |address: Option<Address>| address.map_or_else(|| "-".to_string(), |address| format!("{}", address));
+    format!(
+        "chain {}, L1 {}, {}s blocks, genesis L2 #{} {}, batcher {}, SystemConfig {}, hardfork {}",
+        config.l2_chain_id,
+        config.l1_chain_id,
+        config.block_time,
+        config.genesis.l2.number,
+        config.genesis.l2.hash,
+        address(batcher(config)),
+        address(Some(config.l1_system_config_address).filter(|a| *a != Address::ZERO)),
+        schedule.active(now)
+    )
+}
@@ -0,0 +1,225 @@
+// rpc.rs - Minimaler JSON-RPC-Endpunkt über den gespeicherten Preconfs (Teil der HTTP-API)
+//
+//   POST /v1/chains/{chain_id}/rpc   JSON-RPC 2.0, einzelne Requests oder Batches
+//
+//   eth_chainId
+//   eth_blockNumber                        Nummer des neuesten gespeicherten Preconfs
+//   eth_getBlockByNumber(tag, full)        "pending"/"latest" = neuester Preconf, sonst Hex-Nummer
+//   eth_getTransactionByHash(hash)         über den Transaktions-Index des Stores
+//
+// Damit können vorhandene Ethereum-Tools (viem, ethers, cast, ...) den vorbestätigten Zustand direkt von der
+// Bridge lesen, ohne einen Node zu fragen. Es gibt nur, was in den Preconfs steht: keine Receipts, keinen State,
+// "safe", "finalized" und "earliest" werden abgelehnt. Unbekannte Blöcke und Transaktionen liefern null wie
+// bei einem Node; Blöcke tragen zusätzlich die Signatur des Sequencers (sequencerSignature).
+
+use crate::{
+    codec::PreconfContainer,
+    render::render_block,
+    serve::served_store,
+    ssz::{decode_envelope, ExecutionPayload},
+    storage::PreconfStore,
+    txdecode::render_transaction,
+};
+use alloy::primitives::{keccak256, U256};
+use axum::{
+    extract::Path,
+    http::StatusCode,
+    response::{IntoResponse, Json, Response},
+};
+use serde_json::{json, Value};
+
+const PARSE_ERROR: i64 = -32700;
+const INVALID_REQUEST: i64 = -32600;
+const METHOD_NOT_FOUND: i64 = -32601;
+const INVALID_PARAMS: i64 = -32602;
+const INTERNAL_ERROR: i64 = -32603;
+/// Serverseitiger Fehler ohne passenden Standard-Code (z.B. noch kein Preconf gespeichert)
+const SERVER_ERROR: i64 = -32000;
+
+/// Höchstzahl Requests pro Batch
+const MAX_BATCH_SIZE: usize = 100;
+
+/// Fehler eines einzelnen Aufrufs (Code, Meldung)
+type RpcError = (i64, String);
+
+fn rpc_result(id: Value, result: Value) -> Value {
+    json!({ "jsonrpc": "2.0", "id": id, "result": result })
+}
+
+fn rpc_error(id: Value, (code, message): RpcError) -> Value {
+    json!({ "jsonrpc": "2.0", "id": id, "error": { "code": code, "message": message } })
+}
+
+fn invalid_params(message: impl ToString) -> RpcError {
+    (INVALID_PARAMS, message.to_string())
+}
+
+fn internal_error(message: impl ToString) -> RpcError {
+    (INTERNAL_ERROR, message.to_string())
+}
+
+pub(crate) async fn post_rpc(Path(chain_id): Path<u64>, body: String) -> Response {
+    let Some(store) = served_store(chain_id) else {
+        return (StatusCode::NOT_FOUND, Json(json!({ "error": format!("chain {} not served", chain_id) }))).into_response();
+    };
+    let request: Value = match serde_json::from_str(&body) {
+        Ok(request) => request,
+        Err(e) => return Json(rpc_error(Value::Null, (PARSE_ERROR, e.to_string()))).into_response(),
+    };
+
+    match request {
+        Value::Array(calls) if calls.is_empty() => {
+            Json(rpc_error(Value::Null, (INVALID_REQUEST, "empty batch".to_string()))).into_response()
+        }
+        Value::Array(calls) if calls.len() > MAX_BATCH_SIZE => Json(rpc_error(
+            Value::Null,
+            (INVALID_REQUEST, format!("batch exceeds {} requests", MAX_BATCH_SIZE)),
+        ))
+        .into_response(),
+        Value::Array(calls) => {
+            let mut responses = Vec::with_capacity(calls.len());
+            for call in calls {
+                responses.push(handle_call(&store, call).await);
+            }
+            Json(Value::Array(responses)).into_response()
+        }
+        call => Json(handle_call(&store, call).await).into_response(),
+    }
+}
+
+/// Beantwortet ein einzelnes Request-Objekt
+async fn handle_call(store: &PreconfStore, call: Value) -> Value {
+    let id = call.get("id").cloned().unwrap_or(Value::Null);
+    let Some(method) = call.get("method").and_then(Value::as_str) else {
+        return rpc_error(id, (INVALID_REQUEST, "missing method".to_string()));
+    };
+    let params = match call.get("params") {
+        None | Some(Value::Null) => Vec::new(),
+        Some(Value::Array(params)) => params.clone(),
+        Some(_) => return rpc_error(id, invalid_params("params must be an array")),
+    };
+
+    let result = match method {
+        "eth_chainId" => Ok(json!(format!("0x{:x}", store.chain_id()))),
+        "eth_blockNumber" => match store.latest_entry() {
+            Some((block_number, _)) => Ok(json!(format!("0x{:x}", block_number))),
+            None => Err((SERVER_ERROR, "no preconf stored yet".to_string())),
+        },
+        "eth_getBlockByNumber" => get_block_by_number(store, &params).await,
+        "eth_getTransactionByHash" => get_transaction_by_hash(store, &params).await,
+        _ => Err((METHOD_NOT_FOUND, format!("method {} not supported", method))),
+    };
+    match result {
+        Ok(result) => rpc_result(id, result),
+        Err(e) => rpc_error(id, e),
+    }
+}
+
+async fn get_block_by_number(store: &PreconfStore, params: &[Value]) -> Result<Value, RpcError> {
+    let tag = params.first().and_then(Value::as_str).ok_or_else(|| invalid_params("missing block tag"))?;
+    let full = match params.get(1) {
+        None | Some(Value::Null) => false,
+        Some(Value::Bool(full)) => *full,
+        Some(_) => return Err(invalid_params("second parameter must be a boolean")),
+    };
+    let block_number = match tag {
+        "pending" | "latest" => match store.latest_entry() {
+            Some((block_number, _)) => block_number,
+            None => return Ok(Value::Null),
+        },
+        "safe" | "finalized" | "earliest" => {
+            return Err(invalid_params(format!("block tag {} not available from preconfs", tag)));
+        }
+        number => parse_quantity(number).ok_or_else(|| invalid_params(format!("invalid block number {}", number)))?,
+    };
+
+    let Some(data) = read_block(store, block_number).await? else {
+        return Ok(Value::Null);
+    };
+    tokio::task::spawn_blocking(move || {
+        let container = PreconfContainer::parse(&data)?;
+        let payload = container.payload()?;
+        let envelope = decode_envelope(&payload)?;
+        let mut block = render_block(&envelope, Some(container.signature));
+        if full {
+            let p = &envelope.payload;
+            let base_fee = U256::from_le_bytes(p.base_fee_per_gas);
+            block["transactions"] = p
+                .transactions
+                .iter()
+                .enumerate()
+                .map(|(index, tx)| Ok(with_block_context(render_transaction(tx, Some(base_fee))?, p, index)))
+                .collect::<Result<Vec<_>, Box<dyn std::error::Error + Send + Sync>>>()?
+                .into();
+        }
+        Ok::<_, Box<dyn std::error::Error + Send + Sync>>(block)
+    })
+    .await
+    .map_err(internal_error)?
+    .map_err(internal_error)
+}
+
+async fn get_transaction_by_hash(store: &PreconfStore, params: &[Value]) -> Result<Value, RpcError> {
+    let hash = params.first().and_then(Value::as_str).ok_or_else(|| invalid_params("missing transaction hash"))?;
+    let tx_hash: [u8; 32] = hex::decode(hash.trim_start_matches("0x"))
+        .ok()
+        .and_then(|bytes| bytes.try_into().ok())
+        .ok_or_else(|| invalid_params(format!("invalid transaction hash {}", hash)))?;
+
+    let Some(location) = store.tx_location(&tx_hash) else {
+        return Ok(Value::Null);
+    };
+    let Some(data) = read_block(store, location.block_number).await? else {
+        return Ok(Value::Null);
+    };
+    tokio::task::spawn_blocking(move || {
+        let container = PreconfContainer::parse(&data)?;
+        let payload = container.payload()?;
+        let envelope = decode_envelope(&payload)?;
+        let p = &envelope.payload;
+        let index = location.tx_index as usize;
+        // Index kann nach einem Reorg auf einen anderen Block derselben Nummer zeigen
+        match p.transactions.get(index) {
+            Some(tx) if keccak256(tx).0 == tx_hash => {
+                let base_fee = U256::from_le_bytes(p.base_fee_per_gas);
+                Ok(with_block_context(render_transaction(tx, Some(base_fee))?, p, index))
+            }
+            _ => Ok::<_, Box<dyn std::error::Error + Send + Sync>>(Value::Null),
+        }
+    })
+    .await
+    .map_err(internal_error)?
+    .map_err(internal_error)
+}
+
+/// Gespeicherte .raw Datei eines Blocks (None, wenn er nicht (mehr) vorhanden ist)
+async fn read_block(store: &PreconfStore, block_number: u64) -> Result<Option<Vec<u8>>, RpcError> {
+    let Some(filename) = store.filename_by_number(block_number) else {
+        return Ok(None);
+    };
+    if let Some(data) = store.hot_get(&filename) {
+        return Ok(Some(data.to_vec()));
+    }
+    match store.read_raw(&filename).await {
+        Ok(data) => Ok(Some(data)),
+        // Datei kann zwischen Lookup und Lesen vom TTL-Cleanup entfernt worden sein
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => Ok(None),
+        Err(e) => Err(internal_error(e)),
+    }
+}
+
+/// Ergänzt eine gerenderte Transaktion um ihre Position im Block
+fn with_block_context(mut tx: Value, payload: &ExecutionPayload, index: usize) -> Value {
+    tx["blockHash"] = json!(format!("0x{}", hex::encode(payload.block_hash)));
+    tx["blockNumber"] = json!(format!("0x{:x}", payload.block_number));
+    tx["transactionIndex"] = json!(format!("0x{:x}", index));
+    tx
+}
+
+/// Hex-Quantity ("0x1a") oder Dezimalzahl
+fn parse_quantity(value: &str) -> Option<u64> {
+    match value.strip_prefix("0x") {
+        Some(digits) => u64::from_str_radix(digits, 16).ok(),
+        None => value.parse().ok(),
+    }
+}
@@ -0,0 +1,329 @@
+// s3.rs - Spiegelung gespeicherter Preconfs in S3-kompatiblen Object Storage (AWS S3, GCS, MinIO)
+//
+// Verwendet Path-Style-Requests ({endpoint}/{bucket}/{key}) mit AWS Signature V4,
+// das funktioniert mit AWS S3, der GCS XML-API (HMAC-Keys) und MinIO.
+
+use crate::{settings::S3Settings, sink::StoredPreconf};
+use base64::{engine::general_purpose::STANDARD as BASE64, Engine};
+use sha2::{Digest, Sha256};
+use std::{
+    sync::{Arc, Mutex},
+    time::{Duration, SystemTime, UNIX_EPOCH},
+};
+use tokio::{sync::mpsc, time::sleep};
+use tracing::{debug, info, warn};
+
+const UPLOAD_ATTEMPTS: u32 = 3;
+
+/// ID der eigenen Lifecycle-Regel (die übrigen Regeln des Buckets bleiben unverändert)
+const LIFECYCLE_RULE_ID: &str = "kona-bridge-preconfs";
+
+/// Minimaler S3-Client für PUT-Requests
+pub struct S3Client {
+    settings: S3Settings,
+    client: reqwest::Client,
+}
+
+impl S3Client {
+    pub fn new(settings: S3Settings) -> Self {
+        Self {
+            settings,
+            client: reqwest::Client::new(),
+        }
+    }
+
+    /// Objekt-Key für eine Datei eines Preconfs: {prefix}{chain_id}/{filename}
+    pub fn object_key(&self, chain_id: u64, filename: &str) -> String {
+        format!("{}{}/{}", self.settings.prefix, chain_id, filename)
+    }
+
+    /// Lädt ein Objekt hoch
+    pub async fn put_object(
+        &self,
+        key: &str,
+        body: Vec<u8>,
+        content_type: &str,
+    ) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        let path = format!("/{}/{}", self.settings.bucket, uri_encode(key, false));
+        self.send("PUT", &path, "", body, &[("content-type", content_type.to_string())])
+            .await
+    }
+
+    /// Setzt die Lifecycle-Regel LIFECYCLE_RULE_ID, die Objekte unter dem Prefix nach `days` Tagen löscht. Ein PUT
+    /// ersetzt immer die gesamte Konfiguration, daher werden die vorhandenen Regeln gelesen und nur die eigene ersetzt;
+    /// ist die Konfiguration nicht lesbar (z.B. fehlende Berechtigung), bleibt der Bucket unverändert.
+    pub async fn put_lifecycle(&self, days: u32) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        let path = format!("/{}", self.settings.bucket);
+        let response = self.request("GET", &path, "lifecycle=", Vec::new(), &[]).await?;
+        let existing = match response.status() {
+            reqwest::StatusCode::NOT_FOUND => String::new(), // NoSuchLifecycleConfiguration
+            _ => check_status(response).await?,
+        };
+
+        let own = format!(
+            "<Rule><ID>{}</ID><Filter><Prefix>{}</Prefix></Filter><Status>Enabled</Status>\
+             <Expiration><Days>{}</Days></Expiration></Rule>",
+            LIFECYCLE_RULE_ID, self.settings.prefix, days
+        );
+        let mut rules: Vec<&str> = lifecycle_rules(&existing)
+            .into_iter()
+            .filter(|rule| rule_id(rule) != Some(LIFECYCLE_RULE_ID))
+            .collect();
+        rules.push(&own);
+        let body = format!("<LifecycleConfiguration>{}</LifecycleConfiguration>", rules.concat()).into_bytes();
+
+        let content_md5 = BASE64.encode(md5::compute(&body).0);
+        self.send(
+            "PUT",
+            &path,
+            "lifecycle=",
+            body,
+            &[
+                ("content-md5", content_md5),
+                ("content-type", "application/xml".to_string()),
+            ],
+        )
+        .await
+    }
+
+    async fn send(
+        &self,
+        method: &str,
+        path: &str,
+        query: &str,
+        body: Vec<u8>,
+        extra_headers: &[(&str, String)],
+    ) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        let response = self.request(method, path, query, body, extra_headers).await?;
+        check_status(response).await.map(|_| ())
+    }
+
+    /// Signierter Request (AWS Signature V4), der Status wird nicht geprüft
+    async fn request(
+        &self,
+        method: &str,
+        path: &str,
+        query: &str,
+        body: Vec<u8>,
+        extra_headers: &[(&str, String)],
+    ) -> Result<reqwest::Response, Box<dyn std::error::Error + Send + Sync>> {
+        let host = self
+            .settings
+            .endpoint
+            .split("://")
+            .nth(1)
+            .unwrap_or(&self.settings.endpoint)
+            .to_string();
+        let now = SystemTime::now().duration_since(UNIX_EPOCH)?.as_secs();
+        let amz_date = format_amz_date(now);
+        let date = &amz_date[..8];
+        let payload_hash = hex::encode(Sha256::digest(&body));
+
+        // Header müssen für die Signatur sortiert und kleingeschrieben sein
+        let mut headers: Vec<(String, String)> = vec![
+            ("host".to_string(), host),
+            ("x-amz-content-sha256".to_string(), payload_hash.clone()),
+            ("x-amz-date".to_string(), amz_date.clone()),
+        ];
+        if let Some(ref token) = self.settings.session_token {
+            headers.push(("x-amz-security-token".to_string(), token.clone()));
+        }
+        for (name, value) in extra_headers {
+            headers.push((name.to_string(), value.clone()));
+        }
+        headers.sort_by(|a, b| a.0.cmp(&b.0));
+
+        let canonical_headers: String = headers.iter().map(|(k, v)| format!("{}:{}\n", k, v.trim())).collect();
+        let signed_headers = headers.iter().map(|(k, _)| k.as_str()).collect::<Vec<_>>().join(";");
+        let canonical_request = format!(
+            "{}\n{}\n{}\n{}\n{}\n{}",
+            method, path, query, canonical_headers, signed_headers, payload_hash
+        );
+
+        let scope = format!("{}/{}/s3/aws4_request", date, self.settings.region);
+        let string_to_sign = format!(
+            "AWS4-HMAC-SHA256\n{}\n{}\n{}",
+            amz_date,
+            scope,
+            hex::encode(Sha256::digest(canonical_request.as_bytes()))
+        );
+
+        let k_date = hmac_sha256(format!("AWS4{}", self.settings.secret_key).as_bytes(), date.as_bytes());
+        let k_region = hmac_sha256(&k_date, self.settings.region.as_bytes());
+        let k_service = hmac_sha256(&k_region, b"s3");
+        let k_signing = hmac_sha256(&k_service, b"aws4_request");
+        let signature = hex::encode(hmac_sha256(&k_signing, string_to_sign.as_bytes()));
+
+        let authorization = format!(
+            "AWS4-HMAC-SHA256 Credential={}/{}, SignedHeaders={}, Signature={}",
+            self.settings.access_key, scope, signed_headers, signature
+        );
+
+        let url = if query.is_empty() {
+            format!("{}{}", self.settings.endpoint, path)
+        } else {
+            format!("{}{}?{}", self.settings.endpoint, path, query.trim_end_matches('='))
+        };
+
+        let method = reqwest::Method::from_bytes(method.as_bytes())?;
+        let mut request = self
+            .client
+            .request(method, &url)
+            .timeout(Duration::from_secs(30))
+            .header("authorization", authorization);
+        for (name, value) in headers.iter().filter(|(k, _)| k != "host") {
+            request = request.header(name.as_str(), value.as_str());
+        }
+
+        Ok(request.body(body).send().await?)
+    }
+}
+
+/// Antworttext bei Erfolg, sonst Fehler mit Status und Anfang der Antwort
+async fn check_status(response: reqwest::Response) -> Result<String, Box<dyn std::error::Error + Send + Sync>> {
+    let status = response.status();
+    let url = response.url().to_string();
+    let text = response.text().await.unwrap_or_default();
+    if !status.is_success() {
+        let snippet: String = text.chars().take(200).collect();
+        return Err(format!("S3 {} failed: {} {}", url, status, snippet).into());
+    }
+    Ok(text)
+}
+
+/// <Rule>-Elemente einer Lifecycle-Konfiguration, unverändert für das Zurückschreiben
+fn lifecycle_rules(xml: &str) -> Vec<&str> {
+    let mut rules = Vec::new();
+    let mut rest = xml;
+    while let Some(start) = rest.find("<Rule>") {
+        let Some(len) = rest[start..].find("</Rule>") else {
+            break;
+        };
+        let end = start + len + "</Rule>".len();
+        rules.push(&rest[start..end]);
+        rest = &rest[end..];
+    }
+    rules
+}
+
+/// <ID> einer Lifecycle-Regel
+fn rule_id(rule: &str) -> Option<&str> {
+    let start = rule.find("<ID>")? + "<ID>".len();
+    let len = rule[start..].find("</ID>")?;
+    Some(rule[start..start + len].trim())
+}
+
+/// Sink-Task: lädt .raw und .json jedes gespeicherten Preconfs hoch
+pub async fn run_s3_sink(
+    settings: S3Settings,
+    mut receiver: mpsc::Receiver<Arc<StoredPreconf>>,
+    running: Arc<Mutex<bool>>,
+) {
+    let client = S3Client::new(settings);
+
+    if let Some(days) = client.settings.lifecycle_days {
+        match client.put_lifecycle(days).await {
+            Ok(()) => info!("🪣 S3 lifecycle set: expire {} after {} days", client.settings.prefix, days),
+            Err(e) => warn!("⚠️  Failed to set S3 lifecycle rule: {}", e),
+        }
+    }
+
+    while let Some(preconf) = receiver.recv().await {
+        if !*running.lock().unwrap() {
+            break;
+        }
+
+        let raw_key = client.object_key(preconf.chain_id, &preconf.raw_filename);
+        let meta_key = raw_key.trim_end_matches(".raw").to_string() + ".json";
+        let metadata = serde_json::to_vec_pretty(&preconf.metadata).unwrap_or_default();
+
+        let uploads = [
+            (raw_key, preconf.raw_data.clone(), "application/octet-stream"),
+            (meta_key, metadata, "application/json"),
+        ];
+
+        for (key, body, content_type) in uploads {
+            let mut attempt = 1;
+            loop {
+                match client.put_object(&key, body.clone(), content_type).await {
+                    Ok(()) => {
+                        debug!("🪣 S3: uploaded {}", key);
+                        break;
+                    }
+                    Err(e) if attempt < UPLOAD_ATTEMPTS => {
+                        debug!("🪣 S3: upload of {} failed (attempt {}): {}", key, attempt, e);
+                        sleep(Duration::from_millis(500 * attempt as u64)).await;
+                        attempt += 1;
+                    }
+                    Err(e) => {
+                        warn!("⚠️  S3: giving up on {} after {} attempts: {}", key, attempt, e);
+                        break;
+                    }
+                }
+            }
+        }
+    }
+
+    info!("🛑 S3 sink stopped");
+}
+
+/// HMAC-SHA256 (RFC 2104)
+pub(crate) fn hmac_sha256(key: &[u8], data: &[u8]) -> [u8; 32] {
+    const BLOCK_SIZE: usize = 64;
+    let mut block = [0u8; BLOCK_SIZE];
+    if key.len() > BLOCK_SIZE {
+        block[..32].copy_from_slice(&Sha256::digest(key));
+    } else {
+        block[..key.len()].copy_from_slice(key);
+    }
+
+    let mut inner = Sha256::new();
+    inner.update(block.map(|b| b ^ 0x36));
+    inner.update(data);
+    let inner_hash = inner.finalize();
+
+    let mut outer = Sha256::new();
+    outer.update(block.map(|b| b ^ 0x5c));
+    outer.update(inner_hash);
+    outer.finalize().into()
+}
+
+/// URI-Encoding nach AWS-Regeln (unreserved Zeichen bleiben, '/' optional)
+fn uri_encode(input: &str, encode_slash: bool) -> String {
+    let mut out = String::with_capacity(input.len());
+    for byte in input.bytes() {
+        match byte {
+            b'A'..=b'Z' | b'a'..=b'z' | b'0'..=b'9' | b'-' | b'_' | b'.' | b'~' => out.push(byte as char),
+            b'/' if !encode_slash => out.push('/'),
+            _ => out.push_str(&format!("%{:02X}", byte)),
+        }
+    }
+    out
+}
+
+/// Formatiert Unix-Sekunden als YYYYMMDDTHHMMSSZ (UTC)
+fn format_amz_date(unix_secs: u64) -> String {
+    let days = (unix_secs / 86400) as i64;
+    let secs_of_day = unix_secs % 86400;
+
+    // Civil-from-days (Howard Hinnant)
+    let z = days + 719_468;
+    let era = z.div_euclid(146_097);
+    let doe = z - era * 146_097;
+    let yoe = (doe - doe / 1460 + doe / 36524 - doe / 146_096) / 365;
+    let doy = doe - (365 * yoe + yoe / 4 - yoe / 100);
+    let mp = (5 * doy + 2) / 153;
+    let day = doy - (153 * mp + 2) / 5 + 1;
+    let month = if mp < 10 { mp + 3 } else { mp - 9 };
+    let year = yoe + era * 400 + if month <= 2 { 1 } else { 0 };
+
+    format!(
+        "{:04}{:02}{:02}T{:02}{:02}{:02}Z",
+        year,
+        month,
+        day,
+        secs_of_day / 3600,
+        (secs_of_day % 3600) / 60,
+        secs_of_day % 60
+    )
+}
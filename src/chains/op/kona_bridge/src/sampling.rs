@@ -0,0 +1,81 @@
+// sampling.rs - Nur ausgewählte Blöcke speichern (KONA_BRIDGE_SAMPLING, Analytics-Deployments)
+//
+// Geprüft wird weiterhin jeder Block (Signatur, Plausibilität, Equivocation), gespeichert nur eine Stichprobe:
+//   every:<n>    Blöcke mit block_number % n == 0
+//   bucket:<s>   der erste Block je Zeitfenster von s Sekunden (Block-Timestamp, sonst Empfangszeit)
+// Übersprungene Blöcke zählt sampled_out in den Speicher-Statistiken; sie erscheinen weder in Index, Zeigern noch
+// Sinks und Hooks.
+
+use std::{fmt, sync::Mutex};
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Sampling {
+    EveryNth(u64),
+    TimeBucket(u64),
+}
+
+impl fmt::Display for Sampling {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Sampling::EveryNth(n) => write!(f, "every:{}", n),
+            Sampling::TimeBucket(secs) => write!(f, "bucket:{}", secs),
+        }
+    }
+}
+
+impl Sampling {
+    /// Parst "every:<n>" oder "bucket:<sekunden>" (auch "bucket:<n>s", "bucket:<n>m"), n > 0
+    pub fn parse(value: &str) -> Option<Self> {
+        let value = value.trim().to_ascii_lowercase();
+        let (mode, amount) = value.split_once(':')?;
+        match mode {
+            "every" | "nth" => amount.parse().ok().filter(|n| *n > 0).map(Sampling::EveryNth),
+            "bucket" | "time" => {
+                let (number, factor) = match amount.strip_suffix('m') {
+                    Some(minutes) => (minutes, 60),
+                    None => (amount.strip_suffix('s').unwrap_or(amount), 1),
+                };
+                let secs = number.parse::<u64>().ok()?.checked_mul(factor)?;
+                (secs > 0).then_some(Sampling::TimeBucket(secs))
+            }
+            _ => None,
+        }
+    }
+}
+
+/// Entscheidet je Block, ob er gespeichert wird
+pub struct Sampler {
+    mode: Sampling,
+    last: Mutex<Option<(u64, u64)>>, // (Zeitfenster, Block) des zuletzt gespeicherten Blocks
+}
+
+impl Sampler {
+    pub fn new(mode: Sampling) -> Self {
+        Self { mode, last: Mutex::new(None) }
+    }
+
+    pub fn mode(&self) -> Sampling {
+        self.mode
+    }
+
+    /// true, wenn der Block gespeichert wird; `timestamp` in Sekunden. Ein neuer Block derselben Höhe wie der zuletzt
+    /// gespeicherte (Reorg) wird ebenfalls gespeichert, damit die Stichprobe den kanonischen Block enthält
+    pub fn keep(&self, block_number: u64, timestamp: u64) -> bool {
+        match self.mode {
+            Sampling::EveryNth(n) => block_number % n == 0,
+            Sampling::TimeBucket(secs) => {
+                let bucket = timestamp / secs;
+                let mut last = self.last.lock().unwrap();
+                let keep = match *last {
+                    Some((last_bucket, last_block)) => bucket > last_bucket || block_number == last_block,
+                    None => true,
+                };
+                if keep {
+                    let bucket = last.map_or(bucket, |(last_bucket, _)| bucket.max(last_bucket));
+                    *last = Some((bucket, block_number));
+                }
+                keep
+            }
+        }
+    }
+}
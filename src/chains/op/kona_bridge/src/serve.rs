@@ -0,0 +1,766 @@
+// serve.rs - Eingebaute HTTP-API für gespeicherte Preconfs (KONA_BRIDGE_SERVE_ADDR, gRPC siehe grpc.rs)
+//
+//   GET /v1/chains/{chain_id}/preconfs/{block}?format=json|decoded|raw|ssz|c4|cbor
+//   GET /v1/chains/{chain_id}/preconfs/latest?format=...   neuester gespeicherter (verifizierter) Preconf
+//   GET /v1/chains/{chain_id}/preconfs?from=&to=&limit=&cursor=&payload=1
+//                                                          Metadaten eines Bereichs seitenweise (für Indexer)
+//   GET /v1/chains/{chain_id}/head                         Nummer, Hash, Timestamp, Signer und Alter des neuesten
+//   GET /v1/chains/{chain_id}/signers                      alle bisher gesehenen Signer (siehe signerhistory.rs)
+//   GET /v1/chains/{chain_id}/reorgs?since=&limit=         seit Start erkannte Reorgs, neueste zuerst (siehe reorg.rs)
+//   GET /v1/chains/{chain_id}/events?payload=1             Server-Sent Events, ein Event pro neu gespeichertem Preconf
+//   GET /v1/chains/{chain_id}/ws?payload=1                 dasselbe als WebSocket (Text-Frames mit JSON)
+//   POST /v1/chains/{chain_id}/rpc                         JSON-RPC-Teilmenge über den Preconfs (siehe rpc.rs)
+//   GET /healthz, /readyz?chain_id=...                     Liveness und Readiness (siehe health.rs)
+//   POST /admin/chains/{chain_id}/...                      Betriebsaktionen mit Token (siehe admin.rs)
+//
+//   json (Default): Payload (parent_beacon_block_root + execution payload, hex), Signatur und Metadaten
+//   decoded:        statt des Payloads der Block im Format von eth_getBlockByNumber
+//   raw:            application/octet-stream wie beim Query-Socket (zstd-Payload + Signatur)
+//   ssz:            OP_PRECONF-Container des Verifiers (block_proof eines Preconfs, siehe c4.rs)
+//   c4:             vollständiger C4Request mit BlockProof, direkt verifizierbar
+//   cbor:           wie json, Payload und Signatur als Byte-Strings (application/cbor, siehe cbor.rs)
+//
+// Ohne ?format= entscheidet der Accept-Header: application/json -> decoded, application/octet-stream -> raw,
+// application/cbor -> cbor; ohne Header oder mit */* bleibt es beim Default json, ohne passenden Typ gibt es 406.
+// ?min_confidence=N (0-100, siehe confidence.rs) liefert nur Blöcke mit mindestens diesem Vertrauenswert: ein
+// einzelner Block sonst 404, /latest den neuesten ausreichenden, der Bereich überspringt die übrigen.
+//
+// Damit hängen Konsumenten (auch auf anderen Hosts) nicht mehr am Dateilayout des Output-Verzeichnisses;
+// /latest und /head ersetzen für sie die latest.raw-Symlinks. Push-Abonnenten erhalten Metadaten und optional
+// den Payload über einen eigenen Sink (siehe sink.rs); wer zu langsam liest, verliert Events und bekommt
+// stattdessen ein "lagged" mit der Anzahl übersprungener Preconfs.
+// Alle Chains eines Prozesses teilen sich einen Server: jede Bridge registriert ihren Store beim Start,
+// der Server selbst läuft in einem eigenen Thread, damit er nicht am Runtime einer einzelnen Chain hängt.
+// API-Keys/JWT, Rate-Limits und CORS prüft eine Middleware vor allen Routen (siehe access.rs); mit
+// KONA_BRIDGE_TLS_CERT/KONA_BRIDGE_TLS_KEY laufen HTTP-API und gRPC über TLS (siehe tls.rs).
+// Mit KONA_BRIDGE_ADMIN_ADDR laufen die Admin-Routen auf einem eigenen Listener (z.B. nur 127.0.0.1).
+// Bei einem Upgrade per SIGUSR2 übernimmt der neue Prozess die Listener (siehe handoff.rs).
+
+use crate::{
+    access::{self, AccessControl},
+    admin,
+    c4::{block_proof_request, preconf_proof},
+    cbor::Cbor,
+    codec::{to_legacy, PreconfContainer},
+    confidence,
+    grpc, handoff, health,
+    render::render_raw,
+    rpc,
+    settings::TlsSettings,
+    sink::StoredPreconf,
+    storage::PreconfStore,
+    tls::{self, Tls, TlsListener},
+};
+use axum::{
+    extract::{
+        ws::{Message, WebSocket, WebSocketUpgrade},
+        Path, Query,
+    },
+    http::{header, HeaderMap, HeaderValue, StatusCode},
+    middleware,
+    response::{
+        sse::{Event, KeepAlive, Sse},
+        IntoResponse, Response,
+    },
+    routing::{get, post},
+    Json, Router,
+};
+use serde::Deserialize;
+use std::{
+    collections::HashMap,
+    convert::Infallible,
+    net::SocketAddr,
+    sync::{
+        atomic::{AtomicUsize, Ordering},
+        Arc, Mutex, OnceLock, RwLock,
+    },
+    time::{Duration, SystemTime, UNIX_EPOCH},
+};
+use tokio::sync::{broadcast, mpsc};
+use tokio_stream::wrappers::ReceiverStream;
+use tracing::{debug, error, info, warn};
+
+/// Registrierte Stores nach Chain-ID
+static CHAINS: OnceLock<RwLock<HashMap<u64, Arc<PreconfStore>>>> = OnceLock::new();
+/// Adressen des laufenden Servers (HTTP, gRPC, Admin; einmal pro Prozess gestartet)
+static SERVER: OnceLock<(Option<SocketAddr>, Option<SocketAddr>, Option<SocketAddr>)> = OnceLock::new();
+/// Neu gespeicherte Preconfs je Chain für Push-Abonnenten
+static UPDATES: OnceLock<Mutex<HashMap<u64, broadcast::Sender<Arc<StoredPreconf>>>>> = OnceLock::new();
+/// Offene SSE-/WebSocket-Verbindungen über alle Chains
+static SUBSCRIBERS: AtomicUsize = AtomicUsize::new(0);
+
+/// Events, die ein Abonnent zurückliegen darf, bevor er Events verliert
+const PUSH_BUFFER: usize = 256;
+/// Höchstzahl gleichzeitiger Push-Abonnenten
+const MAX_SUBSCRIBERS: usize = 1024;
+/// Seitengröße für Bereichsabfragen (Default, Maximum, Maximum mit Payload)
+const DEFAULT_PAGE_SIZE: usize = 100;
+const MAX_PAGE_SIZE: usize = 1000;
+const MAX_PAGE_SIZE_WITH_PAYLOAD: usize = 100;
+/// Höchstzahl geprüfter Blocknummern pro Seite, damit große Lücken keine Anfrage blockieren
+const MAX_SCAN_PER_PAGE: u64 = 10_000;
+
+fn chains() -> &'static RwLock<HashMap<u64, Arc<PreconfStore>>> {
+    CHAINS.get_or_init(|| RwLock::new(HashMap::new()))
+}
+
+/// Registrierter Store einer Chain (auch für grpc.rs)
+pub(crate) fn served_store(chain_id: u64) -> Option<Arc<PreconfStore>> {
+    chains().read().ok()?.get(&chain_id).cloned()
+}
+
+fn updates(chain_id: u64) -> broadcast::Sender<Arc<StoredPreconf>> {
+    let updates = UPDATES.get_or_init(|| Mutex::new(HashMap::new()));
+    let mut updates = updates.lock().unwrap();
+    updates.entry(chain_id).or_insert_with(|| broadcast::channel(PUSH_BUFFER).0).clone()
+}
+
+/// Empfänger für neu gespeicherte Preconfs einer Chain
+pub(crate) fn subscribe(chain_id: u64) -> broadcast::Receiver<Arc<StoredPreconf>> {
+    updates(chain_id).subscribe()
+}
+
+/// Sink: reicht gespeicherte Preconfs an die Push-Abonnenten der jeweiligen Chain weiter
+pub async fn run_push_sink(mut receiver: mpsc::Receiver<Arc<StoredPreconf>>, running: Arc<Mutex<bool>>) {
+    while let Some(preconf) = receiver.recv().await {
+        if !*running.lock().unwrap() {
+            break;
+        }
+        // Ohne Abonnenten schlägt send fehl, das ist kein Fehler
+        let _ = updates(preconf.chain_id).send(preconf);
+    }
+}
+
+/// Macht den Store über HTTP-API und/oder gRPC verfügbar (startet die Server beim ersten Aufruf) und nimmt
+/// ihn wieder heraus, sobald `running` false wird. Zugriffsschutz und Adressen gelten ab dem ersten Aufruf.
+pub fn register(
+    http_addr: Option<SocketAddr>,
+    grpc_addr: Option<SocketAddr>,
+    admin_addr: Option<SocketAddr>,
+    access_control: AccessControl,
+    tls: Option<TlsSettings>,
+    store: Arc<PreconfStore>,
+    running: Arc<Mutex<bool>>,
+) {
+    let chain_id = store.chain_id();
+    if let Ok(mut chains) = chains().write() {
+        chains.insert(chain_id, store);
+    }
+
+    let serving = *SERVER.get_or_init(|| {
+        start_server(http_addr, grpc_addr, admin_addr, access_control, tls);
+        (http_addr, grpc_addr, admin_addr)
+    });
+    if serving != (http_addr, grpc_addr, admin_addr) {
+        warn!(
+            "⚠️  Serving API already started (http {:?}, grpc {:?}, admin {:?}), chain {} is served there",
+            serving.0, serving.1, serving.2, chain_id
+        );
+    }
+
+    tokio::spawn(async move {
+        while *running.lock().unwrap() {
+            tokio::time::sleep(Duration::from_secs(1)).await;
+        }
+        if let Ok(mut chains) = chains().write() {
+            chains.remove(&chain_id);
+        }
+    });
+}
+
+fn start_server(
+    http_addr: Option<SocketAddr>,
+    grpc_addr: Option<SocketAddr>,
+    admin_addr: Option<SocketAddr>,
+    access_control: AccessControl,
+    tls: Option<TlsSettings>,
+) {
+    std::thread::spawn(move || {
+        let rt = match tokio::runtime::Builder::new_multi_thread()
+            .worker_threads(1)
+            .thread_name("kona-serve")
+            .enable_all()
+            .build()
+        {
+            Ok(rt) => rt,
+            Err(e) => {
+                error!("❌ Failed to create runtime for the serving API: {}", e);
+                return;
+            }
+        };
+        rt.block_on(async move {
+            // Ohne gültiges Zertifikat lieber gar nicht als unverschlüsselt
+            let tls = match tls.as_ref().map(Tls::start).transpose() {
+                Ok(tls) => tls,
+                Err(e) => {
+                    error!("❌ Serving API disabled, TLS setup failed: {}", e);
+                    return;
+                }
+            };
+            let access_control = Arc::new(access_control);
+            // Mit eigenem Admin-Listener hängen die Admin-Routen nicht mehr an der öffentlichen HTTP-API
+            let api = match admin_addr {
+                Some(_) => api_router(),
+                None => api_router().merge(admin_router()),
+            };
+            let http = run_http_server("Serving API", http_addr, api, access_control.clone(), tls.clone());
+            let admin = run_http_server("Admin API", admin_addr, admin_router(), access_control.clone(), tls.clone());
+            let grpc = async move {
+                if let Some(addr) = grpc_addr {
+                    grpc::run_grpc_server(addr, access_control, tls).await;
+                }
+            };
+            tokio::join!(http, admin, grpc);
+        });
+    });
+}
+
+async fn run_http_server(
+    name: &str,
+    addr: Option<SocketAddr>,
+    router: Router,
+    access_control: Arc<AccessControl>,
+    tls: Option<Arc<Tls>>,
+) {
+    let Some(addr) = addr else {
+        return;
+    };
+    let listener = match handoff::bind_tcp(addr).await {
+        Ok(listener) => listener,
+        Err(e) => {
+            warn!("⚠️  {} disabled, cannot bind {}: {}", name, addr, e);
+            return;
+        }
+    };
+    let app = router
+        .layer(middleware::from_fn_with_state(access_control, access::check))
+        .into_make_service_with_connect_info::<SocketAddr>();
+    let result = match tls {
+        Some(tls) => {
+            let config = tls.server_config(tls::ALPN_HTTP);
+            let listener = match config.and_then(|config| Ok(TlsListener::new(listener, config)?)) {
+                Ok(listener) => listener,
+                Err(e) => {
+                    warn!("⚠️  {} disabled, TLS setup failed: {}", name, e);
+                    return;
+                }
+            };
+            info!("🌍 {} listening on https://{}", name, addr);
+            axum::serve(listener, app).with_graceful_shutdown(handoff::handed_over()).await
+        }
+        None => {
+            info!("🌍 {} listening on http://{}", name, addr);
+            axum::serve(listener, app).with_graceful_shutdown(handoff::handed_over()).await
+        }
+    };
+    if let Err(e) = result {
+        warn!("⚠️  {} stopped: {}", name, e);
+    }
+}
+
+fn api_router() -> Router {
+    Router::new()
+        .route("/v1/chains/{chain_id}/preconfs", get(get_range))
+        .route("/v1/chains/{chain_id}/preconfs/latest", get(get_latest))
+        .route("/v1/chains/{chain_id}/preconfs/{block}", get(get_preconf))
+        .route("/v1/chains/{chain_id}/head", get(get_head))
+        .route("/v1/chains/{chain_id}/signers", get(get_signers))
+        .route("/v1/chains/{chain_id}/reorgs", get(get_reorgs))
+        .route("/v1/chains/{chain_id}/events", get(get_events))
+        .route("/v1/chains/{chain_id}/ws", get(get_ws))
+        .route("/v1/chains/{chain_id}/rpc", post(rpc::post_rpc))
+        .route("/healthz", get(health::get_healthz))
+        .route("/readyz", get(health::get_readyz))
+}
+
+fn admin_router() -> Router {
+    Router::new()
+        .route("/admin/chains/{chain_id}/purge", post(admin::post_purge))
+        .route("/admin/chains/{chain_id}/cleanup", post(admin::post_cleanup))
+        .route("/admin/chains/{chain_id}/backfill", post(admin::post_backfill))
+        .route("/admin/chains/{chain_id}/reload", post(admin::post_reload))
+        .route("/admin/chains/{chain_id}/rotate-latest", post(admin::post_rotate_latest))
+}
+
+#[derive(Deserialize)]
+struct PreconfQuery {
+    format: Option<String>,
+    min_confidence: Option<u64>, // nur Blöcke mit mindestens diesem Vertrauenswert (siehe confidence.rs)
+}
+
+#[derive(Deserialize)]
+struct RangeQuery {
+    from: Option<u64>,
+    to: Option<u64>, // Default: neuester Block
+    limit: Option<usize>,
+    cursor: Option<String>, // next_cursor der vorherigen Seite, ersetzt from
+    #[serde(default)]
+    payload: u8, // 1 = Payload (hex) und Signatur mitsenden
+    min_confidence: Option<u64>,
+}
+
+#[derive(Deserialize)]
+struct ReorgQuery {
+    since: Option<u64>, // Unix-Sekunden, ab denen Reorgs erkannt wurden
+    limit: Option<usize>,
+}
+
+#[derive(Deserialize)]
+struct PushQuery {
+    #[serde(default)]
+    payload: u8, // 1 = Payload (hex) und Signatur mitsenden
+}
+
+/// Fehlerantwort als JSON ({"error": ...})
+fn error_response(status: StatusCode, message: impl ToString) -> Response {
+    (status, Json(serde_json::json!({ "error": message.to_string() }))).into_response()
+}
+
+fn chain_not_served(chain_id: u64) -> Response {
+    error_response(StatusCode::NOT_FOUND, format!("chain {} not served", chain_id))
+}
+
+async fn get_preconf(
+    Path((chain_id, block_number)): Path<(u64, u64)>,
+    Query(query): Query<PreconfQuery>,
+    headers: HeaderMap,
+) -> Response {
+    let Some(store) = served_store(chain_id) else {
+        return chain_not_served(chain_id);
+    };
+    if let Some(min) = query.min_confidence {
+        if store.filename_by_number(block_number).is_some() && !meets_confidence(&store, block_number, min).await {
+            return error_response(StatusCode::NOT_FOUND, format!("block {} below confidence {}", block_number, min));
+        }
+    }
+    negotiated_response(&store, block_number, query.format.as_deref(), &headers).await
+}
+
+async fn get_latest(Path(chain_id): Path<u64>, Query(query): Query<PreconfQuery>, headers: HeaderMap) -> Response {
+    let Some(store) = served_store(chain_id) else {
+        return chain_not_served(chain_id);
+    };
+    let Some((latest, _)) = store.latest_entry() else {
+        return error_response(StatusCode::NOT_FOUND, "no preconf stored yet");
+    };
+    let Some(min) = query.min_confidence else {
+        return negotiated_response(&store, latest, query.format.as_deref(), &headers).await;
+    };
+
+    // Neuester Block mit ausreichendem Vertrauenswert (höchstens MAX_SCAN_PER_PAGE Höhen zurück)
+    let oldest = latest.saturating_sub(MAX_SCAN_PER_PAGE - 1);
+    for block_number in (oldest..=latest).rev() {
+        if store.filename_by_number(block_number).is_some() && meets_confidence(&store, block_number, min).await {
+            return negotiated_response(&store, block_number, query.format.as_deref(), &headers).await;
+        }
+    }
+    error_response(StatusCode::NOT_FOUND, format!("no recent preconf with confidence {}", min))
+}
+
+/// Ob die Metadaten eines Blocks mindestens den Vertrauenswert `min` nennen (ohne Metadaten: nein)
+async fn meets_confidence(store: &PreconfStore, block_number: u64, min: u64) -> bool {
+    store
+        .read_metadata(block_number)
+        .await
+        .is_some_and(|metadata| confidence::of(&metadata) >= min)
+}
+
+/// Medientypen im Accept-Header und das Format, das sie auswählen
+const MEDIA_TYPES: [(&str, &str); 3] = [
+    ("application/json", "decoded"),
+    ("application/octet-stream", "raw"),
+    ("application/cbor", "cbor"),
+];
+
+/// Format aus ?format= oder, falls nicht angegeben, aus dem Accept-Header (höchster q-Wert gewinnt,
+/// bei Gleichstand der erste). None = kein unterstützter Typ akzeptiert.
+fn negotiate_format(format: Option<&str>, headers: &HeaderMap) -> Option<String> {
+    if let Some(format) = format {
+        return Some(format.to_string());
+    }
+    let Some(accept) = headers.get(header::ACCEPT).and_then(|value| value.to_str().ok()) else {
+        return Some("json".to_string());
+    };
+    let mut best: Option<(f32, &str)> = None;
+    for range in accept.split(',') {
+        let mut parts = range.split(';');
+        let media_type = parts.next().unwrap_or_default().trim().to_ascii_lowercase();
+        let quality = parts
+            .find_map(|param| param.trim().strip_prefix("q=").and_then(|q| q.parse::<f32>().ok()))
+            .unwrap_or(1.0);
+        if quality <= 0.0 {
+            continue;
+        }
+        let format = match media_type.as_str() {
+            "*/*" | "application/*" => "json",
+            media_type => match MEDIA_TYPES.iter().find(|(accepted, _)| *accepted == media_type) {
+                Some((_, format)) => format,
+                None => continue,
+            },
+        };
+        if best.map_or(true, |(best_quality, _)| quality > best_quality) {
+            best = Some((quality, format));
+        }
+    }
+    best.map(|(_, format)| format.to_string())
+}
+
+/// Antwort im per ?format= oder Accept gewählten Format
+async fn negotiated_response(store: &PreconfStore, block_number: u64, format: Option<&str>, headers: &HeaderMap) -> Response {
+    let Some(negotiated) = negotiate_format(format, headers) else {
+        let supported: Vec<&str> = MEDIA_TYPES.iter().map(|(media_type, _)| *media_type).collect();
+        return error_response(StatusCode::NOT_ACCEPTABLE, format!("supported media types: {}", supported.join(", ")));
+    };
+    let mut response = preconf_response(store, block_number, &negotiated).await;
+    if format.is_none() {
+        response.headers_mut().append(header::VARY, HeaderValue::from_static("Accept"));
+    }
+    response
+}
+
+/// Eine Seite gespeicherter Blöcke from..=to (fehlende werden übersprungen); next_cursor ist null,
+/// sobald der Bereich vollständig geliefert ist
+async fn get_range(Path(chain_id): Path<u64>, Query(query): Query<RangeQuery>) -> Response {
+    let Some(store) = served_store(chain_id) else {
+        return chain_not_served(chain_id);
+    };
+    let from = match (query.cursor.as_deref(), query.from) {
+        (Some(cursor), _) => match cursor.parse::<u64>() {
+            Ok(from) => from,
+            Err(_) => return error_response(StatusCode::BAD_REQUEST, "invalid cursor"),
+        },
+        (None, Some(from)) => from,
+        (None, None) => return error_response(StatusCode::BAD_REQUEST, "from or cursor required"),
+    };
+    let Some(to) = query.to.or_else(|| store.latest_entry().map(|(number, _)| number)) else {
+        return Json(serde_json::json!({ "chain_id": chain_id, "items": [], "next_cursor": null })).into_response();
+    };
+    if from > to {
+        return error_response(StatusCode::BAD_REQUEST, "from > to");
+    }
+    let with_payload = query.payload != 0;
+    let max_limit = if with_payload { MAX_PAGE_SIZE_WITH_PAYLOAD } else { MAX_PAGE_SIZE };
+    let limit = query.limit.unwrap_or(DEFAULT_PAGE_SIZE).clamp(1, max_limit);
+
+    let mut items = Vec::with_capacity(limit);
+    let scan_end = to.min(from.saturating_add(MAX_SCAN_PER_PAGE - 1));
+    let mut next = Some(from);
+    while let Some(block_number) = next.filter(|number| *number <= scan_end && items.len() < limit) {
+        next = block_number.checked_add(1);
+        let Some(filename) = store.filename_by_number(block_number) else {
+            continue;
+        };
+        let metadata = store.read_metadata(block_number).await;
+        if let Some(min) = query.min_confidence {
+            if !metadata.as_ref().is_some_and(|metadata| confidence::of(metadata) >= min) {
+                continue;
+            }
+        }
+        let mut item = serde_json::json!({ "block_number": block_number, "metadata": metadata });
+        if with_payload {
+            match load_payload(&store, &filename).await {
+                Ok(Some((payload, signature))) => {
+                    item["payload"] = serde_json::json!(payload);
+                    item["signature"] = serde_json::json!(signature);
+                }
+                // Zwischen Lookup und Lesen gelöscht
+                Ok(None) => continue,
+                Err(e) => return error_response(StatusCode::INTERNAL_SERVER_ERROR, e),
+            }
+        }
+        items.push(item);
+    }
+
+    let next_cursor = next.filter(|number| *number <= to).map(|number| number.to_string());
+    Json(serde_json::json!({
+        "chain_id": chain_id,
+        "from": from,
+        "to": to,
+        "items": items,
+        "next_cursor": next_cursor,
+    }))
+    .into_response()
+}
+
+/// Payload und Signatur einer .raw Datei als Hex (None, wenn sie nicht mehr existiert)
+async fn load_payload(
+    store: &PreconfStore,
+    filename: &str,
+) -> Result<Option<(String, String)>, Box<dyn std::error::Error + Send + Sync>> {
+    let data = match store.read_raw(filename).await {
+        Ok(data) => data,
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => return Ok(None),
+        Err(e) => return Err(e.into()),
+    };
+    tokio::task::spawn_blocking(move || {
+        let container = PreconfContainer::parse(&data)?;
+        Ok(Some((
+            format!("0x{}", hex::encode(container.payload()?)),
+            format!("0x{}", hex::encode(container.signature)),
+        )))
+    })
+    .await?
+}
+
+/// Kurzer Überblick über den neuesten Block, ohne Payload
+async fn get_head(Path(chain_id): Path<u64>) -> Response {
+    let Some(store) = served_store(chain_id) else {
+        return chain_not_served(chain_id);
+    };
+    let Some((block_number, entry)) = store.latest_entry() else {
+        return error_response(StatusCode::NOT_FOUND, "no preconf stored yet");
+    };
+    let now = SystemTime::now().duration_since(UNIX_EPOCH).map(|d| d.as_secs()).unwrap_or(0);
+    let produced = if entry.timestamp > 0 { entry.timestamp } else { entry.received_unix };
+    let signer = store.read_metadata(block_number).await.and_then(|metadata| metadata.get("signer").cloned());
+    Json(serde_json::json!({
+        "chain_id": chain_id,
+        "number": block_number,
+        "hash": format!("0x{}", hex::encode(entry.block_hash)),
+        "timestamp": entry.timestamp,
+        "received_unix": entry.received_unix,
+        "signer": signer,
+        "age_secs": now.saturating_sub(produced),
+    }))
+    .into_response()
+}
+
+/// Verlauf aller wiederhergestellten Signer mit erstem/letztem Block und Anzahl
+async fn get_signers(Path(chain_id): Path<u64>) -> Response {
+    let Some(store) = served_store(chain_id) else {
+        return chain_not_served(chain_id);
+    };
+    Json(serde_json::json!({ "chain_id": chain_id, "signers": store.signer_history() })).into_response()
+}
+
+/// Seit Start erkannte Reorgs (beide Hashes, Quellen, verdrängte Datei unter superseded/), neueste zuerst
+async fn get_reorgs(Path(chain_id): Path<u64>, Query(query): Query<ReorgQuery>) -> Response {
+    let Some(store) = served_store(chain_id) else {
+        return chain_not_served(chain_id);
+    };
+    let limit = query.limit.unwrap_or(DEFAULT_PAGE_SIZE).clamp(1, MAX_PAGE_SIZE);
+    let reorgs = store.reorgs(query.since.unwrap_or(0), limit);
+    Json(serde_json::json!({ "chain_id": chain_id, "reorgs": reorgs })).into_response()
+}
+
+/// Antwort für einen gespeicherten Block im gewünschten Format
+async fn preconf_response(store: &PreconfStore, block_number: u64, format: &str) -> Response {
+    let chain_id = store.chain_id();
+    if !matches!(format, "json" | "decoded" | "raw" | "ssz" | "c4" | "cbor") {
+        return error_response(
+            StatusCode::BAD_REQUEST,
+            format!("unknown format {}, expected json, decoded, raw, ssz, c4 or cbor", format),
+        );
+    }
+
+    let Some(filename) = store.filename_by_number(block_number) else {
+        return error_response(StatusCode::NOT_FOUND, format!("block {} not found", block_number));
+    };
+    if format == "raw" {
+        if let Some(data) = store.hot_get(&filename) {
+            return ([(header::CONTENT_TYPE, "application/octet-stream")], data.to_vec()).into_response();
+        }
+    }
+    // Beim Speichern erzeugter OP_PRECONF-Container (KONA_BRIDGE_STORE_C4)
+    if matches!(format, "ssz" | "c4") {
+        if let Some(proof) = store.read_c4(block_number).await {
+            let body = if format == "c4" { block_proof_request(&proof) } else { proof };
+            return ([(header::CONTENT_TYPE, "application/octet-stream")], body).into_response();
+        }
+    }
+    let data = match store.read_raw(&filename).await {
+        Ok(data) => data,
+        // Datei kann zwischen Lookup und Lesen vom TTL-Cleanup entfernt worden sein
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => {
+            return error_response(StatusCode::NOT_FOUND, format!("block {} not found", block_number));
+        }
+        Err(e) => return error_response(StatusCode::INTERNAL_SERVER_ERROR, e),
+    };
+
+    // Dekompression und SSZ-Dekodierung blockieren -> nicht im Async-Thread
+    let decoded = match format {
+        "raw" => tokio::task::spawn_blocking(move || to_legacy(&data, chain_id).map(Body::Raw)).await,
+        "ssz" => tokio::task::spawn_blocking(move || preconf_proof(&data, chain_id).map(Body::Raw)).await,
+        "c4" => tokio::task::spawn_blocking(move || {
+            preconf_proof(&data, chain_id).map(|proof| Body::Raw(block_proof_request(&proof)))
+        })
+        .await,
+        "decoded" => tokio::task::spawn_blocking(move || {
+            let container = PreconfContainer::parse(&data)?;
+            let signature = format!("0x{}", hex::encode(container.signature));
+            Ok(Body::Json(serde_json::json!({ "signature": signature, "block": render_raw(&data)? })))
+        })
+        .await,
+        "cbor" => tokio::task::spawn_blocking(move || {
+            let container = PreconfContainer::parse(&data)?;
+            Ok(Body::Cbor(vec![
+                ("signature".to_string(), Cbor::Bytes(container.signature.to_vec())),
+                ("payload".to_string(), Cbor::Bytes(container.payload()?)),
+            ]))
+        })
+        .await,
+        _ => tokio::task::spawn_blocking(move || {
+            let container = PreconfContainer::parse(&data)?;
+            Ok(Body::Json(serde_json::json!({
+                "signature": format!("0x{}", hex::encode(container.signature)),
+                "payload": format!("0x{}", hex::encode(container.payload()?)),
+            })))
+        })
+        .await,
+    };
+    let mut response = match decoded {
+        Ok(Ok(Body::Raw(data))) => return ([(header::CONTENT_TYPE, "application/octet-stream")], data).into_response(),
+        Ok(Ok(Body::Json(response))) => response,
+        Ok(Ok(Body::Cbor(fields))) => {
+            let metadata = store.read_metadata(block_number).await.unwrap_or(serde_json::Value::Null);
+            let mut entries = vec![
+                ("chain_id".to_string(), Cbor::Unsigned(chain_id)),
+                ("block_number".to_string(), Cbor::Unsigned(block_number)),
+            ];
+            entries.extend(fields);
+            entries.push(("metadata".to_string(), Cbor::from(&metadata)));
+            return ([(header::CONTENT_TYPE, "application/cbor")], Cbor::Map(entries).encode()).into_response();
+        }
+        Ok(Err(e)) => return error_response(StatusCode::INTERNAL_SERVER_ERROR, e),
+        Err(e) => return error_response(StatusCode::INTERNAL_SERVER_ERROR, e),
+    };
+
+    response["chain_id"] = serde_json::json!(chain_id);
+    response["block_number"] = serde_json::json!(block_number);
+    response["metadata"] = store.read_metadata(block_number).await.unwrap_or(serde_json::Value::Null);
+    Json(response).into_response()
+}
+
+enum Body {
+    Raw(Vec<u8>),
+    Json(serde_json::Value),
+    Cbor(Vec<(String, Cbor)>),
+}
+
+/// Zählt eine Push-Verbindung (SSE, WebSocket, gRPC-Stream), solange der Guard lebt
+pub(crate) struct Subscription;
+
+impl Subscription {
+    pub(crate) fn open() -> Option<Self> {
+        if SUBSCRIBERS.fetch_add(1, Ordering::Relaxed) >= MAX_SUBSCRIBERS {
+            SUBSCRIBERS.fetch_sub(1, Ordering::Relaxed);
+            return None;
+        }
+        Some(Subscription)
+    }
+}
+
+impl Drop for Subscription {
+    fn drop(&mut self) {
+        SUBSCRIBERS.fetch_sub(1, Ordering::Relaxed);
+    }
+}
+
+/// Push-Nachricht zu einem neu gespeicherten Preconf
+async fn push_event(preconf: &StoredPreconf, with_payload: bool) -> serde_json::Value {
+    let mut event = serde_json::json!({
+        "chain_id": preconf.chain_id,
+        "block_number": preconf.block_number,
+        "block_hash": format!("0x{}", hex::encode(preconf.block_hash)),
+        "timestamp": preconf.timestamp,
+        "received_unix": preconf.received_unix,
+        "metadata": preconf.metadata,
+    });
+    if with_payload {
+        let data = preconf.raw_data.clone();
+        let decoded = tokio::task::spawn_blocking(move || {
+            let container = PreconfContainer::parse(&data)?;
+            Ok::<_, Box<dyn std::error::Error + Send + Sync>>((container.payload()?, container.signature.to_vec()))
+        })
+        .await;
+        match decoded {
+            Ok(Ok((payload, signature))) => {
+                event["payload"] = serde_json::json!(format!("0x{}", hex::encode(payload)));
+                event["signature"] = serde_json::json!(format!("0x{}", hex::encode(signature)));
+            }
+            Ok(Err(e)) => event["payload_error"] = serde_json::json!(e.to_string()),
+            Err(e) => event["payload_error"] = serde_json::json!(e.to_string()),
+        }
+    }
+    event
+}
+
+/// Nächste Nachricht für einen Abonnenten: Preconf-Event oder Hinweis auf verlorene Events (None = Kanal zu)
+async fn next_push(
+    updates: &mut broadcast::Receiver<Arc<StoredPreconf>>,
+    with_payload: bool,
+) -> Option<(&'static str, serde_json::Value)> {
+    match updates.recv().await {
+        Ok(preconf) => Some(("preconf", push_event(&preconf, with_payload).await)),
+        Err(broadcast::error::RecvError::Lagged(skipped)) => Some(("lagged", serde_json::json!({ "skipped": skipped }))),
+        Err(broadcast::error::RecvError::Closed) => None,
+    }
+}
+
+async fn get_events(Path(chain_id): Path<u64>, Query(query): Query<PushQuery>) -> Response {
+    if served_store(chain_id).is_none() {
+        return chain_not_served(chain_id);
+    }
+    let Some(subscription) = Subscription::open() else {
+        return error_response(StatusCode::SERVICE_UNAVAILABLE, "too many subscribers");
+    };
+    let mut updates = subscribe(chain_id);
+    let with_payload = query.payload != 0;
+
+    // Events in einem eigenen Task erzeugen (Payload-Dekodierung ist async); endet, wenn der Client trennt
+    let (sender, receiver) = mpsc::channel::<Result<Event, Infallible>>(16);
+    tokio::spawn(async move {
+        let _subscription = subscription;
+        while let Some((kind, body)) = next_push(&mut updates, with_payload).await {
+            let mut event = Event::default().event(kind).data(body.to_string());
+            if kind == "preconf" {
+                event = event.id(body["block_number"].to_string());
+            }
+            if sender.send(Ok(event)).await.is_err() {
+                break;
+            }
+        }
+        debug!("📡 SSE subscriber for chain {} disconnected", chain_id);
+    });
+
+    Sse::new(ReceiverStream::new(receiver))
+        .keep_alive(KeepAlive::new().interval(Duration::from_secs(15)))
+        .into_response()
+}
+
+async fn get_ws(Path(chain_id): Path<u64>, Query(query): Query<PushQuery>, upgrade: WebSocketUpgrade) -> Response {
+    if served_store(chain_id).is_none() {
+        return chain_not_served(chain_id);
+    }
+    let Some(subscription) = Subscription::open() else {
+        return error_response(StatusCode::SERVICE_UNAVAILABLE, "too many subscribers");
+    };
+    let updates = subscribe(chain_id);
+    let with_payload = query.payload != 0;
+    upgrade.on_upgrade(move |socket| async move {
+        let _subscription = subscription;
+        push_ws(socket, updates, with_payload).await;
+        debug!("📡 WebSocket subscriber for chain {} disconnected", chain_id);
+    })
+}
+
+/// Schickt Events als Text-Frames ({"type": ..., ...}); eingehende Frames außer Close werden ignoriert
+async fn push_ws(mut socket: WebSocket, mut updates: broadcast::Receiver<Arc<StoredPreconf>>, with_payload: bool) {
+    loop {
+        tokio::select! {
+            push = next_push(&mut updates, with_payload) => {
+                let Some((kind, mut body)) = push else {
+                    break;
+                };
+                body["type"] = serde_json::json!(kind);
+                if socket.send(Message::Text(body.to_string().into())).await.is_err() {
+                    break;
+                }
+            }
+            incoming = socket.recv() => match incoming {
+                Some(Ok(Message::Close(_))) | Some(Err(_)) | None => break,
+                Some(Ok(_)) => {}
+            },
+        }
+    }
+}
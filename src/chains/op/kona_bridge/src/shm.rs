@@ -0,0 +1,252 @@
+// shm.rs - Shared-Memory-Index (mmap) für die Übergabe an den C-Verifier
+//
+// Die Bridge schreibt nach jedem gespeicherten Preconf einen Slot in einen Ringpuffer,
+// der C-Server mappt die Datei read-only (siehe server/preconf_shm.c) und findet so
+// neue Blöcke ohne Verzeichnis-Scans oder Symlink-Races.
+//
+// Layout (native Byte-Order, nur für Leser auf demselben Host; muss mit preconf_shm.h übereinstimmen):
+//   Header (64 bytes): magic u32, version u32, chain_id u64, capacity u32, slot_size u32,
+//                      head u64 (Anzahl geschriebener Slots), latest_block u64, reserved
+//   Slot (128 bytes):  seq u64 (ungerade = wird geschrieben), block_number u64, block_hash [32],
+//                      received_unix u64, file_size u64, filename [64] (NUL-terminiert)
+
+use crate::perms;
+use std::{
+    fs::OpenOptions,
+    os::unix::io::AsRawFd,
+    path::Path,
+    sync::atomic::{fence, AtomicU64, Ordering},
+};
+use tracing::info;
+
+pub const SHM_MAGIC: u32 = 0x4B53_484D; // "KSHM"
+pub const SHM_VERSION: u32 = 1;
+pub const SHM_HEADER_SIZE: usize = 64;
+pub const SHM_SLOT_SIZE: usize = 128;
+pub const SHM_FILENAME_LEN: usize = 64;
+pub const SHM_DEFAULT_SLOTS: u32 = 1024;
+pub const SHM_FILE_NAME: &str = "preconf_index.shm";
+
+// Offsets im Header
+const HDR_MAGIC: usize = 0;
+const HDR_VERSION: usize = 4;
+const HDR_CHAIN_ID: usize = 8;
+const HDR_CAPACITY: usize = 16;
+const HDR_SLOT_SIZE: usize = 20;
+const HDR_HEAD: usize = 24;
+const HDR_LATEST_BLOCK: usize = 32;
+
+// Offsets im Slot
+const SLOT_SEQ: usize = 0;
+const SLOT_BLOCK_NUMBER: usize = 8;
+const SLOT_BLOCK_HASH: usize = 16;
+const SLOT_RECEIVED: usize = 48;
+const SLOT_FILE_SIZE: usize = 56;
+const SLOT_FILENAME: usize = 64;
+
+/// Schreibender Zugriff auf den mmap-Ringpuffer (nur ein Writer pro Datei)
+pub struct ShmIndex {
+    ptr: *mut u8,
+    len: usize,
+    capacity: u32,
+}
+
+// Der Pointer zeigt auf eine geteilte Mapping-Region; Schreibzugriffe erfolgen nur über publish()
+// mit Seqlock-Protokoll, daher ist die Weitergabe zwischen Threads sicher.
+unsafe impl Send for ShmIndex {}
+unsafe impl Sync for ShmIndex {}
+
+impl ShmIndex {
+    /// Öffnet oder erstellt die Index-Datei und mappt sie in den Speicher.
+    /// Eine bestehende Datei mit anderer Chain/Kapazität wird neu initialisiert.
+    pub fn open(path: &Path, chain_id: u64, capacity: u32) -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        let capacity = capacity.max(16);
+        let len = SHM_HEADER_SIZE + capacity as usize * SHM_SLOT_SIZE;
+
+        let file = OpenOptions::new().read(true).write(true).create(true).open(path)?;
+        perms::apply_file(path);
+        let reinit = file.metadata()?.len() != len as u64;
+        if reinit {
+            file.set_len(len as u64)?;
+        }
+
+        let ptr = unsafe {
+            libc::mmap(
+                std::ptr::null_mut(),
+                len,
+                libc::PROT_READ | libc::PROT_WRITE,
+                libc::MAP_SHARED,
+                file.as_raw_fd(),
+                0,
+            )
+        };
+        if ptr == libc::MAP_FAILED {
+            return Err(format!("mmap of {:?} failed: {}", path, std::io::Error::last_os_error()).into());
+        }
+
+        let index = Self {
+            ptr: ptr as *mut u8,
+            len,
+            capacity,
+        };
+
+        let valid = !reinit
+            && index.read_u32(HDR_MAGIC) == SHM_MAGIC
+            && index.read_u32(HDR_VERSION) == SHM_VERSION
+            && index.read_u64(HDR_CHAIN_ID) == chain_id
+            && index.read_u32(HDR_CAPACITY) == capacity;
+
+        if !valid {
+            // Neu initialisieren: magic zuletzt schreiben, damit Leser nie einen halben Header sehen
+            unsafe { std::ptr::write_bytes(index.ptr, 0, len) };
+            index.write_u32(HDR_VERSION, SHM_VERSION);
+            index.write_u64(HDR_CHAIN_ID, chain_id);
+            index.write_u32(HDR_CAPACITY, capacity);
+            index.write_u32(HDR_SLOT_SIZE, SHM_SLOT_SIZE as u32);
+            fence(Ordering::Release);
+            index.write_u32(HDR_MAGIC, SHM_MAGIC);
+        }
+
+        info!(
+            "🧠 Shared-memory index {:?}: {} slots (head: {})",
+            path,
+            capacity,
+            index.atomic_u64(HDR_HEAD).load(Ordering::Acquire)
+        );
+
+        Ok(index)
+    }
+
+    /// Veröffentlicht einen neuen Preconf im nächsten Slot (Seqlock pro Slot)
+    pub fn publish(&self, block_number: u64, block_hash: &[u8; 32], received_unix: u64, file_size: u64, filename: &str) {
+        let head = self.atomic_u64(HDR_HEAD).load(Ordering::Relaxed);
+        let slot = SHM_HEADER_SIZE + (head % self.capacity as u64) as usize * SHM_SLOT_SIZE;
+
+        let seq = self.atomic_u64(slot + SLOT_SEQ);
+        let start = seq.load(Ordering::Relaxed) | 1; // ungerade = in Arbeit
+        seq.store(start, Ordering::Relaxed);
+        fence(Ordering::Release);
+
+        self.write_u64(slot + SLOT_BLOCK_NUMBER, block_number);
+        self.write_bytes(slot + SLOT_BLOCK_HASH, block_hash);
+        self.write_u64(slot + SLOT_RECEIVED, received_unix);
+        self.write_u64(slot + SLOT_FILE_SIZE, file_size);
+
+        let mut name = [0u8; SHM_FILENAME_LEN];
+        let bytes = filename.as_bytes();
+        let n = bytes.len().min(SHM_FILENAME_LEN - 1);
+        name[..n].copy_from_slice(&bytes[..n]);
+        self.write_bytes(slot + SLOT_FILENAME, &name);
+
+        seq.store(start + 1, Ordering::Release); // gerade = stabil
+
+        let latest = self.atomic_u64(HDR_LATEST_BLOCK);
+        if block_number > latest.load(Ordering::Relaxed) {
+            latest.store(block_number, Ordering::Release);
+        }
+        self.atomic_u64(HDR_HEAD).store(head + 1, Ordering::Release);
+    }
+
+    fn atomic_u64(&self, offset: usize) -> &AtomicU64 {
+        debug_assert!(offset % 8 == 0 && offset + 8 <= self.len);
+        unsafe { &*(self.ptr.add(offset) as *const AtomicU64) }
+    }
+
+    fn read_u32(&self, offset: usize) -> u32 {
+        let mut buf = [0u8; 4];
+        unsafe { std::ptr::copy_nonoverlapping(self.ptr.add(offset), buf.as_mut_ptr(), 4) };
+        u32::from_ne_bytes(buf)
+    }
+
+    fn read_u64(&self, offset: usize) -> u64 {
+        let mut buf = [0u8; 8];
+        unsafe { std::ptr::copy_nonoverlapping(self.ptr.add(offset), buf.as_mut_ptr(), 8) };
+        u64::from_ne_bytes(buf)
+    }
+
+    fn write_u32(&self, offset: usize, value: u32) {
+        self.write_bytes(offset, &value.to_ne_bytes());
+    }
+
+    fn write_u64(&self, offset: usize, value: u64) {
+        self.write_bytes(offset, &value.to_ne_bytes());
+    }
+
+    fn write_bytes(&self, offset: usize, data: &[u8]) {
+        debug_assert!(offset + data.len() <= self.len);
+        unsafe { std::ptr::copy_nonoverlapping(data.as_ptr(), self.ptr.add(offset), data.len()) };
+    }
+}
+
+impl Drop for ShmIndex {
+    fn drop(&mut self) {
+        unsafe {
+            libc::munmap(self.ptr as *mut libc::c_void, self.len);
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn slot_block(index: &ShmIndex, slot: u64) -> u64 {
+        index.read_u64(SHM_HEADER_SIZE + (slot % index.capacity as u64) as usize * SHM_SLOT_SIZE + SLOT_BLOCK_NUMBER)
+    }
+
+    #[test]
+    fn backfill_keeps_latest_block() {
+        let dir = tempfile::tempdir().unwrap();
+        let index = ShmIndex::open(&dir.path().join(SHM_FILE_NAME), 10, 16).unwrap();
+        for block_number in [100, 101, 102] {
+            index.publish(block_number, &[0; 32], 0, 0, &format!("block_10_{}.raw", block_number));
+        }
+        // Backfill einer Lücke: neuester Slot, aber nicht der neueste Block
+        index.publish(90, &[0; 32], 0, 0, "block_10_90.raw");
+
+        assert_eq!(index.read_u64(HDR_HEAD), 4);
+        assert_eq!(slot_block(&index, 3), 90);
+        assert_eq!(index.read_u64(HDR_LATEST_BLOCK), 102);
+
+        index.publish(103, &[0; 32], 0, 0, "block_10_103.raw");
+        assert_eq!(index.read_u64(HDR_LATEST_BLOCK), 103);
+    }
+
+    #[test]
+    fn reopen_keeps_or_resets_ring() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join(SHM_FILE_NAME);
+        ShmIndex::open(&path, 10, 16).unwrap().publish(100, &[1; 32], 7, 8, "block_10_100.raw");
+
+        let index = ShmIndex::open(&path, 10, 16).unwrap();
+        assert_eq!((index.read_u64(HDR_HEAD), index.read_u64(HDR_LATEST_BLOCK)), (1, 100));
+        drop(index);
+
+        // Andere Chain: neu initialisiert
+        let index = ShmIndex::open(&path, 11, 16).unwrap();
+        assert_eq!(index.read_u64(HDR_CHAIN_ID), 11);
+        assert_eq!((index.read_u64(HDR_HEAD), index.read_u64(HDR_LATEST_BLOCK)), (0, 0));
+        assert_eq!(index.read_u32(HDR_MAGIC), SHM_MAGIC);
+    }
+
+    #[test]
+    fn ring_wraps_and_truncates_filenames() {
+        let dir = tempfile::tempdir().unwrap();
+        let index = ShmIndex::open(&dir.path().join(SHM_FILE_NAME), 10, 16).unwrap();
+        let long_name = "x".repeat(100);
+        for block_number in 0..20 {
+            index.publish(block_number, &[0; 32], 0, 0, &long_name);
+        }
+        assert_eq!(index.read_u64(HDR_HEAD), 20);
+        // Slot 3 ist überschrieben (Block 19), Slot 4 noch aus der ersten Runde
+        assert_eq!(slot_block(&index, 3), 19);
+        assert_eq!(slot_block(&index, 4), 4);
+
+        let slot = SHM_HEADER_SIZE + 3 * SHM_SLOT_SIZE;
+        let mut name = [0u8; SHM_FILENAME_LEN];
+        unsafe { std::ptr::copy_nonoverlapping(index.ptr.add(slot + SLOT_FILENAME), name.as_mut_ptr(), name.len()) };
+        assert_eq!(name[SHM_FILENAME_LEN - 1], 0);
+        assert!(name[..SHM_FILENAME_LEN - 1].iter().all(|b| *b == b'x'));
+        assert_eq!(index.read_u64(slot + SLOT_SEQ) % 2, 0);
+    }
+}
@@ -0,0 +1,515 @@
+// signer.rs - Unsafe-Block-Signer aus dem SystemConfig-Contract auf L1
+//
+// Betreiber rotieren den Sequencer-Key über SystemConfig.setUnsafeBlockSigner(); ein fest hinterlegter
+// oder vom C-Server übergebener Signer veraltet dann. Mit KONA_BRIDGE_L1_RPC liest die Bridge
+// unsafeBlockSigner() beim Start und danach alle KONA_BRIDGE_SIGNER_REFRESH_SECS (Default 300s).
+// Die Contract-Adresse kommt aus der Rollup-Konfiguration (kona-registry) oder KONA_BRIDGE_SYSTEM_CONFIG.
+// Der aktuelle Wert läuft über einen watch-Channel (ChainConfig::signer_updates) zum Gossip-Netzwerk,
+// das damit die Signaturen der empfangenen Blöcke prüft.
+//
+// Zusätzlich lassen sich mehrere Signer mit Gültigkeitsfenster konfigurieren (Key-Rotation, Shared
+// Sequencing): KONA_BRIDGE_SIGNERS="0xAddr[:valid_from[:valid_until]],..." (Unix-Sekunden, gegen den
+// Payload-Timestamp; valid_until exklusiv). Ist eine Liste oder der Live-Signer aktiv, prüft der Store
+// jede Signatur (auch HTTP), verwirft fremde Signer als invalid_block_*.json (reason invalid_signature)
+// und vermerkt den passenden Eintrag in den Metadaten. Das Gossip-Netzwerk kennt nur einen Signer:
+// es erhält den zuletzt aktivierten Eintrag und wird an den Fenstergrenzen umgestellt.
+// Mit KONA_BRIDGE_SIGNERS_FILE kommt die Liste (ergänzend) aus einer Datei, die bei Änderung oder
+// SIGHUP neu gelesen wird - eine Rotation greift dann ohne Neustart und ohne Reconnect der Gossip-Peers.
+// Die Recovery läuft in einem begrenzten Pool (VerifyPool, KONA_BRIDGE_VERIFY_WORKERS) parallel zu Dekodierung
+// und Hash-Prüfung; der Store wartet erst vor dem Schreiben auf das Ergebnis, die Reihenfolge bleibt erhalten.
+// Ergebnisse werden nach keccak256(payload) + Signatur gecacht, damit Re-Polls und derselbe Block aus
+// mehreren Quellen (HTTP und Gossip) nur einmal die teure Recovery durchlaufen.
+
+use crate::{profile, settings::SignerSettings};
+use alloy::primitives::{keccak256, Address, PrimitiveSignature, B256, U256};
+use kona_registry::ROLLUP_CONFIGS;
+use std::{
+    collections::{HashMap, VecDeque},
+    fs,
+    path::{Path, PathBuf},
+    sync::{Arc, Mutex},
+    time::{Duration, SystemTime, UNIX_EPOCH},
+};
+use tokio::{
+    sync::{watch, Semaphore},
+    task::JoinHandle,
+    time::interval,
+};
+use tracing::{debug, info, warn};
+
+/// keccak256("unsafeBlockSigner()")[..4]
+const UNSAFE_BLOCK_SIGNER_SELECTOR: &str = "0x1fd19ee1";
+
+pub const DEFAULT_SIGNER_REFRESH_SECS: u64 = 300;
+
+/// Prüfintervall für Fenstergrenzen der konfigurierten Signer
+const WINDOW_CHECK_INTERVAL: Duration = Duration::from_secs(10);
+
+/// Prüfintervall für Änderungen der Signer-Datei
+const FILE_CHECK_INTERVAL: Duration = Duration::from_secs(5);
+
+pub const DEFAULT_VERIFY_WORKERS: usize = 4;
+
+/// Unterverzeichnis für Preconfs mit ungültiger Signatur (nicht im Index, nicht für den C-Server)
+pub const QUARANTINE_DIR_NAME: &str = "quarantine";
+
+/// Umgang mit Preconfs, deren Signer nicht akzeptiert ist
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum InvalidSignatureMode {
+    Drop,       // verwerfen, nur zählen und loggen
+    #[default]
+    Quarantine, // Container + Begründung unter quarantine/ ablegen
+    Flag,       // normal speichern, in den Metadaten markieren
+}
+
+impl InvalidSignatureMode {
+    /// Parst "drop", "quarantine" oder "flag"
+    pub fn parse(value: &str) -> Option<Self> {
+        match value.trim().to_ascii_lowercase().as_str() {
+            "drop" | "strict" => Some(InvalidSignatureMode::Drop),
+            "quarantine" => Some(InvalidSignatureMode::Quarantine),
+            "flag" | "store" => Some(InvalidSignatureMode::Flag),
+            _ => None,
+        }
+    }
+
+    pub fn name(&self) -> &'static str {
+        match self {
+            InvalidSignatureMode::Drop => "drop",
+            InvalidSignatureMode::Quarantine => "quarantine",
+            InvalidSignatureMode::Flag => "flag",
+        }
+    }
+}
+
+/// Gecachte Recovery-Ergebnisse (ein Eintrag ~ 84 Bytes), Default; das Profil tiny setzt weniger (profile.rs)
+pub const RECOVERY_CACHE_SIZE: usize = 4096;
+
+/// Hash, den der Sequencer signiert (aus keccak256(payload))
+fn signing_hash(payload_hash: &[u8; 32], chain_id: u64) -> [u8; 32] {
+    let mut message = [0u8; 96];
+    message[56..64].copy_from_slice(&chain_id.to_be_bytes());
+    message[64..].copy_from_slice(payload_hash);
+    keccak256(message).0
+}
+
+fn recover_from_payload_hash(
+    payload_hash: &[u8; 32],
+    signature: &[u8],
+    chain_id: u64,
+) -> Result<Address, Box<dyn std::error::Error + Send + Sync>> {
+    if signature.len() != 65 {
+        return Err(format!("Invalid signature length {}", signature.len()).into());
+    }
+    let y = matches!(signature[64], 1 | 28);
+    let signature = PrimitiveSignature::new(U256::from_be_slice(&signature[..32]), U256::from_be_slice(&signature[32..64]), y);
+    Ok(signature.recover_address_from_prehash(&B256::from(signing_hash(payload_hash, chain_id)))?)
+}
+
+/// Stellt den Signer aus Payload und 65-Byte-Signatur (r, s, v mit v = 27/28 oder 0/1) wieder her
+pub fn recover_signer(payload: &[u8], signature: &[u8], chain_id: u64) -> Result<Address, Box<dyn std::error::Error + Send + Sync>> {
+    recover_from_payload_hash(&keccak256(payload).0, signature, chain_id)
+}
+
+/// Recovery-Ergebnisse nach keccak256(payload hash || signature), älteste Einträge fallen zuerst heraus
+struct RecoveryCache {
+    entries: HashMap<[u8; 32], Address>,
+    order: VecDeque<[u8; 32]>,
+    capacity: usize,
+}
+
+impl RecoveryCache {
+    fn key(payload_hash: &[u8; 32], signature: &[u8; 65]) -> [u8; 32] {
+        let mut data = [0u8; 97];
+        data[..32].copy_from_slice(payload_hash);
+        data[32..].copy_from_slice(signature);
+        keccak256(data).0
+    }
+
+    fn get(&self, key: &[u8; 32]) -> Option<Address> {
+        self.entries.get(key).copied()
+    }
+
+    fn insert(&mut self, key: [u8; 32], signer: Address) {
+        if self.entries.insert(key, signer).is_none() {
+            self.order.push_back(key);
+        }
+        while self.order.len() > self.capacity {
+            if let Some(oldest) = self.order.pop_front() {
+                self.entries.remove(&oldest);
+            }
+        }
+    }
+}
+
+/// Begrenzter Pool für die Signatur-Recovery (Blocking-Threads, höchstens `workers` gleichzeitig)
+#[derive(Clone)]
+pub struct VerifyPool {
+    permits: Arc<Semaphore>,
+    cache: Arc<Mutex<RecoveryCache>>,
+}
+
+impl VerifyPool {
+    pub fn new(workers: usize) -> Self {
+        let capacity = profile::current().signer_cache;
+        Self {
+            permits: Arc::new(Semaphore::new(workers.max(1))),
+            cache: Arc::new(Mutex::new(RecoveryCache {
+                entries: HashMap::with_capacity(capacity),
+                order: VecDeque::with_capacity(capacity),
+                capacity,
+            })),
+        }
+    }
+
+    /// Startet die Recovery im Hintergrund; der Aufrufer wartet erst, wenn er das Ergebnis braucht
+    pub fn recover(
+        &self,
+        payload: Vec<u8>,
+        signature: [u8; 65],
+        chain_id: u64,
+    ) -> JoinHandle<Result<Address, Box<dyn std::error::Error + Send + Sync>>> {
+        let permits = self.permits.clone();
+        let cache = self.cache.clone();
+        tokio::spawn(async move {
+            let _permit = permits.acquire_owned().await?;
+            tokio::task::spawn_blocking(move || {
+                let payload_hash = keccak256(&payload).0;
+                let key = RecoveryCache::key(&payload_hash, &signature);
+                if let Some(signer) = cache.lock().unwrap().get(&key) {
+                    debug!("🔐 Signature recovery cache hit: {}", signer);
+                    return Ok(signer);
+                }
+                let signer = recover_from_payload_hash(&payload_hash, &signature, chain_id)?;
+                cache.lock().unwrap().insert(key, signer);
+                Ok(signer)
+            })
+            .await?
+        })
+    }
+}
+
+/// Konfigurierter Signer mit Gültigkeitsfenster (Unix-Sekunden, None = offen)
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct SignerEntry {
+    pub address: Address,
+    pub valid_from: Option<u64>,
+    pub valid_until: Option<u64>,
+}
+
+impl SignerEntry {
+    /// Parst "0xAddr[:valid_from[:valid_until]]", leere Grenzen sind offen
+    pub fn parse(value: &str) -> Option<Self> {
+        let mut parts = value.trim().split(':');
+        let address = parts.next()?.trim().parse::<Address>().ok()?;
+        let mut bound = || -> Option<Option<u64>> {
+            match parts.next().map(str::trim) {
+                None | Some("") => Some(None),
+                Some(v) => v.parse().ok().map(Some),
+            }
+        };
+        let (valid_from, valid_until) = (bound()?, bound()?);
+        if parts.next().is_some() || matches!((valid_from, valid_until), (Some(from), Some(until)) if from >= until) {
+            return None;
+        }
+        Some(Self { address, valid_from, valid_until })
+    }
+
+    pub fn is_active(&self, timestamp: u64) -> bool {
+        self.valid_from.map_or(true, |from| timestamp >= from) && self.valid_until.map_or(true, |until| timestamp < until)
+    }
+}
+
+/// Zum Zeitpunkt aktiver Signer mit dem spätesten Beginn (für das Gossip-Netzwerk)
+pub fn active_signer(entries: &[SignerEntry], timestamp: u64) -> Option<Address> {
+    entries
+        .iter()
+        .filter(|entry| entry.is_active(timestamp))
+        .max_by_key(|entry| entry.valid_from.unwrap_or(0))
+        .map(|entry| entry.address)
+}
+
+/// Akzeptierte Signer für die Prüfung im Store: konfigurierte Einträge und der Live-Signer aus SystemConfig
+#[derive(Debug, Clone, Default)]
+pub struct SignerSet {
+    entries: Option<watch::Receiver<Vec<SignerEntry>>>, // None = keine Liste konfiguriert
+    live: Option<watch::Receiver<Address>>,
+}
+
+impl SignerSet {
+    pub fn new(entries: Option<watch::Receiver<Vec<SignerEntry>>>, live: Option<watch::Receiver<Address>>) -> Self {
+        Self { entries, live }
+    }
+
+    /// Ohne Liste und Live-Signer wird nicht geprüft
+    pub fn is_empty(&self) -> bool {
+        self.entries.is_none() && self.live.is_none()
+    }
+
+    /// Bezeichnung des passenden Signers ("signers[i]" bzw. "system_config"), None wenn keiner passt
+    pub fn matching(&self, signer: Address, timestamp: u64) -> Option<String> {
+        let matched = self.entries.as_ref().and_then(|entries| {
+            entries.borrow().iter().position(|entry| entry.address == signer && entry.is_active(timestamp))
+        });
+        if let Some(i) = matched {
+            return Some(format!("signers[{}]", i));
+        }
+        self.live
+            .as_ref()
+            .filter(|live| *live.borrow() == signer)
+            .map(|_| "system_config".to_string())
+    }
+}
+
+/// Stellt den Gossip-Signer an den Fenstergrenzen der konfigurierten Einträge um
+pub fn start_window_switch(
+    entries: watch::Receiver<Vec<SignerEntry>>,
+    initial: Address,
+    running: Arc<Mutex<bool>>,
+) -> watch::Receiver<Address> {
+    let now = || SystemTime::now().duration_since(UNIX_EPOCH).map(|d| d.as_secs()).unwrap_or(0);
+    let (sender, receiver) = watch::channel(active_signer(&entries.borrow(), now()).unwrap_or(initial));
+    tokio::spawn(async move {
+        let mut ticker = interval(WINDOW_CHECK_INTERVAL);
+        loop {
+            ticker.tick().await;
+            if !*running.lock().unwrap() {
+                break;
+            }
+            let Some(signer) = active_signer(&entries.borrow(), now()) else {
+                continue;
+            };
+            if signer != *sender.borrow() {
+                info!("🔐 Signer window switched: {} -> {}", *sender.borrow(), signer);
+                if sender.send(signer).is_err() {
+                    break;
+                }
+            }
+        }
+    });
+    receiver
+}
+
+/// Liest eine Signer-Datei: ein Eintrag pro Zeile oder kommagetrennt (wie KONA_BRIDGE_SIGNERS),
+/// `#` leitet Kommentare ein. Ein ungültiger Eintrag macht die ganze Datei ungültig.
+pub fn load_signers_file(path: &Path) -> Result<Vec<SignerEntry>, Box<dyn std::error::Error + Send + Sync>> {
+    let content = fs::read_to_string(path)?;
+    content
+        .lines()
+        .map(|line| line.split('#').next().unwrap_or_default())
+        .flat_map(|line| line.split(','))
+        .filter(|entry| !entry.trim().is_empty())
+        .map(|entry| SignerEntry::parse(entry).ok_or_else(|| format!("Invalid signer entry: {}", entry.trim()).into()))
+        .collect()
+}
+
+fn file_modified(path: &Path) -> Option<SystemTime> {
+    fs::metadata(path).and_then(|m| m.modified()).ok()
+}
+
+/// Wartet auf SIGHUP (ohne Signal-Handler nie)
+#[cfg(unix)]
+pub(crate) async fn hangup(signal: &mut Option<tokio::signal::unix::Signal>) {
+    match signal {
+        Some(signal) => {
+            signal.recv().await;
+        }
+        None => std::future::pending().await,
+    }
+}
+
+/// Wartet auf einen Reload über die Admin-API bzw. eine neue Liste (ohne Sender nie)
+async fn reload_requested<T>(reload: &mut Option<watch::Receiver<T>>) {
+    match reload {
+        Some(receiver) => {
+            if receiver.changed().await.is_err() {
+                *reload = None;
+                std::future::pending::<()>().await;
+            }
+        }
+        None => std::future::pending().await,
+    }
+}
+
+/// Liste aus KONA_BRIDGE_SIGNERS (`base`, neu geladen siehe reload.rs) plus Signer-Datei; die Datei wird bei
+/// Änderung (mtime), auf SIGHUP und auf Reload über die Admin-API neu gelesen. Ist sie nicht lesbar oder
+/// ungültig, bleibt die bisherige Liste aktiv.
+pub fn start_signers_file_watch(
+    path: PathBuf,
+    base: watch::Receiver<Vec<SignerEntry>>,
+    reload: watch::Receiver<()>,
+    running: Arc<Mutex<bool>>,
+) -> watch::Receiver<Vec<SignerEntry>> {
+    let mut base_updates = Some(base.clone());
+    let combined = move |file: Vec<SignerEntry>| base.borrow().iter().cloned().chain(file).collect::<Vec<_>>();
+    let initial = match load_signers_file(&path) {
+        Ok(file) => {
+            info!("🔐 Loaded {} signer(s) from {:?}", file.len(), path);
+            combined(file)
+        }
+        Err(e) => {
+            warn!("⚠️  Cannot load signers from {:?}: {}", path, e);
+            combined(Vec::new())
+        }
+    };
+    let (sender, receiver) = watch::channel(initial);
+
+    tokio::spawn(async move {
+        #[cfg(unix)]
+        let mut signal = tokio::signal::unix::signal(tokio::signal::unix::SignalKind::hangup())
+            .map_err(|e| warn!("⚠️  SIGHUP reload disabled: {}", e))
+            .ok();
+        let mut reload = Some(reload);
+        let mut ticker = interval(FILE_CHECK_INTERVAL);
+        let mut modified = file_modified(&path);
+        loop {
+            #[cfg(unix)]
+            let by_signal = tokio::select! {
+                _ = ticker.tick() => false,
+                _ = hangup(&mut signal) => true,
+                _ = reload_requested(&mut reload) => true,
+                _ = reload_requested(&mut base_updates) => true,
+            };
+            #[cfg(not(unix))]
+            let by_signal = tokio::select! {
+                _ = ticker.tick() => false,
+                _ = reload_requested(&mut reload) => true,
+                _ = reload_requested(&mut base_updates) => true,
+            };
+            if !*running.lock().unwrap() {
+                break;
+            }
+            let current = file_modified(&path);
+            if !by_signal && current == modified {
+                continue;
+            }
+            modified = current;
+            match load_signers_file(&path) {
+                Ok(file) => {
+                    let entries = combined(file);
+                    if entries != *sender.borrow() {
+                        info!("🔐 Reloaded signers from {:?}: {} signer(s)", path, entries.len());
+                        if sender.send(entries).is_err() {
+                            break;
+                        }
+                    }
+                }
+                Err(e) => warn!("⚠️  Cannot reload signers from {:?}, keeping previous list: {}", path, e),
+            }
+        }
+    });
+    receiver
+}
+
+/// SystemConfig-Adresse: explizit konfiguriert oder aus der Rollup-Konfiguration der Chain
+pub fn system_config_address(settings: &SignerSettings, chain_id: u64) -> Option<Address> {
+    settings.system_config.or_else(|| {
+        ROLLUP_CONFIGS
+            .get(&chain_id)
+            .map(|cfg| cfg.l1_system_config_address)
+            .filter(|address| *address != Address::ZERO)
+    })
+}
+
+/// Liest SystemConfig.unsafeBlockSigner() per eth_call
+pub async fn fetch_unsafe_signer(
+    client: &reqwest::Client,
+    l1_rpc: &str,
+    system_config: Address,
+) -> Result<Address, Box<dyn std::error::Error + Send + Sync>> {
+    let request = serde_json::json!({
+        "jsonrpc": "2.0",
+        "id": 1,
+        "method": "eth_call",
+        "params": [{"to": format!("{}", system_config), "data": UNSAFE_BLOCK_SIGNER_SELECTOR}, "latest"],
+    });
+    let response = client.post(l1_rpc).json(&request).timeout(Duration::from_secs(10)).send().await?;
+    if !response.status().is_success() {
+        return Err(format!("HTTP {} from {}", response.status(), l1_rpc).into());
+    }
+    let body: serde_json::Value = response.json().await?;
+    if let Some(error) = body.get("error") {
+        return Err(format!("eth_call failed: {}", error).into());
+    }
+    let result = body["result"].as_str().ok_or("eth_call without result")?;
+    let word = hex::decode(result.trim_start_matches("0x"))?;
+    if word.len() != 32 || word[..12].iter().any(|b| *b != 0) {
+        return Err(format!("Unexpected unsafeBlockSigner() result {}", result).into());
+    }
+    let signer = Address::from_slice(&word[12..]);
+    if signer == Address::ZERO {
+        return Err("unsafeBlockSigner() returned the zero address".into());
+    }
+    Ok(signer)
+}
+
+/// Liest den Signer beim Start und aktualisiert ihn im Hintergrund (periodisch und auf Reload über die
+/// Admin-API). Schlägt die erste Abfrage fehl, bleibt `initial` aktiv, bis eine spätere Abfrage gelingt.
+/// None, wenn keine SystemConfig-Adresse bekannt ist.
+pub async fn start_signer_refresh(
+    settings: &SignerSettings,
+    chain_id: u64,
+    initial: Address,
+    reload: watch::Receiver<()>,
+    running: Arc<Mutex<bool>>,
+) -> Option<watch::Receiver<Address>> {
+    let Some(system_config) = system_config_address(settings, chain_id) else {
+        warn!("⚠️  No SystemConfig address for chain {} - set KONA_BRIDGE_SYSTEM_CONFIG, using static signer", chain_id);
+        return None;
+    };
+
+    let client = reqwest::Client::new();
+    let signer = match fetch_unsafe_signer(&client, &settings.l1_rpc, system_config).await {
+        Ok(signer) => {
+            if signer != initial {
+                info!("🔐 Unsafe signer from SystemConfig {}: {} (configured: {})", system_config, signer, initial);
+            } else {
+                info!("🔐 Unsafe signer from SystemConfig {}: {}", system_config, signer);
+            }
+            signer
+        }
+        Err(e) => {
+            warn!("⚠️  Failed to read unsafe signer from SystemConfig {}: {} - using {}", system_config, e, initial);
+            initial
+        }
+    };
+
+    let (sender, receiver) = watch::channel(signer);
+    let refresh = Duration::from_secs(settings.refresh_secs.max(1));
+    let l1_rpc = settings.l1_rpc.clone();
+    tokio::spawn(async move {
+        let mut reload = Some(reload);
+        let mut ticker = interval(refresh);
+        ticker.tick().await; // erster Tick sofort, Startwert ist schon gelesen
+        loop {
+            tokio::select! {
+                _ = ticker.tick() => {}
+                _ = reload_requested(&mut reload) => info!("🔐 Refreshing unsafe signer (reload requested)"),
+            }
+            if !*running.lock().unwrap() {
+                break;
+            }
+            match fetch_unsafe_signer(&client, &l1_rpc, system_config).await {
+                Ok(signer) if signer != *sender.borrow() => {
+                    info!("🔐 Unsafe signer rotated: {} -> {}", *sender.borrow(), signer);
+                    if sender.send(signer).is_err() {
+                        break;
+                    }
+                }
+                Ok(signer) => debug!("🔐 Unsafe signer unchanged: {}", signer),
+                Err(e) => warn!("⚠️  Failed to refresh unsafe signer: {}", e),
+            }
+        }
+    });
+    Some(receiver)
+}
+
+/// Reicht Signer-Änderungen an das Gossip-Netzwerk weiter, bis eine Seite geschlossen wird
+pub async fn forward_signer_updates(mut updates: watch::Receiver<Address>, target: watch::Sender<Address>) {
+    while updates.changed().await.is_ok() {
+        let signer = *updates.borrow_and_update();
+        if target.send(signer).is_err() {
+            break;
+        }
+    }
+}
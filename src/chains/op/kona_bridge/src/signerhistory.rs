@@ -0,0 +1,126 @@
+// signerhistory.rs - Verlauf aller wiederhergestellten Signer mit Persistenz in signer_history.json
+//
+// Jeder Signer, den die Signaturprüfung aus einem Preconf wiederherstellt, wird mit erstem/letztem Block,
+// Zeitpunkten und Anzahl festgehalten, unabhängig davon, ob er akzeptiert wurde. So lassen sich Schlüsselwechsel
+// des Sequencers nachvollziehen und unerwartete Signer auch nachträglich erkennen. Der Verlauf läuft nicht per
+// TTL ab und überlebt Purges; geschrieben wird er zusammen mit dem Block-Index.
+
+use crate::durability::DurableFs;
+use alloy::primitives::Address;
+use serde::{Deserialize, Serialize};
+use std::{collections::HashMap, fs, path::Path};
+use tracing::{info, warn};
+
+pub const SIGNER_HISTORY_FILE_NAME: &str = "signer_history.json";
+const SIGNER_HISTORY_VERSION: u32 = 1;
+
+/// Ein Signer im Verlauf
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SignerRecord {
+    pub signer: String,
+    pub first_block: u64,
+    pub last_block: u64,
+    pub first_seen_unix: u64,
+    pub last_seen_unix: u64,
+    pub count: u64,
+    pub accepted: bool,                 // Ergebnis der letzten Prüfung
+    pub signer_match: Option<String>,   // signers[i] bzw. system_config der letzten akzeptierten Signatur
+    pub rejected_count: u64,
+}
+
+/// Serialisierte Form von signer_history.json
+#[derive(Serialize, Deserialize)]
+struct HistoryFile {
+    version: u32,
+    chain_id: u64,
+    signers: Vec<SignerRecord>,
+}
+
+#[derive(Default)]
+pub struct SignerHistory {
+    signers: HashMap<Address, SignerRecord>,
+    dirty: bool,
+}
+
+impl SignerHistory {
+    /// Lädt signer_history.json (fehlt die Datei oder ist sie unbrauchbar, beginnt der Verlauf leer)
+    pub fn load(output_dir: &Path, chain_id: u64) -> Self {
+        let mut history = Self::default();
+        let path = output_dir.join(SIGNER_HISTORY_FILE_NAME);
+        match fs::read(&path) {
+            Ok(data) => match serde_json::from_slice::<HistoryFile>(&data) {
+                Ok(file) if file.version == SIGNER_HISTORY_VERSION && file.chain_id == chain_id => {
+                    for record in file.signers {
+                        if let Ok(address) = record.signer.parse::<Address>() {
+                            history.signers.insert(address, record);
+                        }
+                    }
+                }
+                Ok(file) => warn!("⚠️  Ignoring {:?} (version {}, chain {})", path, file.version, file.chain_id),
+                Err(e) => warn!("⚠️  Corrupt {:?} ({}), starting a new signer history", path, e),
+            },
+            Err(e) if e.kind() == std::io::ErrorKind::NotFound => {}
+            Err(e) => warn!("⚠️  Cannot read {:?} ({}), starting a new signer history", path, e),
+        }
+        history
+    }
+
+    /// Vermerkt eine wiederhergestellte Signatur (`matched` = None: Signer nicht akzeptiert)
+    pub fn record(&mut self, signer: Address, block_number: u64, now: u64, matched: Option<&str>) {
+        let record = self.signers.entry(signer).or_insert_with(|| {
+            if matched.is_some() {
+                info!("🔑 New signer {} first seen at block {}", signer, block_number);
+            } else {
+                warn!("⚠️  Unexpected signer {} first seen at block {}", signer, block_number);
+            }
+            SignerRecord {
+                signer: format!("{}", signer),
+                first_block: block_number,
+                last_block: block_number,
+                first_seen_unix: now,
+                last_seen_unix: now,
+                count: 0,
+                accepted: false,
+                signer_match: None,
+                rejected_count: 0,
+            }
+        });
+        // Blöcke kommen nicht zwingend in Reihenfolge (Backfill, Gossip-Nachzügler)
+        record.first_block = record.first_block.min(block_number);
+        record.last_block = record.last_block.max(block_number);
+        record.last_seen_unix = now;
+        record.count += 1;
+        record.accepted = matched.is_some();
+        match matched {
+            Some(matched) => record.signer_match = Some(matched.to_string()),
+            None => record.rejected_count += 1,
+        }
+        self.dirty = true;
+    }
+
+    /// Alle Signer, nach erstem Block sortiert
+    pub fn records(&self) -> Vec<SignerRecord> {
+        let mut records: Vec<SignerRecord> = self.signers.values().cloned().collect();
+        records.sort_by_key(|record| (record.first_block, record.first_seen_unix));
+        records
+    }
+
+    /// Schreibt signer_history.json atomar, falls sich seit dem letzten Aufruf etwas geändert hat (true = geschrieben)
+    pub fn save_if_dirty(
+        &mut self,
+        output_dir: &Path,
+        chain_id: u64,
+        durable: &DurableFs,
+    ) -> Result<bool, Box<dyn std::error::Error + Send + Sync>> {
+        if !self.dirty {
+            return Ok(false);
+        }
+        let file = HistoryFile { version: SIGNER_HISTORY_VERSION, chain_id, signers: self.records() };
+        let path = output_dir.join(SIGNER_HISTORY_FILE_NAME);
+        durable
+            .write_atomic_blocking(&path.with_extension("json.tmp"), &path, &serde_json::to_vec_pretty(&file)?)
+            .map_err(|e| format!("Failed to write {:?}: {}", path, e))?;
+        self.dirty = false;
+        Ok(true)
+    }
+}
@@ -0,0 +1,136 @@
+// sink.rs - Verteilung gespeicherter Preconfs an optionale externe Sinks
+//
+// Jeder Sink läuft als eigener Task mit begrenzter Queue. Die Capture-Pfade (HTTP/Gossip)
+// übergeben nach dem lokalen Speichern nur noch an den Dispatcher und warten nie auf Sinks.
+
+use crate::{postgres, publish, redis, s3, serve, settings::BridgeSettings, stdout};
+use std::sync::{
+    atomic::{AtomicU64, Ordering},
+    Arc, Mutex,
+};
+use tokio::sync::mpsc;
+use tracing::{info, warn};
+
+/// Queue-Größe pro Sink (bei Überlauf werden Preconfs für diesen Sink verworfen)
+const SINK_QUEUE_SIZE: usize = 256;
+
+/// Ein lokal gespeicherter Preconf, wie er an Sinks übergeben wird
+#[derive(Debug)]
+pub struct StoredPreconf {
+    pub chain_id: u64,
+    pub block_number: u64,
+    pub block_hash: [u8; 32],
+    pub received_unix: u64,
+    pub timestamp: u64, // Payload-Timestamp (0 = unbekannt)
+    pub raw_filename: String,
+    pub raw_data: Vec<u8>,
+    pub metadata: serde_json::Value,
+}
+
+struct SinkChannel {
+    name: &'static str,
+    sender: mpsc::Sender<Arc<StoredPreconf>>,
+    dropped: AtomicU64,
+}
+
+/// Verteilt gespeicherte Preconfs an alle registrierten Sinks
+#[derive(Default)]
+pub struct SinkDispatcher {
+    channels: Vec<SinkChannel>,
+}
+
+impl SinkDispatcher {
+    pub fn new() -> Self {
+        Self { channels: Vec::new() }
+    }
+
+    /// Registriert einen Sink und gibt den Empfänger für dessen Task zurück
+    pub fn register(&mut self, name: &'static str) -> mpsc::Receiver<Arc<StoredPreconf>> {
+        let (sender, receiver) = mpsc::channel(SINK_QUEUE_SIZE);
+        self.channels.push(SinkChannel {
+            name,
+            sender,
+            dropped: AtomicU64::new(0),
+        });
+        receiver
+    }
+
+    /// Kein Sink registriert (dispatch verwirft dann alles)
+    pub fn is_empty(&self) -> bool {
+        self.channels.is_empty()
+    }
+
+    /// Übergibt einen Preconf an alle Sinks (nicht-blockierend)
+    pub fn dispatch(&self, preconf: StoredPreconf) {
+        if self.channels.is_empty() {
+            return;
+        }
+
+        let preconf = Arc::new(preconf);
+        for channel in &self.channels {
+            if let Err(e) = channel.sender.try_send(preconf.clone()) {
+                let dropped = channel.dropped.fetch_add(1, Ordering::Relaxed) + 1;
+                warn!(
+                    "⚠️  Sink {} cannot accept block {} ({}), dropped so far: {}",
+                    channel.name, preconf.block_number, e, dropped
+                );
+            }
+        }
+    }
+}
+
+/// Startet alle in den Settings konfigurierten Sinks
+pub fn start_sinks(settings: &BridgeSettings, running: Arc<Mutex<bool>>) -> SinkDispatcher {
+    let mut dispatcher = SinkDispatcher::new();
+
+    if let Some(ref s3_settings) = settings.s3 {
+        info!(
+            "🪣 S3 sink enabled: {}/{}/{}",
+            s3_settings.endpoint, s3_settings.bucket, s3_settings.prefix
+        );
+        let receiver = dispatcher.register("s3");
+        tokio::spawn(s3::run_s3_sink(s3_settings.clone(), receiver, running.clone()));
+    }
+
+    if let Some(ref pg_settings) = settings.postgres {
+        info!(
+            "🐘 Postgres sink enabled (payloads: {}, keep: {})",
+            pg_settings.store_payloads,
+            pg_settings.keep_blocks.map_or("all".to_string(), |n| format!("{} blocks", n))
+        );
+        let receiver = dispatcher.register("postgres");
+        tokio::spawn(postgres::run_postgres_sink(pg_settings.clone(), receiver, running.clone()));
+    }
+
+    if let Some(ref publish_settings) = settings.publish {
+        info!(
+            "📣 Publish sink enabled: {} (inline payload: {})",
+            publish_settings.target, publish_settings.inline_payload
+        );
+        let receiver = dispatcher.register("publish");
+        tokio::spawn(publish::run_publish_sink(publish_settings.clone(), receiver, running.clone()));
+    }
+
+    if settings.serve_addr.is_some() || settings.grpc_addr.is_some() {
+        info!("📡 Push sink enabled for SSE/WebSocket/gRPC subscribers");
+        let receiver = dispatcher.register("serve");
+        tokio::spawn(serve::run_push_sink(receiver, running.clone()));
+    }
+
+    if let Some(ref redis_settings) = settings.redis {
+        info!(
+            "🟥 Redis sink enabled: latest {} blocks, ttl {}s",
+            redis_settings.keep_blocks, redis_settings.ttl_secs
+        );
+        let receiver = dispatcher.register("redis");
+        tokio::spawn(redis::run_redis_sink(redis_settings.clone(), receiver, running.clone()));
+    }
+
+    if let Some(ref stdout_settings) = settings.stdout {
+        info!("📜 Stdout sink enabled: NDJSON (inline payload: {})", stdout_settings.payload);
+        let receiver = dispatcher.register("stdout");
+        tokio::spawn(stdout::run_stdout_sink(stdout_settings.clone(), receiver, running));
+    }
+
+    dispatcher
+}
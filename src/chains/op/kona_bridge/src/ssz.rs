@@ -0,0 +1,621 @@
+// ssz.rs - Strukturelles SSZ-Decoding des Preconf-Payloads
+//
+// Preconf-Daten: 32 Bytes parent_beacon_block_root + SSZ ExecutionPayload. Das Layout des Payloads
+// hängt vom Hardfork ab (Gossip-Topics blocks/v1..v4):
+//   v1 Bedrock   ExecutionPayloadV1 (ohne withdrawals)
+//   v2 Canyon    + withdrawals
+//   v3 Ecotone   + blob_gas_used, excess_blob_gas (parent_beacon_block_root im Envelope)
+//   v4 Isthmus   + withdrawals_root
+// Statt fester Byte-Offsets wird der feste Teil feldweise gelesen und die Offsets der variablen
+// Felder gegen Größe und Reihenfolge geprüft, damit ein geändertes Schema als Fehler auffällt statt
+// stillschweigend falsche Werte zu liefern. v1/v2 haben keinen parent_beacon_block_root; die Bridge
+// speichert dort 32 Null-Bytes, damit alle Versionen dasselbe Präfix haben.
+
+use op_alloy_rpc_types_engine::{ExecutionPayloadV1, OpExecutionPayload};
+
+/// Länge des Präfixes vor dem ExecutionPayload
+pub const ENVELOPE_PREFIX_SIZE: usize = 32;
+
+/// Gemeinsamer fester Teil aller Versionen bis einschließlich transactions-Offset
+const V1_FIXED_SIZE: usize = 32 + 20 + 32 + 32 + 256 + 32 + 8 + 8 + 8 + 8 + 4 + 32 + 32 + 4;
+
+/// Withdrawal: index (8) + validator_index (8) + address (20) + amount (8)
+const WITHDRAWAL_SIZE: usize = 44;
+
+/// Maximale Länge von extra_data
+const MAX_EXTRA_DATA: usize = 32;
+
+/// Payload-Version (entspricht der Version des Gossip-Topics)
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum PayloadVersion {
+    V1,
+    V2,
+    V3,
+    V4,
+}
+
+impl PayloadVersion {
+    /// Version des Gossip-Payloads
+    pub fn of(payload: &OpExecutionPayload) -> Self {
+        match payload {
+            OpExecutionPayload::V1(_) => PayloadVersion::V1,
+            OpExecutionPayload::V2(_) => PayloadVersion::V2,
+            OpExecutionPayload::V3(_) => PayloadVersion::V3,
+            OpExecutionPayload::V4(_) => PayloadVersion::V4,
+        }
+    }
+
+    /// Größe des festen Teils des ExecutionPayloads
+    pub fn fixed_size(self) -> usize {
+        match self {
+            PayloadVersion::V1 => V1_FIXED_SIZE,
+            PayloadVersion::V2 => V1_FIXED_SIZE + 4,
+            PayloadVersion::V3 => V1_FIXED_SIZE + 4 + 8 + 8,
+            PayloadVersion::V4 => V1_FIXED_SIZE + 4 + 8 + 8 + 32,
+        }
+    }
+
+    /// Version anhand des festen Teils (= Offset von extra_data, dem ersten variablen Feld)
+    pub fn from_fixed_size(size: usize) -> Option<Self> {
+        [PayloadVersion::V1, PayloadVersion::V2, PayloadVersion::V3, PayloadVersion::V4]
+            .into_iter()
+            .find(|v| v.fixed_size() == size)
+    }
+
+    /// Name für Metadaten ("v1".."v4")
+    pub fn name(self) -> &'static str {
+        match self {
+            PayloadVersion::V1 => "v1",
+            PayloadVersion::V2 => "v2",
+            PayloadVersion::V3 => "v3",
+            PayloadVersion::V4 => "v4",
+        }
+    }
+
+    /// Hardfork, mit dem die Version eingeführt wurde
+    pub fn hardfork(self) -> &'static str {
+        match self {
+            PayloadVersion::V1 => "bedrock",
+            PayloadVersion::V2 => "canyon",
+            PayloadVersion::V3 => "ecotone",
+            PayloadVersion::V4 => "isthmus",
+        }
+    }
+
+    fn has_withdrawals(self) -> bool {
+        self != PayloadVersion::V1
+    }
+
+    fn has_blob_gas(self) -> bool {
+        matches!(self, PayloadVersion::V3 | PayloadVersion::V4)
+    }
+}
+
+/// Dekodierter ExecutionPayload (Felder mit variabler Länge als Slices der Originaldaten,
+/// Felder späterer Hardforks als Option)
+#[allow(dead_code)] // vollständiges Schema, nicht jedes Feld wird ausgewertet
+#[derive(Debug, Clone)]
+pub struct ExecutionPayload<'a> {
+    pub version: PayloadVersion,
+    pub parent_hash: [u8; 32],
+    pub fee_recipient: [u8; 20],
+    pub state_root: [u8; 32],
+    pub receipts_root: [u8; 32],
+    pub logs_bloom: &'a [u8],
+    pub prev_randao: [u8; 32],
+    pub block_number: u64,
+    pub gas_limit: u64,
+    pub gas_used: u64,
+    pub timestamp: u64,
+    pub extra_data: &'a [u8],
+    pub base_fee_per_gas: [u8; 32], // uint256 little-endian
+    pub block_hash: [u8; 32],
+    pub transactions: Vec<&'a [u8]>,
+    pub withdrawals: Option<&'a [u8]>, // n x WITHDRAWAL_SIZE (ab v2)
+    pub blob_gas_used: Option<u64>,    // ab v3
+    pub excess_blob_gas: Option<u64>,  // ab v3
+    pub withdrawals_root: Option<[u8; 32]>, // ab v4
+}
+
+/// Withdrawal (Beträge in Gwei)
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Withdrawal {
+    pub index: u64,
+    pub validator_index: u64,
+    pub address: [u8; 20],
+    pub amount: u64,
+}
+
+impl ExecutionPayload<'_> {
+    /// Dekodierte Withdrawals (leer vor v2)
+    pub fn withdrawals(&self) -> Vec<Withdrawal> {
+        self.withdrawals
+            .unwrap_or_default()
+            .chunks_exact(WITHDRAWAL_SIZE)
+            .map(|w| {
+                let mut r = Reader { data: w, pos: 0 };
+                Withdrawal {
+                    index: r.u64(),
+                    validator_index: r.u64(),
+                    address: r.array(),
+                    amount: r.u64(),
+                }
+            })
+            .collect()
+    }
+}
+
+/// Preconf-Daten: parent_beacon_block_root + ExecutionPayload
+#[allow(dead_code)]
+#[derive(Debug, Clone)]
+pub struct PayloadEnvelope<'a> {
+    pub parent_beacon_block_root: [u8; 32],
+    pub payload: ExecutionPayload<'a>,
+}
+
+/// Liest den festen Teil feldweise
+struct Reader<'a> {
+    data: &'a [u8],
+    pos: usize,
+}
+
+impl<'a> Reader<'a> {
+    fn bytes(&mut self, len: usize) -> &'a [u8] {
+        let slice = &self.data[self.pos..self.pos + len];
+        self.pos += len;
+        slice
+    }
+
+    fn array<const N: usize>(&mut self) -> [u8; N] {
+        self.bytes(N).try_into().unwrap()
+    }
+
+    fn u64(&mut self) -> u64 {
+        u64::from_le_bytes(self.array())
+    }
+
+    fn offset(&mut self) -> usize {
+        u32::from_le_bytes(self.array()) as usize
+    }
+}
+
+/// Erkennt die Version am extra_data-Offset des Payloads
+pub fn detect_version(payload: &[u8]) -> Result<PayloadVersion, Box<dyn std::error::Error + Send + Sync>> {
+    // extra_data-Offset liegt in allen Versionen hinter timestamp
+    let position = V1_FIXED_SIZE - 4 - 32 - 32 - 4;
+    if payload.len() < position + 4 {
+        return Err(format!("ExecutionPayload too short: {} bytes", payload.len()).into());
+    }
+    let extra_data_offset = u32::from_le_bytes(payload[position..position + 4].try_into()?) as usize;
+    PayloadVersion::from_fixed_size(extra_data_offset)
+        .ok_or_else(|| format!("Unknown payload layout (extra_data offset {})", extra_data_offset).into())
+}
+
+/// Dekodiert Preconf-Daten (Präfix + ExecutionPayload), Version wird aus dem Layout erkannt
+pub fn decode_envelope(data: &[u8]) -> Result<PayloadEnvelope<'_>, Box<dyn std::error::Error + Send + Sync>> {
+    decode_envelope_as(data, None)
+}
+
+/// Dekodiert Preconf-Daten mit bekannter Version (Gossip-Topic), sonst wie `decode_envelope`
+pub fn decode_envelope_as(
+    data: &[u8],
+    version: Option<PayloadVersion>,
+) -> Result<PayloadEnvelope<'_>, Box<dyn std::error::Error + Send + Sync>> {
+    if data.len() < ENVELOPE_PREFIX_SIZE {
+        return Err("Preconf data too short for parent_beacon_block_root".into());
+    }
+    let payload = &data[ENVELOPE_PREFIX_SIZE..];
+    let version = match version {
+        Some(version) => version,
+        None => detect_version(payload)?,
+    };
+    Ok(PayloadEnvelope {
+        parent_beacon_block_root: data[..ENVELOPE_PREFIX_SIZE].try_into()?,
+        payload: decode_payload(payload, version)?,
+    })
+}
+
+/// Dekodiert einen SSZ ExecutionPayload der angegebenen Version
+pub fn decode_payload(
+    data: &[u8],
+    version: PayloadVersion,
+) -> Result<ExecutionPayload<'_>, Box<dyn std::error::Error + Send + Sync>> {
+    let fixed_size = version.fixed_size();
+    if data.len() < fixed_size {
+        return Err(format!("ExecutionPayload {} too short: {} < {} bytes", version.name(), data.len(), fixed_size).into());
+    }
+
+    let mut r = Reader { data, pos: 0 };
+    let parent_hash = r.array();
+    let fee_recipient = r.array();
+    let state_root = r.array();
+    let receipts_root = r.array();
+    let logs_bloom = r.bytes(256);
+    let prev_randao = r.array();
+    let block_number = r.u64();
+    let gas_limit = r.u64();
+    let gas_used = r.u64();
+    let timestamp = r.u64();
+    let extra_data_offset = r.offset();
+    let base_fee_per_gas = r.array();
+    let block_hash = r.array();
+    let transactions_offset = r.offset();
+    let withdrawals_offset = version.has_withdrawals().then(|| r.offset());
+    let (blob_gas_used, excess_blob_gas) = match version.has_blob_gas() {
+        true => (Some(r.u64()), Some(r.u64())),
+        false => (None, None),
+    };
+    let withdrawals_root = (version == PayloadVersion::V4).then(|| r.array());
+
+    // Das erste variable Feld beginnt direkt nach dem festen Teil, danach aufsteigend
+    if extra_data_offset != fixed_size {
+        return Err(format!(
+            "Unexpected extra_data offset {} for payload {} (schema mismatch?)",
+            extra_data_offset,
+            version.name()
+        )
+        .into());
+    }
+    let transactions_end = withdrawals_offset.unwrap_or(data.len());
+    if transactions_offset < extra_data_offset || transactions_end < transactions_offset || transactions_end > data.len() {
+        return Err(format!(
+            "Invalid variable offsets {}/{}/{} for {} bytes",
+            extra_data_offset,
+            transactions_offset,
+            transactions_end,
+            data.len()
+        )
+        .into());
+    }
+
+    let extra_data = &data[extra_data_offset..transactions_offset];
+    if extra_data.len() > MAX_EXTRA_DATA {
+        return Err(format!("extra_data too long: {} bytes", extra_data.len()).into());
+    }
+    let withdrawals = withdrawals_offset.map(|offset| &data[offset..]);
+    if let Some(withdrawals) = withdrawals {
+        if withdrawals.len() % WITHDRAWAL_SIZE != 0 {
+            return Err(format!("Invalid withdrawals length {}", withdrawals.len()).into());
+        }
+    }
+
+    Ok(ExecutionPayload {
+        version,
+        parent_hash,
+        fee_recipient,
+        state_root,
+        receipts_root,
+        logs_bloom,
+        prev_randao,
+        block_number,
+        gas_limit,
+        gas_used,
+        timestamp,
+        extra_data,
+        base_fee_per_gas,
+        block_hash,
+        transactions: decode_byte_list(&data[transactions_offset..transactions_end])?,
+        withdrawals,
+        blob_gas_used,
+        excess_blob_gas,
+        withdrawals_root,
+    })
+}
+
+/// Liste variabler Länge (List[ByteList]): zuerst u32-Offsets aller Elemente, dann die Daten
+fn decode_byte_list(list: &[u8]) -> Result<Vec<&[u8]>, Box<dyn std::error::Error + Send + Sync>> {
+    if list.is_empty() {
+        return Ok(Vec::new());
+    }
+    if list.len() < 4 {
+        return Err("Truncated transactions list".into());
+    }
+
+    let offset = |i: usize| u32::from_le_bytes(list[i * 4..i * 4 + 4].try_into().unwrap()) as usize;
+    let first = offset(0);
+    if first % 4 != 0 || first == 0 || first > list.len() {
+        return Err(format!("Invalid first transaction offset {}", first).into());
+    }
+    let count = first / 4;
+
+    let mut items = Vec::with_capacity(count);
+    for i in 0..count {
+        let start = offset(i);
+        let end = if i + 1 < count { offset(i + 1) } else { list.len() };
+        if start > end || end > list.len() {
+            return Err(format!("Invalid offset for transaction {}", i).into());
+        }
+        items.push(&list[start..end]);
+    }
+    Ok(items)
+}
+
+/// Kodiert einen Gossip-Payload als SSZ (Layout der jeweiligen Version, wie oben beschrieben) hinter das Präfix
+/// (parent_beacon_block_root); Größe vorab berechnet, damit pro Preconf genau ein Puffer entsteht
+pub fn encode_payload(prefix: &[u8; ENVELOPE_PREFIX_SIZE], payload: &OpExecutionPayload) -> Vec<u8> {
+    let version = PayloadVersion::of(payload);
+    let (v1, withdrawals, blob_gas, withdrawals_root) = match payload {
+        OpExecutionPayload::V1(p) => (p, None, None, None),
+        OpExecutionPayload::V2(p) => (&p.payload_inner, Some(&p.withdrawals), None, None),
+        OpExecutionPayload::V3(p) => (
+            &p.payload_inner.payload_inner,
+            Some(&p.payload_inner.withdrawals),
+            Some((p.blob_gas_used, p.excess_blob_gas)),
+            None,
+        ),
+        OpExecutionPayload::V4(p) => (
+            &p.payload_inner.payload_inner.payload_inner,
+            Some(&p.payload_inner.payload_inner.withdrawals),
+            Some((p.payload_inner.blob_gas_used, p.payload_inner.excess_blob_gas)),
+            Some(p.withdrawals_root),
+        ),
+    };
+
+    let extra_data_offset = version.fixed_size();
+    let transactions_offset = extra_data_offset + v1.extra_data.len();
+    let transactions_size: usize = v1.transactions.iter().map(|tx| 4 + tx.len()).sum();
+    let size = transactions_offset + transactions_size + withdrawals.map_or(0, |w| w.len() * WITHDRAWAL_SIZE);
+
+    let mut out = Vec::with_capacity(ENVELOPE_PREFIX_SIZE + size);
+    out.extend_from_slice(prefix);
+    out.extend_from_slice(v1.parent_hash.as_slice());
+    out.extend_from_slice(v1.fee_recipient.as_slice());
+    out.extend_from_slice(v1.state_root.as_slice());
+    out.extend_from_slice(v1.receipts_root.as_slice());
+    out.extend_from_slice(v1.logs_bloom.as_slice());
+    out.extend_from_slice(v1.prev_randao.as_slice());
+    out.extend_from_slice(&v1.block_number.to_le_bytes());
+    out.extend_from_slice(&v1.gas_limit.to_le_bytes());
+    out.extend_from_slice(&v1.gas_used.to_le_bytes());
+    out.extend_from_slice(&v1.timestamp.to_le_bytes());
+    out.extend_from_slice(&(extra_data_offset as u32).to_le_bytes());
+    out.extend_from_slice(&v1.base_fee_per_gas.to_le_bytes::<32>());
+    out.extend_from_slice(v1.block_hash.as_slice());
+    out.extend_from_slice(&(transactions_offset as u32).to_le_bytes());
+    if withdrawals.is_some() {
+        let withdrawals_offset = transactions_offset + transactions_size;
+        out.extend_from_slice(&(withdrawals_offset as u32).to_le_bytes());
+    }
+    if let Some((blob_gas_used, excess_blob_gas)) = blob_gas {
+        out.extend_from_slice(&blob_gas_used.to_le_bytes());
+        out.extend_from_slice(&excess_blob_gas.to_le_bytes());
+    }
+    if let Some(root) = withdrawals_root {
+        out.extend_from_slice(root.as_slice());
+    }
+    debug_assert_eq!(out.len(), ENVELOPE_PREFIX_SIZE + extra_data_offset);
+
+    out.extend_from_slice(&v1.extra_data);
+    encode_byte_list(&mut out, v1);
+    for w in withdrawals.into_iter().flatten() {
+        out.extend_from_slice(&w.index.to_le_bytes());
+        out.extend_from_slice(&w.validator_index.to_le_bytes());
+        out.extend_from_slice(w.address.as_slice());
+        out.extend_from_slice(&w.amount.to_le_bytes());
+    }
+    debug_assert_eq!(out.len(), ENVELOPE_PREFIX_SIZE + size);
+    out
+}
+
+/// Transaktionsliste: u32-Offsets, dann die Transaktionen (direkt in den Ausgabepuffer)
+fn encode_byte_list(out: &mut Vec<u8>, payload: &ExecutionPayloadV1) {
+    let mut offset = payload.transactions.len() * 4;
+    for tx in &payload.transactions {
+        out.extend_from_slice(&(offset as u32).to_le_bytes());
+        offset += tx.len();
+    }
+    for tx in &payload.transactions {
+        out.extend_from_slice(tx);
+    }
+}
+
+#[cfg(test)]
+pub(crate) mod tests {
+    use super::*;
+
+    /// Felder eines Test-Payloads, kodiert wie encode_payload, aber ohne die op-alloy-Typen (auch für die
+    /// Block-Hash-Vektoren in blockhash.rs)
+    #[derive(Debug, Clone)]
+    pub(crate) struct TestPayload {
+        pub version: PayloadVersion,
+        pub parent_beacon_block_root: [u8; 32],
+        pub parent_hash: [u8; 32],
+        pub fee_recipient: [u8; 20],
+        pub state_root: [u8; 32],
+        pub receipts_root: [u8; 32],
+        pub logs_bloom: Vec<u8>,
+        pub prev_randao: [u8; 32],
+        pub block_number: u64,
+        pub gas_limit: u64,
+        pub gas_used: u64,
+        pub timestamp: u64,
+        pub extra_data: Vec<u8>,
+        pub base_fee_per_gas: u128,
+        pub block_hash: [u8; 32],
+        pub transactions: Vec<Vec<u8>>,
+        pub withdrawals: Vec<Withdrawal>,
+        pub blob_gas_used: u64,
+        pub excess_blob_gas: u64,
+        pub withdrawals_root: [u8; 32],
+    }
+
+    impl TestPayload {
+        /// Payload mit unterscheidbaren Werten in jedem Feld
+        pub(crate) fn sample(version: PayloadVersion) -> Self {
+            Self {
+                version,
+                parent_beacon_block_root: [0x01; 32],
+                parent_hash: [0x02; 32],
+                fee_recipient: [0x03; 20],
+                state_root: [0x04; 32],
+                receipts_root: [0x05; 32],
+                logs_bloom: (0..=255).collect(),
+                prev_randao: [0x06; 32],
+                block_number: 30_000_000,
+                gas_limit: 60_000_000,
+                gas_used: 1_234_567,
+                timestamp: 1_767_268_800,
+                extra_data: b"kona".to_vec(),
+                base_fee_per_gas: 1_000_252,
+                block_hash: [0x07; 32],
+                transactions: vec![vec![0x7e, 0x01, 0x02], Vec::new(), vec![0x02; 300]],
+                withdrawals: match version {
+                    PayloadVersion::V1 => Vec::new(),
+                    _ => vec![Withdrawal { index: 9, validator_index: 8, address: [0x08; 20], amount: 7 }],
+                },
+                blob_gas_used: 131_072,
+                excess_blob_gas: 262_144,
+                withdrawals_root: [0x09; 32],
+            }
+        }
+
+        /// Präfix + SSZ ExecutionPayload der Version
+        pub(crate) fn encode(&self) -> Vec<u8> {
+            let version = self.version;
+            let extra_data_offset = version.fixed_size();
+            let transactions_offset = extra_data_offset + self.extra_data.len();
+            let transactions_size: usize = self.transactions.iter().map(|tx| 4 + tx.len()).sum();
+
+            let mut out = self.parent_beacon_block_root.to_vec();
+            out.extend_from_slice(&self.parent_hash);
+            out.extend_from_slice(&self.fee_recipient);
+            out.extend_from_slice(&self.state_root);
+            out.extend_from_slice(&self.receipts_root);
+            out.extend_from_slice(&self.logs_bloom);
+            out.extend_from_slice(&self.prev_randao);
+            out.extend_from_slice(&self.block_number.to_le_bytes());
+            out.extend_from_slice(&self.gas_limit.to_le_bytes());
+            out.extend_from_slice(&self.gas_used.to_le_bytes());
+            out.extend_from_slice(&self.timestamp.to_le_bytes());
+            out.extend_from_slice(&(extra_data_offset as u32).to_le_bytes());
+            out.extend_from_slice(&self.base_fee_per_gas.to_le_bytes());
+            out.extend_from_slice(&[0u8; 16]);
+            out.extend_from_slice(&self.block_hash);
+            out.extend_from_slice(&(transactions_offset as u32).to_le_bytes());
+            if version.has_withdrawals() {
+                out.extend_from_slice(&((transactions_offset + transactions_size) as u32).to_le_bytes());
+            }
+            if version.has_blob_gas() {
+                out.extend_from_slice(&self.blob_gas_used.to_le_bytes());
+                out.extend_from_slice(&self.excess_blob_gas.to_le_bytes());
+            }
+            if version == PayloadVersion::V4 {
+                out.extend_from_slice(&self.withdrawals_root);
+            }
+            assert_eq!(out.len(), ENVELOPE_PREFIX_SIZE + extra_data_offset);
+
+            out.extend_from_slice(&self.extra_data);
+            let mut offset = self.transactions.len() * 4;
+            for tx in &self.transactions {
+                out.extend_from_slice(&(offset as u32).to_le_bytes());
+                offset += tx.len();
+            }
+            for tx in &self.transactions {
+                out.extend_from_slice(tx);
+            }
+            for w in &self.withdrawals {
+                out.extend_from_slice(&w.index.to_le_bytes());
+                out.extend_from_slice(&w.validator_index.to_le_bytes());
+                out.extend_from_slice(&w.address);
+                out.extend_from_slice(&w.amount.to_le_bytes());
+            }
+            out
+        }
+    }
+
+    const VERSIONS: [PayloadVersion; 4] =
+        [PayloadVersion::V1, PayloadVersion::V2, PayloadVersion::V3, PayloadVersion::V4];
+
+    fn assert_decoded(expected: &TestPayload, envelope: &PayloadEnvelope) {
+        let p = &envelope.payload;
+        assert_eq!(envelope.parent_beacon_block_root, expected.parent_beacon_block_root);
+        assert_eq!(p.version, expected.version);
+        assert_eq!(p.parent_hash, expected.parent_hash);
+        assert_eq!(p.fee_recipient, expected.fee_recipient);
+        assert_eq!(p.state_root, expected.state_root);
+        assert_eq!(p.receipts_root, expected.receipts_root);
+        assert_eq!(p.logs_bloom, expected.logs_bloom.as_slice());
+        assert_eq!(p.prev_randao, expected.prev_randao);
+        assert_eq!(p.block_number, expected.block_number);
+        assert_eq!(p.gas_limit, expected.gas_limit);
+        assert_eq!(p.gas_used, expected.gas_used);
+        assert_eq!(p.timestamp, expected.timestamp);
+        assert_eq!(p.extra_data, expected.extra_data.as_slice());
+        assert_eq!(u128::from_le_bytes(p.base_fee_per_gas[..16].try_into().unwrap()), expected.base_fee_per_gas);
+        assert_eq!(p.block_hash, expected.block_hash);
+        assert_eq!(p.transactions, expected.transactions.iter().map(Vec::as_slice).collect::<Vec<_>>());
+        assert_eq!(p.withdrawals(), expected.withdrawals);
+        assert_eq!(p.withdrawals.is_some(), expected.version.has_withdrawals());
+        let blob_gas = expected.version.has_blob_gas();
+        assert_eq!(p.blob_gas_used, blob_gas.then_some(expected.blob_gas_used));
+        assert_eq!(p.excess_blob_gas, blob_gas.then_some(expected.excess_blob_gas));
+        let withdrawals_root = expected.version == PayloadVersion::V4;
+        assert_eq!(p.withdrawals_root, withdrawals_root.then_some(expected.withdrawals_root));
+    }
+
+    #[test]
+    fn decodes_every_version_from_its_layout() {
+        for version in VERSIONS {
+            let expected = TestPayload::sample(version);
+            let data = expected.encode();
+            assert_eq!(detect_version(&data[ENVELOPE_PREFIX_SIZE..]).unwrap(), version);
+            assert_decoded(&expected, &decode_envelope(&data).unwrap());
+            assert_decoded(&expected, &decode_envelope_as(&data, Some(version)).unwrap());
+        }
+    }
+
+    #[test]
+    fn decodes_empty_lists() {
+        for version in VERSIONS {
+            let mut expected = TestPayload::sample(version);
+            expected.extra_data.clear();
+            expected.transactions.clear();
+            expected.withdrawals.clear();
+            assert_decoded(&expected, &decode_envelope(&expected.encode()).unwrap());
+        }
+    }
+
+    #[test]
+    fn rejects_other_version_layout() {
+        let data = TestPayload::sample(PayloadVersion::V3).encode();
+        for version in [PayloadVersion::V1, PayloadVersion::V2, PayloadVersion::V4] {
+            assert!(decode_envelope_as(&data, Some(version)).is_err(), "decoded v3 payload as {}", version.name());
+        }
+    }
+
+    #[test]
+    fn rejects_truncated_data() {
+        assert!(decode_envelope(&[0u8; ENVELOPE_PREFIX_SIZE - 1]).is_err());
+        let data = TestPayload::sample(PayloadVersion::V4).encode();
+        let fixed_end = ENVELOPE_PREFIX_SIZE + PayloadVersion::V4.fixed_size();
+        assert!(decode_envelope(&data[..fixed_end - 1]).is_err());
+        assert!(decode_envelope_as(&data[..fixed_end - 1], Some(PayloadVersion::V4)).is_err());
+        // Abgeschnittene Withdrawals-Liste (kein Vielfaches von 44 Bytes)
+        assert!(decode_envelope(&data[..data.len() - 1]).is_err());
+    }
+
+    #[test]
+    fn rejects_invalid_offsets() {
+        let expected = TestPayload::sample(PayloadVersion::V2);
+        let transactions_offset = ENVELOPE_PREFIX_SIZE + V1_FIXED_SIZE - 4;
+
+        let mut data = expected.encode();
+        data[transactions_offset..transactions_offset + 4].copy_from_slice(&u32::MAX.to_le_bytes());
+        assert!(decode_envelope(&data).is_err());
+
+        // Erster Transaktions-Offset kein Vielfaches von 4
+        let mut data = expected.encode();
+        let list = ENVELOPE_PREFIX_SIZE + PayloadVersion::V2.fixed_size() + expected.extra_data.len();
+        data[list..list + 4].copy_from_slice(&13u32.to_le_bytes());
+        assert!(decode_envelope(&data).is_err());
+    }
+
+    #[test]
+    fn rejects_long_extra_data() {
+        let mut payload = TestPayload::sample(PayloadVersion::V3);
+        payload.extra_data = vec![0xaa; MAX_EXTRA_DATA];
+        assert!(decode_envelope(&payload.encode()).is_ok());
+        payload.extra_data.push(0xaa);
+        assert!(decode_envelope(&payload.encode()).is_err());
+    }
+}
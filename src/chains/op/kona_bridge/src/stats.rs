@@ -0,0 +1,321 @@
+// stats.rs - Speicher-Statistiken des Preconf-Stores
+//
+// Zähler (geschriebene Einträge je Quelle, Löschungen je Grund) werden vom Store und vom
+// Cleanup-Task hochgezählt. Belegung (Anzahl, Bytes, ältester/neuester Block) wird bei Abfrage
+// aus dem Output-Verzeichnis ermittelt, damit die Werte auch nach Import/externem Löschen stimmen.
+// Abrufbar über kona_bridge_get_storage_stats (Metrics des C-Servers) und den Query-Socket.
+//
+// Dazu zwei Histogramme über die gespeicherten Preconfs: Abstand zwischen zwei Ankünften (ein wachsender Anteil über
+// der Blockzeit zeigt einen langsamen Sequencer oder verlorene Blöcke) und Größe des unkomprimierten Payloads
+// (Wachstum der Blöcke). Buckets mit festen Grenzen, kumulativ wie bei Prometheus (kona_bridge_get_histograms).
+
+use crate::{blobs::BLOB_DIR_NAME, utils::block_number_from_filename};
+use std::{
+    collections::HashSet,
+    fs,
+    os::unix::fs::MetadataExt,
+    path::PathBuf,
+    sync::{
+        atomic::{AtomicU64, Ordering},
+        Mutex,
+    },
+    time::{Instant, SystemTime},
+};
+
+/// Anzahl der Buckets je Histogramm (ohne +Inf, das ist `count`)
+pub const HISTOGRAM_BUCKETS: usize = 10;
+/// Obergrenzen der Ankunftsabstände in Millisekunden (OP-Chains produzieren alle 1-2s)
+pub const INTERVAL_BOUNDS_MS: [u64; HISTOGRAM_BUCKETS] = [250, 500, 1000, 1500, 2000, 2500, 3000, 5000, 10000, 30000];
+/// Obergrenzen der Payload-Größen in Bytes (1 KiB bis 4 MiB)
+pub const SIZE_BOUNDS_BYTES: [u64; HISTOGRAM_BUCKETS] =
+    [1 << 10, 4 << 10, 16 << 10, 32 << 10, 64 << 10, 128 << 10, 256 << 10, 512 << 10, 1 << 20, 4 << 20];
+
+/// Grund einer Löschung
+#[derive(Debug, Clone, Copy)]
+pub enum Eviction {
+    Ttl,
+    Quota,
+    Finality,
+}
+
+/// Zähler und Output-Verzeichnis einer Bridge-Instanz
+#[derive(Default)]
+pub struct StorageStats {
+    output_dir: Mutex<Option<(PathBuf, u64)>>,
+    stored_http: AtomicU64,
+    stored_gossip: AtomicU64,
+    stored_other: AtomicU64,
+    evicted_ttl: AtomicU64,
+    evicted_quota: AtomicU64,
+    evicted_finality: AtomicU64,
+    invalid_signatures: AtomicU64,
+    equivocations: AtomicU64,
+    oversized_payloads: AtomicU64,
+    gossip_dropped: AtomicU64,
+    sampled_out: AtomicU64,
+    reorgs: AtomicU64,
+    gaps: AtomicU64,
+    backwards: AtomicU64,
+    dns_failures: AtomicU64,
+    canonical_matched: AtomicU64,
+    canonical_mismatched: AtomicU64,
+    canonical_tx_mismatched: AtomicU64,
+    last_arrival: Mutex<Option<Instant>>,
+    arrival_interval: Histogram, // Millisekunden, Grenzen INTERVAL_BOUNDS_MS
+    payload_size: Histogram,     // Bytes, Grenzen SIZE_BOUNDS_BYTES
+}
+
+/// Histogramm mit festen Bucket-Grenzen (vom Aufrufer übergeben)
+#[derive(Default)]
+struct Histogram {
+    buckets: [AtomicU64; HISTOGRAM_BUCKETS], // nicht kumulativ, Werte über der letzten Grenze nur in `count`
+    count: AtomicU64,
+    sum: AtomicU64,
+}
+
+impl Histogram {
+    fn observe(&self, bounds: &[u64; HISTOGRAM_BUCKETS], value: u64) {
+        if let Some(bucket) = bounds.iter().position(|bound| value <= *bound) {
+            self.buckets[bucket].fetch_add(1, Ordering::Relaxed);
+        }
+        self.count.fetch_add(1, Ordering::Relaxed);
+        self.sum.fetch_add(value, Ordering::Relaxed);
+    }
+
+    fn snapshot(&self, bounds: &[u64; HISTOGRAM_BUCKETS]) -> HistogramSnapshot {
+        let mut cumulative = 0;
+        let buckets = self
+            .buckets
+            .iter()
+            .map(|bucket| {
+                cumulative += bucket.load(Ordering::Relaxed);
+                cumulative
+            })
+            .collect();
+        HistogramSnapshot {
+            bounds: bounds.to_vec(),
+            buckets,
+            count: self.count.load(Ordering::Relaxed),
+            sum: self.sum.load(Ordering::Relaxed),
+        }
+    }
+}
+
+/// Momentaufnahme eines Histogramms im Prometheus-Schema
+#[derive(Debug, Clone, Default, serde::Serialize)]
+pub struct HistogramSnapshot {
+    pub bounds: Vec<u64>,  // Obergrenzen (le)
+    pub buckets: Vec<u64>, // kumulativ: Anzahl der Werte <= bounds[i]
+    pub count: u64,        // alle Werte (+Inf)
+    pub sum: u64,
+}
+
+/// Momentaufnahme der Speicher-Statistiken
+#[derive(Debug, Clone, Default, serde::Serialize)]
+pub struct StorageSnapshot {
+    pub entries: u64,           // Blöcke (.raw Dateien)
+    pub total_bytes: u64,       // .raw/.json, Blobs und Index (Hardlinks einmal gezählt)
+    pub oldest_block: u64,
+    pub newest_block: u64,
+    pub oldest_age_secs: u64,   // Alter der ältesten .raw Datei
+    pub newest_age_secs: u64,   // Alter der neuesten .raw Datei
+    pub stored_http: u64,       // seit Start geschrieben, je Quelle
+    pub stored_gossip: u64,
+    pub stored_other: u64,
+    pub evicted_ttl: u64,       // seit Start gelöschte Blöcke, je Grund
+    pub evicted_quota: u64,
+    pub evicted_finality: u64,
+    pub invalid_signatures: u64, // seit Start abgelehnte Signer (je nach Modus verworfen/quarantäniert/markiert)
+    pub equivocations: u64,      // seit Start erkannte Equivocations (Evidence unter equivocations/)
+    pub oversized_payloads: u64, // seit Start verworfene Payloads über KONA_BRIDGE_MAX_PAYLOAD_KB
+    pub gossip_dropped: u64,     // seit Start verworfene Gossip-Nachrichten (volle Pipeline, Empfänger zurückgelegen)
+    pub sampled_out: u64,        // seit Start geprüfte, wegen KONA_BRIDGE_SAMPLING nicht gespeicherte Blöcke
+    pub reorgs: u64,             // seit Start ersetzte Blöcke (gleiche Höhe, anderer Hash, siehe reorg.rs)
+    pub gaps: u64,               // seit Start erkannte Lücken in der Blockfolge (siehe continuity.rs)
+    pub backwards: u64,          // seit Start erkannte Rücksprünge hinter den höchsten erfassten Block
+    pub dns_failures: u64,       // seit Start gescheiterte DNS-Auflösungen des HTTP-Endpoints (siehe dnscache.rs)
+    pub canonical_matched: u64,       // seit Start mit dem kanonischen Block übereinstimmend (siehe accuracy.rs)
+    pub canonical_mismatched: u64,    // seit Start abweichende Preconfs (anderer Block-Hash)
+    pub canonical_tx_mismatched: u64, // davon mit abweichender Menge an Transaktionen
+    pub arrival_interval_ms: HistogramSnapshot, // Abstand zwischen zwei gespeicherten Preconfs
+    pub payload_size_bytes: HistogramSnapshot,  // unkomprimierte Payload-Größe
+}
+
+impl StorageSnapshot {
+    /// Anteil der mit dem kanonischen Block übereinstimmenden Preconfs (None ohne Vergleiche)
+    pub fn preconf_accuracy(&self) -> Option<f64> {
+        let compared = self.canonical_matched + self.canonical_mismatched;
+        (compared > 0).then(|| self.canonical_matched as f64 / compared as f64)
+    }
+}
+
+impl StorageStats {
+    /// Setzt das Verzeichnis, dessen Belegung gemeldet wird (Chain-Unterverzeichnis), und die Chain seiner Blöcke
+    pub fn set_output_dir(&self, output_dir: PathBuf, chain_id: u64) {
+        if let Ok(mut dir) = self.output_dir.lock() {
+            *dir = Some((output_dir, chain_id));
+        }
+    }
+
+    /// Gespeicherter Preconf: Zähler je Quelle, Ankunftsabstand und Payload-Größe
+    pub fn record_stored(&self, source: &str, payload_size: usize) {
+        let counter = match source {
+            "http" => &self.stored_http,
+            "gossip" => &self.stored_gossip,
+            _ => &self.stored_other,
+        };
+        counter.fetch_add(1, Ordering::Relaxed);
+
+        let now = Instant::now();
+        let previous = self.last_arrival.lock().ok().and_then(|mut last| last.replace(now));
+        if let Some(previous) = previous {
+            let interval_ms = now.duration_since(previous).as_millis() as u64;
+            self.arrival_interval.observe(&INTERVAL_BOUNDS_MS, interval_ms);
+        }
+        self.payload_size.observe(&SIZE_BOUNDS_BYTES, payload_size as u64);
+    }
+
+    pub fn record_evicted(&self, reason: Eviction, blocks: usize) {
+        let counter = match reason {
+            Eviction::Ttl => &self.evicted_ttl,
+            Eviction::Quota => &self.evicted_quota,
+            Eviction::Finality => &self.evicted_finality,
+        };
+        counter.fetch_add(blocks as u64, Ordering::Relaxed);
+    }
+
+    pub fn record_invalid_signature(&self) {
+        self.invalid_signatures.fetch_add(1, Ordering::Relaxed);
+    }
+
+    pub fn record_equivocation(&self) {
+        self.equivocations.fetch_add(1, Ordering::Relaxed);
+    }
+
+    pub fn record_oversized(&self) {
+        self.oversized_payloads.fetch_add(1, Ordering::Relaxed);
+    }
+
+    pub fn record_gossip_dropped(&self, count: u64) {
+        self.gossip_dropped.fetch_add(count, Ordering::Relaxed);
+    }
+
+    pub fn record_sampled_out(&self) {
+        self.sampled_out.fetch_add(1, Ordering::Relaxed);
+    }
+
+    pub fn record_reorg(&self) {
+        self.reorgs.fetch_add(1, Ordering::Relaxed);
+    }
+
+    pub fn record_gap(&self) {
+        self.gaps.fetch_add(1, Ordering::Relaxed);
+    }
+
+    pub fn record_backwards(&self) {
+        self.backwards.fetch_add(1, Ordering::Relaxed);
+    }
+
+    pub fn record_dns_failure(&self) {
+        self.dns_failures.fetch_add(1, Ordering::Relaxed);
+    }
+
+    pub fn record_canonical_match(&self) {
+        self.canonical_matched.fetch_add(1, Ordering::Relaxed);
+    }
+
+    pub fn record_canonical_mismatch(&self, tx_set_matches: bool) {
+        self.canonical_mismatched.fetch_add(1, Ordering::Relaxed);
+        if !tx_set_matches {
+            self.canonical_tx_mismatched.fetch_add(1, Ordering::Relaxed);
+        }
+    }
+
+    /// Nur die Zähler, ohne Verzeichnis-Scan
+    pub fn counters(&self) -> StorageSnapshot {
+        StorageSnapshot {
+            stored_http: self.stored_http.load(Ordering::Relaxed),
+            stored_gossip: self.stored_gossip.load(Ordering::Relaxed),
+            stored_other: self.stored_other.load(Ordering::Relaxed),
+            evicted_ttl: self.evicted_ttl.load(Ordering::Relaxed),
+            evicted_quota: self.evicted_quota.load(Ordering::Relaxed),
+            evicted_finality: self.evicted_finality.load(Ordering::Relaxed),
+            invalid_signatures: self.invalid_signatures.load(Ordering::Relaxed),
+            equivocations: self.equivocations.load(Ordering::Relaxed),
+            oversized_payloads: self.oversized_payloads.load(Ordering::Relaxed),
+            gossip_dropped: self.gossip_dropped.load(Ordering::Relaxed),
+            sampled_out: self.sampled_out.load(Ordering::Relaxed),
+            reorgs: self.reorgs.load(Ordering::Relaxed),
+            gaps: self.gaps.load(Ordering::Relaxed),
+            backwards: self.backwards.load(Ordering::Relaxed),
+            dns_failures: self.dns_failures.load(Ordering::Relaxed),
+            canonical_matched: self.canonical_matched.load(Ordering::Relaxed),
+            canonical_mismatched: self.canonical_mismatched.load(Ordering::Relaxed),
+            canonical_tx_mismatched: self.canonical_tx_mismatched.load(Ordering::Relaxed),
+            arrival_interval_ms: self.arrival_interval.snapshot(&INTERVAL_BOUNDS_MS),
+            payload_size_bytes: self.payload_size.snapshot(&SIZE_BOUNDS_BYTES),
+            ..Default::default()
+        }
+    }
+
+    /// Zähler plus aktuelle Belegung des Output-Verzeichnisses (blockiert für den Verzeichnis-Scan)
+    pub fn snapshot(&self) -> StorageSnapshot {
+        let mut snapshot = self.counters();
+        let output_dir = self.output_dir.lock().ok().and_then(|dir| dir.clone());
+        if let Some((output_dir, chain_id)) = output_dir {
+            scan_usage(&output_dir, chain_id, &mut snapshot);
+        }
+        snapshot
+    }
+}
+
+fn scan_usage(output_dir: &PathBuf, chain_id: u64, snapshot: &mut StorageSnapshot) {
+    let now = SystemTime::now();
+    let mut seen = HashSet::new(); // (dev, ino): Dedup-Hardlinks nur einmal zählen
+    let mut oldest: Option<(u64, SystemTime)> = None;
+    let mut newest: Option<(u64, SystemTime)> = None;
+
+    for dir in [output_dir.clone(), output_dir.join(BLOB_DIR_NAME)] {
+        let Ok(entries) = fs::read_dir(&dir) else {
+            continue;
+        };
+        for entry in entries.flatten() {
+            let Ok(metadata) = entry.metadata() else {
+                continue;
+            };
+            if !metadata.is_file() {
+                continue;
+            }
+            if seen.insert((metadata.dev(), metadata.ino())) {
+                snapshot.total_bytes += metadata.len();
+            }
+
+            let path = entry.path();
+            if path.extension().map_or(true, |ext| ext != "raw") {
+                continue;
+            }
+            let block_number = block_number_from_filename(&path, chain_id);
+            let (Some(block_number), Ok(modified)) = (block_number, metadata.modified()) else {
+                continue;
+            };
+            snapshot.entries += 1;
+            if oldest.map_or(true, |(n, _)| block_number < n) {
+                oldest = Some((block_number, modified));
+            }
+            if newest.map_or(true, |(n, _)| block_number > n) {
+                newest = Some((block_number, modified));
+            }
+        }
+    }
+
+    let age = |time: SystemTime| now.duration_since(time).unwrap_or_default().as_secs();
+    if let Some((block_number, modified)) = oldest {
+        snapshot.oldest_block = block_number;
+        snapshot.oldest_age_secs = age(modified);
+    }
+    if let Some((block_number, modified)) = newest {
+        snapshot.newest_block = block_number;
+        snapshot.newest_age_secs = age(modified);
+    }
+}
+
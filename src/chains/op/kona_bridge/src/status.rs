@@ -0,0 +1,137 @@
+// status.rs - Heartbeat-Datei status.json im Output-Verzeichnis (KONA_BRIDGE_STATUS_INTERVAL_SECS, Default 5)
+//
+// Der C-Server liest das Verzeichnis ohnehin; über status.json erkennt er eine hängende Bridge ohne zusätzlichen
+// Transport: written_unix veraltet, wenn der Prozess steht, capture_lag_secs wächst, wenn keine Preconfs mehr
+// ankommen, und capture_lag.blocks zeigt den Abstand zum Head der Chain (siehe lag.rs). Enthalten sind neuester
+// Block, Quelle (wie bei /readyz), zuletzt gesehener Signer, Zähler und Speicher-Statistiken. Geschrieben wird
+// atomar (tmp + rename), beim Beenden ein letztes Mal mit "state": "stopped".
+
+use crate::{
+    health::source_health,
+    storage::PreconfStore,
+    types::{BridgeMode, HttpHealthTracker, KonaBridgeStats},
+};
+use serde_json::json;
+use std::{
+    sync::{Arc, Mutex},
+    time::{Duration, SystemTime, UNIX_EPOCH},
+};
+use tokio::time::interval;
+use tracing::warn;
+
+pub const STATUS_FILE_NAME: &str = "status.json";
+pub const DEFAULT_STATUS_INTERVAL_SECS: u64 = 5;
+const STATUS_VERSION: u32 = 1;
+
+pub struct StatusWriter {
+    store: Arc<PreconfStore>,
+    stats: Arc<Mutex<KonaBridgeStats>>,
+    http: Option<Arc<Mutex<HttpHealthTracker>>>, // None = reiner Gossip-Betrieb
+    max_age_secs: u64,
+    started_unix: u64,
+}
+
+fn unix_now() -> u64 {
+    SystemTime::now().duration_since(UNIX_EPOCH).map(|d| d.as_secs()).unwrap_or(0)
+}
+
+impl StatusWriter {
+    pub fn new(
+        store: Arc<PreconfStore>,
+        stats: Arc<Mutex<KonaBridgeStats>>,
+        http: Option<Arc<Mutex<HttpHealthTracker>>>,
+        max_age_secs: u64,
+    ) -> Self {
+        Self { store, stats, http, max_age_secs, started_unix: unix_now() }
+    }
+
+    /// Inhalt von status.json (blockiert für den Verzeichnis-Scan der Speicher-Statistiken)
+    fn collect(&self, state: &str) -> serde_json::Value {
+        let now = unix_now();
+        let last_block = self.store.latest_entry().map(|(number, entry)| {
+            let produced = if entry.timestamp > 0 { entry.timestamp } else { entry.received_unix };
+            json!({
+                "number": number,
+                "hash": format!("0x{}", hex::encode(entry.block_hash)),
+                "timestamp": entry.timestamp,
+                "received_unix": entry.received_unix,
+                "capture_lag_secs": now.saturating_sub(produced),
+            })
+        });
+        let (source_ok, source) = source_health(&self.stats, self.http.as_deref(), self.max_age_secs);
+        let mode = match self.http.as_ref().map(|http| http.lock().unwrap().current_mode.clone()) {
+            Some(BridgeMode::HttpOnly) => "http",
+            Some(BridgeMode::HttpPlusGossip) => "http+gossip",
+            Some(BridgeMode::GossipFallback) | None => "gossip",
+        };
+        let signers = self.store.signer_history();
+        let signer = signers.iter().max_by_key(|record| (record.last_seen_unix, record.last_block));
+        let counters = {
+            let stats = self.stats.lock().unwrap();
+            json!({
+                "connected_peers": stats.connected_peers,
+                "received": stats.received_preconfs,
+                "processed": stats.processed_preconfs,
+                "failed": stats.failed_preconfs,
+                "mode_switches": stats.mode_switches,
+                "gaps": stats.total_gaps,
+            })
+        };
+
+        json!({
+            "version": STATUS_VERSION,
+            "chain_id": self.store.chain_id(),
+            "state": state,
+            "pid": std::process::id(),
+            "started_unix": self.started_unix,
+            "written_unix": now,
+            "mode": mode,
+            "last_block": last_block,
+            "capture_lag_secs": last_block.as_ref().map(|block| block["capture_lag_secs"].clone()),
+            "capture_lag": self.store.capture_lag(now),
+            "source": { "ok": source_ok, "detail": source },
+            "signer": signer.map(|record| json!({
+                "address": record.signer,
+                "accepted": record.accepted,
+                "matched": record.signer_match,
+                "last_block": record.last_block,
+            })),
+            "signers_seen": signers.len(),
+            "stats": counters,
+            "storage": self.store.storage_stats().snapshot(),
+        })
+    }
+
+    async fn write(self: &Arc<Self>, state: &'static str) {
+        let writer = self.clone();
+        let result = tokio::task::spawn_blocking(move || -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+            let status = writer.collect(state);
+            let path = writer.store.output_dir().join(STATUS_FILE_NAME);
+            writer
+                .store
+                .durable_fs()
+                .write_atomic_blocking(&path.with_extension("json.tmp"), &path, &serde_json::to_vec_pretty(&status)?)
+                .map_err(|e| format!("Failed to write {:?}: {}", path, e))?;
+            Ok(())
+        })
+        .await;
+        match result {
+            Ok(Ok(())) => {}
+            Ok(Err(e)) => warn!("⚠️  Cannot update {}: {}", STATUS_FILE_NAME, e),
+            Err(e) => warn!("⚠️  Cannot update {}: {}", STATUS_FILE_NAME, e),
+        }
+    }
+}
+
+/// Schreibt status.json alle `interval_secs` Sekunden und beim Beenden
+pub async fn run_status_writer(writer: StatusWriter, interval_secs: u64, running: Arc<Mutex<bool>>) {
+    let writer = Arc::new(writer);
+    let mut interval_timer = interval(Duration::from_secs(interval_secs.max(1)));
+
+    while *running.lock().unwrap() {
+        interval_timer.tick().await;
+        writer.write("running").await;
+    }
+
+    writer.write("stopped").await;
+}
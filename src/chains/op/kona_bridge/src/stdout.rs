@@ -0,0 +1,74 @@
+// stdout.rs - NDJSON-Stream gespeicherter Preconfs auf stdout (KONA_BRIDGE_STDOUT=ndjson)
+//
+// Pro gespeichertem Preconf eine Zeile JSON, Felder wie bei NATS/Kafka (Metadaten + payload_ref, mit
+// KONA_BRIDGE_STDOUT_PAYLOAD=1 zusätzlich der Container als Base64 in "payload"). Damit lässt sich die Bridge über
+// Pipes mit jq, Log-Shippern oder eigenen Prozessen verbinden, ohne das Output-Verzeichnis zu scannen:
+//   KONA_BRIDGE_STDOUT=ndjson kona-bridge ... | jq -c '{block_number, block_hash}'
+// Logs gehen weiterhin auf stderr. Mehrere Chains im selben Prozess teilen sich stdout; jede Zeile wird unter dem
+// stdout-Lock vollständig geschrieben, Zeilen verschiedener Chains vermischen sich also nicht. Liest niemand mehr
+// (EPIPE), werden weitere Preconfs nur noch verworfen, die Dateien werden weiter geschrieben.
+
+use crate::{publish::preconf_message, settings::StdoutSettings, sink::StoredPreconf};
+use std::{
+    io::{self, Write},
+    sync::{Arc, Mutex},
+};
+use tokio::sync::mpsc;
+use tracing::{info, warn};
+
+/// Zeilen, die höchstens in einem Schreibvorgang zusammengefasst werden
+const STDOUT_BATCH_SIZE: usize = 64;
+
+/// Schreibt die Zeilen unter dem stdout-Lock und flusht (blockierend)
+fn write_lines(lines: &[u8]) -> io::Result<()> {
+    let mut stdout = io::stdout().lock();
+    stdout.write_all(lines)?;
+    stdout.flush()
+}
+
+/// Sink-Task: eine JSON-Zeile pro gespeichertem Preconf auf stdout
+pub async fn run_stdout_sink(
+    settings: StdoutSettings,
+    mut receiver: mpsc::Receiver<Arc<StoredPreconf>>,
+    running: Arc<Mutex<bool>>,
+) {
+    let mut closed = false;
+    while let Some(preconf) = receiver.recv().await {
+        if !*running.lock().unwrap() {
+            break;
+        }
+        // Queue weiter leeren, sonst warnt der Dispatcher für jeden Block
+        if closed {
+            continue;
+        }
+
+        // Bereits wartende Preconfs gleich mitnehmen, damit ein langsamer Leser nicht pro Zeile bremst
+        let mut batch = vec![preconf];
+        while batch.len() < STDOUT_BATCH_SIZE {
+            match receiver.try_recv() {
+                Ok(preconf) => batch.push(preconf),
+                Err(_) => break,
+            }
+        }
+
+        let mut lines = Vec::new();
+        for preconf in &batch {
+            match serde_json::to_writer(&mut lines, &preconf_message(preconf, settings.payload)) {
+                Ok(()) => lines.push(b'\n'),
+                Err(e) => warn!("⚠️  Cannot encode block {} for stdout: {}", preconf.block_number, e),
+            }
+        }
+
+        match tokio::task::spawn_blocking(move || write_lines(&lines)).await {
+            Ok(Ok(())) => {}
+            Ok(Err(e)) if e.kind() == io::ErrorKind::BrokenPipe => {
+                warn!("⚠️  stdout closed by reader, NDJSON stream stopped (files are still written)");
+                closed = true;
+            }
+            Ok(Err(e)) => warn!("⚠️  Cannot write {} block(s) to stdout: {}", batch.len(), e),
+            Err(e) => warn!("⚠️  Cannot write {} block(s) to stdout: {}", batch.len(), e),
+        }
+    }
+
+    info!("🛑 Stdout sink stopped");
+}
@@ -0,0 +1,1703 @@
+// storage.rs - Gemeinsamer Preconf-Store für HTTP- und Gossip-Pfad
+//
+// Einziger Schreibpfad für Preconfs: Kompression/Container, .raw/.json, Symlinks und alles,
+// was danach passieren muss (Shared-Memory-Index, externe Sinks), damit HTTP- und
+// Gossip-Pfad identische Dateien erzeugen.
+// Zusätzlich hält der Store den Block-Index (Nummer/Hash -> Datei, persistiert in block_index.json)
+// für Lookups über den Query-Socket, damit Leser das Dateilayout nicht kennen müssen.
+// Wiederhergestellte Signer landen im Signer-Verlauf (signer_history.json), der mit dem Index geschrieben wird,
+// ebenso der Wiederaufsetzpunkt nach einem Absturz (checkpoint.json, siehe checkpoint.rs).
+
+use crate::{
+    accuracy::{CanonicalTracker, Comparison},
+    admin::BACKFILL_SOURCE,
+    attestation::{canonical_metadata, Attestor, INDEX_SIGNATURE_FILE_NAME},
+    backpressure::DropPolicy,
+    blobs::BlobStore,
+    blockhash::{check_withdrawals, compute_block_hash, transactions_root, withdrawals_root},
+    c4::{preconf_proof, C4_SUFFIX},
+    checkpoint::{Checkpoint, PollCursor},
+    codec::{encode_container, to_legacy, write_container, PayloadCodec, PreconfContainer, Reference, SIGNATURE_SIZE},
+    confidence,
+    continuity::{ContinuityMonitor, Discontinuity},
+    delta::{self, DeltaSettings},
+    diskguard::DiskState,
+    durability::DurableFs,
+    durability::Durability,
+    equivocation::{evidence_bundle, evidence_filename, SignedPayload, EVIDENCE_DIR_NAME},
+    hardfork::{check_version, HardforkSchedule},
+    hooks::{HookEvent, Hooks},
+    http::PayloadTooLarge,
+    index::{BlockIndex, IndexEntry, INDEX_FILE_NAME},
+    journal::WriteJournal,
+    lag::{self, CaptureLag, DEFAULT_BLOCK_TIME_SECS},
+    logging,
+    metawriter::MetadataWriter,
+    perms,
+    pointer::{read_latest_json, LatestPointer, LATEST_FILE_NAME, PRE_LATEST_FILE_NAME},
+    provenance::{self, SourcePreference},
+    profile,
+    render::{render_block, RENDERED_SUFFIX},
+    reorg::{keep_superseded, superseded_filename, ReorgEvent, ReorgHistory, SUPERSEDED_DIR_NAME},
+    sampling::Sampler,
+    settings::{BridgeSettings, ValidationSettings},
+    signer::{InvalidSignatureMode, SignerSet, VerifyPool, QUARANTINE_DIR_NAME},
+    signerhistory::{SignerHistory, SignerRecord},
+    ssz::{decode_envelope_as, PayloadVersion},
+    sink::{start_sinks, SinkDispatcher, StoredPreconf},
+    stats::StorageStats,
+    tiering::HotCache,
+    txdecode::l1_origin,
+    txindex::{address_bloom, index_transactions, TxIndex, TxLocation},
+    utils::{block_number_from_filename, extract_timestamp_from_preconf_data, update_latest_pointers},
+    validation::{check_block_number, check_gas, check_timestamp, RpcHead, ValidationMode},
+    zdict::{self, DictTrainer, ZstdDict},
+};
+#[cfg(unix)]
+use crate::shm::{ShmIndex, SHM_FILE_NAME};
+use std::{
+    path::{Path, PathBuf},
+    sync::{
+        atomic::{AtomicBool, AtomicU32, Ordering},
+        Arc, Mutex,
+    },
+    time::{Duration, Instant, SystemTime, UNIX_EPOCH},
+};
+use tokio::time::interval;
+use alloy::primitives::{keccak256, Address, Keccak256};
+use tracing::{debug, error, info, warn, Instrument};
+
+/// Ein empfangener Preconf vor dem Speichern (Payload unkomprimiert)
+pub struct PreconfWrite {
+    pub block_number: u64,
+    pub block_hash: [u8; 32],
+    pub payload: Vec<u8>, // parent_beacon_block_root + execution payload
+    pub signature: [u8; SIGNATURE_SIZE],
+    pub source: &'static str,
+    pub version: Option<PayloadVersion>, // aus dem Gossip-Topic, sonst am Layout erkannt
+}
+
+/// Aus dem dekodierten Payload abgeleitete Werte (vor dem Verschieben des Payloads ermittelt)
+struct PayloadInfo {
+    version: Option<PayloadVersion>,
+    timestamp: u64,
+    hashes: Option<([u8; 32], [u8; 32])>, // (eingebettet, berechnet)
+    header: Option<serde_json::Value>,    // Header-Felder für die Metadaten
+    withdrawals_error: Option<String>,
+    gas: Option<(u64, u64)>, // (gas_limit, gas_used)
+    rendered: Option<serde_json::Value>, // eth_getBlockByNumber-Darstellung (nur mit KONA_BRIDGE_RENDER_JSON)
+}
+
+impl PayloadInfo {
+    fn decode(
+        payload: &[u8],
+        version: Option<PayloadVersion>,
+        render_signature: Option<&[u8]>,
+    ) -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        let envelope = decode_envelope_as(payload, version)?;
+        let p = &envelope.payload;
+        // Root des Transaktions-Tries: für den Block-Hash und für Inclusion-Proofs im C-Prover
+        let tx_root = transactions_root(p);
+        let mut header = serde_json::json!({
+            "gas_used": p.gas_used,
+            "gas_limit": p.gas_limit,
+            "base_fee_per_gas": base_fee_json(&p.base_fee_per_gas),
+            "tx_count": p.transactions.len(),
+            "transactions_root": format!("0x{}", hex::encode(tx_root)),
+            "extra_data": format!("0x{}", hex::encode(p.extra_data)),
+        });
+        match p.transactions.first().map(|tx| l1_origin(tx)) {
+            Some(Ok(origin)) => {
+                header["l1_origin"] = serde_json::json!({
+                    "number": origin.number,
+                    "hash": format!("0x{}", hex::encode(origin.hash)),
+                    "timestamp": origin.timestamp,
+                });
+                header["sequence_number"] = serde_json::json!(origin.sequence_number);
+            }
+            Some(Err(e)) => debug!("No L1 origin in block {}: {}", p.block_number, e),
+            None => {}
+        }
+        // Header-Feld: ab Isthmus aus dem Payload, vorher Root der Liste
+        if let Some(root) = p.withdrawals_root.or_else(|| withdrawals_root(p)) {
+            header["withdrawals_count"] = serde_json::json!(p.withdrawals().len());
+            header["withdrawals_root"] = serde_json::json!(format!("0x{}", hex::encode(root)));
+        }
+        Ok(PayloadInfo {
+            version: Some(p.version),
+            timestamp: p.timestamp,
+            hashes: Some((p.block_hash, compute_block_hash(&envelope, &tx_root))),
+            header: Some(header),
+            withdrawals_error: check_withdrawals(p).err(),
+            gas: Some((p.gas_limit, p.gas_used)),
+            rendered: render_signature.map(|signature| render_block(&envelope, Some(signature))),
+        })
+    }
+}
+
+/// base_fee_per_gas (uint256 little-endian) als Zahl, falls er in u64 passt, sonst als Hex-String
+fn base_fee_json(base_fee: &[u8; 32]) -> serde_json::Value {
+    if base_fee[8..].iter().all(|b| *b == 0) {
+        serde_json::json!(u64::from_le_bytes(base_fee[..8].try_into().unwrap()))
+    } else {
+        let mut be = *base_fee;
+        be.reverse();
+        serde_json::json!(format!("0x{}", hex::encode(be)))
+    }
+}
+
+/// Delta-Basis vor Dictionary: Keyframes werden mit dem Dictionary komprimiert, Deltas ohne
+fn reference<'a>(base: &'a Option<Arc<Vec<u8>>>, dict: &'a Option<Arc<ZstdDict>>) -> Option<Reference<'a>> {
+    match (base, dict) {
+        (Some(base), _) => Some(Reference::Delta(base)),
+        (None, Some(dict)) => Some(Reference::Dict(dict)),
+        (None, None) => None,
+    }
+}
+
+/// Inhaltsschlüssel eines Preconfs: keccak256(payload || signature), unabhängig vom Codec (inkrementell gehasht,
+/// ohne Payload und Signatur in einen neuen Puffer zu kopieren)
+fn content_key(payload: &[u8], signature: &[u8; SIGNATURE_SIZE]) -> [u8; 32] {
+    let mut hasher = Keccak256::new();
+    hasher.update(payload);
+    hasher.update(signature);
+    hasher.finalize().0
+}
+
+pub struct PreconfStore {
+    output_dir: PathBuf,
+    chain_id: u64,
+    codec: PayloadCodec,
+    blobs: Option<BlobStore>,
+    fs: Arc<DurableFs>,
+    zstd_dict: Option<Arc<DictTrainer>>,
+    delta: Option<DeltaSettings>,
+    sampler: Option<Sampler>,
+    source_preference: SourcePreference,
+    reorg_grace: u64,
+    reorgs: Mutex<ReorgHistory>,
+    continuity: Arc<ContinuityMonitor>,
+    canonical: Option<Arc<CanonicalTracker>>, // Abgleich mit kanonischen Blöcken (siehe accuracy.rs)
+    metadata: Arc<MetadataWriter>,
+    journal: WriteJournal,
+    pointer: LatestPointer,
+    sinks: SinkDispatcher,
+    #[cfg(unix)]
+    shm: Option<Mutex<ShmIndex>>,
+    index: Mutex<BlockIndex>,
+    index_dirty: AtomicBool,
+    hot: Option<Mutex<HotCache>>,
+    tx_index: Mutex<TxIndex>,
+    address_index: bool,
+    render_json: bool,
+    store_c4: bool,
+    metadata_files: bool,
+    persist_index: bool,
+    max_payload_bytes: usize,
+    drop_policy: DropPolicy,
+    validation: ValidationSettings,
+    hardforks: Option<HardforkSchedule>,
+    rpc_head: Arc<RpcHead>,
+    block_time: u64,
+    signers: SignerSet,
+    signer_history: Mutex<SignerHistory>,
+    checkpoint: Mutex<Checkpoint>,
+    verify_pool: VerifyPool,
+    gossip_peers: AtomicU32, // verbundene Gossip-Peers für den Vertrauenswert (siehe confidence.rs)
+    invalid_signature: InvalidSignatureMode,
+    attestor: Option<Attestor>,
+    hooks: Option<Hooks>,
+    stats: Arc<StorageStats>,
+    disk: Arc<DiskState>,
+}
+
+impl PreconfStore {
+    /// Erstellt den Store und startet die konfigurierten Sinks (muss im Tokio-Runtime laufen)
+    pub fn new(
+        output_dir: PathBuf,
+        chain_id: u64,
+        settings: &BridgeSettings,
+        stats: Arc<StorageStats>,
+        running: Arc<Mutex<bool>>,
+    ) -> Self {
+        #[cfg(unix)]
+        let shm = settings.shm_slots.and_then(|slots| {
+            // Relativer Pfad = im Output-Verzeichnis (wie der Query-Socket und wie ihn der C-Server auflöst)
+            let path = output_dir.join(settings.shm_path.as_deref().unwrap_or(Path::new(SHM_FILE_NAME)));
+            match ShmIndex::open(&path, chain_id, slots) {
+                Ok(index) => Some(Mutex::new(index)),
+                Err(e) => {
+                    warn!("⚠️  Shared-memory index disabled: {}", e);
+                    None
+                }
+            }
+        });
+
+        let fs = Arc::new(DurableFs::new(&output_dir, settings.durability).with_group_commit(settings.group_commit));
+
+        // Abgebrochene Schreibvorgänge aufräumen, bevor der Index mit dem Verzeichnis abgeglichen wird
+        let journal = WriteJournal::open(&output_dir, matches!(settings.durability, Durability::Flush | Durability::FsyncEach));
+
+        // Persistierten Index laden und mit den Dateien im Output-Verzeichnis abgleichen (Profil tiny: nur im
+        // Speicher); block_index.idx wird nur gemappt, Einträge ohne Datei fallen beim ersten Flush heraus
+        let index = match (settings.index_mmap_blocks, settings.persist_index) {
+            (Some(blocks), _) => BlockIndex::mapped(&output_dir, chain_id, blocks).unwrap_or_else(|e| {
+                warn!("⚠️  Memory-mapped block index disabled: {}", e);
+                BlockIndex::load(&output_dir, chain_id)
+            }),
+            (None, true) => BlockIndex::load(&output_dir, chain_id),
+            (None, false) => BlockIndex::default(),
+        };
+        let continuity = ContinuityMonitor::new(
+            settings.gap_tolerance,
+            settings.gap_backfill && settings.backfill_url.is_some(),
+            index.latest_block().map(|(n, _)| n),
+        );
+        let signer_history = SignerHistory::load(&output_dir, chain_id);
+        let checkpoint = Checkpoint::load(&output_dir, chain_id, profile::current().dedup_blocks);
+
+        // Dictionaries vorhandener Dateien laden, trainiert wird nur mit zstd
+        let zstd_dict = match (&settings.zstd_dict, settings.codec) {
+            (Some(dict), PayloadCodec::Zstd(_)) => {
+                Some(Arc::new(DictTrainer::open(&output_dir, fs.clone(), dict.clone())))
+            }
+            (Some(_), codec) => {
+                warn!("⚠️  KONA_BRIDGE_ZSTD_DICT needs the zstd codec (configured: {}), not training", codec);
+                zdict::load_dir(&output_dir);
+                None
+            }
+            (None, _) => {
+                zdict::load_dir(&output_dir);
+                None
+            }
+        };
+
+        // Deltas bestehender Dateien brauchen ihre Basis aus diesem Verzeichnis, auch wenn der Modus aus ist
+        delta::register_dir(chain_id, &output_dir);
+        let delta = match (&settings.delta, settings.codec) {
+            (Some(delta), PayloadCodec::Zstd(_)) => {
+                info!("🧩 Delta storage: keyframe every {} blocks", delta.keyframe_interval);
+                Some(delta.clone())
+            }
+            (Some(_), codec) => {
+                warn!("⚠️  KONA_BRIDGE_DELTA needs the zstd codec (configured: {}), storing full payloads", codec);
+                None
+            }
+            (None, _) => None,
+        };
+
+        let blobs = if settings.dedup {
+            match BlobStore::open(&output_dir, fs.clone()) {
+                Ok(blobs) => Some(blobs),
+                Err(e) => {
+                    warn!("⚠️  Content-addressed store disabled: {}", e);
+                    None
+                }
+            }
+        } else {
+            None
+        };
+
+        let attestor = settings.attestation.as_ref().and_then(|attestation| match Attestor::from_settings(attestation) {
+            Ok(attestor) => {
+                info!("🔏 Attesting metadata and block index as {}", attestor.address());
+                Some(attestor)
+            }
+            Err(e) => {
+                warn!("⚠️  Attestation disabled: {}", e);
+                None
+            }
+        });
+
+        Self {
+            output_dir,
+            chain_id,
+            codec: settings.codec,
+            blobs,
+            zstd_dict,
+            delta,
+            sampler: settings.sampling.map(|sampling| {
+                info!("🎲 Sampling: storing {} of the verified blocks", sampling);
+                Sampler::new(sampling)
+            }),
+            source_preference: settings.source_preference.clone(),
+            reorg_grace: settings.reorg_grace_secs,
+            reorgs: Mutex::new(ReorgHistory::default()),
+            continuity: Arc::new(continuity),
+            canonical: settings.accuracy_rpc.is_some().then(|| Arc::new(CanonicalTracker::default())),
+            metadata: Arc::new(MetadataWriter::new(fs.clone())),
+            fs,
+            journal,
+            pointer: LatestPointer::new(settings.latest_pointer),
+            hooks: settings.hooks.as_ref().map(|hooks| Hooks::start(hooks, running.clone())),
+            sinks: start_sinks(settings, running),
+            #[cfg(unix)]
+            shm,
+            index: Mutex::new(index),
+            index_dirty: AtomicBool::new(false),
+            hot: (settings.hot_blocks > 0).then(|| Mutex::new(HotCache::new(chain_id, settings.hot_blocks))),
+            tx_index: Mutex::new(TxIndex::default()),
+            address_index: settings.address_index,
+            render_json: settings.render_json,
+            store_c4: settings.store_c4,
+            metadata_files: settings.metadata_files,
+            persist_index: settings.persist_index,
+            max_payload_bytes: settings.max_payload_bytes,
+            drop_policy: settings.gossip_drop_policy,
+            validation: settings.validation.clone(),
+            hardforks: None,
+            rpc_head: Arc::new(RpcHead::default()),
+            block_time: DEFAULT_BLOCK_TIME_SECS,
+            signers: SignerSet::default(),
+            signer_history: Mutex::new(signer_history),
+            checkpoint: Mutex::new(checkpoint),
+            verify_pool: VerifyPool::new(settings.verify_workers),
+            gossip_peers: AtomicU32::new(0),
+            invalid_signature: settings.invalid_signature,
+            attestor,
+            stats,
+            disk: Arc::new(DiskState::default()),
+        }
+    }
+
+    /// Komprimiert und speichert einen Preconf (.raw atomar, dann Symlinks und .json) und publiziert ihn.
+    /// Der Schreibvorgang wird im Journal geklammert, damit ein Absturz keine halben Einträge hinterlässt.
+    pub async fn write(&self, preconf: PreconfWrite) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        let block_number = preconf.block_number;
+        let filename = format!("block_{}_{}.raw", self.chain_id, block_number);
+
+        // Übergroße Payloads (jede Quelle) vor Kompression und Journal verwerfen
+        if preconf.payload.len() > self.max_payload_bytes {
+            self.stats.record_oversized();
+            warn!(
+                "🐘 Dropped block {} from {}: payload of {} bytes exceeds the limit of {} bytes",
+                block_number,
+                preconf.source,
+                preconf.payload.len(),
+                self.max_payload_bytes
+            );
+            return Err(PayloadTooLarge { size: preconf.payload.len(), limit: self.max_payload_bytes }.into());
+        }
+
+        // Bei fast vollem Dateisystem gar nicht erst anfangen (kein halber Eintrag, latest bleibt gültig)
+        if let Some(free) = self.disk.read_only() {
+            return Err(format!("Only {} MB free, block {} not stored", free / (1024 * 1024), block_number).into());
+        }
+
+        self.journal.begin(block_number, &filename).await;
+        let span = logging::preconf_span(self.chain_id, block_number, preconf.source);
+        let result = self.write_entry(preconf).instrument(span).await;
+        self.journal.end(&self.output_dir, block_number, result.is_ok()).await;
+        result
+    }
+
+    /// Führt die Signatur-Recovery vorab aus (Verify-Stufe der Gossip-Pipeline), write() findet das Ergebnis
+    /// danach im Cache des Worker-Pools; Fehler meldet erst write()
+    pub async fn prefetch_signer(&self, preconf: &PreconfWrite) {
+        if self.signers.is_empty() {
+            return;
+        }
+        let recovery = self.verify_pool.recover(preconf.payload.clone(), preconf.signature, self.chain_id);
+        if let Ok(Err(e)) = recovery.await {
+            debug!("Early signature recovery for block {} failed: {}", preconf.block_number, e);
+        }
+    }
+
+    /// Hält einen Preconf fest, der eine Integritätsprüfung nicht besteht (invalid_block_{chain}_{n}.json,
+    /// läuft per TTL ab); .raw, Zeiger, Index und Sinks werden nicht angefasst, damit der C-Verifier ihn nie liest
+    async fn mark_invalid(&self, preconf: &PreconfWrite, reason: &str, details: serde_json::Value) {
+        let path = self.output_dir.join(format!("invalid_block_{}_{}.json", self.chain_id, preconf.block_number));
+        let data = serde_json::to_vec_pretty(&self.invalid_marker(preconf, reason, details)).unwrap_or_default();
+        if let Err(e) = self.fs.write_atomic(&path.with_extension("json.tmp"), &path, &data).await {
+            warn!("⚠️  Cannot write {:?}: {}", path, e);
+        }
+    }
+
+    /// Legt einen Preconf mit ungültiger Signatur unter quarantine/ ab (.raw im konfigurierten Codec plus
+    /// .json mit Begründung). Nicht im Index, in Zeigern oder Sinks; wird per TTL mit aufgeräumt.
+    async fn quarantine(
+        &self,
+        preconf: &PreconfWrite,
+        reason: &str,
+        details: serde_json::Value,
+    ) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        let dir = self.output_dir.join(QUARANTINE_DIR_NAME);
+        tokio::fs::create_dir_all(&dir).await?;
+        perms::apply_dir(&dir);
+
+        let filename = format!("block_{}_{}.raw", self.chain_id, preconf.block_number);
+        let filepath = dir.join(&filename);
+        let payload = Arc::new(preconf.payload.clone());
+        let (raw_data, _) = self.encode(preconf.block_number, payload, preconf.signature, None).await?;
+        self.fs.write_atomic(&filepath.with_extension("tmp"), &filepath, &raw_data).await?;
+
+        let mut marker = self.invalid_marker(preconf, reason, details);
+        marker["file_path"] = serde_json::json!(filename);
+        marker["signature"] = serde_json::json!(format!("0x{}", hex::encode(preconf.signature)));
+        let meta_filepath = filepath.with_extension("json");
+        let data = serde_json::to_vec_pretty(&marker).unwrap_or_default();
+        self.fs.write_atomic(&meta_filepath.with_extension("json.tmp"), &meta_filepath, &data).await?;
+        Ok(())
+    }
+
+    /// Prüft, ob für die Höhe bereits ein anderer, ebenfalls von einem akzeptierten Signer signierter Block
+    /// gespeichert ist, und schreibt dann ein Evidence-Bundle nach equivocations/ (einmal pro Hash-Paar)
+    async fn detect_equivocation(&self, preconf: &PreconfWrite, signer: Address, matched: &str, at: u64, now: u64) {
+        let existing = self.index.lock().ok().and_then(|index| {
+            index
+                .get(preconf.block_number)
+                .filter(|entry| entry.block_hash != preconf.block_hash)
+        });
+        let Some(existing) = existing else {
+            return;
+        };
+
+        let dir = self.output_dir.join(EVIDENCE_DIR_NAME);
+        let path = dir.join(evidence_filename(self.chain_id, preconf.block_number, &existing.block_hash, &preconf.block_hash));
+        if path.exists() {
+            return;
+        }
+
+        // Gespeicherte Version auspacken und ihre Signatur unabhängig vom damaligen Modus neu prüfen
+        let data = match tokio::fs::read(self.output_dir.join(&existing.file)).await {
+            Ok(data) => data,
+            Err(e) => {
+                debug!("Cannot read {} for equivocation check: {}", existing.file, e);
+                return;
+            }
+        };
+        let stored = PreconfContainer::parse(&data).and_then(|container| {
+            let signature: [u8; SIGNATURE_SIZE] = container.signature.try_into()?;
+            Ok((container.payload()?, signature))
+        });
+        let (payload, signature) = match stored {
+            Ok(stored) => stored,
+            Err(e) => {
+                debug!("Cannot decode {} for equivocation check: {}", existing.file, e);
+                return;
+            }
+        };
+        let existing_signer = match self.verify_pool.recover(payload.clone(), signature, self.chain_id).await {
+            Ok(Ok(signer)) => signer,
+            _ => return,
+        };
+        let existing_at = if existing.timestamp > 0 { existing.timestamp } else { at };
+        let Some(existing_match) = self.signers.matching(existing_signer, existing_at) else {
+            return;
+        };
+
+        let metadata = self.read_metadata(preconf.block_number).await;
+        let first = SignedPayload {
+            block_hash: existing.block_hash,
+            payload: &payload,
+            signature: &signature,
+            signer: existing_signer,
+            signer_match: existing_match,
+            source: metadata
+                .as_ref()
+                .and_then(|m| m["source"].as_str())
+                .unwrap_or("stored")
+                .to_string(),
+            received_unix: existing.received_unix,
+            metadata,
+        };
+        let second = SignedPayload {
+            block_hash: preconf.block_hash,
+            payload: &preconf.payload,
+            signature: &preconf.signature,
+            signer,
+            signer_match: matched.to_string(),
+            source: preconf.source.to_string(),
+            received_unix: now,
+            metadata: None,
+        };
+
+        self.stats.record_equivocation();
+        self.emit(HookEvent {
+            event: "equivocation",
+            chain_id: self.chain_id,
+            block_number: preconf.block_number,
+            block_hash: Some(preconf.block_hash),
+            details: serde_json::json!({
+                "first_block_hash": format!("0x{}", hex::encode(existing.block_hash)),
+                "first_signer": format!("{}", existing_signer),
+                "second_signer": format!("{}", signer),
+                "evidence": path.file_name().map(|name| name.to_string_lossy().to_string()),
+            }),
+        });
+        error!(
+            "🚨 Sequencer equivocation at block {}: 0x{} ({}) vs 0x{} ({})",
+            preconf.block_number,
+            hex::encode(existing.block_hash),
+            existing_signer,
+            hex::encode(preconf.block_hash),
+            signer
+        );
+
+        let bundle = evidence_bundle(self.chain_id, preconf.block_number, &first, &second, now);
+        let data = serde_json::to_vec_pretty(&bundle).unwrap_or_default();
+        let written = match tokio::fs::create_dir_all(&dir).await {
+            Ok(()) => {
+                perms::apply_dir(&dir);
+                self.fs.write_atomic(&path.with_extension("json.tmp"), &path, &data).await
+            }
+            Err(e) => Err(e.into()),
+        };
+        match written {
+            Ok(()) => info!("📦 Equivocation evidence written to {:?}", path),
+            Err(e) => warn!("⚠️  Cannot write {:?}: {}", path, e),
+        }
+    }
+
+    /// Derselbe Block (gleicher Hash) aus einer weiteren Quelle: statt ihn erneut zu speichern, nur Quelle und
+    /// Empfangszeit in den Metadaten ergänzen (siehe provenance.rs); false, wenn die Höhe so nicht gespeichert ist
+    async fn merge_duplicate(&self, block_number: u64, block_hash: [u8; 32], source: &str, now: u64) -> bool {
+        let stored = self.index.lock().ok().and_then(|index| {
+            index
+                .get(block_number)
+                .filter(|entry| entry.block_hash == block_hash)
+        });
+        let Some(stored) = stored else {
+            return false;
+        };
+        if !tokio::fs::try_exists(self.output_dir.join(&stored.file)).await.unwrap_or(false) {
+            return false;
+        }
+
+        // Ohne Metadaten-Dateien gibt es nichts zu ergänzen, gespeichert wird trotzdem nicht erneut
+        let Some(mut metadata) = self.read_metadata(block_number).await else {
+            debug!("♻️  Block {} from {} already stored", block_number, source);
+            return true;
+        };
+        if !provenance::merge(&mut metadata, source, now, &self.source_preference) {
+            return true;
+        }
+        confidence::apply(&mut metadata, self.gossip_peers.load(Ordering::Relaxed), &self.validation);
+        if let Some(ref attestor) = self.attestor {
+            match attestor.attest(&canonical_metadata(&metadata)).await {
+                Ok(attestation) => metadata["attestation"] = attestation,
+                Err(e) => warn!("⚠️  Cannot attest metadata of block {}: {}", block_number, e),
+            }
+        }
+        debug!("♻️  Block {} also received from {}, preferred source {}", block_number, source, metadata["source"]);
+        let path = self.output_dir.join(format!("block_{}_{}.json", self.chain_id, block_number));
+        self.metadata.enqueue(path, metadata);
+        true
+    }
+
+    /// Erkennt einen Reorg (gespeicherter Block derselben Höhe mit anderem Hash), verschiebt den bisherigen Block
+    /// mit Gnadenfrist nach superseded/ und meldet das Ereignis (siehe reorg.rs); läuft vor dem Überschreiben
+    async fn detect_reorg(&self, block_number: u64, block_hash: [u8; 32], source: &str, exists: bool, now: u64) {
+        let previous = self.index.lock().ok().and_then(|index| {
+            index
+                .get(block_number)
+                .filter(|entry| entry.block_hash != block_hash)
+        });
+        let Some(previous) = previous else {
+            return;
+        };
+
+        let metadata = self.read_metadata(block_number).await;
+        let previous_source = metadata.as_ref().and_then(|m| m["source"].as_str()).map(str::to_string);
+        let hash = format!("0x{}", hex::encode(block_hash));
+        let mut superseded_file = None;
+        if self.reorg_grace > 0 && exists {
+            let name = superseded_filename(self.chain_id, block_number, &previous.block_hash);
+            let mut marker = metadata.unwrap_or_else(|| {
+                serde_json::json!({
+                    "chain_id": self.chain_id.to_string(),
+                    "block_number": block_number,
+                    "block_hash": format!("0x{}", hex::encode(previous.block_hash)),
+                    "received_unix": previous.received_unix,
+                    "timestamp": previous.timestamp,
+                })
+            });
+            marker["file_path"] = serde_json::json!(format!("{}/{}", SUPERSEDED_DIR_NAME, name));
+            marker["superseded_by"] = serde_json::json!(hash);
+            marker["superseded_unix"] = serde_json::json!(now);
+
+            let output_dir = self.output_dir.clone();
+            let (file, target) = (previous.file.clone(), name.clone());
+            match tokio::task::spawn_blocking(move || keep_superseded(&output_dir, &file, &target, &marker)).await {
+                Ok(Ok(())) => superseded_file = Some(name),
+                Ok(Err(e)) => warn!("⚠️  Cannot keep superseded block {}: {}", block_number, e),
+                Err(e) => warn!("⚠️  Cannot keep superseded block {}: {}", block_number, e),
+            }
+        }
+
+        let event = ReorgEvent {
+            block_number,
+            previous_block_hash: format!("0x{}", hex::encode(previous.block_hash)),
+            block_hash: hash,
+            previous_source,
+            source: source.to_string(),
+            previous_received_unix: previous.received_unix,
+            detected_unix: now,
+            superseded_file,
+        };
+        warn!(
+            "🔀 Reorg at block {}: {} replaced by {} ({})",
+            block_number, event.previous_block_hash, event.block_hash, source
+        );
+        self.stats.record_reorg();
+        self.emit(HookEvent {
+            event: "reorg",
+            chain_id: self.chain_id,
+            block_number,
+            block_hash: Some(block_hash),
+            details: event.hook_details(),
+        });
+        if let Ok(mut reorgs) = self.reorgs.lock() {
+            reorgs.record(event);
+        }
+    }
+
+    /// Lücke oder Rücksprung außerhalb der Toleranz (siehe continuity.rs): Log-Ereignis, Zähler und Hook
+    fn report_discontinuity(&self, block_number: u64, block_hash: [u8; 32], source: &str, found: Discontinuity) {
+        match found {
+            Discontinuity::Gap { expected, received } => {
+                warn!(
+                    event = "gap",
+                    chain_id = self.chain_id,
+                    expected,
+                    received,
+                    missing = received - expected,
+                    source,
+                    "🕳️  Gap before block {}: expected {}, {} blocks missing",
+                    received,
+                    expected,
+                    received - expected
+                );
+                self.stats.record_gap();
+            }
+            Discontinuity::Backwards { highest, received } => {
+                warn!(
+                    event = "backwards",
+                    chain_id = self.chain_id,
+                    highest,
+                    received,
+                    behind = highest - received,
+                    source,
+                    "⏪ Block {} is {} blocks behind the highest captured block {}",
+                    received,
+                    highest - received,
+                    highest
+                );
+                self.stats.record_backwards();
+            }
+        }
+        self.emit(HookEvent {
+            event: found.event(),
+            chain_id: self.chain_id,
+            block_number,
+            block_hash: Some(block_hash),
+            details: found.details(),
+        });
+    }
+
+    /// Ergebnis des Abgleichs mit dem kanonischen Block (siehe accuracy.rs): Zähler, bei Abweichung Log-Ereignis
+    /// und Hook
+    pub fn report_canonical(&self, block_number: u64, comparison: &Comparison) {
+        if comparison.matched() {
+            self.stats.record_canonical_match();
+            return;
+        }
+        self.stats.record_canonical_mismatch(comparison.tx_set_matches());
+        warn!(
+            event = "mismatch",
+            chain_id = self.chain_id,
+            block_number,
+            missing_txs = comparison.missing,
+            extra_txs = comparison.extra,
+            "❌ Preconf of block {} differs from the canonical block: 0x{} vs 0x{}{}",
+            block_number,
+            hex::encode(comparison.preconf_hash),
+            hex::encode(comparison.canonical_hash),
+            if comparison.tx_set_matches() { " (same transactions)" } else { "" }
+        );
+        self.emit(HookEvent {
+            event: "mismatch",
+            chain_id: self.chain_id,
+            block_number,
+            block_hash: Some(comparison.preconf_hash),
+            details: comparison.details(),
+        });
+    }
+
+    /// Gemeinsame Felder für Invalid-Marker und Quarantäne-Metadaten
+    fn invalid_marker(&self, preconf: &PreconfWrite, reason: &str, details: serde_json::Value) -> serde_json::Value {
+        let mut marker = serde_json::json!({
+            "chain_id": self.chain_id.to_string(),
+            "block_number": preconf.block_number,
+            "announced_block_hash": format!("0x{}", hex::encode(preconf.block_hash)),
+            "reason": reason,
+            "received_unix": SystemTime::now().duration_since(UNIX_EPOCH).map(|d| d.as_secs()).unwrap_or(0),
+            "source": preconf.source,
+        });
+        if let serde_json::Value::Object(details) = details {
+            for (key, value) in details {
+                marker[key] = value;
+            }
+        }
+        marker
+    }
+
+    /// Akzeptierte Signer für die Signaturprüfung (ohne Aufruf wird nicht geprüft)
+    pub fn with_signers(mut self, signers: SignerSet) -> Self {
+        self.signers = signers;
+        self
+    }
+
+    /// Hardfork-Zeitplan für Dekodierung und Versionsprüfung (ohne Aufruf: Layout-Erkennung, keine Prüfung)
+    pub fn with_hardforks(mut self, hardforks: Option<HardforkSchedule>) -> Self {
+        self.hardforks = hardforks;
+        self
+    }
+
+    /// Blockzeit der Chain für den Capture-Lag (ohne Aufruf: DEFAULT_BLOCK_TIME_SECS)
+    pub fn with_block_time(mut self, block_time: u64) -> Self {
+        self.block_time = block_time;
+        self
+    }
+
+    /// Bekannter Head (Blocknummer, Alter in Sekunden): der höhere aus bisherigen Captures und Head-RPC
+    fn known_head(&self, now: u64) -> Option<(u64, u64)> {
+        let captured = self
+            .index
+            .lock()
+            .ok()
+            .and_then(|index| index.latest_block().map(|(n, e)| (n, now.saturating_sub(e.received_unix))));
+        captured.into_iter().chain(self.rpc_head.get(now)).max_by_key(|(number, _)| *number)
+    }
+
+    /// Neuester gespeicherter Block (Nummer, Index-Eintrag)
+    pub fn latest_entry(&self) -> Option<(u64, IndexEntry)> {
+        let index = self.index.lock().ok()?;
+        index.latest_block()
+    }
+
+    /// Abstand zwischen Head der Chain und neuestem gespeicherten Preconf (None = noch nichts gespeichert)
+    pub fn capture_lag(&self, now: u64) -> Option<CaptureLag> {
+        lag::measure(self.latest_entry(), self.rpc_head.get(now), self.block_time, now)
+    }
+
+    /// Head laut KONA_BRIDGE_HEAD_RPC (wird von validation::run_head_poll aktualisiert)
+    pub fn rpc_head(&self) -> Arc<RpcHead> {
+        self.rpc_head.clone()
+    }
+
+    /// Wendet das Ergebnis einer Plausibilitätsprüfung an: reject markiert und bricht ab,
+    /// flag sammelt den Befund für die Metadaten
+    async fn apply_check(
+        &self,
+        preconf: &PreconfWrite,
+        check: &str,
+        mode: ValidationMode,
+        result: Result<(), String>,
+        flags: &mut Vec<String>,
+    ) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        let Err(problem) = result else {
+            return Ok(());
+        };
+        match mode {
+            ValidationMode::Off => Ok(()),
+            ValidationMode::Flag => {
+                warn!("⚠️  Block {}: {} ({}), storing flagged", preconf.block_number, problem, check);
+                flags.push(format!("{}: {}", check, problem));
+                Ok(())
+            }
+            ValidationMode::Reject => {
+                self.mark_invalid(preconf, check, serde_json::json!({ "validation_error": problem })).await;
+                Err(format!("Rejected block {}: {}", preconf.block_number, problem).into())
+            }
+        }
+    }
+
+    async fn write_entry(&self, preconf: PreconfWrite) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        let chain_id = self.chain_id;
+        let block_number = preconf.block_number;
+        let decompressed_size = preconf.payload.len();
+
+        // Signatur-Recovery parallel zu Dekodierung und Hash-Prüfung, das Ergebnis wird vor dem Schreiben abgewartet
+        let recovery = (!self.signers.is_empty())
+            .then(|| self.verify_pool.recover(preconf.payload.clone(), preconf.signature, chain_id));
+
+        // Ohne Gossip-Topic gilt die Version laut Hardfork-Zeitplan, das Layout entscheidet nur, wenn das scheitert
+        let render_signature = self.render_json.then_some(&preconf.signature[..]);
+        let scheduled = match (preconf.version, self.hardforks.as_ref()) {
+            (None, Some(forks)) => {
+                extract_timestamp_from_preconf_data(&preconf.payload).ok().map(|ts| forks.payload_version(ts))
+            }
+            _ => None,
+        };
+        let info = match scheduled {
+            Some(version) => PayloadInfo::decode(&preconf.payload, Some(version), render_signature).or_else(|e| {
+                debug!("Payload of block {} not decodable as scheduled {:?}: {}", block_number, version, e);
+                PayloadInfo::decode(&preconf.payload, None, render_signature)
+            }),
+            None => PayloadInfo::decode(&preconf.payload, preconf.version, render_signature),
+        }
+        .unwrap_or_else(|e| {
+            debug!("Payload of block {} not decodable as {:?}: {}", block_number, preconf.version, e);
+            PayloadInfo {
+                version: preconf.version,
+                timestamp: extract_timestamp_from_preconf_data(&preconf.payload).unwrap_or(0),
+                hashes: None,
+                header: None,
+                withdrawals_error: None,
+                gas: None,
+                rendered: None,
+            }
+        });
+        let payload_timestamp = info.timestamp;
+
+        // Block-Hash muss zum Header passen, sonst wird der Preconf nicht gespeichert (nur markiert)
+        if let Some((embedded, computed)) = info.hashes {
+            if embedded != computed || embedded != preconf.block_hash {
+                let details = serde_json::json!({
+                    "block_hash": format!("0x{}", hex::encode(embedded)),
+                    "computed_block_hash": format!("0x{}", hex::encode(computed)),
+                    "block_hash_verified": false,
+                });
+                self.mark_invalid(&preconf, "block_hash", details).await;
+                return Err(format!(
+                    "Block hash mismatch for block {}: payload 0x{}, computed 0x{}",
+                    block_number,
+                    hex::encode(embedded),
+                    hex::encode(computed)
+                )
+                .into());
+            }
+        }
+
+        // Withdrawals müssen zum Header-Feld passen (OP: leere Liste)
+        if let Some(ref error) = info.withdrawals_error {
+            self.mark_invalid(&preconf, "withdrawals", serde_json::json!({ "withdrawals_error": error })).await;
+            return Err(format!("Invalid withdrawals in block {}: {}", block_number, error).into());
+        }
+
+        let now = SystemTime::now().duration_since(UNIX_EPOCH)?.as_secs();
+
+        // Signatur gegen die konfigurierten Signer (Gültigkeitsfenster am Payload-Timestamp);
+        // abgelehnte Signer je nach Modus verwerfen, in die Quarantäne legen oder markiert speichern
+        let mut validation_flags = Vec::new();
+        let mut rejected_signer = None;
+        let signer_match = match recovery {
+            None => None,
+            Some(recovery) => {
+                let at = if payload_timestamp > 0 { payload_timestamp } else { now };
+                let signer = recovery.await?;
+                let matched = signer.as_ref().ok().and_then(|signer| self.signers.matching(*signer, at).map(|m| (*signer, m)));
+                if let (Ok(recovered), Ok(mut history)) = (signer.as_ref(), self.signer_history.lock()) {
+                    history.record(*recovered, block_number, now, matched.as_ref().map(|(_, m)| m.as_str()));
+                }
+                match matched {
+                    Some(matched) => Some(matched),
+                    None => {
+                        let signer = signer.map(|s| format!("{}", s)).unwrap_or_else(|e| format!("unrecoverable: {}", e));
+                        self.stats.record_invalid_signature();
+                        self.emit(HookEvent {
+                            event: "invalid_signature",
+                            chain_id,
+                            block_number,
+                            block_hash: Some(preconf.block_hash),
+                            details: serde_json::json!({
+                                "signer": signer,
+                                "mode": self.invalid_signature.name(),
+                                "source": preconf.source,
+                            }),
+                        });
+                        let error = format!("Invalid signature for block {}: signer {}", block_number, signer);
+                        match self.invalid_signature {
+                            InvalidSignatureMode::Drop => return Err(error.into()),
+                            InvalidSignatureMode::Quarantine => {
+                                let details = serde_json::json!({ "signer": signer });
+                                if let Err(e) = self.quarantine(&preconf, "invalid_signature", details).await {
+                                    warn!("⚠️  Cannot quarantine block {}: {}", block_number, e);
+                                }
+                                return Err(error.into());
+                            }
+                            InvalidSignatureMode::Flag => {
+                                warn!("⚠️  {} (stored flagged)", error);
+                                validation_flags.push(format!("signature: signer {} not accepted", signer));
+                                rejected_signer = Some(signer);
+                                None
+                            }
+                        }
+                    }
+                }
+            }
+        };
+
+        // Gleiche Höhe, anderer Hash, beide gültig signiert -> Evidence festhalten (der neue Block wird trotzdem gespeichert)
+        if let Some((signer, ref matched)) = signer_match {
+            let at = if payload_timestamp > 0 { payload_timestamp } else { now };
+            self.detect_equivocation(&preconf, signer, matched, at, now).await;
+        }
+
+        // Plausibilität (op-node-Regeln): je nach Modus verwerfen oder nur vermerken
+        self.apply_check(
+            &preconf,
+            "timestamp",
+            self.validation.timestamp,
+            check_timestamp(info.timestamp, now, &self.validation),
+            &mut validation_flags,
+        )
+        .await?;
+        let plausible = check_block_number(block_number, self.known_head(now), &self.validation)
+            .and_then(|()| info.gas.map_or(Ok(()), |(limit, used)| check_gas(limit, used, &self.validation)));
+        self.apply_check(&preconf, "plausibility", self.validation.plausibility, plausible, &mut validation_flags)
+            .await?;
+        if let (Some(forks), Some(version)) = (self.hardforks.as_ref(), info.version) {
+            let fork = check_version(forks, info.timestamp, version);
+            self.apply_check(&preconf, "fork", self.validation.fork, fork, &mut validation_flags).await?;
+        }
+
+        // Derselbe Block aus einer weiteren Quelle wird nicht erneut gespeichert, nur seine Herkunft ergänzt
+        if rejected_signer.is_none()
+            && self.merge_duplicate(block_number, preconf.block_hash, preconf.source, now).await
+        {
+            return Ok(());
+        }
+        if preconf.source != BACKFILL_SOURCE {
+            if let Some(discontinuity) = self.continuity.observe(block_number) {
+                self.report_discontinuity(block_number, preconf.block_hash, preconf.source, discontinuity);
+            }
+        }
+
+        // Sampling (KONA_BRIDGE_SAMPLING): geprüft ist der Block, gespeichert wird nur die Stichprobe
+        if let Some(ref sampler) = self.sampler {
+            let at = if payload_timestamp > 0 { payload_timestamp } else { now };
+            if !sampler.keep(block_number, at) {
+                debug!("🎲 Block {} not in sample ({}), skipping", block_number, sampler.mode());
+                self.stats.record_sampled_out();
+                return Ok(());
+            }
+        }
+
+        let filename = format!("block_{}_{}.raw", chain_id, block_number);
+        let filepath = self.output_dir.join(&filename);
+        let payload_size = preconf.payload.len();
+
+        // Transaktionen vor der Kompression indizieren (der Payload wird danach verschoben)
+        let txs = if self.address_index {
+            // Signatur-Recovery kostet CPU, daher außerhalb des Runtime-Threads
+            let payload = preconf.payload.clone();
+            tokio::task::spawn_blocking(move || index_transactions(&payload, true)).await?
+        } else {
+            index_transactions(&preconf.payload, false)
+        }
+        .unwrap_or_else(|e| {
+            warn!("⚠️  Cannot decode transactions of block {}: {}", block_number, e);
+            Vec::new()
+        });
+
+        // Delta gegen den Vorgänger (KONA_BRIDGE_DELTA); ein Delta-Nachfolger der bisherigen Datei dieser Höhe braucht
+        // deren Payload und wird vorher als Keyframe neu geschrieben
+        let payload = Arc::new(preconf.payload);
+        let base = self.delta_base(block_number).await;
+        let exists = tokio::fs::try_exists(&filepath).await.unwrap_or(false);
+        if exists {
+            delta::detach(std::slice::from_ref(&filepath)).await;
+        }
+
+        // Anderer Hash für eine gespeicherte Höhe: bisherigen Block aufbewahren statt still zu überschreiben
+        self.detect_reorg(block_number, preconf.block_hash, preconf.source, exists, now).await;
+
+        let (raw_size, raw_data, compressed_size, content_hash) = match self.blobs {
+            Some(ref blobs) => {
+                let key = content_key(&payload, &preconf.signature);
+                if blobs.is_linked(&key, &filepath).await {
+                    debug!("♻️  Block {} already stored with identical payload, skipping", block_number);
+                    return Ok(());
+                }
+
+                // Blobs aus älteren Formaten (ohne Chain/Block im Header) werden neu geschrieben
+                let reusable = blobs.get(&key).await.and_then(|existing| {
+                    let container = PreconfContainer::parse(&existing).ok()?;
+                    let current = container.chain_id == Some(chain_id) && container.block_number == Some(block_number);
+                    let compressed_size = container.body.len();
+                    current.then_some((existing, compressed_size))
+                });
+                let (raw_data, compressed_size) = match reusable {
+                    Some(existing) => existing,
+                    None => {
+                        let encoded = self.encode(block_number, payload.clone(), preconf.signature, base).await?;
+                        blobs.put(&key, &encoded.0).await?;
+                        encoded
+                    }
+                };
+
+                // Blob kann zwischen get() und link() vom GC entfernt worden sein
+                if blobs.link(&key, &filepath).await.is_err() {
+                    blobs.put(&key, &raw_data).await?;
+                    blobs.link(&key, &filepath).await?;
+                }
+                (raw_data.len(), raw_data, compressed_size, Some(key))
+            }
+            // Braucht niemand im Prozess die fertige Datei, wird sie direkt aus dem Kompressionspuffer geschrieben
+            None if !self.needs_raw_data() => {
+                let (raw_size, compressed_size) =
+                    self.encode_to_file(block_number, payload.clone(), preconf.signature, base, &filepath).await?;
+                (raw_size, Vec::new(), compressed_size, None)
+            }
+            None => {
+                let (raw_data, compressed_size) =
+                    self.encode(block_number, payload.clone(), preconf.signature, base).await?;
+
+                // Write to file: block_{chain_id}_{block_number}.raw (atomar über tmp + rename)
+                let temp_filepath = filepath.with_extension("tmp");
+                self.fs.write_atomic(&temp_filepath, &filepath, &raw_data).await
+                    .map_err(|e| format!("Failed to write {:?}: {}", filepath, e))?;
+                (raw_data.len(), raw_data, compressed_size, None)
+            }
+        };
+
+        if self.delta.is_some() {
+            delta::remember(chain_id, block_number, payload);
+        }
+
+        // Update latest.raw, pre_latest.raw and latest.json
+        update_latest_pointers(&self.output_dir, &filename, chain_id, &self.pointer, &self.fs).await?;
+
+        let meta_filepath = self.output_dir.join(format!("block_{}_{}.json", chain_id, block_number));
+        let timestamp = SystemTime::now().duration_since(UNIX_EPOCH)?.as_secs();
+        let mut metadata = serde_json::json!({
+            "chain_id": chain_id.to_string(),
+            "block_number": block_number,
+            "block_hash": format!("0x{}", hex::encode(preconf.block_hash)),
+            "received_unix": timestamp,
+            "timestamp": payload_timestamp,
+            "signature": format!("0x{}", hex::encode(preconf.signature)),
+            "codec": self.codec.name(),
+            "compressed_size": compressed_size,
+            "decompressed_size": decompressed_size,
+            "file_path": filename,
+            "source": preconf.source,
+            "sources": provenance::sources(preconf.source, timestamp),
+            "kona_p2p": true
+        });
+        metadata["block_hash_verified"] = serde_json::json!(info.hashes.is_some());
+        if let Some((signer, matched)) = signer_match {
+            metadata["signer"] = serde_json::json!(format!("{}", signer));
+            metadata["signer_match"] = serde_json::json!(matched);
+        }
+        if let Some(signer) = rejected_signer {
+            metadata["signer"] = serde_json::json!(signer);
+            metadata["signature_valid"] = serde_json::json!(false);
+        }
+        if !validation_flags.is_empty() {
+            metadata["validation_flags"] = serde_json::json!(validation_flags);
+        }
+        if let Some(version) = info.version {
+            metadata["payload_version"] = serde_json::json!(version.name());
+            metadata["hardfork"] = serde_json::json!(version.hardfork());
+        }
+        if let Some(serde_json::Value::Object(header)) = info.header {
+            for (key, value) in header {
+                metadata[key] = value;
+            }
+        }
+        if let Some(key) = content_hash {
+            metadata["content_hash"] = serde_json::json!(format!("0x{}", hex::encode(key)));
+        }
+        if self.address_index {
+            metadata["address_bloom"] = serde_json::json!(format!("0x{}", hex::encode(address_bloom(&txs))));
+        }
+        confidence::apply(&mut metadata, self.gossip_peers.load(Ordering::Relaxed), &self.validation);
+
+        // Betreiber-Signatur (raw_keccak bindet die .raw Datei ein); ohne Signatur wird trotzdem gespeichert
+        if let Some(ref attestor) = self.attestor {
+            metadata["raw_keccak"] = serde_json::json!(format!("0x{}", hex::encode(keccak256(&raw_data))));
+            match attestor.attest(&canonical_metadata(&metadata)).await {
+                Ok(attestation) => metadata["attestation"] = attestation,
+                Err(e) => warn!("⚠️  Cannot attest metadata of block {}: {}", block_number, e),
+            }
+        }
+
+        // Metadaten serialisiert und schreibt der Hintergrund-Task (mit Group-Commit der nächste Zyklus) über
+        // tmp + rename; ohne Metadaten-Dateien gehen sie nur an Sinks und Hooks
+        if self.metadata_files {
+            self.metadata.enqueue(meta_filepath, metadata.clone());
+        }
+
+        // Optionale JSON-Darstellung (Fehler hier verhindern das Speichern nicht)
+        if let Some(ref rendered) = info.rendered {
+            let path = self.output_dir.join(format!("block_{}_{}{}", chain_id, block_number, RENDERED_SUFFIX));
+            let data = serde_json::to_vec_pretty(rendered).unwrap_or_default();
+            if let Err(e) = self.fs.write_atomic(&path.with_extension("json.tmp"), &path, &data).await {
+                warn!("⚠️  Cannot write {:?}: {}", path, e);
+            }
+        }
+
+        // Optionaler OP_PRECONF-Container für den Verifier (Fehler hier verhindern das Speichern nicht)
+        if self.store_c4 {
+            let data = raw_data.clone();
+            let path = self.output_dir.join(format!("block_{}_{}{}", chain_id, block_number, C4_SUFFIX));
+            match tokio::task::spawn_blocking(move || preconf_proof(&data, chain_id)).await? {
+                Ok(proof) => {
+                    if let Err(e) = self.fs.write_atomic(&path.with_extension("c4.tmp"), &path, &proof).await {
+                        warn!("⚠️  Cannot write {:?}: {}", path, e);
+                    }
+                }
+                Err(e) => warn!("⚠️  Cannot build C4 container for block {}: {}", block_number, e),
+            }
+        }
+
+        // Hot-Tier: Antwort des Query-Sockets vorab erzeugen
+        if let Some(ref hot) = self.hot {
+            let data = raw_data.clone();
+            match tokio::task::spawn_blocking(move || to_legacy(&data, chain_id)).await? {
+                Ok(legacy) => {
+                    if let Ok(mut hot) = hot.lock() {
+                        hot.insert(block_number, filename.clone(), legacy);
+                    }
+                }
+                Err(e) => warn!("⚠️  Block {} not cached in hot tier: {}", block_number, e),
+            }
+        }
+
+        if let Some(ref canonical) = self.canonical {
+            canonical.track(block_number, preconf.block_hash, txs.iter().map(|tx| tx.hash).collect());
+        }
+        if let Ok(mut tx_index) = self.tx_index.lock() {
+            tx_index.insert_block(block_number, txs);
+        }
+
+        self.stats.record_stored(preconf.source, payload_size);
+        self.publish(raw_size, StoredPreconf {
+            chain_id,
+            block_number,
+            block_hash: preconf.block_hash,
+            received_unix: timestamp,
+            timestamp: payload_timestamp,
+            raw_filename: filename,
+            raw_data,
+            metadata,
+        });
+
+        Ok(())
+    }
+
+    /// Fertige .raw Datei wird im Prozess weiterverwendet (Sinks, Hot-Tier, C4, Attestation); sonst schreibt
+    /// encode_to_file sie direkt und die Datei liegt nie am Stück im Speicher
+    fn needs_raw_data(&self) -> bool {
+        !self.sinks.is_empty() || self.hot.is_some() || self.store_c4 || self.attestor.is_some()
+    }
+
+    /// Wie encode, schreibt den Container aber direkt in die .raw Datei (tmp + rename), (Dateigröße, Body-Größe)
+    async fn encode_to_file(
+        &self,
+        block_number: u64,
+        payload: Arc<Vec<u8>>,
+        signature: [u8; SIGNATURE_SIZE],
+        base: Option<Arc<Vec<u8>>>,
+        path: &Path,
+    ) -> Result<(usize, usize), Box<dyn std::error::Error + Send + Sync>> {
+        let codec = self.codec;
+        let chain_id = self.chain_id;
+        let dict = self.observe_payload(&payload);
+        let fs = self.fs.clone();
+        let path = path.to_path_buf();
+        tokio::task::spawn_blocking(move || {
+            let mut sizes = (0, 0);
+            fs.write_atomic_with(&path.with_extension("tmp"), &path, |file| {
+                let reference = reference(&base, &dict);
+                sizes = write_container(codec, chain_id, block_number, &payload, &signature, reference, file)?;
+                Ok::<_, Box<dyn std::error::Error + Send + Sync>>(())
+            })
+            .map_err(|e| format!("Failed to write {:?}: {}", path, e))?;
+            Ok(sizes)
+        })
+        .await?
+    }
+
+    /// Baut den Container im konfigurierten Codec (blockiert je nach Level spürbar -> nicht im Async-Thread),
+    /// mit `base` als Delta gegen den Vorgänger
+    async fn encode(
+        &self,
+        block_number: u64,
+        payload: Arc<Vec<u8>>,
+        signature: [u8; SIGNATURE_SIZE],
+        base: Option<Arc<Vec<u8>>>,
+    ) -> Result<(Vec<u8>, usize), Box<dyn std::error::Error + Send + Sync>> {
+        let codec = self.codec;
+        let chain_id = self.chain_id;
+        let dict = self.observe_payload(&payload);
+        tokio::task::spawn_blocking(move || {
+            encode_container(codec, chain_id, block_number, &payload, &signature, reference(&base, &dict))
+        })
+        .await?
+    }
+
+    /// Payload des Vorgängers als Delta-Basis (KONA_BRIDGE_DELTA), None für Keyframes und wenn der Vorgänger nicht
+    /// (mehr) gespeichert ist
+    async fn delta_base(&self, block_number: u64) -> Option<Arc<Vec<u8>>> {
+        let interval = self.delta.as_ref()?.keyframe_interval;
+        if block_number % interval == 0 {
+            return None;
+        }
+        let base = delta::cached(self.chain_id, block_number - 1)?;
+        let path = self.output_dir.join(format!("block_{}_{}.raw", self.chain_id, block_number - 1));
+        tokio::fs::try_exists(&path).await.unwrap_or(false).then_some(base)
+    }
+
+    /// Payload für das Dictionary-Training vormerken, gibt das aktuelle Dictionary zurück (KONA_BRIDGE_ZSTD_DICT)
+    fn observe_payload(&self, payload: &[u8]) -> Option<Arc<ZstdDict>> {
+        let trainer = self.zstd_dict.as_ref()?;
+        trainer.observe(payload);
+        trainer.current()
+    }
+
+    /// Wird nach erfolgreichem Schreiben von .raw und .json aufgerufen (`raw_data` ist leer, wenn die Datei direkt
+    /// geschrieben wurde, siehe needs_raw_data)
+    fn publish(&self, raw_size: usize, preconf: StoredPreconf) {
+        #[cfg(unix)]
+        if let Some(ref shm) = self.shm {
+            if let Ok(index) = shm.lock() {
+                index.publish(
+                    preconf.block_number,
+                    &preconf.block_hash,
+                    preconf.received_unix,
+                    raw_size as u64,
+                    &preconf.raw_filename,
+                );
+            }
+        }
+
+        if let Ok(mut index) = self.index.lock() {
+            index.insert(
+                preconf.block_number,
+                IndexEntry {
+                    block_hash: preconf.block_hash,
+                    file: preconf.raw_filename.clone(),
+                    received_unix: preconf.received_unix,
+                    timestamp: preconf.timestamp,
+                },
+            );
+            self.index_dirty.store(true, Ordering::Relaxed);
+        }
+        if let Ok(mut checkpoint) = self.checkpoint.lock() {
+            let source = preconf.metadata["source"].as_str().unwrap_or("unknown");
+            checkpoint.record_stored(source, preconf.block_number, &preconf.block_hash, preconf.received_unix);
+        }
+
+        if let Some(ref hooks) = self.hooks {
+            hooks.emit(HookEvent::from_preconf(&preconf));
+        }
+        self.sinks.dispatch(preconf);
+    }
+
+    /// Zustand des Dateisystems (vom Disk-Guard aktualisiert, siehe diskguard.rs)
+    pub fn disk_state(&self) -> Arc<DiskState> {
+        self.disk.clone()
+    }
+
+    /// Hook "disk_full" nach einer Notfall-Löschung
+    pub fn report_disk_full(&self, free_before: u64, free_after: u64, deleted_blocks: usize) {
+        let latest = self.latest_entry();
+        self.emit(HookEvent {
+            event: "disk_full",
+            chain_id: self.chain_id,
+            block_number: latest.as_ref().map_or(0, |(number, _)| *number),
+            block_hash: latest.map(|(_, entry)| entry.block_hash),
+            details: serde_json::json!({
+                "free_bytes": free_before,
+                "free_bytes_after": free_after,
+                "deleted_blocks": deleted_blocks,
+                "read_only": self.disk.read_only().is_some(),
+            }),
+        });
+    }
+
+    /// Reicht ein Ereignis an die Hooks weiter (ohne Hooks nichts)
+    fn emit(&self, event: HookEvent) {
+        if let Some(ref hooks) = self.hooks {
+            hooks.emit(event);
+        }
+    }
+
+    /// Dateiname des neuesten (back = 0) bzw. eines der vorherigen Blöcke
+    pub fn latest_filename(&self, back: usize) -> Option<String> {
+        let indexed = self
+            .index
+            .lock()
+            .ok()
+            .and_then(|index| index.latest(back).map(|e| e.file));
+
+        // Leerer Index (z.B. nur Metadaten ohne Index) - dann latest.json bzw. die Symlinks verwenden
+        indexed.or_else(|| {
+            let link = match back {
+                0 => LATEST_FILE_NAME,
+                1 => PRE_LATEST_FILE_NAME,
+                _ => return None,
+            };
+            read_latest_json(&self.output_dir, back).or_else(|| {
+                std::fs::read_link(self.output_dir.join(link))
+                    .ok()
+                    .and_then(|target| target.file_name().map(|n| n.to_string_lossy().into_owned()))
+            })
+        })
+    }
+
+    /// Dateiname eines Blocks anhand der Nummer
+    pub fn filename_by_number(&self, block_number: u64) -> Option<String> {
+        if let Some(filename) = self
+            .index
+            .lock()
+            .ok()
+            .and_then(|index| index.get(block_number).map(|e| e.file))
+        {
+            return Some(filename);
+        }
+
+        let filename = format!("block_{}_{}.raw", self.chain_id, block_number);
+        self.output_dir.join(&filename).exists().then_some(filename)
+    }
+
+    /// Dateiname eines Blocks anhand des Block-Hashes (nur Blöcke im Index)
+    pub fn filename_by_hash(&self, block_hash: &[u8; 32]) -> Option<String> {
+        let index = self.index.lock().ok()?;
+        index.get_by_hash(block_hash).map(|e| e.file)
+    }
+
+    /// Dateiname des Blocks mit dem Payload-Timestamp am nächsten an `timestamp` bzw. (closest = false)
+    /// des neuesten Blocks mit Timestamp <= `timestamp`
+    pub fn filename_by_timestamp(&self, timestamp: u64, closest: bool) -> Option<String> {
+        let index = self.index.lock().ok()?;
+        index.by_timestamp(timestamp, closest).map(|e| e.file)
+    }
+
+    /// Entfernt gelöschte Dateien aus dem Index und schreibt block_index.json, falls geändert (true = geschrieben;
+    /// block_index.idx wird nur synchronisiert und nicht signiert)
+    pub fn flush_index(&self) -> Result<bool, Box<dyn std::error::Error + Send + Sync>> {
+        let mut index = self.index.lock().map_err(|_| "Block index lock poisoned")?;
+        let pruned = index.retain_existing(&self.output_dir);
+        if !self.persist_index || (pruned == 0 && !self.index_dirty.swap(false, Ordering::Relaxed)) {
+            return Ok(false);
+        }
+        index.save(&self.output_dir, self.chain_id, &self.fs)?;
+        Ok(!index.is_mapped())
+    }
+
+    /// Schreibt signer_history.json, falls seit dem letzten Aufruf Signaturen vermerkt wurden
+    pub fn flush_signer_history(&self) -> Result<bool, Box<dyn std::error::Error + Send + Sync>> {
+        if !self.persist_index {
+            return Ok(false);
+        }
+        let mut history = self.signer_history.lock().map_err(|_| "Signer history lock poisoned")?;
+        history.save_if_dirty(&self.output_dir, self.chain_id, &self.fs)
+    }
+
+    /// Schreibt checkpoint.json, falls sich der Wiederaufsetzpunkt seit dem letzten Aufruf geändert hat
+    pub fn flush_checkpoint(&self) -> Result<bool, Box<dyn std::error::Error + Send + Sync>> {
+        if !self.persist_index {
+            return Ok(false);
+        }
+        let mut checkpoint = self.checkpoint.lock().map_err(|_| "Checkpoint lock poisoned")?;
+        checkpoint.save_if_dirty(&self.output_dir, self.chain_id, &self.fs)
+    }
+
+    /// Cursor des HTTP-Polls aus dem Checkpoint (siehe checkpoint.rs)
+    pub fn poll_cursor(&self) -> Option<PollCursor> {
+        self.checkpoint.lock().ok()?.poll()
+    }
+
+    pub fn record_poll(&self, cursor: PollCursor) {
+        if let Ok(mut checkpoint) = self.checkpoint.lock() {
+            checkpoint.record_poll(cursor);
+        }
+    }
+
+    /// Zuletzt über Gossip verarbeitete Blöcke aus dem Checkpoint, älteste zuerst
+    pub fn gossip_window(&self) -> Vec<u64> {
+        self.checkpoint.lock().map(|checkpoint| checkpoint.gossip_window()).unwrap_or_default()
+    }
+
+    pub fn record_gossip(&self, block_number: u64) {
+        if let Ok(mut checkpoint) = self.checkpoint.lock() {
+            checkpoint.record_gossip(block_number);
+        }
+    }
+
+    /// Höchster Block im Checkpoint (Wiederaufsetzpunkt für den Backfill nach einem Neustart)
+    pub fn checkpoint_highest(&self) -> Option<u64> {
+        self.checkpoint.lock().ok()?.highest()
+    }
+
+    /// Erkannte Reorgs seit dem Start ab `since` (Unix-Sekunden), neueste zuerst
+    pub fn reorgs(&self, since: u64, limit: usize) -> Vec<ReorgEvent> {
+        self.reorgs.lock().map(|reorgs| reorgs.list(since, limit)).unwrap_or_default()
+    }
+
+    /// Erwartete Blockfolge der Chain (für den Lücken-Backfill)
+    pub fn continuity(&self) -> Arc<ContinuityMonitor> {
+        self.continuity.clone()
+    }
+
+    /// Vorgemerkte Blöcke für den Abgleich mit kanonischen Blöcken (None ohne KONA_BRIDGE_ACCURACY_RPC)
+    pub fn canonical_tracker(&self) -> Option<Arc<CanonicalTracker>> {
+        self.canonical.clone()
+    }
+
+    /// Verbundene Gossip-Peers (fließen in den Vertrauenswert neuer Blöcke ein)
+    pub fn set_gossip_peers(&self, peers: u32) {
+        self.gossip_peers.store(peers, Ordering::Relaxed);
+    }
+
+    /// Alle bisher wiederhergestellten Signer (nach erstem Block sortiert)
+    pub fn signer_history(&self) -> Vec<SignerRecord> {
+        self.signer_history.lock().map(|history| history.records()).unwrap_or_default()
+    }
+
+    /// Signiert die aktuelle block_index.json und legt die Signatur in block_index.json.sig ab
+    async fn attest_index(&self) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        let Some(ref attestor) = self.attestor else {
+            return Ok(());
+        };
+        let data = tokio::fs::read(self.output_dir.join(INDEX_FILE_NAME)).await?;
+        let mut attestation = attestor.attest(&data).await?;
+        attestation["file"] = serde_json::json!(INDEX_FILE_NAME);
+        let path = self.output_dir.join(INDEX_SIGNATURE_FILE_NAME);
+        let data = serde_json::to_vec_pretty(&attestation)?;
+        self.fs.write_atomic(&path.with_extension("sig.tmp"), &path, &data).await?;
+        Ok(())
+    }
+
+    /// Setzt latest.raw, pre_latest.raw und latest.json neu auf den neuesten Block im Index
+    /// (z.B. nach Backfill oder Purge); None, wenn kein Block gespeichert ist
+    pub async fn rotate_latest(&self) -> Result<Option<String>, Box<dyn std::error::Error + Send + Sync>> {
+        let latest = self.index.lock().ok().and_then(|index| index.latest(0).map(|e| e.file));
+        let Some(filename) = latest else {
+            return Ok(None);
+        };
+        update_latest_pointers(&self.output_dir, &filename, self.chain_id, &self.pointer, &self.fs).await?;
+        Ok(Some(filename))
+    }
+
+    /// Löscht alle Dateien der Blöcke from..=to und gibt die Anzahl gelöschter Blöcke zurück.
+    /// Index und Hot-Cache werden bereinigt, die latest-Zeiger bei Bedarf neu gesetzt.
+    pub async fn purge(&self, from: u64, to: u64) -> Result<usize, Box<dyn std::error::Error + Send + Sync>> {
+        let mut files = Vec::new();
+        let mut entries = tokio::fs::read_dir(&self.output_dir).await?;
+        while let Some(entry) = entries.next_entry().await? {
+            let path = entry.path();
+            let Some(block_number) = block_number_from_filename(&path, self.chain_id) else { continue };
+            if (from..=to).contains(&block_number) {
+                files.push((block_number, path));
+            }
+        }
+
+        // Block to + 1 kann ein Delta gegen to sein
+        let paths: Vec<PathBuf> = files.iter().map(|(_, path)| path.clone()).collect();
+        delta::detach(&paths).await;
+
+        let mut blocks = Vec::new();
+        for (block_number, path) in files {
+            if tokio::fs::remove_file(&path).await.is_ok() {
+                blocks.push(block_number);
+            }
+        }
+        blocks.sort_unstable();
+        blocks.dedup();
+
+        if let Some(ref hot) = self.hot {
+            if let Ok(mut hot) = hot.lock() {
+                hot.remove_range(from, to);
+            }
+        }
+        if let Ok(mut index) = self.index.lock() {
+            if index.remove_range(from, to) > 0 {
+                self.index_dirty.store(true, Ordering::Relaxed);
+            }
+        }
+        flush_and_attest_index(self).await;
+        if !blocks.is_empty() {
+            self.rotate_latest().await?;
+            info!("🗑️  Purged {} blocks ({} - {}) of chain {}", blocks.len(), from, to, self.chain_id);
+        }
+        Ok(blocks.len())
+    }
+
+    /// Block und Position einer vorbestätigten Transaktion
+    pub fn tx_location(&self, tx_hash: &[u8; 32]) -> Option<TxLocation> {
+        self.tx_index.lock().ok()?.get(tx_hash)
+    }
+
+    /// Vorbestätigte Transaktionen mit `address` als Absender oder Empfänger (neueste zuerst)
+    pub fn address_locations(&self, address: &[u8; 20]) -> Vec<TxLocation> {
+        self.tx_index.lock().map(|tx_index| tx_index.by_address(address)).unwrap_or_default()
+    }
+
+    /// Baut den Transaktions-Index für die neuesten Blöcke im Block-Index aus den Dateien auf (höchstens so viele,
+    /// wie der Transaktions-Index hält; block_index.idx kann deutlich mehr enthalten)
+    pub async fn rebuild_tx_index(&self) {
+        let files = match self.index.lock() {
+            Ok(index) => index.files(),
+            Err(_) => return,
+        };
+        let skip = files.len().saturating_sub(profile::current().index_capacity);
+
+        let mut indexed = 0;
+        for (block_number, filename) in files.into_iter().skip(skip) {
+            let known = self.tx_index.lock().map_or(true, |tx_index| tx_index.contains_block(block_number));
+            if known {
+                continue;
+            }
+            let Ok(data) = self.read_raw(&filename).await else {
+                continue;
+            };
+            let with_addresses = self.address_index;
+            let txs = tokio::task::spawn_blocking(move || {
+                let payload = PreconfContainer::parse(&data)?.payload()?;
+                index_transactions(&payload, with_addresses)
+            })
+            .await;
+            match txs {
+                Ok(Ok(txs)) => {
+                    if let Ok(mut tx_index) = self.tx_index.lock() {
+                        // Inzwischen neu geschriebene Blöcke nicht mit altem Stand überschreiben
+                        if !tx_index.contains_block(block_number) {
+                            tx_index.insert_block(block_number, txs);
+                            indexed += 1;
+                        }
+                    }
+                }
+                Ok(Err(e)) => debug!("Skipping block {} for tx index: {}", block_number, e),
+                Err(e) => debug!("Skipping block {} for tx index: {}", block_number, e),
+            }
+        }
+
+        if indexed > 0 {
+            info!("🧾 Transaction index: rebuilt {} blocks", indexed);
+        }
+    }
+
+    /// Antwort für den Query-Socket aus dem Hot-Tier (None = nicht im Speicher)
+    pub fn hot_get(&self, filename: &str) -> Option<Arc<Vec<u8>>> {
+        self.hot.as_ref()?.lock().ok()?.get(filename)
+    }
+
+    /// Speicher-Statistiken (Zähler, Belegung über StorageStats::snapshot)
+    pub fn storage_stats(&self) -> Arc<StorageStats> {
+        self.stats.clone()
+    }
+
+    /// Grenze für die Payload eines Preconfs (KONA_BRIDGE_MAX_PAYLOAD_KB)
+    pub fn max_payload_bytes(&self) -> usize {
+        self.max_payload_bytes
+    }
+
+    /// Verhalten der Gossip-Pipeline bei voller Queue (KONA_BRIDGE_GOSSIP_DROP_POLICY)
+    pub fn drop_policy(&self) -> DropPolicy {
+        self.drop_policy
+    }
+
+    pub fn output_dir(&self) -> &Path {
+        &self.output_dir
+    }
+
+    pub fn durable_fs(&self) -> Arc<DurableFs> {
+        self.fs.clone()
+    }
+
+    pub fn metadata_writer(&self) -> Arc<MetadataWriter> {
+        self.metadata.clone()
+    }
+
+    pub fn chain_id(&self) -> u64 {
+        self.chain_id
+    }
+
+    /// Liest die gespeicherten Rohdaten einer Preconf-Datei
+    pub async fn read_raw(&self, filename: &str) -> std::io::Result<Vec<u8>> {
+        tokio::fs::read(self.output_dir.join(filename)).await
+    }
+
+    /// Beim Speichern erzeugter OP_PRECONF-Container (nur mit KONA_BRIDGE_STORE_C4)
+    pub async fn read_c4(&self, block_number: u64) -> Option<Vec<u8>> {
+        let path = self.output_dir.join(format!("block_{}_{}{}", self.chain_id, block_number, C4_SUFFIX));
+        tokio::fs::read(path).await.ok()
+    }
+
+    /// Gespeicherte Metadaten (block_{chain}_{n}.json) eines Blocks
+    pub async fn read_metadata(&self, block_number: u64) -> Option<serde_json::Value> {
+        let path = self.output_dir.join(format!("block_{}_{}.json", self.chain_id, block_number));
+        if let Some(metadata) = self.metadata.get(&path) {
+            return Some(metadata);
+        }
+        let data = match self.fs.read_staged(&path) {
+            Some(data) => data,
+            None => tokio::fs::read(&path).await.ok()?,
+        };
+        serde_json::from_slice(&data).ok()
+    }
+}
+
+/// Intervall, in dem der Block-Index nach block_index.json geschrieben wird
+const INDEX_FLUSH_INTERVAL: Duration = Duration::from_secs(30);
+
+/// Schreibt den Block-Index und den Signer-Verlauf periodisch und beim Beenden
+pub async fn run_index_flush(store: Arc<PreconfStore>, running: Arc<Mutex<bool>>) {
+    let mut interval_timer = interval(INDEX_FLUSH_INTERVAL);
+
+    while *running.lock().unwrap() {
+        interval_timer.tick().await;
+        flush_and_attest_index(&store).await;
+    }
+
+    flush_and_attest_index(&store).await;
+}
+
+/// Group-Commit (KONA_BRIDGE_GROUP_COMMIT): gesammelte Metadaten, der fällige Index und höchstens ein Sync in einem
+/// Zyklus; ersetzt run_index_flush und run_group_sync
+pub async fn run_group_commit(store: Arc<PreconfStore>, interval_ms: u64, running: Arc<Mutex<bool>>) {
+    info!("💾 Group commit: metadata and index every {}ms", interval_ms);
+    let mut interval_timer = interval(Duration::from_millis(interval_ms.max(1)));
+    let mut last_index_flush = Instant::now();
+
+    while *running.lock().unwrap() {
+        interval_timer.tick().await;
+        let index_due = last_index_flush.elapsed() >= INDEX_FLUSH_INTERVAL;
+        if index_due {
+            last_index_flush = Instant::now();
+        }
+        commit_cycle(&store, index_due).await;
+    }
+
+    commit_cycle(&store, true).await;
+}
+
+async fn commit_cycle(store: &PreconfStore, flush_index: bool) {
+    // Vorgemerkte Metadaten zuerst in den Puffer, Fehler einzelner Dateien loggen beide selbst
+    store.metadata.write_pending().await;
+    if let Ok(files) = store.fs.commit_staged().await {
+        if files > 0 {
+            debug!("💾 Group commit: {} file(s)", files);
+        }
+    }
+    if flush_index {
+        flush_and_attest_index(store).await;
+    }
+    if let Err(e) = store.fs.sync_pending().await {
+        warn!("⚠️  Group sync failed: {}", e);
+    }
+}
+
+async fn flush_and_attest_index(store: &PreconfStore) {
+    if let Err(e) = store.flush_signer_history() {
+        warn!("⚠️  Failed to persist signer history: {}", e);
+    }
+    if let Err(e) = store.flush_checkpoint() {
+        warn!("⚠️  Failed to persist checkpoint: {}", e);
+    }
+    match store.flush_index() {
+        Ok(true) => {
+            if let Err(e) = store.attest_index().await {
+                warn!("⚠️  Cannot attest block index: {}", e);
+            }
+        }
+        Ok(false) => {}
+        Err(e) => warn!("⚠️  Failed to persist block index: {}", e),
+    }
+}
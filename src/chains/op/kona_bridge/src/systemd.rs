@@ -0,0 +1,164 @@
+// systemd.rs - Startmeldung und Watchdog für systemd (Type=notify, WatchdogSec=), nur `kona_bridge capture`
+//
+// Ohne NOTIFY_SOCKET (Start außerhalb von systemd) passiert nichts. Mit Type=notify meldet die Bridge:
+//   READY=1       sobald jede Chain gestartet ist (Verzeichnis-Sperre, Chain-Konfiguration, Chain-ID-Abgleich)
+//                 oder beim Start aufgegeben hat
+//   STATUS=...    Anzahl laufender Chains, bei einem Watchdog-Problem die hängende Chain
+//   STOPPING=1    auf SIGINT/SIGTERM
+//   WATCHDOG=1    mit WatchdogSec= alle WATCHDOG_USEC/2, aber nur solange jede Chain lebt
+// Lebendig ist eine Chain, solange ihre Capture-Schleife (HTTP-Poll bzw. Gossip-Event-Loop) innerhalb von
+// KONA_BRIDGE_WATCHDOG_STALL_SECS (Default 60, mindestens dreimal das HTTP-Poll-Intervall) einen Durchlauf
+// meldet. Hängt eine Schleife, bleibt WATCHDOG=1 aus und systemd startet die Bridge nach WatchdogSec neu
+// (Restart=on-failure bzw. on-watchdog). Ausfälle der Quelle zählen nicht, die Schleife läuft dabei weiter.
+
+use crate::settings::env_string;
+use std::{
+    collections::HashMap,
+    os::unix::net::UnixDatagram,
+    sync::{
+        atomic::{AtomicU64, Ordering},
+        Arc, Mutex, OnceLock, RwLock,
+    },
+    time::{Duration, SystemTime, UNIX_EPOCH},
+};
+use tokio::time::interval;
+use tracing::{debug, info, warn};
+
+pub const DEFAULT_WATCHDOG_STALL_SECS: u64 = 60;
+/// Prüfintervall bis READY=1 gemeldet ist
+const READY_POLL: Duration = Duration::from_millis(200);
+
+/// Letzter Durchlauf der Capture-Schleife einer Chain
+struct Liveness {
+    last_beat: AtomicU64, // Unix-Sekunden
+    stall_secs: u64,
+    running: Arc<Mutex<bool>>,
+}
+
+/// Registrierte Chains nach Chain-ID
+static CHAINS: OnceLock<RwLock<HashMap<u64, Arc<Liveness>>>> = OnceLock::new();
+
+fn chains() -> &'static RwLock<HashMap<u64, Arc<Liveness>>> {
+    CHAINS.get_or_init(|| RwLock::new(HashMap::new()))
+}
+
+fn unix_now() -> u64 {
+    SystemTime::now().duration_since(UNIX_EPOCH).map_or(0, |d| d.as_secs())
+}
+
+/// Meldet die Chain nach dem Start an; `stall_secs` ist die erlaubte Pause der Capture-Schleife
+pub fn register(chain_id: u64, stall_secs: u64, running: Arc<Mutex<bool>>) {
+    let liveness = Liveness { last_beat: AtomicU64::new(unix_now()), stall_secs, running };
+    if let Ok(mut chains) = chains().write() {
+        chains.insert(chain_id, Arc::new(liveness));
+    }
+}
+
+/// Durchlauf der Capture-Schleife (HTTP-Poll, Gossip-Event-Loop)
+pub fn beat(chain_id: u64) {
+    if let Some(liveness) = chains().read().ok().and_then(|chains| chains.get(&chain_id).cloned()) {
+        liveness.last_beat.store(unix_now(), Ordering::Relaxed);
+    }
+}
+
+fn registered(chain_id: u64) -> bool {
+    chains().read().is_ok_and(|chains| chains.contains_key(&chain_id))
+}
+
+/// Laufende Chains und die erste, deren Capture-Schleife hängt (Chain-ID, Sekunden seit dem letzten Durchlauf)
+fn liveness() -> (usize, Option<(u64, u64)>) {
+    let now = unix_now();
+    let Ok(chains) = chains().read() else {
+        return (0, None);
+    };
+    let mut live = 0;
+    let mut stalled = None;
+    let mut chain_ids: Vec<u64> = chains.keys().copied().collect();
+    chain_ids.sort_unstable();
+    for chain_id in chain_ids {
+        let liveness = &chains[&chain_id];
+        if !*liveness.running.lock().unwrap() {
+            continue;
+        }
+        live += 1;
+        let idle = now.saturating_sub(liveness.last_beat.load(Ordering::Relaxed));
+        if idle > liveness.stall_secs && stalled.is_none() {
+            stalled = Some((chain_id, idle));
+        }
+    }
+    (live, stalled)
+}
+
+/// Schickt eine Nachricht an NOTIFY_SOCKET (Pfad oder abstrakter Socket "@..."); ohne systemd ein No-op
+pub fn notify(state: &str) {
+    let Some(path) = env_string("NOTIFY_SOCKET") else {
+        return;
+    };
+    let sent = UnixDatagram::unbound().and_then(|socket| match path.strip_prefix('@') {
+        #[cfg(target_os = "linux")]
+        Some(name) => {
+            use std::os::linux::net::SocketAddrExt;
+            let addr = std::os::unix::net::SocketAddr::from_abstract_name(name)?;
+            socket.send_to_addr(state.as_bytes(), &addr)
+        }
+        _ => socket.send_to(state.as_bytes(), &path),
+    });
+    if let Err(e) = sent {
+        debug!("sd_notify {:?} failed: {}", state, e);
+    }
+}
+
+/// Ping-Intervall aus WATCHDOG_USEC (None = kein Watchdog oder für einen anderen Prozess bestimmt)
+fn watchdog_interval() -> Option<Duration> {
+    let usec: u64 = env_string("WATCHDOG_USEC")?.parse().ok().filter(|usec| *usec > 0)?;
+    if let Some(pid) = env_string("WATCHDOG_PID") {
+        if pid.parse::<u32>().ok() != Some(std::process::id()) {
+            return None;
+        }
+    }
+    Some(Duration::from_micros(usec / 2).max(Duration::from_millis(100)))
+}
+
+/// Meldet READY=1, sobald jede Chain angemeldet ist oder aufgegeben hat, und pingt danach den Watchdog
+pub async fn run_notify(chains: Vec<(u64, Arc<Mutex<bool>>)>) {
+    if env_string("NOTIFY_SOCKET").is_none() {
+        return;
+    }
+    let stopped = |running: &Arc<Mutex<bool>>| !*running.lock().unwrap();
+    while !chains.iter().all(|(chain_id, running)| registered(*chain_id) || stopped(running)) {
+        tokio::time::sleep(READY_POLL).await;
+    }
+    let (live, _) = liveness();
+    notify(&format!("READY=1\nSTATUS=Capturing {} chain(s)", live));
+    info!("🐕 systemd notified: ready, {} chain(s)", live);
+
+    let Some(period) = watchdog_interval() else {
+        return;
+    };
+    info!("🐕 systemd watchdog: ping every {}ms while all capture loops are alive", period.as_millis());
+    let mut ticker = interval(period);
+    let mut stalled_before = None;
+    loop {
+        ticker.tick().await;
+        if chains.iter().all(|(_, running)| stopped(running)) {
+            break;
+        }
+        let (live, stalled) = liveness();
+        match stalled {
+            None => {
+                if stalled_before.take().is_some() {
+                    info!("🐕 Capture loops alive again, resuming watchdog pings");
+                    notify(&format!("STATUS=Capturing {} chain(s)", live));
+                }
+                notify("WATCHDOG=1");
+            }
+            Some((chain_id, idle)) => {
+                if stalled_before != Some(chain_id) {
+                    warn!("⚠️  Chain {} capture loop stalled for {}s, withholding watchdog ping", chain_id, idle);
+                    notify(&format!("STATUS=Chain {} capture loop stalled for {}s", chain_id, idle));
+                    stalled_before = Some(chain_id);
+                }
+            }
+        }
+    }
+}
@@ -0,0 +1,203 @@
+// tiering.rs - Hot/Cold-Tiering der gespeicherten Preconfs
+//
+// Hot: die letzten KONA_BRIDGE_HOT_BLOCKS Blöcke liegen zusätzlich im Speicher, bereits im Format,
+// das der Query-Socket ausliefert (zstd + Signatur) - Anfragen auf aktuelle Blöcke brauchen
+// weder Dateizugriff noch Konvertierung.
+// Cold: ein Hintergrund-Task komprimiert ältere .raw Dateien mit KONA_BRIDGE_COLD_CODEC
+// (z.B. zstd:19) neu, damit der Schreibpfad einen schnellen Codec verwenden kann und die
+// Historie trotzdem wenig Platz belegt.
+
+use crate::{
+    codec::{encode_container, PayloadCodec, PreconfContainer},
+    membudget,
+    storage::PreconfStore,
+    utils::block_number_from_filename,
+};
+use std::{
+    collections::BTreeMap,
+    os::unix::fs::MetadataExt,
+    path::Path,
+    sync::{Arc, Mutex},
+    time::Duration,
+};
+use tokio::{fs as tokio_fs, time::interval};
+use tracing::{debug, info, warn};
+
+/// Intervall des Compactors
+const COMPACT_INTERVAL: Duration = Duration::from_secs(60);
+
+/// Maximal neu komprimierte Dateien pro Durchlauf (begrenzt die CPU-Last)
+const COMPACT_BATCH: usize = 64;
+
+/// Die neuesten Blöcke werden nie neu komprimiert (latest/pre_latest)
+const MIN_HOT_BLOCKS: usize = 2;
+
+struct HotEntry {
+    filename: String,
+    data: Arc<Vec<u8>>,
+}
+
+/// Speicher-Cache der neuesten Blöcke (Antworten des Query-Sockets)
+pub struct HotCache {
+    chain_id: u64,
+    capacity: usize,
+    entries: BTreeMap<u64, HotEntry>,
+}
+
+impl HotCache {
+    pub fn new(chain_id: u64, capacity: usize) -> Self {
+        Self {
+            chain_id,
+            capacity,
+            entries: BTreeMap::new(),
+        }
+    }
+
+    pub fn insert(&mut self, block_number: u64, filename: String, data: Vec<u8>) {
+        self.entries.insert(
+            block_number,
+            HotEntry {
+                filename,
+                data: Arc::new(data),
+            },
+        );
+        // Unter Speicherdruck (siehe membudget.rs) nur noch latest/pre_latest halten
+        let capacity = match membudget::under_pressure() {
+            true => self.capacity.min(MIN_HOT_BLOCKS),
+            false => self.capacity,
+        };
+        while self.entries.len() > capacity {
+            self.entries.pop_first();
+        }
+    }
+
+    /// Entfernt die Blöcke from..=to (Purge über die Admin-API)
+    pub fn remove_range(&mut self, from: u64, to: u64) {
+        self.entries.retain(|block_number, _| !(from..=to).contains(block_number));
+    }
+
+    pub fn get(&self, filename: &str) -> Option<Arc<Vec<u8>>> {
+        let block_number = block_number_from_filename(Path::new(filename), self.chain_id)?;
+        self.entries
+            .get(&block_number)
+            .filter(|entry| entry.filename == filename)
+            .map(|entry| entry.data.clone())
+    }
+}
+
+/// Komprimiert .raw Dateien außerhalb der Hot-Blöcke periodisch mit `cold_codec` neu
+pub async fn run_compactor(
+    store: Arc<PreconfStore>,
+    cold_codec: PayloadCodec,
+    hot_blocks: usize,
+    running: Arc<Mutex<bool>>,
+) {
+    let hot_blocks = hot_blocks.max(MIN_HOT_BLOCKS);
+    let chain_id = store.chain_id();
+    info!("🧊 Cold tier: recompressing blocks older than the newest {} with {}", hot_blocks, cold_codec);
+    let mut interval_timer = interval(COMPACT_INTERVAL);
+
+    while *running.lock().unwrap() {
+        interval_timer.tick().await;
+
+        // Grenze: alles unterhalb des hot_blocks-neuesten Blocks ist cold
+        let Some(threshold) = store
+            .latest_filename(hot_blocks - 1)
+            .and_then(|f| block_number_from_filename(Path::new(&f), chain_id))
+        else {
+            continue;
+        };
+
+        match compact(&store, cold_codec, threshold).await {
+            Ok(0) => {}
+            Ok(count) => info!("🧊 Cold tier: recompressed {} files with {}", count, cold_codec),
+            Err(e) => warn!("⚠️  Cold tier compaction failed: {}", e),
+        }
+    }
+}
+
+async fn compact(
+    store: &PreconfStore,
+    cold_codec: PayloadCodec,
+    threshold: u64,
+) -> Result<usize, Box<dyn std::error::Error + Send + Sync>> {
+    let output_dir = store.output_dir();
+    let mut candidates = Vec::new();
+
+    let mut entries = tokio_fs::read_dir(output_dir).await?;
+    while let Some(entry) = entries.next_entry().await? {
+        let path = entry.path();
+        if path.extension().map_or(true, |ext| ext != "raw") {
+            continue;
+        }
+        match block_number_from_filename(&path, store.chain_id()) {
+            Some(block_number) if block_number < threshold => candidates.push((block_number, path)),
+            _ => {}
+        }
+    }
+    candidates.sort_unstable_by_key(|(block_number, _)| *block_number);
+
+    let mut recompressed = 0;
+    for (block_number, path) in candidates {
+        if recompressed >= COMPACT_BATCH {
+            break;
+        }
+        match recompress(store, cold_codec, block_number, &path).await {
+            Ok(true) => recompressed += 1,
+            Ok(false) => {}
+            Err(e) => debug!("Skipping {:?}: {}", path.file_name().unwrap_or_default(), e),
+        }
+    }
+    Ok(recompressed)
+}
+
+/// Komprimiert eine Datei neu, false wenn sie bereits im Cold-Codec vorliegt oder übersprungen wird
+async fn recompress(
+    store: &PreconfStore,
+    cold_codec: PayloadCodec,
+    block_number: u64,
+    path: &Path,
+) -> Result<bool, Box<dyn std::error::Error + Send + Sync>> {
+    // Dedup-Einträge sind Hardlinks auf geteilte Blobs und bleiben unverändert
+    let metadata = tokio_fs::symlink_metadata(path).await?;
+    if !metadata.is_file() || metadata.nlink() > 1 {
+        return Ok(false);
+    }
+
+    let data = tokio_fs::read(path).await?;
+    let chain_id = store.chain_id();
+    let encoded = tokio::task::spawn_blocking(move || {
+        let container = PreconfContainer::parse(&data)?;
+        if container.codec == cold_codec {
+            return Ok(None);
+        }
+        let payload = container.payload()?;
+        let signature: [u8; 65] = container.signature.try_into()?;
+        let (raw, body_len) = encode_container(cold_codec, chain_id, block_number, &payload, &signature, None)?;
+        Ok::<_, Box<dyn std::error::Error + Send + Sync>>(Some((raw, body_len, data.len())))
+    })
+    .await??;
+
+    let Some((raw, body_len, old_size)) = encoded else {
+        return Ok(false);
+    };
+    if raw.len() >= old_size {
+        return Ok(false);
+    }
+
+    let fs = store.durable_fs();
+    fs.write_atomic(&path.with_extension("tmp"), path, &raw).await?;
+
+    // Metadaten nachziehen (Codec und Größe)
+    let meta_path = path.with_extension("json");
+    if let Ok(meta) = tokio_fs::read(&meta_path).await {
+        let mut metadata: serde_json::Value = serde_json::from_slice(&meta)?;
+        metadata["codec"] = serde_json::json!(cold_codec.name());
+        metadata["compressed_size"] = serde_json::json!(body_len);
+        let json = serde_json::to_string_pretty(&metadata)?;
+        fs.write_atomic(&meta_path.with_extension("json.tmp"), &meta_path, json.as_bytes()).await?;
+    }
+
+    debug!("🧊 Block {}: {} -> {} bytes", block_number, old_size, raw.len());
+    Ok(true)
+}
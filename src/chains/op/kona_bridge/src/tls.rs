@@ -0,0 +1,215 @@
+// tls.rs - TLS für HTTP-API und gRPC mit Zertifikatswechsel ohne Neustart
+//
+// KONA_BRIDGE_TLS_CERT (PEM, Zertifikatskette mit dem Serverzertifikat zuerst) und KONA_BRIDGE_TLS_KEY (PEM,
+// PKCS#8, PKCS#1 oder SEC1) schalten TLS für beide Listener ein. Die Dateien werden bei Änderung (mtime) und auf
+// SIGHUP neu gelesen; das neue Paar gilt für neue Verbindungen, offene SSE-/WebSocket-Verbindungen und gRPC-Streams
+// laufen mit ihrem bisherigen Handshake weiter. Ist das neue Paar unlesbar oder passt der Schlüssel nicht zum
+// Zertifikat, bleibt das bisherige aktiv (z.B. während certbot erst eine der beiden Dateien geschrieben hat).
+// Handshakes laufen parallel zum Accept, ein langsamer Client hält also keine anderen auf.
+
+use crate::{handoff, settings::TlsSettings};
+use rustls::{
+    crypto::{ring::default_provider, CryptoProvider},
+    pki_types::{pem::PemObject, CertificateDer, PrivateKeyDer},
+    server::{ClientHello, ResolvesServerCert},
+    sign::CertifiedKey,
+    ServerConfig,
+};
+use std::{
+    fs, io,
+    net::SocketAddr,
+    path::Path,
+    sync::{Arc, RwLock},
+    time::{Duration, SystemTime},
+};
+use tokio::{
+    net::{TcpListener, TcpStream},
+    sync::mpsc,
+    time::{interval, sleep, timeout},
+};
+use tokio_rustls::{server::TlsStream, TlsAcceptor};
+use tracing::{debug, info, warn};
+
+/// Intervall, in dem Zertifikat und Schlüssel auf Änderungen geprüft werden
+const FILE_CHECK_INTERVAL: Duration = Duration::from_secs(5);
+/// Zeit, die ein Client für den Handshake hat
+const HANDSHAKE_TIMEOUT: Duration = Duration::from_secs(10);
+/// Fertige Handshakes, die auf den Server warten dürfen
+const PENDING_CONNECTIONS: usize = 64;
+/// Pause nach einem Fehler beim Accept (z.B. keine File-Deskriptoren mehr)
+const ACCEPT_ERROR_BACKOFF: Duration = Duration::from_millis(100);
+
+/// ALPN der beiden Listener (axum spricht hier nur HTTP/1.1, gRPC braucht h2)
+pub const ALPN_HTTP: &[u8] = b"http/1.1";
+pub const ALPN_GRPC: &[u8] = b"h2";
+
+/// Aktuelles Zertifikat für alle Handshakes, wird beim Reload ausgetauscht
+#[derive(Debug)]
+struct ReloadableCert {
+    current: RwLock<Arc<CertifiedKey>>,
+}
+
+impl ResolvesServerCert for ReloadableCert {
+    fn resolve(&self, _client_hello: ClientHello<'_>) -> Option<Arc<CertifiedKey>> {
+        self.current.read().ok().map(|current| current.clone())
+    }
+}
+
+pub struct Tls {
+    settings: TlsSettings,
+    provider: Arc<CryptoProvider>,
+    cert: Arc<ReloadableCert>,
+}
+
+impl Tls {
+    /// Lädt Zertifikat und Schlüssel und startet die Überwachung der Dateien (im Runtime des Servers)
+    pub fn start(settings: &TlsSettings) -> Result<Arc<Self>, Box<dyn std::error::Error + Send + Sync>> {
+        let provider = Arc::new(default_provider());
+        let certified = load_certified_key(settings, &provider)?;
+        info!("🔒 TLS enabled with certificate {:?}", settings.cert_path);
+        let tls = Arc::new(Self {
+            settings: settings.clone(),
+            provider,
+            cert: Arc::new(ReloadableCert { current: RwLock::new(Arc::new(certified)) }),
+        });
+        tokio::spawn(watch_files(tls.clone()));
+        Ok(tls)
+    }
+
+    /// Server-Konfiguration für einen Listener mit dem jeweils aktuellen Zertifikat
+    pub fn server_config(&self, alpn: &[u8]) -> Result<Arc<ServerConfig>, Box<dyn std::error::Error + Send + Sync>> {
+        let mut config = ServerConfig::builder_with_provider(self.provider.clone())
+            .with_safe_default_protocol_versions()?
+            .with_no_client_auth()
+            .with_cert_resolver(self.cert.clone());
+        config.alpn_protocols = vec![alpn.to_vec()];
+        Ok(Arc::new(config))
+    }
+
+    /// Liest Zertifikat und Schlüssel neu; bei Fehlern bleibt das bisherige Paar aktiv
+    fn reload(&self) {
+        match load_certified_key(&self.settings, &self.provider) {
+            Ok(certified) => {
+                if let Ok(mut current) = self.cert.current.write() {
+                    *current = Arc::new(certified);
+                }
+                info!("🔒 Reloaded TLS certificate from {:?}", self.settings.cert_path);
+            }
+            Err(e) => warn!("⚠️  Cannot reload TLS certificate, keeping previous one: {}", e),
+        }
+    }
+}
+
+fn load_certified_key(
+    settings: &TlsSettings,
+    provider: &CryptoProvider,
+) -> Result<CertifiedKey, Box<dyn std::error::Error + Send + Sync>> {
+    let certs = CertificateDer::pem_file_iter(&settings.cert_path)
+        .and_then(|certs| certs.collect::<Result<Vec<_>, _>>())
+        .map_err(|e| format!("Cannot read certificate {:?}: {}", settings.cert_path, e))?;
+    if certs.is_empty() {
+        return Err(format!("No certificate in {:?}", settings.cert_path).into());
+    }
+    let key = PrivateKeyDer::from_pem_file(&settings.key_path)
+        .map_err(|e| format!("Cannot read private key {:?}: {}", settings.key_path, e))?;
+    CertifiedKey::from_der(certs, key, provider).map_err(|e| {
+        format!("Key {:?} does not fit certificate {:?}: {}", settings.key_path, settings.cert_path, e).into()
+    })
+}
+
+fn file_modified(path: &Path) -> Option<SystemTime> {
+    fs::metadata(path).and_then(|m| m.modified()).ok()
+}
+
+/// Lädt das Paar neu, sobald sich eine der Dateien ändert oder SIGHUP kommt (läuft bis zum Prozessende)
+async fn watch_files(tls: Arc<Tls>) {
+    #[cfg(unix)]
+    let mut signal = tokio::signal::unix::signal(tokio::signal::unix::SignalKind::hangup())
+        .map_err(|e| warn!("⚠️  SIGHUP reload of the TLS certificate disabled: {}", e))
+        .ok();
+    let mut ticker = interval(FILE_CHECK_INTERVAL);
+    let modified = || (file_modified(&tls.settings.cert_path), file_modified(&tls.settings.key_path));
+    let mut last = modified();
+    loop {
+        #[cfg(unix)]
+        let by_signal = tokio::select! {
+            _ = ticker.tick() => false,
+            _ = crate::signer::hangup(&mut signal) => true,
+        };
+        #[cfg(not(unix))]
+        let by_signal = {
+            ticker.tick().await;
+            false
+        };
+        let current = modified();
+        if !by_signal && current == last {
+            continue;
+        }
+        last = current;
+        tls.reload();
+    }
+}
+
+/// Nimmt TCP-Verbindungen an und liefert sie nach erfolgreichem Handshake (mit Adresse des Clients)
+pub fn accept(listener: TcpListener, config: Arc<ServerConfig>) -> mpsc::Receiver<(TlsStream<TcpStream>, SocketAddr)> {
+    let (sender, receiver) = mpsc::channel(PENDING_CONNECTIONS);
+    let acceptor = TlsAcceptor::from(config);
+    tokio::spawn(async move {
+        while !sender.is_closed() {
+            // Nach einem Upgrade nimmt der Nachfolger die Verbindungen an (siehe handoff.rs)
+            let accepted = tokio::select! {
+                accepted = listener.accept() => accepted,
+                _ = handoff::handed_over() => break,
+            };
+            let (stream, peer) = match accepted {
+                Ok(connection) => connection,
+                Err(e) => {
+                    debug!("🔒 Accept failed: {}", e);
+                    sleep(ACCEPT_ERROR_BACKOFF).await;
+                    continue;
+                }
+            };
+            let acceptor = acceptor.clone();
+            let sender = sender.clone();
+            tokio::spawn(async move {
+                match timeout(HANDSHAKE_TIMEOUT, acceptor.accept(stream)).await {
+                    Ok(Ok(stream)) => {
+                        let _ = sender.send((stream, peer)).await;
+                    }
+                    Ok(Err(e)) => debug!("🔒 TLS handshake with {} failed: {}", peer, e),
+                    Err(_) => debug!("🔒 TLS handshake with {} timed out", peer),
+                }
+            });
+        }
+    });
+    receiver
+}
+
+/// Listener für axum::serve, liefert fertige TLS-Verbindungen (ConnectInfo bleibt die Adresse des Clients)
+pub struct TlsListener {
+    connections: mpsc::Receiver<(TlsStream<TcpStream>, SocketAddr)>,
+    local_addr: SocketAddr,
+}
+
+impl TlsListener {
+    pub fn new(listener: TcpListener, config: Arc<ServerConfig>) -> io::Result<Self> {
+        let local_addr = listener.local_addr()?;
+        Ok(Self { connections: accept(listener, config), local_addr })
+    }
+}
+
+impl axum::serve::Listener for TlsListener {
+    type Io = TlsStream<TcpStream>;
+    type Addr = SocketAddr;
+
+    async fn accept(&mut self) -> (Self::Io, Self::Addr) {
+        match self.connections.recv().await {
+            Some(connection) => connection,
+            None => std::future::pending().await,
+        }
+    }
+
+    fn local_addr(&self) -> io::Result<Self::Addr> {
+        Ok(self.local_addr)
+    }
+}
@@ -0,0 +1,392 @@
+// txdecode.rs - Minimaler Decoder für EIP-2718-Transaktionen (Absender und Empfänger)
+//
+// Unterstützt Legacy (mit/ohne EIP-155), EIP-2930 (0x01), EIP-1559 (0x02), EIP-4844 (0x03),
+// EIP-7702 (0x04) und OP-Deposits (0x7e, Absender steht direkt in der Transaktion).
+// Der Absender wird aus der Signatur über den Signing-Hash wiederhergestellt. Für die
+// Signing-Daten werden die unsignierten Felder aus der Originalkodierung übernommen
+// (sie liegen zusammenhängend vor den Feldern v, r, s) und neu mit einem Listen-Header versehen.
+// Außerdem wird die L1-Herkunft aus der L1-Attributes-Deposit-Transaktion gelesen (`l1_origin`) und
+// eine Transaktion im Format von eth_getTransactionByHash dargestellt (`render_transaction`, für rpc.rs).
+
+use alloy::primitives::{keccak256, Address, PrimitiveSignature, U256};
+
+const TX_TYPE_DEPOSIT: u8 = 0x7e;
+
+/// Absender und Empfänger einer Transaktion (`to` = None bei Contract-Erzeugung)
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct TxAddresses {
+    pub from: [u8; 20],
+    pub to: Option<[u8; 20]>,
+}
+
+/// Ein RLP-Item als Slice der Originaldaten
+#[derive(Clone, Copy)]
+struct RlpItem<'a> {
+    raw: &'a [u8],     // Header + Inhalt
+    payload: &'a [u8], // Inhalt ohne Header
+    is_list: bool,
+}
+
+/// Dekodiert ein RLP-Item am Anfang von `data`
+fn rlp_item(data: &[u8]) -> Result<RlpItem<'_>, Box<dyn std::error::Error + Send + Sync>> {
+    let first = *data.first().ok_or("Empty RLP item")?;
+    let (header, len, is_list) = match first {
+        0x00..=0x7f => return Ok(RlpItem { raw: &data[..1], payload: &data[..1], is_list: false }),
+        0x80..=0xb7 => (1, (first - 0x80) as usize, false),
+        0xb8..=0xbf => {
+            let n = (first - 0xb7) as usize;
+            (1 + n, be_len(data.get(1..1 + n).ok_or("Truncated RLP length")?)?, false)
+        }
+        0xc0..=0xf7 => (1, (first - 0xc0) as usize, true),
+        0xf8..=0xff => {
+            let n = (first - 0xf7) as usize;
+            (1 + n, be_len(data.get(1..1 + n).ok_or("Truncated RLP length")?)?, true)
+        }
+    };
+    let end = header.checked_add(len).filter(|end| *end <= data.len()).ok_or("Truncated RLP item")?;
+    Ok(RlpItem {
+        raw: &data[..end],
+        payload: &data[header..end],
+        is_list,
+    })
+}
+
+fn be_len(bytes: &[u8]) -> Result<usize, Box<dyn std::error::Error + Send + Sync>> {
+    if bytes.len() > 8 {
+        return Err("RLP length too large".into());
+    }
+    Ok(bytes.iter().fold(0usize, |acc, b| (acc << 8) | *b as usize))
+}
+
+/// Alle Elemente einer RLP-Liste
+fn rlp_list(data: &[u8]) -> Result<Vec<RlpItem<'_>>, Box<dyn std::error::Error + Send + Sync>> {
+    let list = rlp_item(data)?;
+    if !list.is_list {
+        return Err("Expected RLP list".into());
+    }
+    let mut items = Vec::new();
+    let mut rest = list.payload;
+    while !rest.is_empty() {
+        let item = rlp_item(rest)?;
+        rest = &rest[item.raw.len()..];
+        items.push(item);
+    }
+    Ok(items)
+}
+
+/// RLP-Listen-Header für einen Inhalt der Länge `len`
+fn list_header(len: usize) -> Vec<u8> {
+    if len < 56 {
+        return vec![0xc0 + len as u8];
+    }
+    let bytes = (len as u64).to_be_bytes();
+    let skip = bytes.iter().take_while(|b| **b == 0).count();
+    let mut header = vec![0xf7 + (8 - skip) as u8];
+    header.extend_from_slice(&bytes[skip..]);
+    header
+}
+
+/// RLP-Kodierung einer Zahl
+fn encode_u64(value: u64) -> Vec<u8> {
+    match value {
+        0 => vec![0x80],
+        1..=0x7f => vec![value as u8],
+        _ => {
+            let bytes = value.to_be_bytes();
+            let skip = bytes.iter().take_while(|b| **b == 0).count();
+            let mut out = vec![0x80 + (8 - skip) as u8];
+            out.extend_from_slice(&bytes[skip..]);
+            out
+        }
+    }
+}
+
+fn item_u64(item: &RlpItem) -> Result<u64, Box<dyn std::error::Error + Send + Sync>> {
+    if item.is_list {
+        return Err("Expected RLP integer".into());
+    }
+    // Einzelbyte < 0x80 ist sein eigener Inhalt
+    be_len(item.payload).map(|v| v as u64)
+}
+
+fn item_address(item: &RlpItem) -> Result<Option<[u8; 20]>, Box<dyn std::error::Error + Send + Sync>> {
+    match item.payload.len() {
+        0 => Ok(None),
+        20 => Ok(Some(item.payload.try_into()?)),
+        n => Err(format!("Invalid address length {}", n).into()),
+    }
+}
+
+/// Stellt den Absender aus Signing-Daten und (y, r, s) wieder her
+fn recover(signing_data: &[u8], y: bool, r: &RlpItem, s: &RlpItem) -> Result<[u8; 20], Box<dyn std::error::Error + Send + Sync>> {
+    let signature = PrimitiveSignature::new(U256::from_be_slice(r.payload), U256::from_be_slice(s.payload), y);
+    let address: Address = signature.recover_address_from_prehash(&keccak256(signing_data))?;
+    Ok(address.as_slice().try_into()?)
+}
+
+/// Absender und Empfänger einer EIP-2718-kodierten Transaktion
+pub fn transaction_addresses(tx: &[u8]) -> Result<TxAddresses, Box<dyn std::error::Error + Send + Sync>> {
+    let tx_type = *tx.first().ok_or("Empty transaction")?;
+
+    // Legacy: RLP-Liste [nonce, gas_price, gas, to, value, data, v, r, s]
+    if tx_type >= 0xc0 {
+        let fields = rlp_list(tx)?;
+        if fields.len() != 9 {
+            return Err(format!("Legacy transaction with {} fields", fields.len()).into());
+        }
+        let v = item_u64(&fields[6])?;
+        let unsigned: Vec<u8> = fields[..6].iter().flat_map(|f| f.raw.iter().copied()).collect();
+        let (mut body, y) = match v {
+            27 | 28 => (unsigned, v == 28),
+            v if v >= 35 => {
+                // EIP-155: [.., chain_id, 0, 0]
+                let mut body = unsigned;
+                body.extend(encode_u64((v - 35) / 2));
+                body.extend([0x80, 0x80]);
+                (body, (v - 35) % 2 == 1)
+            }
+            v => return Err(format!("Invalid legacy v {}", v).into()),
+        };
+        let mut signing_data = list_header(body.len());
+        signing_data.append(&mut body);
+        return Ok(TxAddresses {
+            from: recover(&signing_data, y, &fields[7], &fields[8])?,
+            to: item_address(&fields[3])?,
+        });
+    }
+
+    let fields = rlp_list(&tx[1..])?;
+    if tx_type == TX_TYPE_DEPOSIT {
+        // [source_hash, from, to, mint, value, gas, is_system_tx, data]
+        if fields.len() < 3 {
+            return Err("Deposit transaction too short".into());
+        }
+        return Ok(TxAddresses {
+            from: item_address(&fields[1])?.ok_or("Deposit without sender")?,
+            to: item_address(&fields[2])?,
+        });
+    }
+
+    // Position von `to` und Anzahl Felder inkl. y_parity, r, s
+    let (to_index, field_count) = match tx_type {
+        0x01 => (4, 11), // chain_id, nonce, gas_price, gas, to, value, data, access_list, y, r, s
+        0x02 => (5, 12), // chain_id, nonce, max_priority_fee, max_fee, gas, to, value, data, access_list, y, r, s
+        0x03 => (5, 14), // wie 0x02 + max_fee_per_blob_gas, blob_versioned_hashes
+        0x04 => (5, 13), // wie 0x02 + authorization_list
+        t => return Err(format!("Unsupported transaction type 0x{:02x}", t).into()),
+    };
+    if fields.len() != field_count {
+        return Err(format!("Transaction type 0x{:02x} with {} fields", tx_type, fields.len()).into());
+    }
+
+    let unsigned = &fields[..field_count - 3];
+    let body_len: usize = unsigned.iter().map(|f| f.raw.len()).sum();
+    let mut signing_data = vec![tx_type];
+    signing_data.extend(list_header(body_len));
+    for field in unsigned {
+        signing_data.extend_from_slice(field.raw);
+    }
+
+    let y = item_u64(&fields[field_count - 3])? == 1;
+    Ok(TxAddresses {
+        from: recover(&signing_data, y, &fields[field_count - 2], &fields[field_count - 1])?,
+        to: item_address(&fields[to_index])?,
+    })
+}
+
+/// RLP-Integer (big endian) als Hex-Quantity
+fn item_quantity(item: &RlpItem) -> Result<String, Box<dyn std::error::Error + Send + Sync>> {
+    if item.is_list {
+        return Err("Expected RLP integer".into());
+    }
+    let digits = hex::encode(item.payload);
+    let trimmed = digits.trim_start_matches('0');
+    Ok(format!("0x{}", if trimmed.is_empty() { "0" } else { trimmed }))
+}
+
+fn item_u256(item: &RlpItem) -> Result<U256, Box<dyn std::error::Error + Send + Sync>> {
+    if item.is_list || item.payload.len() > 32 {
+        return Err("Expected RLP uint256".into());
+    }
+    Ok(U256::from_be_slice(item.payload))
+}
+
+fn item_data(item: &RlpItem) -> String {
+    format!("0x{}", hex::encode(item.payload))
+}
+
+/// EIP-2930 Access-List: [[address, [storage_key, ...]], ...]
+fn render_access_list(item: &RlpItem) -> Result<serde_json::Value, Box<dyn std::error::Error + Send + Sync>> {
+    let mut entries = Vec::new();
+    for entry in rlp_list(item.raw)? {
+        let fields = rlp_list(entry.raw)?;
+        if fields.len() != 2 {
+            return Err(format!("Access list entry with {} fields", fields.len()).into());
+        }
+        let keys: Vec<String> = rlp_list(fields[1].raw)?.iter().map(item_data).collect();
+        entries.push(serde_json::json!({ "address": item_data(&fields[0]), "storageKeys": keys }));
+    }
+    Ok(serde_json::Value::Array(entries))
+}
+
+/// EIP-7702 Authorization-List: [[chain_id, address, nonce, y_parity, r, s], ...]
+fn render_authorization_list(item: &RlpItem) -> Result<serde_json::Value, Box<dyn std::error::Error + Send + Sync>> {
+    let mut entries = Vec::new();
+    for entry in rlp_list(item.raw)? {
+        let fields = rlp_list(entry.raw)?;
+        if fields.len() != 6 {
+            return Err(format!("Authorization with {} fields", fields.len()).into());
+        }
+        entries.push(serde_json::json!({
+            "chainId": item_quantity(&fields[0])?,
+            "address": item_data(&fields[1]),
+            "nonce": item_quantity(&fields[2])?,
+            "yParity": item_quantity(&fields[3])?,
+            "r": item_quantity(&fields[4])?,
+            "s": item_quantity(&fields[5])?,
+        }));
+    }
+    Ok(serde_json::Value::Array(entries))
+}
+
+/// Transaktion im Format von eth_getTransactionByHash, ohne Block-Kontext (blockHash, blockNumber,
+/// transactionIndex setzt der Aufrufer). `base_fee` bestimmt gasPrice bei EIP-1559-Transaktionen.
+pub fn render_transaction(tx: &[u8], base_fee: Option<U256>) -> Result<serde_json::Value, Box<dyn std::error::Error + Send + Sync>> {
+    // Prüft zugleich Typ und Feldanzahl
+    let addresses = transaction_addresses(tx)?;
+    let mut rendered = serde_json::json!({
+        "hash": format!("0x{}", hex::encode(keccak256(tx))),
+        "from": format!("0x{}", hex::encode(addresses.from)),
+        "to": addresses.to.map(|to| format!("0x{}", hex::encode(to))),
+    });
+
+    let tx_type = tx[0];
+    if tx_type >= 0xc0 {
+        // [nonce, gas_price, gas, to, value, data, v, r, s]
+        let fields = rlp_list(tx)?;
+        let v = item_u64(&fields[6])?;
+        rendered["type"] = "0x0".into();
+        rendered["nonce"] = item_quantity(&fields[0])?.into();
+        rendered["gasPrice"] = item_quantity(&fields[1])?.into();
+        rendered["gas"] = item_quantity(&fields[2])?.into();
+        rendered["value"] = item_quantity(&fields[4])?.into();
+        rendered["input"] = item_data(&fields[5]).into();
+        rendered["v"] = item_quantity(&fields[6])?.into();
+        rendered["r"] = item_quantity(&fields[7])?.into();
+        rendered["s"] = item_quantity(&fields[8])?.into();
+        if v >= 35 {
+            rendered["chainId"] = format!("0x{:x}", (v - 35) / 2).into();
+        }
+        return Ok(rendered);
+    }
+
+    let fields = rlp_list(&tx[1..])?;
+    rendered["type"] = format!("0x{:x}", tx_type).into();
+    if tx_type == TX_TYPE_DEPOSIT {
+        // [source_hash, from, to, mint, value, gas, is_system_tx, data]; keine Signatur, Nonce erst im Receipt
+        if fields.len() < 7 {
+            return Err(format!("Deposit transaction with {} fields", fields.len()).into());
+        }
+        rendered["sourceHash"] = item_data(&fields[0]).into();
+        rendered["mint"] = item_quantity(&fields[3])?.into();
+        rendered["value"] = item_quantity(&fields[4])?.into();
+        rendered["gas"] = item_quantity(&fields[5])?.into();
+        rendered["isSystemTx"] = (fields[6].payload == [1]).into();
+        rendered["input"] = fields.get(7).map(item_data).unwrap_or_else(|| "0x".to_string()).into();
+        rendered["nonce"] = "0x0".into();
+        rendered["gasPrice"] = "0x0".into();
+        for field in ["v", "r", "s"] {
+            rendered[field] = "0x0".into();
+        }
+        return Ok(rendered);
+    }
+
+    let count = fields.len();
+    rendered["chainId"] = item_quantity(&fields[0])?.into();
+    rendered["nonce"] = item_quantity(&fields[1])?.into();
+    if tx_type == 0x01 {
+        // chain_id, nonce, gas_price, gas, to, value, data, access_list, y, r, s
+        rendered["gasPrice"] = item_quantity(&fields[2])?.into();
+        rendered["gas"] = item_quantity(&fields[3])?.into();
+        rendered["value"] = item_quantity(&fields[5])?.into();
+        rendered["input"] = item_data(&fields[6]).into();
+        rendered["accessList"] = render_access_list(&fields[7])?;
+    } else {
+        // chain_id, nonce, max_priority_fee, max_fee, gas, to, value, data, access_list, ...
+        let tip = item_u256(&fields[2])?;
+        let max_fee = item_u256(&fields[3])?;
+        let gas_price = base_fee.map_or(max_fee, |base_fee| max_fee.min(base_fee.saturating_add(tip)));
+        rendered["maxPriorityFeePerGas"] = format!("0x{:x}", tip).into();
+        rendered["maxFeePerGas"] = format!("0x{:x}", max_fee).into();
+        rendered["gasPrice"] = format!("0x{:x}", gas_price).into();
+        rendered["gas"] = item_quantity(&fields[4])?.into();
+        rendered["value"] = item_quantity(&fields[6])?.into();
+        rendered["input"] = item_data(&fields[7]).into();
+        rendered["accessList"] = render_access_list(&fields[8])?;
+        if tx_type == 0x03 {
+            rendered["maxFeePerBlobGas"] = item_quantity(&fields[9])?.into();
+            let hashes: Vec<String> = rlp_list(fields[10].raw)?.iter().map(item_data).collect();
+            rendered["blobVersionedHashes"] = hashes.into();
+        }
+        if tx_type == 0x04 {
+            rendered["authorizationList"] = render_authorization_list(&fields[9])?;
+        }
+    }
+    rendered["yParity"] = item_quantity(&fields[count - 3])?.into();
+    rendered["v"] = item_quantity(&fields[count - 3])?.into();
+    rendered["r"] = item_quantity(&fields[count - 2])?.into();
+    rendered["s"] = item_quantity(&fields[count - 1])?.into();
+    Ok(rendered)
+}
+
+/// L1-Herkunft eines L2-Blocks aus der L1-Attributes-Deposit-Transaktion (erste Transaktion jedes Blocks)
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct L1Origin {
+    pub number: u64,
+    pub hash: [u8; 32],
+    pub timestamp: u64,
+    pub sequence_number: u64,
+}
+
+/// setL1BlockValues(uint64,uint64,uint256,bytes32,uint64,bytes32,uint256,uint256) - Bedrock, ABI-kodiert
+const SET_L1_BLOCK_VALUES_BEDROCK: [u8; 4] = [0x01, 0x5d, 0x8e, 0xb9];
+/// setL1BlockValuesEcotone() - gepackt: baseFeeScalar(4) blobBaseFeeScalar(4) sequenceNumber(8)
+/// timestamp(8) number(8) basefee(32) blobBaseFee(32) hash(32) batcherHash(32)
+const SET_L1_BLOCK_VALUES_ECOTONE: [u8; 4] = [0x44, 0x0a, 0x5e, 0x20];
+/// setL1BlockValuesIsthmus() - wie Ecotone + operatorFeeScalar(4) operatorFeeConstant(8)
+const SET_L1_BLOCK_VALUES_ISTHMUS: [u8; 4] = [0x09, 0x89, 0x99, 0xbe];
+
+fn be_u64(bytes: &[u8]) -> u64 {
+    u64::from_be_bytes(bytes.try_into().unwrap())
+}
+
+/// Liest die L1-Herkunft aus einer L1-Attributes-Deposit-Transaktion
+pub fn l1_origin(tx: &[u8]) -> Result<L1Origin, Box<dyn std::error::Error + Send + Sync>> {
+    if tx.first() != Some(&TX_TYPE_DEPOSIT) {
+        return Err("First transaction is not a deposit".into());
+    }
+    // [source_hash, from, to, mint, value, gas, is_system_tx, data]
+    let fields = rlp_list(&tx[1..])?;
+    let data = fields.get(7).ok_or("Deposit transaction without data")?.payload;
+    let (selector, args) = data.split_at(4.min(data.len()));
+
+    match selector {
+        s if s == SET_L1_BLOCK_VALUES_BEDROCK && args.len() >= 8 * 32 => {
+            // 32-Byte-Wörter: number, timestamp, basefee, hash, sequenceNumber, ...
+            let word = |i: usize| &args[i * 32..(i + 1) * 32];
+            Ok(L1Origin {
+                number: be_u64(&word(0)[24..]),
+                timestamp: be_u64(&word(1)[24..]),
+                hash: word(3).try_into()?,
+                sequence_number: be_u64(&word(4)[24..]),
+            })
+        }
+        s if (s == SET_L1_BLOCK_VALUES_ECOTONE || s == SET_L1_BLOCK_VALUES_ISTHMUS) && args.len() >= 160 => Ok(L1Origin {
+            sequence_number: be_u64(&args[8..16]),
+            timestamp: be_u64(&args[16..24]),
+            number: be_u64(&args[24..32]),
+            hash: args[96..128].try_into()?,
+        }),
+        s => Err(format!("Unknown L1 attributes call 0x{} ({} bytes)", hex::encode(s), data.len()).into()),
+    }
+}
@@ -0,0 +1,148 @@
+// txindex.rs - Transaktions-Hash-Index über die gespeicherten Preconfs
+//
+// Aus jedem Payload wird die Transaktionsliste dekodiert und txhash -> (Block, Index) gemerkt,
+// damit nachgelagerte Komponenten eth_getTransactionByHash / Receipt-Lookups für
+// vorbestätigte Transaktionen beantworten können, bevor sie in kanonischen Blöcken stehen.
+// Der Index umfasst dieselben Blöcke wie der Block-Index und wird beim Start aus den Dateien aufgebaut.
+// Optional (KONA_BRIDGE_ADDRESS_INDEX) werden zusätzlich Absender und Empfänger jeder Transaktion
+// extrahiert (adresse -> Transaktionen), damit Wallets fragen können, ob ihre Adresse in einem
+// Preconf vorkommt. Pro Block wird dazu ein 2048-Bit-Bloom der Adressen in die Metadaten geschrieben.
+
+use crate::{profile, ssz::decode_envelope, txdecode::transaction_addresses};
+use alloy::primitives::keccak256;
+use std::collections::{BTreeMap, HashMap};
+use tracing::debug;
+
+/// Position einer Transaktion
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct TxLocation {
+    pub block_number: u64,
+    pub tx_index: u32,
+}
+
+/// Dekodiert die Transaktionsliste (EIP-2718-kodierte Transaktionen) aus einem Preconf-Payload
+pub fn payload_transactions(data: &[u8]) -> Result<Vec<&[u8]>, Box<dyn std::error::Error + Send + Sync>> {
+    Ok(decode_envelope(data)?.payload.transactions)
+}
+
+/// Maximal gelieferte Transaktionen pro Adresse (die neuesten zuerst)
+pub const MAX_ADDRESS_RESULTS: usize = 256;
+
+/// Größe des Adress-Blooms pro Block (wie logsBloom)
+pub const BLOOM_SIZE: usize = 256;
+
+/// Eine Transaktion mit Hash (keccak256 der EIP-2718-Kodierung) und berührten Adressen
+#[derive(Debug, Clone)]
+pub struct IndexedTx {
+    pub hash: [u8; 32],
+    pub addresses: Vec<[u8; 20]>, // Absender und Empfänger (leer ohne Adress-Index)
+}
+
+/// Dekodiert die Transaktionen eines Payloads, mit `with_addresses` inkl. Absender/Empfänger.
+/// Nicht dekodierbare Transaktionen bleiben ohne Adressen im Index.
+pub fn index_transactions(data: &[u8], with_addresses: bool) -> Result<Vec<IndexedTx>, Box<dyn std::error::Error + Send + Sync>> {
+    Ok(payload_transactions(data)?
+        .into_iter()
+        .map(|tx| {
+            let hash = keccak256(tx).0;
+            let mut addresses = Vec::new();
+            if with_addresses {
+                match transaction_addresses(tx) {
+                    Ok(tx_addresses) => {
+                        addresses.push(tx_addresses.from);
+                        if let Some(to) = tx_addresses.to.filter(|to| *to != tx_addresses.from) {
+                            addresses.push(to);
+                        }
+                    }
+                    Err(e) => debug!("No addresses for tx 0x{}: {}", hex::encode(hash), e),
+                }
+            }
+            IndexedTx { hash, addresses }
+        })
+        .collect())
+}
+
+/// 2048-Bit-Bloom über alle Adressen eines Blocks (3 x 11 Bit aus keccak256(adresse), wie logsBloom)
+pub fn address_bloom(txs: &[IndexedTx]) -> [u8; BLOOM_SIZE] {
+    let mut bloom = [0u8; BLOOM_SIZE];
+    for address in txs.iter().flat_map(|tx| tx.addresses.iter()) {
+        let hash = keccak256(address);
+        for i in 0..3 {
+            let bit = (u16::from_be_bytes([hash[2 * i], hash[2 * i + 1]]) & 0x7ff) as usize;
+            bloom[BLOOM_SIZE - 1 - bit / 8] |= 1 << (bit % 8);
+        }
+    }
+    bloom
+}
+
+/// txhash -> (Block, Index) und adresse -> [(Block, Index)] für die letzten index_capacity Blöcke (profile.rs)
+#[derive(Default)]
+pub struct TxIndex {
+    by_hash: HashMap<[u8; 32], TxLocation>,
+    by_address: HashMap<[u8; 20], Vec<TxLocation>>,
+    by_block: BTreeMap<u64, Vec<IndexedTx>>,
+}
+
+impl TxIndex {
+    /// Setzt die Transaktionen eines Blocks (ersetzt einen früheren Stand desselben Blocks)
+    pub fn insert_block(&mut self, block_number: u64, txs: Vec<IndexedTx>) {
+        self.remove_block(block_number);
+        for (tx_index, tx) in txs.iter().enumerate() {
+            let location = TxLocation {
+                block_number,
+                tx_index: tx_index as u32,
+            };
+            self.by_hash.insert(tx.hash, location);
+            for address in &tx.addresses {
+                self.by_address.entry(*address).or_default().push(location);
+            }
+        }
+        self.by_block.insert(block_number, txs);
+
+        while self.by_block.len() > profile::current().index_capacity {
+            if let Some((&oldest, _)) = self.by_block.first_key_value() {
+                self.remove_block(oldest);
+            }
+        }
+    }
+
+    pub fn contains_block(&self, block_number: u64) -> bool {
+        self.by_block.contains_key(&block_number)
+    }
+
+    pub fn get(&self, tx_hash: &[u8; 32]) -> Option<TxLocation> {
+        self.by_hash.get(tx_hash).copied()
+    }
+
+    /// Transaktionen, die `address` als Absender oder Empfänger berühren (neueste zuerst)
+    pub fn by_address(&self, address: &[u8; 20]) -> Vec<TxLocation> {
+        let Some(locations) = self.by_address.get(address) else {
+            return Vec::new();
+        };
+        let mut locations = locations.clone();
+        locations.sort_unstable_by(|a, b| (b.block_number, b.tx_index).cmp(&(a.block_number, a.tx_index)));
+        locations.dedup();
+        locations.truncate(MAX_ADDRESS_RESULTS);
+        locations
+    }
+
+    fn remove_block(&mut self, block_number: u64) {
+        let Some(txs) = self.by_block.remove(&block_number) else {
+            return;
+        };
+        for tx in txs {
+            // Nur entfernen, wenn der Hash nicht inzwischen auf einen anderen Block zeigt (Reorg)
+            if self.by_hash.get(&tx.hash).map_or(false, |loc| loc.block_number == block_number) {
+                self.by_hash.remove(&tx.hash);
+            }
+            for address in tx.addresses {
+                if let Some(locations) = self.by_address.get_mut(&address) {
+                    locations.retain(|loc| loc.block_number != block_number);
+                    if locations.is_empty() {
+                        self.by_address.remove(&address);
+                    }
+                }
+            }
+        }
+    }
+}
@@ -1,5 +1,6 @@
 // types.rs - Datenstrukturen und Enums für die Kona-Bridge
 
+use crate::stats::{StorageStats, HISTOGRAM_BUCKETS};
 use std::{
     collections::HashSet,
     os::raw::{c_char, c_uint},
@@ -59,9 +60,10 @@ pub struct BlockBitmaskTracker {
 
 impl BlockDeduplicator {
     pub fn new() -> Self {
+        let max_size = crate::profile::current().dedup_blocks; // 200, mit Speicherbudget weniger
         Self {
-            processed_blocks: HashSet::with_capacity(200), // Pre-allocate for 200 blocks
-            max_size: 200, // Reduziert von 1000 auf 200 für Memory-Effizienz
+            processed_blocks: HashSet::with_capacity(max_size),
+            max_size,
         }
     }
 
@@ -83,6 +85,13 @@ impl BlockDeduplicator {
             self.processed_blocks = blocks[keep_from..].iter().cloned().collect();
         }
     }
+
+    /// Übernimmt die zuletzt verarbeiteten Blöcke aus dem Checkpoint (siehe checkpoint.rs)
+    pub fn resume(&mut self, blocks: &[u64]) {
+        for block_number in blocks {
+            self.mark_processed(*block_number);
+        }
+    }
 }
 
 impl BlockBitmaskTracker {
@@ -180,6 +189,7 @@ pub struct KonaBridgeHandle {
     pub config: KonaBridgeConfig,
     pub runtime: Option<Runtime>,
     pub stats: Arc<Mutex<KonaBridgeStats>>,
+    pub storage_stats: Arc<StorageStats>,
     pub running: Arc<Mutex<bool>>,
     pub thread_handle: Option<thread::JoinHandle<()>>,
     pub deduplicator: Arc<Mutex<BlockDeduplicator>>, // Race-Condition-Schutz
@@ -188,6 +198,7 @@ pub struct KonaBridgeHandle {
 
 /// Statistiken der Bridge
 #[repr(C)]
+#[derive(Default)]
 pub struct KonaBridgeStats {
     pub connected_peers: c_uint,
     pub received_preconfs: c_uint,
@@ -204,3 +215,45 @@ pub struct KonaBridgeStats {
     pub gossip_gaps: c_uint,  // Verpasste Blöcke während Gossip-Modus
     pub bitmask_gaps: c_uint, // Präzise Gaps via Bitmask-Tracking
 }
+
+/// Speicher-Statistiken des Preconf-Stores (siehe stats.rs)
+#[repr(C)]
+pub struct KonaBridgeStorageStats {
+    pub entries: u64,          // Gespeicherte Blöcke
+    pub total_bytes: u64,      // Belegter Platz inkl. Metadaten und Blobs
+    pub oldest_block: u64,
+    pub newest_block: u64,
+    pub oldest_age_secs: u64,
+    pub newest_age_secs: u64,
+    pub stored_http: u64,      // Seit Start gespeichert, je Quelle
+    pub stored_gossip: u64,
+    pub stored_other: u64,
+    pub evicted_ttl: u64,      // Seit Start gelöschte Blöcke, je Grund
+    pub evicted_quota: u64,
+    pub evicted_finality: u64,
+    pub invalid_signatures: u64, // Seit Start abgelehnte Signer
+    pub equivocations: u64,      // Seit Start erkannte Sequencer-Equivocations
+    pub oversized_payloads: u64, // Seit Start verworfene Payloads über der Größengrenze
+    pub gossip_dropped: u64,     // Seit Start verworfene Gossip-Nachrichten (Backpressure)
+    pub sampled_out: u64,        // Seit Start geprüfte, per Sampling nicht gespeicherte Blöcke
+    pub reorgs: u64,             // Seit Start ersetzte Blöcke (gleiche Höhe, anderer Hash)
+    pub gaps: u64,               // Seit Start erkannte Lücken in der Blockfolge
+    pub backwards: u64,          // Seit Start erkannte Rücksprünge in der Blockfolge
+    pub dns_failures: u64,       // Seit Start gescheiterte DNS-Auflösungen des HTTP-Endpoints
+    pub canonical_matched: u64,       // Seit Start mit dem kanonischen Block übereinstimmende Preconfs
+    pub canonical_mismatched: u64,    // Seit Start abweichende Preconfs (anderer Block-Hash)
+    pub canonical_tx_mismatched: u64, // Davon mit abweichender Menge an Transaktionen
+}
+
+/// Histogramme der gespeicherten Preconfs, Buckets kumulativ wie bei Prometheus (siehe stats.rs)
+#[repr(C)]
+pub struct KonaBridgeHistograms {
+    pub interval_bounds_ms: [u64; HISTOGRAM_BUCKETS], // Obergrenzen (le) der Ankunftsabstände
+    pub interval_buckets: [u64; HISTOGRAM_BUCKETS],   // Anzahl <= interval_bounds_ms[i]
+    pub interval_count: u64,                          // alle Abstände (+Inf)
+    pub interval_sum_ms: u64,
+    pub size_bounds_bytes: [u64; HISTOGRAM_BUCKETS],  // Obergrenzen (le) der Payload-Größen
+    pub size_buckets: [u64; HISTOGRAM_BUCKETS],
+    pub size_count: u64,
+    pub size_sum_bytes: u64,
+}
@@ -1,116 +1,118 @@
 // utils.rs - Hilfsfunktionen für die Kona-Bridge
 
+use crate::{
+    blobs::{gc_unreferenced_blobs, BLOB_DIR_NAME},
+    c4::C4_SUFFIX,
+    checkpoint::CHECKPOINT_FILE_NAME,
+    codec::PreconfContainer,
+    delta,
+    durability::DurableFs,
+    index::INDEX_FILE_NAME,
+    pointer::{LatestPointer, LATEST_FILE_NAME, LATEST_JSON_FILE_NAME},
+    reload::RuntimeConfig,
+    render::RENDERED_SUFFIX,
+    reorg::SUPERSEDED_DIR_NAME,
+    retention::{prune_finalized, FinalityRetention},
+    signer::QUARANTINE_DIR_NAME,
+    signerhistory::SIGNER_HISTORY_FILE_NAME,
+    ssz::{decode_envelope, PayloadEnvelope, PayloadVersion, ENVELOPE_PREFIX_SIZE},
+    stats::{Eviction, StorageStats},
+    status::STATUS_FILE_NAME,
+};
 use std::{
-    path::PathBuf,
-    sync::{Arc, Mutex},
-    time::{Duration, SystemTime},
+    collections::{BTreeMap, HashMap, HashSet},
+    io,
+    path::{Path, PathBuf},
+    sync::{
+        atomic::{AtomicBool, Ordering},
+        Arc, Mutex,
+    },
+    time::{Duration, SystemTime, UNIX_EPOCH},
 };
-use tokio::{fs as tokio_fs, time::interval};
-use tracing::{info, warn};
-
-/// Extract block number from raw preconf data using fixed offsets
-/// Struktur: 32 bytes previousBlockHash + ExecutionPayload
-/// ExecutionPayload Offsets (wie in beacon_denep.c):
-/// - parentHash: 32 bytes
-/// - feeRecipient: 20 bytes  
-/// - stateRoot: 32 bytes
-/// - receiptsRoot: 32 bytes
-/// - logsBloom: 256 bytes
-/// - prevRandao: 32 bytes
-/// - blockNumber: 8 bytes (little-endian) <- das wollen wir
-pub fn extract_block_number_from_preconf_data(data: &[u8]) -> Result<u64, Box<dyn std::error::Error + Send + Sync>> {
-    // Berechne Offset für blockNumber
-    let previous_block_hash_offset = 32;
-    let parent_hash_offset = 32;
-    let fee_recipient_offset = 20;
-    let state_root_offset = 32;
-    let receipts_root_offset = 32;
-    let logs_bloom_offset = 256;
-    let prev_randao_offset = 32;
-    
-    let block_number_offset = previous_block_hash_offset 
-        + parent_hash_offset 
-        + fee_recipient_offset 
-        + state_root_offset 
-        + receipts_root_offset 
-        + logs_bloom_offset 
-        + prev_randao_offset;
-    
-    if data.len() < block_number_offset + 8 {
-        return Err("Data too short to contain block number".into());
+use tokio::{
+    fs as tokio_fs,
+    sync::{watch, Notify},
+    time::interval,
+};
+use tracing::{debug, info, warn};
+
+/// Fester Offset von blockNumber in den Preconf-Daten (Fallback, wenn das SSZ-Decoding scheitert):
+/// 32 bytes parent_beacon_block_root + parentHash(32) + feeRecipient(20) + stateRoot(32)
+/// + receiptsRoot(32) + logsBloom(256) + prevRandao(32)
+const FALLBACK_BLOCK_NUMBER_OFFSET: usize = 436;
+/// Danach blockNumber, gasLimit, gasUsed (je 8 bytes) -> timestamp
+const FALLBACK_TIMESTAMP_OFFSET: usize = FALLBACK_BLOCK_NUMBER_OFFSET + 24;
+/// Danach timestamp (8), extraData-Offset (4), baseFeePerGas (32) -> blockHash
+const FALLBACK_BLOCK_HASH_OFFSET: usize = FALLBACK_TIMESTAMP_OFFSET + 8 + 4 + 32;
+
+/// Liest ein Feld über den festen Offset - nur wenn der extraData-Offset einem bekannten Layout
+/// (v1-v4, die Felder bis blockHash liegen überall gleich) entspricht und dessen fester Teil vorhanden ist
+fn fallback_field(data: &[u8], offset: usize, len: usize) -> Result<&[u8], Box<dyn std::error::Error + Send + Sync>> {
+    let extra_data_offset = FALLBACK_TIMESTAMP_OFFSET + 8;
+    let layout_ok = data.len() >= FALLBACK_BLOCK_HASH_OFFSET + 32
+        && PayloadVersion::from_fixed_size(u32::from_le_bytes(data[extra_data_offset..extra_data_offset + 4].try_into()?) as usize)
+            .map_or(false, |version| data.len() >= ENVELOPE_PREFIX_SIZE + version.fixed_size());
+    if !layout_ok {
+        return Err("Preconf data does not match the expected payload layout".into());
     }
-    
-    // Extrahiere 8 Bytes als little-endian u64
-    let block_number_bytes = &data[block_number_offset..block_number_offset + 8];
-    let block_number = u64::from_le_bytes([
-        block_number_bytes[0], block_number_bytes[1], block_number_bytes[2], block_number_bytes[3],
-        block_number_bytes[4], block_number_bytes[5], block_number_bytes[6], block_number_bytes[7],
-    ]);
-    
-    Ok(block_number)
+    Ok(&data[offset..offset + len])
 }
 
-/// Extract block hash from raw preconf data using fixed offsets  
-/// Block hash ist nach blockNumber + gasLimit + gasUsed + timestamp + extraData(4) + baseFeePerGas
-pub fn extract_block_hash_from_preconf_data(data: &[u8]) -> Result<[u8; 32], Box<dyn std::error::Error + Send + Sync>> {
-    // Berechne Offset für blockHash (nach blockNumber)
-    let block_number_offset = 32 + 32 + 20 + 32 + 32 + 256 + 32; // wie oben berechnet
-    let gas_limit_offset = 8;
-    let gas_used_offset = 8;  
-    let timestamp_offset = 8;
-    let extra_data_offset = 4; // extraData ist dynamisch, aber meist 4 bytes
-    let base_fee_offset = 32;
-    
-    let block_hash_offset = block_number_offset 
-        + gas_limit_offset 
-        + gas_used_offset 
-        + timestamp_offset 
-        + extra_data_offset 
-        + base_fee_offset;
-    
-    if data.len() < block_hash_offset + 32 {
-        return Err("Data too short to contain block hash".into());
+static SSZ_FALLBACK_WARNED: AtomicBool = AtomicBool::new(false);
+
+/// Dekodiert die Preconf-Daten strukturell, bei Fehlern über den festen Offset (mit Warnung)
+fn preconf_field<T>(
+    data: &[u8],
+    name: &str,
+    decoded: impl FnOnce(&PayloadEnvelope) -> T,
+    fallback: impl FnOnce(&[u8]) -> Result<T, Box<dyn std::error::Error + Send + Sync>>,
+) -> Result<T, Box<dyn std::error::Error + Send + Sync>> {
+    match decode_envelope(data) {
+        Ok(envelope) => Ok(decoded(&envelope)),
+        Err(e) => {
+            // Einmal sichtbar warnen, danach nur noch debug (sonst ein Log-Eintrag pro Block)
+            if !SSZ_FALLBACK_WARNED.swap(true, Ordering::Relaxed) {
+                warn!("⚠️  SSZ decoding failed ({}), reading {} from fixed offset", e, name);
+            } else {
+                debug!("SSZ decoding failed ({}), reading {} from fixed offset", e, name);
+            }
+            fallback(data)
+        }
     }
-    
-    // Extrahiere 32 Bytes für block hash
-    let mut block_hash = [0u8; 32];
-    block_hash.copy_from_slice(&data[block_hash_offset..block_hash_offset + 32]);
-    
-    Ok(block_hash)
 }
 
-/// Update both latest.raw and pre_latest.raw symlinks
-pub async fn update_symlinks_lib(
+/// Blocknummer aus den Preconf-Daten (32 bytes parent_beacon_block_root + ExecutionPayload)
+pub fn extract_block_number_from_preconf_data(data: &[u8]) -> Result<u64, Box<dyn std::error::Error + Send + Sync>> {
+    preconf_field(data, "block number", |e| e.payload.block_number, |data| {
+        Ok(u64::from_le_bytes(fallback_field(data, FALLBACK_BLOCK_NUMBER_OFFSET, 8)?.try_into()?))
+    })
+}
+
+/// Payload-Timestamp (unix Sekunden) aus den Preconf-Daten
+pub fn extract_timestamp_from_preconf_data(data: &[u8]) -> Result<u64, Box<dyn std::error::Error + Send + Sync>> {
+    preconf_field(data, "timestamp", |e| e.payload.timestamp, |data| {
+        Ok(u64::from_le_bytes(fallback_field(data, FALLBACK_TIMESTAMP_OFFSET, 8)?.try_into()?))
+    })
+}
+
+/// Block-Hash aus den Preconf-Daten
+pub fn extract_block_hash_from_preconf_data(data: &[u8]) -> Result<[u8; 32], Box<dyn std::error::Error + Send + Sync>> {
+    preconf_field(data, "block hash", |e| e.payload.block_hash, |data| {
+        Ok(fallback_field(data, FALLBACK_BLOCK_HASH_OFFSET, 32)?.try_into()?)
+    })
+}
+
+/// Update latest.raw, pre_latest.raw and latest.json (portable, see pointer.rs)
+pub async fn update_latest_pointers(
     output_dir: &PathBuf,
     new_latest_filename: &str,
     chain_id: u64,
+    pointer: &LatestPointer,
+    fs: &DurableFs,
 ) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
-    let latest_path = output_dir.join("latest.raw");
-    let pre_latest_path = output_dir.join("pre_latest.raw");
-    
     // Find the previous latest file by scanning directory
     let previous_filename = find_previous_block_file(output_dir, chain_id).await?;
-    
-    // Update pre_latest.raw first (if we have a previous file)
-    if let Some(prev_filename) = previous_filename {
-        // Remove old pre_latest symlink
-        let _ = tokio_fs::remove_file(&pre_latest_path).await; // Ignore error if doesn't exist
-        
-        // Create new pre_latest symlink
-        tokio_fs::symlink(&prev_filename, &pre_latest_path).await
-            .map_err(|e| format!("Failed to create pre_latest.raw symlink: {}", e))?;
-        
-//        info!("🔗 Updated pre_latest.raw symlink to {}", prev_filename);
-    }
-    
-    // Update latest.raw
-    let _ = tokio_fs::remove_file(&latest_path).await; // Ignore error if doesn't exist
-    tokio_fs::symlink(new_latest_filename, &latest_path).await
-        .map_err(|e| format!("Failed to create latest.raw symlink: {}", e))?;
-    
-//    info!("🔗 Updated latest.raw symlink to {}", new_latest_filename);
-    
-    Ok(())
+    pointer.update(output_dir, new_latest_filename, previous_filename.as_deref(), fs).await
 }
 
 /// Find the previous block file (second newest) by scanning directory
@@ -159,91 +161,222 @@ async fn find_previous_block_file(
 /// Cleanup-Funktion für TTL-basierte Löschung alter Preconf-Dateien
 pub async fn cleanup_old_files(
     output_dir: PathBuf,
-    ttl_minutes: u64,
+    chain_id: u64,
     cleanup_interval_minutes: u64,
+    mut runtime: watch::Receiver<RuntimeConfig>,
+    stats: Arc<StorageStats>,
+    reorg_grace: Duration,
+    trigger: Arc<Notify>,
     running: Arc<Mutex<bool>>,
 ) {
-    let ttl_duration = Duration::from_secs(ttl_minutes * 60);
+    // TTL, Quota und Retention können sich auf SIGHUP ändern (siehe reload.rs)
+    let mut config = runtime.borrow_and_update().clone();
+    let mut finality = FinalityRetention::configured(config.retention, config.finality_rpc.clone());
     let cleanup_interval = Duration::from_secs(cleanup_interval_minutes * 60);
     
-    info!("🧹 Starting TTL cleanup task: TTL={}min, interval={}min", ttl_minutes, cleanup_interval_minutes);
+    match finality {
+        Some(ref finality) => info!(
+            "🧹 Starting cleanup task: retention={:?} head, interval={}min",
+            finality.retention(),
+            cleanup_interval_minutes
+        ),
+        None => info!(
+            "🧹 Starting TTL cleanup task: TTL={}min, interval={}min",
+            config.ttl_minutes, cleanup_interval_minutes
+        ),
+    }
     
     let mut interval_timer = interval(cleanup_interval);
+    let mut block_times = BlockTimes::new();
     
     while *running.lock().unwrap() {
-        interval_timer.tick().await;
+        // Nächster Zyklus nach Intervall oder sofort, wenn über die Admin-API angestoßen
+        let forced = tokio::select! {
+            _ = interval_timer.tick() => false,
+            _ = trigger.notified() => true,
+        };
         
         if !*running.lock().unwrap() {
             break;
         }
         
-        // GPT-5 Hot-Thread Fix: Noch längere Pause zwischen Cleanup-Zyklen (60 Sekunden)
-        tokio::time::sleep(Duration::from_secs(60)).await;
+        if forced {
+            info!("🧹 Cleanup cycle triggered via admin API");
+        } else {
+            // GPT-5 Hot-Thread Fix: Noch längere Pause zwischen Cleanup-Zyklen (60 Sekunden)
+            tokio::time::sleep(Duration::from_secs(60)).await;
+        }
+
+        if runtime.has_changed().unwrap_or(false) {
+            let updated = runtime.borrow_and_update().clone();
+            if (updated.retention, &updated.finality_rpc) != (config.retention, &config.finality_rpc) {
+                finality = FinalityRetention::configured(updated.retention, updated.finality_rpc.clone());
+            }
+            config = updated;
+        }
+        let ttl_duration = Duration::from_secs(config.ttl_minutes * 60);
         
-        match cleanup_expired_files(&output_dir, ttl_duration).await {
-            Ok(_deleted_count) => {
-                // Cleanup-Meldung wird bereits in cleanup_expired_files() geloggt
-                // Note: Status wird bereits in cleanup_expired_files() geloggt
+        if let Some(ref finality) = finality {
+            // Finality-Modus: nur Blöcke bis zum Safe-/Finalized-Head löschen, unsafe Blöcke bleiben.
+            // Ist der RPC nicht erreichbar, wird nichts gelöscht (die Quota begrenzt weiterhin den Platz).
+            match finality.head().await {
+                Ok(head) => match prune_finalized(&output_dir, chain_id, head).await {
+                    Ok(deleted_blocks) => stats.record_evicted(Eviction::Finality, deleted_blocks),
+                    Err(e) => warn!("⚠️  Finality cleanup failed: {}", e),
+                },
+                Err(e) => warn!("⚠️  Cannot determine {:?} head, skipping cleanup: {}", finality.retention(), e),
             }
-            Err(e) => {
-                warn!("⚠️  Cleanup failed: {}", e);
+        } else {
+            match cleanup_expired_files(&output_dir, chain_id, ttl_duration, Some(&mut block_times)).await {
+                Ok(deleted_blocks) => {
+                    // Cleanup-Meldung wird bereits in cleanup_expired_files() geloggt
+                    stats.record_evicted(Eviction::Ttl, deleted_blocks);
+                }
+                Err(e) => {
+                    warn!("⚠️  Cleanup failed: {}", e);
+                }
+            }
+        }
+
+        // Quarantäne läuft immer per TTL ab (zählt nicht als Eviction)
+        let quarantine_dir = output_dir.join(QUARANTINE_DIR_NAME);
+        if quarantine_dir.is_dir() {
+            if let Err(e) = cleanup_expired_files(&quarantine_dir, chain_id, ttl_duration, None).await {
+                warn!("⚠️  Quarantine cleanup failed: {}", e);
             }
         }
+
+        // Verdrängte Blöcke nach der Gnadenfrist entfernen (siehe reorg.rs, zählt nicht als Eviction)
+        let superseded_dir = output_dir.join(SUPERSEDED_DIR_NAME);
+        if superseded_dir.is_dir() {
+            if let Err(e) = cleanup_expired_files(&superseded_dir, chain_id, reorg_grace, None).await {
+                warn!("⚠️  Superseded block cleanup failed: {}", e);
+            }
+        }
+
+        // Größenlimit der Chain durchsetzen (älteste Blöcke zuerst)
+        if let Some(quota) = config.quota_bytes {
+            match enforce_quota(&output_dir, chain_id, quota).await {
+                Ok(deleted_blocks) => stats.record_evicted(Eviction::Quota, deleted_blocks),
+                Err(e) => warn!("⚠️  Quota enforcement failed: {}", e),
+            }
+        }
+
+        // Temp-Dateien abgebrochener Schreibvorgänge entfernen
+        let stale_dir = output_dir.clone();
+        match tokio::task::spawn_blocking(move || remove_stale_temp_files(&stale_dir, STALE_TEMP_AGE)).await {
+            Ok(removed) if removed > 0 => info!("🧹 Removed {} stranded temp files", removed),
+            Ok(_) => {}
+            Err(e) => warn!("⚠️  Temp file cleanup failed: {}", e),
+        }
+
+        // Blobs ohne verbleibende .raw Einträge entfernen (nur bei KONA_BRIDGE_DEDUP)
+        if let Err(e) = gc_unreferenced_blobs(&output_dir).await {
+            warn!("⚠️  Blob GC failed: {}", e);
+        }
     }
     
     info!("🛑 TTL cleanup task stopped");
 }
 
-/// Löscht alle .raw und .json Dateien, die älter als die TTL sind
+/// Payload-Timestamps gespeicherter Blöcke (None = nicht lesbar), damit jeder Block nur einmal gelesen wird
+type BlockTimes = HashMap<u64, Option<u64>>;
+
+/// Payload-Timestamp eines gespeicherten Blocks aus seinen Metadaten (block_*.json), sonst aus der .raw Datei
+async fn stored_block_timestamp(raw_path: &Path) -> Option<u64> {
+    let metadata = tokio_fs::read(raw_path.with_extension("json")).await.ok();
+    let from_metadata = metadata
+        .and_then(|data| serde_json::from_slice::<serde_json::Value>(&data).ok())
+        .and_then(|metadata| metadata["timestamp"].as_u64());
+    if let Some(timestamp) = from_metadata.filter(|timestamp| *timestamp > 0) {
+        return Some(timestamp);
+    }
+    let data = tokio_fs::read(raw_path).await.ok()?;
+    let payload = PreconfContainer::parse(&data).and_then(|container| container.payload()).ok()?;
+    extract_timestamp_from_preconf_data(&payload).ok().filter(|timestamp| *timestamp > 0)
+}
+
+/// Löscht alle .raw und .json Dateien, die älter als die TTL sind.
+/// Mit `block_times` zählt bei Blockdateien das Alter des Blocks (Payload-Timestamp), damit nachgeladene alte Blöcke
+/// nicht die volle TTL bleiben; ohne lesbaren Timestamp und ohne `block_times` (Quarantäne, verdrängte Blöcke) die
+/// mtime, also die Empfangszeit. Blockdateien anderer Chains im selben (flachen) Verzeichnis bleiben unberührt.
+/// Gibt die Anzahl gelöschter Blöcke (.raw Dateien) zurück.
 async fn cleanup_expired_files(
     output_dir: &PathBuf,
+    chain_id: u64,
     ttl_duration: Duration,
+    mut block_times: Option<&mut BlockTimes>,
 ) -> Result<usize, Box<dyn std::error::Error + Send + Sync>> {
     let now = SystemTime::now();
+    let now_unix = now.duration_since(UNIX_EPOCH).map_or(0, |d| d.as_secs());
     let mut deleted_count = 0;
+    let mut deleted_blocks = 0;
     let mut deleted_files = Vec::new();
+    let mut expired = Vec::new();
+    let mut seen_blocks = HashSet::new();
     
     let mut entries = tokio_fs::read_dir(output_dir).await?;
     
     while let Some(entry) = entries.next_entry().await? {
         let path = entry.path();
         
-        // Nur .raw und .json Dateien berücksichtigen
+        // Nur .raw, .json und .c4 Dateien berücksichtigen
         if let Some(extension) = path.extension() {
             let ext = extension.to_string_lossy();
-            if ext != "raw" && ext != "json" {
+            if ext != "raw" && ext != "json" && ext != &C4_SUFFIX[1..] {
                 continue;
             }
         } else {
             continue;
         }
         
-        // latest.raw Symlink nicht löschen
-        if path.file_name().unwrap_or_default() == "latest.raw" {
+        // latest-Zeiger, Block-Index, Signer-Verlauf und Checkpoint nicht löschen
+        let file_name = path.file_name().unwrap_or_default();
+        if file_name == LATEST_FILE_NAME
+            || file_name == LATEST_JSON_FILE_NAME
+            || file_name == INDEX_FILE_NAME
+            || file_name == SIGNER_HISTORY_FILE_NAME
+            || file_name == CHECKPOINT_FILE_NAME
+            || file_name == STATUS_FILE_NAME
+        {
             continue;
         }
         
+        // Blockdateien anderer Chains gehören deren Cleanup-Task
+        let stem = block_file_stem(&path);
+        let block_number = block_number_from_filename(&path, chain_id);
+        if stem.is_some() && block_number.is_none() {
+            continue;
+        }
+
+        // Alter des Blocks nach Payload-Timestamp (je Block einmal über die .raw Datei bestimmt)
+        if let (Some(times), Some(stem), Some(block_number)) = (block_times.as_deref_mut(), stem, block_number) {
+            seen_blocks.insert(block_number);
+            let timestamp = match times.get(&block_number) {
+                Some(timestamp) => *timestamp,
+                None => {
+                    let raw_path = output_dir.join(format!("{}.raw", stem));
+                    let timestamp = stored_block_timestamp(&raw_path).await;
+                    times.insert(block_number, timestamp);
+                    timestamp
+                }
+            };
+            if let Some(timestamp) = timestamp {
+                let age = Duration::from_secs(now_unix.saturating_sub(timestamp));
+                if age > ttl_duration {
+                    expired.push((path, age));
+                }
+                continue;
+            }
+        }
+
         // Prüfe Dateialter
         match entry.metadata().await {
             Ok(metadata) => {
                 if let Ok(modified) = metadata.modified() {
                     if let Ok(age) = now.duration_since(modified) {
                         if age > ttl_duration {
-                            // Datei ist zu alt - löschen
-                            match tokio_fs::remove_file(&path).await {
-                                Ok(_) => {
-                                    // Sammle gelöschte Dateien für Zusammenfassung
-                                    let filename = path.file_name()
-                                        .unwrap_or_default()
-                                        .to_string_lossy()
-                                        .to_string();
-                                    deleted_files.push((filename, age.as_secs() / 60));
-                                    deleted_count += 1;
-                                }
-                                Err(e) => {
-                                    warn!("⚠️  Failed to delete {:?}: {}", path, e);
-                                }
-                            }
+                            expired.push((path, age));
                         }
                     }
                 }
@@ -253,6 +386,38 @@ async fn cleanup_expired_files(
             }
         }
     }
+
+    // Timestamps gelöschter Blöcke vergessen (eine neue Datei derselben Höhe wird neu gelesen)
+    if let Some(times) = block_times {
+        let expired_blocks: HashSet<u64> =
+            expired.iter().filter_map(|(path, _)| block_number_from_filename(path, chain_id)).collect();
+        times.retain(|block_number, _| seen_blocks.contains(block_number) && !expired_blocks.contains(block_number));
+    }
+
+    // Delta-Nachfolger, die nicht mit abgelaufen sind, brauchen ihre Basis - vorher als Keyframe neu schreiben
+    let expired_paths: Vec<PathBuf> = expired.iter().map(|(path, _)| path.clone()).collect();
+    delta::detach(&expired_paths).await;
+
+    for (path, age) in expired {
+        // Datei ist zu alt - löschen
+        match tokio_fs::remove_file(&path).await {
+            Ok(_) => {
+                // Sammle gelöschte Dateien für Zusammenfassung
+                let filename = path.file_name()
+                    .unwrap_or_default()
+                    .to_string_lossy()
+                    .to_string();
+                if filename.ends_with(".raw") {
+                    deleted_blocks += 1;
+                }
+                deleted_files.push((filename, age.as_secs() / 60));
+                deleted_count += 1;
+            }
+            Err(e) => {
+                warn!("⚠️  Failed to delete {:?}: {}", path, e);
+            }
+        }
+    }
     
     // Zusammenfassung der gelöschten Dateien loggen
     if !deleted_files.is_empty() {
@@ -272,7 +437,165 @@ async fn cleanup_expired_files(
         }
     }
     
-    Ok(deleted_count)
+    Ok(deleted_blocks)
+}
+
+/// Löscht die ältesten Blöcke (.raw + .json) der Chain, bis ihre Dateien unter `quota_bytes` liegen.
+/// Die zwei neuesten Blöcke (latest/pre_latest) bleiben immer erhalten.
+async fn enforce_quota(
+    output_dir: &PathBuf,
+    chain_id: u64,
+    quota_bytes: u64,
+) -> Result<usize, Box<dyn std::error::Error + Send + Sync>> {
+    let (blocks, total) = scan_block_files(output_dir, chain_id).await?;
+    if total <= quota_bytes {
+        return Ok(0);
+    }
+
+    let (deleted_blocks, total) = delete_oldest_blocks(blocks, total, quota_bytes).await;
+    info!(
+        "🗑️  Quota: deleted {} oldest blocks ({} MB in use, limit {} MB)",
+        deleted_blocks,
+        total / (1024 * 1024),
+        quota_bytes / (1024 * 1024)
+    );
+    Ok(deleted_blocks)
+}
+
+/// Notfall-Löschung bei vollem Dateisystem (siehe diskguard.rs): löscht die ältesten Blöcke, bis `bytes` frei
+/// geworden sind, ebenfalls ohne die zwei neuesten Blöcke
+pub async fn prune_for_free_space(
+    output_dir: &Path,
+    chain_id: u64,
+    bytes: u64,
+) -> Result<usize, Box<dyn std::error::Error + Send + Sync>> {
+    let (blocks, total) = scan_block_files(output_dir, chain_id).await?;
+    let (deleted_blocks, remaining) = delete_oldest_blocks(blocks, total, total.saturating_sub(bytes)).await;
+    if deleted_blocks > 0 {
+        warn!(
+            "🗑️  Disk full: deleted {} oldest blocks ({} MB freed, {} MB still stored)",
+            deleted_blocks,
+            (total - remaining) / (1024 * 1024),
+            remaining / (1024 * 1024)
+        );
+    }
+    Ok(deleted_blocks)
+}
+
+/// Dateien der gespeicherten Blöcke (.raw, .json, ...) der Chain nach Blocknummer und ihre Gesamtgröße
+async fn scan_block_files(
+    output_dir: &Path,
+    chain_id: u64,
+) -> Result<(BTreeMap<u64, Vec<(PathBuf, u64)>>, u64), Box<dyn std::error::Error + Send + Sync>> {
+    let mut blocks: BTreeMap<u64, Vec<(PathBuf, u64)>> = BTreeMap::new();
+    let mut total: u64 = 0;
+
+    let mut entries = tokio_fs::read_dir(output_dir).await?;
+    while let Some(entry) = entries.next_entry().await? {
+        let path = entry.path();
+        let Some(block_number) = block_number_from_filename(&path, chain_id) else {
+            continue;
+        };
+        let size = match tokio_fs::symlink_metadata(&path).await {
+            Ok(metadata) if metadata.is_file() => metadata.len(),
+            _ => continue,
+        };
+        total += size;
+        blocks.entry(block_number).or_default().push((path, size));
+    }
+    Ok((blocks, total))
+}
+
+/// Löscht ab dem ältesten Block, bis `total` höchstens `limit` ist; (gelöschte Blöcke, verbleibende Bytes)
+async fn delete_oldest_blocks(blocks: BTreeMap<u64, Vec<(PathBuf, u64)>>, mut total: u64, limit: u64) -> (usize, u64) {
+    // Erst die zu löschenden Blöcke bestimmen, damit nur der Delta-Nachfolger des letzten neu geschrieben wird
+    let deletable = blocks.len().saturating_sub(2);
+    let mut remaining = total;
+    let victims: Vec<Vec<(PathBuf, u64)>> = blocks
+        .into_values()
+        .take(deletable)
+        .take_while(|files| {
+            let delete = remaining > limit;
+            remaining = remaining.saturating_sub(files.iter().map(|(_, size)| size).sum());
+            delete
+        })
+        .collect();
+    let paths: Vec<PathBuf> = victims.iter().flatten().map(|(path, _)| path.clone()).collect();
+    delta::detach(&paths).await;
+
+    let mut deleted_blocks = 0;
+    for files in victims {
+        if total <= limit {
+            break;
+        }
+        for (path, size) in files {
+            if tokio_fs::remove_file(&path).await.is_ok() {
+                total = total.saturating_sub(size);
+            }
+        }
+        deleted_blocks += 1;
+    }
+    (deleted_blocks, total)
+}
+
+/// Endungen der Temp-Dateien von tmp + rename (.raw/.blob -> .tmp, .json -> .json.tmp, Blob-Links -> .lnk)
+const TEMP_EXTENSIONS: [&str; 2] = ["tmp", "lnk"];
+
+/// Maximales Alter einer Temp-Datei, bevor der Cleanup-Task sie als liegen geblieben betrachtet
+const STALE_TEMP_AGE: Duration = Duration::from_secs(10 * 60);
+
+/// Entfernt Temp-Dateien (im Output- und Blob-Verzeichnis), die älter als `max_age` sind
+pub fn remove_stale_temp_files(output_dir: &Path, max_age: Duration) -> usize {
+    let now = SystemTime::now();
+    let mut removed = 0;
+
+    for dir in [output_dir.to_path_buf(), output_dir.join(BLOB_DIR_NAME)] {
+        let Ok(entries) = std::fs::read_dir(&dir) else {
+            continue;
+        };
+        for entry in entries.flatten() {
+            let path = entry.path();
+            let is_temp = path
+                .extension()
+                .map_or(false, |ext| TEMP_EXTENSIONS.iter().any(|t| ext == *t));
+            if !is_temp {
+                continue;
+            }
+            let age = entry
+                .metadata()
+                .and_then(|m| m.modified())
+                .ok()
+                .and_then(|modified| now.duration_since(modified).ok())
+                .unwrap_or_default();
+            if age >= max_age && std::fs::remove_file(&path).is_ok() {
+                debug!("🧹 Removed stranded temp file {:?}", path.file_name().unwrap_or_default());
+                removed += 1;
+            }
+        }
+    }
+    removed
+}
+
+/// block_{chain}_{number} aus block_{chain}_{number}.raw/.json/.eth.json/.c4
+fn block_file_stem(path: &Path) -> Option<&str> {
+    let name = path.file_name()?.to_str()?;
+    let stem = name
+        .strip_suffix(".raw")
+        .or_else(|| name.strip_suffix(RENDERED_SUFFIX))
+        .or_else(|| name.strip_suffix(C4_SUFFIX))
+        .or_else(|| name.strip_suffix(".json"))?;
+    stem.starts_with("block_").then_some(stem)
+}
+
+/// Blocknummer aus block_{chain}_{number}.raw/.json/.eth.json/.c4, nur für Dateien der Chain `chain_id`
+/// (im flachen Layout liegen mehrere Chains im selben Verzeichnis)
+pub fn block_number_from_filename(path: &Path, chain_id: u64) -> Option<u64> {
+    let number = block_file_stem(path)?.strip_prefix("block_")?.strip_prefix(chain_id.to_string().as_str())?;
+    let number = number.strip_prefix('_')?;
+    if number.is_empty() || !number.bytes().all(|b| b.is_ascii_digit()) {
+        return None;
+    }
+    number.parse().ok()
 }
 
 /// Konvertiere Alloy-Signatur zu 65-Byte-Array
@@ -284,3 +607,21 @@ pub fn signature_to_bytes(signature: &alloy::signers::Signature) -> [u8; 65] {
     bytes[64] = if signature.v() { 28 } else { 27 };
     bytes
 }
+
+/// Freier Platz für unprivilegierte Prozesse im Dateisystem des Pfads
+#[cfg(unix)]
+pub fn free_bytes(path: &Path) -> io::Result<u64> {
+    use std::{ffi::CString, os::unix::ffi::OsStrExt};
+
+    let path = CString::new(path.as_os_str().as_bytes()).map_err(|e| io::Error::new(io::ErrorKind::InvalidInput, e))?;
+    let mut stat: libc::statvfs = unsafe { std::mem::zeroed() };
+    if unsafe { libc::statvfs(path.as_ptr(), &mut stat) } != 0 {
+        return Err(io::Error::last_os_error());
+    }
+    Ok(stat.f_bavail as u64 * stat.f_frsize as u64)
+}
+
+#[cfg(not(unix))]
+pub fn free_bytes(_path: &Path) -> io::Result<u64> {
+    Err(io::Error::new(io::ErrorKind::Unsupported, "free space not available on this platform"))
+}
@@ -0,0 +1,157 @@
+// validation.rs - Plausibilitätsprüfungen für empfangene Payloads vor dem Speichern
+//
+// Ergänzt die Integritätsprüfungen (Block-Hash, Withdrawals) um Regeln, die op-node für Gossip
+// anwendet: Payload-Timestamp höchstens KONA_BRIDGE_MAX_FUTURE_SECS in der Zukunft (Default 5s)
+// und höchstens KONA_BRIDGE_MAX_PAST_SECS in der Vergangenheit (Default 60s).
+// Dazu Plausibilität: Blocknummer in einem Fenster um den bekannten Head (aus den bisherigen
+// Captures bzw. KONA_BRIDGE_HEAD_RPC) und Gas-Limit innerhalb der Protokollgrenzen.
+// Je Prüfung: off, flag (speichern, in den Metadaten unter `validation_flags` vermerken) oder
+// reject (verwerfen, wie bei falschem Block-Hash als invalid_block_*.json festhalten).
+// Die Payload-Version gegen den Hardfork-Zeitplan prüft hardfork.rs (Modus KONA_BRIDGE_FORK_CHECK).
+// Default: Timestamp flag, Plausibilität reject.
+
+use crate::settings::ValidationSettings;
+use std::{
+    sync::{
+        atomic::{AtomicU64, Ordering},
+        Arc, Mutex,
+    },
+    time::{Duration, SystemTime, UNIX_EPOCH},
+};
+use tokio::time::interval;
+use tracing::{debug, info, warn};
+
+/// Umgang mit einer fehlgeschlagenen Prüfung
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ValidationMode {
+    Off,
+    Flag,
+    Reject,
+}
+
+impl ValidationMode {
+    /// Parst "off", "flag" oder "reject"
+    pub fn parse(value: &str) -> Option<Self> {
+        match value.trim().to_ascii_lowercase().as_str() {
+            "off" | "0" => Some(ValidationMode::Off),
+            "flag" | "" => Some(ValidationMode::Flag),
+            "reject" => Some(ValidationMode::Reject),
+            _ => None,
+        }
+    }
+}
+
+pub const DEFAULT_MAX_FUTURE_SECS: u64 = 5;
+pub const DEFAULT_MAX_PAST_SECS: u64 = 60;
+/// Fenster um den Head (1800 Blöcke = 1h bei 2s Blockzeit)
+pub const DEFAULT_MAX_BLOCKS_AHEAD: u64 = 1800;
+pub const DEFAULT_MAX_BLOCKS_BEHIND: u64 = 1800;
+/// Untergrenze aus dem Protokoll (MIN_GAS_LIMIT), Obergrenze wie SystemConfig.MAX_GAS_LIMIT
+pub const DEFAULT_MIN_GAS_LIMIT: u64 = 5_000;
+pub const DEFAULT_MAX_GAS_LIMIT: u64 = 200_000_000;
+
+const HEAD_POLL_INTERVAL: Duration = Duration::from_secs(30);
+
+/// Prüft den Payload-Timestamp gegen die lokale Zeit (0 = unbekannt, wird nicht geprüft)
+pub fn check_timestamp(timestamp: u64, now: u64, settings: &ValidationSettings) -> Result<(), String> {
+    if timestamp == 0 {
+        return Ok(());
+    }
+    if timestamp > now + settings.max_future_secs {
+        return Err(format!("timestamp {} is {}s in the future", timestamp, timestamp - now));
+    }
+    if timestamp + settings.max_past_secs < now {
+        return Err(format!("timestamp {} is {}s in the past", timestamp, now - timestamp));
+    }
+    Ok(())
+}
+
+/// Prüft die Blocknummer gegen den bekannten Head. `head` = (Blocknummer, Alter in Sekunden);
+/// pro Sekunde Alter ist ein Block mehr Vorsprung erlaubt (Blockzeit >= 1s), damit ein veralteter
+/// Head nach einer Pause nicht alle neuen Blöcke verwirft.
+pub fn check_block_number(block_number: u64, head: Option<(u64, u64)>, settings: &ValidationSettings) -> Result<(), String> {
+    let Some((head, age)) = head else {
+        return Ok(());
+    };
+    let max_ahead = settings.max_blocks_ahead.saturating_add(age);
+    if block_number > head.saturating_add(max_ahead) {
+        return Err(format!("block {} is {} blocks ahead of head {}", block_number, block_number - head, head));
+    }
+    if block_number.saturating_add(settings.max_blocks_behind) < head {
+        return Err(format!("block {} is {} blocks behind head {}", block_number, head - block_number, head));
+    }
+    Ok(())
+}
+
+/// Prüft Gas-Limit und Gas-Verbrauch gegen die konfigurierten Grenzen
+pub fn check_gas(gas_limit: u64, gas_used: u64, settings: &ValidationSettings) -> Result<(), String> {
+    if gas_limit < settings.min_gas_limit || gas_limit > settings.max_gas_limit {
+        return Err(format!(
+            "gas limit {} outside [{}, {}]",
+            gas_limit, settings.min_gas_limit, settings.max_gas_limit
+        ));
+    }
+    if gas_used > gas_limit {
+        return Err(format!("gas used {} exceeds gas limit {}", gas_used, gas_limit));
+    }
+    Ok(())
+}
+
+/// Head der Chain laut RPC (0 = unbekannt) und Zeitpunkt der Abfrage
+#[derive(Default)]
+pub struct RpcHead {
+    number: AtomicU64,
+    updated_unix: AtomicU64,
+}
+
+impl RpcHead {
+    /// (Blocknummer, Alter in Sekunden), falls bekannt
+    pub fn get(&self, now: u64) -> Option<(u64, u64)> {
+        let number = self.number.load(Ordering::Relaxed);
+        (number > 0).then(|| (number, now.saturating_sub(self.updated_unix.load(Ordering::Relaxed))))
+    }
+
+    fn set(&self, number: u64) {
+        let now = SystemTime::now().duration_since(UNIX_EPOCH).map(|d| d.as_secs()).unwrap_or(0);
+        self.updated_unix.store(now, Ordering::Relaxed);
+        self.number.store(number, Ordering::Relaxed);
+    }
+}
+
+/// Fragt den Head regelmäßig per eth_blockNumber ab (KONA_BRIDGE_HEAD_RPC)
+pub async fn run_head_poll(head: Arc<RpcHead>, rpc_url: String, running: Arc<Mutex<bool>>) {
+    let client = reqwest::Client::new();
+    let mut ticker = interval(HEAD_POLL_INTERVAL);
+    let mut failing = false;
+    info!("🧭 Head RPC: polling {} every {}s", rpc_url, HEAD_POLL_INTERVAL.as_secs());
+
+    loop {
+        ticker.tick().await;
+        if !*running.lock().unwrap() {
+            break;
+        }
+        match fetch_block_number(&client, &rpc_url).await {
+            Ok(number) => {
+                debug!("🧭 Head RPC: block {}", number);
+                head.set(number);
+                failing = false;
+            }
+            Err(e) if !failing => {
+                warn!("⚠️  Head RPC: eth_blockNumber failed: {}", e);
+                failing = true;
+            }
+            Err(e) => debug!("🧭 Head RPC: eth_blockNumber failed: {}", e),
+        }
+    }
+}
+
+async fn fetch_block_number(client: &reqwest::Client, rpc_url: &str) -> Result<u64, Box<dyn std::error::Error + Send + Sync>> {
+    let request = serde_json::json!({"jsonrpc": "2.0", "id": 1, "method": "eth_blockNumber", "params": []});
+    let response = client.post(rpc_url).json(&request).timeout(Duration::from_secs(10)).send().await?;
+    if !response.status().is_success() {
+        return Err(format!("HTTP {} from {}", response.status(), rpc_url).into());
+    }
+    let body: serde_json::Value = response.json().await?;
+    let number = body["result"].as_str().ok_or("eth_blockNumber without result")?;
+    Ok(u64::from_str_radix(number.trim_start_matches("0x"), 16)?)
+}
@@ -0,0 +1,194 @@
+// zdict.rs - zstd-Dictionary aus den letzten Payloads einer Chain (KONA_BRIDGE_ZSTD_DICT=1)
+//
+// Execution-Payloads derselben Chain teilen viel Struktur (Header-Felder, Deposit-Transaktion, Contract-Aufrufe).
+// Der Trainer sammelt kleine Payloads, trainiert daraus ein Dictionary und komprimiert alle folgenden Blöcke damit;
+// das bringt bei kleinen Blöcken 20-40% bessere Raten. Die ID steht im Container-Header (flags bit 0, reserved
+// Bytes 97..100 als u24), die Dictionaries liegen dauerhaft in {output_dir}/zstd_dicts/zstd_dict_{id}.dict und werden
+// beim Start in die prozessweite Registry geladen (PreconfContainer::payload() schlägt dort nach). Alte Dictionaries
+// bleiben liegen, solange Dateien sie brauchen können (wenige 100 KiB je Training).
+
+use crate::{durability::DurableFs, perms};
+use std::{
+    collections::{HashMap, VecDeque},
+    fs,
+    path::{Path, PathBuf},
+    sync::{
+        atomic::{AtomicBool, AtomicU64, Ordering},
+        Arc, Mutex, OnceLock, RwLock,
+    },
+    time::SystemTime,
+};
+use tracing::{info, warn};
+
+pub const DICT_DIR_NAME: &str = "zstd_dicts";
+pub const DEFAULT_DICT_SAMPLES: usize = 512;
+pub const DEFAULT_DICT_KB: usize = 112;
+pub const DEFAULT_DICT_RETRAIN_BLOCKS: u64 = 100_000;
+
+/// Größere Payloads gehen nicht ins Training (Dictionaries helfen vor allem kleinen Blöcken)
+const MAX_SAMPLE_BYTES: usize = 256 * 1024;
+/// IDs passen in die drei reservierten Header-Bytes
+const MAX_DICT_ID: u32 = 0x00FF_FFFF;
+
+/// Trainiertes Dictionary mit seiner ID im Container-Header
+pub struct ZstdDict {
+    pub id: u32,
+    pub data: Vec<u8>,
+}
+
+static DICTIONARIES: OnceLock<RwLock<HashMap<u32, Arc<ZstdDict>>>> = OnceLock::new();
+
+fn registry() -> &'static RwLock<HashMap<u32, Arc<ZstdDict>>> {
+    DICTIONARIES.get_or_init(|| RwLock::new(HashMap::new()))
+}
+
+/// Dictionary zu einer ID aus dem Container-Header
+pub fn lookup(id: u32) -> Option<Arc<ZstdDict>> {
+    registry().read().unwrap().get(&id).cloned()
+}
+
+fn dict_filename(id: u32) -> String {
+    format!("zstd_dict_{:06x}.dict", id)
+}
+
+/// Lädt alle Dictionaries aus {output_dir}/zstd_dicts in die Registry und gibt das zuletzt geschriebene zurück
+pub fn load_dir(output_dir: &Path) -> Option<Arc<ZstdDict>> {
+    let entries = fs::read_dir(output_dir.join(DICT_DIR_NAME)).ok()?;
+    let mut newest: Option<(SystemTime, Arc<ZstdDict>)> = None;
+    for entry in entries.flatten() {
+        let name = entry.file_name().to_string_lossy().into_owned();
+        let Some(id) = name
+            .strip_prefix("zstd_dict_")
+            .and_then(|rest| rest.strip_suffix(".dict"))
+            .and_then(|hex| u32::from_str_radix(hex, 16).ok())
+        else {
+            continue;
+        };
+        let data = match fs::read(entry.path()) {
+            Ok(data) => data,
+            Err(e) => {
+                warn!("⚠️  Cannot read zstd dictionary {:?}: {}", entry.path(), e);
+                continue;
+            }
+        };
+        let dict = Arc::new(ZstdDict { id, data });
+        registry().write().unwrap().insert(id, dict.clone());
+
+        let modified = entry.metadata().and_then(|m| m.modified()).unwrap_or(SystemTime::UNIX_EPOCH);
+        if newest.as_ref().map_or(true, |(time, _)| modified > *time) {
+            newest = Some((modified, dict));
+        }
+    }
+    newest.map(|(_, dict)| dict)
+}
+
+/// Einstellungen des Trainers (siehe BridgeSettings)
+#[derive(Debug, Clone)]
+pub struct DictSettings {
+    pub samples: usize,      // Payloads je Training
+    pub dict_kb: usize,      // Zielgröße des Dictionaries
+    pub retrain_blocks: u64, // Neu trainieren nach so vielen Blöcken (0 = nur einmal)
+}
+
+/// Sammelt Payloads und hält das aktuelle Dictionary einer Chain
+pub struct DictTrainer {
+    output_dir: PathBuf,
+    fs: Arc<DurableFs>,
+    settings: DictSettings,
+    samples: Mutex<VecDeque<Vec<u8>>>,
+    since_training: AtomicU64,
+    training: AtomicBool,
+    current: RwLock<Option<Arc<ZstdDict>>>,
+}
+
+impl DictTrainer {
+    pub fn open(output_dir: &Path, fs: Arc<DurableFs>, settings: DictSettings) -> Self {
+        let current = load_dir(output_dir);
+        match current {
+            Some(ref dict) => info!("📚 zstd dictionary {:06x} ({} KiB)", dict.id, dict.data.len() / 1024),
+            None => info!("📚 zstd dictionary: training after {} payloads", settings.samples),
+        }
+        Self {
+            output_dir: output_dir.to_path_buf(),
+            fs,
+            samples: Mutex::new(VecDeque::with_capacity(settings.samples)),
+            settings,
+            since_training: AtomicU64::new(0),
+            training: AtomicBool::new(false),
+            current: RwLock::new(current),
+        }
+    }
+
+    /// Dictionary für den nächsten Block (None = noch nicht trainiert)
+    pub fn current(&self) -> Option<Arc<ZstdDict>> {
+        self.current.read().unwrap().clone()
+    }
+
+    /// Merkt einen Payload für das Training vor und startet es, sobald genug gesammelt sind bzw. das Intervall
+    /// abgelaufen ist (im Blocking-Pool, komprimiert wird solange mit dem bisherigen Dictionary)
+    pub fn observe(self: &Arc<Self>, payload: &[u8]) {
+        if payload.len() <= MAX_SAMPLE_BYTES {
+            let mut samples = self.samples.lock().unwrap();
+            if samples.len() >= self.settings.samples {
+                samples.pop_front();
+            }
+            samples.push_back(payload.to_vec());
+        }
+
+        let since = self.since_training.fetch_add(1, Ordering::Relaxed) + 1;
+        let due = match self.current.read().unwrap().is_some() {
+            false => self.samples.lock().unwrap().len() >= self.settings.samples,
+            true => self.settings.retrain_blocks > 0 && since >= self.settings.retrain_blocks,
+        };
+        if !due || self.training.swap(true, Ordering::AcqRel) {
+            return;
+        }
+
+        let samples: Vec<Vec<u8>> = self.samples.lock().unwrap().iter().cloned().collect();
+        let trainer = self.clone();
+        tokio::task::spawn_blocking(move || {
+            match trainer.train(&samples) {
+                Ok(dict) => {
+                    info!(
+                        "📚 Trained zstd dictionary {:06x} ({} KiB) from {} payloads",
+                        dict.id,
+                        dict.data.len() / 1024,
+                        samples.len()
+                    );
+                    *trainer.current.write().unwrap() = Some(dict);
+                    trainer.since_training.store(0, Ordering::Relaxed);
+                }
+                Err(e) => warn!("⚠️  zstd dictionary training failed: {}", e),
+            }
+            trainer.training.store(false, Ordering::Release);
+        });
+    }
+
+    /// Trainiert, speichert und registriert ein neues Dictionary
+    fn train(&self, samples: &[Vec<u8>]) -> Result<Arc<ZstdDict>, Box<dyn std::error::Error + Send + Sync>> {
+        let data = zstd::dict::from_samples(samples, self.settings.dict_kb * 1024)?;
+
+        // ID aus dem Inhalt, bei Kollision mit einem anderen Dictionary (andere Chain im Prozess) weiterzählen
+        let mut id = (crc32fast::hash(&data) & MAX_DICT_ID).max(1);
+        let dict = loop {
+            let mut dictionaries = registry().write().unwrap();
+            match dictionaries.get(&id) {
+                Some(existing) if existing.data != data => id = (id % MAX_DICT_ID) + 1,
+                Some(existing) => break existing.clone(),
+                None => {
+                    let dict = Arc::new(ZstdDict { id, data });
+                    dictionaries.insert(id, dict.clone());
+                    break dict;
+                }
+            }
+        };
+
+        // Erst nach dem Schreiben verwenden, sonst wären Dateien nach einem Absturz nicht mehr lesbar
+        let dir = self.output_dir.join(DICT_DIR_NAME);
+        fs::create_dir_all(&dir)?;
+        perms::apply_dir(&dir);
+        let path = dir.join(dict_filename(dict.id));
+        self.fs.write_atomic_blocking(&path.with_extension("dict.tmp"), &path, &dict.data)?;
+        Ok(dict)
+    }
+}
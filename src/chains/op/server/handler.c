@@ -3,6 +3,7 @@
 #include "kona_preconf_capture.h"
 #include "logger.h"
 #include "op_conf.h"
+#include "preconf_shm.h"
 #include "util/bytes.h"
 #include <libgen.h>
 #include <limits.h>
@@ -51,6 +52,7 @@ void op_server_shutdown(http_server_t* server) {
 
   // Stop Kona preconf capture if running
   stop_kona_preconf_capture();
+  preconf_shm_close();
 
   log_info("✅ OP server handler shutdown complete");
 }
@@ -144,6 +146,114 @@ void op_server_metrics(http_server_t* server, buffer_t* data) {
 
     bprintf(data, "\n");
   }
+
+  // Speicher-Metriken (Belegung des Output-Verzeichnisses, Löschungen)
+  KonaBridgeStorageStats storage = {0};
+  if (get_kona_preconf_capture_storage_stats(&storage) == 0) {
+    uint32_t chain_id = (uint32_t) server->chain_id;
+    bprintf(data, "# HELP colibri_op_preconf_storage_entries Number of preconfirmations stored on disk.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_storage_entries gauge\n");
+    bprintf(data, "colibri_op_preconf_storage_entries{chain_id=\"%d\"} %l\n", chain_id, storage.entries);
+
+    bprintf(data, "# HELP colibri_op_preconf_storage_bytes Disk space used by stored preconfirmations.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_storage_bytes gauge\n");
+    bprintf(data, "colibri_op_preconf_storage_bytes{chain_id=\"%d\"} %l\n", chain_id, storage.total_bytes);
+
+    bprintf(data, "# HELP colibri_op_preconf_storage_block Oldest and newest stored block number.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_storage_block gauge\n");
+    bprintf(data, "colibri_op_preconf_storage_block{chain_id=\"%d\",edge=\"oldest\"} %l\n", chain_id, storage.oldest_block);
+    bprintf(data, "colibri_op_preconf_storage_block{chain_id=\"%d\",edge=\"newest\"} %l\n", chain_id, storage.newest_block);
+
+    bprintf(data, "# HELP colibri_op_preconf_storage_age_seconds Age of the oldest and newest stored block.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_storage_age_seconds gauge\n");
+    bprintf(data, "colibri_op_preconf_storage_age_seconds{chain_id=\"%d\",edge=\"oldest\"} %l\n", chain_id, storage.oldest_age_secs);
+    bprintf(data, "colibri_op_preconf_storage_age_seconds{chain_id=\"%d\",edge=\"newest\"} %l\n", chain_id, storage.newest_age_secs);
+
+    bprintf(data, "# HELP colibri_op_preconf_stored_total Preconfirmations written to disk, by source.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_stored_total counter\n");
+    bprintf(data, "colibri_op_preconf_stored_total{chain_id=\"%d\",source=\"http\"} %l\n", chain_id, storage.stored_http);
+    bprintf(data, "colibri_op_preconf_stored_total{chain_id=\"%d\",source=\"gossip\"} %l\n", chain_id, storage.stored_gossip);
+    bprintf(data, "colibri_op_preconf_stored_total{chain_id=\"%d\",source=\"other\"} %l\n", chain_id, storage.stored_other);
+
+    bprintf(data, "# HELP colibri_op_preconf_evicted_total Stored blocks deleted by retention, by reason.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_evicted_total counter\n");
+    bprintf(data, "colibri_op_preconf_evicted_total{chain_id=\"%d\",reason=\"ttl\"} %l\n", chain_id, storage.evicted_ttl);
+    bprintf(data, "colibri_op_preconf_evicted_total{chain_id=\"%d\",reason=\"quota\"} %l\n", chain_id, storage.evicted_quota);
+    bprintf(data, "colibri_op_preconf_evicted_total{chain_id=\"%d\",reason=\"finality\"} %l\n", chain_id, storage.evicted_finality);
+
+    bprintf(data, "# HELP colibri_op_preconf_invalid_signatures_total Preconfirmations signed by a signer that is not accepted.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_invalid_signatures_total counter\n");
+    bprintf(data, "colibri_op_preconf_invalid_signatures_total{chain_id=\"%d\"} %l\n", chain_id, storage.invalid_signatures);
+
+    bprintf(data, "# HELP colibri_op_preconf_equivocations_total Blocks for which the sequencer signed two different payloads.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_equivocations_total counter\n");
+    bprintf(data, "colibri_op_preconf_equivocations_total{chain_id=\"%d\"} %l\n", chain_id, storage.equivocations);
+    bprintf(data, "# HELP colibri_op_preconf_oversized_payloads_total Payloads dropped for exceeding the size limit.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_oversized_payloads_total counter\n");
+    bprintf(data, "colibri_op_preconf_oversized_payloads_total{chain_id=\"%d\"} %l\n", chain_id, storage.oversized_payloads);
+    bprintf(data, "# HELP colibri_op_preconf_gossip_dropped_total Gossip messages dropped because the write stage could not keep up.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_gossip_dropped_total counter\n");
+    bprintf(data, "colibri_op_preconf_gossip_dropped_total{chain_id=\"%d\"} %l\n", chain_id, storage.gossip_dropped);
+    bprintf(data, "# HELP colibri_op_preconf_sampled_out_total Verified blocks not stored because of the sampling mode.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_sampled_out_total counter\n");
+    bprintf(data, "colibri_op_preconf_sampled_out_total{chain_id=\"%d\"} %l\n", chain_id, storage.sampled_out);
+    bprintf(data, "# HELP colibri_op_preconf_reorgs_total Stored blocks replaced by a block with the same height and a different hash.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_reorgs_total counter\n");
+    bprintf(data, "colibri_op_preconf_reorgs_total{chain_id=\"%d\"} %l\n", chain_id, storage.reorgs);
+    bprintf(data, "# HELP colibri_op_preconf_gaps_total Captured blocks that skipped ahead of the expected next block beyond the gap tolerance.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_gaps_total counter\n");
+    bprintf(data, "colibri_op_preconf_gaps_total{chain_id=\"%d\"} %l\n", chain_id, storage.gaps);
+    bprintf(data, "# HELP colibri_op_preconf_backwards_total Captured blocks behind the highest captured block beyond the gap tolerance.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_backwards_total counter\n");
+    bprintf(data, "colibri_op_preconf_backwards_total{chain_id=\"%d\"} %l\n", chain_id, storage.backwards);
+    bprintf(data, "# HELP colibri_op_preconf_dns_failures_total Failed DNS lookups of the HTTP preconf endpoint, including those served from the address cache.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_dns_failures_total counter\n");
+    bprintf(data, "colibri_op_preconf_dns_failures_total{chain_id=\"%d\"} %l\n", chain_id, storage.dns_failures);
+    bprintf(data, "# HELP colibri_op_preconf_canonical_matched_total Stored preconfs whose block hash matches the canonical L2 block.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_canonical_matched_total counter\n");
+    bprintf(data, "colibri_op_preconf_canonical_matched_total{chain_id=\"%d\"} %l\n", chain_id, storage.canonical_matched);
+    bprintf(data, "# HELP colibri_op_preconf_canonical_mismatched_total Stored preconfs that differ from the canonical L2 block.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_canonical_mismatched_total counter\n");
+    bprintf(data, "colibri_op_preconf_canonical_mismatched_total{chain_id=\"%d\"} %l\n", chain_id, storage.canonical_mismatched);
+    bprintf(data, "# HELP colibri_op_preconf_canonical_tx_mismatched_total Mismatching preconfs whose transaction set also differs.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_canonical_tx_mismatched_total counter\n");
+    bprintf(data, "colibri_op_preconf_canonical_tx_mismatched_total{chain_id=\"%d\"} %l\n", chain_id, storage.canonical_tx_mismatched);
+
+    // Preconf-Accuracy erst nach dem ersten Vergleich (sonst wäre 0 irreführend)
+    uint64_t compared = storage.canonical_matched + storage.canonical_mismatched;
+    if (compared > 0) {
+      bprintf(data, "# HELP colibri_op_preconf_accuracy Share of compared preconfs that match the canonical L2 block.\n");
+      bprintf(data, "# TYPE colibri_op_preconf_accuracy gauge\n");
+      bprintf(data, "colibri_op_preconf_accuracy{chain_id=\"%d\"} %f\n", chain_id, (double) storage.canonical_matched / (double) compared);
+    }
+
+    bprintf(data, "\n");
+  }
+
+  // Histogramme: Abstand zwischen gespeicherten Preconfs (Sequencer-Verlangsamung) und Payload-Größe (Wachstum)
+  KonaBridgeHistograms histograms = {0};
+  if (get_kona_preconf_capture_histograms(&histograms) == 0) {
+    uint32_t chain_id = (uint32_t) server->chain_id;
+    bprintf(data, "# HELP colibri_op_preconf_arrival_interval_seconds Time between two stored preconfirmations.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_arrival_interval_seconds histogram\n");
+    for (int i = 0; i < KONA_BRIDGE_HISTOGRAM_BUCKETS; i++)
+      bprintf(data, "colibri_op_preconf_arrival_interval_seconds_bucket{chain_id=\"%d\",le=\"%f\"} %l\n", chain_id,
+              (double) histograms.interval_bounds_ms[i] / 1000.0, histograms.interval_buckets[i]);
+    bprintf(data, "colibri_op_preconf_arrival_interval_seconds_bucket{chain_id=\"%d\",le=\"+Inf\"} %l\n", chain_id, histograms.interval_count);
+    bprintf(data, "colibri_op_preconf_arrival_interval_seconds_sum{chain_id=\"%d\"} %f\n", chain_id, (double) histograms.interval_sum_ms / 1000.0);
+    bprintf(data, "colibri_op_preconf_arrival_interval_seconds_count{chain_id=\"%d\"} %l\n", chain_id, histograms.interval_count);
+
+    bprintf(data, "# HELP colibri_op_preconf_payload_size_bytes Uncompressed payload size of stored preconfirmations.\n");
+    bprintf(data, "# TYPE colibri_op_preconf_payload_size_bytes histogram\n");
+    for (int i = 0; i < KONA_BRIDGE_HISTOGRAM_BUCKETS; i++)
+      bprintf(data, "colibri_op_preconf_payload_size_bytes_bucket{chain_id=\"%d\",le=\"%l\"} %l\n", chain_id,
+              histograms.size_bounds_bytes[i], histograms.size_buckets[i]);
+    bprintf(data, "colibri_op_preconf_payload_size_bytes_bucket{chain_id=\"%d\",le=\"+Inf\"} %l\n", chain_id, histograms.size_count);
+    bprintf(data, "colibri_op_preconf_payload_size_bytes_sum{chain_id=\"%d\"} %l\n", chain_id, histograms.size_sum_bytes);
+    bprintf(data, "colibri_op_preconf_payload_size_bytes_count{chain_id=\"%d\"} %l\n", chain_id, histograms.size_count);
+
+    bprintf(data, "\n");
+  }
 #else
   // Kona-Bridge not available - add placeholder metrics
   bprintf(data, "# HELP colibri_op_preconf_peers Connected peers in the OP preconf network.\n");
@@ -2,6 +2,7 @@
 #include "kona_preconf_capture.h"
 #include "../verifier/op_chains_conf.h"
 #include "logger.h"
+#include "op_conf.h"
 #include <pthread.h>
 #include <stdlib.h>
 #include <time.h>
@@ -32,6 +33,11 @@ static void kona_worker_thread(uv_work_t* req) {
   // Set Rust tracing level for better debugging
   setenv("RUST_LOG", "kona_bridge=info,warn", 1);
 
+  // Durability-Modus wird wie die übrigen optionalen Bridge-Einstellungen per Umgebung übergeben
+  if (op_config.preconf_durability) setenv("KONA_BRIDGE_DURABILITY", op_config.preconf_durability, 1);
+  if (op_config.preconf_profile) setenv("KONA_BRIDGE_PROFILE", op_config.preconf_profile, 1);
+  if (op_config.preconf_max_memory) setenv("KONA_BRIDGE_MAX_MEMORY", op_config.preconf_max_memory, 1);
+
   // Initialize Rust logging explicitly
   log_info("🦀 Initializing Rust logging...");
   kona_bridge_init_logging();
@@ -209,3 +215,19 @@ int get_kona_preconf_capture_stats(KonaBridgeStats* stats) {
   // Hole echte Statistiken von der Rust-Bridge
   return kona_bridge_get_stats(g_kona_worker->bridge_handle, stats);
 }
+
+int get_kona_preconf_capture_storage_stats(KonaBridgeStorageStats* stats) {
+  if (g_kona_worker == NULL || !stats || g_kona_worker->bridge_handle == NULL) {
+    return -1;
+  }
+
+  return kona_bridge_get_storage_stats(g_kona_worker->bridge_handle, stats);
+}
+
+int get_kona_preconf_capture_histograms(KonaBridgeHistograms* histograms) {
+  if (g_kona_worker == NULL || !histograms || g_kona_worker->bridge_handle == NULL) {
+    return -1;
+  }
+
+  return kona_bridge_get_histograms(g_kona_worker->bridge_handle, histograms);
+}
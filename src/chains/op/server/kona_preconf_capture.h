@@ -44,6 +44,22 @@ bool is_kona_preconf_capture_running(void);
  */
 int get_kona_preconf_capture_stats(KonaBridgeStats* stats);
 
+/**
+ * Gibt Speicher-Statistiken des Preconf-Stores zurück
+ *
+ * @param stats Zeiger auf KonaBridgeStorageStats-Struktur
+ * @return 0 bei Erfolg, -1 bei Fehler
+ */
+int get_kona_preconf_capture_storage_stats(KonaBridgeStorageStats* stats);
+
+/**
+ * Gibt die Histogramme der Ankunftsabstände und Payload-Größen zurück
+ *
+ * @param histograms Zeiger auf KonaBridgeHistograms-Struktur
+ * @return 0 bei Erfolg, -1 bei Fehler
+ */
+int get_kona_preconf_capture_histograms(KonaBridgeHistograms* histograms);
+
 #ifdef __cplusplus
 }
 #endif
@@ -15,6 +15,9 @@ void op_configure() {
   conf_string(&op_config.preconf_storage_dir, "PRECONF_DIR", "preconf_dir", 'P', "directory for storing preconfirmations");
   conf_int(&op_config.preconf_ttl_minutes, "PRECONF_TTL", "preconf_ttl", 'T', "TTL for preconfirmations in minutes", 1, 1440);
   conf_int(&op_config.preconf_cleanup_interval_minutes, "PRECONF_CLEANUP_INTERVAL", "preconf_cleanup_interval", 'C', "cleanup interval in minutes", 1, 60);
+  conf_string(&op_config.preconf_durability, "PRECONF_DURABILITY", "preconf_durability", 0, "fsync mode for preconf files: none, flush, fsync-each or group");
+  conf_string(&op_config.preconf_profile, "PRECONF_PROFILE", "preconf_profile", 0, "resource profile of the preconf bridge: default or tiny (edge devices)");
+  conf_string(&op_config.preconf_max_memory, "PRECONF_MAX_MEMORY", "preconf_max_memory", 0, "memory budget of the preconf bridge, e.g. 512M or 2G (sizes caches and queues)");
 
   http_server.prover_flags |= C4_PROVER_FLAG_USE_ACCESSLIST;
 }
\ No newline at end of file
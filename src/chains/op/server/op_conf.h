@@ -7,6 +7,9 @@ typedef struct {
   char* preconf_storage_dir;
   int   preconf_ttl_minutes;
   int   preconf_cleanup_interval_minutes;
+  char* preconf_durability; // none|flush|fsync-each|group (NULL = Default der Bridge)
+  char* preconf_profile;    // default|tiny (NULL = Default der Bridge)
+  char* preconf_max_memory; // Speicherbudget, z.B. 512M oder 2G (NULL = unbegrenzt)
   // preconf_use_gossip removed - now using automatic HTTP fallback until gossip is active
 
 } op_config_t;
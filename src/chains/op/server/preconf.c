@@ -1,19 +1,209 @@
 #include "handler.h"
 #include "op_conf.h"
+#include "preconf_shm.h"
+#include "preconf_socket.h"
 #include "uv_util.h"
+#include <sys/stat.h>
+#include <zstd.h>
+
+// MSVC kennt nur die S_IF*-Konstanten
+#ifndef S_ISDIR
+#define S_ISDIR(m) (((m) & S_IFMT) == S_IFDIR)
+#endif
+
+// Container-Format der Bridge (siehe kona_bridge/src/codec.rs)
+#define PRECONF_SIGNATURE_SIZE 65
+#define PRECONF_V1_HEADER_SIZE 12
+#define PRECONF_V2_HEADER_SIZE 104
+#define PRECONF_V2_CRC_OFFSET  100
+#define PRECONF_CODEC_NONE     0
+#define PRECONF_CODEC_ZSTD     2
+#define PRECONF_FLAG_ZSTD_DICT 0x01
+#define PRECONF_FLAG_DELTA     0x02
+// Header-Offsets (v1: decompressed_size bei 8, v2 siehe codec.rs)
+#define PRECONF_V1_SIZE_OFFSET     8
+#define PRECONF_V2_SIZE_OFFSET     24
+#define PRECONF_V2_BODY_LEN_OFFSET 28
+// Obergrenze für decompressed_size (Payloads sind weit kleiner, siehe KONA_BRIDGE_MAX_PAYLOAD_KB)
+#define PRECONF_MAX_DECOMPRESSED_SIZE (64u * 1024 * 1024)
+
+static uint32_t preconf_crc32(uint32_t crc, const uint8_t* data, size_t len) {
+  crc = ~crc;
+  for (size_t i = 0; i < len; i++) {
+    crc ^= data[i];
+    for (int k = 0; k < 8; k++) crc = (crc >> 1) ^ (0xEDB88320 & (0 - (crc & 1)));
+  }
+  return ~crc;
+}
+
+static inline uint32_t preconf_le32(const uint8_t* p) {
+  return (uint32_t) p[0] | ((uint32_t) p[1] << 8) | ((uint32_t) p[2] << 16) | ((uint32_t) p[3] << 24);
+}
+
+static inline uint64_t preconf_le64(const uint8_t* p) {
+  return (uint64_t) preconf_le32(p) | ((uint64_t) preconf_le32(p + 4) << 32);
+}
+
+// Wandelt eine .raw Datei in zstd-Payload + Signatur, wie der Prover sie erwartet.
+// Dateien ohne Container-Header (ältere Bridge) bleiben unverändert. Gibt im Fehlerfall eine Meldung zurück.
+static char* c4_preconf_to_legacy(bytes_t* data, chain_id_t chain_id) {
+  if (data->len < 5 || memcmp(data->data, "KPRC", 4)) return NULL;
+
+  // Erst wenn der vollständige Header der Version vorliegt, werden seine Felder gelesen
+  uint8_t  version           = data->data[4];
+  uint8_t  codec             = 0;
+  uint32_t decompressed_size = 0;
+  bytes_t  body              = NULL_BYTES;
+  uint8_t* sig               = NULL;
+  if (version == 2) {
+    if (data->len < PRECONF_V2_HEADER_SIZE) return strdup("Truncated preconf container");
+    uint32_t body_len = preconf_le32(data->data + PRECONF_V2_BODY_LEN_OFFSET);
+    if ((uint64_t) data->len != (uint64_t) PRECONF_V2_HEADER_SIZE + body_len)
+      return strdup("Preconf container length mismatch");
+    uint32_t crc = preconf_crc32(0, data->data, PRECONF_V2_CRC_OFFSET);
+    crc          = preconf_crc32(crc, data->data + PRECONF_V2_HEADER_SIZE, body_len);
+    if (crc != preconf_le32(data->data + PRECONF_V2_CRC_OFFSET)) return strdup("Preconf container CRC mismatch");
+    uint64_t stored_chain = preconf_le64(data->data + 8);
+    if (stored_chain != (uint64_t) chain_id) return bprintf(NULL, "Preconf belongs to chain %l", stored_chain);
+    // mit Dictionary komprimiert: nur die Bridge kennt das Dictionary
+    if (data->data[7] & PRECONF_FLAG_ZSTD_DICT)
+      return strdup("Preconf uses a zstd dictionary and requires the bridge query socket (KONA_BRIDGE_QUERY=1)");
+    // Delta gegen den Vorgänger: die Basis rekonstruiert nur die Bridge
+    if (data->data[7] & PRECONF_FLAG_DELTA)
+      return strdup("Preconf is stored as delta and requires the bridge query socket (KONA_BRIDGE_QUERY=1)");
+    codec             = data->data[5];
+    decompressed_size = preconf_le32(data->data + PRECONF_V2_SIZE_OFFSET);
+    body              = bytes(data->data + PRECONF_V2_HEADER_SIZE, body_len);
+    sig               = data->data + 32;
+  }
+  else if (version == 1) {
+    if (data->len < PRECONF_V1_HEADER_SIZE + PRECONF_SIGNATURE_SIZE) return strdup("Truncated preconf container");
+    codec             = data->data[5];
+    decompressed_size = preconf_le32(data->data + PRECONF_V1_SIZE_OFFSET);
+    body              = bytes(data->data + PRECONF_V1_HEADER_SIZE, data->len - PRECONF_V1_HEADER_SIZE - PRECONF_SIGNATURE_SIZE);
+    sig               = body.data + body.len;
+  }
+  else
+    return bprintf(NULL, "Unsupported preconf container version %d", (uint32_t) version);
+
+  // decompressed_size muss zum Body passen, bevor der Prover den Payload entpackt
+  if (decompressed_size > PRECONF_MAX_DECOMPRESSED_SIZE)
+    return bprintf(NULL, "Preconf payload too large: %d bytes", decompressed_size);
+  if (codec == PRECONF_CODEC_NONE && decompressed_size != body.len)
+    return strdup("Preconf payload size mismatch");
+  if (codec == PRECONF_CODEC_ZSTD) {
+    unsigned long long frame_size = ZSTD_getFrameContentSize(body.data, body.len);
+    if (frame_size == ZSTD_CONTENTSIZE_ERROR) return strdup("Invalid zstd frame in preconf container");
+    if (frame_size != ZSTD_CONTENTSIZE_UNKNOWN && frame_size != decompressed_size)
+      return strdup("Preconf payload size mismatch");
+  }
+
+  bytes_t out = NULL_BYTES;
+  switch (codec) {
+    case PRECONF_CODEC_ZSTD:
+      out = bytes(safe_malloc(body.len + PRECONF_SIGNATURE_SIZE), body.len);
+      memcpy(out.data, body.data, body.len);
+      break;
+    case PRECONF_CODEC_NONE: {
+      // unkomprimiert gespeichert -> für den Proof mit zstd (Level 1, schnell) komprimieren
+      size_t bound = ZSTD_compressBound(body.len);
+      out.data     = safe_malloc(bound + PRECONF_SIGNATURE_SIZE);
+      size_t len   = ZSTD_compress(out.data, bound, body.data, body.len, 1);
+      if (ZSTD_isError(len)) {
+        safe_free(out.data);
+        return bprintf(NULL, "zstd compression failed: %s", ZSTD_getErrorName(len));
+      }
+      out.len = (uint32_t) len;
+      break;
+    }
+    default:
+      return bprintf(NULL, "Preconf codec %d requires the bridge query socket (KONA_BRIDGE_QUERY=1)", (uint32_t) codec);
+  }
+
+  memcpy(out.data + out.len, sig, PRECONF_SIGNATURE_SIZE);
+  out.len += PRECONF_SIGNATURE_SIZE;
+  safe_free(data->data);
+  *data = out;
+  return NULL;
+}
 
 static void c4_handle_preconf_cb(void* user_data, file_data_t* files, int num_files) {
   single_request_t* r = (single_request_t*) user_data;
   if (files[0].error)
     r->req->error = strdup(files[0].error);
-  else {
+  else if ((r->req->error = c4_preconf_to_legacy(&files[0].data, r->req->chain_id)) == NULL) {
     r->req->response   = files[0].data;
     files[0].data.data = NULL;
   }
-  c4_file_data_array_free(files, num_files, 0);
+  c4_file_data_array_free(files, num_files, 1);
   c4_internal_call_finish(r);
 }
 
+// Dateiname aus latest.json der Bridge (für Plattformen/Dateisysteme ohne latest.raw Symlink)
+static char* c4_preconf_latest_from_json(const char* dir, uint32_t back) {
+  char*   json_path = bprintf(NULL, "%s/latest.json", dir);
+  bytes_t content   = bytes_read(json_path);
+  char*   file_name = NULL;
+  safe_free(json_path);
+  if (!content.data) return NULL;
+
+  json_t value = json_get(json_parse((char*) content.data), back ? "pre_latest" : "latest");
+  if (value.type == JSON_TYPE_STRING) {
+    char* name = json_as_string(value, NULL);
+    file_name  = bprintf(NULL, "%s/%s", dir, name);
+    safe_free(name);
+  }
+  safe_free(content.data);
+  return file_name;
+}
+
+// Mit KONA_BRIDGE_CHAIN_SUBDIRS liegen die Dateien der Chain unter {preconf_storage_dir}/{chain_id}
+static char* c4_preconf_dir(chain_id_t chain_id) {
+  char*       dir = bprintf(NULL, "%s/%l", op_config.preconf_storage_dir, (uint64_t) chain_id);
+  struct stat st;
+  if (stat(dir, &st) == 0 && S_ISDIR(st.st_mode)) return dir;
+  safe_free(dir);
+  return strdup(op_config.preconf_storage_dir);
+}
+
+// Pfad einer Datei der Bridge: wie dort aus der Umgebung (KONA_BRIDGE_SHM_PATH, KONA_BRIDGE_QUERY_SOCKET), ein
+// relativer Pfad liegt im Verzeichnis der Chain; ohne Einstellung der Default-Name im Verzeichnis
+static char* c4_preconf_bridge_path(const char* dir, const char* env_name, const char* default_name) {
+  const char* value = getenv(env_name);
+  if (!value || !*value) value = default_name;
+  if (value[0] == '/') return strdup(value);
+  return bprintf(NULL, "%s/%s", dir, value);
+}
+
+typedef struct {
+  single_request_t* r;
+  char*             file_name; // Fallback auf das Dateilayout (NULL = kein Fallback möglich)
+} preconf_query_ctx_t;
+
+static void c4_read_preconf_file(single_request_t* r, char* file_name) {
+  file_data_t f = {.path = file_name};
+  c4_read_files_uv(r, c4_handle_preconf_cb, &f, 1);
+}
+
+static void c4_handle_preconf_socket_cb(void* user_data, uint8_t status, bytes_t body) {
+  preconf_query_ctx_t* ctx = (preconf_query_ctx_t*) user_data;
+  single_request_t*    r   = ctx->r;
+  char*                fn  = ctx->file_name;
+  safe_free(ctx);
+
+  if (status == PRECONF_SOCKET_OK) {
+    safe_free(fn);
+    r->req->response = body;
+    c4_internal_call_finish(r);
+  }
+  else if (fn)
+    c4_read_preconf_file(r, fn);
+  else {
+    r->req->error = strdup(status == PRECONF_SOCKET_NOT_FOUND ? "Preconf not found" : "Preconf query socket not available");
+    c4_internal_call_finish(r);
+  }
+}
+
 bool c4_handle_preconf(single_request_t* r) {
   const char* path = "preconf/";
   if (strncmp(r->req->url, path, strlen(path))) return false;
@@ -23,19 +213,79 @@ bool c4_handle_preconf(single_request_t* r) {
     return true;
   }
 
-  char* block_identifier = r->req->url + strlen(path);
-  char* file_name        = NULL;
-  if (strcmp(block_identifier, "latest") == 0 || strcmp(block_identifier, "pre_latest") == 0)
-    file_name = bprintf(NULL, "%s/%s.raw", op_config.preconf_storage_dir, block_identifier);
-  else if (strncmp(block_identifier, "0x", 2) == 0 || strncmp(block_identifier, "0X", 2) == 0)
-    file_name = bprintf(NULL, "%s/block_%l_%l.raw", op_config.preconf_storage_dir, r->req->chain_id, strtoull(block_identifier + 2, NULL, 16));
+  char*   dir              = c4_preconf_dir(r->req->chain_id);
+  char*   block_identifier = r->req->url + strlen(path);
+  char*   file_name        = NULL;
+  uint8_t op               = 0;
+  uint8_t arg_data[32]     = {0};
+  bytes_t arg              = NULL_BYTES;
+  if (strcmp(block_identifier, "latest") == 0 || strcmp(block_identifier, "pre_latest") == 0) {
+    // Shared-Memory-Index der Bridge bevorzugen (keine Symlink-Races), sonst latest.raw bzw. latest.json
+    preconf_shm_entry_t entry    = {0};
+    uint32_t            back     = block_identifier[0] == 'p' ? 1 : 0;
+    char*               shm_path = c4_preconf_bridge_path(dir, "KONA_BRIDGE_SHM_PATH", PRECONF_SHM_FILE_NAME);
+    bool                from_shm = preconf_shm_get(shm_path, r->req->chain_id, back, &entry);
+    struct stat         st;
+    op                           = back ? PRECONF_SOCKET_OP_PRE_LATEST : PRECONF_SOCKET_OP_LATEST;
+    safe_free(shm_path);
+    if (from_shm)
+      file_name = bprintf(NULL, "%s/%s", dir, entry.filename);
+    else {
+      file_name = bprintf(NULL, "%s/%s.raw", dir, block_identifier);
+      if (stat(file_name, &st) != 0) {
+        char* json_file_name = c4_preconf_latest_from_json(dir, back);
+        if (json_file_name) {
+          safe_free(file_name);
+          file_name = json_file_name;
+        }
+      }
+    }
+  }
+  else if ((strncmp(block_identifier, "0x", 2) == 0 || strncmp(block_identifier, "0X", 2) == 0) && strlen(block_identifier) == 66) {
+    // Block-Hash: nur über den Query-Socket auflösbar
+    if (hex_to_bytes(block_identifier + 2, 64, bytes(arg_data, 32)) != 32) {
+      r->req->error = bprintf(NULL, "Invalid block hash: %s", block_identifier);
+      safe_free(dir);
+      c4_internal_call_finish(r);
+      return true;
+    }
+    op  = PRECONF_SOCKET_OP_BY_HASH;
+    arg = bytes(arg_data, 32);
+  }
+  else if (strncmp(block_identifier, "0x", 2) == 0 || strncmp(block_identifier, "0X", 2) == 0) {
+    uint64_t block_number = strtoull(block_identifier + 2, NULL, 16);
+    file_name             = bprintf(NULL, "%s/block_%l_%l.raw", dir, r->req->chain_id, block_number);
+    op                    = PRECONF_SOCKET_OP_BY_NUMBER;
+    uint64_to_be(arg_data, block_number);
+    arg = bytes(arg_data, 8);
+  }
   else {
     r->req->error = bprintf(NULL, "Invalid block identifier: %s", block_identifier);
+    safe_free(dir);
     c4_internal_call_finish(r);
     return true;
   }
 
-  file_data_t f = {.path = file_name};
-  c4_read_files_uv(r, c4_handle_preconf_cb, &f, 1);
+  // Query-Socket der Bridge bevorzugen, falls vorhanden (Fallback: Dateilayout)
+  char*       socket_path = c4_preconf_bridge_path(dir, "KONA_BRIDGE_QUERY_SOCKET", PRECONF_SOCKET_FILE_NAME);
+  struct stat st;
+  safe_free(dir);
+  if (stat(socket_path, &st) == 0) {
+    preconf_query_ctx_t* ctx = (preconf_query_ctx_t*) safe_calloc(1, sizeof(preconf_query_ctx_t));
+    ctx->r                   = r;
+    ctx->file_name           = file_name;
+    preconf_socket_query(socket_path, op, arg, ctx, c4_handle_preconf_socket_cb);
+    safe_free(socket_path);
+    return true;
+  }
+  safe_free(socket_path);
+
+  if (!file_name) {
+    r->req->error = strdup("Preconf lookup by hash requires the bridge query socket");
+    c4_internal_call_finish(r);
+    return true;
+  }
+
+  c4_read_preconf_file(r, file_name);
   return true;
-}
\ No newline at end of file
+}
@@ -0,0 +1,170 @@
+// preconf_shm.c - Seqlock-Leser für den mmap-Ringpuffer der Kona-Bridge (siehe kona_bridge/src/shm.rs)
+#include "preconf_shm.h"
+#include "logger.h"
+#include <stdio.h>
+#include <string.h>
+
+#ifndef _WIN32
+#include <fcntl.h>
+#include <sys/mman.h>
+#include <sys/stat.h>
+#include <unistd.h>
+
+#define SHM_MAGIC        0x4B53484Du // "KSHM"
+#define SHM_VERSION      1
+#define SHM_HEADER_SIZE  64
+#define SHM_SLOT_SIZE    128
+#define SHM_READ_RETRIES 8
+
+// Offsets im Header
+#define HDR_MAGIC     0
+#define HDR_VERSION   4
+#define HDR_CHAIN_ID  8
+#define HDR_CAPACITY  16
+#define HDR_SLOT_SIZE 20
+#define HDR_HEAD      24
+#define HDR_LATEST    32
+
+// Offsets im Slot
+#define SLOT_SEQ          0
+#define SLOT_BLOCK_NUMBER 8
+#define SLOT_BLOCK_HASH   16
+#define SLOT_RECEIVED     48
+#define SLOT_FILE_SIZE    56
+#define SLOT_FILENAME     64
+
+static uint8_t* shm_ptr = NULL;
+static size_t   shm_len = 0;
+static uint32_t shm_cap = 0;
+static uint64_t shm_dev = 0;
+static uint64_t shm_ino = 0;
+static uint64_t shm_chain_id = 0;
+static char     shm_path[1024];
+
+static inline uint64_t load_u64(size_t offset) {
+  return __atomic_load_n((uint64_t*) (shm_ptr + offset), __ATOMIC_ACQUIRE);
+}
+
+static inline uint32_t read_u32(size_t offset) {
+  uint32_t v;
+  memcpy(&v, shm_ptr + offset, sizeof(v));
+  return v;
+}
+
+static bool shm_open_index(const char* path, uint64_t chain_id) {
+  struct stat st;
+  if (stat(path, &st) != 0) {
+    preconf_shm_close();
+    return false;
+  }
+  // Bridge hat die Datei neu angelegt oder die Größe geändert, oder eine andere Chain bzw. ein anderes
+  // Verzeichnis wird abgefragt -> neu mappen
+  if (shm_ptr && ((uint64_t) st.st_dev != shm_dev || (uint64_t) st.st_ino != shm_ino || (size_t) st.st_size != shm_len ||
+                  chain_id != shm_chain_id || strcmp(path, shm_path) != 0))
+    preconf_shm_close();
+  if (shm_ptr) return true;
+  if (st.st_size < SHM_HEADER_SIZE) return false;
+
+  int fd = open(path, O_RDONLY);
+  if (fd < 0) return false;
+  void* ptr = mmap(NULL, (size_t) st.st_size, PROT_READ, MAP_SHARED, fd, 0);
+  close(fd);
+  if (ptr == MAP_FAILED) return false;
+
+  shm_ptr = ptr;
+  shm_len = (size_t) st.st_size;
+
+  uint64_t file_chain_id;
+  memcpy(&file_chain_id, shm_ptr + HDR_CHAIN_ID, sizeof(file_chain_id));
+  uint32_t capacity = read_u32(HDR_CAPACITY);
+
+  if (__atomic_load_n((uint32_t*) (shm_ptr + HDR_MAGIC), __ATOMIC_ACQUIRE) != SHM_MAGIC ||
+      read_u32(HDR_VERSION) != SHM_VERSION || read_u32(HDR_SLOT_SIZE) != SHM_SLOT_SIZE ||
+      file_chain_id != chain_id || capacity == 0 ||
+      SHM_HEADER_SIZE + (size_t) capacity * SHM_SLOT_SIZE > shm_len) {
+    log_warn("preconf shm index %s does not match chain %l, ignoring", path, chain_id);
+    preconf_shm_close();
+    return false;
+  }
+
+  shm_cap = capacity;
+  shm_dev = (uint64_t) st.st_dev;
+  shm_ino = (uint64_t) st.st_ino;
+  shm_chain_id = chain_id;
+  snprintf(shm_path, sizeof(shm_path), "%s", path);
+  log_info("🧠 Using preconf shm index %s (%d slots)", path, capacity);
+  return true;
+}
+
+// Liest einen Slot unter dem Seqlock (false = wird gerade geschrieben oder ist leer)
+static bool shm_read_slot(uint64_t index, preconf_shm_entry_t* entry) {
+  size_t slot = SHM_HEADER_SIZE + (size_t) (index % shm_cap) * SHM_SLOT_SIZE;
+  for (int attempt = 0; attempt < SHM_READ_RETRIES; attempt++) {
+    uint64_t seq_a = load_u64(slot + SLOT_SEQ);
+    if (seq_a & 1) continue; // Writer ist gerade in diesem Slot
+
+    memcpy(&entry->block_number, shm_ptr + slot + SLOT_BLOCK_NUMBER, sizeof(entry->block_number));
+    memcpy(entry->block_hash, shm_ptr + slot + SLOT_BLOCK_HASH, 32);
+    memcpy(&entry->received_unix, shm_ptr + slot + SLOT_RECEIVED, sizeof(entry->received_unix));
+    memcpy(&entry->file_size, shm_ptr + slot + SLOT_FILE_SIZE, sizeof(entry->file_size));
+    memcpy(entry->filename, shm_ptr + slot + SLOT_FILENAME, PRECONF_SHM_FILENAME_LEN);
+    entry->filename[PRECONF_SHM_FILENAME_LEN - 1] = 0;
+
+    __atomic_thread_fence(__ATOMIC_ACQUIRE);
+    if (load_u64(slot + SLOT_SEQ) == seq_a) return entry->filename[0] != 0;
+  }
+  return false;
+}
+
+bool preconf_shm_get(const char* path, uint64_t chain_id, uint32_t back, preconf_shm_entry_t* entry) {
+  if (!path || !entry || !shm_open_index(path, chain_id)) return false;
+
+  uint64_t head   = load_u64(HDR_HEAD);
+  uint64_t latest = load_u64(HDR_LATEST);
+  if (head == 0 || back >= shm_cap) return false;
+
+  // Üblicher Fall: der zuletzt veröffentlichte Slot ist der neueste Block
+  if (back == 0 && shm_read_slot(head - 1, entry) && entry->block_number == latest) return true;
+
+  // Nach einem Backfill oder Nachzügler ist der letzte Slot ein älterer Block: den Ring nach der back-ten
+  // Blocknummer (absteigend, höchstens latest) durchsuchen; bei gleicher Nummer gilt der neuere Slot (Reorg)
+  uint64_t            slots = head < shm_cap ? head : shm_cap;
+  uint64_t            bound = latest;
+  bool                found = false;
+  preconf_shm_entry_t candidate;
+  for (uint32_t rank = 0; rank <= back; rank++) {
+    found = false;
+    for (uint64_t i = 0; i < slots; i++) {
+      if (!shm_read_slot(head - 1 - i, &candidate) || candidate.block_number > bound) continue;
+      if (rank > 0 && candidate.block_number == bound) continue;
+      if (!found || candidate.block_number > entry->block_number) {
+        *entry = candidate;
+        found  = true;
+      }
+    }
+    if (!found) return false;
+    bound = entry->block_number;
+  }
+  return found;
+}
+
+void preconf_shm_close(void) {
+  if (shm_ptr) munmap(shm_ptr, shm_len);
+  shm_ptr = NULL;
+  shm_len = 0;
+  shm_cap = 0;
+  shm_dev = 0;
+  shm_ino = 0;
+  shm_chain_id = 0;
+  shm_path[0] = 0;
+}
+
+#else
+
+bool preconf_shm_get(const char* path, uint64_t chain_id, uint32_t back, preconf_shm_entry_t* entry) {
+  return false;
+}
+
+void preconf_shm_close(void) {}
+
+#endif
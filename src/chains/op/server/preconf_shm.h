@@ -0,0 +1,46 @@
+// preconf_shm.h - Lesender Zugriff auf den Shared-Memory-Index der Kona-Bridge
+#pragma once
+
+#include <stdbool.h>
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+// Muss mit kona_bridge/src/shm.rs übereinstimmen
+#define PRECONF_SHM_FILE_NAME    "preconf_index.shm"
+#define PRECONF_SHM_FILENAME_LEN 64
+
+typedef struct {
+  uint64_t block_number;
+  uint8_t  block_hash[32];
+  uint64_t received_unix;
+  uint64_t file_size;
+  char     filename[PRECONF_SHM_FILENAME_LEN];
+} preconf_shm_entry_t;
+
+/**
+ * Liest einen der neuesten von der Bridge gespeicherten Preconfs aus dem Shared-Memory-Index
+ *
+ * Die Index-Datei wird beim ersten Aufruf read-only gemappt. Ist sie nicht vorhanden (KONA_BRIDGE_SHM nicht
+ * aktiv) oder passt sie nicht zur Chain, liefert die Funktion false und der Aufrufer fällt auf die Symlinks zurück.
+ * Maßgeblich ist die Blocknummer, nicht die Reihenfolge im Ring: nach einem Backfill älterer Blöcke bleibt der
+ * höchste Block (latest_block im Header) latest.
+ *
+ * @param path Index-Datei ({dir}/preconf_index.shm bzw. KONA_BRIDGE_SHM_PATH)
+ * @param chain_id Erwartete Chain ID
+ * @param back 0 = latest, 1 = pre_latest, ... (n-höchste Blocknummer im Ring)
+ * @param entry Ausgabe
+ * @return true wenn ein stabiler Eintrag gelesen wurde
+ */
+bool preconf_shm_get(const char* path, uint64_t chain_id, uint32_t back, preconf_shm_entry_t* entry);
+
+/**
+ * Gibt das Mapping wieder frei
+ */
+void preconf_shm_close(void);
+
+#ifdef __cplusplus
+}
+#endif
@@ -0,0 +1,129 @@
+// preconf_socket.c - Asynchroner Client (libuv pipe) für den Query-Socket der Kona-Bridge
+#include "preconf_socket.h"
+#include "logger.h"
+#include <stdlib.h>
+#include <string.h>
+#include <uv.h>
+
+#define QUERY_TIMEOUT_MS   2000
+#define QUERY_MAX_ARG      32
+#define QUERY_MAX_RESPONSE (64 * 1024 * 1024)
+
+typedef struct {
+  uv_pipe_t         pipe;
+  uv_connect_t      connect_req;
+  uv_write_t        write_req;
+  uv_timer_t        timer;
+  uint8_t           request[4 + 1 + QUERY_MAX_ARG];
+  uint32_t          request_len;
+  buffer_t          response;
+  void*             user_data;
+  preconf_socket_cb cb;
+  int               open_handles;
+  bool              done;
+} query_ctx_t;
+
+static void query_on_close(uv_handle_t* handle) {
+  query_ctx_t* ctx = (query_ctx_t*) handle->data;
+  if (--ctx->open_handles > 0) return;
+  buffer_free(&ctx->response);
+  free(ctx);
+}
+
+// Ruft den Callback genau einmal auf und schließt alle Handles
+static void query_finish(query_ctx_t* ctx, uint8_t status, bytes_t body) {
+  if (ctx->done) return;
+  ctx->done = true;
+  ctx->cb(ctx->user_data, status, body);
+  uv_timer_stop(&ctx->timer);
+  uv_close((uv_handle_t*) &ctx->timer, query_on_close);
+  uv_close((uv_handle_t*) &ctx->pipe, query_on_close);
+}
+
+static void query_on_timeout(uv_timer_t* timer) {
+  query_ctx_t* ctx = (query_ctx_t*) timer->data;
+  log_warn("preconf query socket timed out");
+  query_finish(ctx, PRECONF_SOCKET_UNAVAILABLE, NULL_BYTES);
+}
+
+static void query_alloc(uv_handle_t* handle, size_t suggested_size, uv_buf_t* buf) {
+  query_ctx_t* ctx = (query_ctx_t*) handle->data;
+  buffer_grow(&ctx->response, ctx->response.data.len + suggested_size);
+  buf->base = (char*) ctx->response.data.data + ctx->response.data.len;
+  buf->len  = (size_t) ctx->response.allocated - ctx->response.data.len;
+}
+
+static void query_on_read(uv_stream_t* stream, ssize_t nread, const uv_buf_t* buf) {
+  query_ctx_t* ctx = (query_ctx_t*) stream->data;
+  (void) buf;
+  if (nread < 0) {
+    query_finish(ctx, PRECONF_SOCKET_UNAVAILABLE, NULL_BYTES);
+    return;
+  }
+  ctx->response.data.len += (uint32_t) nread;
+  if (ctx->response.data.len < 5) return;
+
+  uint32_t frame_len = (uint32_t) bytes_as_be(bytes(ctx->response.data.data, 4));
+  if (frame_len == 0 || frame_len > QUERY_MAX_RESPONSE) {
+    query_finish(ctx, PRECONF_SOCKET_UNAVAILABLE, NULL_BYTES);
+    return;
+  }
+  if (ctx->response.data.len < 4 + frame_len) return;
+
+  uint8_t status = ctx->response.data.data[4];
+  if (status != PRECONF_SOCKET_OK) {
+    query_finish(ctx, status, NULL_BYTES);
+    return;
+  }
+
+  // Body in einen eigenen Buffer kopieren, der dem Callback gehört
+  bytes_t body = bytes_dup(bytes(ctx->response.data.data + 5, frame_len - 1));
+  query_finish(ctx, PRECONF_SOCKET_OK, body);
+}
+
+static void query_on_write(uv_write_t* req, int status) {
+  query_ctx_t* ctx = (query_ctx_t*) req->data;
+  if (status < 0) {
+    query_finish(ctx, PRECONF_SOCKET_UNAVAILABLE, NULL_BYTES);
+    return;
+  }
+  uv_read_start((uv_stream_t*) &ctx->pipe, query_alloc, query_on_read);
+}
+
+static void query_on_connect(uv_connect_t* req, int status) {
+  query_ctx_t* ctx = (query_ctx_t*) req->data;
+  if (status < 0) {
+    log_debug("preconf query socket unavailable: %s", uv_strerror(status));
+    query_finish(ctx, PRECONF_SOCKET_UNAVAILABLE, NULL_BYTES);
+    return;
+  }
+  uv_buf_t buf        = uv_buf_init((char*) ctx->request, ctx->request_len);
+  ctx->write_req.data = ctx;
+  uv_write(&ctx->write_req, (uv_stream_t*) &ctx->pipe, &buf, 1, query_on_write);
+}
+
+void preconf_socket_query(const char* socket_path, uint8_t op, bytes_t arg, void* user_data, preconf_socket_cb cb) {
+  if (arg.len > QUERY_MAX_ARG) {
+    cb(user_data, PRECONF_SOCKET_BAD_REQUEST, NULL_BYTES);
+    return;
+  }
+
+  query_ctx_t* ctx = (query_ctx_t*) safe_calloc(1, sizeof(query_ctx_t));
+  ctx->user_data   = user_data;
+  ctx->cb          = cb;
+  ctx->request_len = 5 + arg.len;
+  ctx->request[3]  = (uint8_t) (1 + arg.len); // Länge big-endian, passt immer in das letzte Byte
+  ctx->request[4]  = op;
+  if (arg.len) memcpy(ctx->request + 5, arg.data, arg.len);
+
+  uv_loop_t* loop = uv_default_loop();
+  uv_pipe_init(loop, &ctx->pipe, 0);
+  uv_timer_init(loop, &ctx->timer);
+  ctx->pipe.data        = ctx;
+  ctx->timer.data       = ctx;
+  ctx->connect_req.data = ctx;
+  ctx->open_handles     = 2;
+
+  uv_timer_start(&ctx->timer, query_on_timeout, QUERY_TIMEOUT_MS, 0);
+  uv_pipe_connect(&ctx->connect_req, &ctx->pipe, socket_path, query_on_connect);
+}
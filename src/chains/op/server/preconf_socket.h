@@ -0,0 +1,56 @@
+// preconf_socket.h - Client für den Query-Socket der Kona-Bridge (siehe kona_bridge/src/query.rs)
+#pragma once
+
+#include "bytes.h"
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+// Muss mit kona_bridge/src/query.rs übereinstimmen
+#define PRECONF_SOCKET_FILE_NAME "preconf.sock"
+
+#define PRECONF_SOCKET_OP_LATEST     0x01
+#define PRECONF_SOCKET_OP_PRE_LATEST 0x02
+#define PRECONF_SOCKET_OP_BY_NUMBER  0x03
+#define PRECONF_SOCKET_OP_BY_HASH    0x04
+#define PRECONF_SOCKET_OP_BY_TX_HASH 0x05 // Body: Blocknummer (u64 BE) + Transaktionsindex (u32 BE)
+#define PRECONF_SOCKET_OP_BY_ADDRESS 0x06 // Body: n x (Blocknummer u64 BE + Transaktionsindex u32 BE)
+#define PRECONF_SOCKET_OP_BY_TIMESTAMP  0x07 // Argument: unix-Zeit (u64 BE), Block mit nächstem Payload-Timestamp
+#define PRECONF_SOCKET_OP_AT_OR_BEFORE  0x08 // Argument: unix-Zeit (u64 BE), neuester Block mit Timestamp <= Zeit
+#define PRECONF_SOCKET_OP_STATS         0x09 // Body: Speicher-Statistiken als JSON
+#define PRECONF_SOCKET_OP_RENDER        0x0a // u64 BE Blocknummer, Body: Block als JSON (eth_getBlockByNumber)
+
+#define PRECONF_SOCKET_OK          0x00
+#define PRECONF_SOCKET_NOT_FOUND   0x01
+#define PRECONF_SOCKET_BAD_REQUEST 0x02
+#define PRECONF_SOCKET_ERROR       0x03
+#define PRECONF_SOCKET_UNAVAILABLE 0xFF // Verbindung/Timeout/Protokollfehler auf C-Seite
+
+/**
+ * Callback für eine Socket-Abfrage
+ *
+ * @param user_data Wert aus preconf_socket_query
+ * @param status PRECONF_SOCKET_* Status
+ * @param body Bei PRECONF_SOCKET_OK die .raw Daten (gehören dem Callback), sonst NULL_BYTES
+ */
+typedef void (*preconf_socket_cb)(void* user_data, uint8_t status, bytes_t body);
+
+/**
+ * Fragt einen Preconf asynchron über den Unix-Socket der Bridge ab
+ *
+ * Der Callback wird immer genau einmal auf dem Default-Loop aufgerufen, auch wenn
+ * der Socket nicht erreichbar ist (dann mit PRECONF_SOCKET_UNAVAILABLE).
+ *
+ * @param socket_path Pfad des Sockets ({preconf_storage_dir}/preconf.sock)
+ * @param op PRECONF_SOCKET_OP_*
+ * @param arg Argument (8 Bytes Blocknummer big-endian bzw. 32 Bytes Hash), sonst NULL_BYTES
+ * @param user_data Wird an den Callback durchgereicht
+ * @param cb Callback
+ */
+void preconf_socket_query(const char* socket_path, uint8_t op, bytes_t arg, void* user_data, preconf_socket_cb cb);
+
+#ifdef __cplusplus
+}
+#endif
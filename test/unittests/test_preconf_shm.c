@@ -0,0 +1,160 @@
+/*
+ * Tests for the reader of the kona bridge shared-memory index (src/chains/op/server/preconf_shm.c)
+ */
+
+#include "unity.h"
+
+#if defined(HTTP_SERVER) && defined(OP_SERVER_HANDLER) && !defined(_WIN32)
+
+#include "chains/op/server/preconf_shm.h"
+
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <unistd.h>
+
+// Layout as written by kona_bridge/src/shm.rs
+#define SHM_MAGIC       0x4B53484Du
+#define SHM_VERSION     1
+#define SHM_HEADER_SIZE 64
+#define SHM_SLOT_SIZE   128
+#define SHM_CAPACITY    16
+#define CHAIN_ID        10
+
+static char    g_path[256];
+static uint8_t g_ring[SHM_HEADER_SIZE + SHM_CAPACITY * SHM_SLOT_SIZE];
+
+static void put_u32(size_t offset, uint32_t value) {
+  memcpy(g_ring + offset, &value, sizeof(value));
+}
+
+static void put_u64(size_t offset, uint64_t value) {
+  memcpy(g_ring + offset, &value, sizeof(value));
+}
+
+static uint64_t get_u64(size_t offset) {
+  uint64_t value;
+  memcpy(&value, g_ring + offset, sizeof(value));
+  return value;
+}
+
+// Same as ShmIndex::publish: next slot, latest_block only moves up
+static void publish(uint64_t block_number) {
+  uint64_t head = get_u64(24);
+  size_t   slot = SHM_HEADER_SIZE + (size_t) (head % SHM_CAPACITY) * SHM_SLOT_SIZE;
+  char     name[64];
+  snprintf(name, sizeof(name), "block_%d_%llu.raw", CHAIN_ID, (unsigned long long) block_number);
+  put_u64(slot, get_u64(slot) + 2);
+  put_u64(slot + 8, block_number);
+  memset(g_ring + slot + 16, (int) block_number, 32);
+  memset(g_ring + slot + 64, 0, 64);
+  memcpy(g_ring + slot + 64, name, strlen(name));
+  if (block_number > get_u64(32)) put_u64(32, block_number);
+  put_u64(24, head + 1);
+}
+
+// Writes the ring as a new file (new inode, so the reader remaps it)
+static void flush_ring(void) {
+  char  temp[300];
+  FILE* f;
+  snprintf(temp, sizeof(temp), "%s.tmp", g_path);
+  f = fopen(temp, "wb");
+  TEST_ASSERT_NOT_NULL(f);
+  TEST_ASSERT_EQUAL(sizeof(g_ring), fwrite(g_ring, 1, sizeof(g_ring), f));
+  fclose(f);
+  TEST_ASSERT_EQUAL(0, rename(temp, g_path));
+}
+
+void setUp(void) {
+  snprintf(g_path, sizeof(g_path), "/tmp/test_preconf_shm_%d.shm", (int) getpid());
+  memset(g_ring, 0, sizeof(g_ring));
+  put_u32(0, SHM_MAGIC);
+  put_u32(4, SHM_VERSION);
+  put_u64(8, CHAIN_ID);
+  put_u32(16, SHM_CAPACITY);
+  put_u32(20, SHM_SLOT_SIZE);
+}
+
+void tearDown(void) {
+  preconf_shm_close();
+  unlink(g_path);
+}
+
+static uint64_t block_at(uint32_t back) {
+  preconf_shm_entry_t entry = {0};
+  TEST_ASSERT_TRUE(preconf_shm_get(g_path, CHAIN_ID, back, &entry));
+  return entry.block_number;
+}
+
+static void test_latest_in_publish_order(void) {
+  publish(100);
+  publish(101);
+  publish(102);
+  flush_ring();
+
+  preconf_shm_entry_t entry = {0};
+  TEST_ASSERT_TRUE(preconf_shm_get(g_path, CHAIN_ID, 0, &entry));
+  TEST_ASSERT_EQUAL_UINT64(102, entry.block_number);
+  TEST_ASSERT_EQUAL_STRING("block_10_102.raw", entry.filename);
+  TEST_ASSERT_EQUAL_UINT64(101, block_at(1));
+}
+
+static void test_latest_after_backfill(void) {
+  // Backfill of older blocks after 102: the most recent slots do not hold the newest block
+  publish(100);
+  publish(101);
+  publish(102);
+  publish(90);
+  publish(91);
+  flush_ring();
+
+  TEST_ASSERT_EQUAL_UINT64(102, block_at(0));
+  TEST_ASSERT_EQUAL_UINT64(101, block_at(1));
+
+  publish(103);
+  flush_ring();
+  TEST_ASSERT_EQUAL_UINT64(103, block_at(0));
+  TEST_ASSERT_EQUAL_UINT64(102, block_at(1));
+}
+
+static void test_reorg_replaces_block(void) {
+  // Reorg: block 101 is published again, the newer slot wins
+  publish(100);
+  publish(101);
+  publish(101);
+  memset(g_ring + SHM_HEADER_SIZE + 2 * SHM_SLOT_SIZE + 16, 0xab, 32);
+  flush_ring();
+
+  preconf_shm_entry_t entry = {0};
+  TEST_ASSERT_TRUE(preconf_shm_get(g_path, CHAIN_ID, 0, &entry));
+  TEST_ASSERT_EQUAL_UINT64(101, entry.block_number);
+  TEST_ASSERT_EQUAL_HEX8(0xab, entry.block_hash[0]);
+  TEST_ASSERT_EQUAL_UINT64(100, block_at(1));
+}
+
+static void test_rejects_other_chain_and_missing_file(void) {
+  preconf_shm_entry_t entry = {0};
+  TEST_ASSERT_FALSE(preconf_shm_get(g_path, CHAIN_ID, 0, &entry));
+
+  publish(100);
+  flush_ring();
+  TEST_ASSERT_FALSE(preconf_shm_get(g_path, CHAIN_ID + 1, 0, &entry));
+  TEST_ASSERT_FALSE(preconf_shm_get(g_path, CHAIN_ID, 1, &entry));
+  TEST_ASSERT_TRUE(preconf_shm_get(g_path, CHAIN_ID, 0, &entry));
+}
+
+int main(void) {
+  UNITY_BEGIN();
+  RUN_TEST(test_latest_in_publish_order);
+  RUN_TEST(test_latest_after_backfill);
+  RUN_TEST(test_reorg_replaces_block);
+  RUN_TEST(test_rejects_other_chain_and_missing_file);
+  return UNITY_END();
+}
+
+#else
+int main(void) {
+  fprintf(stderr, "test_preconf_shm: Skipped (HTTP_SERVER with op server handler on unix required)\n");
+  return 0;
+}
+#endif